@@ -0,0 +1,279 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package client exposes qbec operations for use by other Go programs. It loads qbec apps, evaluates components
+// for an environment and provides a Kubernetes client to apply, delete and list the objects produced.
+// The package behaves like the qbec command and respects the same environment variables (e.g. QBEC_ROOT).
+// Paths configured in the app, such as component files and library paths, are resolved relative to the qbec root.
+// Loading an app does not change the working directory of the process.
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/eval"
+	"github.com/splunk/qbec/internal/filematcher"
+	"github.com/splunk/qbec/internal/model"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Meta is the metadata of a Kubernetes object along with the qbec attributes of the app that produced it.
+type Meta interface {
+	GetKind() string
+	GroupVersionKind() schema.GroupVersionKind
+	GetNamespace() string
+	GetName() string
+	GetGenerateName() string
+	GetAnnotations() map[string]string
+	Application() string // the application name
+	Component() string   // the component name
+	Environment() string // the environment name
+	Tag() string         // the app tag
+}
+
+// Object is a Kubernetes object produced by a component.
+type Object interface {
+	Meta
+	ToUnstructured() *unstructured.Unstructured
+}
+
+// Options are the options to load an app. They correspond to the global options of the qbec command.
+type Options struct {
+	Root       string            // the qbec root directory, auto-detected from the working directory when blank
+	AppTag     string            // tag for GC scope
+	EnvFile    string            // additional environment file not declared in qbec.yaml
+	Kubeconfig string            // path to a kubeconfig file, alternative to the KUBECONFIG environment variable
+	ExtStrs    map[string]string // external string variables
+	ExtCodes   map[string]string // external code variables
+	Args       []string          // additional global options of the qbec command, e.g. "--vm:tla-str=foo=bar"
+}
+
+func (o Options) args() []string {
+	var ret []string
+	add := func(name, value string) {
+		if value != "" {
+			ret = append(ret, fmt.Sprintf("--%s=%s", name, value))
+		}
+	}
+	addVars := func(name string, vars map[string]string) {
+		var keys []string
+		for k := range vars {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ret = append(ret, fmt.Sprintf("--%s=%s=%s", name, k, vars[k]))
+		}
+	}
+	add("root", o.Root)
+	add("app-tag", o.AppTag)
+	add("env-file", o.EnvFile)
+	add("k8s:kubeconfig", o.Kubeconfig)
+	addVars("vm:ext-str", o.ExtStrs)
+	addVars("vm:ext-code", o.ExtCodes)
+	return append(ret, o.Args...)
+}
+
+// App is a qbec app loaded from a qbec.yaml file.
+type App struct {
+	ctx cmd.AppContext
+}
+
+// Load loads the app at the root specified in the supplied options or, when blank, the app at the closest
+// directory at or above the working directory that has an app file.
+func Load(opts Options) (*App, error) {
+	return load(opts, cmd.Options{SkipConfirm: true})
+}
+
+func load(opts Options, cmdOpts cmd.Options) (*App, error) {
+	root := &cobra.Command{Use: "qbec"}
+	ctxFn := cmd.NewContext(root, cmdOpts)
+	if err := root.ParseFlags(opts.args()); err != nil {
+		return nil, errors.Wrap(err, "options")
+	}
+	ctx, err := ctxFn()
+	if err != nil {
+		return nil, err
+	}
+	// environment files are resolved with respect to the current working directory
+	var envFiles []string
+	for _, envFile := range ctx.EnvFiles() {
+		files, err := filematcher.Match(envFile)
+		if err != nil {
+			return nil, err
+		}
+		envFiles = append(envFiles, files...)
+	}
+	dir, err := findRoot(ctx.RootDir())
+	if err != nil {
+		return nil, err
+	}
+	file, err := model.FindAppFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	app, err := model.NewAppWithVars(file, envFiles, ctx.AppTag(), ctx.InterpolationVars())
+	if err != nil {
+		return nil, err
+	}
+	forceOpts, err := ctx.ForceOptions()
+	if err != nil {
+		return nil, err
+	}
+	app.SetOverrideNamespace(forceOpts.K8sNamespace)
+	appCtx, err := ctx.AppContext(app)
+	if err != nil {
+		return nil, err
+	}
+	return &App{ctx: appCtx}, nil
+}
+
+// findRoot returns the absolute path of the supplied root or, when blank, of the closest directory
+// at or above the working directory that has an app file.
+func findRoot(root string) (string, error) {
+	isRoot := func(dir string) bool {
+		for _, f := range model.AppFiles {
+			if _, err := os.Stat(filepath.Join(dir, f)); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+	if root != "" {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return "", err
+		}
+		if !isRoot(abs) {
+			return "", fmt.Errorf("specified root %q not valid, does not contain any of %s", abs, strings.Join(model.AppFiles, ", "))
+		}
+		return abs, nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for dir := cwd; ; {
+		if isRoot(dir) {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("unable to find source root at or above %s", cwd)
+		}
+		dir = parent
+	}
+}
+
+// Close releases resources held by the app, such as processes started by data sources.
+func (a *App) Close() error {
+	return cmd.Close()
+}
+
+// Name returns the name of the app.
+func (a *App) Name() string {
+	return a.ctx.App().Name()
+}
+
+// Environments returns the sorted names of the environments defined for the app.
+func (a *App) Environments() []string {
+	var ret []string
+	for name := range a.ctx.App().Environments() {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Components returns the sorted names of the components for the supplied environment.
+func (a *App) Components(env string) ([]string, error) {
+	comps, err := a.ctx.App().ComponentsForEnvironment(env, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, c := range comps {
+		ret = append(ret, c.Name)
+	}
+	return ret, nil
+}
+
+// Environment returns the supplied environment of the app. Data sources are initialized and computed
+// variables are evaluated at this time.
+func (a *App) Environment(name string) (*Environment, error) {
+	if name == model.Baseline {
+		return nil, fmt.Errorf("cannot use the baseline environment, use a real environment")
+	}
+	if _, ok := a.ctx.App().Environments()[name]; !ok {
+		return nil, fmt.Errorf("invalid environment %q", name)
+	}
+	envCtx, err := a.ctx.EnvContext(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Environment{ctx: envCtx}, nil
+}
+
+// Environment is an environment of an app.
+type Environment struct {
+	ctx cmd.EnvContext
+}
+
+// Name returns the name of the environment.
+func (e *Environment) Name() string {
+	return e.ctx.Env()
+}
+
+// DefaultNamespace returns the default namespace of the environment.
+func (e *Environment) DefaultNamespace() string {
+	return e.ctx.App().DefaultNamespace(e.ctx.Env())
+}
+
+// Objects evaluates the supplied components, or all components of the environment when none are specified,
+// and returns the objects produced.
+func (e *Environment) Objects(ctx context.Context, components ...string) ([]Object, error) {
+	comps, err := e.ctx.App().ComponentsForEnvironment(e.ctx.Env(), components, nil)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := eval.Components(comps, e.ctx.EvalContext(false), e.ctx.ObjectProducer())
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]Object, 0, len(objs))
+	for _, o := range objs {
+		ret = append(ret, o)
+	}
+	return ret, nil
+}
+
+// Client returns a Kubernetes client for the environment.
+func (e *Environment) Client() (*Client, error) {
+	kc, err := e.ctx.Client()
+	if err != nil {
+		return nil, err
+	}
+	return &Client{env: e, inner: kc}, nil
+}
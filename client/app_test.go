@@ -0,0 +1,188 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeClient implements the operations needed by the client. Other operations panic.
+type fakeClient struct {
+	cmd.KubeClient
+	syncOpts  []remote.SyncOptions
+	deleted   []string
+	listScope remote.ListQueryConfig
+	remote    []model.K8sQbecMeta
+}
+
+func (f *fakeClient) IsNamespaced(gvk schema.GroupVersionKind) (bool, error) {
+	return gvk.Kind != "Namespace" && gvk.Kind != "ClusterRole", nil
+}
+
+func (f *fakeClient) Sync(_ context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+	f.syncOpts = append(f.syncOpts, opts)
+	return &remote.SyncResult{Type: remote.SyncCreated, Details: "created " + obj.GetName()}, nil
+}
+
+func (f *fakeClient) Delete(_ context.Context, obj model.K8sMeta, opts remote.DeleteOptions) (*remote.SyncResult, error) {
+	f.deleted = append(f.deleted, obj.GetName())
+	return &remote.SyncResult{Type: remote.SyncDeleted}, nil
+}
+
+type fakeCollection []model.K8sQbecMeta
+
+func (f fakeCollection) Remove(_ []model.K8sQbecMeta) error { return nil }
+func (f fakeCollection) ToList() []model.K8sQbecMeta        { return f }
+
+func (f *fakeClient) ListObjects(_ context.Context, scope remote.ListQueryConfig) (remote.Collection, error) {
+	f.listScope = scope
+	return fakeCollection(f.remote), nil
+}
+
+func loadTestApp(t *testing.T, opts Options) (*App, *fakeClient) {
+	fc := &fakeClient{}
+	app, err := load(opts, cmd.Options{
+		SkipConfirm:    true,
+		ClientProvider: func(env string) (cmd.KubeClient, error) { return fc, nil },
+	})
+	require.NoError(t, err)
+	return app, fc
+}
+
+func TestAppBasic(t *testing.T) {
+	app, _ := loadTestApp(t, Options{Root: "../examples/test-app", AppTag: "t1"})
+	a := assert.New(t)
+	a.Equal("example1", app.Name())
+	a.Equal([]string{"dev", "local", "prod", "stage"}, app.Environments())
+	comps, err := app.Components("dev")
+	require.NoError(t, err)
+	a.Equal([]string{"cluster-objects", "service2", "test-job"}, comps)
+
+	env, err := app.Environment("dev")
+	require.NoError(t, err)
+	a.Equal("dev", env.Name())
+	a.Equal("default", env.DefaultNamespace())
+	objs, err := env.Objects(context.Background(), "service2")
+	require.NoError(t, err)
+	require.NotEmpty(t, objs)
+	for _, o := range objs {
+		a.Equal("service2", o.Component())
+		a.Equal("dev", o.Environment())
+		a.Equal("example1", o.Application())
+		a.Equal("t1", o.Tag())
+	}
+}
+
+func TestAppKeepsWorkDir(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	app, _ := loadTestApp(t, Options{Root: "../examples/test-app"})
+	env, err := app.Environment("dev")
+	require.NoError(t, err)
+	objs, err := env.Objects(context.Background())
+	require.NoError(t, err)
+	require.NotEmpty(t, objs)
+	after, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, wd, after)
+}
+
+func TestAppVars(t *testing.T) {
+	app, _ := loadTestApp(t, Options{
+		Root:     "../examples/test-app",
+		ExtStrs:  map[string]string{"externalFoo": "baz"},
+		ExtCodes: map[string]string{"extraCode": "10"},
+		Args:     []string{"--vm:tla-str=tlaFoo=foo"},
+	})
+	env, err := app.Environment("dev")
+	require.NoError(t, err)
+	_, err = env.Objects(context.Background())
+	require.NoError(t, err)
+}
+
+func TestAppJsonnet(t *testing.T) {
+	app, _ := loadTestApp(t, Options{Root: "../internal/commands/testdata/projects/jsonnet-app"})
+	a := assert.New(t)
+	a.Equal("jsonnet-app", app.Name())
+	a.Equal([]string{"dev-eu-west", "dev-us-east", "prod-eu-west", "prod-us-east"}, app.Environments())
+	env, err := app.Environment("prod-eu-west")
+	require.NoError(t, err)
+	a.Equal("web", env.DefaultNamespace())
+}
+
+func TestAppNegative(t *testing.T) {
+	_, err := Load(Options{Root: t.TempDir()})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not contain any of qbec.yaml, qbec.jsonnet")
+
+	_, err = Load(Options{Args: []string{"--no-such-option"}})
+	require.Error(t, err)
+	assert.Equal(t, "options: unknown flag: --no-such-option", err.Error())
+
+	app, _ := loadTestApp(t, Options{Root: "../examples/test-app"})
+	_, err = app.Environment("_")
+	require.Error(t, err)
+	assert.Equal(t, "cannot use the baseline environment, use a real environment", err.Error())
+	_, err = app.Environment("foo")
+	require.Error(t, err)
+	assert.Equal(t, `invalid environment "foo"`, err.Error())
+	_, err = app.Components("foo")
+	require.Error(t, err)
+}
+
+func TestClient(t *testing.T) {
+	app, fc := loadTestApp(t, Options{Root: "../examples/test-app"})
+	env, err := app.Environment("dev")
+	require.NoError(t, err)
+	objs, err := env.Objects(context.Background())
+	require.NoError(t, err)
+	c, err := env.Client()
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	res, err := c.Sync(ctx, objs[0], SyncOptions{DryRun: true, NoWaitForType: true})
+	require.NoError(t, err)
+	assert.Equal(t, ResultCreated, res.Type)
+	assert.Equal(t, "created "+objs[0].GetName(), res.Details)
+	require.Equal(t, 1, len(fc.syncOpts))
+	assert.True(t, fc.syncOpts[0].DryRun)
+	assert.True(t, fc.syncOpts[0].WaitOptions.NoWait)
+
+	res, err = c.Delete(ctx, objs[0], false)
+	require.NoError(t, err)
+	assert.Equal(t, ResultDeleted, res.Type)
+	assert.Equal(t, []string{objs[0].GetName()}, fc.deleted)
+
+	fc.remote = []model.K8sQbecMeta{objs[0]}
+	list, err := c.ListObjects(ctx, objs)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(list))
+	assert.Equal(t, objs[0].GetName(), list[0].GetName())
+	assert.Equal(t, "example1", fc.listScope.Application)
+	assert.Equal(t, "dev", fc.listScope.Environment)
+	assert.Equal(t, []string{"bar-system", "default"}, fc.listScope.Namespaces)
+	assert.True(t, fc.listScope.ClusterObjects)
+}
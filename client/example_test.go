@@ -0,0 +1,54 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/splunk/qbec/client"
+)
+
+func Example() {
+	app, err := client.Load(client.Options{Root: "path/to/app", ExtStrs: map[string]string{"imageTag": "v1.2.3"}})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer app.Close()
+
+	env, err := app.Environment("dev")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	ctx := context.Background()
+	objects, err := env.Objects(ctx)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	c, err := env.Client()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	for _, o := range objects {
+		res, err := c.Sync(ctx, o, client.SyncOptions{DryRun: true})
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(c.DisplayName(o), res.Type)
+	}
+}
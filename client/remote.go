@@ -0,0 +1,166 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"sort"
+
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ErrNotFound is returned by Get when the remote object does not exist.
+var ErrNotFound = remote.ErrNotFound
+
+// ResultType indicates what happened to an object in a sync or delete operation.
+type ResultType string
+
+// Result types
+const (
+	ResultIdentical ResultType = "identical" // local and remote objects are identical, nothing was done
+	ResultSkipped   ResultType = "skipped"   // the object was skipped, for example when creation was needed but disabled
+	ResultCreated   ResultType = "created"   // the object was created
+	ResultUpdated   ResultType = "updated"   // the object was updated
+	ResultDeleted   ResultType = "deleted"   // the object was deleted
+)
+
+var resultTypes = map[remote.SyncResultType]ResultType{
+	remote.SyncObjectsIdentical: ResultIdentical,
+	remote.SyncSkip:             ResultSkipped,
+	remote.SyncCreated:          ResultCreated,
+	remote.SyncUpdated:          ResultUpdated,
+	remote.SyncDeleted:          ResultDeleted,
+}
+
+// Result is the result of a sync or delete operation. The result is the same for real and dry-run operations.
+type Result struct {
+	Type          ResultType // what happened to the object
+	GeneratedName string     // the actual name of an object that has generateName set
+	Details       string     // additional details that are safe to display, secrets are obfuscated unless requested
+}
+
+func toResult(r *remote.SyncResult) *Result {
+	if r == nil {
+		return nil
+	}
+	return &Result{Type: resultTypes[r.Type], GeneratedName: r.GeneratedName, Details: r.Details}
+}
+
+// SyncOptions are options for the sync operation.
+type SyncOptions struct {
+	DryRun        bool // do not actually create or update objects, return what would happen
+	DisableCreate bool // only update objects if they exist, do not create new ones
+	NoWaitForType bool // do not wait for custom resource definitions to be established after they are applied
+	ShowSecrets   bool // show secret values in details
+}
+
+// Client performs remote operations for the objects of an environment.
+type Client struct {
+	env   *Environment
+	inner cmd.KubeClient
+}
+
+// DisplayName returns a name for the supplied object that is suitable for display.
+func (c *Client) DisplayName(obj Meta) string {
+	return c.inner.DisplayName(obj)
+}
+
+// Get returns the remote object corresponding to the supplied object, or ErrNotFound if it does not exist.
+func (c *Client) Get(ctx context.Context, obj Meta) (*unstructured.Unstructured, error) {
+	return c.inner.Get(ctx, obj)
+}
+
+// Sync creates or updates the remote object for the supplied local object.
+func (c *Client) Sync(ctx context.Context, obj Object, opts SyncOptions) (*Result, error) {
+	res, err := c.inner.Sync(ctx, obj, remote.SyncOptions{
+		DryRun:        opts.DryRun,
+		DisableCreate: opts.DisableCreate,
+		WaitOptions:   remote.TypeWaitOptions{NoWait: opts.NoWaitForType},
+		ShowSecrets:   opts.ShowSecrets,
+	})
+	return toResult(res), err
+}
+
+// Delete deletes the remote object corresponding to the supplied object.
+func (c *Client) Delete(ctx context.Context, obj Meta, dryRun bool) (*Result, error) {
+	res, err := c.inner.Delete(ctx, obj, remote.DeleteOptions{DryRun: dryRun})
+	return toResult(res), err
+}
+
+// ListObjects returns the remote objects that were created by the app for the environment, in the default
+// namespace of the environment and the namespaces of the supplied local objects. Cluster scoped objects are
+// listed when any of the local objects is cluster scoped. Objects that exist remotely but not in the supplied
+// list are candidates for garbage collection.
+func (c *Client) ListObjects(ctx context.Context, objects []Object) ([]Meta, error) {
+	app := c.env.ctx.App()
+	defaultNs := c.env.DefaultNamespace()
+	nsMap := map[string]bool{}
+	if defaultNs != "" {
+		nsMap[defaultNs] = true
+	}
+	clusterObjects := false
+	for _, o := range objects {
+		namespaced, err := c.inner.IsNamespaced(o.GroupVersionKind())
+		if err != nil {
+			return nil, err
+		}
+		if !namespaced {
+			clusterObjects = true
+			continue
+		}
+		ns := o.GetNamespace()
+		if ns == "" {
+			ns = defaultNs
+		}
+		nsMap[ns] = true
+	}
+	var namespaces []string
+	for ns := range nsMap {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	coll, err := c.inner.ListObjects(ctx, remote.ListQueryConfig{
+		Application: app.Name(),
+		Tag:         app.Tag(),
+		Environment: c.env.Name(),
+		ListQueryScope: remote.ListQueryScope{
+			Namespaces:     namespaces,
+			ClusterObjects: clusterObjects,
+		},
+		ClusterScopedLists: app.ClusterScopedLists(),
+		Limit:              c.env.ctx.ListPageSize(),
+		Concurrency:        c.env.ctx.ListConcurrency(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	list := coll.ToList()
+	ret := make([]Meta, 0, len(list))
+	for _, o := range list {
+		ret = append(ret, o)
+	}
+	return ret, nil
+}
+
+// compile time check that the public types are compatible with the internal ones
+var (
+	_ model.K8sQbecMeta    = Meta(nil)
+	_ model.K8sLocalObject = Object(nil)
+)
@@ -19,19 +19,37 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/eval"
 	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
 	"github.com/splunk/qbec/internal/sio"
+	"github.com/splunk/qbec/internal/types"
 	"github.com/splunk/qbec/vm"
 )
 
+// AutoEnv is the special environment name that is resolved to the environment corresponding to the
+// current kubeconfig context.
+const AutoEnv = "auto"
+
 // AppContext is a context that also has a validated app.
 type AppContext struct {
 	Context
 	app  *model.App
 	vars vm.VariableSet
 	vmc  vm.Config
+	// libraries loaded from library bundles, keyed by name
+	libraries map[string]fs.FS
+	// imports from HTTP library paths, nil when none are declared
+	httpImports *vm.HTTPImports
+	// annotations with source metadata added to all objects, nil when not enabled
+	sourceAnnotations map[string]string
 }
 
 // App returns the app set up for this context.
@@ -39,6 +57,15 @@ func (c AppContext) App() *model.App {
 	return c.app
 }
 
+// EvalCache returns the cache for component outputs under the root directory of the app, or nil if caching is
+// not enabled.
+func (c AppContext) EvalCache() *eval.Cache {
+	if !c.evalCache {
+		return nil
+	}
+	return eval.NewCache(filepath.Join(c.app.Root(), evalCacheDir), c.version)
+}
+
 func (c *AppContext) init() error {
 	var msgs []string
 	c.ext = c.ext.WithLibPaths(c.app.LibPaths())
@@ -113,22 +140,123 @@ func (c *AppContext) init() error {
 	}
 
 	c.vars = vs.WithVars(addVars...)
+	libs, err := loadLibraryBundles(c.app.LibraryBundles())
+	if err != nil {
+		return err
+	}
+	c.libraries = libs
+	c.httpImports = newHTTPImports(c.app.HTTPLibPaths())
+	types.SetRedactions(c.app.Redactions())
 	c.vmc = vm.Config{
-		LibPaths: c.ext.LibPaths,
+		LibPaths:           c.ext.LibPaths,
+		MaxDataSourceBytes: c.MaxDataSourceBytes(),
+		Libraries:          libs,
+		HTTPImports:        c.httpImports,
+	}
+	if sa := c.app.SourceAnnotations(); c.annotateSource || sa.Enabled {
+		c.sourceAnnotations = sourceAnnotations(runGit, c.app.Root(), c.version, sa.Timestamp, time.Now())
 	}
 	return nil
 }
 
+// loadLibraryBundles loads the supplied library bundles keyed by library name.
+func loadLibraryBundles(bundles map[string]string) (map[string]fs.FS, error) {
+	if len(bundles) == 0 {
+		return nil, nil
+	}
+	ret := map[string]fs.FS{}
+	for name, file := range bundles {
+		lib, err := vm.LoadLibraryBundle(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "library %s", name)
+		}
+		ret[name] = lib
+	}
+	return ret, nil
+}
+
+// newHTTPImports returns HTTP imports for the supplied library paths, or nil if there are none. Pinned files are
+// cached in the qbec directory under the user cache directory.
+func newHTTPImports(paths []model.HTTPLibPath) *vm.HTTPImports {
+	if len(paths) == 0 {
+		return nil
+	}
+	var cacheDir string
+	if dir, err := os.UserCacheDir(); err == nil {
+		cacheDir = filepath.Join(dir, "qbec", "http-imports")
+	} else {
+		sio.Warnf("no cache directory for HTTP imports, %v\n", err)
+	}
+	var ps []vm.HTTPLibPath
+	for _, p := range paths {
+		ps = append(ps, vm.HTTPLibPath{URL: p.URL, SHA256: p.SHA256})
+	}
+	return vm.NewHTTPImports(ps, cacheDir)
+}
+
+// CurrentEnv returns the name of the environment that corresponds to the current kubeconfig context, along with
+// the context information. It returns an error when no environment, or more than one environment, matches.
+func (c AppContext) CurrentEnv() (string, *remote.ContextInfo, error) {
+	info, err := c.KubeContextInfo()
+	if err != nil {
+		return "", nil, errors.Wrap(err, "get current context")
+	}
+	envs := c.app.EnvironmentsForContext(info.ContextName, info.ServerURL)
+	switch len(envs) {
+	case 0:
+		return "", nil, fmt.Errorf("no environment matches current context %s (server %s)", info.ContextName, info.ServerURL)
+	case 1:
+		return envs[0], info, nil
+	default:
+		return "", nil, fmt.Errorf("multiple environments match current context %s (server %s): %s, specify one explicitly",
+			info.ContextName, info.ServerURL, strings.Join(envs, ", "))
+	}
+}
+
+// ResolveEnv returns the supplied environment name, except when it is the special name "auto" and the app does not
+// define an environment with that name. In that case the environment corresponding to the current kubeconfig context
+// is returned.
+func (c AppContext) ResolveEnv(env string) (string, error) {
+	if env != AutoEnv {
+		return env, nil
+	}
+	if _, ok := c.app.Environments()[env]; ok {
+		return env, nil
+	}
+	ret, _, err := c.CurrentEnv()
+	if err != nil {
+		return "", err
+	}
+	sio.Debugf("resolved environment %s from current context\n", ret)
+	return ret, nil
+}
+
+// ResolveEnvs resolves every environment in the supplied list using ResolveEnv. Blank names are rejected.
+func (c AppContext) ResolveEnvs(envs []string) ([]string, error) {
+	ret := make([]string, 0, len(envs))
+	for _, e := range envs {
+		if strings.TrimSpace(e) == "" {
+			return nil, NewUsageError(fmt.Sprintf("environment names must not be empty, but provided: %q", envs))
+		}
+		r, err := c.ResolveEnv(e)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, r)
+	}
+	return ret, nil
+}
+
 // EnvContext returns an execution context for the specified environment.
 func (c AppContext) EnvContext(env string) (EnvContext, error) {
+	if err := c.app.AssertAppTagAllowed(env); err != nil {
+		return EnvContext{}, err
+	}
 	props, err := c.app.Properties(env)
 	if err != nil {
 		return EnvContext{}, err
 	}
 	ret := EnvContext{AppContext: c, env: env, props: props}
-	if err := ret.initEnv(); err != nil {
-		return EnvContext{}, err
-	}
 	fc, err := c.forceOptsFn()
 	if err != nil {
 		return EnvContext{}, err
@@ -145,5 +273,9 @@ func (c AppContext) EnvContext(env string) (EnvContext, error) {
 	if ret.attrsp == nil {
 		ret.attrsp = sp.Attrs
 	}
+	// data sources are created after the client provider is set up so that they can look up cluster objects
+	if err := ret.initEnv(); err != nil {
+		return EnvContext{}, err
+	}
 	return ret, nil
 }
@@ -81,3 +81,21 @@ func TestConfigStrictVarsFail(t *testing.T) {
 	a.Contains(msg, "specified top level variable 'tlaBurble' not declared for app")
 	a.Contains(msg, "declared top level variable 'tlaFoo' not specfied for command")
 }
+
+func TestResolveEnvsBlank(t *testing.T) {
+	fn := setPwd(t, "testdata")
+	defer fn()
+	app, err := model.NewApp("qbec.yaml", nil, "")
+	require.NoError(t, err)
+
+	ctx := getContext(t, Options{}, []string{})
+	ac, err := ctx.AppContext(app)
+	require.NoError(t, err)
+	envs, err := ac.ResolveEnvs([]string{"dev", "prod"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dev", "prod"}, envs)
+	_, err = ac.ResolveEnvs([]string{"dev", " "})
+	require.Error(t, err)
+	assert.True(t, IsUsageError(err))
+	assert.Equal(t, `environment names must not be empty, but provided: ["dev" " "]`, err.Error())
+}
@@ -24,7 +24,10 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/chzyer/readline"
 	"github.com/mattn/go-isatty"
@@ -33,6 +36,7 @@ import (
 	"github.com/splunk/qbec/internal/model"
 	"github.com/splunk/qbec/internal/remote"
 	"github.com/splunk/qbec/internal/remote/k8smeta"
+	"github.com/splunk/qbec/internal/sio"
 	"github.com/splunk/qbec/internal/vmexternals"
 	"github.com/splunk/qbec/vm"
 	"github.com/splunk/qbec/vm/datasource"
@@ -52,6 +56,9 @@ type KubeClient interface {
 	Delete(context.Context, model.K8sMeta, remote.DeleteOptions) (*remote.SyncResult, error)
 	ObjectKey(obj model.K8sMeta) string
 	ResourceInterface(obj schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error)
+	PodLogs(ctx context.Context, namespace, pod string, opts remote.PodLogOptions) (io.ReadCloser, error)
+	ServerDryRun(ctx context.Context, obj model.K8sLocalObject) (*unstructured.Unstructured, error)
+	ServerValidate(ctx context.Context, obj model.K8sLocalObject) ([]string, error)
 }
 
 // ClientProvider returns a kubernetes client for the specific environment
@@ -67,6 +74,7 @@ type Options struct {
 	SkipConfirm       bool
 	ClientProvider    ClientProvider
 	KubeAttrsProvider KubeAttrsProvider
+	Version           string // the qbec version, used for source annotations
 }
 
 // Context is the global context of the qbec command that handles all global options supported by
@@ -83,7 +91,9 @@ type Context struct {
 	colors          bool                         // colorize output
 	yes             bool                         // auto-confirm
 	evalConcurrency int                          // concurrency of component eval
+	evalTimeout     time.Duration                // timeout for evaluating a single component
 	verbose         int                          // verbosity level
+	quiet           bool                         // suppress all non-error output
 	stdin           io.Reader                    // standard input
 	stdout          io.Writer                    // standard output
 	stderr          io.Writer                    // standard error
@@ -91,8 +101,26 @@ type Context struct {
 	profiler        *profiler                    // profiler
 	listPageSize    int                          // page size for list operations
 	app             *model.App                   // app loaded from file
+	version         string                       // qbec version
+	annotateSource  bool                         // add source annotations to all objects
+	maxDSBytes      int64                        // maximum size of the output of a data source
+	maxDSBytesSet   bool                         // whether the maximum size was specified on the command line
+	dsOpts          vm.DataSourceOptions         // options to record or replay data source outputs
+	errorFormat     string                       // format of errors, text or json
+	logFormat       string                       // format of warnings, notices and debug messages, text or json
+	setVars         []string                     // values for variables in environment fields, as name=value
+	interpolation   map[string]string            // values for variables in environment fields keyed by name
+	listCacheFile   string                       // file in which to cache list query results
+	listCacheTTL    time.Duration                // maximum age of cached list query results
+	evalCache       bool                         // cache component outputs across invocations
+	clusterLookups  bool                         // allow data sources to look up objects from the cluster
+	profileRemote   bool                         // print the time taken by remote list queries
+	listProgress    bool                         // show the progress of remote list queries
 }
 
+// defaultMaxDataSourceBytes is the default maximum size of the output of a data source for a single import.
+const defaultMaxDataSourceBytes = 256 * 1024 * 1024
+
 func envOrDefault(name, def string) string {
 	v := os.Getenv(name)
 	if v != "" {
@@ -145,6 +173,8 @@ func NewContext(root *cobra.Command, opts Options) func() (Context, error) {
 		stdout:      opts.Stdout,
 		stderr:      opts.Stderr,
 		yes:         opts.SkipConfirm || skipPrompts(),
+		version:     opts.Version,
+		maxDSBytes:  defaultMaxDataSourceBytes,
 	}
 	cf.stdin = os.Stdin
 	if cf.stdout == nil {
@@ -156,21 +186,82 @@ func NewContext(root *cobra.Command, opts Options) func() (Context, error) {
 
 	root.PersistentFlags().StringVar(&cf.root, "root", defaultRoot(), "root directory of repo (from QBEC_ROOT or auto-detect)")
 	root.PersistentFlags().IntVarP(&cf.verbose, "verbose", "v", cf.verbose, "verbosity level")
+	root.PersistentFlags().BoolVarP(&cf.quiet, "quiet", "q", cf.quiet, "suppress all output to standard error other than errors")
 	root.PersistentFlags().BoolVar(&cf.colors, "colors", cf.colors, "colorize output (set automatically if not specified)")
 	root.PersistentFlags().BoolVar(&cf.yes, "yes", cf.yes, "do not prompt for confirmation. The default value can be overridden by setting QBEC_YES=true")
 	root.PersistentFlags().BoolVar(&cf.strictVars, "strict-vars", cf.strictVars, "require declared variables to be specified, do not allow undeclared variables")
 	root.PersistentFlags().IntVar(&cf.evalConcurrency, "eval-concurrency", cf.evalConcurrency, "concurrency with which to evaluate components")
+	root.PersistentFlags().DurationVar(&cf.evalTimeout, "component-timeout", cf.evalTimeout, "maximum time to evaluate a single component, overrides the componentTimeout setting in qbec.yaml")
+	root.PersistentFlags().BoolVar(&cf.annotateSource, "annotate-source", cf.annotateSource, "annotate all objects with source metadata, same as enabling annotateSource in qbec.yaml")
+	root.PersistentFlags().Int64Var(&cf.maxDSBytes, "max-data-source-bytes", cf.maxDSBytes, "maximum size in bytes of the output of a data source for a single import, 0 for no limit")
+	root.PersistentFlags().StringVar(&cf.dsOpts.RecordDir, "ds-record", "", "record the outputs of all data sources in the supplied directory")
+	root.PersistentFlags().StringVar(&cf.dsOpts.ReplayDir, "ds-replay", "", "use outputs recorded using --ds-record in the supplied directory instead of running data sources")
+	root.PersistentFlags().BoolVar(&cf.clusterLookups, "allow-cluster-lookups", false, "allow k8s data sources to fetch objects from the cluster of the environment during evaluation")
 	root.PersistentFlags().StringVar(&cf.appTag, "app-tag", "", "build tag to create suffixed objects, indicates GC scope")
+	root.PersistentFlags().StringVar(&cf.errorFormat, "error-format", "text", "format of the error printed when a command fails, one of text or json")
+	root.PersistentFlags().StringVar(&cf.logFormat, "log-format", "text", "format of messages written to standard error, one of text or json")
+	root.PersistentFlags().StringVar(&cf.listCacheFile, "remote-cache", "", "file in which to cache the results of listing remote objects, for reuse by subsequent commands")
+	root.PersistentFlags().DurationVar(&cf.listCacheTTL, "remote-cache-ttl", 10*time.Minute, "maximum age of cached remote object lists, 0 for no limit")
+	root.PersistentFlags().BoolVar(&cf.evalCache, "eval-cache", false, "cache the objects produced by components under .qbec/cache/eval and skip evaluating components whose inputs have not changed")
+	root.PersistentFlags().BoolVar(&cf.profileRemote, "profile-remote", false, "print the number of objects and time taken by every list query for remote objects")
+	root.PersistentFlags().StringArrayVar(&cf.setVars, "set", nil, "set a value for a variable referenced by templates in environment fields, as <name>=<value>. Overrides external variable values")
 	root.PersistentFlags().StringVarP(&cf.envFile, "env-file", "E", defaultEnvironmentFile(), "use additional environment file not declared in qbec.yaml")
 
 	return func() (_ Context, err error) {
+		if cf.quiet && cf.verbose > 0 {
+			return cf, NewUsageError("cannot specify both --quiet and --verbose")
+		}
 		if !root.Flags().Changed("colors") {
 			cf.colors = isatty.IsTerminal(os.Stdout.Fd())
 		}
+		cf.maxDSBytesSet = root.PersistentFlags().Changed("max-data-source-bytes")
+		if cf.maxDSBytes < 0 {
+			return cf, NewUsageError(fmt.Sprintf("invalid data source size limit %d, must not be negative", cf.maxDSBytes))
+		}
+		if cf.errorFormat != "text" && cf.errorFormat != "json" {
+			return cf, NewUsageError(fmt.Sprintf("invalid error format %q, must be one of text or json", cf.errorFormat))
+		}
+		if cf.logFormat != "text" && cf.logFormat != "json" {
+			return cf, NewUsageError(fmt.Sprintf("invalid log format %q, must be one of text or json", cf.logFormat))
+		}
+		if cf.dsOpts.RecordDir != "" && cf.dsOpts.ReplayDir != "" {
+			return cf, NewUsageError("cannot specify both --ds-record and --ds-replay")
+		}
+		// resolve directories w.r.t. the current working directory before it is changed to the qbec root
+		for _, dir := range []*string{&cf.dsOpts.RecordDir, &cf.dsOpts.ReplayDir, &cf.listCacheFile} {
+			if *dir != "" {
+				if *dir, err = filepath.Abs(*dir); err != nil {
+					return cf, err
+				}
+			}
+		}
+		if cf.listCacheTTL < 0 {
+			return cf, NewUsageError(fmt.Sprintf("invalid remote cache TTL %v, must not be negative", cf.listCacheTTL))
+		}
+		if cf.remote.ListConcurrency <= 0 {
+			return cf, NewUsageError(fmt.Sprintf("invalid list concurrency %d, must be positive", cf.remote.ListConcurrency))
+		}
+		cf.listProgress = !cf.quiet && cf.logFormat == "text" && cf.stderr == os.Stderr && isatty.IsTerminal(os.Stderr.Fd())
+		if cf.evalTimeout < 0 {
+			return cf, NewUsageError(fmt.Sprintf("invalid component timeout %v, must not be negative", cf.evalTimeout))
+		}
 		cf.ext, err = extConfigFn()
 		if err != nil {
 			return cf, err
 		}
+		cf.interpolation = map[string]string{}
+		for name, v := range cf.ext.Variables.Vars {
+			if !v.Code {
+				cf.interpolation[name] = v.Value
+			}
+		}
+		for _, s := range cf.setVars {
+			parts := strings.SplitN(s, "=", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return cf, NewUsageError(fmt.Sprintf("invalid --set value %q, must be of the form <name>=<value>", s))
+			}
+			cf.interpolation[parts[0]] = parts[1]
+		}
 		cf.profiler, err = profilerFn()
 		if err != nil {
 			return cf, err
@@ -189,6 +280,33 @@ func (c Context) AppTag() string { return c.appTag }
 // ListPageSize returns the page size for kubernetes list operations
 func (c Context) ListPageSize() int64 { return c.remote.ListPageSize }
 
+// ListConcurrency returns the number of kubernetes list operations to execute concurrently.
+func (c Context) ListConcurrency() int { return c.remote.ListConcurrency }
+
+// ListObserver returns a new observer for the list queries executed for a single list query config, or nil if
+// neither progress nor timings need to be shown.
+func (c Context) ListObserver() remote.ListObserver {
+	if !c.listProgress && !c.profileRemote {
+		return nil
+	}
+	return &listObserver{w: c.stderr, progress: c.listProgress, profile: c.profileRemote, json: c.JSONLogs()}
+}
+
+// evalCacheDir is the directory, relative to the qbec root, in which component outputs are cached.
+var evalCacheDir = filepath.Join(".qbec", "cache", "eval")
+
+// ListCache returns the cache for remote list query results, or nil if caching is not enabled.
+func (c Context) ListCache() *remote.ListCache {
+	if c.listCacheFile == "" {
+		return nil
+	}
+	return remote.NewListCache(c.listCacheFile, c.listCacheTTL)
+}
+
+// InterpolationVars returns the values for variables referenced by templates in environment fields. These are
+// the string external variables specified on the command line, overridden by values specified using --set.
+func (c Context) InterpolationVars() map[string]string { return c.interpolation }
+
 // EnvFiles returns additional environment files and URLs
 func (c Context) EnvFiles() []string {
 	if c.envFile == "" {
@@ -197,15 +315,27 @@ func (c Context) EnvFiles() []string {
 	return []string{c.envFile}
 }
 
+// JSONLogs returns true if messages written to standard error must be structured as JSON.
+func (c Context) JSONLogs() bool { return c.logFormat == "json" }
+
 // Colorize returns true if output needs to be colorized.
 func (c Context) Colorize() bool { return c.colors }
 
 // Verbosity returns the log verbosity level
 func (c Context) Verbosity() int { return c.verbose }
 
+// Quiet returns true if all non-error output to standard error should be suppressed.
+func (c Context) Quiet() bool { return c.quiet }
+
 // EvalConcurrency returns the concurrency to be used for evaluating components.
 func (c Context) EvalConcurrency() int { return c.evalConcurrency }
 
+// MaxDataSourceBytes returns the maximum size of the output of a data source for a single import, 0 if unlimited.
+func (c Context) MaxDataSourceBytes() int64 { return c.maxDSBytes }
+
+// ComponentTimeout returns the timeout for evaluating a single component, if specified on the command line.
+func (c Context) ComponentTimeout() time.Duration { return c.evalTimeout }
+
 // Stdout returns the standard output configured for the command.
 func (c Context) Stdout() io.Writer { return c.stdout }
 
@@ -223,14 +353,20 @@ func (c Context) KubeContextInfo() (*remote.ContextInfo, error) {
 	return c.remote.CurrentContextInfo()
 }
 
-// Confirm prompts for confirmation if needed.
+// Confirm prompts for confirmation if needed. When confirmation is skipped, the action is only reported as a
+// message that is subject to quiet mode.
 func (c Context) Confirm(action string) error {
-	_, _ = fmt.Fprintln(c.stderr)
-	_, _ = fmt.Fprintln(c.stderr, action)
-	_, _ = fmt.Fprintln(c.stderr)
 	if c.yes {
+		sio.Println(action)
 		return nil
 	}
+	if c.JSONLogs() {
+		sio.Noticeln(action)
+	} else {
+		_, _ = fmt.Fprintln(c.stderr)
+		_, _ = fmt.Fprintln(c.stderr, action)
+		_, _ = fmt.Fprintln(c.stderr)
+	}
 	inst, err := readline.NewEx(&readline.Config{
 		Prompt:              "Do you want to continue [y/n]: ",
 		Stdin:               ioutil.NopCloser(c.stdin),
@@ -276,16 +412,17 @@ func (c Context) BasicEvalContext() (eval.BaseContext, error) {
 		return eval.BaseContext{}, err
 	}
 	ctx := eval.BaseContext{
-		LibPaths: c.ext.LibPaths,
-		Vars:     c.ext.ToVariableSet(),
-		Verbose:  c.verbose > 1,
+		LibPaths:           c.ext.LibPaths,
+		Vars:               c.ext.ToVariableSet(),
+		Verbose:            c.verbose > 1,
+		MaxDataSourceBytes: c.maxDSBytes,
 	}
 
 	ctx.DataSources = sources
 	return ctx, nil
 }
 func (c *Context) createDataSources() ([]datasource.DataSource, error) {
-	sources, closer, err := vm.CreateDataSources(c.ext.DataSources, vm.ConfigProviderFromVariables(c.ext.ToVariableSet()))
+	sources, closer, err := vm.CreateDataSourcesWithOptions(c.ext.DataSources, vm.ConfigProviderFromVariables(c.ext.ToVariableSet()), c.dsOpts)
 	RegisterCleanupTask(closer)
 	if err != nil {
 		return nil, err
@@ -18,9 +18,12 @@ package cmd
 
 import (
 	"bytes"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/splunk/qbec/internal/sio"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -32,6 +35,7 @@ func TestContextCreateSimple(t *testing.T) {
 	ctx := getContext(t, Options{}, []string{})
 	a.False(ctx.strictVars)
 	a.Equal(0, ctx.Verbosity())
+	a.False(ctx.Quiet())
 	a.Equal("", ctx.AppTag())
 	a.Equal("", ctx.RootDir())
 	a.Nil(ctx.EnvFiles())
@@ -41,6 +45,84 @@ func TestContextCreateSimple(t *testing.T) {
 	a.Equal(os.Stderr, ctx.Stderr())
 }
 
+func TestContextQuiet(t *testing.T) {
+	fn := setPwd(t, "testdata")
+	defer fn()
+	ctx := getContext(t, Options{}, []string{"--quiet"})
+	assert.True(t, ctx.Quiet())
+	err := getBadContext(t, Options{}, []string{"-q", "-v=2"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot specify both --quiet and --verbose")
+}
+
+func TestContextErrorFormat(t *testing.T) {
+	fn := setPwd(t, "testdata")
+	defer fn()
+	ctx := getContext(t, Options{}, []string{"--error-format", "json"})
+	assert.Equal(t, "json", ctx.errorFormat)
+	err := getBadContext(t, Options{}, []string{"--error-format", "xml"})
+	require.Error(t, err)
+	assert.True(t, IsUsageError(err))
+	assert.Equal(t, `invalid error format "xml", must be one of text or json`, err.Error())
+}
+
+func TestContextLogFormat(t *testing.T) {
+	fn := setPwd(t, "testdata")
+	defer fn()
+	ctx := getContext(t, Options{}, []string{})
+	assert.False(t, ctx.JSONLogs())
+	ctx = getContext(t, Options{}, []string{"--log-format", "json"})
+	assert.True(t, ctx.JSONLogs())
+	assert.False(t, ctx.listProgress)
+	err := getBadContext(t, Options{}, []string{"--log-format", "xml"})
+	require.Error(t, err)
+	assert.True(t, IsUsageError(err))
+	assert.Equal(t, `invalid log format "xml", must be one of text or json`, err.Error())
+}
+
+func TestContextInterpolationVars(t *testing.T) {
+	fn := setPwd(t, "testdata")
+	defer fn()
+	ctx := getContext(t, Options{}, []string{"--vm:ext-str", "team=payments", "--vm:ext-str", "region=us-west-2", "--vm:ext-code", "replicas=2", "--set", "team=search", "--set", "suffix=a=b"})
+	assert.Equal(t, map[string]string{"team": "search", "region": "us-west-2", "suffix": "a=b"}, ctx.InterpolationVars())
+	err := getBadContext(t, Options{}, []string{"--set", "team"})
+	require.Error(t, err)
+	assert.True(t, IsUsageError(err))
+	assert.Equal(t, `invalid --set value "team", must be of the form <name>=<value>`, err.Error())
+}
+
+func TestContextListOptions(t *testing.T) {
+	fn := setPwd(t, "testdata")
+	defer fn()
+	ctx := getContext(t, Options{Stderr: ioutil.Discard}, []string{})
+	assert.Equal(t, 5, ctx.ListConcurrency())
+	assert.Nil(t, ctx.ListObserver())
+	ctx = getContext(t, Options{Stderr: ioutil.Discard}, []string{"--k8s:list-concurrency=20", "--profile-remote"})
+	assert.Equal(t, 20, ctx.ListConcurrency())
+	o, ok := ctx.ListObserver().(*listObserver)
+	require.True(t, ok)
+	assert.True(t, o.profile)
+	assert.False(t, o.progress)
+	err := getBadContext(t, Options{}, []string{"--k8s:list-concurrency=0"})
+	require.Error(t, err)
+	assert.True(t, IsUsageError(err))
+	assert.Equal(t, "invalid list concurrency 0, must be positive", err.Error())
+}
+
+func TestContextDataSourceRecordReplay(t *testing.T) {
+	fn := setPwd(t, "testdata")
+	defer fn()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	ctx := getContext(t, Options{}, []string{"--ds-record", "recordings"})
+	assert.Equal(t, filepath.Join(wd, "recordings"), ctx.dsOpts.RecordDir)
+	ctx = getContext(t, Options{}, []string{"--ds-replay", "/tmp/recordings"})
+	assert.Equal(t, "/tmp/recordings", ctx.dsOpts.ReplayDir)
+	err = getBadContext(t, Options{}, []string{"--ds-record", "a", "--ds-replay", "b"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot specify both --ds-record and --ds-replay")
+}
+
 func TestContextCreate(t *testing.T) {
 	a := assert.New(t)
 	fn := setPwd(t, "testdata")
@@ -144,3 +226,41 @@ func TestContextConfirm(t *testing.T) {
 	require.NotNil(t, err)
 	a.Equal("canceled", err.Error())
 }
+
+func TestContextConfirmYesQuiet(t *testing.T) {
+	fn := setPwd(t, "testdata")
+	defer fn()
+	var stdout, stderr, logs bytes.Buffer
+	orig := sio.Output
+	defer func() { sio.Output = orig; sio.EnableQuiet(false) }()
+	sio.Output = &logs
+
+	ctx := getContext(t, Options{Stdout: &stdout, Stderr: &stderr}, []string{"--yes"})
+	require.NoError(t, ctx.Confirm("will delete 2 object(s)"))
+	assert.Equal(t, "will delete 2 object(s)\n", logs.String())
+	assert.Equal(t, "", stderr.String())
+
+	logs.Reset()
+	ctx = getContext(t, Options{Stdout: &stdout, Stderr: &stderr}, []string{"--yes", "--quiet"})
+	sio.EnableQuiet(ctx.Quiet())
+	require.NoError(t, ctx.Confirm("will delete 2 object(s)"))
+	assert.Equal(t, "", logs.String())
+	assert.Equal(t, "", stderr.String())
+	assert.Equal(t, "", stdout.String())
+}
+
+func TestContextConfirmJSON(t *testing.T) {
+	fn := setPwd(t, "testdata")
+	defer fn()
+	var stdout, stderr, logs bytes.Buffer
+	orig := sio.Output
+	defer func() { sio.Output = orig; sio.EnableJSON(false) }()
+	sio.Output = &logs
+
+	ctx := getContext(t, Options{Stdout: &stdout, Stderr: &stderr}, []string{"--log-format", "json"})
+	sio.EnableJSON(ctx.JSONLogs())
+	ctx.stdin = bytes.NewReader([]byte("y\n"))
+	require.NoError(t, ctx.Confirm("will delete 2 object(s)"))
+	assert.Regexp(t, `^\{"time":"[^"]+","level":"notice","msg":"will delete 2 object\(s\)"\}\n$`, logs.String())
+	assert.NotContains(t, stderr.String(), "will delete")
+}
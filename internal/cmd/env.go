@@ -19,12 +19,14 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 
 	"github.com/pkg/errors"
 	"github.com/splunk/qbec/internal/eval"
 	"github.com/splunk/qbec/internal/model"
 	"github.com/splunk/qbec/internal/remote"
 	"github.com/splunk/qbec/internal/sio"
+	"github.com/splunk/qbec/internal/vmexternals"
 	"github.com/splunk/qbec/vm"
 	vmds "github.com/splunk/qbec/vm/datasource"
 )
@@ -44,7 +46,16 @@ func (c *EnvContext) configProvider(name string) (string, error) {
 }
 
 func (c *EnvContext) createDataSources() error {
-	sources, closer, err := vm.CreateDataSources(c.App().DataSources(), c.configProvider)
+	opts := c.dsOpts
+	opts.Dir = c.App().Root()
+	// data sources are initialized with environment specific configuration, so their outputs are recorded per environment
+	opts.RecordingKey = c.env
+	opts.Providers = map[string]vm.DataSourceProvider{
+		lookupScheme: func(name string, _ url.Values) (vmds.DataSource, error) {
+			return &lookupSource{name: name, env: c.env, enabled: c.clusterLookups, clp: c.clp}, nil
+		},
+	}
+	sources, closer, err := vm.CreateDataSourcesWithOptions(c.App().DataSources(), c.configProvider, opts)
 	RegisterCleanupTask(closer)
 	if err != nil {
 		return err
@@ -55,19 +66,61 @@ func (c *EnvContext) createDataSources() error {
 
 func (c *EnvContext) computeVars() error {
 	cVars := c.App().DeclaredComputedVars()
+	if len(cVars) == 0 {
+		return nil
+	}
+	sharedCtx := c.EvalContext(false).BaseContext.WithSharedVM()
 	for _, varObj := range cVars {
 		name := varObj.Name
-		baseCtx := c.EvalContext(false).BaseContext
-		jsonData, err := eval.Code(fmt.Sprintf("<%s>", name), vm.MakeCode(varObj.Code), baseCtx)
+		baseCtx := sharedCtx
+		baseCtx.Vars = c.EvalContext(false).Vars
+		// inline code is evaluated as though it were defined in a file in the qbec root
+		file := c.App().ResolvePath(fmt.Sprintf("<%s>", name))
+		jsonData, err := eval.Code(file, vm.MakeCode(varObj.Code), baseCtx)
 		if err != nil {
-			return errors.Wrapf(err, "eval computed var %s", name)
+			return WithCode(ErrorCodeEval, errors.Wrapf(err, "eval computed var %s", name))
 		}
 		c.vars = c.vars.WithVars(vm.NewCodeVar(name, jsonData))
 	}
 	return nil
 }
 
+// applyVarFiles sets external string variables from the dotenv files declared for the environment. Variables that
+// have been specified on the command line are left alone.
+func (c *EnvContext) applyVarFiles() error {
+	files := c.App().VarFiles(c.env)
+	if len(files) == 0 {
+		return nil
+	}
+	declared := c.App().DeclaredVars()
+	values := map[string]string{}
+	for _, file := range files {
+		vars, err := vmexternals.ParseEnvFile(file)
+		if err != nil {
+			return errors.Wrapf(err, "environment %s", c.env)
+		}
+		for k, v := range vars {
+			if _, ok := declared[k]; !ok && c.strictVars {
+				return fmt.Errorf("variable %s from var file %s not declared for app", k, file)
+			}
+			values[k] = v
+		}
+	}
+	var add []vm.Var
+	for k, v := range values {
+		if _, ok := c.ext.Variables.Vars[k]; ok {
+			continue
+		}
+		add = append(add, vm.NewVar(k, v))
+	}
+	c.vars = c.vars.WithVars(add...)
+	return nil
+}
+
 func (c *EnvContext) initEnv() error {
+	if err := c.applyVarFiles(); err != nil {
+		return err
+	}
 	if err := c.createDataSources(); err != nil {
 		return err
 	}
@@ -80,6 +133,29 @@ func (c *EnvContext) initEnv() error {
 // Env returns the environment name for this context.
 func (c EnvContext) Env() string { return c.env }
 
+// DataSources returns the data sources created for the environment.
+func (c EnvContext) DataSources() []vmds.DataSource { return c.dataSources }
+
+// EvalConcurrency returns the concurrency to be used for evaluating components, as specified on the command line
+// or, failing that, for the environment.
+func (c EnvContext) EvalConcurrency() int {
+	if n := c.Context.EvalConcurrency(); n > 0 {
+		return n
+	}
+	return c.app.EvalConcurrency(c.env)
+}
+
+// MaxDataSourceBytes returns the maximum size of the output of a data source for a single import, as specified
+// on the command line or, failing that, for the environment.
+func (c EnvContext) MaxDataSourceBytes() int64 {
+	if !c.maxDSBytesSet {
+		if limit, ok := c.app.MaxDataSourceBytes(c.env); ok {
+			return limit
+		}
+	}
+	return c.Context.MaxDataSourceBytes()
+}
+
 // EvalContext returns the evaluation context for the supplied environment.
 func (c EnvContext) EvalContext(cleanMode bool) eval.Context {
 	p, err := json.Marshal(c.props)
@@ -97,15 +173,26 @@ func (c EnvContext) EvalContext(cleanMode bool) eval.Context {
 		vm.NewVar(model.QbecNames.CleanModeVarName, cm),
 		vm.NewCodeVar(model.QbecNames.EnvPropsVarName, string(p)),
 	)
+	timeout := c.Context.ComponentTimeout()
+	if timeout == 0 {
+		timeout = c.app.EnvComponentTimeout(c.env)
+	}
 	return eval.Context{
 		BaseContext: eval.BaseContext{
-			Vars:        baseVars,
-			LibPaths:    c.ext.LibPaths,
-			DataSources: c.dataSources,
-			Verbose:     c.Verbosity() > 1,
+			Vars:               baseVars,
+			LibPaths:           c.ext.LibPaths,
+			DataSources:        c.dataSources,
+			Verbose:            c.Verbosity() > 1,
+			MaxDataSourceBytes: c.MaxDataSourceBytes(),
+			Libraries:          c.libraries,
+			HTTPImports:        c.httpImports,
 		},
 		Concurrency:      c.EvalConcurrency(),
+		ComponentTimeout: timeout,
+		PreProcessFiles:  c.App().PreProcessors(),
 		PostProcessFiles: c.App().PostProcessors(),
+		Transforms:       c.App().Transforms(c.env),
+		Cache:            c.EvalCache(),
 	}
 }
 
@@ -119,6 +206,9 @@ func (c EnvContext) ObjectProducer() eval.LocalObjectProducer {
 			Component:         component,
 			Env:               c.env,
 			SetComponentLabel: app.AddComponentLabel(),
+			CommonLabels:      app.CommonLabels(),
+			CommonAnnotations: app.CommonAnnotations(),
+			SourceAnnotations: c.sourceAnnotations,
 		})
 	}
 }
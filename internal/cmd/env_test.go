@@ -17,11 +17,15 @@
 package cmd
 
 import (
+	"fmt"
 	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/splunk/qbec/internal/eval"
 	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/vm"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -108,6 +112,93 @@ func TestEnvContextBadCompute2(t *testing.T) {
 	a.Contains(err.Error(), `eval computed var compFoo: RUNTIME ERROR: variable compBar has not yet been computed`)
 }
 
+func TestEnvContextVarFiles(t *testing.T) {
+	fn := setPwd(t, "testdata")
+	defer fn()
+	app, err := model.NewApp("qbec-varfiles.yaml", nil, "")
+	require.NoError(t, err)
+
+	evalVar := func(ec EnvContext, name string) string {
+		out, err := eval.Code("test.jsonnet", vm.MakeCode(fmt.Sprintf("std.extVar('%s')", name)), ec.EvalContext(false).BaseContext)
+		require.NoError(t, err)
+		return strings.TrimSpace(out)
+	}
+
+	ctx := getContext(t, Options{}, []string{"--k8s:kubeconfig=kubeconfig.yaml"})
+	ac, err := ctx.AppContext(app)
+	require.NoError(t, err)
+	ec, err := ac.EnvContext("dev")
+	require.NoError(t, err)
+	assert.Equal(t, `"from-file"`, evalVar(ec, "extFoo"))
+	assert.Equal(t, `"other value"`, evalVar(ec, "extOther"))
+	assert.Equal(t, `{
+   "foo": "from-file"
+}`, evalVar(ec, "compFoo"))
+
+	ec, err = ac.EnvContext("prod")
+	require.NoError(t, err)
+	assert.Equal(t, `"baz"`, evalVar(ec, "extFoo"))
+
+	_, err = ac.EnvContext("bad")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "environment bad: open vars/no-such-file.env")
+
+	ctx = getContext(t, Options{}, []string{"--k8s:kubeconfig=kubeconfig.yaml", "--vm:ext-str=extFoo=cmdline"})
+	ac, err = ctx.AppContext(app)
+	require.NoError(t, err)
+	ec, err = ac.EnvContext("dev")
+	require.NoError(t, err)
+	assert.Equal(t, `"cmdline"`, evalVar(ec, "extFoo"))
+	assert.Equal(t, `"other value"`, evalVar(ec, "extOther"))
+
+	ctx = getContext(t, Options{}, []string{
+		"--k8s:kubeconfig=kubeconfig.yaml",
+		"--strict-vars",
+		"--vm:ext-str=extFoo=a",
+		"--vm:ext-str=extOther=b",
+	})
+	ac, err = ctx.AppContext(app)
+	require.NoError(t, err)
+	_, err = ac.EnvContext("undeclared")
+	require.Error(t, err)
+	assert.Equal(t, "variable unknownVar from var file vars/undeclared.env not declared for app", err.Error())
+}
+
+func TestEnvContextEvalSettings(t *testing.T) {
+	fn := setPwd(t, "testdata")
+	defer fn()
+	app, err := model.NewApp("qbec-eval.yaml", nil, "")
+	require.NoError(t, err)
+
+	envContext := func(env string, args ...string) EnvContext {
+		ctx := getContext(t, Options{}, append([]string{"--k8s:kubeconfig=kubeconfig.yaml"}, args...))
+		ac, err := ctx.AppContext(app)
+		require.NoError(t, err)
+		ec, err := ac.EnvContext(env)
+		require.NoError(t, err)
+		return ec
+	}
+
+	a := assert.New(t)
+	ec := envContext("laptop")
+	ect := ec.EvalContext(false)
+	a.Equal(2, ect.Concurrency)
+	a.Equal(5*time.Minute, ect.ComponentTimeout)
+	a.EqualValues(1024, ect.MaxDataSourceBytes)
+
+	ec = envContext("laptop", "--eval-concurrency=8", "--component-timeout=1m", "--max-data-source-bytes=0")
+	ect = ec.EvalContext(false)
+	a.Equal(8, ect.Concurrency)
+	a.Equal(time.Minute, ect.ComponentTimeout)
+	a.EqualValues(0, ect.MaxDataSourceBytes)
+
+	ec = envContext("ci")
+	ect = ec.EvalContext(false)
+	a.Equal(0, ect.Concurrency)
+	a.Equal(2*time.Minute, ect.ComponentTimeout)
+	a.EqualValues(defaultMaxDataSourceBytes, ect.MaxDataSourceBytes)
+}
+
 func TestEnvContextForceContext(t *testing.T) {
 	a := assert.New(t)
 	fn := setPwd(t, "testdata")
@@ -16,7 +16,12 @@
 
 package cmd
 
-import "errors"
+import (
+	"errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
 
 // usageError indicates that the user supplied incorrect arguments or flags to the command.
 type usageError struct {
@@ -69,3 +74,75 @@ func WrapError(err error) error {
 	}
 	return NewRuntimeError(err)
 }
+
+// Error codes are stable categories of errors that are surfaced in machine-readable error output.
+const (
+	ErrorCodeUsage          = "usage"           // incorrect arguments or flags
+	ErrorCodeEval           = "eval"            // failure to evaluate components
+	ErrorCodeRemoteAuth     = "remote-auth"     // the server rejected the credentials or denied access
+	ErrorCodeRemoteConflict = "remote-conflict" // the server reported a conflict with an existing object
+	ErrorCodeGC             = "gc"              // failure to garbage collect extra objects
+	ErrorCodeWaitTimeout    = "wait-timeout"    // objects were not ready before the wait timeout
+	ErrorCodeRuntime        = "runtime"         // all other errors
+)
+
+// codedError is an error that has been classified with an error code.
+type codedError struct {
+	error
+	code string
+}
+
+// Unwrap returns the underlying error
+func (e *codedError) Unwrap() error {
+	return e.error
+}
+
+// WithCode classifies the supplied error using the supplied error code. It returns nil for a nil error.
+func WithCode(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{error: err, code: code}
+}
+
+// ErrorCode returns the error code for the supplied error. Errors from the Kubernetes API server that have
+// not otherwise been classified are classified based on their status reason.
+func ErrorCode(err error) string {
+	var ue *usageError
+	if errors.As(err, &ue) {
+		return ErrorCodeUsage
+	}
+	var ce *codedError
+	if errors.As(err, &ce) {
+		return ce.code
+	}
+	switch apierrors.ReasonForError(err) {
+	case metav1.StatusReasonUnauthorized, metav1.StatusReasonForbidden:
+		return ErrorCodeRemoteAuth
+	case metav1.StatusReasonConflict, metav1.StatusReasonAlreadyExists:
+		return ErrorCodeRemoteConflict
+	}
+	return ErrorCodeRuntime
+}
+
+// ErrorEnvelope is the machine-readable representation of an error.
+type ErrorEnvelope struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail contains the attributes of an error.
+type ErrorDetail struct {
+	Code    string `json:"code"`              // the error code
+	Message string `json:"message"`           // the error message
+	Command string `json:"command,omitempty"` // the command that failed
+}
+
+// NewErrorEnvelope returns the envelope for the supplied error returned by the supplied command. Errors that are not
+// runtime errors, including the flag and argument errors reported by cobra before the command runs, are usage errors.
+func NewErrorEnvelope(command string, err error) ErrorEnvelope {
+	code := ErrorCodeUsage
+	if IsRuntimeError(err) {
+		code = ErrorCode(err)
+	}
+	return ErrorEnvelope{Error: ErrorDetail{Code: code, Message: err.Error(), Command: command}}
+}
@@ -17,10 +17,16 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
 	"testing"
 
+	pkgerrors "github.com/pkg/errors"
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 func TestUsageError(t *testing.T) {
@@ -45,3 +51,72 @@ func TestWrapError(t *testing.T) {
 	a.True(IsUsageError(WrapError(ue)))
 	a.True(IsRuntimeError(WrapError(errors.New("foobar"))))
 }
+
+func TestErrorCode(t *testing.T) {
+	gr := schema.GroupResource{Resource: "configmaps"}
+	tests := []struct {
+		name string
+		err  error
+		code string
+	}{
+		{"usage", NewUsageError("foo"), ErrorCodeUsage},
+		{"plain", errors.New("foo"), ErrorCodeRuntime},
+		{"runtime", WrapError(errors.New("foo")), ErrorCodeRuntime},
+		{"coded", WithCode(ErrorCodeEval, errors.New("foo")), ErrorCodeEval},
+		{"wrapped-coded", WrapError(pkgerrors.Wrap(WithCode(ErrorCodeGC, errors.New("foo")), "bar")), ErrorCodeGC},
+		{"unauthorized", pkgerrors.Wrap(apierrors.NewUnauthorized("foo"), "get"), ErrorCodeRemoteAuth},
+		{"forbidden", WrapError(apierrors.NewForbidden(gr, "foo", errors.New("bar"))), ErrorCodeRemoteAuth},
+		{"conflict", apierrors.NewConflict(gr, "foo", errors.New("bar")), ErrorCodeRemoteConflict},
+		{"already-exists", apierrors.NewAlreadyExists(gr, "foo"), ErrorCodeRemoteConflict},
+		{"not-found", apierrors.NewNotFound(gr, "foo"), ErrorCodeRuntime},
+		{"coded-api", WithCode(ErrorCodeGC, apierrors.NewForbidden(gr, "foo", errors.New("bar"))), ErrorCodeGC},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.code, ErrorCode(test.err))
+		})
+	}
+	assert.Nil(t, WithCode(ErrorCodeEval, nil))
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	b, err := json.Marshal(NewErrorEnvelope("qbec apply", WrapError(WithCode(ErrorCodeWaitTimeout, errors.New("wait timed out after 1s")))))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"error":{"code":"wait-timeout","message":"wait timed out after 1s","command":"qbec apply"}}`, string(b))
+}
+
+func TestErrorEnvelopeUsage(t *testing.T) {
+	newCommand := func() *cobra.Command {
+		c := &cobra.Command{
+			Use:  "show",
+			Args: cobra.ExactArgs(1),
+			RunE: func(c *cobra.Command, args []string) error {
+				return WrapError(errors.New("runtime failure"))
+			},
+			SilenceErrors: true,
+			SilenceUsage:  true,
+		}
+		c.Flags().Bool("known", false, "a known flag")
+		return c
+	}
+	tests := []struct {
+		name string
+		args []string
+		code string
+	}{
+		{"unknown-flag", []string{"dev", "--unknown"}, ErrorCodeUsage},
+		{"bad-flag-value", []string{"dev", "--known=maybe"}, ErrorCodeUsage},
+		{"bad-args", []string{"dev", "prod"}, ErrorCodeUsage},
+		{"runtime", []string{"dev"}, ErrorCodeRuntime},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newCommand()
+			c.SetArgs(test.args)
+			err := c.Execute()
+			require.Error(t, err)
+			assert.Equal(t, test.code, NewErrorEnvelope(c.CommandPath(), err).Error.Code)
+		})
+	}
+	assert.Equal(t, ErrorCodeUsage, NewErrorEnvelope("qbec show", NewUsageError("foo")).Error.Code)
+}
@@ -0,0 +1,137 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/splunk/qbec/internal/sio"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// listTiming is the timing of a single list query.
+type listTiming struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	objects   int
+	elapsed   time.Duration
+	err       error
+}
+
+// listObserver shows the progress of list queries on a terminal and, optionally, prints a summary of the time
+// taken by every query once all of them complete.
+type listObserver struct {
+	w        io.Writer
+	progress bool // show a progress indicator
+	profile  bool // print timings when done
+	json     bool // print timings as structured records
+	l        sync.Mutex
+	start    time.Time
+	total    int
+	timings  []listTiming
+}
+
+// Start implements the remote.ListObserver interface.
+func (o *listObserver) Start(queries int) {
+	o.l.Lock()
+	defer o.l.Unlock()
+	o.start = time.Now()
+	o.total = queries
+	o.timings = nil
+	o.showProgress()
+	if queries == 0 {
+		o.finish()
+	}
+}
+
+// Done implements the remote.ListObserver interface.
+func (o *listObserver) Done(gvk schema.GroupVersionKind, namespace string, objects int, elapsed time.Duration, err error) {
+	o.l.Lock()
+	defer o.l.Unlock()
+	o.timings = append(o.timings, listTiming{gvk: gvk, namespace: namespace, objects: objects, elapsed: elapsed, err: err})
+	o.showProgress()
+	if len(o.timings) == o.total {
+		o.finish()
+	}
+}
+
+// showProgress shows the progress as the status line of the output, since components are evaluated and
+// messages are logged while objects are listed.
+func (o *listObserver) showProgress() {
+	if o.progress {
+		sio.SetStatus(fmt.Sprintf("listing remote objects: %d/%d", len(o.timings), o.total))
+	}
+}
+
+// finish clears the progress indicator and prints the timings of all queries, slowest first.
+func (o *listObserver) finish() {
+	if o.progress {
+		sio.ClearStatus()
+	}
+	if !o.profile {
+		return
+	}
+	timings := append([]listTiming{}, o.timings...)
+	sort.SliceStable(timings, func(i, j int) bool {
+		if timings[i].elapsed != timings[j].elapsed {
+			return timings[i].elapsed > timings[j].elapsed
+		}
+		return fmt.Sprint(timings[i].gvk, timings[i].namespace) < fmt.Sprint(timings[j].gvk, timings[j].namespace)
+	})
+	elapsed := time.Since(o.start).Round(time.Millisecond)
+	if o.json {
+		o.printRecords(timings, elapsed)
+		return
+	}
+	fmt.Fprintf(o.w, "remote list profile: %d queries in %v\n", o.total, elapsed)
+	tw := tabwriter.NewWriter(o.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "  GROUP-VERSION\tKIND\tNAMESPACE\tOBJECTS\tTIME")
+	for _, t := range timings {
+		objects := fmt.Sprint(t.objects)
+		if t.err != nil {
+			objects = "error"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%v\n", t.gvk.GroupVersion(), t.gvk.Kind, t.namespace, objects, t.elapsed.Round(time.Millisecond))
+	}
+	tw.Flush()
+}
+
+// printRecords prints the profile as a summary record followed by one record for every query.
+func (o *listObserver) printRecords(timings []listTiming, elapsed time.Duration) {
+	sio.PrintFields("remote list profile", map[string]interface{}{
+		"queries":   o.total,
+		"elapsedMs": elapsed.Milliseconds(),
+	})
+	for _, t := range timings {
+		fields := map[string]interface{}{
+			"groupVersion": t.gvk.GroupVersion().String(),
+			"kind":         t.gvk.Kind,
+			"namespace":    t.namespace,
+			"objects":      t.objects,
+			"elapsedMs":    t.elapsed.Milliseconds(),
+		}
+		if t.err != nil {
+			fields["error"] = t.err.Error()
+		}
+		sio.PrintFields("remote list query", fields)
+	}
+}
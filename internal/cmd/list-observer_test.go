@@ -0,0 +1,93 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/splunk/qbec/internal/sio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestListObserverProfile(t *testing.T) {
+	var buf bytes.Buffer
+	o := &listObserver{w: &buf, profile: true}
+	o.Start(3)
+	o.Done(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, "ns1", 10, 20*time.Millisecond, nil)
+	o.Done(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, "ns1", 2, 300*time.Millisecond, nil)
+	assert.Equal(t, "", buf.String())
+	o.Done(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, "", 0, 5*time.Millisecond, errors.New("forbidden"))
+	lines := regexp.MustCompile(`\n`).Split(buf.String(), -1)
+	assert.Equal(t, 6, len(lines))
+	assert.Regexp(t, `^remote list profile: 3 queries in \d+`, lines[0])
+	assert.Regexp(t, `^\s+GROUP-VERSION\s+KIND\s+NAMESPACE\s+OBJECTS\s+TIME$`, lines[1])
+	assert.Regexp(t, `^\s+apps/v1\s+Deployment\s+ns1\s+2\s+300ms$`, lines[2])
+	assert.Regexp(t, `^\s+v1\s+ConfigMap\s+ns1\s+10\s+20ms$`, lines[3])
+	assert.Regexp(t, `^\s+v1\s+Namespace\s+error\s+5ms$`, lines[4])
+}
+
+func TestListObserverProgress(t *testing.T) {
+	var buf, out bytes.Buffer
+	orig := sio.Output
+	defer func() { sio.Output = orig }()
+	sio.Output = &out
+	o := &listObserver{w: &buf, progress: true}
+	o.Start(2)
+	o.Done(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, "ns1", 1, time.Millisecond, nil)
+	sio.Println("evaluated components")
+	o.Done(schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, "ns1", 1, time.Millisecond, nil)
+	assert.Equal(t, "", buf.String())
+	assert.Equal(t, "\r\033[Klisting remote objects: 0/2\r\033[Klisting remote objects: 1/2"+
+		"\r\033[Kevaluated components\nlisting remote objects: 1/2"+
+		"\r\033[Klisting remote objects: 2/2\r\033[K", out.String())
+	out.Reset()
+	sio.Println("done")
+	assert.Equal(t, "done\n", out.String())
+}
+
+func TestListObserverNoQueries(t *testing.T) {
+	var buf, out bytes.Buffer
+	orig := sio.Output
+	defer func() { sio.Output = orig }()
+	sio.Output = &out
+	o := &listObserver{w: &buf, progress: true, profile: true}
+	o.Start(0)
+	assert.Equal(t, "\r\033[Klisting remote objects: 0/0\r\033[K", out.String())
+	assert.Regexp(t, `^remote list profile: 0 queries in`, buf.String())
+}
+
+func TestListObserverProfileJSON(t *testing.T) {
+	var buf, out bytes.Buffer
+	orig := sio.Output
+	defer func() { sio.Output = orig; sio.EnableJSON(false) }()
+	sio.Output = &out
+	sio.EnableJSON(true)
+	o := &listObserver{w: &buf, profile: true, json: true}
+	o.Start(1)
+	o.Done(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, "ns1", 10, 20*time.Millisecond, nil)
+	assert.Equal(t, "", buf.String())
+	lines := regexp.MustCompile(`\n`).Split(out.String(), -1)
+	require.Equal(t, 3, len(lines))
+	assert.Regexp(t, `^\{"time":"[^"]+","level":"info","msg":"remote list profile","fields":\{"elapsedMs":\d+,"queries":1\}\}$`, lines[0])
+	assert.Regexp(t, `^\{"time":"[^"]+","level":"info","msg":"remote list query","fields":\{"elapsedMs":20,"groupVersion":"v1","kind":"ConfigMap","namespace":"ns1","objects":10\}\}$`, lines[1])
+}
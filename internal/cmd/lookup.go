@@ -0,0 +1,159 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/splunk/qbec/internal/types"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// lookupScheme is the data source scheme for objects looked up from the cluster of an environment.
+const lookupScheme = "k8s"
+
+// lookupTimeout is the maximum time taken to fetch a single object from the cluster.
+const lookupTimeout = time.Minute
+
+// lookupSource is a data source that returns objects from the cluster of an environment. It is only usable
+// when cluster lookups have been explicitly enabled on the command line.
+type lookupSource struct {
+	name    string
+	env     string
+	enabled bool
+	clp     ClientProvider
+	once    sync.Once
+	client  KubeClient
+	err     error
+}
+
+// parseLookupPath returns the group version kind, namespace and name of the object referenced by a lookup path of the
+// form /<apiVersion>/<kind>/[<namespace>/]<name>, where the API version is either v1 or <group>/<version>.
+func parseLookupPath(path string) (gvk schema.GroupVersionKind, namespace, name string, _ error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return gvk, "", "", err
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	var apiVersion string
+	if parts[0] == "v1" {
+		apiVersion, parts = parts[0], parts[1:]
+	} else if len(parts) > 1 {
+		apiVersion, parts = parts[0]+"/"+parts[1], parts[2:]
+	}
+	if apiVersion == "" || len(parts) < 2 || len(parts) > 3 {
+		return gvk, "", "", fmt.Errorf("invalid lookup path %q, must be /<apiVersion>/<kind>/[<namespace>/]<name>", path)
+	}
+	for _, p := range parts {
+		if p == "" {
+			return gvk, "", "", fmt.Errorf("invalid lookup path %q, empty path segment", path)
+		}
+	}
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return gvk, "", "", errors.Wrapf(err, "invalid lookup path %q", path)
+	}
+	gvk = gv.WithKind(parts[0])
+	if len(parts) == 3 {
+		return gvk, parts[1], parts[2], nil
+	}
+	return gvk, "", parts[1], nil
+}
+
+// Name implements the data source interface.
+func (l *lookupSource) Name() string {
+	return l.name
+}
+
+// Resolve implements the data source interface. It returns the JSON representation of the object referenced by
+// the path, or null if it does not exist. Namespaced objects are looked up in the default namespace of the
+// environment when the path does not have a namespace.
+func (l *lookupSource) Resolve(path string) (string, error) {
+	if !l.enabled {
+		return "", fmt.Errorf("cluster lookups are not enabled, use --allow-cluster-lookups to enable them")
+	}
+	gvk, ns, name, err := parseLookupPath(path)
+	if err != nil {
+		return "", err
+	}
+	l.once.Do(func() {
+		l.client, l.err = l.clp(l.env)
+	})
+	if l.err != nil {
+		return "", l.err
+	}
+	namespaced, err := l.client.IsNamespaced(gvk)
+	if err != nil {
+		return "", errors.Wrapf(err, "lookup %s", path)
+	}
+	if !namespaced && ns != "" {
+		return "", fmt.Errorf("lookup %s: %s is cluster-scoped and cannot have a namespace", path, gvk.Kind)
+	}
+	obj := model.NewK8sObject(map[string]interface{}{
+		"apiVersion": gvk.GroupVersion().String(),
+		"kind":       gvk.Kind,
+		"metadata": map[string]interface{}{
+			"namespace": ns,
+			"name":      name,
+		},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	u, err := l.client.Get(ctx, obj)
+	if err != nil {
+		if err == remote.ErrNotFound {
+			return "null", nil
+		}
+		return "", errors.Wrapf(err, "lookup %s", path)
+	}
+	b, err := json.Marshal(u.Object)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Redact implements the redacting data source interface, such that the data of secrets and of fields with configured
+// redactions is never recorded.
+func (l *lookupSource) Redact(_ string, output string) (string, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &obj); err != nil {
+		return "", err
+	}
+	if obj == nil {
+		return output, nil
+	}
+	u, changed := types.HideSensitiveInfo(&unstructured.Unstructured{Object: obj})
+	if !changed {
+		return output, nil
+	}
+	b, err := json.Marshal(u.Object)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
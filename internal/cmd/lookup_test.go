@@ -0,0 +1,158 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParseLookupPath(t *testing.T) {
+	tests := []struct {
+		path string
+		gvk  schema.GroupVersionKind
+		ns   string
+		name string
+	}{
+		{"/v1/ConfigMap/shared/settings", schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, "shared", "settings"},
+		{"/v1/Secret/db?foo=bar", schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, "", "db"},
+		{"/apps/v1/Deployment/web/api", schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, "web", "api"},
+		{"/rbac.authorization.k8s.io/v1/ClusterRole/admin", schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}, "", "admin"},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			gvk, ns, name, err := parseLookupPath(test.path)
+			require.NoError(t, err)
+			assert.Equal(t, test.gvk, gvk)
+			assert.Equal(t, test.ns, ns)
+			assert.Equal(t, test.name, name)
+		})
+	}
+	for _, path := range []string{"/", "/v1", "/v1/ConfigMap", "/apps/v1/Deployment", "/v1/ConfigMap/a/b/c", "/v1/ConfigMap//b", "/a/b/c/d/e/f"} {
+		t.Run(path, func(t *testing.T) {
+			_, _, _, err := parseLookupPath(path)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid lookup path")
+		})
+	}
+}
+
+type lookupClient struct {
+	KubeClient
+	objects map[string]map[string]interface{}
+}
+
+func (l *lookupClient) IsNamespaced(gvk schema.GroupVersionKind) (bool, error) {
+	if gvk.Kind == "Unknown" {
+		return false, errors.New("server type not found")
+	}
+	return gvk.Kind != "Namespace", nil
+}
+
+func (l *lookupClient) Get(_ context.Context, obj model.K8sMeta) (*unstructured.Unstructured, error) {
+	if obj.GetName() == "forbidden" {
+		return nil, remote.ErrForbidden
+	}
+	o, ok := l.objects[obj.GetKind()+"/"+obj.GetNamespace()+"/"+obj.GetName()]
+	if !ok {
+		return nil, remote.ErrNotFound
+	}
+	return &unstructured.Unstructured{Object: o}, nil
+}
+
+func TestLookupSource(t *testing.T) {
+	client := &lookupClient{objects: map[string]map[string]interface{}{
+		"ConfigMap/shared/settings": {
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"namespace": "shared", "name": "settings"},
+			"data":       map[string]interface{}{"foo": "bar"},
+		},
+		"Namespace//shared": {
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata":   map[string]interface{}{"name": "shared"},
+		},
+	}}
+	var envs []string
+	l := &lookupSource{name: "cluster", env: "dev", enabled: true, clp: func(env string) (KubeClient, error) {
+		envs = append(envs, env)
+		return client, nil
+	}}
+	a := assert.New(t)
+	a.Equal("cluster", l.Name())
+	out, err := l.Resolve("/v1/ConfigMap/shared/settings")
+	require.NoError(t, err)
+	a.JSONEq(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"namespace":"shared","name":"settings"},"data":{"foo":"bar"}}`, out)
+	out, err = l.Resolve("/v1/Namespace/shared")
+	require.NoError(t, err)
+	a.JSONEq(`{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"shared"}}`, out)
+	out, err = l.Resolve("/v1/ConfigMap/shared/missing")
+	require.NoError(t, err)
+	a.Equal("null", out)
+	a.Equal([]string{"dev"}, envs)
+
+	_, err = l.Resolve("/v1/Namespace/foo/shared")
+	require.Error(t, err)
+	a.Equal("lookup /v1/Namespace/foo/shared: Namespace is cluster-scoped and cannot have a namespace", err.Error())
+	_, err = l.Resolve("/v1/ConfigMap/shared/forbidden")
+	require.Error(t, err)
+	a.Equal("lookup /v1/ConfigMap/shared/forbidden: forbidden", err.Error())
+	_, err = l.Resolve("/v1/Unknown/foo")
+	require.Error(t, err)
+	a.Equal("lookup /v1/Unknown/foo: server type not found", err.Error())
+
+	l = &lookupSource{name: "cluster", env: "dev", clp: func(env string) (KubeClient, error) {
+		t.Fatal("client requested when lookups are disabled")
+		return nil, nil
+	}}
+	_, err = l.Resolve("/v1/ConfigMap/shared/settings")
+	require.Error(t, err)
+	a.Equal("cluster lookups are not enabled, use --allow-cluster-lookups to enable them", err.Error())
+
+	l = &lookupSource{name: "cluster", env: "dev", enabled: true, clp: func(env string) (KubeClient, error) {
+		return nil, errors.New("no cluster")
+	}}
+	_, err = l.Resolve("/v1/ConfigMap/shared/settings")
+	require.Error(t, err)
+	a.Equal("no cluster", err.Error())
+}
+
+func TestLookupSourceRedact(t *testing.T) {
+	l := &lookupSource{name: "cluster"}
+	a := assert.New(t)
+	secret := `{"apiVersion":"v1","kind":"Secret","metadata":{"namespace":"shared","name":"creds"},"data":{"password":"c2VjcmV0"}}`
+	out, err := l.Redact("/v1/Secret/shared/creds", secret)
+	require.NoError(t, err)
+	a.NotContains(out, "c2VjcmV0")
+	a.Contains(out, `"name":"creds"`)
+	cm := `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings"},"data":{"foo":"bar"}}`
+	out, err = l.Redact("/v1/ConfigMap/settings", cm)
+	require.NoError(t, err)
+	a.Equal(cm, out)
+	out, err = l.Redact("/v1/Secret/missing", "null")
+	require.NoError(t, err)
+	a.Equal("null", out)
+}
@@ -86,13 +86,27 @@ func (s stdClientProvider) connectOpts(env string) (ret remote.ConnectOpts, _ er
 	if s.forceContext != "" {
 		fc = s.forceContext
 	}
+	creds, err := s.app.Credentials(env)
+	if err != nil {
+		return ret, err
+	}
+	impersonate, err := s.app.Impersonation(env)
+	if err != nil {
+		return ret, err
+	}
+	qps, burst := s.app.ClientRateLimits(env)
 	ns := s.app.DefaultNamespace(env)
 	return remote.ConnectOpts{
-		EnvName:      env,
-		ServerURL:    server,
-		Namespace:    ns,
-		ForceContext: fc,
-		Verbosity:    s.verbosity,
+		EnvName:           env,
+		ServerURL:         server,
+		Namespace:         ns,
+		ForceContext:      fc,
+		Verbosity:         s.verbosity,
+		CanonicalVersions: s.app.CanonicalVersions(),
+		Credentials:       creds,
+		Impersonate:       impersonate,
+		QPS:               qps,
+		Burst:             burst,
 	}, nil
 }
 
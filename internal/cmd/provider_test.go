@@ -17,6 +17,8 @@
 package cmd
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/splunk/qbec/internal/model"
@@ -88,4 +90,25 @@ func TestConfigConnectOpts(t *testing.T) {
 		Verbosity:    2,
 		ForceContext: "kind",
 	}, co)
+
+	scp = stdClientProvider{
+		app:       app,
+		verbosity: 0,
+	}
+	co, err = scp.connectOpts("ci")
+	require.NoError(t, err)
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	assert.EqualValues(t, remote.ConnectOpts{
+		EnvName:   "ci",
+		ServerURL: "https://ci-server",
+		Namespace: "ci",
+		Credentials: &model.Credentials{
+			CertificateAuthority: filepath.Join(wd, "certs", "ca.pem"),
+			TokenEnv:             "CI_TOKEN",
+		},
+		Impersonate: &model.Impersonation{User: "ci-deployer"},
+		QPS:         50,
+		Burst:       100,
+	}, co)
 }
@@ -0,0 +1,83 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/sio"
+)
+
+// gitRunner runs a git command in the supplied directory and returns its trimmed standard output.
+type gitRunner func(dir string, args ...string) (string, error)
+
+func runGit(dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	c := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %v %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// sourceAnnotations returns the source metadata annotations for objects rendered from the supplied directory.
+// Git metadata is omitted with a warning when the directory is not in a git work tree.
+func sourceAnnotations(git gitRunner, dir string, version string, timestampPolicy string, now time.Time) map[string]string {
+	names := model.QbecNames.SourceAnnotations
+	ret := map[string]string{
+		names.QbecVersion: version,
+	}
+	commit, err := git(dir, "rev-parse", "HEAD")
+	if err != nil {
+		sio.Warnf("unable to get git commit for source annotations: %v\n", err)
+		if timestampPolicy == model.SourceTimestampRender {
+			ret[names.RenderedTime] = now.UTC().Format(time.RFC3339)
+		}
+		return ret
+	}
+	ret[names.Commit] = commit
+	status, err := git(dir, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		sio.Warnf("unable to get git status for source annotations: %v\n", err)
+	} else {
+		ret[names.Dirty] = fmt.Sprint(status != "")
+	}
+	switch timestampPolicy {
+	case model.SourceTimestampRender:
+		ret[names.RenderedTime] = now.UTC().Format(time.RFC3339)
+	case model.SourceTimestampCommit:
+		ts, err := git(dir, "log", "-1", "--format=%cI")
+		if err != nil {
+			sio.Warnf("unable to get git commit time for source annotations: %v\n", err)
+			break
+		}
+		t, err := time.Parse(time.RFC3339, ts)
+		if err != nil {
+			sio.Warnf("unable to parse git commit time %q: %v\n", ts, err)
+			break
+		}
+		ret[names.RenderedTime] = t.UTC().Format(time.RFC3339)
+	}
+	return ret
+}
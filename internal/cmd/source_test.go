@@ -0,0 +1,117 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/splunk/qbec/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fakeGit(outputs map[string]string) gitRunner {
+	return func(dir string, args ...string) (string, error) {
+		out, ok := outputs[args[0]]
+		if !ok {
+			return "", fmt.Errorf("git %s: not a git repository", args[0])
+		}
+		return out, nil
+	}
+}
+
+func TestSourceAnnotations(t *testing.T) {
+	names := model.QbecNames.SourceAnnotations
+	now := time.Date(2021, 6, 1, 10, 0, 0, 0, time.FixedZone("PDT", -7*3600))
+	git := fakeGit(map[string]string{
+		"rev-parse": "abc123",
+		"status":    " M components/foo.jsonnet",
+		"log":       "2021-05-30T08:00:00-07:00",
+	})
+	tests := []struct {
+		name     string
+		git      gitRunner
+		policy   string
+		expected map[string]string
+	}{
+		{
+			name:   "no timestamp",
+			git:    git,
+			policy: model.SourceTimestampNone,
+			expected: map[string]string{
+				names.QbecVersion: "v1.0.0",
+				names.Commit:      "abc123",
+				names.Dirty:       "true",
+			},
+		},
+		{
+			name:   "commit timestamp",
+			git:    fakeGit(map[string]string{"rev-parse": "abc123", "status": "", "log": "2021-05-30T08:00:00-07:00"}),
+			policy: model.SourceTimestampCommit,
+			expected: map[string]string{
+				names.QbecVersion:  "v1.0.0",
+				names.Commit:       "abc123",
+				names.Dirty:        "false",
+				names.RenderedTime: "2021-05-30T15:00:00Z",
+			},
+		},
+		{
+			name:   "render timestamp",
+			git:    git,
+			policy: model.SourceTimestampRender,
+			expected: map[string]string{
+				names.QbecVersion:  "v1.0.0",
+				names.Commit:       "abc123",
+				names.Dirty:        "true",
+				names.RenderedTime: "2021-06-01T17:00:00Z",
+			},
+		},
+		{
+			name:   "no git",
+			git:    fakeGit(nil),
+			policy: model.SourceTimestampRender,
+			expected: map[string]string{
+				names.QbecVersion:  "v1.0.0",
+				names.RenderedTime: "2021-06-01T17:00:00Z",
+			},
+		},
+		{
+			name:   "no git commit time",
+			git:    fakeGit(map[string]string{"rev-parse": "abc123", "status": ""}),
+			policy: model.SourceTimestampCommit,
+			expected: map[string]string{
+				names.QbecVersion: "v1.0.0",
+				names.Commit:      "abc123",
+				names.Dirty:       "false",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, sourceAnnotations(test.git, ".", "v1.0.0", test.policy, now))
+		})
+	}
+}
+
+func TestRunGit(t *testing.T) {
+	_, err := runGit(t.TempDir(), "rev-parse", "HEAD")
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "git rev-parse:"))
+}
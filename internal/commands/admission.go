@@ -0,0 +1,219 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/diff"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/objsort"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/splunk/qbec/internal/sio"
+	"github.com/splunk/qbec/internal/types"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type admissionCommandConfig struct {
+	cmd.AppContext
+	format      string
+	showSecrets bool
+	parallel    int
+	filterFunc  func() (model.Filters, error)
+}
+
+// admissionObject is the admission report for a single object.
+type admissionObject struct {
+	Object    string        `json:"object"`
+	Mutations []diff.Change `json:"mutations,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// admissionReport is the admission report for all objects that were mutated by the server or could not be
+// submitted to it.
+type admissionReport struct {
+	Objects   []admissionObject `json:"objects"`
+	Unchanged int               `json:"unchanged"`
+}
+
+// admissionMutations returns the changes that the server made to the submitted object. Live-only fields, like the
+// status and the metadata that is always set by the server, are removed from both objects before they are compared.
+func admissionMutations(submitted, admitted *unstructured.Unstructured, showSecrets bool) []diff.Change {
+	left := remote.WithoutServerFields(submitted)
+	right := remote.WithoutServerFields(admitted)
+	if !showSecrets {
+		left, _ = types.HideSensitiveInfo(left)
+		right, _ = types.HideSensitiveInfo(right)
+	}
+	if left.GetNamespace() == "" {
+		left.SetNamespace(right.GetNamespace())
+	}
+	if left.GetName() == "" { // generated name
+		left.SetName(right.GetName())
+	}
+	return diff.Paths(left.Object, right.Object)
+}
+
+func formatValue(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func printAdmissionReport(w io.Writer, report admissionReport) {
+	for _, o := range report.Objects {
+		if o.Error != "" {
+			continue
+		}
+		fmt.Fprintln(w, o.Object)
+		for _, m := range o.Mutations {
+			switch m.Type {
+			case diff.Added:
+				fmt.Fprintf(w, "  + %s: %s\n", m.Path, formatValue(m.Right))
+			case diff.Removed:
+				fmt.Fprintf(w, "  - %s: %s\n", m.Path, formatValue(m.Left))
+			default:
+				fmt.Fprintf(w, "  ~ %s: %s -> %s\n", m.Path, formatValue(m.Left), formatValue(m.Right))
+			}
+		}
+	}
+}
+
+func doAdmission(ctx context.Context, args []string, config admissionCommandConfig) error {
+	if len(args) != 1 {
+		return cmd.NewUsageError(fmt.Sprintf("exactly one environment required, but provided: %q", args))
+	}
+	env, err := config.ResolveEnv(args[0])
+	if err != nil {
+		return err
+	}
+	if env == model.Baseline {
+		return cmd.NewUsageError("cannot run admission report for baseline environment, use a real environment")
+	}
+	if config.format != "" && config.format != "json" && config.format != "yaml" {
+		return cmd.NewUsageError(fmt.Sprintf("admission: unsupported format %q", config.format))
+	}
+	fp, err := config.filterFunc()
+	if err != nil {
+		return err
+	}
+	envCtx, err := config.EnvContext(env)
+	if err != nil {
+		return err
+	}
+	client, err := envCtx.Client()
+	if err != nil {
+		return err
+	}
+	objects, err := generateObjects(ctx, envCtx, makeFilterOpts(fp, client).withPolicies())
+	if err != nil {
+		return err
+	}
+	objects = objsort.Sort(objects, sortConfig(client.IsNamespaced))
+
+	results := make([]admissionObject, len(objects))
+	index := map[model.K8sLocalObject]int{}
+	for i, ob := range objects {
+		index[ob] = i
+	}
+	var l sync.Mutex
+	worker := func(ctx context.Context, ob model.K8sLocalObject) error {
+		name := client.DisplayName(ob)
+		res := admissionObject{Object: name}
+		admitted, err := client.ServerDryRun(ctx, ob)
+		if err != nil {
+			sio.Errorf("%s: %v\n", name, err)
+			res.Error = err.Error()
+		} else {
+			res.Mutations = admissionMutations(ob.ToUnstructured(), admitted, config.showSecrets)
+		}
+		l.Lock()
+		results[index[ob]] = res
+		l.Unlock()
+		return nil
+	}
+	if err := runInParallel(ctx, objects, worker, config.parallel); err != nil {
+		return err
+	}
+
+	var report admissionReport
+	var failed int
+	for _, r := range results {
+		switch {
+		case r.Error != "":
+			failed++
+			report.Objects = append(report.Objects, r)
+		case len(r.Mutations) > 0:
+			report.Objects = append(report.Objects, r)
+		default:
+			report.Unchanged++
+		}
+	}
+
+	w := config.Stdout()
+	switch config.format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	case "yaml":
+		b, err := yaml.Marshal(report)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "---\n%s\n", b)
+	default:
+		printAdmissionReport(w, report)
+		sio.Noticef("%d object(s) mutated by the server, %d unchanged\n", len(report.Objects)-failed, report.Unchanged)
+	}
+	if failed > 0 {
+		return fmt.Errorf("server dry-run failed for %d object(s)", failed)
+	}
+	return nil
+}
+
+func newAdmissionCommand(cp ctxProvider) *cobra.Command {
+	c := &cobra.Command{
+		Use:     "admission <environment>",
+		Short:   "report changes that the server and admission webhooks would make to objects when they are applied",
+		Example: admissionExamples(),
+	}
+
+	config := admissionCommandConfig{
+		filterFunc: addFilterParams(c, true),
+	}
+	c.Flags().StringVarP(&config.format, "format", "o", "", "use json|yaml to display machine readable output")
+	c.Flags().BoolVarP(&config.showSecrets, "show-secrets", "S", false, "do not obfuscate secret values in the report")
+	c.Flags().IntVar(&config.parallel, "parallel", 5, "number of parallel routines to run")
+
+	c.RunE = func(c *cobra.Command, args []string) error {
+		config.AppContext = cp()
+		return cmd.WrapError(doAdmission(c.Context(), args, config))
+	}
+	return c
+}
@@ -0,0 +1,213 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func admissionDryRun(ctx context.Context, obj model.K8sLocalObject) (*unstructured.Unstructured, error) {
+	u := obj.ToUnstructured().DeepCopy()
+	if u.GetNamespace() == "" && obj.GetKind() == "Job" {
+		u.SetNamespace("default")
+	}
+	if u.GetName() == "" {
+		u.SetName(u.GetGenerateName() + "abcde")
+	}
+	u.SetUID("1234")
+	u.SetResourceVersion("1")
+	u.SetGeneration(1)
+	u.SetCreationTimestamp(metav1.Now())
+	u.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "qbec", Operation: metav1.ManagedFieldsOperationApply}})
+	u.Object["status"] = map[string]interface{}{"foo": "bar"}
+	switch obj.GetKind() {
+	case "Deployment":
+		_ = unstructured.SetNestedField(u.Object, int64(10), "spec", "revisionHistoryLimit")
+		_ = unstructured.SetNestedField(u.Object, "true", "metadata", "annotations", "webhook.example.com/injected")
+	case "Secret":
+		_ = unstructured.SetNestedField(u.Object, "c2VjcmV0", "data", "injected")
+	}
+	return u, nil
+}
+
+func TestAdmissionBasic(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	s.client.dryRunFunc = admissionDryRun
+	err := s.executeCommand("alpha", "admission", "dev", "-c", "service2")
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`^Deployment:bar-system:svc2-deploy$`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^  \+ spec.revisionHistoryLimit: 10$`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^  \+ metadata.annotations\["webhook.example.com/injected"\]: "true"$`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^Secret:bar-system:svc2-secret$`))
+	s.assertOutputLineNoMatch(regexp.MustCompile(`c2VjcmV0`))
+	s.assertOutputLineNoMatch(regexp.MustCompile(`ConfigMap`))
+	s.assertOutputLineNoMatch(regexp.MustCompile(`status|uid|resourceVersion|generation|creationTimestamp|managedFields`))
+	s.assertErrorLineMatch(regexp.MustCompile(`2 object\(s\) mutated by the server, 1 unchanged`))
+}
+
+func TestAdmissionShowSecrets(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	s.client.dryRunFunc = admissionDryRun
+	err := s.executeCommand("alpha", "admission", "dev", "-c", "service2", "-k", "Secret", "-S")
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`^  \+ data.injected: "c2VjcmV0"$`))
+}
+
+func TestAdmissionJSON(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	s.client.dryRunFunc = admissionDryRun
+	err := s.executeCommand("alpha", "admission", "dev", "-c", "test-job", "-c", "service2", "-k", "Deployment", "-k", "Job", "-o", "json")
+	require.NoError(t, err)
+	var data struct {
+		Objects []struct {
+			Object    string `json:"object"`
+			Mutations []struct {
+				Path  string      `json:"path"`
+				Type  string      `json:"type"`
+				Right interface{} `json:"right"`
+			} `json:"mutations"`
+		} `json:"objects"`
+		Unchanged int `json:"unchanged"`
+	}
+	err = s.jsonOutput(&data)
+	require.NoError(t, err)
+	a := assert.New(t)
+	require.Equal(t, 1, len(data.Objects))
+	a.Equal(1, data.Unchanged)
+	a.Equal("Deployment:bar-system:svc2-deploy", data.Objects[0].Object)
+	require.Equal(t, 2, len(data.Objects[0].Mutations))
+	a.Equal(`metadata.annotations["webhook.example.com/injected"]`, data.Objects[0].Mutations[0].Path)
+	a.Equal("added", data.Objects[0].Mutations[0].Type)
+	a.Equal("spec.revisionHistoryLimit", data.Objects[0].Mutations[1].Path)
+	a.EqualValues(10, data.Objects[0].Mutations[1].Right)
+}
+
+func TestAdmissionMutationsIgnoresLiveFields(t *testing.T) {
+	submitted := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "d", "namespace": "ns", "creationTimestamp": nil},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"creationTimestamp": nil},
+			},
+		},
+		"status": map[string]interface{}{},
+	}}
+	admitted := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":              "d",
+			"namespace":         "ns",
+			"uid":               "1234",
+			"resourceVersion":   "42",
+			"generation":        int64(3),
+			"creationTimestamp": "2021-01-01T00:00:00Z",
+			"managedFields":     []interface{}{map[string]interface{}{"manager": "kubectl"}},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{},
+			},
+		},
+		"status": map[string]interface{}{"replicas": int64(1)},
+	}}
+	changes := admissionMutations(submitted, admitted, false)
+	require.Equal(t, 1, len(changes))
+	assert.Equal(t, "spec.replicas", changes[0].Path)
+}
+
+func TestAdmissionNegative(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		init     func(s *scaffold)
+		asserter func(s *scaffold, err error)
+	}{
+		{
+			name: "no env",
+			args: []string{"alpha", "admission"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.True(cmd.IsUsageError(err))
+				a.Equal("exactly one environment required, but provided: []", err.Error())
+			},
+		},
+		{
+			name: "baseline",
+			args: []string{"alpha", "admission", "_"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.True(cmd.IsUsageError(err))
+				a.Equal("cannot run admission report for baseline environment, use a real environment", err.Error())
+			},
+		},
+		{
+			name: "bad format",
+			args: []string{"alpha", "admission", "dev", "-o", "table"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.True(cmd.IsUsageError(err))
+				a.Equal(`admission: unsupported format "table"`, err.Error())
+			},
+		},
+		{
+			name: "dry-run error",
+			args: []string{"alpha", "admission", "dev", "-c", "service2"},
+			init: func(s *scaffold) {
+				s.client.dryRunFunc = func(ctx context.Context, obj model.K8sLocalObject) (*unstructured.Unstructured, error) {
+					if obj.GetKind() == "ConfigMap" {
+						return nil, fmt.Errorf("admission webhook denied the request")
+					}
+					return obj.ToUnstructured(), nil
+				}
+			},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.Equal("server dry-run failed for 1 object(s)", err.Error())
+				a.Contains(s.stderr(), "ConfigMap:bar-system:svc2-cm: admission webhook denied the request")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newScaffold(t)
+			defer s.reset()
+			if test.init != nil {
+				test.init(s)
+			}
+			err := s.executeCommand(test.args...)
+			require.NotNil(t, err)
+			test.asserter(s, err)
+		})
+	}
+}
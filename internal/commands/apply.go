@@ -19,8 +19,12 @@ package commands
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/splunk/qbec/internal/cmd"
 	"github.com/splunk/qbec/internal/model"
@@ -28,6 +32,7 @@ import (
 	"github.com/splunk/qbec/internal/remote"
 	"github.com/splunk/qbec/internal/rollout"
 	"github.com/splunk/qbec/internal/sio"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/watch"
 )
 
@@ -37,6 +42,10 @@ type applyStats struct {
 	Skipped []string `json:"skipped,omitempty"`
 	Deleted []string `json:"deleted,omitempty"`
 	Same    int      `json:"same,omitempty"`
+	// Failed lists the objects that could not be synced or deleted when continuing past failures
+	Failed []string `json:"failed,omitempty"`
+	// Generated maps the names of objects created with generated names to their local names
+	Generated map[string]string `json:"generated,omitempty"`
 }
 
 func (a *applyStats) update(name string, s *remote.SyncResult) {
@@ -56,13 +65,23 @@ func (a *applyStats) update(name string, s *remote.SyncResult) {
 
 type applyCommandConfig struct {
 	cmd.AppContext
-	syncOptions remote.SyncOptions
-	showDetails bool
-	gc          bool
-	wait        bool
-	waitAll     bool
-	waitTimeout time.Duration
-	filterFunc  func() (model.Filters, error)
+	syncOptions     remote.SyncOptions
+	showDetails     bool
+	gc              bool
+	gcScope         gcScope
+	wait            bool
+	waitAll         bool
+	waitTimeout     time.Duration
+	rollback        bool
+	pruneOnly       bool
+	allEnvs         bool
+	envConcurrency  int
+	lock            lockOptions
+	showForeign     bool
+	skipHooks       bool
+	keepHPAReplicas bool
+	keepGoing       bool
+	filterFunc      func() (model.Filters, error)
 }
 
 type nameWrap struct {
@@ -93,14 +112,147 @@ func (n nsWrap) GetNamespace() string {
 
 var applyWaitFn = rollout.WaitUntilComplete // allow override in tests
 
+// applyEnvironments returns the list of environments to apply from the supplied arguments. Environments
+// may be specified as multiple arguments or as comma-separated lists and are applied in the order specified.
+// Empty names are rejected when more than one environment is specified.
+func applyEnvironments(args []string, config applyCommandConfig) ([]string, error) {
+	if config.allEnvs {
+		if len(args) > 0 {
+			return nil, cmd.NewUsageError(fmt.Sprintf("cannot specify environments when --all-envs is set, but provided: %q", args))
+		}
+		var ret []string
+		for env := range config.App().Environments() {
+			ret = append(ret, env)
+		}
+		sort.Strings(ret)
+		return ret, nil
+	}
+	var names []string
+	for _, arg := range args {
+		names = append(names, strings.Split(arg, ",")...)
+	}
+	if len(names) > 1 {
+		for _, name := range names {
+			if strings.TrimSpace(name) == "" {
+				return nil, cmd.NewUsageError(fmt.Sprintf("environment names must not be empty, but provided: %q", args))
+			}
+		}
+	}
+	var ret []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		env, err := config.ResolveEnv(name)
+		if err != nil {
+			return nil, err
+		}
+		if env == model.Baseline { // cannot apply for the baseline environment
+			return nil, cmd.NewUsageError("cannot apply baseline environment, use a real environment")
+		}
+		if seen[env] {
+			continue
+		}
+		seen[env] = true
+		ret = append(ret, env)
+	}
+	if len(ret) == 0 {
+		return nil, cmd.NewUsageError(fmt.Sprintf("one or more environments required, but provided: %q", args))
+	}
+	return ret, nil
+}
+
 func doApply(ctx context.Context, args []string, config applyCommandConfig) error {
-	if len(args) != 1 {
-		return cmd.NewUsageError(fmt.Sprintf("exactly one environment required, but provided: %q", args))
+	envs, err := applyEnvironments(args, config)
+	if err != nil {
+		return err
+	}
+	if config.envConcurrency < 1 {
+		return cmd.NewUsageError(fmt.Sprintf("invalid environment concurrency %d, must be at least 1", config.envConcurrency))
+	}
+	for _, env := range envs { // fail before applying anything when any environment does not accept the tag
+		if err := config.App().AssertAppTagAllowed(env); err != nil {
+			return err
+		}
 	}
-	env := args[0]
-	if env == model.Baseline { // cannot apply for the baseline environment
-		return cmd.NewUsageError("cannot apply baseline environment, use a real environment")
+	if len(envs) == 1 {
+		return applyEnvironment(ctx, envs[0], config, config.Confirm, func(stats *applyStats) {
+			printStats(config.Stdout(), stats)
+			if config.syncOptions.DryRun {
+				sio.Noticeln("** dry-run mode, nothing was actually changed **")
+			}
+		})
+	}
+	return applyMultiple(ctx, envs, config)
+}
+
+// applyMultiple applies the supplied environments in order, with up to the configured number of environments
+// being applied concurrently. No further environments are applied once an environment fails, unless the command
+// is configured to keep going. Stats are printed for all environments that were applied, keyed by environment name.
+func applyMultiple(ctx context.Context, envs []string, config applyCommandConfig) error {
+	var l sync.Mutex
+	allStats := map[string]*applyStats{}
+	var failures []string
+	failed := false
+
+	confirm := func(env string) func(string) error {
+		return func(msg string) error {
+			l.Lock()
+			defer l.Unlock()
+			return config.Confirm(fmt.Sprintf("[%s] %s", env, msg))
+		}
 	}
+
+	sem := make(chan struct{}, config.envConcurrency)
+	var wg sync.WaitGroup
+	for _, env := range envs {
+		sem <- struct{}{}
+		l.Lock()
+		stop := failed && !config.keepGoing
+		l.Unlock()
+		if stop {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(env string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			// output from environments applied concurrently is told apart by prefixing it with the environment
+			ctx := sio.WithScope(ctx, env)
+			sio.For(ctx).Noticef("applying environment %s\n", env)
+			err := applyEnvironment(ctx, env, config, confirm(env), func(stats *applyStats) {
+				l.Lock()
+				allStats[env] = stats
+				l.Unlock()
+			})
+			if err != nil {
+				sio.Errorf("apply %s: %v\n", env, err)
+				l.Lock()
+				failed = true
+				failures = append(failures, env)
+				l.Unlock()
+			}
+		}(env)
+	}
+	wg.Wait()
+
+	printStats(config.Stdout(), allStats)
+	if config.syncOptions.DryRun {
+		sio.Noticeln("** dry-run mode, nothing was actually changed **")
+	}
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return fmt.Errorf("apply failed for environment(s): %s", strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+// applyEnvironment applies objects for a single environment. The confirm function is used to prompt for changes
+// and the stats function is called with the stats for the environment once all changes have been made, before
+// waiting for objects to be ready.
+func applyEnvironment(ctx context.Context, env string, config applyCommandConfig, confirm func(string) error, statsFn func(*applyStats)) error {
+	log := sio.For(ctx)
 	envCtx, err := config.EnvContext(env)
 	if err != nil {
 		return err
@@ -113,17 +265,50 @@ func doApply(ctx context.Context, args []string, config applyCommandConfig) erro
 	if err != nil {
 		return err
 	}
-	objects, err := generateObjects(ctx, envCtx, makeFilterOpts(fp, client))
-	if err != nil {
-		return err
+	if !config.syncOptions.DryRun {
+		unlock, err := lockEnvironment(ctx, config.AppContext, client, env, config.lock)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+	// in prune-only mode, no objects are created or updated and only garbage collection is performed
+	var objects []model.K8sLocalObject
+	if !config.pruneOnly {
+		objects, err = generateObjects(ctx, envCtx, makeFilterOpts(fp, client).withPolicies())
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.showForeign {
+		if err := reportForeign(ctx, envCtx, client, objects); err != nil {
+			return err
+		}
 	}
 
 	opts := config.syncOptions
 	opts.DisableUpdateFn = newUpdatePolicy().disableUpdate
+	if config.keepHPAReplicas {
+		opts.KeepReplicasFn = newScaledObjects(objects, config.App().DefaultNamespace(env)).isScaled
+	}
 
 	if !opts.DryRun && len(objects) > 0 {
 		msg := fmt.Sprintf("will synchronize %d object(s)", len(objects))
-		if err := config.Confirm(msg); err != nil {
+		if err := confirm(msg); err != nil {
+			return err
+		}
+	}
+
+	hooks := &hookRunner{
+		envCtx:      envCtx,
+		client:      client,
+		dryRun:      opts.DryRun,
+		waitTimeout: config.waitTimeout,
+	}
+	runHooks := !config.skipHooks && !config.pruneOnly
+	if runHooks {
+		if err := hooks.run(ctx, hookPreApply, config.App().PreApplyHooks(), nil); err != nil {
 			return err
 		}
 	}
@@ -132,9 +317,9 @@ func doApply(ctx context.Context, args []string, config applyCommandConfig) erro
 	var lister lister = &stubLister{}
 	var retainObjects []model.K8sLocalObject
 	if config.gc {
-		lister, retainObjects, err = startRemoteList(ctx, envCtx, client, fp)
+		lister, retainObjects, err = startRemoteList(ctx, envCtx, client, fp, remoteListOptions{consumeCache: !opts.DryRun, gc: config.gcScope})
 		if err != nil {
-			return err
+			return cmd.WithCode(cmd.ErrorCodeGC, err)
 		}
 	}
 
@@ -148,17 +333,18 @@ func doApply(ctx context.Context, args []string, config applyCommandConfig) erro
 
 	var stats applyStats
 	var waitObjects []model.K8sMeta
+	var failures objectFailures
 
 	printSyncStatus := func(name string, res *remote.SyncResult, err error) {
 		if err != nil {
-			sio.Errorf("%ssync %s failed\n", dryRun, name)
+			log.Errorf("%ssync %s failed\n", dryRun, name)
 			return
 		}
 		if res.Type == remote.SyncObjectsIdentical {
 			if config.Verbosity() > 0 {
-				sio.Noticef("%sno changes to %s\n", dryRun, name)
+				log.Noticef("%sno changes to %s\n", dryRun, name)
 				if res.Details != "" {
-					sio.Println(res.Details)
+					log.Println(res.Details)
 				}
 			}
 			return
@@ -170,73 +356,146 @@ func doApply(ctx context.Context, args []string, config applyCommandConfig) erro
 		if res.Type == remote.SyncSkip {
 			verb = "skip"
 		}
-		sio.Noticef("%s%s %s\n", dryRun, verb, name)
+		log.Noticef("%s%s %s\n", dryRun, verb, name)
 		if config.showDetails || config.Verbosity() > 0 {
 			if res.Details != "" {
-				sio.Println(res.Details)
+				log.Println(res.Details)
 			}
 		}
 	}
 
-	waitPolicy := newWaitPolicy()
-	for _, ob := range objects {
-		name := client.DisplayName(ob)
-		res, err := client.Sync(ctx, ob, opts)
-		if res != nil && res.GeneratedName != "" {
-			ob = nameWrap{name: res.GeneratedName, K8sLocalObject: ob}
-			name = client.DisplayName(ob)
-			retainObjects = append(retainObjects, ob)
+	defaultNs := envCtx.App().DefaultNamespace(env)
+	waitFor := func(objs []model.K8sMeta) error {
+		wl := &waitListener{
+			log:           log,
+			displayNameFn: client.DisplayName,
 		}
-		printSyncStatus(name, res, err)
-		if err != nil {
+		err := applyWaitFn(objs,
+			func(obj model.K8sMeta) (watch.Interface, error) {
+				return waitWatcher(ctx, client.ResourceInterface, nsWrap{K8sMeta: obj, ns: defaultNs})
+			},
+			rollout.WaitOptions{
+				Listener:          wl,
+				Timeout:           config.waitTimeout,
+				StatusExpressions: config.App().WaitStatusExpressions(),
+			},
+		)
+		return waitError(err)
+	}
+
+	rb := &rollbackRecorder{client: client}
+	rollbackOnFailure := func(err error) error {
+		if !config.rollback {
 			return err
 		}
-		shouldWait := config.waitAll || (res.Type == remote.SyncCreated || res.Type == remote.SyncUpdated)
-		if shouldWait {
-			if waitPolicy.disableWait(ob) {
-				sio.Debugf("%s: wait disabled by policy\n", name)
-			} else {
-				waitObjects = append(waitObjects, metaWrap{K8sMeta: ob})
+		if rbErr := rb.rollback(ctx); rbErr != nil {
+			return errors.Wrap(err, rbErr.Error())
+		}
+		return errors.Wrap(err, "changes rolled back")
+	}
+
+	waitPolicy := newWaitPolicy()
+	stages := applyStages(config.App(), objects)
+	for i, stage := range stages {
+		last := i == len(stages)-1
+		var stageWaitObjects []model.K8sMeta
+		for _, ob := range stage {
+			name := client.DisplayName(ob)
+			var previous *unstructured.Unstructured
+			if config.rollback {
+				previous, err = rb.snapshot(ctx, ob)
+				if err != nil {
+					return err
+				}
+			}
+			res, err := client.Sync(ctx, ob, opts)
+			if res != nil && res.GeneratedName != "" {
+				localName := name
+				ob = nameWrap{name: res.GeneratedName, K8sLocalObject: ob}
+				name = client.DisplayName(ob)
+				retainObjects = append(retainObjects, ob)
+				if stats.Generated == nil {
+					stats.Generated = map[string]string{}
+				}
+				stats.Generated[name] = localName
+			}
+			printSyncStatus(name, res, err)
+			if err != nil {
+				if !config.keepGoing {
+					return rollbackOnFailure(err)
+				}
+				failures.add(name, err)
+				stats.Failed = append(stats.Failed, name)
+				continue
 			}
+			rb.record(ob, previous, res)
+			// objects in components that others depend on are always waited for
+			shouldWait := !last || config.waitAll || (res.Type == remote.SyncCreated || res.Type == remote.SyncUpdated)
+			if shouldWait {
+				if waitPolicy.disableWait(ob) {
+					log.Debugf("%s: wait disabled by policy\n", name)
+				} else {
+					stageWaitObjects = append(stageWaitObjects, metaWrap{K8sMeta: ob})
+				}
+			}
+			stats.update(name, res)
+		}
+		// components that depend on components with failed objects are not applied, and objects are not
+		// garbage collected when any object failed to sync
+		if len(failures) > 0 {
+			statsFn(&stats)
+			return rollbackOnFailure(failures.error("sync"))
+		}
+		if last {
+			waitObjects = stageWaitObjects
+			break
+		}
+		if opts.DryRun {
+			continue
+		}
+		log.Noticef("waiting for component(s) %s before applying dependent components\n", strings.Join(stageComponents(stage), ", "))
+		if err := waitFor(stageWaitObjects); err != nil {
+			return rollbackOnFailure(errors.Wrap(err, "wait for dependencies"))
 		}
-		stats.update(name, res)
 	}
 
 	// process deletions
 	deletions, err := lister.deletions(retainObjects, fp.Match)
 	if err != nil {
-		return err
+		return cmd.WithCode(cmd.ErrorCodeGC, err)
 	}
+	deletions = withoutHookObjects(deletions)
 
 	if !opts.DryRun && len(deletions) > 0 {
 		msg := fmt.Sprintf("will delete %d object(s)", len(deletions))
-		if err := config.Confirm(msg); err != nil {
+		if err := confirm(msg); err != nil {
 			return err
 		}
 	}
 
 	dp := newDeletePolicy(client.IsNamespaced, config.App().DefaultNamespace(env))
-	deleteOpts := remote.DeleteOptions{DryRun: opts.DryRun, DisableDeleteFn: dp.disableDelete}
+	deleteOpts := remote.DeleteOptions{DryRun: opts.DryRun, DisableDeleteFn: dp.disableDelete, Retry: opts.Retry}
 
 	deletions = objsort.SortMeta(deletions, sortConfig(client.IsNamespaced))
 
 	printDelStatus := func(name string, res *remote.SyncResult, err error) {
 		if err != nil {
-			sio.Errorf("%sdelete %s failed\n", dryRun, name)
+			log.Errorf("%sdelete %s failed\n", dryRun, name)
 			return
 		}
 		verb := "delete"
 		if res.Type == remote.SyncSkip {
 			verb = "skip delete"
 		}
-		sio.Noticef("%s%s %s\n", dryRun, verb, name)
+		log.Noticef("%s%s %s\n", dryRun, verb, name)
 		if config.showDetails || config.Verbosity() > 0 {
 			if res.Details != "" {
-				sio.Println(res.Details)
+				log.Println(res.Details)
 			}
 		}
 	}
 
+	deletions, implied := collapseNamespaceDeletions(deletions, dp)
 	for i := len(deletions) - 1; i >= 0; i-- {
 		ob := deletions[i]
 		name := client.DisplayName(ob)
@@ -244,38 +503,131 @@ func doApply(ctx context.Context, args []string, config applyCommandConfig) erro
 		res, err := client.Delete(ctx, ob, deleteOpts)
 		printDelStatus(name, res, err)
 		if err != nil {
-			return err
+			if !config.keepGoing {
+				return cmd.WithCode(cmd.ErrorCodeGC, err)
+			}
+			failures.add(name, cmd.WithCode(cmd.ErrorCodeGC, err))
+			stats.Failed = append(stats.Failed, name)
+			continue
 		}
 		stats.update(name, res)
 	}
+	printImpliedDeletions(log, client, implied, dryRun, &stats)
 
-	printStats(config.Stdout(), &stats)
-	if opts.DryRun {
-		sio.Noticeln("** dry-run mode, nothing was actually changed **")
+	statsFn(&stats)
+
+	// objects are not waited for and post-apply hooks are not run when some objects failed to be deleted
+	if len(failures) > 0 {
+		return rollbackOnFailure(failures.error("apply"))
 	}
 
-	defaultNs := envCtx.App().DefaultNamespace(env)
 	if config.wait || config.waitAll {
-		wl := &waitListener{
-			displayNameFn: client.DisplayName,
+		if err := waitFor(waitObjects); err != nil {
+			return rollbackOnFailure(err)
 		}
-		return applyWaitFn(waitObjects,
-			func(obj model.K8sMeta) (watch.Interface, error) {
-				return waitWatcher(ctx, client.ResourceInterface, nsWrap{K8sMeta: obj, ns: defaultNs})
-			},
-			rollout.WaitOptions{
-				Listener: wl,
-				Timeout:  config.waitTimeout,
-			},
-		)
 	}
 
+	if runHooks && len(config.App().PostApplyHooks()) > 0 {
+		input, err := postApplyHookInput(envCtx, &stats)
+		if err != nil {
+			return err
+		}
+		if err := hooks.run(ctx, hookPostApply, config.App().PostApplyHooks(), input); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// objectFailure is the failure of a single object operation.
+type objectFailure struct {
+	name string
+	err  error
+}
+
+// objectFailures are the object operations that failed when continuing past failures.
+type objectFailures []objectFailure
+
+func (o *objectFailures) add(name string, err error) {
+	*o = append(*o, objectFailure{name: name, err: err})
+}
+
+// error returns a consolidated error for all failures, classified using the error code of the first failure.
+func (o objectFailures) error(op string) error {
+	var lines []string
+	for _, f := range o {
+		lines = append(lines, fmt.Sprintf("- %s: %v", f.name, f.err))
+	}
+	err := fmt.Errorf("%s failed for %d object(s):\n%s", op, len(o), strings.Join(lines, "\n"))
+	return cmd.WithCode(cmd.ErrorCode(o[0].err), err)
+}
+
+// applyStages groups the supplied objects into stages such that components are applied in a later stage than
+// the components they depend on. Dependencies on components that are not being applied are resolved through
+// to their own dependencies. Objects retain their relative order within a stage, and a single stage is returned
+// when there are no dependencies between the components being applied.
+func applyStages(app *model.App, objects []model.K8sLocalObject) [][]model.K8sLocalObject {
+	present := map[string]bool{}
+	for _, ob := range objects {
+		present[ob.Component()] = true
+	}
+	levels := map[string]int{}
+	var level func(component string) int
+	level = func(component string) int {
+		if l, ok := levels[component]; ok {
+			return l
+		}
+		var ret int
+		for _, dep := range app.ComponentDependencies(component) {
+			l := level(dep)
+			if present[dep] {
+				l++
+			}
+			if l > ret {
+				ret = l
+			}
+		}
+		levels[component] = ret
+		return ret
+	}
+	byLevel := map[int][]model.K8sLocalObject{}
+	var maxLevel int
+	for _, ob := range objects {
+		l := level(ob.Component())
+		byLevel[l] = append(byLevel[l], ob)
+		if l > maxLevel {
+			maxLevel = l
+		}
+	}
+	var ret [][]model.K8sLocalObject
+	for l := 0; l <= maxLevel; l++ {
+		if len(byLevel[l]) > 0 {
+			ret = append(ret, byLevel[l])
+		}
+	}
+	if len(ret) == 0 {
+		ret = append(ret, nil)
+	}
+	return ret
+}
+
+// stageComponents returns the sorted names of components for the supplied objects.
+func stageComponents(objects []model.K8sLocalObject) []string {
+	seen := map[string]bool{}
+	var ret []string
+	for _, ob := range objects {
+		if !seen[ob.Component()] {
+			seen[ob.Component()] = true
+			ret = append(ret, ob.Component())
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
 func newApplyCommand(cp ctxProvider) *cobra.Command {
 	c := &cobra.Command{
-		Use:     "apply [-n] <environment>",
+		Use:     "apply [-n] <environment>...",
 		Short:   "apply one or more components to a Kubernetes cluster",
 		Example: applyExamples(),
 	}
@@ -283,27 +635,72 @@ func newApplyCommand(cp ctxProvider) *cobra.Command {
 	config := applyCommandConfig{
 		filterFunc: addFilterParams(c, true),
 	}
+	addComponents := addComponentDirParams(c)
 
 	c.Flags().BoolVar(&config.syncOptions.DisableCreate, "skip-create", false, "set to true to only update existing resources but not create new ones")
 	c.Flags().BoolVarP(&config.syncOptions.DryRun, "dry-run", "n", false, "dry-run, do not create/ update resources but show what would happen")
 	c.Flags().BoolVarP(&config.syncOptions.ShowSecrets, "show-secrets", "S", false, "do not obfuscate secret values in the output")
+	c.Flags().BoolVar(&config.keepHPAReplicas, "keep-hpa-replicas", true, "keep the live replica counts of existing objects scaled by horizontal pod autoscalers produced by the app")
+	c.Flags().BoolVar(&config.syncOptions.ContentHash, "content-hash", false, "stamp a content hash annotation on objects and skip updates for objects whose live hash matches")
 	c.Flags().BoolVar(&config.showDetails, "show-details", false, "show details for object operations")
 	c.Flags().BoolVar(&config.gc, "gc", true, "garbage collect extra objects on the server")
+	gcScopeFn := addGCScopeFlags(c)
 	c.Flags().BoolVar(&config.wait, "wait", false, "wait for changed objects to be ready")
 	c.Flags().BoolVar(&config.waitAll, "wait-all", true, "wait for all objects to be ready, not just the ones that have changed")
-	var waitTime string
+	c.Flags().BoolVar(&config.pruneOnly, "prune-only", false, "do not create or update objects, only garbage collect extra objects on the server")
+	c.Flags().BoolVar(&config.rollback, "rollback-on-failure", false, "undo changes to created and updated objects when the apply fails")
+	c.Flags().BoolVar(&config.skipHooks, "skip-hooks", false, "do not run pre-apply and post-apply hooks defined for the app")
+	c.Flags().BoolVar(&config.showForeign, "show-foreign", false, "list objects in the target namespaces that are managed by other apps, tags or environments before applying changes")
+	c.Flags().BoolVar(&config.allEnvs, "all-envs", false, "apply all environments defined for the app, in alphabetical order")
+	c.Flags().BoolVar(&config.keepGoing, "keep-going", false, "continue with remaining objects and environments after a failure, reporting all failures at the end")
+	c.Flags().IntVar(&config.envConcurrency, "env-concurrency", 1, "number of environments to apply concurrently when applying multiple environments")
+	var waitTime, typesWaitTime string
 	c.Flags().StringVar(&waitTime, "wait-timeout", "5m", "wait timeout")
+	var waitForTypes bool
+	c.Flags().BoolVar(&waitForTypes, "wait-for-types", true, "wait for created custom resource definitions to be established and their types to be available")
+	c.Flags().StringVar(&typesWaitTime, "wait-for-types-timeout", "2m", "timeout for waiting for custom types")
+	lockOpts := addLockFlags(c)
+	retryFn := addRetryFlags(c)
 
 	c.RunE = func(c *cobra.Command, args []string) error {
 		config.AppContext = cp()
+		if err := addComponents(config.App()); err != nil {
+			return cmd.WrapError(err)
+		}
+		config.lock = *lockOpts
 		var err error
+		config.syncOptions.Retry, err = retryFn()
+		if err != nil {
+			return err
+		}
+		config.gcScope, err = gcScopeFn()
+		if err != nil {
+			return cmd.WrapError(err)
+		}
 		config.waitTimeout, err = time.ParseDuration(waitTime)
 		if err != nil {
 			return cmd.NewUsageError(fmt.Sprintf("invalid wait timeout: %s, %v", waitTime, err))
 		}
+		config.syncOptions.WaitOptions.NoWait = !waitForTypes
+		config.syncOptions.WaitOptions.Timeout, err = time.ParseDuration(typesWaitTime)
+		if err != nil {
+			return cmd.NewUsageError(fmt.Sprintf("invalid wait for types timeout: %s, %v", typesWaitTime, err))
+		}
+		if config.pruneOnly {
+			if !config.gc {
+				return cmd.NewUsageError("cannot specify --prune-only when garbage collection is disabled")
+			}
+			config.wait = false
+			config.waitAll = false
+			config.rollback = false
+		}
 		if config.syncOptions.DryRun {
 			config.wait = false
 			config.waitAll = false
+			config.rollback = false
+		}
+		if config.rollback && !config.wait && !config.waitAll {
+			return cmd.NewUsageError("--rollback-on-failure requires one of --wait or --wait-all")
 		}
 		if !c.Flag("show-details").Changed {
 			config.showDetails = config.syncOptions.DryRun
@@ -19,8 +19,13 @@ package commands
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/splunk/qbec/internal/cmd"
 	"github.com/splunk/qbec/internal/model"
@@ -96,14 +101,347 @@ func TestApplyBasic(t *testing.T) {
 	a.False(captured.DryRun)
 	a.False(captured.DisableCreate)
 	a.False(captured.ShowSecrets)
+	a.False(captured.WaitOptions.NoWait)
+	a.Equal(2*time.Minute, captured.WaitOptions.Timeout)
 	a.True(stats["same"].(float64) > 0)
 	a.EqualValues(8, stats["same"])
 	a.EqualValues([]interface{}{"Secret:bar-system:svc2-secret", "Job::tj-1234"}, stats["created"])
 	a.EqualValues([]interface{}{"ConfigMap:bar-system:svc2-cm"}, stats["updated"])
 	a.EqualValues([]interface{}{"Deployment:bar-system:svc2-previous-deploy"}, stats["deleted"])
+	a.EqualValues(map[string]interface{}{"Job::tj-1234": "Job::tj-<xxxxx>"}, stats["generated"])
 	s.assertErrorLineMatch(regexp.MustCompile(`update ConfigMap:bar-system:svc2-cm`))
 }
 
+func TestApplyRollbackOnFailure(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	origWait := applyWaitFn
+	applyWaitFn = func(objects []model.K8sMeta, wp rollout.WatchProvider, opts rollout.WaitOptions) (finalErr error) {
+		return fmt.Errorf("wait timed out after 5m")
+	}
+	defer func() { applyWaitFn = origWait }()
+	lastApplied := `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"svc2-cm","namespace":"bar-system"},"data":{"foo":"old"}}`
+	s.client.getFunc = func(ctx context.Context, obj model.K8sMeta) (*unstructured.Unstructured, error) {
+		if obj.GetName() == "svc2-cm" {
+			return &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata": map[string]interface{}{
+					"name":            "svc2-cm",
+					"namespace":       "bar-system",
+					"resourceVersion": "10",
+					"annotations": map[string]interface{}{
+						"kubectl.kubernetes.io/last-applied-configuration": lastApplied,
+					},
+				},
+				"data": map[string]interface{}{"foo": "old"},
+			}}, nil
+		}
+		return nil, remote.ErrNotFound
+	}
+	var restored []model.K8sLocalObject
+	applying := true
+	s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+		if !applying {
+			restored = append(restored, obj)
+			return &remote.SyncResult{Type: remote.SyncUpdated}, nil
+		}
+		switch {
+		case obj.GetName() == "svc2-cm":
+			return &remote.SyncResult{Type: remote.SyncUpdated, Details: "data updated"}, nil
+		case obj.GetName() == "svc2-secret":
+			return &remote.SyncResult{Type: remote.SyncCreated, Details: "some yaml"}, nil
+		case obj.GetName() == "":
+			return &remote.SyncResult{Type: remote.SyncCreated, GeneratedName: obj.GetGenerateName() + "1234", Details: "created"}, nil
+		default:
+			return &remote.SyncResult{Type: remote.SyncObjectsIdentical, Details: "sync skipped"}, nil
+		}
+	}
+	var deleted []string
+	s.client.deleteFunc = func(ctx context.Context, obj model.K8sMeta, opts remote.DeleteOptions) (*remote.SyncResult, error) {
+		applying = false
+		deleted = append(deleted, obj.GetName())
+		return &remote.SyncResult{Type: remote.SyncDeleted}, nil
+	}
+	err := s.executeCommand("apply", "dev", "--gc=false", "--rollback-on-failure")
+	require.Error(t, err)
+	a := assert.New(t)
+	a.Equal("changes rolled back: wait timed out after 5m", err.Error())
+	a.Equal([]string{"tj-1234", "svc2-secret"}, deleted)
+	require.Equal(t, 1, len(restored))
+	a.Equal("svc2-cm", restored[0].GetName())
+	a.Equal("service2", restored[0].Component())
+	data, _, _ := unstructured.NestedStringMap(restored[0].ToUnstructured().Object, "data")
+	a.Equal(map[string]string{"foo": "old"}, data)
+	s.assertErrorLineMatch(regexp.MustCompile(`rolling back 3 object\(s\)`))
+	s.assertErrorLineMatch(regexp.MustCompile(`rollback: restore ConfigMap:bar-system:svc2-cm`))
+	s.assertErrorLineMatch(regexp.MustCompile(`rollback: delete Secret:bar-system:svc2-secret`))
+}
+
+func TestApplyBadWaitStatus(t *testing.T) {
+	extra := filepath.Join(t.TempDir(), "adhoc")
+	require.NoError(t, os.Mkdir(extra, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(extra, "index.jsonnet"),
+		[]byte(`{ apiVersion: 'v1', kind: 'ConfigMap', metadata: { name: 'adhoc-cm', annotations: { 'directives.qbec.io/wait-status': '.status.phase' } } }`), 0644))
+	s := newScaffold(t)
+	defer s.reset()
+	synced := false
+	s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+		synced = true
+		return &remote.SyncResult{Type: remote.SyncObjectsIdentical}, nil
+	}
+	err := s.executeCommand("apply", "dev", "--gc=false", "--component-dir", extra)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `ConfigMap adhoc-cm (component: adhoc): invalid status expression ".status.phase"`)
+	assert.False(t, synced)
+}
+
+func TestApplyRollbackNeedsWait(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("apply", "dev", "--wait-all=false", "--rollback-on-failure")
+	require.Error(t, err)
+	assert.True(t, cmd.IsUsageError(err))
+	assert.Equal(t, "--rollback-on-failure requires one of --wait or --wait-all", err.Error())
+}
+
+func TestApplyPruneOnly(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	origWait := applyWaitFn
+	applyWaitFn = func(objects []model.K8sMeta, wp rollout.WatchProvider, opts rollout.WaitOptions) (finalErr error) {
+		assert.Fail(t, "wait called in prune-only mode")
+		return nil
+	}
+	defer func() { applyWaitFn = origWait }()
+	s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+		assert.Fail(t, "sync called in prune-only mode", obj.GetName())
+		return nil, fmt.Errorf("unexpected sync")
+	}
+	s.client.listFunc = stdLister
+	s.client.deleteFunc = func(ctx context.Context, obj model.K8sMeta, opts remote.DeleteOptions) (*remote.SyncResult, error) {
+		return &remote.SyncResult{Type: remote.SyncDeleted}, nil
+	}
+	err := s.executeCommand("apply", "dev", "--prune-only")
+	require.NoError(t, err)
+	stats := s.outputStats()
+	a := assert.New(t)
+	a.Nil(stats["created"])
+	a.Nil(stats["updated"])
+	a.EqualValues([]interface{}{"Deployment:bar-system:svc2-previous-deploy"}, stats["deleted"])
+}
+
+func TestApplyPruneOnlyNoGC(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("apply", "dev", "--prune-only", "--gc=false")
+	require.Error(t, err)
+	assert.True(t, cmd.IsUsageError(err))
+	assert.Equal(t, "cannot specify --prune-only when garbage collection is disabled", err.Error())
+}
+
+func TestApplyMultipleEnvs(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	var l sync.Mutex
+	var envs []string
+	s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+		l.Lock()
+		defer l.Unlock()
+		if len(envs) == 0 || envs[len(envs)-1] != obj.Environment() {
+			envs = append(envs, obj.Environment())
+		}
+		if obj.GetName() == "svc2-cm" {
+			return &remote.SyncResult{Type: remote.SyncUpdated}, nil
+		}
+		return &remote.SyncResult{Type: remote.SyncObjectsIdentical}, nil
+	}
+	s.client.listFunc = stdLister
+	err := s.executeCommand("apply", "prod,dev", "dev", "--gc=false", "--wait-all=false")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prod", "dev"}, envs)
+	stats := s.outputStats()
+	require.Contains(t, stats, "dev")
+	require.Contains(t, stats, "prod")
+	assert.EqualValues(t, []interface{}{"ConfigMap:bar-system:svc2-cm"}, stats["dev"].(map[string]interface{})["updated"])
+	s.assertErrorLineMatch(regexp.MustCompile(`^\[prod\] applying environment prod$`))
+	s.assertErrorLineMatch(regexp.MustCompile(`^\[dev\] update ConfigMap:bar-system:svc2-cm$`))
+	s.assertErrorLineMatch(regexp.MustCompile(`^\[prod\] update ConfigMap:bar-system:svc2-cm$`))
+}
+
+func TestApplyMultipleEnvsConcurrentOutput(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+		return &remote.SyncResult{Type: remote.SyncCreated}, nil
+	}
+	s.client.listFunc = stdLister
+	err := s.executeCommand("apply", "dev,prod", "--gc=false", "--wait-all=false", "--env-concurrency=2")
+	require.NoError(t, err)
+	for _, line := range strings.Split(strings.TrimSpace(s.stderr()), "\n") {
+		if strings.HasPrefix(line, "**") {
+			continue
+		}
+		assert.Regexp(t, `^\[(dev|prod)\] `, line)
+	}
+	s.assertErrorLineMatch(regexp.MustCompile(`^\[dev\] create ConfigMap:bar-system:svc2-cm$`))
+	s.assertErrorLineMatch(regexp.MustCompile(`^\[prod\] create ConfigMap:bar-system:svc2-cm$`))
+}
+
+func TestApplyAllEnvsFailFast(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	var l sync.Mutex
+	applied := map[string]bool{}
+	s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+		l.Lock()
+		defer l.Unlock()
+		applied[obj.Environment()] = true
+		if obj.Environment() == "local" {
+			return nil, fmt.Errorf("cluster unreachable")
+		}
+		return &remote.SyncResult{Type: remote.SyncObjectsIdentical}, nil
+	}
+	s.client.listFunc = stdLister
+	err := s.executeCommand("apply", "--all-envs", "--gc=false", "--wait-all=false")
+	require.Error(t, err)
+	assert.Equal(t, "apply failed for environment(s): local", err.Error())
+	assert.True(t, applied["dev"])
+	assert.True(t, applied["local"])
+	assert.False(t, applied["prod"])
+	assert.False(t, applied["stage"])
+	stats := s.outputStats()
+	assert.Contains(t, stats, "dev")
+	assert.NotContains(t, stats, "local")
+	s.assertErrorLineMatch(regexp.MustCompile(`apply local: cluster unreachable`))
+}
+
+func TestApplyKeepGoing(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	waited := false
+	origWait := applyWaitFn
+	applyWaitFn = func(objects []model.K8sMeta, wp rollout.WatchProvider, opts rollout.WaitOptions) (finalErr error) {
+		waited = true
+		return nil
+	}
+	defer func() { applyWaitFn = origWait }()
+	var synced []string
+	s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+		synced = append(synced, obj.GetName())
+		switch obj.GetName() {
+		case "svc2-cm":
+			return nil, fmt.Errorf("server unavailable")
+		case "svc2-secret":
+			return &remote.SyncResult{Type: remote.SyncCreated}, nil
+		default:
+			return &remote.SyncResult{Type: remote.SyncObjectsIdentical}, nil
+		}
+	}
+	s.client.listFunc = stdLister
+	deleted := false
+	s.client.deleteFunc = func(ctx context.Context, obj model.K8sMeta, opts remote.DeleteOptions) (*remote.SyncResult, error) {
+		deleted = true
+		return &remote.SyncResult{Type: remote.SyncDeleted}, nil
+	}
+	err := s.executeCommand("apply", "dev", "--keep-going", "--wait")
+	require.Error(t, err)
+	a := assert.New(t)
+	a.Equal("sync failed for 1 object(s):\n"+
+		"- ConfigMap:bar-system:svc2-cm: server unavailable", err.Error())
+	a.Equal(cmd.ErrorCodeRuntime, cmd.ErrorCode(err))
+	a.Contains(synced, "svc2-secret")
+	a.False(waited)
+	a.False(deleted, "objects garbage collected after sync failures")
+	stats := s.outputStats()
+	a.EqualValues([]interface{}{"ConfigMap:bar-system:svc2-cm"}, stats["failed"])
+	a.EqualValues([]interface{}{"Secret:bar-system:svc2-secret"}, stats["created"])
+	s.assertErrorLineMatch(regexp.MustCompile(`sync ConfigMap:bar-system:svc2-cm failed`))
+}
+
+func TestApplyKeepGoingDeletions(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	waited := false
+	origWait := applyWaitFn
+	applyWaitFn = func(objects []model.K8sMeta, wp rollout.WatchProvider, opts rollout.WaitOptions) (finalErr error) {
+		waited = true
+		return nil
+	}
+	defer func() { applyWaitFn = origWait }()
+	s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+		return &remote.SyncResult{Type: remote.SyncObjectsIdentical}, nil
+	}
+	s.client.listFunc = stdLister
+	s.client.deleteFunc = func(ctx context.Context, obj model.K8sMeta, opts remote.DeleteOptions) (*remote.SyncResult, error) {
+		return nil, fmt.Errorf("delete forbidden")
+	}
+	err := s.executeCommand("apply", "dev", "--keep-going", "--wait")
+	require.Error(t, err)
+	a := assert.New(t)
+	a.Equal("apply failed for 1 object(s):\n"+
+		"- Deployment:bar-system:svc2-previous-deploy: delete forbidden", err.Error())
+	a.Equal(cmd.ErrorCodeGC, cmd.ErrorCode(err))
+	a.False(waited)
+	stats := s.outputStats()
+	a.EqualValues([]interface{}{"Deployment:bar-system:svc2-previous-deploy"}, stats["failed"])
+}
+
+func TestApplyKeepGoingRollback(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	s.client.getFunc = func(ctx context.Context, obj model.K8sMeta) (*unstructured.Unstructured, error) {
+		return nil, remote.ErrNotFound
+	}
+	s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+		switch {
+		case obj.GetName() == "svc2-cm":
+			return nil, fmt.Errorf("server unavailable")
+		case obj.GetName() == "svc2-secret":
+			return &remote.SyncResult{Type: remote.SyncCreated}, nil
+		default:
+			return &remote.SyncResult{Type: remote.SyncObjectsIdentical}, nil
+		}
+	}
+	var deleted []string
+	s.client.deleteFunc = func(ctx context.Context, obj model.K8sMeta, opts remote.DeleteOptions) (*remote.SyncResult, error) {
+		deleted = append(deleted, obj.GetName())
+		return &remote.SyncResult{Type: remote.SyncDeleted}, nil
+	}
+	err := s.executeCommand("apply", "dev", "--gc=false", "--keep-going", "--rollback-on-failure")
+	require.Error(t, err)
+	a := assert.New(t)
+	a.Equal("changes rolled back: sync failed for 1 object(s):\n- ConfigMap:bar-system:svc2-cm: server unavailable", err.Error())
+	a.Equal([]string{"svc2-secret"}, deleted)
+	s.assertErrorLineMatch(regexp.MustCompile(`rollback: delete Secret:bar-system:svc2-secret`))
+}
+
+func TestApplyAllEnvsKeepGoing(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	var l sync.Mutex
+	applied := map[string]bool{}
+	s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+		l.Lock()
+		defer l.Unlock()
+		applied[obj.Environment()] = true
+		if obj.Environment() == "local" {
+			return nil, fmt.Errorf("cluster unreachable")
+		}
+		return &remote.SyncResult{Type: remote.SyncObjectsIdentical}, nil
+	}
+	s.client.listFunc = stdLister
+	err := s.executeCommand("apply", "--all-envs", "--gc=false", "--wait-all=false", "--keep-going")
+	require.Error(t, err)
+	assert.Equal(t, "apply failed for environment(s): local", err.Error())
+	for _, env := range []string{"dev", "local", "prod", "stage"} {
+		assert.True(t, applied[env], env)
+	}
+	stats := s.outputStats()
+	assert.Contains(t, stats, "prod")
+	assert.Contains(t, stats, "local")
+}
+
 func TestApplyFlags(t *testing.T) {
 	s := newScaffold(t)
 	defer s.reset()
@@ -137,6 +475,114 @@ func TestApplyFlags(t *testing.T) {
 	s.assertErrorLineMatch(regexp.MustCompile(`\*\* dry-run mode, nothing was actually changed \*\*`))
 }
 
+func TestApplyWaitForTypesFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected remote.TypeWaitOptions
+	}{
+		{
+			name:     "defaults",
+			expected: remote.TypeWaitOptions{Timeout: 2 * time.Minute},
+		},
+		{
+			name:     "timeout",
+			args:     []string{"--wait-for-types-timeout=30s"},
+			expected: remote.TypeWaitOptions{Timeout: 30 * time.Second},
+		},
+		{
+			name:     "no wait",
+			args:     []string{"--wait-for-types=false", "--wait-for-types-timeout=30s"},
+			expected: remote.TypeWaitOptions{NoWait: true, Timeout: 30 * time.Second},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newScaffold(t)
+			defer s.reset()
+			var l sync.Mutex
+			var captured []remote.TypeWaitOptions
+			s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+				l.Lock()
+				defer l.Unlock()
+				captured = append(captured, opts.WaitOptions)
+				return &remote.SyncResult{Type: remote.SyncObjectsIdentical, Details: "sync skipped"}, nil
+			}
+			args := append([]string{"apply", "dev", "--gc=false", "--wait-all=false"}, test.args...)
+			err := s.executeCommand(args...)
+			require.NoError(t, err)
+			require.NotEmpty(t, captured)
+			for _, wo := range captured {
+				assert.Equal(t, test.expected, wo)
+			}
+		})
+	}
+}
+
+func TestApplyRetryFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected remote.RetryPolicy
+	}{
+		{
+			name:     "defaults",
+			expected: remote.RetryPolicy{Backoff: time.Second, MaxBackoff: 30 * time.Second},
+		},
+		{
+			name:     "custom",
+			args:     []string{"--retries=3", "--retry-backoff=2s", "--retry-max-backoff=10s"},
+			expected: remote.RetryPolicy{Retries: 3, Backoff: 2 * time.Second, MaxBackoff: 10 * time.Second},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newScaffold(t)
+			defer s.reset()
+			var l sync.Mutex
+			var syncRetries, deleteRetries []remote.RetryPolicy
+			s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+				l.Lock()
+				defer l.Unlock()
+				syncRetries = append(syncRetries, opts.Retry)
+				return &remote.SyncResult{Type: remote.SyncObjectsIdentical, Details: "sync skipped"}, nil
+			}
+			s.client.listFunc = stdLister
+			s.client.deleteFunc = func(ctx context.Context, obj model.K8sMeta, opts remote.DeleteOptions) (*remote.SyncResult, error) {
+				l.Lock()
+				defer l.Unlock()
+				deleteRetries = append(deleteRetries, opts.Retry)
+				return &remote.SyncResult{Type: remote.SyncDeleted}, nil
+			}
+			args := append([]string{"apply", "dev", "--wait-all=false"}, test.args...)
+			err := s.executeCommand(args...)
+			require.NoError(t, err)
+			require.NotEmpty(t, syncRetries)
+			require.NotEmpty(t, deleteRetries)
+			for _, r := range append(syncRetries, deleteRetries...) {
+				assert.Equal(t, test.expected, r)
+			}
+		})
+	}
+}
+
+func TestApplyQuiet(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+		if obj.GetName() == "svc2-cm" {
+			return &remote.SyncResult{Type: remote.SyncUpdated, Details: "data updated"}, nil
+		}
+		return &remote.SyncResult{Type: remote.SyncObjectsIdentical, Details: "sync skipped"}, nil
+	}
+	err := s.executeCommand("apply", "dev", "-n", "--gc=false", "--wait-all=false", "--quiet")
+	require.NoError(t, err)
+	stats := s.outputStats()
+	a := assert.New(t)
+	a.EqualValues([]interface{}{"ConfigMap:bar-system:svc2-cm"}, stats["updated"])
+	a.Equal("", s.stderr())
+}
+
 func TestApplyNamespaceClusterFilters(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -210,6 +656,137 @@ func TestApplyNamespaceFilterMetadataError(t *testing.T) {
 	assert.Equal(t, "namespace filter: no metadata found", err.Error())
 }
 
+func TestApplyComponentDependencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		waitErr  error
+		synced   []string
+		waits    [][]string
+		errorMsg string
+	}{
+		{
+			name:   "basic",
+			args:   []string{"apply", "local", "--gc=false"},
+			synced: []string{"CustomResourceDefinition::foos.test.qbec.io", "ConfigMap::app-config", "Foo::foo", "Deployment::app"},
+			waits: [][]string{
+				{"CustomResourceDefinition::foos.test.qbec.io", "ConfigMap::app-config"},
+				{"Foo::foo", "Deployment::app"},
+			},
+		},
+		{
+			name:   "dependency not applied",
+			args:   []string{"apply", "local", "--gc=false", "-c", "crds", "-c", "app"},
+			synced: []string{"CustomResourceDefinition::foos.test.qbec.io", "Deployment::app"},
+			waits: [][]string{
+				{"CustomResourceDefinition::foos.test.qbec.io", "Deployment::app"},
+			},
+		},
+		{
+			name:   "dry run",
+			args:   []string{"apply", "local", "--gc=false", "-n"},
+			synced: []string{"CustomResourceDefinition::foos.test.qbec.io", "ConfigMap::app-config", "Foo::foo", "Deployment::app"},
+		},
+		{
+			name:     "wait failure",
+			args:     []string{"apply", "local", "--gc=false"},
+			waitErr:  fmt.Errorf("1 wait errors"),
+			synced:   []string{"CustomResourceDefinition::foos.test.qbec.io", "ConfigMap::app-config"},
+			waits:    [][]string{{"CustomResourceDefinition::foos.test.qbec.io", "ConfigMap::app-config"}},
+			errorMsg: "wait for dependencies: 1 wait errors",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newCustomScaffold(t, "testdata/projects/component-deps")
+			defer s.reset()
+			var waits [][]string
+			origWait := applyWaitFn
+			applyWaitFn = func(objects []model.K8sMeta, wp rollout.WatchProvider, opts rollout.WaitOptions) (finalErr error) {
+				var names []string
+				for _, o := range objects {
+					names = append(names, s.client.DisplayName(o))
+				}
+				waits = append(waits, names)
+				return test.waitErr
+			}
+			defer func() { applyWaitFn = origWait }()
+			var synced []string
+			s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+				synced = append(synced, s.client.DisplayName(obj))
+				return &remote.SyncResult{Type: remote.SyncCreated}, nil
+			}
+			err := s.executeCommand(test.args...)
+			if test.errorMsg != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.errorMsg, err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.synced, synced)
+			assert.Equal(t, test.waits, waits)
+		})
+	}
+}
+
+func TestApplyErrorCodes(t *testing.T) {
+	bad := filepath.Join(t.TempDir(), "bad.jsonnet")
+	require.NoError(t, os.WriteFile(bad, []byte(`error 'boom'`), 0644))
+	tests := []struct {
+		name  string
+		args  []string
+		setup func(s *scaffold)
+		code  string
+	}{
+		{
+			name: "wait timeout",
+			args: []string{"apply", "dev", "--gc=false"},
+			setup: func(s *scaffold) {
+				applyWaitFn = func(objects []model.K8sMeta, wp rollout.WatchProvider, opts rollout.WaitOptions) error {
+					return &rollout.TimeoutError{Timeout: time.Second}
+				}
+			},
+			code: cmd.ErrorCodeWaitTimeout,
+		},
+		{
+			name: "gc",
+			args: []string{"apply", "dev"},
+			setup: func(s *scaffold) {
+				s.client.listFunc = func(ctx context.Context, scope remote.ListQueryConfig) (remote.Collection, error) {
+					return nil, fmt.Errorf("list failed")
+				}
+			},
+			code: cmd.ErrorCodeGC,
+		},
+		{
+			name: "eval",
+			args: []string{"apply", "dev", "--component-dir", bad},
+			code: cmd.ErrorCodeEval,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newScaffold(t)
+			defer s.reset()
+			origWait := applyWaitFn
+			defer func() { applyWaitFn = origWait }()
+			applyWaitFn = func(objects []model.K8sMeta, wp rollout.WatchProvider, opts rollout.WaitOptions) error {
+				return nil
+			}
+			s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+				return &remote.SyncResult{Type: remote.SyncObjectsIdentical}, nil
+			}
+			s.client.listFunc = stdLister
+			if test.setup != nil {
+				test.setup(s)
+			}
+			err := s.executeCommand(test.args...)
+			require.Error(t, err)
+			assert.Equal(t, test.code, cmd.ErrorCode(err))
+		})
+	}
+}
+
 func TestApplyNegative(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -222,16 +799,71 @@ func TestApplyNegative(t *testing.T) {
 			asserter: func(s *scaffold, err error) {
 				a := assert.New(s.t)
 				a.True(cmd.IsUsageError(err))
-				a.Equal("exactly one environment required, but provided: []", err.Error())
+				a.Equal("one or more environments required, but provided: []", err.Error())
+			},
+		},
+		{
+			name: "envs and all envs",
+			args: []string{"apply", "dev", "prod", "--all-envs"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.True(cmd.IsUsageError(err))
+				a.Equal("cannot specify environments when --all-envs is set, but provided: [\"dev\" \"prod\"]", err.Error())
+			},
+		},
+		{
+			name: "bad types wait timeout",
+			args: []string{"apply", "dev", "--wait-for-types-timeout=forever"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.True(cmd.IsUsageError(err))
+				a.Equal(`invalid wait for types timeout: forever, time: invalid duration "forever"`, err.Error())
 			},
 		},
 		{
-			name: "2 envs",
-			args: []string{"apply", "dev", "prod"},
+			name: "bad retries",
+			args: []string{"apply", "dev", "--retries=-1"},
 			asserter: func(s *scaffold, err error) {
 				a := assert.New(s.t)
 				a.True(cmd.IsUsageError(err))
-				a.Equal("exactly one environment required, but provided: [\"dev\" \"prod\"]", err.Error())
+				a.Equal("invalid retries: -1, must not be negative", err.Error())
+			},
+		},
+		{
+			name: "bad env concurrency",
+			args: []string{"apply", "dev,prod", "--env-concurrency=0"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.True(cmd.IsUsageError(err))
+				a.Equal("invalid environment concurrency 0, must be at least 1", err.Error())
+			},
+		},
+		{
+			name: "empty env in list",
+			args: []string{"apply", "dev,"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.True(cmd.IsUsageError(err))
+				a.Equal(`environment names must not be empty, but provided: ["dev,"]`, err.Error())
+			},
+		},
+		{
+			name: "baseline in list",
+			args: []string{"apply", "dev,_"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.True(cmd.IsUsageError(err))
+				a.Equal("cannot apply baseline environment, use a real environment", err.Error())
+			},
+		},
+		{
+			name: "app tag denied",
+			args: []string{"apply", "dev,prod", "--app-tag=pr-1"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.False(cmd.IsUsageError(err))
+				a.Equal(`environment prod does not allow app tag "pr-1"`, err.Error())
+				a.Equal("", s.stdout())
 			},
 		},
 		{
@@ -300,3 +932,37 @@ func TestApplyNegative(t *testing.T) {
 	}
 
 }
+
+func TestApplyRemoteCache(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "lists.json")
+	tests := []struct {
+		name    string
+		args    []string
+		consume bool
+	}{
+		{name: "diff", args: []string{"diff", "dev", "--remote-cache", cacheFile}},
+		{name: "apply-dry-run", args: []string{"apply", "dev", "-n", "--remote-cache", cacheFile}},
+		{name: "apply", args: []string{"apply", "dev", "--remote-cache", cacheFile}, consume: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newScaffold(t)
+			defer s.reset()
+			var captured remote.ListQueryConfig
+			s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+				return &remote.SyncResult{Type: remote.SyncObjectsIdentical, Details: "sync skipped"}, nil
+			}
+			s.client.listFunc = func(ctx context.Context, scope remote.ListQueryConfig) (remote.Collection, error) {
+				captured = scope
+				return stdLister(ctx, scope)
+			}
+			s.client.deleteFunc = func(ctx context.Context, obj model.K8sMeta, opts remote.DeleteOptions) (*remote.SyncResult, error) {
+				return &remote.SyncResult{Type: remote.SyncDeleted}, nil
+			}
+			_ = s.executeCommand(test.args...)
+			require.NotNil(t, captured.Cache)
+			assert.Equal(t, cacheFile, captured.Cache.File())
+			assert.Equal(t, test.consume, captured.ConsumeCache)
+		})
+	}
+}
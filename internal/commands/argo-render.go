@@ -0,0 +1,117 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/objyaml"
+)
+
+const (
+	// argoEnvPrefix is the prefix that ArgoCD adds to the names of environment variables set for a config
+	// management plugin.
+	argoEnvPrefix = "ARGOCD_ENV_"
+	// argoEnvVar is the environment variable that holds the qbec environment to render.
+	argoEnvVar = argoEnvPrefix + "QBEC_ENV"
+)
+
+// argoEnvFlags are the environment variables that set flags for the argo-render command when the flags are not
+// explicitly specified.
+var argoEnvFlags = []struct {
+	name  string
+	flag  string
+	multi bool // comma-separated list of values
+}{
+	{name: argoEnvPrefix + "QBEC_APP_TAG", flag: "app-tag"},
+	{name: argoEnvPrefix + "QBEC_COMPONENTS", flag: "component", multi: true},
+	{name: argoEnvPrefix + "QBEC_EXCLUDE_COMPONENTS", flag: "exclude-component", multi: true},
+}
+
+// applyArgoEnv sets the flags for the argo-render command from their environment variables. It must be called
+// before the qbec context is created since the app tag is used to load the app.
+func applyArgoEnv(flags *pflag.FlagSet) error {
+	for _, ef := range argoEnvFlags {
+		v := strings.TrimSpace(os.Getenv(ef.name))
+		if v == "" || flags.Changed(ef.flag) {
+			continue
+		}
+		values := []string{v}
+		if ef.multi {
+			values = strings.Split(v, ",")
+		}
+		for _, value := range values {
+			if err := flags.Set(ef.flag, strings.TrimSpace(value)); err != nil {
+				return cmd.NewUsageError(fmt.Sprintf("%s: %v", ef.name, err))
+			}
+		}
+	}
+	return nil
+}
+
+type argoRenderCommandConfig struct {
+	cmd.AppContext
+	filterFunc func() (model.Filters, error)
+}
+
+func doArgoRender(ctx context.Context, args []string, config argoRenderCommandConfig) error {
+	var env string
+	switch len(args) {
+	case 0:
+		env = strings.TrimSpace(os.Getenv(argoEnvVar))
+		if env == "" {
+			return cmd.NewUsageError(fmt.Sprintf("environment must be specified as an argument or using %s", argoEnvVar))
+		}
+	case 1:
+		env = args[0]
+	default:
+		return cmd.NewUsageError(fmt.Sprintf("at most one environment may be specified, but provided: %q", args))
+	}
+	cleanEvalMode = true
+	return doShow(ctx, []string{env}, showCommandConfig{
+		AppContext:  config.AppContext,
+		showSecrets: true,
+		format:      "yaml",
+		fieldOrder:  string(objyaml.Alphabetical),
+		filterFunc:  config.filterFunc,
+	})
+}
+
+func newArgoRenderCommand(cp ctxProvider) *cobra.Command {
+	c := &cobra.Command{
+		Use:     "argo-render [<environment>]",
+		Short:   "render objects for an environment as an ArgoCD config management plugin",
+		Example: argoRenderExamples(),
+	}
+
+	config := argoRenderCommandConfig{
+		filterFunc: addFilterParams(c, false),
+	}
+
+	c.RunE = func(c *cobra.Command, args []string) error {
+		config.AppContext = cp()
+		return cmd.WrapError(doArgoRender(c.Context(), args, config))
+	}
+	return c
+}
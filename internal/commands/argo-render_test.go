@@ -0,0 +1,79 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArgoRenderFromEnv(t *testing.T) {
+	t.Setenv("ARGOCD_ENV_QBEC_ENV", "dev")
+	t.Setenv("ARGOCD_ENV_QBEC_COMPONENTS", "service2, test-job")
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("argo-render")
+	require.NoError(t, err)
+	out := s.stdout()
+	a := assert.New(t)
+	a.Contains(out, "name: svc2-deploy\n")
+	a.Contains(out, "generateName: tj-\n")
+	a.NotContains(out, "name: allow-root-psp-policy")
+	a.NotContains(out, "qbec.io/")
+	a.Contains(out, base64.StdEncoding.EncodeToString([]byte("bar")))
+}
+
+func TestArgoRenderFlagsOverrideEnv(t *testing.T) {
+	t.Setenv("ARGOCD_ENV_QBEC_ENV", "prod")
+	t.Setenv("ARGOCD_ENV_QBEC_COMPONENTS", "service2")
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("argo-render", "dev", "-c", "cluster-objects")
+	require.NoError(t, err)
+	out := s.stdout()
+	a := assert.New(t)
+	a.Contains(out, "name: allow-root-psp-policy\n")
+	a.NotContains(out, "name: svc2-deploy")
+}
+
+func TestArgoRenderAppTag(t *testing.T) {
+	t.Setenv("ARGOCD_ENV_QBEC_APP_TAG", "bad tag")
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("argo-render", "dev")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid tag name 'bad tag'")
+}
+
+func TestArgoRenderNegative(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("argo-render")
+	require.Error(t, err)
+	a := assert.New(t)
+	a.True(cmd.IsUsageError(err))
+	a.Equal("environment must be specified as an argument or using ARGOCD_ENV_QBEC_ENV", err.Error())
+
+	err = s.executeCommand("argo-render", "dev", "prod")
+	require.Error(t, err)
+	a.True(cmd.IsUsageError(err))
+	a.Equal(`at most one environment may be specified, but provided: ["dev" "prod"]`, err.Error())
+}
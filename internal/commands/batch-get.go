@@ -0,0 +1,162 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"sync"
+
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/splunk/qbec/internal/sio"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// objectGetter returns the remote version of an object.
+type objectGetter interface {
+	Get(ctx context.Context, ob model.K8sMeta) (*unstructured.Unstructured, error)
+}
+
+// batchKey identifies a list query for objects of a specific type in a namespace.
+type batchKey struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+}
+
+// batch holds the results of a single list query, loaded at most once.
+type batch struct {
+	once    sync.Once
+	objects map[string]*unstructured.Unstructured
+	ok      bool // false if the list query failed and objects need to be fetched individually
+}
+
+// batchGetter returns the remote versions of objects using a single list query, filtered by the app, tag and
+// environment labels, for every type and namespace that has more than one object instead of getting every object.
+// Objects that are not found in the list results, such as those that are not yet labeled by qbec, are fetched
+// individually so that the results are always the same as calling the Get method of the client.
+type batchGetter struct {
+	client    cmd.KubeClient
+	defaultNS string
+	selector  string
+	limit     int64
+	counts    map[batchKey]int
+	l         sync.Mutex
+	batches   map[batchKey]*batch
+}
+
+// newBatchGetter returns a batch getter for the supplied objects. List queries are only executed for types and
+// namespaces that have multiple objects.
+func newBatchGetter(client cmd.KubeClient, scope remote.ListQueryConfig, defaultNS string, objects []model.K8sLocalObject) *batchGetter {
+	b := &batchGetter{
+		client:    client,
+		defaultNS: defaultNS,
+		selector:  scope.LabelSelector(),
+		limit:     scope.Limit,
+		counts:    map[batchKey]int{},
+		batches:   map[batchKey]*batch{},
+	}
+	for _, ob := range objects {
+		if key, ok := b.key(ob); ok {
+			b.counts[key]++
+		}
+	}
+	return b
+}
+
+// key returns the batch key for the supplied object and false if the object cannot be fetched using list queries.
+func (b *batchGetter) key(ob model.K8sMeta) (batchKey, bool) {
+	if ob.GetName() == "" || model.TrackedIdentity(ob) != "" {
+		return batchKey{}, false
+	}
+	namespaced, err := b.client.IsNamespaced(ob.GroupVersionKind())
+	if err != nil {
+		return batchKey{}, false
+	}
+	ns := ""
+	if namespaced {
+		ns = ob.GetNamespace()
+		if ns == "" {
+			ns = b.defaultNS
+		}
+	}
+	return batchKey{gvk: ob.GroupVersionKind(), namespace: ns}, true
+}
+
+// list loads all objects for the supplied key.
+func (b *batchGetter) list(ctx context.Context, key batchKey) (map[string]*unstructured.Unstructured, error) {
+	ri, err := b.client.ResourceInterface(key.gvk, key.namespace)
+	if err != nil {
+		return nil, err
+	}
+	ret := map[string]*unstructured.Unstructured{}
+	opts := &metav1.ListOptions{LabelSelector: b.selector, Limit: b.limit}
+	err = resource.FollowContinue(opts, func(options metav1.ListOptions) (runtime.Object, error) {
+		l, err := ri.List(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		for i := range l.Items {
+			ret[l.Items[i].GetName()] = &l.Items[i]
+		}
+		return l, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (b *batchGetter) batchFor(ctx context.Context, key batchKey) *batch {
+	b.l.Lock()
+	bt, ok := b.batches[key]
+	if !ok {
+		bt = &batch{}
+		b.batches[key] = bt
+	}
+	b.l.Unlock()
+	bt.once.Do(func() {
+		objects, err := b.list(ctx, key)
+		if err != nil {
+			sio.Debugf("list %s in namespace %q failed, fall back to get: %v\n", key.gvk, key.namespace, err)
+			return
+		}
+		bt.objects = objects
+		bt.ok = true
+	})
+	return bt
+}
+
+// Get returns the remote object for the supplied metadata using the results of the list query for its type and
+// namespace, falling back to getting the object when it isn't part of a batch or wasn't found in the list results.
+func (b *batchGetter) Get(ctx context.Context, ob model.K8sMeta) (*unstructured.Unstructured, error) {
+	key, ok := b.key(ob)
+	if !ok || b.counts[key] < 2 {
+		return b.client.Get(ctx, ob)
+	}
+	bt := b.batchFor(ctx, key)
+	if bt.ok {
+		if u, ok := bt.objects[ob.GetName()]; ok {
+			return u, nil
+		}
+	}
+	return b.client.Get(ctx, ob)
+}
@@ -0,0 +1,161 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func batchObject(kind, ns, name string, labels map[string]interface{}) map[string]interface{} {
+	meta := map[string]interface{}{"name": name}
+	if ns != "" {
+		meta["namespace"] = ns
+	}
+	if labels != nil {
+		meta["labels"] = labels
+	}
+	return map[string]interface{}{"apiVersion": "v1", "kind": kind, "metadata": meta}
+}
+
+type batchFixture struct {
+	client *client
+	l      sync.Mutex
+	gets   []string
+	lists  []string
+}
+
+func newBatchFixture(remoteObjects ...map[string]interface{}) *batchFixture {
+	var objs []runtime.Object
+	for _, o := range remoteObjects {
+		objs = append(objs, &unstructured.Unstructured{Object: o})
+	}
+	cmGVR := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	nsGVR := schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	dc := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		cmGVR: "ConfigMapList",
+		nsGVR: "NamespaceList",
+	}, objs...)
+	f := &batchFixture{}
+	dc.PrependReactor("list", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		f.l.Lock()
+		defer f.l.Unlock()
+		la := action.(k8stesting.ListAction)
+		f.lists = append(f.lists, fmt.Sprintf("%s:%s:%s", action.GetResource().Resource, action.GetNamespace(), la.GetListRestrictions().Labels))
+		return false, nil, nil
+	})
+	f.client = &client{
+		nsFunc: func(gvk schema.GroupVersionKind) (bool, error) {
+			return gvk.Kind != "Namespace", nil
+		},
+		riFunc: func(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+			switch gvk.Kind {
+			case "ConfigMap":
+				return dc.Resource(cmGVR).Namespace(namespace), nil
+			case "Namespace":
+				return dc.Resource(nsGVR), nil
+			default:
+				return nil, fmt.Errorf("unexpected kind %s", gvk.Kind)
+			}
+		},
+		getFunc: func(ctx context.Context, obj model.K8sMeta) (*unstructured.Unstructured, error) {
+			f.l.Lock()
+			f.gets = append(f.gets, obj.GetName())
+			f.l.Unlock()
+			for _, o := range remoteObjects {
+				u := &unstructured.Unstructured{Object: o}
+				if u.GetKind() == obj.GetKind() && u.GetName() == obj.GetName() {
+					return u, nil
+				}
+			}
+			return nil, remote.ErrNotFound
+		},
+	}
+	return f
+}
+
+func TestBatchGetter(t *testing.T) {
+	labels := map[string]interface{}{
+		model.QbecNames.ApplicationLabel: "app1",
+		model.QbecNames.EnvironmentLabel: "dev",
+	}
+	f := newBatchFixture(
+		batchObject("ConfigMap", "ns1", "cm1", labels),
+		batchObject("ConfigMap", "ns1", "cm2", labels),
+		batchObject("ConfigMap", "ns1", "unlabeled", nil),
+		batchObject("ConfigMap", "ns2", "cm3", labels),
+		batchObject("Namespace", "", "ns1", labels),
+		batchObject("Namespace", "", "ns2", labels),
+	)
+	local := func(kind, ns, name string) model.K8sLocalObject {
+		return model.NewK8sLocalObject(batchObject(kind, ns, name, nil), model.LocalAttrs{App: "app1", Env: "dev"})
+	}
+	objects := []model.K8sLocalObject{
+		local("ConfigMap", "", "cm1"),
+		local("ConfigMap", "ns1", "cm2"),
+		local("ConfigMap", "ns1", "unlabeled"),
+		local("ConfigMap", "ns1", "new"),
+		local("ConfigMap", "ns2", "cm3"),
+		local("Namespace", "", "ns1"),
+		local("Namespace", "", "ns2"),
+	}
+	b := newBatchGetter(f.client, remote.ListQueryConfig{Application: "app1", Environment: "dev"}, "ns1", objects)
+	found := map[string]bool{}
+	for _, ob := range objects {
+		u, err := b.Get(context.Background(), ob)
+		if err == remote.ErrNotFound {
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, ob.GetName(), u.GetName())
+		found[ob.GetName()] = true
+	}
+	a := assert.New(t)
+	a.Equal(map[string]bool{"cm1": true, "cm2": true, "unlabeled": true, "cm3": true, "ns1": true, "ns2": true}, found)
+	a.ElementsMatch([]string{"unlabeled", "new", "cm3"}, f.gets)
+	selector := "qbec.io/application=app1,qbec.io/environment=dev,!qbec.io/tag"
+	a.ElementsMatch([]string{"configmaps:ns1:" + selector, "namespaces::" + selector}, f.lists)
+}
+
+func TestBatchGetterListFail(t *testing.T) {
+	f := newBatchFixture(batchObject("ConfigMap", "ns1", "cm1", nil), batchObject("ConfigMap", "ns1", "cm2", nil))
+	f.client.riFunc = nil
+	objects := []model.K8sLocalObject{
+		model.NewK8sLocalObject(batchObject("ConfigMap", "ns1", "cm1", nil), model.LocalAttrs{App: "app1", Env: "dev"}),
+		model.NewK8sLocalObject(batchObject("ConfigMap", "ns1", "cm2", nil), model.LocalAttrs{App: "app1", Env: "dev"}),
+	}
+	b := newBatchGetter(f.client, remote.ListQueryConfig{Application: "app1", Environment: "dev"}, "ns1", objects)
+	for _, ob := range objects {
+		u, err := b.Get(context.Background(), ob)
+		require.NoError(t, err)
+		assert.Equal(t, ob.GetName(), u.GetName())
+	}
+	assert.ElementsMatch(t, []string{"cm1", "cm2"}, f.gets)
+}
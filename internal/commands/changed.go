@@ -0,0 +1,233 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+)
+
+// changeSet is the set of components affected by changed files.
+type changeSet struct {
+	Files        []string            `json:"files"`        // files changed under the app root
+	Components   []string            `json:"components"`   // components affected in any of the selected environments
+	Environments map[string][]string `json:"environments"` // affected components keyed by environment, for affected environments
+}
+
+// gitLines runs git with the supplied arguments in the current directory and returns the NUL-separated entries of
+// its output.
+func gitLines(args ...string) ([]string, error) {
+	var stdout, stderr bytes.Buffer
+	c := exec.Command("git", args...)
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		return nil, fmt.Errorf("git %s: %v %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	var ret []string
+	for _, s := range strings.Split(stdout.String(), "\x00") {
+		if s != "" {
+			ret = append(ret, s)
+		}
+	}
+	return ret, nil
+}
+
+// changedFiles returns the files under the current directory that differ from the supplied git reference, including
+// untracked files, as paths relative to the current directory.
+func changedFiles(from string) ([]string, error) {
+	diffs, err := gitLines("diff", "--name-only", "--no-renames", "--relative", "-z", from, "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := gitLines("ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var ret []string
+	for _, f := range append(diffs, untracked...) {
+		f = filepath.ToSlash(filepath.Clean(f))
+		if !seen[f] {
+			seen[f] = true
+			ret = append(ret, f)
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+type changedCommandConfig struct {
+	cmd.AppContext
+	from   string
+	envs   []string
+	format string
+}
+
+func doChanged(args []string, config changedCommandConfig) error {
+	if len(args) != 0 {
+		return cmd.NewUsageError(fmt.Sprintf("extra arguments specified: %q", args))
+	}
+	if config.from == "" {
+		return cmd.NewUsageError("git reference must be specified using --from")
+	}
+	switch config.format {
+	case "text", "json":
+	default:
+		return cmd.NewUsageError(fmt.Sprintf("invalid output format %q, must be one of text or json", config.format))
+	}
+	app := config.App()
+	envs := config.envs
+	if len(envs) == 0 {
+		for env := range app.Environments() {
+			envs = append(envs, env)
+		}
+	} else {
+		var err error
+		envs, err = config.ResolveEnvs(envs)
+		if err != nil {
+			return err
+		}
+	}
+	sort.Strings(envs)
+
+	files, err := changedFiles(config.from)
+	if err != nil {
+		return err
+	}
+	changed := map[string]bool{}
+	for _, f := range files {
+		changed[f] = true
+	}
+	anyChanged := func(list []string) bool {
+		for _, f := range list {
+			if changed[filepath.ToSlash(filepath.Clean(f))] {
+				return true
+			}
+		}
+		return false
+	}
+
+	// changes to the app configuration, including files imported by a jsonnet app file, and to files that are
+	// used for every component, like processors and parameter schemas, affect every component. Policy files
+	// affect every component of the environments they apply to. Otherwise a component is affected when any file
+	// in its import graph has changed.
+	all := false
+	affected := map[string]bool{}
+	policiesChanged := map[string]bool{}
+	if len(files) > 0 {
+		envCtx, err := config.EnvContext(model.Baseline)
+		if err != nil {
+			return err
+		}
+		ctx := envCtx.EvalContext(cleanEvalMode).BaseContext
+		graphChanged := func(files []string) (bool, error) {
+			g, err := newImportGraph(files, ctx)
+			if err != nil {
+				return false, cmd.WithCode(cmd.ErrorCodeEval, err)
+			}
+			return anyChanged(g.Files), nil
+		}
+		var global []string
+		global = append(global, app.ConfigFiles()...)
+		global = append(global, app.PreProcessors()...)
+		global = append(global, app.PostProcessors()...)
+		global = append(global, app.ParamsSchemaFiles()...)
+		if all, err = graphChanged(global); err != nil {
+			return err
+		}
+		for _, env := range envs {
+			var policyFiles []string
+			for _, p := range app.Policies(env) {
+				policyFiles = append(policyFiles, p.File)
+			}
+			if policiesChanged[env], err = graphChanged(policyFiles); err != nil {
+				return err
+			}
+		}
+		if !all {
+			for _, c := range app.AllComponents() {
+				if affected[c.Name], err = graphChanged(c.Files); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	cs := changeSet{Files: files, Components: []string{}, Environments: map[string][]string{}}
+	if cs.Files == nil {
+		cs.Files = []string{}
+	}
+	seen := map[string]bool{}
+	for _, env := range envs {
+		comps, err := app.ComponentsForEnvironment(env, nil, nil)
+		if err != nil {
+			return err
+		}
+		envAll := all || policiesChanged[env] || anyChanged(app.VarFiles(env))
+		var names []string
+		for _, c := range comps {
+			if envAll || affected[c.Name] {
+				names = append(names, c.Name)
+				if !seen[c.Name] {
+					seen[c.Name] = true
+					cs.Components = append(cs.Components, c.Name)
+				}
+			}
+		}
+		if len(names) > 0 {
+			cs.Environments[env] = names
+		}
+	}
+	sort.Strings(cs.Components)
+
+	if config.format == "json" {
+		encoder := json.NewEncoder(config.Stdout())
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(cs)
+	}
+	for _, c := range cs.Components {
+		fmt.Fprintln(config.Stdout(), c)
+	}
+	return nil
+}
+
+func newChangedCommand(cp ctxProvider) *cobra.Command {
+	c := &cobra.Command{
+		Use:     "changed --from <git-ref>",
+		Short:   "list components affected by files changed since a git reference",
+		Example: changedExamples(),
+	}
+	config := changedCommandConfig{}
+	c.Flags().StringVar(&config.from, "from", "", "git reference to compare the working tree against")
+	c.Flags().StringArrayVar(&config.envs, "env", nil, "limit results to this environment, can be specified multiple times, defaults to all environments")
+	c.Flags().StringVarP(&config.format, "format", "o", "text", "output format, one of text or json")
+	c.RunE = func(c *cobra.Command, args []string) error {
+		config.AppContext = cp()
+		return cmd.WrapError(doChanged(args, config))
+	}
+	return c
+}
@@ -0,0 +1,324 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gitProject copies the supplied test project to a temporary directory, commits it to a new git repository in
+// a subdirectory of the repository root, and returns the path to the project.
+func gitProject(t *testing.T, src string) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	repo := copyProject(t, src)
+	dir := filepath.Join(repo, "app")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	entries, err := ioutil.ReadDir(repo)
+	require.NoError(t, err)
+	for _, e := range entries {
+		if e.Name() != "app" {
+			require.NoError(t, os.Rename(filepath.Join(repo, e.Name()), filepath.Join(dir, e.Name())))
+		}
+	}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(repo, "other.txt"), []byte("other\n"), 0644))
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		c := exec.Command("git", args...)
+		c.Dir = repo
+		out, err := c.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	return dir
+}
+
+func TestChanged(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(t *testing.T, dir string)
+		args     []string
+		expected changeSet
+	}{
+		{
+			name:   "no-changes",
+			modify: func(t *testing.T, dir string) {},
+			expected: changeSet{
+				Files:        []string{},
+				Components:   []string{},
+				Environments: map[string][]string{},
+			},
+		},
+		{
+			name: "unrelated",
+			modify: func(t *testing.T, dir string) {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("changed\n"), 0644))
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "..", "other.txt"), []byte("changed\n"), 0644))
+			},
+			expected: changeSet{
+				Files:        []string{"README.md"},
+				Components:   []string{},
+				Environments: map[string][]string{},
+			},
+		},
+		{
+			name: "transitive-lib",
+			modify: func(t *testing.T, dir string) {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "lib", "web.libsonnet"), []byte("{ port: 80, labels: {} }\n"), 0644))
+			},
+			expected: changeSet{
+				Files:      []string{"lib/web.libsonnet"},
+				Components: []string{"web"},
+				Environments: map[string][]string{
+					"dev":  {"web"},
+					"prod": {"web"},
+				},
+			},
+		},
+		{
+			name: "common-lib",
+			modify: func(t *testing.T, dir string) {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "lib", "common.libsonnet"), []byte("{ labels: {} }\n"), 0644))
+			},
+			expected: changeSet{
+				Files:      []string{"lib/common.libsonnet"},
+				Components: []string{"db", "web"},
+				Environments: map[string][]string{
+					"dev":  {"db", "web"},
+					"prod": {"web"},
+				},
+			},
+		},
+		{
+			name: "new-component",
+			modify: func(t *testing.T, dir string) {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "components", "extra.yaml"), []byte("---\n"), 0644))
+			},
+			expected: changeSet{
+				Files:      []string{"components/extra.yaml"},
+				Components: []string{"extra"},
+				Environments: map[string][]string{
+					"dev":  {"extra"},
+					"prod": {"extra"},
+				},
+			},
+		},
+		{
+			name: "var-file",
+			modify: func(t *testing.T, dir string) {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "vars", "prod.env"), []byte("banner=hi\n"), 0644))
+			},
+			expected: changeSet{
+				Files:      []string{"vars/prod.env"},
+				Components: []string{"static", "web"},
+				Environments: map[string][]string{
+					"prod": {"static", "web"},
+				},
+			},
+		},
+		{
+			name: "app-config",
+			modify: func(t *testing.T, dir string) {
+				f, err := os.OpenFile(filepath.Join(dir, "qbec.yaml"), os.O_APPEND|os.O_WRONLY, 0644)
+				require.NoError(t, err)
+				defer f.Close()
+				_, err = f.WriteString("# comment\n")
+				require.NoError(t, err)
+			},
+			expected: changeSet{
+				Files:      []string{"qbec.yaml"},
+				Components: []string{"db", "static", "web"},
+				Environments: map[string][]string{
+					"dev":  {"db", "static", "web"},
+					"prod": {"static", "web"},
+				},
+			},
+		},
+		{
+			name: "pre-processor",
+			modify: func(t *testing.T, dir string) {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "processors", "pre.jsonnet"), []byte("{}\n"), 0644))
+			},
+			expected: changeSet{
+				Files:      []string{"processors/pre.jsonnet"},
+				Components: []string{"db", "static", "web"},
+				Environments: map[string][]string{
+					"dev":  {"db", "static", "web"},
+					"prod": {"static", "web"},
+				},
+			},
+		},
+		{
+			name: "post-processor-import",
+			modify: func(t *testing.T, dir string) {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "processors", "labels.libsonnet"), []byte("{}\n"), 0644))
+			},
+			expected: changeSet{
+				Files:      []string{"processors/labels.libsonnet"},
+				Components: []string{"db", "static", "web"},
+				Environments: map[string][]string{
+					"dev":  {"db", "static", "web"},
+					"prod": {"static", "web"},
+				},
+			},
+		},
+		{
+			name: "policy-import",
+			modify: func(t *testing.T, dir string) {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "policies", "rules.libsonnet"), []byte("{ check(object):: [] }\n"), 0644))
+			},
+			expected: changeSet{
+				Files:      []string{"policies/rules.libsonnet"},
+				Components: []string{"static", "web"},
+				Environments: map[string][]string{
+					"prod": {"static", "web"},
+				},
+			},
+		},
+		{
+			name: "params-schema",
+			modify: func(t *testing.T, dir string) {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "schemas", "web.json"), []byte("{}\n"), 0644))
+			},
+			expected: changeSet{
+				Files:      []string{"schemas/web.json"},
+				Components: []string{"db", "static", "web"},
+				Environments: map[string][]string{
+					"dev":  {"db", "static", "web"},
+					"prod": {"static", "web"},
+				},
+			},
+		},
+		{
+			name: "env-filter",
+			modify: func(t *testing.T, dir string) {
+				require.NoError(t, os.Remove(filepath.Join(dir, "components", "static.yaml")))
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "lib", "common.libsonnet"), []byte("{ labels: {} }\n"), 0644))
+			},
+			args: []string{"--env", "prod"},
+			expected: changeSet{
+				Files:      []string{"components/static.yaml", "lib/common.libsonnet"},
+				Components: []string{"web"},
+				Environments: map[string][]string{
+					"prod": {"web"},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := gitProject(t, "testdata/projects/changed")
+			test.modify(t, dir)
+			s := newCustomScaffold(t, dir)
+			defer s.reset()
+			err := s.executeCommand(append([]string{"changed", "--from", "HEAD", "-o", "json"}, test.args...)...)
+			require.NoError(t, err)
+			var cs changeSet
+			require.NoError(t, json.Unmarshal([]byte(s.stdout()), &cs))
+			assert.Equal(t, test.expected, cs)
+		})
+	}
+}
+
+func TestChangedJsonnetAppImport(t *testing.T) {
+	dir := gitProject(t, "testdata/projects/jsonnet-app")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "regions.libsonnet"), []byte("['us-east', 'eu-west', 'ap-south']\n"), 0644))
+	s := newCustomScaffold(t, dir)
+	defer s.reset()
+	err := s.executeCommand("changed", "--from", "HEAD", "--env", "dev-us-east", "-o", "json")
+	require.NoError(t, err)
+	var cs changeSet
+	require.NoError(t, json.Unmarshal([]byte(s.stdout()), &cs))
+	assert.Equal(t, changeSet{
+		Files:        []string{"regions.libsonnet"},
+		Components:   []string{"web"},
+		Environments: map[string][]string{"dev-us-east": {"web"}},
+	}, cs)
+}
+
+func TestChangedText(t *testing.T) {
+	dir := gitProject(t, "testdata/projects/changed")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "lib", "common.libsonnet"), []byte("{ labels: {} }\n"), 0644))
+	s := newCustomScaffold(t, dir)
+	defer s.reset()
+	err := s.executeCommand("changed", "--from", "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, "db\nweb\n", s.stdout())
+}
+
+func TestChangedNegative(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		code   string
+		errMsg string
+	}{
+		{
+			name:   "extra-args",
+			args:   []string{"changed", "--from", "HEAD", "foo"},
+			code:   cmd.ErrorCodeUsage,
+			errMsg: `extra arguments specified: ["foo"]`,
+		},
+		{
+			name:   "no-from",
+			args:   []string{"changed"},
+			code:   cmd.ErrorCodeUsage,
+			errMsg: "git reference must be specified using --from",
+		},
+		{
+			name:   "bad-format",
+			args:   []string{"changed", "--from", "HEAD", "-o", "yaml"},
+			code:   cmd.ErrorCodeUsage,
+			errMsg: `invalid output format "yaml", must be one of text or json`,
+		},
+		{
+			name:   "bad-env",
+			args:   []string{"changed", "--from", "HEAD", "--env", "stage"},
+			code:   cmd.ErrorCodeRuntime,
+			errMsg: `invalid environment "stage"`,
+		},
+		{
+			name:   "bad-ref",
+			args:   []string{"changed", "--from", "no-such-ref"},
+			code:   cmd.ErrorCodeRuntime,
+			errMsg: "git diff:",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := gitProject(t, "testdata/projects/changed")
+			s := newCustomScaffold(t, dir)
+			defer s.reset()
+			err := s.executeCommand(test.args...)
+			require.Error(t, err)
+			assert.Equal(t, test.code, cmd.ErrorCode(err))
+			assert.Contains(t, err.Error(), test.errMsg)
+		})
+	}
+}
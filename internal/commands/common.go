@@ -44,19 +44,30 @@ func setupCommands(root *cobra.Command, cp ctxProvider) {
 	root.AddCommand(newApplyCommand(cp))
 	root.AddCommand(newValidateCommand(cp))
 	root.AddCommand(newShowCommand(cp))
+	root.AddCommand(newArgoRenderCommand(cp))
 	root.AddCommand(newEvalCommand(cp))
+	root.AddCommand(newUnitCommand(cp))
 	root.AddCommand(newDiffCommand(cp))
 	root.AddCommand(newDeleteCommand(cp))
+	root.AddCommand(newGCPreviewCommand(cp))
+	root.AddCommand(newStatusCommand(cp))
+	root.AddCommand(newLogsCommand(cp))
 	root.AddCommand(newComponentCommand(cp))
+	root.AddCommand(newDataSourceCommand(cp))
 	root.AddCommand(newParamCommand(cp))
 	root.AddCommand(newEnvCommand(cp))
 	root.AddCommand(newInitCommand(cp))
+	root.AddCommand(newDepsCommand(cp))
+	root.AddCommand(newChangedCommand(cp))
 	root.AddCommand(newCompletionCommand(root))
 	root.AddCommand(newFmtCommand(cp))
 	alplhaCmd := newAlphaCommand()
 	alplhaCmd.AddCommand(newFmtCommand(cp))
 	alplhaCmd.AddCommand(newLintCommand(cp))
+	alplhaCmd.AddCommand(newAdmissionCommand(cp))
+	alplhaCmd.AddCommand(newImportsCommand(cp))
 	root.AddCommand(alplhaCmd)
+	addDynamicCompletions(root)
 }
 
 type worker func(ctx context.Context, object model.K8sLocalObject) error
@@ -124,18 +135,26 @@ func (lw *lockWriter) Write(buf []byte) (int, error) {
 	return n, err
 }
 
-func startRemoteList(ctx context.Context, envCtx cmd.EnvContext, client cmd.KubeClient, fp model.Filters) (_ lister, retainObjects []model.K8sLocalObject, _ error) {
+// remoteListOptions are options for listing remote objects.
+type remoteListOptions struct {
+	consumeCache bool    // remove cached list results once used, for commands that change the cluster
+	gc           gcScope // restricts the namespaces and kinds that are listed
+}
+
+// startRemoteList starts listing remote objects for the environment. Cached list results are used when a cache is
+// configured; commands that change the cluster must consume the cached results such that they are not reused.
+func startRemoteList(ctx context.Context, envCtx cmd.EnvContext, client cmd.KubeClient, fp model.Filters, opts remoteListOptions) (_ lister, retainObjects []model.K8sLocalObject, _ error) {
 	all, err := generateObjects(ctx, envCtx, emptyFilterOpts())
 	if err != nil {
 		return nil, nil, err
 	}
 	for _, o := range all {
-		if o.GetName() != "" {
+		if o.GetName() != "" || model.TrackedIdentity(o) != "" {
 			retainObjects = append(retainObjects, o)
 		}
 	}
 	var scope remote.ListQueryScope
-	lister, scope, err := newRemoteLister(client, all, envCtx.App().DefaultNamespace(envCtx.Env()))
+	lister, scope, err := newRemoteLister(ctx, client, all, envCtx.App().DefaultNamespace(envCtx.Env()))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -143,7 +162,7 @@ func startRemoteList(ctx context.Context, envCtx cmd.EnvContext, client cmd.Kube
 	if len(scope.Namespaces) > 1 && envCtx.App().ClusterScopedLists() {
 		clusterScopedLists = true
 	}
-	lister.start(ctx, remote.ListQueryConfig{
+	cfg := remote.ListQueryConfig{
 		Application:        envCtx.App().Name(),
 		Tag:                envCtx.App().Tag(),
 		Environment:        envCtx.Env(),
@@ -151,7 +170,14 @@ func startRemoteList(ctx context.Context, envCtx cmd.EnvContext, client cmd.Kube
 		ListQueryScope:     scope,
 		ClusterScopedLists: clusterScopedLists,
 		Limit:              envCtx.ListPageSize(),
-	})
+		Concurrency:        envCtx.ListConcurrency(),
+		Observer:           envCtx.ListObserver(),
+		Cache:              envCtx.ListCache(),
+		ConsumeCache:       opts.consumeCache,
+	}
+	opts.gc.restrict(&cfg)
+	lister.scope = opts.gc
+	lister.start(ctx, cfg)
 	return lister, retainObjects, nil
 }
 
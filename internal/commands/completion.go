@@ -15,121 +15,296 @@
 package commands
 
 import (
+	"fmt"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/filematcher"
+	"github.com/splunk/qbec/internal/model"
 )
 
 const (
 	completionLongDesc = `
-Output shell completion code for bash.
-The shell code must be evaluated to provide interactive
-completion of qbec commands.  This can be done by sourcing it from
-the .bash_profile.`
+Output shell completion code for bash, zsh, fish or powershell, defaulting to bash.
+The shell code must be evaluated to provide interactive completion of qbec commands.
+Completions for environment names, component names and kinds are computed on the fly
+from the qbec.yaml of the app in which the command line is being completed.`
 
 	completionExample = `
 # If running bash-completion, write the output code to your
 bash_completion.d/ directory,
-	qbec completion > /usr/local/etc/bash_completion.d/qbec
-
-# To load the completion into your current shell
-	source <(qbec completion)
-
-# Save the completion script to a file and source it in your ~/.bash_profile
-	qbec completion > ~/qbec.bash
-	printf "
-	# qbec bash completion
-	source '~/qbec.bash'
-	" >> ~/.bash_profile
-	source ~/.bash_profile
-`
-	// BashCompletionFunc contains all the custom bash functions that are
-	// used to generate dynamic completion lists to extend the completion
-	// capabilities
-	BashCompletionFunc = `
-__qbec_override_root() {
-    local root pair
-    for w in "${words[@]}"; do
-    	if [ -n "${pair}" ]; then
-	    root="--root=${w}"
-	fi
-        case "${w}" in
-            --root=*)
-		root="${w}"
-                ;;
-            --root)
-	    	pair=1
-                ;;
-        esac
-    done
-    if [ -n "${root}" ]; then
-    	echo -n "${root} "
-    fi
-}
+	qbec completion bash > /usr/local/etc/bash_completion.d/qbec
 
-__qbec_get_envs() {
-    if env_list=$(qbec $(__qbec_override_root) env list 2>/dev/null); then
-    	COMPREPLY+=( $( compgen -W "${env_list[*]}" -- "$cur" ))
-    fi
-}
+# To load the completion into your current bash shell
+	source <(qbec completion bash)
 
-__qbec_custom_func() {
-    case ${last_command} in
-        qbec_apply | qbec_component_diff | qbec_component_list | qbec_delete |\
-	qbec_diff | qbec_env_vars | qbec_param_diff | qbec_param_list |\
-	qbec_show | qbec_validate)
-	    __qbec_get_envs
-	    return
-	    ;;
-	qbec_completion | qbec_component | qbec_env_list | qbec_env | qbec_init |\
-	qbec_param | qbec_version)
-	    return
-	    ;;
-	*)
-	    ;;
-    esac
-}
+# To load the completion for every new zsh session
+	qbec completion zsh > "${fpath[1]}/_qbec"
 
-# remove ':' as a word break since it is used inside flags
-COMP_WORDBREAKS=${COMP_WORDBREAKS//:}
+# To load the completion for every new fish session
+	qbec completion fish > ~/.config/fish/completions/qbec.fish
+
+# To load the completion into your current powershell session
+	qbec completion powershell | Out-String | Invoke-Expression
 `
 )
 
-var (
-	// add any custom completion functions for flags here
-	customFlagCompletions = map[string]string{}
-)
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
 
 func newCompletionCommand(root *cobra.Command) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:                   "completion",
+		Use:                   "completion [bash|zsh|fish|powershell]",
 		DisableFlagsInUseLine: true,
-		Short:                 "Output shell completion for bash",
+		Short:                 "Output shell completion for bash, zsh, fish or powershell",
 		Long:                  completionLongDesc,
 		Example:               completionExample,
-		RunE: func(_ *cobra.Command, _ []string) error {
-			if len(customFlagCompletions) > 0 {
-				addCustomFlagCompletions(root)
-			}
-			return cmd.WrapError(root.GenBashCompletion(os.Stdout))
+		ValidArgs:             completionShells,
+		RunE: func(c *cobra.Command, args []string) error {
+			return cmd.WrapError(doCompletion(root, args))
 		},
 	}
 	return cmd
 }
 
-func addCustomFlagCompletions(c *cobra.Command) {
-	for name, completion := range customFlagCompletions {
-		if f := c.Flags().Lookup(name); f != nil {
-			if f.Annotations == nil {
-				f.Annotations = map[string][]string{}
+func doCompletion(root *cobra.Command, args []string) error {
+	if len(args) > 1 {
+		return cmd.NewUsageError("at most one shell may be specified")
+	}
+	shell := "bash"
+	if len(args) == 1 {
+		shell = args[0]
+	}
+	switch shell {
+	case "bash":
+		return root.GenBashCompletionV2(os.Stdout, true)
+	case "zsh":
+		return root.GenZshCompletion(os.Stdout)
+	case "fish":
+		return root.GenFishCompletion(os.Stdout, true)
+	case "powershell":
+		return root.GenPowerShellCompletionWithDesc(os.Stdout)
+	default:
+		return cmd.NewUsageError(fmt.Sprintf("unsupported shell %q, must be one of %s", shell, strings.Join(completionShells, ", ")))
+	}
+}
+
+// envArgs describes the positional arguments of a command that are environment names.
+type envArgs struct {
+	count    int  // number of leading arguments that are environments, -1 for all arguments
+	baseline bool // whether the baseline environment is allowed
+	files    bool // whether files may be specified in place of the environment
+}
+
+// envArgCompletions has the environment arguments of commands keyed by command path without the executable.
+var envArgCompletions = map[string]envArgs{
+	"alpha admission": {count: 1},
+	"apply":           {count: -1},
+	"argo-render":     {count: 1},
+	"component diff":  {count: 2, baseline: true},
+	"component graph": {count: -1},
+	"component list":  {count: 1, baseline: true},
+	"datasource test": {count: 1},
+	"delete":          {count: 1},
+	"diff":            {count: 1},
+	"env props":       {count: 1},
+	"env remove":      {count: 1},
+	"env set":         {count: 1},
+	"env vars":        {count: 1},
+	"eval":            {count: 1, files: true},
+	"gc-preview":      {count: 1},
+	"logs":            {count: 1},
+	"param diff":      {count: 2, baseline: true},
+	"param explain":   {count: 1, baseline: true},
+	"param list":      {count: 1, baseline: true},
+	"show":            {count: 1},
+	"status":          {count: 1},
+	"validate":        {count: 1},
+}
+
+// wellKnownKinds are the kinds of common built-in objects that are offered as completions for kind filters
+// in addition to the kinds referenced in qbec.yaml.
+var wellKnownKinds = []string{
+	"clusterrole", "clusterrolebinding", "configmap", "cronjob", "customresourcedefinition", "daemonset",
+	"deployment", "horizontalpodautoscaler", "ingress", "job", "limitrange", "mutatingwebhookconfiguration",
+	"namespace", "networkpolicy", "persistentvolume", "persistentvolumeclaim", "pod", "poddisruptionbudget",
+	"priorityclass", "resourcequota", "role", "rolebinding", "secret", "service", "serviceaccount",
+	"statefulset", "storageclass", "validatingwebhookconfiguration",
+}
+
+// completionApp loads the app in whose directory completion is performed, honoring the root, environment
+// file and variable options already present on the command line.
+func completionApp(c *cobra.Command) (*model.App, error) {
+	root, _ := c.Flags().GetString("root")
+	envFile, _ := c.Flags().GetString("env-file")
+	var envFiles []string
+	if envFile != "" {
+		files, err := filematcher.Match(envFile)
+		if err != nil {
+			return nil, err
+		}
+		envFiles = files
+	}
+	vars := map[string]string{}
+	sets, _ := c.Flags().GetStringArray("set")
+	for _, s := range sets {
+		if parts := strings.SplitN(s, "=", 2); len(parts) == 2 {
+			vars[parts[0]] = parts[1]
+		}
+	}
+	if err := setWorkDir(root); err != nil {
+		return nil, err
+	}
+	file, err := appFile()
+	if err != nil {
+		return nil, err
+	}
+	return model.NewAppWithVars(file, envFiles, "", vars)
+}
+
+// matching returns the sorted, de-duplicated candidates that start with the supplied prefix and have not been excluded.
+func matching(candidates []string, prefix string, exclude []string) []string {
+	seen := map[string]bool{}
+	for _, e := range exclude {
+		seen[e] = true
+	}
+	var ret []string
+	for _, c := range candidates {
+		if seen[c] || !strings.HasPrefix(c, prefix) {
+			continue
+		}
+		seen[c] = true
+		ret = append(ret, c)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func environmentNames(app *model.App, baseline bool) []string {
+	var ret []string
+	for name := range app.Environments() {
+		ret = append(ret, name)
+	}
+	if baseline {
+		ret = append(ret, model.Baseline)
+	}
+	return ret
+}
+
+func completeEnvArgs(spec envArgs) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(c *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		directive := cobra.ShellCompDirectiveNoFileComp
+		if spec.files {
+			directive = cobra.ShellCompDirectiveDefault
+		}
+		if spec.count >= 0 && len(args) >= spec.count {
+			return nil, directive
+		}
+		app, err := completionApp(c)
+		if err != nil {
+			cobra.CompDebugln(fmt.Sprintf("load app: %v", err), false)
+			return nil, directive
+		}
+		var exclude []string
+		if spec.count < 0 {
+			exclude = args
+		}
+		return matching(environmentNames(app, spec.baseline), toComplete, exclude), directive
+	}
+}
+
+func completeEnvFlag(c *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	app, err := completionApp(c)
+	if err != nil {
+		cobra.CompDebugln(fmt.Sprintf("load app: %v", err), false)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return matching(environmentNames(app, false), toComplete, nil), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeComponents completes component names, restricted to the components of the environment
+// when one has already been specified as the first argument.
+func completeComponents(c *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	app, err := completionApp(c)
+	if err != nil {
+		cobra.CompDebugln(fmt.Sprintf("load app: %v", err), false)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	components := app.AllComponents()
+	if len(args) > 0 {
+		if _, ok := app.Environments()[args[0]]; ok {
+			if envComponents, err := app.ComponentsForEnvironment(args[0], nil, nil); err == nil {
+				components = envComponents
 			}
-			f.Annotations[cobra.BashCompCustom] = append(f.Annotations[cobra.BashCompCustom], completion)
 		}
 	}
+	var names []string
+	for _, comp := range components {
+		names = append(names, comp.Name)
+	}
+	existing, _ := c.Flags().GetStringArray("component")
+	excluded, _ := c.Flags().GetStringArray("exclude-component")
+	return matching(names, toComplete, append(existing, excluded...)), cobra.ShellCompDirectiveNoFileComp
+}
 
-	// recursively go through every command
-	for _, cmd := range c.Commands() {
-		addCustomFlagCompletions(cmd)
+// completeKinds completes kind filters using well-known kinds and the kinds referenced in qbec.yaml.
+func completeKinds(c *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	kinds := append([]string{}, wellKnownKinds...)
+	if app, err := completionApp(c); err == nil {
+		for gk := range app.CanonicalVersions() {
+			kinds = append(kinds, gk.Kind)
+		}
+		for gk := range app.WaitStatusExpressions() {
+			kinds = append(kinds, gk.Kind)
+		}
+		for gk := range app.Redactions() {
+			kinds = append(kinds, gk.Kind)
+		}
+		envs := append(environmentNames(app, false), model.Baseline)
+		for _, env := range envs {
+			for _, t := range app.Transforms(env) {
+				kinds = append(kinds, t.Target.Kind)
+			}
+		}
+	} else {
+		cobra.CompDebugln(fmt.Sprintf("load app: %v", err), false)
+	}
+	var lower []string
+	for _, k := range kinds {
+		if k != "" {
+			lower = append(lower, strings.ToLower(k))
+		}
+	}
+	return matching(lower, strings.ToLower(toComplete), nil), cobra.ShellCompDirectiveNoFileComp
+}
+
+// addDynamicCompletions sets up completion functions for environment arguments and for the
+// environment, component and kind flags of the supplied command and all its sub-commands.
+func addDynamicCompletions(root *cobra.Command) {
+	var walk func(c *cobra.Command, path string)
+	walk = func(c *cobra.Command, path string) {
+		if spec, ok := envArgCompletions[path]; ok && c.ValidArgsFunction == nil {
+			c.ValidArgsFunction = completeEnvArgs(spec)
+		}
+		register := func(name string, fn func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective)) {
+			if c.LocalNonPersistentFlags().Lookup(name) != nil {
+				_ = c.RegisterFlagCompletionFunc(name, fn)
+			}
+		}
+		register("env", completeEnvFlag)
+		register("component", completeComponents)
+		register("exclude-component", completeComponents)
+		register("kind", completeKinds)
+		register("exclude-kind", completeKinds)
+		for _, sub := range c.Commands() {
+			p := sub.Name()
+			if path != "" {
+				p = path + " " + sub.Name()
+			}
+			walk(sub, p)
+		}
 	}
+	walk(root, "")
 }
@@ -0,0 +1,88 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func completions(t *testing.T, s *scaffold, args ...string) []string {
+	s.outCapture.Reset()
+	err := s.executeCommand(append([]string{"__complete"}, args...)...)
+	require.NoError(t, err)
+	var ret []string
+	for _, line := range strings.Split(strings.TrimSpace(s.stdout()), "\n") {
+		if strings.HasPrefix(line, ":") {
+			break
+		}
+		ret = append(ret, line)
+	}
+	return ret
+}
+
+func TestCompletionDynamic(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"env", []string{"show", ""}, []string{"dev", "local", "prod", "stage"}},
+		{"env prefix", []string{"diff", "p"}, []string{"prod"}},
+		{"env baseline", []string{"component", "diff", "dev", ""}, []string{"_", "dev", "local", "prod", "stage"}},
+		{"env no more args", []string{"show", "dev", ""}, nil},
+		{"env variadic", []string{"apply", "dev", "local", ""}, []string{"prod", "stage"}},
+		{"env flag", []string{"eval", "--env", "s"}, []string{"stage"}},
+		{"env add", []string{"env", "add", ""}, nil},
+		{"components", []string{"show", "-c", ""}, []string{"cluster-objects", "service1", "service2", "test-job"}},
+		{"env components", []string{"show", "dev", "-c", ""}, []string{"cluster-objects", "service2", "test-job"}},
+		{"components excluded", []string{"show", "dev", "-c", "service2", "-C", ""}, []string{"cluster-objects", "test-job"}},
+		{"kinds", []string{"show", "dev", "-K", "Se"}, []string{"secret", "service", "serviceaccount"}},
+		{"shells", []string{"completion", ""}, []string{"bash", "zsh", "fish", "powershell"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newScaffold(t)
+			defer s.reset()
+			assert.Equal(t, test.expected, completions(t, s, test.args...))
+		})
+	}
+}
+
+func TestCompletionNoApp(t *testing.T) {
+	s := newCustomScaffold(t, t.TempDir())
+	defer s.reset()
+	assert.Nil(t, completions(t, s, "show", ""))
+	assert.Contains(t, completions(t, s, "show", "dev", "-k", "dep"), "deployment")
+}
+
+func TestCompletionBadShell(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("completion", "tcsh")
+	require.Error(t, err)
+	a := assert.New(t)
+	a.True(cmd.IsUsageError(err))
+	a.Contains(err.Error(), `unsupported shell "tcsh"`)
+	err = s.executeCommand("completion", "bash", "zsh")
+	require.Error(t, err)
+	a.Contains(err.Error(), "at most one shell may be specified")
+}
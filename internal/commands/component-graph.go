@@ -0,0 +1,241 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+)
+
+// node kinds in a component graph.
+const (
+	graphEnvironment = "environment"
+	graphComponent   = "component"
+	graphTLA         = "tla"
+	graphDataSource  = "dataSource"
+	graphVariable    = "variable"
+)
+
+// edge relations in a component graph.
+const (
+	relDefault   = "default"    // component is enabled for the environment by default
+	relIncluded  = "included"   // component is excluded for the app but included by the environment
+	relExcluded  = "excluded"   // component is enabled for the app but excluded by the environment
+	relUsesTLA   = "uses-tla"   // component uses a top-level variable
+	relDependsOn = "depends-on" // component must be applied after another component
+	relConfigVar = "config-var" // data source is configured using a variable
+)
+
+type graphNode struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+type graphEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Relation string `json:"relation"`
+}
+
+type componentGraph struct {
+	App   string      `json:"app"`
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+func nodeID(kind, name string) string {
+	return kind + ":" + name
+}
+
+// dataSourceConfigVar returns the variable used to configure the supplied data source URL, if any.
+func dataSourceConfigVar(ds string) (name string, configVar string) {
+	u, err := url.Parse(ds)
+	if err != nil {
+		return ds, ""
+	}
+	name = u.Scheme + "://" + u.Host
+	q := u.Query()
+	for _, param := range []string{"configVar", "config-from"} {
+		if v := q.Get(param); v != "" {
+			return name, v
+		}
+	}
+	return name, ""
+}
+
+// newComponentGraph returns the graph of relationships between the supplied environments, the components of the
+// app, the top-level variables they use, and the data sources of the app.
+func newComponentGraph(app *model.App, envs []string) (*componentGraph, error) {
+	g := &componentGraph{App: app.Name()}
+	seen := map[string]bool{}
+	addNode := func(kind, name string) string {
+		id := nodeID(kind, name)
+		if !seen[id] {
+			seen[id] = true
+			g.Nodes = append(g.Nodes, graphNode{ID: id, Kind: kind, Name: name})
+		}
+		return id
+	}
+	addEdge := func(from, to, rel string) {
+		g.Edges = append(g.Edges, graphEdge{From: from, To: to, Relation: rel})
+	}
+
+	defaults, err := app.ComponentsForEnvironment(model.Baseline, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	isDefault := map[string]bool{}
+	for _, c := range defaults {
+		isDefault[c.Name] = true
+	}
+	all := app.AllComponents()
+	for _, env := range envs {
+		addNode(graphEnvironment, env)
+	}
+	// add all components upfront such that components that are not part of any environment are shown
+	for _, c := range all {
+		addNode(graphComponent, c.Name)
+	}
+	for _, env := range envs {
+		envID := nodeID(graphEnvironment, env)
+		comps, err := app.ComponentsForEnvironment(env, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		enabled := map[string]bool{}
+		for _, c := range comps {
+			enabled[c.Name] = true
+		}
+		for _, c := range all {
+			compID := nodeID(graphComponent, c.Name)
+			switch {
+			case enabled[c.Name] && isDefault[c.Name]:
+				addEdge(envID, compID, relDefault)
+			case enabled[c.Name]:
+				addEdge(envID, compID, relIncluded)
+			case isDefault[c.Name]:
+				addEdge(envID, compID, relExcluded)
+			}
+		}
+	}
+	for _, c := range all {
+		compID := nodeID(graphComponent, c.Name)
+		for _, v := range c.TopLevelVars {
+			addEdge(compID, addNode(graphTLA, v), relUsesTLA)
+		}
+		for _, d := range c.DependsOn {
+			addEdge(compID, nodeID(graphComponent, d), relDependsOn)
+		}
+	}
+	for _, ds := range app.DataSources() {
+		name, configVar := dataSourceConfigVar(ds)
+		dsID := addNode(graphDataSource, name)
+		if configVar != "" {
+			addEdge(dsID, addNode(graphVariable, configVar), relConfigVar)
+		}
+	}
+	return g, nil
+}
+
+var dotStyles = map[string]string{
+	graphEnvironment: `shape=box, style=filled, fillcolor=lightblue`,
+	graphComponent:   `shape=ellipse`,
+	graphTLA:         `shape=note`,
+	graphDataSource:  `shape=cylinder`,
+	graphVariable:    `shape=note, style=dashed`,
+}
+
+func (g *componentGraph) writeDOT(w io.Writer) {
+	fmt.Fprintf(w, "digraph %q {\n", g.App)
+	fmt.Fprintln(w, "  rankdir=LR;")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(w, "  %q [label=%q, %s];\n", n.ID, n.Name, dotStyles[n.Kind])
+	}
+	for _, e := range g.Edges {
+		switch e.Relation {
+		case relDefault:
+			fmt.Fprintf(w, "  %q -> %q;\n", e.From, e.To)
+		case relExcluded:
+			fmt.Fprintf(w, "  %q -> %q [label=%q, style=dashed, color=red];\n", e.From, e.To, e.Relation)
+		default:
+			fmt.Fprintf(w, "  %q -> %q [label=%q];\n", e.From, e.To, e.Relation)
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
+
+type componentGraphCommandConfig struct {
+	cmd.AppContext
+	format string
+}
+
+func doComponentGraph(args []string, config componentGraphCommandConfig) error {
+	envs, err := config.ResolveEnvs(args)
+	if err != nil {
+		return err
+	}
+	if len(envs) == 0 {
+		for env := range config.App().Environments() {
+			envs = append(envs, env)
+		}
+		sort.Strings(envs)
+	}
+	for _, env := range envs {
+		if env == model.Baseline {
+			return cmd.NewUsageError("cannot graph the baseline environment, use real environments")
+		}
+	}
+	if config.format != "dot" && config.format != "json" {
+		return cmd.NewUsageError(fmt.Sprintf("invalid output format %q, must be one of dot or json", config.format))
+	}
+	g, err := newComponentGraph(config.App(), envs)
+	if err != nil {
+		return err
+	}
+	if config.format == "json" {
+		encoder := json.NewEncoder(config.Stdout())
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(g)
+	}
+	g.writeDOT(config.Stdout())
+	return nil
+}
+
+func newComponentGraphCommand(cp ctxProvider) *cobra.Command {
+	c := &cobra.Command{
+		Use:     "graph [<environment>...]",
+		Short:   "print the relationships between environments, components, top-level variables and data sources as a graph",
+		Example: componentGraphExamples(),
+	}
+
+	config := componentGraphCommandConfig{}
+	c.Flags().StringVarP(&config.format, "format", "o", "dot", "output format, one of dot or json")
+
+	c.RunE = func(c *cobra.Command, args []string) error {
+		config.AppContext = cp()
+		return cmd.WrapError(doComponentGraph(args, config))
+	}
+	return c
+}
@@ -34,9 +34,9 @@ import (
 func newComponentCommand(cp ctxProvider) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "component <subcommand>",
-		Short: "component lists and diffs",
+		Short: "component lists, diffs and graphs",
 	}
-	cmd.AddCommand(newComponentListCommand(cp), newComponentDiffCommand(cp))
+	cmd.AddCommand(newComponentListCommand(cp), newComponentDiffCommand(cp), newComponentGraphCommand(cp))
 	return cmd
 }
 
@@ -76,7 +76,10 @@ func doComponentList(ctx context.Context, args []string, config componentListCom
 	if len(args) != 1 {
 		return cmd.NewUsageError(fmt.Sprintf("exactly one environment required, but provided: %q", args))
 	}
-	env := args[0]
+	env, err := config.ResolveEnv(args[0])
+	if err != nil {
+		return err
+	}
 	if config.objects {
 		envCtx, err := config.EnvContext(env)
 		if err != nil {
@@ -120,7 +123,10 @@ type componentDiffCommandConfig struct {
 
 func doComponentDiff(ctx context.Context, args []string, config componentDiffCommandConfig) error {
 	var leftEnv, rightEnv cmd.EnvContext
-	var err error
+	args, err := config.ResolveEnvs(args)
+	if err != nil {
+		return err
+	}
 	switch len(args) {
 	case 1:
 		leftEnv, err = config.EnvContext("_")
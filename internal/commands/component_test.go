@@ -202,3 +202,67 @@ func TestComponentNegative(t *testing.T) {
 		})
 	}
 }
+
+func TestComponentGraphDOT(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("component", "graph", "dev", "local")
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`^digraph "example1" \{$`))
+	s.assertOutputLineMatch(regexp.MustCompile(`"environment:dev" \[label="dev", shape=box`))
+	s.assertOutputLineMatch(regexp.MustCompile(`"environment:dev" -> "component:cluster-objects";`))
+	s.assertOutputLineMatch(regexp.MustCompile(`"environment:dev" -> "component:service1" \[label="excluded", style=dashed, color=red\];`))
+	s.assertOutputLineMatch(regexp.MustCompile(`"environment:dev" -> "component:service2" \[label="included"\];`))
+	s.assertOutputLineMatch(regexp.MustCompile(`"environment:local" -> "component:service1";`))
+	s.assertOutputLineMatch(regexp.MustCompile(`"component:service2" -> "tla:tlaFoo" \[label="uses-tla"\];`))
+	assert.NotContains(t, s.stdout(), "environment:prod")
+	assert.NotContains(t, s.stdout(), `"environment:local" -> "component:service2"`)
+}
+
+func TestComponentGraphJSON(t *testing.T) {
+	s := newCustomScaffold(t, "../../examples/external-data-app")
+	defer s.reset()
+	err := s.executeCommand("component", "graph", "-o", "json")
+	require.NoError(t, err)
+	var g componentGraph
+	err = s.jsonOutput(&g)
+	require.NoError(t, err)
+	a := assert.New(t)
+	a.Contains(g.Nodes, graphNode{ID: "dataSource:exec://config-map", Kind: graphDataSource, Name: "exec://config-map"})
+	a.Contains(g.Edges, graphEdge{From: "dataSource:exec://config-map", To: "variable:cmdConfig", Relation: relConfigVar})
+	a.Contains(g.Edges, graphEdge{From: "environment:local", To: "component:my-config-map", Relation: relDefault})
+}
+
+func TestComponentGraphNegative(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		msg  string
+	}{
+		{"baseline", []string{"component", "graph", "_"}, "cannot graph the baseline environment, use real environments"},
+		{"bad-env", []string{"component", "graph", "foo"}, `invalid environment "foo"`},
+		{"bad-format", []string{"component", "graph", "-o", "svg"}, `invalid output format "svg", must be one of dot or json`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newScaffold(t)
+			defer s.reset()
+			err := s.executeCommand(test.args...)
+			require.Error(t, err)
+			assert.Equal(t, test.msg, err.Error())
+		})
+	}
+}
+
+func TestDataSourceConfigVar(t *testing.T) {
+	a := assert.New(t)
+	name, v := dataSourceConfigVar("exec://foo?configVar=bar")
+	a.Equal("exec://foo", name)
+	a.Equal("bar", v)
+	name, v = dataSourceConfigVar("helm3://chart?config-from=baz")
+	a.Equal("helm3://chart", name)
+	a.Equal("baz", v)
+	name, v = dataSourceConfigVar("exec://foo")
+	a.Equal("exec://foo", name)
+	a.Equal("", v)
+}
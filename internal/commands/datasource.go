@@ -0,0 +1,171 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/sio"
+	"github.com/splunk/qbec/vm/datasource"
+)
+
+func newDataSourceCommand(cp ctxProvider) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "datasource <subcommand>",
+		Short: "data source health checks",
+	}
+	cmd.AddCommand(newDataSourceTestCommand(cp))
+	return cmd
+}
+
+// data source test statuses
+const (
+	dsStatusOK      = "ok"
+	dsStatusFailed  = "failed"
+	dsStatusSkipped = "skipped"
+)
+
+// dataSourceTestResult is the outcome of testing a single data source.
+type dataSourceTestResult struct {
+	name    string
+	status  string
+	latency time.Duration
+	detail  string
+}
+
+type dataSourceTestCommandConfig struct {
+	cmd.AppContext
+	path string
+}
+
+// testDataSource resolves the supplied path using the data source. Data sources are initialized lazily such that
+// the latency includes the time taken to initialize the data source.
+func testDataSource(src datasource.DataSource, path string) dataSourceTestResult {
+	ret := dataSourceTestResult{name: src.Name()}
+	if path == "" {
+		ret.status = dsStatusSkipped
+		ret.detail = "no test path configured"
+		return ret
+	}
+	start := time.Now()
+	out, err := src.Resolve(path)
+	ret.latency = time.Since(start)
+	if err != nil {
+		ret.status = dsStatusFailed
+		ret.detail = fmt.Sprintf("resolve %s: %v", path, err)
+		return ret
+	}
+	ret.status = dsStatusOK
+	ret.detail = fmt.Sprintf("resolved %s, %d bytes", path, len(out))
+	return ret
+}
+
+func printDataSourceResults(w io.Writer, results []dataSourceTestResult) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATUS\tLATENCY\tDETAIL")
+	for _, r := range results {
+		latency := "-"
+		if r.status != dsStatusSkipped {
+			latency = r.latency.Round(time.Millisecond).String()
+		}
+		// only the first line of multi-line errors is shown in the table
+		detail := strings.SplitN(strings.TrimSpace(r.detail), "\n", 2)[0]
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.name, r.status, latency, detail)
+	}
+	tw.Flush()
+}
+
+func doDataSourceTest(args []string, config dataSourceTestCommandConfig) error {
+	if len(args) < 1 {
+		return cmd.NewUsageError("no environment specified")
+	}
+	env, err := config.ResolveEnv(args[0])
+	if err != nil {
+		return err
+	}
+	if env == model.Baseline {
+		return cmd.NewUsageError("cannot test data sources for the baseline environment, use a real environment")
+	}
+	envCtx, err := config.EnvContext(env)
+	if err != nil {
+		return err
+	}
+	sources := map[string]datasource.DataSource{}
+	for _, src := range envCtx.DataSources() {
+		sources[src.Name()] = src
+	}
+	for _, name := range args[1:] {
+		if _, ok := sources[name]; !ok {
+			return cmd.NewUsageError(fmt.Sprintf("data source %s not declared for app", name))
+		}
+	}
+	selected := map[string]bool{}
+	for _, name := range args[1:] {
+		selected[name] = true
+	}
+	var results []dataSourceTestResult
+	for _, src := range envCtx.DataSources() {
+		if len(selected) > 0 && !selected[src.Name()] {
+			continue
+		}
+		path := config.path
+		if path == "" {
+			path = config.App().DataSourceTestPath(src.Name())
+		}
+		results = append(results, testDataSource(src, path))
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(config.Stdout(), "no data sources declared for app")
+		return nil
+	}
+	printDataSourceResults(config.Stdout(), results)
+	var failed int
+	for _, r := range results {
+		if r.status == dsStatusFailed {
+			failed++
+			sio.Errorf("data source %s: %s\n", r.name, r.detail)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d data source(s) failed", failed, len(results))
+	}
+	return nil
+}
+
+func newDataSourceTestCommand(cp ctxProvider) *cobra.Command {
+	c := &cobra.Command{
+		Use:     "test <environment> [<data-source-name>...]",
+		Short:   "initialize data sources and resolve a test path using each of them, reporting status and latency",
+		Example: dataSourceTestExamples(),
+	}
+
+	config := dataSourceTestCommandConfig{}
+	c.Flags().StringVar(&config.path, "path", "", "path to resolve using every data source tested, overrides the paths in dsTestPaths")
+
+	c.RunE = func(c *cobra.Command, args []string) error {
+		config.AppContext = cp()
+		return cmd.WrapError(doDataSourceTest(args, config))
+	}
+	return c
+}
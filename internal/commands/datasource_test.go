@@ -0,0 +1,79 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataSourceTest(t *testing.T) {
+	s := newCustomScaffold(t, "testdata/projects/datasources")
+	defer s.reset()
+	err := s.executeCommand("datasource", "test", "local")
+	require.Error(t, err)
+	assert.Equal(t, "2 of 3 data source(s) failed", err.Error())
+	s.assertOutputLineMatch(regexp.MustCompile(`^NAME\s+STATUS\s+LATENCY\s+DETAIL`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^good\s+ok\s+\S+s\s+resolved /foo, 17 bytes`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^bad\s+failed\s+\S+s\s+resolve /bar: .*exit status 1`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^broken\s+failed\s+\S+s\s+resolve /baz: .*missingConfig`))
+	s.assertErrorLineMatch(regexp.MustCompile(`data source bad: resolve /bar`))
+}
+
+func TestDataSourceTestSelected(t *testing.T) {
+	s := newCustomScaffold(t, "testdata/projects/datasources")
+	defer s.reset()
+	err := s.executeCommand("datasource", "test", "local", "good", "--path", "/bar/baz")
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`^good\s+ok\s+\S+s\s+resolved /bar/baz, 21 bytes`))
+}
+
+func TestDataSourceTestNoPath(t *testing.T) {
+	s := newCustomScaffold(t, "../../examples/external-data-app")
+	defer s.reset()
+	err := s.executeCommand("datasource", "test", "local")
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`^config-map\s+skipped\s+-\s+no test path configured`))
+}
+
+func TestDataSourceTestNegative(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		msg   string
+		usage bool
+	}{
+		{"no-env", []string{"datasource", "test"}, "no environment specified", true},
+		{"baseline", []string{"datasource", "test", "_"}, "cannot test data sources for the baseline environment, use a real environment", true},
+		{"bad-env", []string{"datasource", "test", "foo"}, `invalid environment "foo"`, false},
+		{"bad-name", []string{"datasource", "test", "local", "good", "foo"}, "data source foo not declared for app", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newCustomScaffold(t, "testdata/projects/datasources")
+			defer s.reset()
+			err := s.executeCommand(test.args...)
+			require.Error(t, err)
+			assert.Equal(t, test.msg, err.Error())
+			assert.Equal(t, test.usage, cmd.IsUsageError(err))
+		})
+	}
+}
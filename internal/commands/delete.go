@@ -19,6 +19,9 @@ package commands
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/splunk/qbec/internal/cmd"
@@ -28,18 +31,157 @@ import (
 	"github.com/splunk/qbec/internal/sio"
 )
 
+// deletedWithNamespace is an object that need not be deleted explicitly since its namespace is deleted.
+type deletedWithNamespace struct {
+	model.K8sQbecMeta
+	namespace string
+}
+
+// collapseNamespaceDeletions removes objects from the supplied list of deletions that belong to namespaces which
+// are themselves being deleted, since deleting the namespace deletes everything in it. Namespaces that contain
+// objects protected by the delete policy are kept, with a warning, since deleting them would delete the protected
+// objects as well. The other objects in such namespaces are deleted individually. It returns the objects that still
+// need to be deleted in their original order, and the objects that will be deleted along with their namespace.
+func collapseNamespaceDeletions(deletions []model.K8sQbecMeta, dp *deletePolicy) (retained []model.K8sQbecMeta, implied []deletedWithNamespace) {
+	namespaced := func(ob model.K8sQbecMeta) bool {
+		isNamespaced, err := dp.nsFunc(ob.GroupVersionKind())
+		return err == nil && isNamespaced
+	}
+	isNamespace := func(ob model.K8sQbecMeta) bool {
+		return ob.GroupVersionKind().Group == "" && ob.GetKind() == "Namespace"
+	}
+	namespaceOf := func(ob model.K8sQbecMeta) string {
+		if ns := ob.GetNamespace(); ns != "" {
+			return ns
+		}
+		return dp.defaultNS
+	}
+	// run the policy on all namespaced objects first so that namespaces of objects that are never deleted are retained
+	skip := map[model.K8sQbecMeta]bool{}
+	protected := map[string]bool{}
+	for _, ob := range deletions {
+		if namespaced(ob) && dp.disableDelete(ob) {
+			skip[ob] = true
+			protected[namespaceOf(ob)] = true
+		}
+	}
+	deletedNamespaces := map[string]bool{}
+	for _, ob := range deletions {
+		if isNamespace(ob) && !dp.disableDelete(ob) {
+			deletedNamespaces[ob.GetName()] = true
+		}
+	}
+	for _, ob := range deletions {
+		if isNamespace(ob) && protected[ob.GetName()] {
+			sio.Warnf("namespace %s not deleted since it contains objects with a delete policy of never\n", ob.GetName())
+			continue
+		}
+		if !skip[ob] && namespaced(ob) && deletedNamespaces[namespaceOf(ob)] {
+			implied = append(implied, deletedWithNamespace{K8sQbecMeta: ob, namespace: namespaceOf(ob)})
+			continue
+		}
+		retained = append(retained, ob)
+	}
+	return retained, implied
+}
+
+// deletePollInterval is the interval at which the server is checked for deleted objects that still exist.
+var deletePollInterval = 2 * time.Second
+
+// pendingDeletion is an object that has been deleted but may still exist on the server.
+type pendingDeletion struct {
+	obj  model.K8sMeta
+	name string
+	uid  string // the UID of the object when it was deleted, blank if it could not be determined
+	desc string // description of why the object still exists
+}
+
+// serverUID returns the UID of the supplied object as it exists on the server, or a blank string if it
+// does not exist.
+func serverUID(ctx context.Context, client cmd.KubeClient, ob model.K8sMeta) (string, error) {
+	u, err := client.Get(ctx, ob)
+	if err == remote.ErrNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("get %s: %v", client.DisplayName(ob), err)
+	}
+	return string(u.GetUID()), nil
+}
+
+// waitForDeletions waits until all the supplied objects have been removed from the server, returning an error
+// that lists the objects that still exist when the timeout expires. An object is considered removed when it
+// can no longer be found, or when an object with the same name but a different UID from the one captured at
+// deletion time has replaced it.
+func waitForDeletions(ctx context.Context, client cmd.KubeClient, pending []*pendingDeletion, timeout time.Duration) error {
+	if len(pending) == 0 {
+		return nil
+	}
+	sio.Noticef("waiting for %d object(s) to be deleted\n", len(pending))
+	end := time.Now().Add(timeout)
+	for {
+		var remaining []*pendingDeletion
+		for _, p := range pending {
+			u, err := client.Get(ctx, p.obj)
+			if err == remote.ErrNotFound {
+				sio.Debugf("%s deleted\n", p.name)
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("wait for deletion of %s: %v", p.name, err)
+			}
+			if p.uid != "" && p.uid != string(u.GetUID()) {
+				sio.Debugf("%s deleted and recreated\n", p.name)
+				continue
+			}
+			p.desc = "pending deletion"
+			if u.GetDeletionTimestamp() == nil {
+				p.desc = "deletion not started"
+			}
+			if fin := u.GetFinalizers(); len(fin) > 0 {
+				p.desc = fmt.Sprintf("%s, finalizers: %s", p.desc, strings.Join(fin, ", "))
+			}
+			remaining = append(remaining, p)
+		}
+		pending = remaining
+		if len(pending) == 0 {
+			return nil
+		}
+		if time.Now().After(end) {
+			var lines []string
+			for _, p := range pending {
+				lines = append(lines, fmt.Sprintf("%s (%s)", p.name, p.desc))
+			}
+			sort.Strings(lines)
+			return fmt.Errorf("%d object(s) not deleted after %s:\n\t%s", len(pending), timeout, strings.Join(lines, "\n\t"))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(deletePollInterval):
+		}
+	}
+}
+
 type deleteCommandConfig struct {
 	cmd.AppContext
-	dryRun     bool
-	useLocal   bool
-	filterFunc func() (model.Filters, error)
+	dryRun      bool
+	useLocal    bool
+	wait        bool
+	waitTimeout time.Duration
+	lock        lockOptions
+	retry       remote.RetryPolicy
+	filterFunc  func() (model.Filters, error)
 }
 
 func doDelete(ctx context.Context, args []string, config deleteCommandConfig) error {
 	if len(args) != 1 {
 		return cmd.NewUsageError(fmt.Sprintf("exactly one environment required, but provided: %q", args))
 	}
-	env := args[0]
+	env, err := config.ResolveEnv(args[0])
+	if err != nil {
+		return err
+	}
 	if env == model.Baseline { // cannot apply for the baseline environment
 		return cmd.NewUsageError("cannot delete baseline environment, use a real environment")
 	}
@@ -56,6 +198,13 @@ func doDelete(ctx context.Context, args []string, config deleteCommandConfig) er
 	if err != nil {
 		return err
 	}
+	if !config.dryRun {
+		unlock, err := lockEnvironment(ctx, config.AppContext, client, env, config.lock)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
 
 	var deletions []model.K8sQbecMeta
 	if config.useLocal {
@@ -69,7 +218,7 @@ func doDelete(ctx context.Context, args []string, config deleteCommandConfig) er
 			}
 		}
 	} else {
-		lister, _, err := startRemoteList(ctx, envCtx, client, fp)
+		lister, _, err := startRemoteList(ctx, envCtx, client, fp, remoteListOptions{consumeCache: !config.dryRun})
 		if err != nil {
 			return err
 		}
@@ -114,16 +263,40 @@ func doDelete(ctx context.Context, args []string, config deleteCommandConfig) er
 	delOpts := remote.DeleteOptions{
 		DryRun:          config.dryRun,
 		DisableDeleteFn: dp.disableDelete,
+		Retry:           config.retry,
 	}
+	deletions, implied := collapseNamespaceDeletions(deletions, dp)
+	wait := config.wait && !config.dryRun
+	var deleted []*pendingDeletion
 	for i := len(deletions) - 1; i >= 0; i-- {
 		ob := deletions[i]
 		name := client.DisplayName(ob)
+		// capture the UID of the object being deleted so that a replacement with the same name is not
+		// mistaken for it when waiting
+		var uid string
+		if wait {
+			uid, err = serverUID(ctx, client, ob)
+			if err != nil {
+				return err
+			}
+		}
 		res, err := client.Delete(ctx, ob, delOpts)
 		printDelStatus(name, res, err)
 		if err != nil {
 			return err
 		}
 		stats.update(name, res)
+		if res.Type == remote.SyncDeleted {
+			deleted = append(deleted, &pendingDeletion{obj: ob, name: name, uid: uid})
+		}
+	}
+	printImpliedDeletions(sio.For(ctx), client, implied, dryRun, &stats)
+
+	// objects deleted with their namespace are covered by waiting for the namespace
+	if wait {
+		if err := waitForDeletions(ctx, client, deleted, config.waitTimeout); err != nil {
+			return err
+		}
 	}
 
 	printStats(config.Stdout(), &stats)
@@ -133,6 +306,15 @@ func doDelete(ctx context.Context, args []string, config deleteCommandConfig) er
 	return nil
 }
 
+// printImpliedDeletions reports objects that were deleted along with their namespace.
+func printImpliedDeletions(log sio.Logger, client cmd.KubeClient, implied []deletedWithNamespace, dryRun string, stats *applyStats) {
+	for _, ob := range implied {
+		name := client.DisplayName(ob)
+		log.Noticef("%sdelete %s (with namespace %s)\n", dryRun, name, ob.namespace)
+		stats.update(name, &remote.SyncResult{Type: remote.SyncDeleted})
+	}
+}
+
 func newDeleteCommand(cp ctxProvider) *cobra.Command {
 	c := &cobra.Command{
 		Use:     "delete [-n] <environment>",
@@ -146,9 +328,22 @@ func newDeleteCommand(cp ctxProvider) *cobra.Command {
 
 	c.Flags().BoolVarP(&config.dryRun, "dry-run", "n", false, "dry-run, do not delete resources but show what would happen")
 	c.Flags().BoolVar(&config.useLocal, "local", false, "use local object names to delete, do not derive list from server")
+	c.Flags().BoolVar(&config.wait, "wait", false, "wait for deleted objects to be removed from the server")
+	c.Flags().DurationVar(&config.waitTimeout, "wait-timeout", 5*time.Minute, "wait timeout")
+	lockOpts := addLockFlags(c)
+	retryFn := addRetryFlags(c)
 
 	c.RunE = func(c *cobra.Command, args []string) error {
 		config.AppContext = cp()
+		config.lock = *lockOpts
+		var err error
+		config.retry, err = retryFn()
+		if err != nil {
+			return err
+		}
+		if config.waitTimeout <= 0 {
+			return cmd.NewUsageError(fmt.Sprintf("invalid wait timeout: %s, must be positive", config.waitTimeout))
+		}
 		return cmd.WrapError(doDelete(c.Context(), args, config))
 	}
 	return c
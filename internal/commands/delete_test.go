@@ -2,13 +2,21 @@ package commands
 
 import (
 	"context"
+	"fmt"
+	"regexp"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/splunk/qbec/internal/cmd"
 	"github.com/splunk/qbec/internal/model"
 	"github.com/splunk/qbec/internal/remote"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 /*
@@ -155,3 +163,166 @@ func TestDeleteNegative(t *testing.T) {
 	}
 
 }
+
+func TestCollapseNamespaceDeletions(t *testing.T) {
+	nsKind := schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}
+	cmKind := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	crKind := schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}
+	nsFunc := func(gvk schema.GroupVersionKind) (bool, error) {
+		return gvk.Kind != "Namespace" && gvk.Kind != "ClusterRole", nil
+	}
+	obj := func(gvk schema.GroupVersionKind, ns, name string, anns map[string]string) *basicObject {
+		return &basicObject{objectKey: objectKey{gvk: gvk, namespace: ns, name: name}, anns: anns}
+	}
+	never := map[string]string{model.QbecNames.Directives.DeletePolicy: policyNever}
+	deletions := []model.K8sQbecMeta{
+		obj(nsKind, "", "ns1", nil),
+		obj(nsKind, "", "ns2", nil),
+		obj(nsKind, "", "default", nil),
+		obj(crKind, "", "cr1", nil),
+		obj(cmKind, "ns1", "cm1", nil),
+		obj(cmKind, "", "cm2", nil),
+		obj(cmKind, "ns2", "cm3", nil),
+		obj(cmKind, "ns2", "cm4", never),
+		obj(cmKind, "default", "cm5", nil),
+		obj(cmKind, "ns3", "cm6", nil),
+	}
+	s := newScaffold(t)
+	defer s.reset()
+	retained, implied := collapseNamespaceDeletions(deletions, newDeletePolicy(nsFunc, "ns1"))
+	var retainedNames, impliedNames []string
+	for _, r := range retained {
+		retainedNames = append(retainedNames, r.GetName())
+	}
+	for _, i := range implied {
+		impliedNames = append(impliedNames, i.GetName()+"@"+i.namespace)
+	}
+	a := assert.New(t)
+	a.EqualValues([]string{"ns1", "default", "cr1", "cm3", "cm4", "cm5", "cm6"}, retainedNames)
+	a.EqualValues([]string{"cm1@ns1", "cm2@ns1"}, impliedNames)
+	a.Contains(s.stderr(), "namespace ns2 not deleted since it contains objects with a delete policy of never")
+}
+
+func TestDeleteWithNamespace(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	s.client.listFunc = func(ctx context.Context, _ remote.ListQueryConfig) (remote.Collection, error) {
+		c := &coll{}
+		c.add(
+			&basicObject{
+				objectKey: objectKey{gvk: schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, name: "bar-system"},
+				component: "service2",
+				app:       "app",
+				env:       "dev",
+			},
+			&basicObject{
+				objectKey: objectKey{gvk: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, namespace: "bar-system", name: "svc2-deploy"},
+				component: "service2",
+				app:       "app",
+				env:       "dev",
+			},
+		)
+		return c, nil
+	}
+	var deleted []string
+	s.client.deleteFunc = func(ctx context.Context, obj model.K8sMeta, opts remote.DeleteOptions) (*remote.SyncResult, error) {
+		deleted = append(deleted, obj.GetName())
+		return &remote.SyncResult{Type: remote.SyncDeleted}, nil
+	}
+	err := s.executeCommand("delete", "dev")
+	require.NoError(t, err)
+	stats := s.outputStats()
+	a := assert.New(t)
+	a.EqualValues([]string{"bar-system"}, deleted)
+	a.EqualValues([]interface{}{"Namespace::bar-system", "Deployment:bar-system:svc2-deploy"}, stats["deleted"])
+	a.Contains(s.stderr(), "delete Deployment:bar-system:svc2-deploy (with namespace bar-system)")
+}
+
+func TestDeleteWait(t *testing.T) {
+	defer func(d time.Duration) { deletePollInterval = d }(deletePollInterval)
+	deletePollInterval = time.Millisecond
+	newObject := func(obj model.K8sMeta, uid string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(obj.GroupVersionKind())
+		u.SetNamespace(obj.GetNamespace())
+		u.SetName(obj.GetName())
+		u.SetUID(types.UID(uid))
+		u.SetFinalizers([]string{"example.com/cleanup"})
+		now := metav1.Now()
+		u.SetDeletionTimestamp(&now)
+		return u
+	}
+	tests := []struct {
+		name     string
+		get      func(calls int, obj model.K8sMeta) (*unstructured.Unstructured, error)
+		asserter func(t *testing.T, s *scaffold, err error)
+	}{
+		{
+			name: "removed",
+			get: func(calls int, obj model.K8sMeta) (*unstructured.Unstructured, error) {
+				if calls < 3 {
+					return newObject(obj, "uid1"), nil
+				}
+				return nil, remote.ErrNotFound
+			},
+			asserter: func(t *testing.T, s *scaffold, err error) {
+				require.NoError(t, err)
+				s.assertErrorLineMatch(regexp.MustCompile(`waiting for 2 object\(s\) to be deleted`))
+			},
+		},
+		{
+			name: "recreated",
+			get: func(calls int, obj model.K8sMeta) (*unstructured.Unstructured, error) {
+				return newObject(obj, fmt.Sprintf("uid%d", calls)), nil
+			},
+			asserter: func(t *testing.T, s *scaffold, err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "replaced before first check",
+			get: func(calls int, obj model.K8sMeta) (*unstructured.Unstructured, error) {
+				if calls == 1 {
+					return newObject(obj, "uid1"), nil
+				}
+				return newObject(obj, "uid2"), nil
+			},
+			asserter: func(t *testing.T, s *scaffold, err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "stuck",
+			get: func(calls int, obj model.K8sMeta) (*unstructured.Unstructured, error) {
+				if obj.GetName() == "svc2-deploy" {
+					return nil, remote.ErrNotFound
+				}
+				return newObject(obj, "uid1"), nil
+			},
+			asserter: func(t *testing.T, s *scaffold, err error) {
+				require.Error(t, err)
+				assert.Equal(t, "1 object(s) not deleted after 50ms:\n\tDeployment:bar-system:svc2-previous-deploy (pending deletion, finalizers: example.com/cleanup)", err.Error())
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newScaffold(t)
+			defer s.reset()
+			s.client.listFunc = stdLister
+			s.client.deleteFunc = func(ctx context.Context, obj model.K8sMeta, opts remote.DeleteOptions) (*remote.SyncResult, error) {
+				return &remote.SyncResult{Type: remote.SyncDeleted}, nil
+			}
+			var l sync.Mutex
+			calls := map[string]int{}
+			s.client.getFunc = func(ctx context.Context, obj model.K8sMeta) (*unstructured.Unstructured, error) {
+				l.Lock()
+				defer l.Unlock()
+				calls[obj.GetName()]++
+				return test.get(calls[obj.GetName()], obj)
+			}
+			err := s.executeCommand("delete", "dev", "--wait", "--wait-timeout", "50ms")
+			test.asserter(t, s, err)
+		})
+	}
+}
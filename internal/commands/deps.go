@@ -0,0 +1,72 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/jb"
+)
+
+func newDepsCommand(cp ctxProvider) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "deps <subcommand>",
+		Short: "jsonnet-bundler dependency management",
+	}
+	cmd.AddCommand(newDepsEnsureCommand(cp))
+	return cmd
+}
+
+type depsEnsureCommandConfig struct {
+	cmd.AppContext
+}
+
+func doDepsEnsure(args []string, config depsEnsureCommandConfig) error {
+	if len(args) != 0 {
+		return cmd.NewUsageError(fmt.Sprintf("extra arguments specified: %q", args))
+	}
+	if !jb.HasManifest(config.RootDir()) {
+		return fmt.Errorf("no %s file found in %s", jb.ManifestFile, config.RootDir())
+	}
+	results, err := jb.Ensure(config.RootDir(), config.App().VendorDir())
+	if err != nil {
+		return err
+	}
+	w := config.Stdout()
+	fmt.Fprintf(w, "%-60s %s\n", "DEPENDENCY", "STATUS")
+	for _, r := range results {
+		fmt.Fprintf(w, "%-60s %s\n", r.Dependency.Path(), r.Status)
+	}
+	return nil
+}
+
+func newDepsEnsureCommand(cp ctxProvider) *cobra.Command {
+	c := &cobra.Command{
+		Use:     "ensure",
+		Short:   "vendor missing dependencies declared in jsonnetfile.json",
+		Example: depsEnsureExamples(),
+	}
+
+	config := depsEnsureCommandConfig{}
+	c.RunE = func(c *cobra.Command, args []string) error {
+		config.AppContext = cp()
+		return cmd.WrapError(doDepsEnsure(args, config))
+	}
+	return c
+}
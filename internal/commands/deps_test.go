@@ -0,0 +1,71 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDepsEnsure(t *testing.T) {
+	dir := copyProject(t, "testdata/projects/simple-service")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "shared-lib"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "shared-lib", "cm.libsonnet"),
+		[]byte(`{ apiVersion: 'v1', kind: 'ConfigMap', metadata: { name: 'shared' }, data: { foo: 'bar' } }`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "jsonnetfile.json"),
+		[]byte(`{ "version": 1, "dependencies": [ { "source": { "local": { "directory": "shared-lib" } }, "version": "" } ] }`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "components", "shared.jsonnet"),
+		[]byte(`import 'shared-lib/cm.libsonnet'`), 0644))
+
+	s := newCustomScaffold(t, dir)
+	err := s.executeCommand("deps", "ensure")
+	s.reset()
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`shared-lib\s+fetched`))
+	_, err = os.Stat(filepath.Join(dir, "vendor", "shared-lib", "cm.libsonnet"))
+	require.NoError(t, err)
+
+	s = newCustomScaffold(t, dir)
+	err = s.executeCommand("deps", "ensure")
+	s.reset()
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`shared-lib\s+present`))
+
+	s = newCustomScaffold(t, dir)
+	err = s.executeCommand("show", "local", "-c", "shared", "-O")
+	s.reset()
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`shared\s+ConfigMap\s+shared`))
+}
+
+func TestDepsEnsureNegative(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("deps", "ensure")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no jsonnetfile.json file found in")
+
+	err = s.executeCommand("deps", "ensure", "foo")
+	require.Error(t, err)
+	assert.Equal(t, `extra arguments specified: ["foo"]`, err.Error())
+}
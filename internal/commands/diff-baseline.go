@@ -0,0 +1,172 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/splunk/qbec/vm/vmutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type baselineEntry struct {
+	obj    *unstructured.Unstructured
+	source string
+}
+
+// diffBaseline is a set of objects that local objects are diffed against, used instead of fetching every
+// object from the server. Objects are keyed by the object key returned by the client.
+type diffBaseline struct {
+	label   string
+	entries map[string]baselineEntry
+	raw     map[string]*unstructured.Unstructured // the unprocessed objects, for deletions
+}
+
+// get returns a copy of the baseline version of the supplied object and its source. It returns a nil object
+// if the object is not part of the baseline.
+func (b *diffBaseline) get(client cmd.KubeClient, ob model.K8sMeta) (*unstructured.Unstructured, string) {
+	e, ok := b.entries[client.ObjectKey(ob)]
+	if !ok {
+		return nil, ""
+	}
+	return e.obj.DeepCopy(), e.source
+}
+
+// newAppliedBaseline returns a baseline that consists of the last applied configurations recorded in
+// the annotations of the supplied objects, as returned by a list query. Objects for which no configuration
+// was recorded are represented by their metadata.
+func newAppliedBaseline(client cmd.KubeClient, listed []model.K8sQbecMeta) *diffBaseline {
+	ret := &diffBaseline{label: "applied", entries: map[string]baselineEntry{}}
+	for _, o := range listed {
+		obj, source := remote.GetPristineVersionFromAnnotations(o.GetAnnotations())
+		if obj == nil {
+			obj = &unstructured.Unstructured{Object: map[string]interface{}{}}
+			obj.SetGroupVersionKind(o.GroupVersionKind())
+			obj.SetNamespace(o.GetNamespace())
+			obj.SetName(o.GetName())
+			obj.SetAnnotations(o.GetAnnotations())
+			source = "metadata only, no last applied configuration"
+		}
+		ret.entries[client.ObjectKey(o)] = baselineEntry{obj: obj, source: source}
+	}
+	return ret
+}
+
+// newSnapshotBaseline returns a baseline consisting of the objects in the supplied file.
+func newSnapshotBaseline(client cmd.KubeClient, file string) (*diffBaseline, error) {
+	objs, err := loadSnapshot(file)
+	if err != nil {
+		return nil, err
+	}
+	ret := &diffBaseline{label: "snapshot", entries: map[string]baselineEntry{}, raw: map[string]*unstructured.Unstructured{}}
+	for _, o := range objs {
+		key := client.ObjectKey(model.NewK8sObject(o.Object))
+		if _, ok := ret.entries[key]; ok {
+			return nil, fmt.Errorf("%s: duplicate object %s", file, key)
+		}
+		ret.entries[key] = baselineEntry{obj: o, source: file}
+		ret.raw[key] = o
+	}
+	return ret, nil
+}
+
+// snapshotObject exposes only the metadata of a snapshot object such that it is treated as a deletion.
+type snapshotObject struct {
+	model.K8sQbecMeta
+}
+
+// deletions returns the objects in a snapshot baseline that are not present in the supplied list of
+// objects and that are accepted by the filter.
+func (b *diffBaseline) deletions(client cmd.KubeClient, all []model.K8sLocalObject, filter listFilterFunc, defaultNs string) ([]model.K8sQbecMeta, error) {
+	retain := map[string]bool{}
+	for _, o := range all {
+		retain[client.ObjectKey(o)] = true
+	}
+	var ret []model.K8sQbecMeta
+	for key, u := range b.raw {
+		if retain[key] {
+			continue
+		}
+		labels := u.GetLabels()
+		ob := model.NewK8sLocalObject(u.Object, model.LocalAttrs{
+			App:       labels[model.QbecNames.ApplicationLabel],
+			Tag:       labels[model.QbecNames.TagLabel],
+			Env:       labels[model.QbecNames.EnvironmentLabel],
+			Component: u.GetAnnotations()[model.QbecNames.ComponentAnnotation],
+		})
+		ok, err := filter(ob, client, defaultNs)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ret = append(ret, &snapshotObject{ob})
+		}
+	}
+	return ret, nil
+}
+
+// loadSnapshot loads Kubernetes objects from the supplied file that contains either YAML documents or a JSON
+// array of objects, as produced by the show command.
+func loadSnapshot(file string) ([]*unstructured.Unstructured, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "open snapshot")
+	}
+	defer f.Close()
+	docs, err := vmutil.ParseYAMLDocuments(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse snapshot %s", file)
+	}
+	var ret []*unstructured.Unstructured
+	var add func(doc interface{}) error
+	add = func(doc interface{}) error {
+		switch d := doc.(type) {
+		case nil:
+			return nil
+		case []interface{}:
+			for _, item := range d {
+				if err := add(item); err != nil {
+					return err
+				}
+			}
+			return nil
+		case map[string]interface{}:
+			if err := model.AssertMetadataValid(d); err != nil {
+				return err
+			}
+			u := &unstructured.Unstructured{Object: d}
+			if u.GetName() == "" { // generated names cannot be matched
+				return nil
+			}
+			ret = append(ret, u)
+			return nil
+		default:
+			return fmt.Errorf("unexpected document of type %T", doc)
+		}
+	}
+	for _, doc := range docs {
+		if err := add(doc); err != nil {
+			return nil, errors.Wrapf(err, "snapshot %s", file)
+		}
+	}
+	return ret, nil
+}
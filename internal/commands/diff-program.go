@@ -0,0 +1,91 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// diffProgramEnv is the environment variable that sets the default external diff program.
+const diffProgramEnv = "QBEC_DIFF"
+
+// externalDiff runs an external program, like dyff or delta, to render the differences between two versions of
+// an object, similar to git's external diff support.
+type externalDiff struct {
+	command string
+	args    []string
+}
+
+// newExternalDiff returns an external diff for the supplied program, which is a command optionally followed by
+// arguments separated by spaces. It returns nil when the program is empty.
+func newExternalDiff(program string) *externalDiff {
+	parts := strings.Fields(program)
+	if len(parts) == 0 {
+		return nil
+	}
+	return &externalDiff{command: parts[0], args: parts[1:]}
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// run writes the supplied contents to temporary files named after the object in left and right directories and
+// runs the program with the left and right files as its last two arguments. It returns the combined output of the program. An exit code of 1
+// is not treated as an error since diff programs use it to indicate that the files are different.
+func (e *externalDiff) run(name, leftContent, rightContent string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "qbec-diff-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(subdir, content string) (string, error) {
+		file := filepath.Join(dir, subdir, unsafeFileChars.ReplaceAllString(name, "_")+".yaml")
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return "", err
+		}
+		return file, os.WriteFile(file, []byte(content), 0644)
+	}
+	leftFile, err := write("left", leftContent)
+	if err != nil {
+		return nil, err
+	}
+	rightFile, err := write("right", rightContent)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	c := exec.Command(e.command, append(e.args, leftFile, rightFile)...)
+	c.Stdout = &out
+	c.Stderr = &out
+	err = c.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("run diff program %s: %v\n%s", e.command, err, strings.TrimSpace(out.String()))
+	}
+	return out.Bytes(), nil
+}
@@ -20,15 +20,17 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"sort"
 	"sync"
 
-	"github.com/ghodss/yaml"
 	"github.com/spf13/cobra"
 	"github.com/splunk/qbec/internal/cmd"
 	"github.com/splunk/qbec/internal/diff"
+	"github.com/splunk/qbec/internal/fieldpath"
 	"github.com/splunk/qbec/internal/model"
 	"github.com/splunk/qbec/internal/objsort"
+	"github.com/splunk/qbec/internal/objyaml"
 	"github.com/splunk/qbec/internal/remote"
 	"github.com/splunk/qbec/internal/sio"
 	"github.com/splunk/qbec/internal/types"
@@ -40,9 +42,69 @@ type diffIgnores struct {
 	allLabels       bool
 	annotationNames []string
 	labelNames      []string
+	pathExprs       []string         // JSONPath expressions for fields ignored for all objects
+	paths           []fieldpath.Path // parsed path expressions
+	targeted        []targetedPaths  // fields ignored for specific objects, from the app configuration
+	scaled          *scaledObjects   // objects whose replica counts are managed by autoscalers
 }
 
-func (di diffIgnores) preprocess(obj *unstructured.Unstructured) {
+// targetedPaths are fields ignored for objects that match a target.
+type targetedPaths struct {
+	target model.TransformTarget
+	paths  []fieldpath.Path
+}
+
+// init parses the path expressions and adds the fields ignored by the app configuration for the supplied
+// environment.
+func (di *diffIgnores) init(app *model.App, env string) error {
+	for _, expr := range di.pathExprs {
+		p, err := fieldpath.Parse(expr)
+		if err != nil {
+			return cmd.NewUsageError(err.Error())
+		}
+		di.paths = append(di.paths, p)
+	}
+	for _, ignore := range app.DiffIgnores(env) {
+		tp := targetedPaths{target: ignore.Target}
+		for _, expr := range ignore.Paths {
+			p, err := fieldpath.Parse(expr)
+			if err != nil {
+				return err
+			}
+			tp.paths = append(tp.paths, p)
+		}
+		di.targeted = append(di.targeted, tp)
+	}
+	return nil
+}
+
+// hasPaths returns true if fields are ignored using path expressions.
+func (di diffIgnores) hasPaths() bool {
+	return len(di.paths) > 0 || len(di.targeted) > 0 || (di.scaled != nil && len(di.scaled.targets) > 0)
+}
+
+// preprocess removes ignored fields from the supplied object, which is a version of the supplied metadata object.
+func (di diffIgnores) preprocess(ob model.K8sMeta, obj *unstructured.Unstructured) {
+	for _, p := range di.paths {
+		p.Remove(obj.Object)
+	}
+	if di.scaled.isScaled(ob) {
+		unstructured.RemoveNestedField(obj.Object, "spec", "replicas")
+	}
+	if len(di.targeted) > 0 {
+		var component string
+		if qm, ok := ob.(model.K8sQbecMeta); ok {
+			component = qm.Component()
+		}
+		for _, tp := range di.targeted {
+			if !tp.target.Matches(component, ob) {
+				continue
+			}
+			for _, p := range tp.paths {
+				p.Remove(obj.Object)
+			}
+		}
+	}
 	if di.allLabels || len(di.labelNames) > 0 {
 		labels := obj.GetLabels()
 		if labels == nil {
@@ -86,6 +148,7 @@ type diffStats struct {
 	SameCount int        `json:"same,omitempty"`
 	Errors    []string   `json:"errors,omitempty"`
 	Skipped   *skipStats `json:"skipped,omitempty"`
+	Drifted   []string   `json:"drifted,omitempty"`
 }
 
 func (d *diffStats) added(s string) {
@@ -136,8 +199,15 @@ func (d *diffStats) errors(s string) {
 	d.Errors = append(d.Errors, s)
 }
 
+func (d *diffStats) drifted(s string) {
+	d.l.Lock()
+	defer d.l.Unlock()
+	d.Drifted = append(d.Drifted, s)
+}
+
 func (d *diffStats) done() {
 	sort.Strings(d.Additions)
+	sort.Strings(d.Drifted)
 	sort.Strings(d.Changes)
 	sort.Strings(d.Errors)
 	if d.Skipped != nil {
@@ -149,6 +219,7 @@ func (d *diffStats) done() {
 type differ struct {
 	w           io.Writer
 	client      cmd.KubeClient
+	getter      objectGetter // fetches live objects, the client itself when list queries are not used
 	opts        diff.Options
 	stats       diffStats
 	ignores     diffIgnores
@@ -156,11 +227,16 @@ type differ struct {
 	verbose     int
 	upPolicy    *updatePolicy
 	delPolicy   *deletePolicy
+	baseline    *diffBaseline // when set, objects are diffed against the baseline instead of the live versions
+	program     *externalDiff // when set, differences are rendered by an external program
 }
 
 func (d *differ) names(ob model.K8sMeta) (name, leftName, rightName string) {
 	name = d.client.DisplayName(ob)
 	leftName = "live " + name
+	if d.baseline != nil {
+		leftName = d.baseline.label + " " + name
+	}
 	rightName = "config " + name
 	return
 }
@@ -176,7 +252,7 @@ type namedUn struct {
 // Otherwise output will be interleaved across diffs.
 func (d *differ) writeDiff(name string, left, right namedUn) (finalErr error) {
 	asYaml := func(obj interface{}) (string, error) {
-		b, err := yaml.Marshal(obj)
+		b, err := objyaml.Marshal(obj, d.opts.FieldOrder)
 		if err != nil {
 			return "", err
 		}
@@ -196,26 +272,41 @@ func (d *differ) writeDiff(name string, left, right namedUn) (finalErr error) {
 	fileOpts := d.opts
 	fileOpts.LeftName = left.name
 	fileOpts.RightName = right.name
+	// render returns the differences between the supplied contents using the external program, when set,
+	// or the built-in renderer.
+	render := func(leftContent, rightContent string) ([]byte, error) {
+		if d.program != nil {
+			return d.program.run(name, leftContent, rightContent)
+		}
+		return diff.Strings(leftContent, rightContent, fileOpts)
+	}
 	switch {
 	case left.obj == nil && right.obj == nil:
 		return fmt.Errorf("internal error: both left and right objects were nil for diff")
 	case left.obj != nil && right.obj != nil:
-		b, err := diff.Objects(left.obj, right.obj, fileOpts)
+		leftContent, err := asYaml(left.obj)
 		if err != nil {
 			return err
 		}
-		if len(b) == 0 {
+		rightContent, err := asYaml(right.obj)
+		if err != nil {
+			return err
+		}
+		switch {
+		case leftContent == rightContent:
 			if d.verbose > 0 {
 				fmt.Fprintf(d.w, "%s unchanged\n", name)
 			}
 			d.stats.same(name)
-		} else {
-			if d.upPolicy.disableUpdate(left.obj) {
-				d.stats.skippedUpdated(name)
-			} else {
-				fmt.Fprintln(d.w, string(b))
-				d.stats.changed(name)
+		case d.upPolicy.disableUpdate(left.obj):
+			d.stats.skippedUpdated(name)
+		default:
+			b, err := render(leftContent, rightContent)
+			if err != nil {
+				return err
 			}
+			fmt.Fprintln(d.w, string(b))
+			d.stats.changed(name)
 		}
 	case left.obj == nil:
 		rightContent, err := asYaml(right.obj)
@@ -227,7 +318,7 @@ func (d *differ) writeDiff(name string, left, right namedUn) (finalErr error) {
 			leaderComment += " (generated name)"
 		}
 		rightContent = addLeader(rightContent, leaderComment)
-		b, err := diff.Strings("", rightContent, fileOpts)
+		b, err := render("", rightContent)
 		if err != nil {
 			return err
 		}
@@ -243,7 +334,7 @@ func (d *differ) writeDiff(name string, left, right namedUn) (finalErr error) {
 			return err
 		}
 		leftContent = addLeader(leftContent, "object doesn't exist locally")
-		b, err := diff.Strings(leftContent, "", fileOpts)
+		b, err := render(leftContent, "")
 		if err != nil {
 			return err
 		}
@@ -259,11 +350,16 @@ func (d *differ) writeDiff(name string, left, right namedUn) (finalErr error) {
 func (d *differ) diff(ctx context.Context, ob model.K8sMeta) error {
 	name, leftName, rightName := d.names(ob)
 
-	var remoteObject *unstructured.Unstructured
+	var remoteObject, left, right *unstructured.Unstructured
+	var source string
 	var err error
 
-	if ob.GetName() != "" {
-		remoteObject, err = d.client.Get(ctx, ob)
+	switch {
+	case ob.GetName() == "" && model.TrackedIdentity(ob) == "":
+	case d.baseline != nil:
+		left, source = d.baseline.get(d.client, ob)
+	default:
+		remoteObject, err = d.getter.Get(ctx, ob)
 		if err != nil && err != remote.ErrNotFound && err.Error() != "server type not found" { // *sigh*
 			d.stats.errors(name)
 			sio.Errorf("error fetching %s, %v\n", name, err)
@@ -278,14 +374,23 @@ func (d *differ) diff(ctx context.Context, ob model.K8sMeta) error {
 		if !d.showSecrets {
 			u, _ = types.HideSensitiveInfo(u)
 		}
-		d.ignores.preprocess(u)
+		// path expressions may remove any field, do not change objects that are used after the diff
+		if d.ignores.hasPaths() {
+			u = u.DeepCopy()
+		}
+		d.ignores.preprocess(ob, u)
 		return u
 	}
 
-	var left, right *unstructured.Unstructured
 	if remoteObject != nil {
-		var source string
+		if err := d.checkDrift(name, ob, remoteObject); err != nil {
+			d.stats.errors(name)
+			sio.Errorf("error checking drift for %s, %v\n", name, err)
+			return err
+		}
 		left, source = remote.GetPristineVersionForDiff(remoteObject)
+	}
+	if left != nil {
 		leftName += " (source: " + source + ")"
 	}
 	left = fixup(left)
@@ -301,15 +406,30 @@ func (d *differ) diffLocal(ctx context.Context, ob model.K8sLocalObject) error {
 	return d.diff(ctx, ob)
 }
 
+// values for the --error-on flag
+const (
+	errorOnAny   = "any"   // exit with an error when there are differences or drift
+	errorOnDrift = "drift" // exit with an error only when objects have drifted from their last applied configuration
+	errorOnNone  = "none"  // never exit with an error because of differences
+)
+
 type diffCommandConfig struct {
 	cmd.AppContext
-	showDeletions bool
-	showSecrets   bool
-	parallel      int
-	contextLines  int
-	di            diffIgnores
-	filterFunc    func() (model.Filters, error)
-	exitNonZero   bool
+	showDeletions   bool
+	showSecrets     bool
+	parallel        int
+	contextLines    int
+	di              diffIgnores
+	filterFunc      func() (model.Filters, error)
+	exitNonZero     bool
+	errorOn         string
+	offline         bool
+	snapshotFile    string
+	showForeign     bool
+	fieldOrder      string
+	keepHPAReplicas bool
+	diffProgram     string
+	noBatch         bool
 }
 
 func doDiff(ctx context.Context, args []string, config diffCommandConfig) error {
@@ -317,10 +437,37 @@ func doDiff(ctx context.Context, args []string, config diffCommandConfig) error
 		return cmd.NewUsageError(fmt.Sprintf("exactly one environment required, but provided: %q", args))
 	}
 
-	env := args[0]
+	env, err := config.ResolveEnv(args[0])
+	if err != nil {
+		return err
+	}
 	if env == model.Baseline {
 		return cmd.NewUsageError("cannot diff baseline environment, use a real environment")
 	}
+	errorOn := config.errorOn
+	switch {
+	case errorOn == "" && config.exitNonZero:
+		errorOn = errorOnAny
+	case errorOn == "":
+		errorOn = errorOnNone
+	case config.exitNonZero:
+		return cmd.NewUsageError("cannot specify both --error-exit and --error-on")
+	case errorOn != errorOnAny && errorOn != errorOnDrift && errorOn != errorOnNone:
+		return cmd.NewUsageError(fmt.Sprintf("invalid value for --error-on %q, must be one of %q, %q or %q", errorOn, errorOnAny, errorOnDrift, errorOnNone))
+	}
+	if config.offline && config.snapshotFile != "" {
+		return cmd.NewUsageError("cannot specify both --offline and --snapshot")
+	}
+	if config.showForeign && config.snapshotFile != "" {
+		return cmd.NewUsageError("cannot specify both --show-foreign and --snapshot")
+	}
+	fieldOrder, err := objyaml.ParseFieldOrder(config.fieldOrder)
+	if err != nil {
+		return cmd.NewUsageError(err.Error())
+	}
+	if err := config.di.init(config.App(), env); err != nil {
+		return err
+	}
 	fp, err := config.filterFunc()
 	if err != nil {
 		return err
@@ -336,18 +483,38 @@ func doDiff(ctx context.Context, args []string, config diffCommandConfig) error
 		return err
 	}
 
-	objects, err := generateObjects(ctx, envCtx, makeFilterOpts(fp, client))
+	objects, err := generateObjects(ctx, envCtx, makeFilterOpts(fp, client).withPolicies())
 	if err != nil {
 		return err
 	}
 
 	var lister lister = &stubLister{}
 	var retainObjects []model.K8sLocalObject
-	if config.showDeletions {
-		lister, retainObjects, err = startRemoteList(ctx, envCtx, client, fp)
+	var baseline *diffBaseline
+	switch {
+	case config.snapshotFile != "":
+		baseline, err = newSnapshotBaseline(client, config.snapshotFile)
 		if err != nil {
 			return err
 		}
+		if config.showDeletions {
+			retainObjects, err = generateObjects(ctx, envCtx, emptyFilterOpts())
+			if err != nil {
+				return err
+			}
+		}
+	case config.showDeletions || config.offline:
+		lister, retainObjects, err = startRemoteList(ctx, envCtx, client, fp, remoteListOptions{})
+		if err != nil {
+			return err
+		}
+		if config.offline {
+			listed, err := lister.objects()
+			if err != nil {
+				return err
+			}
+			baseline = newAppliedBaseline(client, listed)
+		}
 	}
 
 	objects = objsort.Sort(objects, sortConfig(client.IsNamespaced))
@@ -357,28 +524,51 @@ func doDiff(ctx context.Context, args []string, config diffCommandConfig) error
 	if config.contextLines == 0 {
 		config.contextLines = -1
 	}
-	opts := diff.Options{Context: config.contextLines, Colorize: config.Colorize()}
+	opts := diff.Options{Context: config.contextLines, Colorize: config.Colorize(), FieldOrder: fieldOrder}
+
+	ignores := config.di
+	if config.keepHPAReplicas {
+		ignores.scaled = newScaledObjects(objects, config.App().DefaultNamespace(env))
+	}
+	var getter objectGetter = client
+	if baseline == nil && !config.noBatch {
+		getter = newBatchGetter(client, remote.ListQueryConfig{
+			Application: config.App().Name(),
+			Tag:         config.App().Tag(),
+			Environment: env,
+			Limit:       envCtx.ListPageSize(),
+		}, config.App().DefaultNamespace(env), objects)
+	}
 
 	w := &lockWriter{Writer: config.Stdout()}
 	d := &differ{
 		w:           w,
 		client:      client,
+		getter:      getter,
 		opts:        opts,
-		ignores:     config.di,
+		ignores:     ignores,
 		showSecrets: config.showSecrets,
 		verbose:     config.Verbosity(),
 		upPolicy:    newUpdatePolicy(),
 		delPolicy:   newDeletePolicy(client.IsNamespaced, config.App().DefaultNamespace(env)),
+		baseline:    baseline,
+		program:     newExternalDiff(config.diffProgram),
 	}
 	dErr := runInParallel(ctx, objects, d.diffLocal, config.parallel)
 
 	var listErr error
-	if dErr == nil {
-		extra, err := lister.deletions(retainObjects, fp.Match)
+	if dErr == nil && config.showDeletions {
+		var extra []model.K8sQbecMeta
+		var err error
+		if config.snapshotFile != "" {
+			extra, err = baseline.deletions(client, retainObjects, fp.Match, config.App().DefaultNamespace(env))
+		} else {
+			extra, err = lister.deletions(retainObjects, fp.Match)
+		}
 		if err != nil {
 			listErr = err
 		} else {
-			for _, ob := range extra {
+			for _, ob := range withoutHookObjects(extra) {
 				if err := d.diff(ctx, ob); err != nil {
 					return err
 				}
@@ -386,24 +576,34 @@ func doDiff(ctx context.Context, args []string, config diffCommandConfig) error
 		}
 	}
 
+	if dErr == nil && listErr == nil && config.showForeign {
+		listErr = reportForeign(ctx, envCtx, client, objects)
+	}
+
 	d.stats.done()
 	printStats(d.w, &d.stats)
 	numDiffs := len(d.stats.Additions) + len(d.stats.Changes) + len(d.stats.Deletions)
+	numDrifted := len(d.stats.Drifted)
 
 	switch {
 	case dErr != nil:
 		return dErr
 	case listErr != nil:
 		return listErr
-	case numDiffs > 0:
-		if config.exitNonZero {
+	}
+	if numDrifted > 0 {
+		if errorOn == errorOnDrift || (errorOn == errorOnAny && numDiffs == 0) {
+			return fmt.Errorf("%d object(s) drifted", numDrifted)
+		}
+		sio.Noticef("%d object(s) drifted\n", numDrifted)
+	}
+	if numDiffs > 0 {
+		if errorOn == errorOnAny {
 			return fmt.Errorf("%d object(s) different", numDiffs)
 		}
 		sio.Noticef("%d object(s) different\n", numDiffs)
-		return nil
-	default:
-		return nil
 	}
+	return nil
 }
 
 func newDiffCommand(cp ctxProvider) *cobra.Command {
@@ -416,6 +616,7 @@ func newDiffCommand(cp ctxProvider) *cobra.Command {
 	config := diffCommandConfig{
 		filterFunc: addFilterParams(c, true),
 	}
+	addComponents := addComponentDirParams(c)
 
 	c.Flags().BoolVar(&config.showDeletions, "show-deletes", true, "include deletions in diff")
 	c.Flags().IntVar(&config.contextLines, "context", 3, "context lines for diff")
@@ -425,10 +626,22 @@ func newDiffCommand(cp ctxProvider) *cobra.Command {
 	c.Flags().StringArrayVar(&config.di.annotationNames, "ignore-annotation", nil, "remove specific annotation from objects before diff")
 	c.Flags().BoolVar(&config.di.allLabels, "ignore-all-labels", false, "remove all labels from objects before diff")
 	c.Flags().StringArrayVar(&config.di.labelNames, "ignore-label", nil, "remove specific label from objects before diff")
-	c.Flags().BoolVar(&config.exitNonZero, "error-exit", false, "exit with non-zero status code when diffs present")
+	c.Flags().BoolVar(&config.keepHPAReplicas, "keep-hpa-replicas", true, "ignore the replica counts of objects scaled by horizontal pod autoscalers produced by the app")
+	c.Flags().StringArrayVar(&config.di.pathExprs, "ignore-path", nil, "remove fields matching the supplied JSONPath expression, for example .spec.replicas, from objects before diff")
+	c.Flags().BoolVar(&config.exitNonZero, "error-exit", false, "exit with non-zero status code when diffs present, same as --error-on=any")
+	c.Flags().StringVar(&config.errorOn, "error-on", "", "exit with non-zero status code when the cluster has drifted from the last applied configuration (drift), when there are any differences or drift (any), or never (none)")
+	c.Flags().BoolVar(&config.offline, "offline", false, "diff against the last applied configuration of objects fetched using list queries instead of getting every object")
+	c.Flags().BoolVar(&config.showForeign, "show-foreign", false, "list objects in the target namespaces that are managed by other apps, tags or environments")
+	c.Flags().StringVar(&config.fieldOrder, "field-order", string(objyaml.Alphabetical), "order of object fields in the diff, one of alpha or kubectl (apiVersion, kind and metadata first)")
+	c.Flags().StringVar(&config.diffProgram, "diff-program", os.Getenv(diffProgramEnv), "external program, with optional arguments, run with the left and right files of every changed object instead of the built-in diff (from QBEC_DIFF)")
+	c.Flags().BoolVar(&config.noBatch, "no-batch", false, "get every object individually instead of using list queries for types with multiple objects in a namespace")
+	c.Flags().StringVar(&config.snapshotFile, "snapshot", "", "diff against objects in the supplied file, typically the output of a previous show command, instead of the cluster")
 
 	c.RunE = func(c *cobra.Command, args []string) error {
 		config.AppContext = cp()
+		if err := addComponents(config.App()); err != nil {
+			return cmd.WrapError(err)
+		}
 		return cmd.WrapError(doDiff(c.Context(), args, config))
 	}
 	return c
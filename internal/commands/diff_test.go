@@ -17,14 +17,26 @@
 package commands
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 )
 
 func TestDiffBasicNoDiffs(t *testing.T) {
@@ -36,6 +48,20 @@ func TestDiffBasicNoDiffs(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestDiffQuiet(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	d := &dg{cmValue: "baz", secretValue: "baz"}
+	s.client.getFunc = d.get
+	s.client.listFunc = stdLister
+	err := s.executeCommand("diff", "dev", "--error-exit=false", "--quiet")
+	require.NoError(t, err)
+	stats := s.outputStats()
+	a := assert.New(t)
+	a.EqualValues([]interface{}{"ConfigMap:bar-system:svc2-cm", "Secret:bar-system:svc2-secret"}, stats["changes"])
+	a.Equal("", s.stderr())
+}
+
 func testDiffBasic(t *testing.T, errorExit bool) {
 	s := newScaffold(t)
 	defer s.reset()
@@ -73,6 +99,73 @@ func TestDiffBasicNoErrorExit(t *testing.T) {
 	testDiffBasic(t, false)
 }
 
+func TestDiffProgram(t *testing.T) {
+	program, err := filepath.Abs("testdata/diff-program/fake-diff.sh")
+	require.NoError(t, err)
+	s := newScaffold(t)
+	defer s.reset()
+	d := &dg{cmValue: "baz", secretValue: "baz"}
+	s.client.getFunc = d.get
+	s.client.listFunc = stdLister
+	err = s.executeCommand("diff", "dev", "--diff-program", program+" --color=never")
+	require.NoError(t, err)
+	stats := s.outputStats()
+	a := assert.New(t)
+	a.EqualValues([]interface{}{"ConfigMap:bar-system:svc2-cm", "Secret:bar-system:svc2-secret"}, stats["changes"])
+	a.EqualValues([]interface{}{"Deployment:bar-system:svc2-previous-deploy"}, stats["deletions"])
+	s.assertOutputLineMatch(regexp.MustCompile(`^fake-diff --color=never left/ConfigMap_bar-system_svc2-cm\.yaml right/ConfigMap_bar-system_svc2-cm\.yaml$`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^  foo: bar$`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^fake-diff --color=never left/Deployment_bar-system_svc2-previous-deploy\.yaml right/Deployment_bar-system_svc2-previous-deploy\.yaml$`))
+	s.assertOutputLineNoMatch(regexp.MustCompile(`^@@`))
+	a.NotContains(s.stdout(), base64.StdEncoding.EncodeToString([]byte("baz")))
+}
+
+func TestDiffProgramFieldOrder(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	d := &dg{cmValue: "baz", secretValue: "baz"}
+	s.client.getFunc = d.get
+	err := s.executeCommand("diff", "dev", "-k", "configmaps", "--show-deletes=false", "--diff-program", "cat", "--field-order", "kubectl")
+	require.NoError(t, err)
+	out := s.stdout()
+	kind, data := strings.Index(out, "kind: ConfigMap"), strings.Index(out, "data:")
+	require.True(t, kind >= 0 && data >= 0)
+	assert.Less(t, kind, data)
+	assert.NotContains(t, out, "@@")
+}
+
+func TestDiffProgramEnv(t *testing.T) {
+	program, err := filepath.Abs("testdata/diff-program/bad-diff.sh")
+	require.NoError(t, err)
+	t.Setenv("QBEC_DIFF", program)
+	s := newScaffold(t)
+	defer s.reset()
+	d := &dg{cmValue: "baz", secretValue: "baz"}
+	s.client.getFunc = d.get
+	err = s.executeCommand("diff", "dev", "-k", "configmaps", "--show-deletes=false")
+	require.Error(t, err)
+	a := assert.New(t)
+	a.Contains(s.stderr(), "run diff program "+program+": exit status 2")
+	a.Contains(s.stderr(), "cannot render diff")
+	a.EqualValues([]interface{}{"ConfigMap:bar-system:svc2-cm"}, s.outputStats()["errors"])
+}
+
+func TestDiffNoBatch(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	d := &dg{cmValue: "baz", secretValue: "baz"}
+	s.client.getFunc = d.get
+	s.client.listFunc = stdLister
+	s.client.riFunc = func(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+		t.Errorf("unexpected list query for %s in %q", gvk, namespace)
+		return nil, fmt.Errorf("not implemented")
+	}
+	err := s.executeCommand("diff", "dev", "--no-batch", "--error-exit=false")
+	require.NoError(t, err)
+	stats := s.outputStats()
+	assert.EqualValues(t, []interface{}{"ConfigMap:bar-system:svc2-cm", "Secret:bar-system:svc2-secret"}, stats["changes"])
+}
+
 func TestDiffGetFail(t *testing.T) {
 	s := newScaffold(t)
 	defer s.reset()
@@ -93,6 +186,303 @@ func TestDiffListFail(t *testing.T) {
 	a.Contains(err.Error(), "not implemented")
 }
 
+func foreignLister() func(ctx context.Context, scope remote.ListQueryConfig) (remote.Collection, error) {
+	return func(ctx context.Context, scope remote.ListQueryConfig) (remote.Collection, error) {
+		if !scope.Foreign {
+			return stdLister(ctx, scope)
+		}
+		c := &coll{}
+		c.add(
+			&basicObject{
+				objectKey: objectKey{
+					gvk:       schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+					namespace: "bar-system",
+					name:      "svc2-cm",
+				},
+				component: "cm",
+				app:       "other-app",
+				env:       "dev",
+			},
+			&basicObject{
+				objectKey: objectKey{
+					gvk:       schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+					namespace: "bar-system",
+					name:      "other-deploy",
+				},
+				component: "deploy",
+				app:       "app",
+				tag:       "t1",
+				env:       "dev",
+			},
+		)
+		return c, nil
+	}
+}
+
+func TestDiffShowForeign(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	d := &dg{cmValue: "bar", secretValue: "baz"}
+	s.client.getFunc = d.get
+	s.client.listFunc = foreignLister()
+	err := s.executeCommand("diff", "dev", "--show-foreign", "--show-deletes=false")
+	require.NoError(t, err)
+	s.assertErrorLineMatch(regexp.MustCompile(`2 foreign object\(s\) managed by other apps, tags or environments, not modified:`))
+	s.assertErrorLineMatch(regexp.MustCompile(`conflict: ConfigMap:bar-system:svc2-cm \(app=other-app, environment=dev\)`))
+	s.assertErrorLineMatch(regexp.MustCompile(`Deployment:bar-system:other-deploy \(app=app, tag=t1, environment=dev\)`))
+}
+
+func TestDiffShowForeignNone(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	d := &dg{cmValue: "bar", secretValue: "baz"}
+	s.client.getFunc = d.get
+	s.client.listFunc = func(ctx context.Context, scope remote.ListQueryConfig) (remote.Collection, error) {
+		return &coll{}, nil
+	}
+	err := s.executeCommand("diff", "dev", "--show-foreign")
+	require.NoError(t, err)
+	s.assertErrorLineMatch(regexp.MustCompile(`no foreign objects found`))
+}
+
+func TestDiffShowForeignSnapshot(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("diff", "dev", "--show-foreign", "--snapshot", "foo.yaml")
+	require.Error(t, err)
+	assert.True(t, cmd.IsUsageError(err))
+	assert.Equal(t, "cannot specify both --show-foreign and --snapshot", err.Error())
+}
+
+func TestDiffKubectlFieldOrder(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	d := &dg{cmValue: "baz", secretValue: "baz"}
+	s.client.getFunc = d.get
+	err := s.executeCommand("diff", "dev", "-c", "service2", "-k", "configmap", "--field-order", "kubectl", "--context", "100", "--show-deletes=false")
+	require.NoError(t, err)
+	a := assert.New(t)
+	a.Contains(s.stdout(), "\n apiVersion: v1\n kind: ConfigMap\n metadata:\n   name: svc2-cm\n   namespace: bar-system\n+  labels:\n")
+	a.True(strings.Index(s.stdout(), "   annotations:") < strings.Index(s.stdout(), "\n data:"))
+}
+
+func TestDiffIgnorePaths(t *testing.T) {
+	// the live objects have fields changed by controllers
+	get := func(ctx context.Context, obj model.K8sMeta) (*unstructured.Unstructured, error) {
+		live := obj.(model.K8sLocalObject).ToUnstructured().DeepCopy()
+		if live.GetKind() == "Deployment" {
+			require.NoError(t, unstructured.SetNestedField(live.Object, int64(5), "spec", "replicas"))
+			require.NoError(t, unstructured.SetNestedField(live.Object, "2026-10-18T00:00:00Z",
+				"spec", "template", "metadata", "annotations", "kubectl.kubernetes.io/restartedAt"))
+		}
+		return live, nil
+	}
+	tests := []struct {
+		name    string
+		args    []string
+		changes interface{}
+	}{
+		{
+			name:    "app config",
+			args:    []string{"diff", "local"},
+			changes: []interface{}{"Deployment::web"},
+		},
+		{
+			name: "app config for env",
+			args: []string{"diff", "prod"},
+		},
+		{
+			name: "flags",
+			args: []string{"diff", "local", "--ignore-path", `.spec.template.metadata.annotations["kubectl.kubernetes.io/restartedAt"]`},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newCustomScaffold(t, "testdata/projects/diff-ignores")
+			defer s.reset()
+			s.client.getFunc = get
+			err := s.executeCommand(append(test.args, "--show-deletes=false")...)
+			require.NoError(t, err)
+			stats := s.outputStats()
+			assert.EqualValues(t, test.changes, stats["changes"])
+			assert.NotContains(t, s.stdout(), "replicas: 5")
+		})
+	}
+}
+
+func TestDiffRedactions(t *testing.T) {
+	s := newCustomScaffold(t, "testdata/projects/redactions")
+	defer s.reset()
+	s.client.getFunc = func(ctx context.Context, obj model.K8sMeta) (*unstructured.Unstructured, error) {
+		live := obj.(model.K8sLocalObject).ToUnstructured().DeepCopy()
+		require.NoError(t, unstructured.SetNestedField(live.Object, "oldpass", "spec", "datasource", "secureJsonData", "basicAuthPassword"))
+		return live, nil
+	}
+	err := s.executeCommand("diff", "local", "--show-deletes=false")
+	require.NoError(t, err)
+	a := assert.New(t)
+	a.EqualValues([]interface{}{"GrafanaDatasource::prometheus"}, s.outputStats()["changes"])
+	a.Contains(s.stdout(), "basicAuthPassword: redacted.")
+	a.NotContains(s.stdout(), "oldpass")
+	a.NotContains(s.stdout(), "changeme")
+	a.Contains(s.stdout(), "url: http://prometheus:9090")
+}
+
+func TestDiffDrift(t *testing.T) {
+	// the live objects record the local object as the last applied configuration and may have been changed since
+	getter := func(change func(live *unstructured.Unstructured)) func(ctx context.Context, obj model.K8sMeta) (*unstructured.Unstructured, error) {
+		return func(ctx context.Context, obj model.K8sMeta) (*unstructured.Unstructured, error) {
+			local := obj.(model.K8sLocalObject).ToUnstructured()
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			require.NoError(t, json.NewEncoder(gz).Encode(local.Object))
+			require.NoError(t, gz.Close())
+			live := local.DeepCopy()
+			anns := live.GetAnnotations()
+			anns[model.QbecNames.PristineAnnotation] = base64.StdEncoding.EncodeToString(buf.Bytes())
+			live.SetAnnotations(anns)
+			live.SetUID("1234")
+			if live.GetKind() == "Deployment" {
+				// fields set by the server and other controllers are not drift
+				require.NoError(t, unstructured.SetNestedField(live.Object, int64(5), "spec", "replicas"))
+				require.NoError(t, unstructured.SetNestedField(live.Object, "RollingUpdate", "spec", "strategy", "type"))
+				require.NoError(t, unstructured.SetNestedSlice(live.Object, []interface{}{
+					map[string]interface{}{"name": "sidecar", "image": "envoy"},
+					map[string]interface{}{"name": "main", "image": "nginx", "imagePullPolicy": "Always"},
+				}, "spec", "template", "spec", "containers"))
+				if change != nil {
+					change(live)
+				}
+			}
+			return live, nil
+		}
+	}
+	tests := []struct {
+		name     string
+		change   func(live *unstructured.Unstructured)
+		args     []string
+		asserter func(s *scaffold, err error)
+	}{
+		{
+			name: "no drift",
+			args: []string{"--error-on=drift"},
+			asserter: func(s *scaffold, err error) {
+				require.NoError(s.t, err)
+				stats := s.outputStats()
+				assert.Nil(s.t, stats["drifted"])
+				assert.Nil(s.t, stats["changes"])
+			},
+		},
+		{
+			name: "drift",
+			change: func(live *unstructured.Unstructured) {
+				containers, _, _ := unstructured.NestedSlice(live.Object, "spec", "template", "spec", "containers")
+				containers[1].(map[string]interface{})["image"] = "nginx:hotfix"
+				require.NoError(t, unstructured.SetNestedSlice(live.Object, containers, "spec", "template", "spec", "containers"))
+			},
+			args: []string{"--error-on=drift"},
+			asserter: func(s *scaffold, err error) {
+				require.Error(s.t, err)
+				a := assert.New(s.t)
+				a.Equal("1 object(s) drifted", err.Error())
+				stats := s.outputStats()
+				a.EqualValues([]interface{}{"Deployment::web"}, stats["drifted"])
+				a.Nil(stats["changes"])
+				a.Contains(s.stdout(), "--- applied Deployment::web")
+				a.Contains(s.stdout(), "+++ live Deployment::web")
+				a.Contains(s.stdout(), "+      - image: nginx:hotfix")
+				a.NotContains(s.stdout(), "envoy")
+			},
+		},
+		{
+			name: "drift no error",
+			change: func(live *unstructured.Unstructured) {
+				unstructured.RemoveNestedField(live.Object, "spec", "selector")
+			},
+			args: []string{"--error-on=none"},
+			asserter: func(s *scaffold, err error) {
+				require.NoError(s.t, err)
+				assert.EqualValues(s.t, []interface{}{"Deployment::web"}, s.outputStats()["drifted"])
+			},
+		},
+		{
+			name: "drift error exit",
+			change: func(live *unstructured.Unstructured) {
+				require.NoError(t, unstructured.SetNestedField(live.Object, "other", "spec", "template", "metadata", "labels", "app"))
+			},
+			args: []string{"--error-exit"},
+			asserter: func(s *scaffold, err error) {
+				require.Error(s.t, err)
+				assert.Equal(s.t, "1 object(s) drifted", err.Error())
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newCustomScaffold(t, "testdata/projects/diff-ignores")
+			defer s.reset()
+			s.client.getFunc = getter(test.change)
+			err := s.executeCommand(append([]string{"diff", "local", "--show-deletes=false"}, test.args...)...)
+			test.asserter(s, err)
+		})
+	}
+}
+
+func TestLiveView(t *testing.T) {
+	tests := []struct {
+		name    string
+		applied interface{}
+		live    interface{}
+		drifted bool
+	}{
+		{"same", map[string]interface{}{"a": "b"}, map[string]interface{}{"a": "b", "c": "d"}, false},
+		{"null", map[string]interface{}{"creationTimestamp": nil}, map[string]interface{}{"creationTimestamp": "2026-10-18T00:00:00Z"}, false},
+		{"missing", map[string]interface{}{"a": "b"}, map[string]interface{}{}, true},
+		{"numbers", map[string]interface{}{"a": json.Number("2")}, map[string]interface{}{"a": int64(2)}, false},
+		{"quantities", map[string]interface{}{"cpu": "1000m"}, map[string]interface{}{"cpu": "1"}, false},
+		{"versions", map[string]interface{}{"v": "1.1"}, map[string]interface{}{"v": "1.10"}, true},
+		{"list", []interface{}{"a", "b"}, []interface{}{"a", "b", "c"}, true},
+		{"type change", map[string]interface{}{"a": "b"}, "x", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, drifted := liveView(test.applied, test.live)
+			assert.Equal(t, test.drifted, drifted)
+		})
+	}
+}
+
+func TestDiffIgnorePathsNegative(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("diff", "dev", "--ignore-path", "spec.replicas")
+	require.Error(t, err)
+	a := assert.New(t)
+	a.True(cmd.IsUsageError(err))
+	a.Equal(`invalid path "spec.replicas": expected . or [ at "spec.replicas"`, err.Error())
+}
+
+func TestDiffErrorOnNegative(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"bad value", []string{"--error-on=all"}, `invalid value for --error-on "all", must be one of "any", "drift" or "none"`},
+		{"with error exit", []string{"--error-on=drift", "--error-exit"}, "cannot specify both --error-exit and --error-on"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newScaffold(t)
+			defer s.reset()
+			err := s.executeCommand(append([]string{"diff", "dev"}, test.args...)...)
+			require.Error(t, err)
+			assert.True(t, cmd.IsUsageError(err))
+			assert.Equal(t, test.expected, err.Error())
+		})
+	}
+}
+
 func TestDiffBasicNoLabels(t *testing.T) {
 	s := newScaffold(t)
 	defer s.reset()
@@ -225,3 +615,103 @@ func TestDiffNegative(t *testing.T) {
 	}
 
 }
+
+func TestDiffOffline(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	s.client.listFunc = func(ctx context.Context, _ remote.ListQueryConfig) (remote.Collection, error) {
+		c := &coll{}
+		c.add(
+			&basicObject{
+				objectKey: objectKey{
+					gvk:       schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+					namespace: "bar-system",
+					name:      "svc2-cm",
+				},
+				component: "service2",
+				app:       "example1",
+				env:       "dev",
+				anns: map[string]string{
+					"kubectl.kubernetes.io/last-applied-configuration": `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"svc2-cm","namespace":"bar-system"},"data":{"foo":"baz"}}`,
+				},
+			},
+			&basicObject{
+				objectKey: objectKey{
+					gvk:       schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+					namespace: "bar-system",
+					name:      "svc2-previous-deploy",
+				},
+				component: "service2",
+				app:       "example1",
+				env:       "dev",
+			},
+		)
+		return c, nil
+	}
+	err := s.executeCommand("diff", "dev", "--offline")
+	require.NoError(t, err)
+	stats := s.outputStats()
+	a := assert.New(t)
+	a.EqualValues([]interface{}{"ConfigMap:bar-system:svc2-cm"}, stats["changes"])
+	a.EqualValues([]interface{}{"Deployment:bar-system:svc2-previous-deploy"}, stats["deletions"])
+	a.Contains(stats["additions"], "Secret:bar-system:svc2-secret")
+	a.Contains(s.stdout(), "--- applied ConfigMap:bar-system:svc2-cm (source: kubectl annotation)")
+	a.Contains(s.stdout(), "metadata only, no last applied configuration")
+}
+
+func TestDiffSnapshot(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("show", "dev", "-S")
+	require.NoError(t, err)
+	extra := `
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: svc2-previous-deploy
+  namespace: bar-system
+  labels:
+    qbec.io/application: example1
+    qbec.io/environment: dev
+  annotations:
+    qbec.io/component: service2
+`
+	file := filepath.Join(t.TempDir(), "snapshot.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte(s.stdout()+extra), 0644))
+
+	s2 := newScaffold(t)
+	defer s2.reset()
+	err = s2.executeCommand("diff", "dev", "--snapshot", file)
+	require.NoError(t, err)
+	stats := s2.outputStats()
+	a := assert.New(t)
+	a.Nil(stats["changes"])
+	a.EqualValues([]interface{}{"Deployment:bar-system:svc2-previous-deploy"}, stats["deletions"])
+	a.EqualValues([]interface{}{"Job::tj-<xxxxx>"}, stats["additions"])
+	a.Contains(s2.stdout(), "--- snapshot Deployment:bar-system:svc2-previous-deploy")
+
+	s3 := newScaffold(t)
+	defer s3.reset()
+	err = s3.executeCommand("diff", "dev", "--snapshot", file, "-c", "service1", "--show-deletes=false")
+	require.NoError(t, err)
+	a.Nil(s3.outputStats()["deletions"])
+}
+
+func TestDiffSnapshotNegative(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("diff", "dev", "--snapshot", "foo.yaml", "--offline")
+	require.Error(t, err)
+	a := assert.New(t)
+	a.True(cmd.IsUsageError(err))
+	a.Equal("cannot specify both --offline and --snapshot", err.Error())
+
+	file := filepath.Join(t.TempDir(), "snapshot.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte("- foo\n"), 0644))
+	s2 := newScaffold(t)
+	defer s2.reset()
+	err = s2.executeCommand("diff", "dev", "--snapshot", file)
+	require.Error(t, err)
+	a.Equal(fmt.Sprintf("snapshot %s: unexpected document of type string", file), err.Error())
+}
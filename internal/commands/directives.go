@@ -26,8 +26,10 @@ import (
 )
 
 const (
-	policyNever   = "never"
-	policyDefault = "default"
+	policyNever    = "never"
+	policyDefault  = "default"
+	directiveTrue  = "true"
+	directiveFalse = "false"
 )
 
 // isSet return true if the annotation name specified as directive is equal to the supplied value.
@@ -61,7 +63,8 @@ func isSet(ob model.K8sMeta, directive, value string, otherAllowedValues []strin
 type updatePolicy struct{}
 
 func (u *updatePolicy) disableUpdate(ob model.K8sMeta) bool {
-	return isSet(ob, model.QbecNames.Directives.UpdatePolicy, policyNever, []string{policyDefault})
+	return isSet(ob, model.QbecNames.Directives.UpdatePolicy, policyNever, []string{policyDefault}) ||
+		isSet(ob, model.QbecNames.Directives.IgnoreUpdates, directiveTrue, []string{directiveFalse})
 }
 
 func newUpdatePolicy() *updatePolicy {
@@ -85,7 +88,8 @@ func newDeletePolicy(nsFunc func(kind schema.GroupVersionKind) (bool, error), de
 }
 
 func (d *deletePolicy) disableDelete(ob model.K8sMeta) bool {
-	ret := isSet(ob, model.QbecNames.Directives.DeletePolicy, policyNever, []string{policyDefault})
+	ret := isSet(ob, model.QbecNames.Directives.DeletePolicy, policyNever, []string{policyDefault}) ||
+		isSet(ob, model.QbecNames.Directives.IgnoreDelete, directiveTrue, []string{directiveFalse})
 	if ret {
 		isNamespaced, _ := d.nsFunc(ob.GroupVersionKind())
 		if isNamespaced {
@@ -90,6 +90,14 @@ func TestDirectivesUpdatePolicy(t *testing.T) {
 		"directives.qbec.io/update-policy": "never",
 	}))
 	a.True(ret)
+	ret = up.disableUpdate(k8sMetaWithAnnotations("ConfigMap", "foo", "bar", map[string]interface{}{
+		"directives.qbec.io/ignore-updates": "true",
+	}))
+	a.True(ret)
+	ret = up.disableUpdate(k8sMetaWithAnnotations("ConfigMap", "foo", "bar", map[string]interface{}{
+		"directives.qbec.io/ignore-updates": "false",
+	}))
+	a.False(ret)
 }
 
 func TestDirectivesDeletePolicy(t *testing.T) {
@@ -116,6 +124,15 @@ func TestDirectivesDeletePolicy(t *testing.T) {
 	clusterNs := k8sMetaWithAnnotations("ClusterObj", "yyy", "cobj1", disableAnns)
 	a.True(dp.disableDelete(clusterNs))
 	a.False(dp.disableDelete(k8sMetaWithAnnotations("Namespace", "", "yyy", nil)))
+
+	ignoreAnns := map[string]interface{}{
+		"directives.qbec.io/ignore-delete": "true",
+	}
+	a.True(dp.disableDelete(k8sMetaWithAnnotations("ConfigMap", "zzz", "data", ignoreAnns)))
+	a.True(dp.disableDelete(k8sMetaWithAnnotations("Namespace", "", "zzz", nil)))
+	a.False(dp.disableDelete(k8sMetaWithAnnotations("ConfigMap", "zzz", "data", map[string]interface{}{
+		"directives.qbec.io/ignore-delete": "false",
+	})))
 }
 
 func TestDirectivesWaitPolicy(t *testing.T) {
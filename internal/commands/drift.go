@@ -0,0 +1,198 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/splunk/qbec/internal/diff"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/splunk/qbec/internal/types"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// liveView returns the values in the live object for the fields set in the applied configuration, and whether
+// any of these values are different from the applied ones. Fields that are only present in the live object,
+// such as defaults and fields set by other controllers, are not part of the view. Items of lists of objects
+// that have names are matched by name such that, for example, containers injected into pods are ignored.
+func liveView(applied, live interface{}) (interface{}, bool) {
+	switch a := applied.(type) {
+	case nil:
+		return nil, false // null values delete fields on apply and are not retained by the server
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			return live, true
+		}
+		ret := map[string]interface{}{}
+		drifted := false
+		for k, av := range a {
+			lv, ok := l[k]
+			if !ok {
+				if av != nil {
+					drifted = true
+				}
+				continue
+			}
+			v, d := liveView(av, lv)
+			ret[k] = v
+			drifted = drifted || d
+		}
+		return ret, drifted
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok {
+			return live, true
+		}
+		appliedNames, liveNames := itemsByName(a), itemsByName(l)
+		drifted := false
+		ret := make([]interface{}, 0, len(a))
+		if appliedNames != nil && liveNames != nil {
+			for _, item := range a {
+				lv, ok := liveNames[item.(map[string]interface{})["name"].(string)]
+				if !ok {
+					drifted = true
+					continue
+				}
+				v, d := liveView(item, lv)
+				ret = append(ret, v)
+				drifted = drifted || d
+			}
+			return ret, drifted
+		}
+		if len(a) != len(l) {
+			return l, true
+		}
+		for i := range a {
+			v, d := liveView(a[i], l[i])
+			ret = append(ret, v)
+			drifted = drifted || d
+		}
+		return ret, drifted
+	default:
+		if scalarsEqual(applied, live) {
+			return applied, false
+		}
+		return live, true
+	}
+}
+
+// itemsByName returns the items of the supplied list keyed by their name, or nil if the list is empty or
+// not every item is an object with a unique name.
+func itemsByName(list []interface{}) map[string]interface{} {
+	if len(list) == 0 {
+		return nil
+	}
+	ret := map[string]interface{}{}
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		name, ok := m["name"].(string)
+		if !ok {
+			return nil
+		}
+		if _, ok := ret[name]; ok {
+			return nil
+		}
+		ret[name] = item
+	}
+	return ret
+}
+
+// scalarsEqual returns true if the supplied scalar values are the same, accounting for different representations
+// of numbers and for quantities that are normalized by the server (e.g. 1000m and 1).
+func scalarsEqual(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	if af, ok := toFloat(a); ok {
+		bf, ok := toFloat(b)
+		return ok && af == bf
+	}
+	as, ok1 := a.(string)
+	bs, ok2 := b.(string)
+	if !ok1 || !ok2 || (isPlainNumber(as) && isPlainNumber(bs)) {
+		return false
+	}
+	aq, err := resource.ParseQuantity(as)
+	if err != nil {
+		return false
+	}
+	bq, err := resource.ParseQuantity(bs)
+	return err == nil && aq.Cmp(bq) == 0
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// isPlainNumber returns true if the supplied string only has digits and decimal points, such that two such strings
+// that are different are never treated as the same quantity (e.g. versions like 1.1 and 1.10).
+func isPlainNumber(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789.") == ""
+}
+
+// checkDrift writes a diff for the fields of the configuration last applied to the supplied live object that
+// have since been changed in the cluster. Objects without a recorded configuration and objects for which
+// updates are disabled are not checked.
+func (d *differ) checkDrift(name string, ob model.K8sMeta, live *unstructured.Unstructured) error {
+	applied, _ := remote.GetPristineVersionFromAnnotations(live.GetAnnotations())
+	if applied == nil || d.upPolicy.disableUpdate(live) {
+		return nil
+	}
+	live = live.DeepCopy()
+	d.ignores.preprocess(ob, applied)
+	d.ignores.preprocess(ob, live)
+	view, drifted := liveView(applied.Object, live.Object)
+	if !drifted {
+		return nil
+	}
+	left, right := applied, &unstructured.Unstructured{Object: view.(map[string]interface{})}
+	if !d.showSecrets {
+		left, _ = types.HideSensitiveInfo(left)
+		right, _ = types.HideSensitiveInfo(right)
+	}
+	opts := d.opts
+	opts.LeftName = "applied " + name
+	opts.RightName = "live " + name
+	b, err := diff.Objects(left, right, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(d.w, "#\n# drift: %s has been changed in the cluster\n#\n%s\n", name, b)
+	d.stats.drifted(name)
+	return nil
+}
@@ -0,0 +1,240 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/sio"
+)
+
+type envEditCommandConfig struct {
+	cmd.AppContext
+	file string
+	edit model.EnvironmentEdit
+}
+
+// addEnvEditFlags adds flags for environment attributes to the supplied command and returns a function that
+// returns the changes specified on the command line.
+func addEnvEditFlags(c *cobra.Command) func() (model.EnvironmentEdit, error) {
+	var server, context, ns, inherits string
+	var props []string
+	c.Flags().StringVar(&server, "server", "", "the server URL for the environment")
+	c.Flags().StringVar(&context, "context", "", "the kubeconfig context for the environment, instead of a server URL")
+	c.Flags().StringVar(&ns, "default-namespace", "", "the default namespace for the environment")
+	c.Flags().StringVar(&inherits, "inherits", "", "the environment from which this environment inherits")
+	c.Flags().StringArrayVar(&props, "property", nil, "set a string property for the environment as key=value, an empty value removes the property")
+	return func() (model.EnvironmentEdit, error) {
+		var edit model.EnvironmentEdit
+		str := func(name string, value string) *string {
+			if !c.Flags().Changed(name) {
+				return nil
+			}
+			return &value
+		}
+		edit.Server = str("server", server)
+		edit.Context = str("context", context)
+		edit.DefaultNamespace = str("default-namespace", ns)
+		edit.Inherits = str("inherits", inherits)
+		if edit.Server != nil && edit.Context != nil && server != "" && context != "" {
+			return edit, cmd.NewUsageError("only one of --server or --context may be specified")
+		}
+		for _, p := range props {
+			parts := strings.SplitN(p, "=", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return edit, cmd.NewUsageError(fmt.Sprintf("invalid property %q, must be of the form key=value", p))
+			}
+			if edit.Properties == nil {
+				edit.Properties = map[string]string{}
+			}
+			edit.Properties[parts[0]] = parts[1]
+		}
+		return edit, nil
+	}
+}
+
+// targetFile returns the file to be updated, which is the file that defines the app unless one was specified.
+func (c envEditCommandConfig) targetFile() (string, error) {
+	if c.file != "" {
+		return c.file, nil
+	}
+	file, err := appFile()
+	if err != nil {
+		return "", err
+	}
+	if strings.HasSuffix(file, ".jsonnet") {
+		return "", cmd.NewUsageError(fmt.Sprintf("environments defined in %s cannot be edited, use --file to update an environment file", file))
+	}
+	return file, nil
+}
+
+// editEnvFile changes the supplied file using the edit function and ensures that the app can still be loaded,
+// with the environment files and tag of the current app and the supplied values for templates in environment fields,
+// after the change. The original contents are restored when this is not the case.
+func editEnvFile(file string, current *model.App, vars map[string]string, fn func(content []byte) ([]byte, error)) error {
+	app, err := appFile()
+	if err != nil {
+		return err
+	}
+	stat, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	original, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	updated, err := fn(original)
+	if err != nil {
+		return errors.Wrap(err, file)
+	}
+	if err := ioutil.WriteFile(file, updated, stat.Mode()); err != nil {
+		return err
+	}
+	if _, err := model.NewAppWithVars(app, current.ExtraEnvFiles(), current.Tag(), vars); err != nil {
+		if rerr := ioutil.WriteFile(file, original, stat.Mode()); rerr != nil {
+			sio.Errorf("unable to restore %s: %v\n", file, rerr)
+		}
+		return errors.Wrap(err, "invalid app after change, not updated")
+	}
+	return nil
+}
+
+func doEnvAdd(args []string, config envEditCommandConfig) error {
+	if len(args) != 1 {
+		return cmd.NewUsageError(fmt.Sprintf("exactly one environment required, but provided: %q", args))
+	}
+	name := args[0]
+	if _, ok := config.App().Environments()[name]; ok {
+		return fmt.Errorf("environment %s already exists", name)
+	}
+	if config.edit.Server == nil && config.edit.Context == nil && config.edit.Inherits == nil {
+		return cmd.NewUsageError("one of --server, --context or --inherits must be specified")
+	}
+	file, err := config.targetFile()
+	if err != nil {
+		return err
+	}
+	if err := editEnvFile(file, config.App(), config.InterpolationVars(), func(content []byte) ([]byte, error) {
+		return model.AddEnvironment(content, name, config.edit)
+	}); err != nil {
+		return err
+	}
+	sio.Noticef("added environment %s to %s\n", name, file)
+	return nil
+}
+
+func doEnvSet(args []string, config envEditCommandConfig) error {
+	if len(args) != 1 {
+		return cmd.NewUsageError(fmt.Sprintf("exactly one environment required, but provided: %q", args))
+	}
+	name := args[0]
+	file, err := config.targetFile()
+	if err != nil {
+		return err
+	}
+	if err := editEnvFile(file, config.App(), config.InterpolationVars(), func(content []byte) ([]byte, error) {
+		return model.UpdateEnvironment(content, name, config.edit)
+	}); err != nil {
+		return err
+	}
+	sio.Noticef("updated environment %s in %s\n", name, file)
+	return nil
+}
+
+func doEnvRemove(args []string, config envEditCommandConfig) error {
+	if len(args) != 1 {
+		return cmd.NewUsageError(fmt.Sprintf("exactly one environment required, but provided: %q", args))
+	}
+	name := args[0]
+	file, err := config.targetFile()
+	if err != nil {
+		return err
+	}
+	if err := editEnvFile(file, config.App(), config.InterpolationVars(), func(content []byte) ([]byte, error) {
+		return model.RemoveEnvironment(content, name)
+	}); err != nil {
+		return err
+	}
+	sio.Noticef("removed environment %s from %s\n", name, file)
+	return nil
+}
+
+func newEnvAddCommand(cp ctxProvider) *cobra.Command {
+	c := &cobra.Command{
+		Use:     "add <env> [--server <url> | --context <name>] [--default-namespace <ns>]",
+		Short:   "add an environment to qbec.yaml or an environment file",
+		Example: envAddExamples(),
+	}
+	config := envEditCommandConfig{}
+	c.Flags().StringVar(&config.file, "file", "", "the file to update, relative to the qbec root, defaults to the app file")
+	editFn := addEnvEditFlags(c)
+
+	c.RunE = func(c *cobra.Command, args []string) error {
+		config.AppContext = cp()
+		var err error
+		if config.edit, err = editFn(); err != nil {
+			return cmd.WrapError(err)
+		}
+		return cmd.WrapError(doEnvAdd(args, config))
+	}
+	return c
+}
+
+func newEnvSetCommand(cp ctxProvider) *cobra.Command {
+	c := &cobra.Command{
+		Use:     "set <env> [--server <url> | --context <name>] [--default-namespace <ns>]",
+		Short:   "change attributes of an environment in qbec.yaml or an environment file",
+		Example: envSetExamples(),
+	}
+	config := envEditCommandConfig{}
+	c.Flags().StringVar(&config.file, "file", "", "the file to update, relative to the qbec root, defaults to the app file")
+	editFn := addEnvEditFlags(c)
+
+	c.RunE = func(c *cobra.Command, args []string) error {
+		config.AppContext = cp()
+		var err error
+		if config.edit, err = editFn(); err != nil {
+			return cmd.WrapError(err)
+		}
+		return cmd.WrapError(doEnvSet(args, config))
+	}
+	return c
+}
+
+func newEnvRemoveCommand(cp ctxProvider) *cobra.Command {
+	c := &cobra.Command{
+		Use:     "remove <env>",
+		Short:   "remove an environment from qbec.yaml or an environment file",
+		Example: envRemoveExamples(),
+	}
+	config := envEditCommandConfig{}
+	c.Flags().StringVar(&config.file, "file", "", "the file to update, relative to the qbec root, defaults to the app file")
+
+	c.RunE = func(c *cobra.Command, args []string) error {
+		config.AppContext = cp()
+		return cmd.WrapError(doEnvRemove(args, config))
+	}
+	return c
+}
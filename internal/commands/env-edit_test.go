@@ -0,0 +1,268 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// copyProject copies the supplied test project to a temporary directory and returns its path.
+func copyProject(t *testing.T, src string) string {
+	dest := t.TempDir()
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, b, info.Mode())
+	})
+	require.NoError(t, err)
+	return dest
+}
+
+func TestEnvAddSetRemove(t *testing.T) {
+	dir := copyProject(t, "testdata/projects/simple-service")
+	file := filepath.Join(dir, "qbec.yaml")
+	a := assert.New(t)
+
+	s := newCustomScaffold(t, dir)
+	err := s.executeCommand("env", "add", "stage", "--server", "https://stage-server", "--default-namespace", "stage-ns", "--property", "tier=silver")
+	s.reset()
+	require.NoError(t, err)
+	b, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	a.Contains(string(b), "    stage:\n      server: https://stage-server\n      defaultNamespace: stage-ns\n      properties:\n        tier: silver\n")
+
+	s = newCustomScaffold(t, dir)
+	err = s.executeCommand("env", "list")
+	s.reset()
+	require.NoError(t, err)
+	a.Equal("local\nstage\n", s.stdout())
+
+	s = newCustomScaffold(t, dir)
+	err = s.executeCommand("env", "set", "stage", "--context", "kind-stage", "--property", "tier=")
+	s.reset()
+	require.NoError(t, err)
+	b, err = ioutil.ReadFile(file)
+	require.NoError(t, err)
+	a.Contains(string(b), "    stage:\n      defaultNamespace: stage-ns\n      context: kind-stage\n")
+	a.NotContains(string(b), "stage-server")
+	a.NotContains(string(b), "tier")
+
+	s = newCustomScaffold(t, dir)
+	err = s.executeCommand("env", "remove", "stage")
+	s.reset()
+	require.NoError(t, err)
+	b, err = ioutil.ReadFile(file)
+	require.NoError(t, err)
+	a.NotContains(string(b), "stage")
+}
+
+func TestEnvAddToEnvFile(t *testing.T) {
+	dir := copyProject(t, "testdata/projects/simple-service")
+	envFile := filepath.Join(dir, "envs.yaml")
+	require.NoError(t, ioutil.WriteFile(envFile, []byte("apiVersion: qbec.io/v1alpha1\nkind: EnvironmentMap\nspec:\n  environments:\n    dev:\n      context: kind-dev\n"), 0644))
+	qbecFile := filepath.Join(dir, "qbec.yaml")
+	b, err := ioutil.ReadFile(qbecFile)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(qbecFile, append(b, []byte("  envFiles:\n    - envs.yaml\n")...), 0644))
+
+	s := newCustomScaffold(t, dir)
+	defer s.reset()
+	err = s.executeCommand("env", "add", "prod", "--context", "kind-prod", "--file", "envs.yaml")
+	require.NoError(t, err)
+	b, err = ioutil.ReadFile(envFile)
+	require.NoError(t, err)
+	assert.Equal(t, "apiVersion: qbec.io/v1alpha1\nkind: EnvironmentMap\nspec:\n  environments:\n    dev:\n      context: kind-dev\n    prod:\n      context: kind-prod\n", string(b))
+}
+
+func TestEnvEditJsonnetApp(t *testing.T) {
+	dir := copyProject(t, "testdata/projects/jsonnet-app")
+	envFile := filepath.Join(dir, "envs.yaml")
+	require.NoError(t, ioutil.WriteFile(envFile, []byte("apiVersion: qbec.io/v1alpha1\nkind: EnvironmentMap\nspec:\n  environments:\n    local:\n      context: kind-local\n"), 0644))
+	appFile := filepath.Join(dir, "qbec.jsonnet")
+	b, err := ioutil.ReadFile(appFile)
+	require.NoError(t, err)
+	updated := bytes.Replace(b, []byte("  spec: {\n"), []byte("  spec: {\n    envFiles: ['envs.yaml'],\n"), 1)
+	require.NoError(t, ioutil.WriteFile(appFile, updated, 0644))
+	a := assert.New(t)
+
+	s := newCustomScaffold(t, dir)
+	err = s.executeCommand("env", "add", "stage", "--context", "kind-stage")
+	s.reset()
+	require.Error(t, err)
+	a.True(cmd.IsUsageError(err))
+	a.Equal("environments defined in qbec.jsonnet cannot be edited, use --file to update an environment file", err.Error())
+
+	s = newCustomScaffold(t, dir)
+	err = s.executeCommand("env", "add", "stage", "--context", "kind-stage", "--file", "envs.yaml")
+	s.reset()
+	require.NoError(t, err)
+	b, err = ioutil.ReadFile(envFile)
+	require.NoError(t, err)
+	a.Contains(string(b), "    stage:\n      context: kind-stage\n")
+
+	s = newCustomScaffold(t, dir)
+	err = s.executeCommand("env", "set", "stage", "--inherits", "missing", "--file", "envs.yaml")
+	s.reset()
+	require.Error(t, err)
+	a.Contains(err.Error(), "invalid app after change, not updated")
+	b, err = ioutil.ReadFile(envFile)
+	require.NoError(t, err)
+	a.NotContains(string(b), "missing")
+
+	s = newCustomScaffold(t, dir)
+	err = s.executeCommand("env", "remove", "stage", "--file", "envs.yaml")
+	s.reset()
+	require.NoError(t, err)
+	b, err = ioutil.ReadFile(envFile)
+	require.NoError(t, err)
+	a.NotContains(string(b), "stage")
+}
+
+func TestEnvEditWithEnvFileFlag(t *testing.T) {
+	dir := copyProject(t, "testdata/projects/simple-service")
+	file := filepath.Join(dir, "qbec.yaml")
+	extra := filepath.Join(t.TempDir(), "extra.yaml")
+	require.NoError(t, ioutil.WriteFile(extra, []byte("apiVersion: qbec.io/v1alpha1\nkind: EnvironmentMap\nspec:\n  environments:\n    extra:\n      inherits: stage\n"), 0644))
+	a := assert.New(t)
+
+	s := newCustomScaffold(t, dir)
+	err := s.executeCommand("env", "add", "stage", "--server", "https://stage-server")
+	s.reset()
+	require.NoError(t, err)
+
+	s = newCustomScaffold(t, dir)
+	err = s.executeCommand("env", "remove", "stage", "-E", extra)
+	s.reset()
+	require.Error(t, err)
+	a.Contains(err.Error(), "invalid app after change, not updated")
+	b, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	a.Contains(string(b), "stage")
+}
+
+func TestEnvEditNegative(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		asserter func(s *scaffold, err error)
+	}{
+		{
+			name: "add no args",
+			args: []string{"env", "add"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.True(cmd.IsUsageError(err))
+				a.Equal("exactly one environment required, but provided: []", err.Error())
+			},
+		},
+		{
+			name: "add existing",
+			args: []string{"env", "add", "local", "--context", "kind-kind"},
+			asserter: func(s *scaffold, err error) {
+				assert.Equal(s.t, "environment local already exists", err.Error())
+			},
+		},
+		{
+			name: "add no destination",
+			args: []string{"env", "add", "stage", "--default-namespace", "foo"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.True(cmd.IsUsageError(err))
+				a.Equal("one of --server, --context or --inherits must be specified", err.Error())
+			},
+		},
+		{
+			name: "add server and context",
+			args: []string{"env", "add", "stage", "--server", "https://stage", "--context", "kind-kind"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.True(cmd.IsUsageError(err))
+				a.Equal("only one of --server or --context may be specified", err.Error())
+			},
+		},
+		{
+			name: "add bad property",
+			args: []string{"env", "add", "stage", "--server", "https://stage", "--property", "tier"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.True(cmd.IsUsageError(err))
+				a.Equal(`invalid property "tier", must be of the form key=value`, err.Error())
+			},
+		},
+		{
+			name: "add unknown parent",
+			args: []string{"env", "add", "stage", "--inherits", "base"},
+			asserter: func(s *scaffold, err error) {
+				assert.Equal(s.t, "invalid app after change, not updated: environment stage inherits from unknown environment base", err.Error())
+				b, err := ioutil.ReadFile("qbec.yaml")
+				require.NoError(s.t, err)
+				assert.NotContains(s.t, string(b), "stage")
+			},
+		},
+		{
+			name: "set missing",
+			args: []string{"env", "set", "stage", "--server", "https://stage"},
+			asserter: func(s *scaffold, err error) {
+				assert.Equal(s.t, "qbec.yaml: environment stage not found", err.Error())
+			},
+		},
+		{
+			name: "remove last",
+			args: []string{"env", "remove", "local"},
+			asserter: func(s *scaffold, err error) {
+				assert.Contains(s.t, err.Error(), "qbec.yaml: file: updated document, 1 schema validation error(s)")
+			},
+		},
+		{
+			name: "remove bad file",
+			args: []string{"env", "remove", "local", "--file", "missing.yaml"},
+			asserter: func(s *scaffold, err error) {
+				assert.Contains(s.t, err.Error(), "missing.yaml: no such file or directory")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newCustomScaffold(t, copyProject(t, "testdata/projects/simple-service"))
+			defer s.reset()
+			err := s.executeCommand(test.args...)
+			require.NotNil(t, err)
+			test.asserter(s, err)
+		})
+	}
+}
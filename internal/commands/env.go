@@ -32,7 +32,8 @@ func newEnvCommand(cp ctxProvider) *cobra.Command {
 		Use:   "env <subcommand>",
 		Short: "environment lists and details",
 	}
-	cmd.AddCommand(newEnvListCommand(cp), newEnvVarsCommand(cp), newEnvPropsCommand(cp))
+	cmd.AddCommand(newEnvListCommand(cp), newEnvVarsCommand(cp), newEnvPropsCommand(cp), newEnvCurrentCommand(cp))
+	cmd.AddCommand(newEnvAddCommand(cp), newEnvSetCommand(cp), newEnvRemoveCommand(cp))
 	return cmd
 }
 
@@ -140,10 +141,14 @@ func doEnvVars(args []string, config envVarsCommandConfig) error {
 	if len(args) != 1 {
 		return cmd.NewUsageError(fmt.Sprintf("exactly one environment required, but provided: %q", args))
 	}
-	if _, ok := config.App().Environments()[args[0]]; !ok {
-		return fmt.Errorf("invalid environment: %q", args[0])
+	env, err := config.ResolveEnv(args[0])
+	if err != nil {
+		return err
+	}
+	if _, ok := config.App().Environments()[env]; !ok {
+		return fmt.Errorf("invalid environment: %q", env)
 	}
-	return environmentVars(args[0], config)
+	return environmentVars(env, config)
 }
 
 func environmentVars(name string, config envVarsCommandConfig) error {
@@ -222,10 +227,14 @@ func doEnvProps(args []string, config envPropsCommandConfig) error {
 	if len(args) != 1 {
 		return cmd.NewUsageError(fmt.Sprintf("exactly one environment required, but provided: %q", args))
 	}
-	if _, ok := config.App().Environments()[args[0]]; !ok {
-		return fmt.Errorf("invalid environment: %q", args[0])
+	env, err := config.ResolveEnv(args[0])
+	if err != nil {
+		return err
+	}
+	if _, ok := config.App().Environments()[env]; !ok {
+		return fmt.Errorf("invalid environment: %q", env)
 	}
-	return environmentProps(args[0], config)
+	return environmentProps(env, config)
 }
 
 func environmentProps(name string, config envPropsCommandConfig) error {
@@ -247,3 +256,64 @@ func environmentProps(name string, config envPropsCommandConfig) error {
 	}
 	return nil
 }
+
+func newEnvCurrentCommand(cp ctxProvider) *cobra.Command {
+	c := &cobra.Command{
+		Use:     "current [-o <format>]",
+		Short:   "print the environment that corresponds to the current kubeconfig context",
+		Example: envCurrentExamples(),
+	}
+
+	config := envCurrentCommandConfig{}
+	c.Flags().StringVarP(&config.format, "format", "o", "", "use json|yaml to display machine readable output")
+
+	c.RunE = func(c *cobra.Command, args []string) error {
+		config.AppContext = cp()
+		return cmd.WrapError(doEnvCurrent(args, config))
+	}
+	return c
+}
+
+type envCurrentCommandConfig struct {
+	cmd.AppContext
+	format string
+}
+
+type displayCurrentEnv struct {
+	displayEnv
+	KubeContext string `json:"kubeContext"`
+}
+
+func doEnvCurrent(args []string, config envCurrentCommandConfig) error {
+	if len(args) != 0 {
+		return cmd.NewUsageError("extra arguments specified")
+	}
+	if config.format != "" && config.format != "json" && config.format != "yaml" {
+		return cmd.NewUsageError(fmt.Sprintf("currentEnvironment: unsupported format %q", config.format))
+	}
+	name, info, err := config.CurrentEnv()
+	if err != nil {
+		return err
+	}
+	out := displayCurrentEnv{
+		displayEnv: displayEnv{
+			Name:             name,
+			Server:           info.ServerURL,
+			DefaultNamespace: config.App().DefaultNamespace(name),
+		},
+		KubeContext: info.ContextName,
+	}
+	w := config.Stdout()
+	switch config.format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(out)
+	case "yaml":
+		b, _ := yaml.Marshal(out)
+		_, _ = w.Write(b)
+	default:
+		fmt.Fprintln(w, name)
+	}
+	return nil
+}
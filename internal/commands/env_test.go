@@ -17,6 +17,8 @@
 package commands
 
 import (
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
@@ -60,6 +62,72 @@ func TestEnvListJSON(t *testing.T) {
 	require.NoError(t, err)
 }
 
+// kubeconfigWithContext writes a copy of the test kubeconfig with the supplied current context and returns its path.
+func kubeconfigWithContext(t *testing.T, context string) string {
+	b, err := os.ReadFile("kubeconfig.yaml")
+	require.NoError(t, err)
+	out := strings.Replace(string(b), "current-context: prod", "current-context: "+context, 1)
+	file := filepath.Join(t.TempDir(), "kubeconfig.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(out), 0644))
+	return file
+}
+
+func TestEnvCurrentBasic(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("env", "current", "--k8s:kubeconfig=kubeconfig.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "prod\n", s.stdout())
+}
+
+func TestEnvCurrentByContext(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("env", "current", "-o", "json", "--k8s:kubeconfig="+kubeconfigWithContext(t, "minikube"))
+	require.NoError(t, err)
+	var data map[string]interface{}
+	err = s.jsonOutput(&data)
+	require.NoError(t, err)
+	a := assert.New(t)
+	a.Equal("local", data["name"])
+	a.Equal("minikube", data["kubeContext"])
+	a.Equal("https://localhost:30000", data["server"])
+	a.Equal("default", data["defaultNamespace"])
+}
+
+func TestEnvAuto(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("env", "props", "auto", "--k8s:kubeconfig="+kubeconfigWithContext(t, "dev"))
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`envType: development`))
+}
+
+func TestEnvCurrentNoMatch(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "kubeconfig.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`apiVersion: v1
+kind: Config
+clusters:
+  - cluster:
+      server: https://other-server
+    name: other
+contexts:
+  - context:
+      cluster: other
+    name: other
+current-context: other
+`), 0644))
+	for _, args := range [][]string{{"env", "current"}, {"show", "auto"}, {"apply", "dev,auto"}} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			s := newScaffold(t)
+			defer s.reset()
+			err := s.executeCommand(append(args, "--k8s:kubeconfig="+file)...)
+			require.Error(t, err)
+			assert.Equal(t, "no environment matches current context other (server https://other-server)", err.Error())
+		})
+	}
+}
+
 func TestEnvVarsBasic(t *testing.T) {
 	s := newScaffold(t)
 	defer s.reset()
@@ -163,6 +231,24 @@ func TestEnvNegative(t *testing.T) {
 				a.Equal(`invalid environment: "foo"`, err.Error())
 			},
 		},
+		{
+			name: "current extra args",
+			args: []string{"env", "current", "dev", "--k8s:kubeconfig=kubeconfig.yaml"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.True(cmd.IsUsageError(err))
+				a.Equal(`extra arguments specified`, err.Error())
+			},
+		},
+		{
+			name: "current bad format",
+			args: []string{"env", "current", "-o", "table", "--k8s:kubeconfig=kubeconfig.yaml"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.True(cmd.IsUsageError(err))
+				a.Equal(`currentEnvironment: unsupported format "table"`, err.Error())
+			},
+		},
 		{
 			name: "empty string env",
 			args: []string{"apply", ""},
@@ -23,39 +23,64 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/splunk/qbec/internal/cmd"
 	"github.com/splunk/qbec/internal/eval"
+	"github.com/splunk/qbec/vm"
 	"github.com/splunk/qbec/vm/vmutil"
 )
 
+// exprFile is the diagnostic file name used in errors for expressions evaluated using --expr.
+const exprFile = "<expr>"
+
 type evalCommandConfig struct {
 	cmd.AppContext
 	format string
 	env    string
+	expr   bool
 }
 
 func doEval(args []string, config evalCommandConfig) error {
-	if len(args) != 1 {
-		return cmd.NewUsageError("exactly one file required")
+	what := "file"
+	if config.expr {
+		what = "expression"
+	}
+	env := config.env
+	switch {
+	case len(args) == 2 && env != "":
+		return cmd.NewUsageError("cannot specify the environment both as an argument and using --env")
+	case len(args) == 2:
+		env, args = args[0], args[1:]
+	case len(args) != 1:
+		return cmd.NewUsageError(fmt.Sprintf("exactly one %s required", what))
+	}
+	evalFn := func(ctx eval.BaseContext) (string, error) {
+		if config.expr {
+			return eval.Code(exprFile, vm.MakeCode(args[0]), ctx)
+		}
+		return eval.File(args[0], ctx)
 	}
 	var output string
 	var err error
-	var envCtx cmd.EnvContext
-	var basicCtx eval.BaseContext
-	if config.env == "" {
+	if env == "" {
+		var basicCtx eval.BaseContext
 		basicCtx, err = config.BasicEvalContext()
 		if err != nil {
 			return err
 		}
-		output, err = eval.File(args[0], basicCtx)
+		output, err = evalFn(basicCtx)
 	} else {
-		envCtx, err = config.EnvContext(config.env)
+		env, err = config.ResolveEnv(env)
+		if err != nil {
+			return err
+		}
+		var envCtx cmd.EnvContext
+		envCtx, err = config.EnvContext(env)
 		if err != nil {
 			return err
 		}
 		ctx := envCtx.EvalContext(cleanEvalMode)
-		output, err = eval.File(args[0], ctx.BaseContext)
+		output, err = evalFn(ctx.BaseContext)
 	}
 	if err != nil {
-		return err
+		return cmd.WithCode(cmd.ErrorCodeEval, err)
 	}
 	var data interface{}
 	err = json.Unmarshal([]byte(output), &data)
@@ -84,13 +109,14 @@ func doEval(args []string, config evalCommandConfig) error {
 
 func newEvalCommand(cp ctxProvider) *cobra.Command {
 	c := &cobra.Command{
-		Use:     "eval [--env <env>] path/to/file.jsonnet",
-		Short:   "evaluate the supplied file optionally under a qbec environment",
+		Use:     "eval [<env>] path/to/file.jsonnet | eval [<env>] --expr <code>",
+		Short:   "evaluate the supplied file or expression optionally under a qbec environment",
 		Example: evalExamples(),
 	}
 	cfg := evalCommandConfig{}
 	c.Flags().StringVarP(&cfg.format, "format", "o", "json", "Output format. Supported values are: json, yaml")
-	c.Flags().StringVar(&cfg.env, "env", "", "qbec environment context, optional")
+	c.Flags().StringVar(&cfg.env, "env", "", "qbec environment context, optional. Use auto for the environment matching the current kubeconfig context")
+	c.Flags().BoolVarP(&cfg.expr, "expr", "e", false, "treat the argument as jsonnet code instead of a file")
 	c.RunE = func(c *cobra.Command, args []string) error {
 		cfg.AppContext = cp()
 		return cmd.WrapError(doEval(args, cfg))
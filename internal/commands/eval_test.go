@@ -105,12 +105,72 @@ func TestEvalInQBECContext(t *testing.T) {
 	a.Equal("development", data["bar"])
 }
 
-func TestEvalBadArgs(t *testing.T) {
+func TestEvalEnvArg(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("eval", "dev", "misc/qbec.jsonnet")
+	require.NoError(t, err)
+	var data map[string]interface{}
+	err = s.jsonOutput(&data)
+	require.NoError(t, err)
+	a := assert.New(t)
+	a.Equal("dev", data["foo"])
+	a.Equal("development", data["bar"])
+}
+
+func TestEvalExpr(t *testing.T) {
 	s := newScaffold(t)
 	defer s.reset()
-	err := s.executeCommand("eval", "misc/qbec.jsonnet", "misc/simple.jsonnet")
+	err := s.executeCommand("eval", "dev", "-e", `{ env: std.extVar('qbec.io/env'), ns: std.extVar('qbec.io/defaultNs'), simple: (import 'misc/simple.jsonnet').foo }`)
+	require.NoError(t, err)
+	var data map[string]interface{}
+	err = s.jsonOutput(&data)
+	require.NoError(t, err)
+	a := assert.New(t)
+	a.Equal("dev", data["env"])
+	a.Equal("str", data["simple"])
+	a.NotEmpty(data["ns"])
+}
+
+func TestEvalExprNoEnv(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("eval", "--expr", "std.extVar('foo') + '-bar'", "--vm:ext-str", "foo=str", "-o", "yaml")
+	require.NoError(t, err)
+	d, err := s.yamlOutput()
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"str-bar"}, d)
+}
+
+func TestEvalBadExpr(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("eval", "dev", "-e", "{ foo: ")
 	require.Error(t, err)
-	assert.Equal(t, "exactly one file required", err.Error())
+	assert.Contains(t, err.Error(), "<expr>")
+}
+
+func TestEvalBadArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"too many", []string{"dev", "misc/qbec.jsonnet", "misc/simple.jsonnet"}, "exactly one file required"},
+		{"too many exprs", []string{"-e", "dev", "1", "2"}, "exactly one expression required"},
+		{"none", nil, "exactly one file required"},
+		{"env twice", []string{"--env=dev", "dev", "misc/qbec.jsonnet"}, "cannot specify the environment both as an argument and using --env"},
+		{"bad env", []string{"misc/qbec.jsonnet", "misc/simple.jsonnet"}, `invalid environment "misc/qbec.jsonnet"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newScaffold(t)
+			defer s.reset()
+			err := s.executeCommand(append([]string{"eval"}, test.args...)...)
+			require.Error(t, err)
+			assert.Equal(t, test.expected, err.Error())
+		})
+	}
 }
 
 func TestEvalBadFile(t *testing.T) {
@@ -46,6 +46,22 @@ func exampleHelp(examples ...example) string {
 	return "\n" + strings.Join(ret, "\n\n")
 }
 
+func initExamples() string {
+	return exampleHelp(
+		newExample("init my-app --with-example", "create a qbec app in the my-app directory with a hello world component"),
+		newExample("init my-app --template=helm-wrapper", "create a qbec app that wraps a helm chart"),
+		newExample("init my-app --template=https://github.com/example/templates//web#v1.0.0",
+			"create a qbec app from the web directory of a git repository at tag v1.0.0"),
+	)
+}
+
+func depsEnsureExamples() string {
+	return exampleHelp(
+		newExample("deps ensure", "vendor dependencies declared in jsonnetfile.json that are not yet present,",
+			"at the versions recorded in jsonnetfile.lock.json"),
+	)
+}
+
 func applyExamples() string {
 	return exampleHelp(
 		newExample("apply dev --yes --wait", "create/ update all dev components and delete extra objects on the server",
@@ -53,6 +69,10 @@ func applyExamples() string {
 		newExample("apply -n dev", "show what apply would do for the dev environment"),
 		newExample("apply dev -c redis -K secret", "update all objects except secrets just for the redis component"),
 		newExample("apply dev --gc=false", "only create/ update, do not delete extra objects from the server"),
+		newExample("apply dev --prune-only", "only delete extra objects from the server, do not create/ update objects"),
+		newExample("apply dev,stage,prod --yes", "apply the dev, stage and prod environments in that order, stopping at the first failure"),
+		newExample("apply --all-envs --env-concurrency=3 -n", "show what apply would do for all environments, three at a time"),
+		newExample("apply dev --lock-timeout=10m", "lock the dev environment before making changes, waiting up to 10 minutes for a lock held by someone else"),
 	)
 }
 
@@ -64,6 +84,15 @@ func showExamples() string {
 		newExample("show dev -k deployment -k configmap", "show only deployments and config maps"),
 		newExample("show dev -K secret", "show all objects except secrets"),
 		newExample("show dev -O", "list all objects for the dev environment"),
+		newExample("show dev --export out/dev", "write every object for the dev environment to its own file under out/dev"),
+		newExample("show dev --component-dir ../experiments/redis -c redis", "show objects for a component that is not part of the app yet"),
+	)
+}
+
+func argoRenderExamples() string {
+	return exampleHelp(
+		newExample("argo-render", "render the environment named by ARGOCD_ENV_QBEC_ENV, using the app tag and components from ARGOCD_ENV_QBEC_APP_TAG, ARGOCD_ENV_QBEC_COMPONENTS and ARGOCD_ENV_QBEC_EXCLUDE_COMPONENTS"),
+		newExample("argo-render dev", "render the dev environment"),
 	)
 }
 
@@ -71,6 +100,31 @@ func evalExamples() string {
 	return exampleHelp(
 		newExample("eval some/file.jsonnet --vm:ext-str foo=bar", "evaluate the supplied file using simple jsonnet semantics, does not require qbec.yaml"),
 		newExample("eval some/file.jsonnet --env dev", "evaluate the supplied file using qbec semantics, automatically setting all external variables like qbec would set them for the environment"),
+		newExample(`eval dev -e "(import 'params.libsonnet').components.redis"`, "evaluate an expression for the dev environment, with external variables, library paths and data sources set up like they are for components"),
+	)
+}
+
+func unitExamples() string {
+	return exampleHelp(
+		newExample("unit", "run all tests in *_test.jsonnet files under the qbec root, excluding the vendor directory"),
+		newExample("unit lib --run '^merge'", "run tests under the lib directory whose names start with merge"),
+		newExample("unit --env dev -v", "run all tests with external variables set for the dev environment and show passing tests"),
+	)
+}
+
+func importsExamples() string {
+	return exampleHelp(
+		newExample("alpha imports web", "list all files and data sources used by the web component"),
+		newExample("alpha imports lib/util.libsonnet -o json", "show the import graph of a library file as JSON"),
+		newExample("alpha imports web -o dot | dot -Tsvg > web.svg", "render the import graph of the web component as an SVG image"),
+	)
+}
+
+func changedExamples() string {
+	return exampleHelp(
+		newExample("changed --from origin/main", "list components affected by changes since the main branch"),
+		newExample("changed --from HEAD~1 --env dev", "list components affected by the last commit in the dev environment"),
+		newExample("changed --from origin/main -o json", "show changed files and affected components for every environment as JSON"),
 	)
 }
 
@@ -78,6 +132,7 @@ func fmtExamples() string {
 	return exampleHelp(
 		newExample("fmt -w", "format all jsonnet and libsonnet files in-place"),
 		newExample("fmt -e", "check if all jsonnet and libsonnet files are formatted well. Non zero exit code in case a unformatted file is found"),
+		newExample("fmt --check", "same as fmt -e, suitable for CI pipelines"),
 		newExample("fmt --type=json", "format all json files to stdout"),
 		newExample("fmt somefolder file1.jsonnet file2.libsonnet", "format all jsonnet and libsonnet= files in the somefolder, file1.jsonnet and file2.libsonnet files to stdout"),
 		newExample("fmt -t=yaml", "format all yaml files to stdout"),
@@ -104,18 +159,42 @@ func deleteExamples() string {
 	)
 }
 
+func gcPreviewExamples() string {
+	return exampleHelp(
+		newExample("gc-preview dev", "list all remote objects that would be garbage collected for the dev environment"),
+		newExample("gc-preview dev --app-tag feature1 -o json", "list garbage collection candidates for the feature1 tag",
+			"in JSON format"),
+		newExample("gc-preview dev -c redis -k secret", "list secrets of the redis component that would be garbage collected"),
+	)
+}
+
+func logsExamples() string {
+	return exampleHelp(
+		newExample("logs dev -c redis -f", "stream logs for all pods of workloads in the redis component for the dev environment"),
+		newExample("logs dev -k deployment --tail=-1 --since=10m", "show all logs from the last 10 minutes for pods of deployments"),
+		newExample("logs dev --container sidecar", "show recent logs only for containers named sidecar"),
+	)
+}
+
 func diffExamples() string {
 	return exampleHelp(
 		newExample("diff dev", "show differences between local and remote objects for the dev environment"),
 		newExample("diff dev -c redis --show-deletes=false", "show differences for the redis component for the dev environment",
 			"ignore extra remote objects"),
 		newExample("diff dev -ignore-all-labels", "do not take labels into account when calculating the diff"),
+		newExample("diff dev --offline", "diff against the last applied configuration of objects, using list queries",
+			"instead of fetching every object"),
+		newExample("diff dev --snapshot dev.yaml", "diff against objects in a file produced by a previous show command"),
+		newExample("diff dev --error-on=drift", "exit with an error only when live objects have been changed in the cluster",
+			"since they were last applied"),
+		newExample(`diff dev --diff-program "dyff between --omit-header"`, "render differences for every object using dyff"),
 	)
 }
 
 func validateExamples() string {
 	return exampleHelp(
 		newExample("validate dev", "validate all objects for all components against the dev environment"),
+		newExample("validate dev --server-side", "validate all objects using a server-side dry-run that also runs admission webhooks"),
 	)
 }
 
@@ -134,6 +213,28 @@ func componentDiffExamples() string {
 	)
 }
 
+func componentGraphExamples() string {
+	return exampleHelp(
+		newExample("component graph", "print a graph of all environments and components in DOT format"),
+		newExample("component graph dev prod | dot -Tsvg > graph.svg", "render a graph for the dev and prod environments using graphviz"),
+		newExample("component graph -o json", "print the graph as JSON"),
+	)
+}
+
+func statusExamples() string {
+	return exampleHelp(
+		newExample("status dev", "list all objects for the dev environment on the server along with their readiness"),
+		newExample("status dev -k deployment -o json", "show the status of deployments in JSON format"),
+	)
+}
+
+func dataSourceTestExamples() string {
+	return exampleHelp(
+		newExample("datasource test dev", "test all data sources for the dev environment using the paths configured in dsTestPaths"),
+		newExample("datasource test dev vault --path /secret/foo", "test the vault data source by resolving the path /secret/foo"),
+	)
+}
+
 func paramListExamples() string {
 	return exampleHelp(
 		newExample("param list dev", "list all parameters for the dev environment"),
@@ -149,6 +250,14 @@ func paramDiffExamples() string {
 	)
 }
 
+func paramExplainExamples() string {
+	return exampleHelp(
+		newExample("param explain prod", "list parameter values for prod and whether each value comes from the baseline, base properties,",
+			"environment-specific parameters or environment properties"),
+		newExample("param explain prod -c redis -o yaml", "explain parameters for the redis component in YAML format"),
+	)
+}
+
 func envListExamples() string {
 	return exampleHelp(
 		newExample("env list", "list all environment names, one per line in sorted order"),
@@ -156,9 +265,44 @@ func envListExamples() string {
 	)
 }
 
+func envCurrentExamples() string {
+	return exampleHelp(
+		newExample("env current", "print the name of the environment that corresponds to the current kubeconfig context"),
+		newExample("env current -o json", "print environment and context details in JSON format, (use -o yaml for YAML)"),
+		newExample("show auto", "show objects for the environment that corresponds to the current kubeconfig context"),
+	)
+}
+
 func envVarsExamples() string {
 	return exampleHelp(
 		newExample("env vars <env>", "print kubernetes variables for env in eval format, run as `eval $(qbec env vars env)`"),
 		newExample("env vars -o json", "print kubernetes variables for env in JSON format, (use -o yaml for YAML)"),
 	)
 }
+
+func admissionExamples() string {
+	return exampleHelp(
+		newExample("alpha admission dev", "show fields that the server and admission webhooks would set or change for objects in the dev environment"),
+		newExample("alpha admission dev -c redis -o json", "show changes for objects of the redis component in JSON format, (use -o yaml for YAML)"),
+	)
+}
+
+func envAddExamples() string {
+	return exampleHelp(
+		newExample("env add stage --server https://stage-server --default-namespace my-ns", "add the stage environment to qbec.yaml"),
+		newExample("env add kind --context kind-kind --file envs/local.yaml", "add an environment that uses a kubeconfig context to an environment file"),
+	)
+}
+
+func envSetExamples() string {
+	return exampleHelp(
+		newExample("env set stage --default-namespace other-ns --property tier=gold", "change the default namespace and set a property for the stage environment"),
+		newExample("env set stage --property tier=", "remove the tier property from the stage environment"),
+	)
+}
+
+func envRemoveExamples() string {
+	return exampleHelp(
+		newExample("env remove stage", "remove the stage environment from qbec.yaml"),
+	)
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/splunk/qbec/internal/cmd"
 	"github.com/splunk/qbec/internal/fswalk"
+	"github.com/splunk/qbec/internal/jb"
 	"github.com/splunk/qbec/internal/sio"
 )
 
@@ -62,6 +63,12 @@ func doFmt(args []string, config *fmtCommandConfig, failFast *bool) error {
 		config.files = args
 	} else {
 		config.files = []string{"."}
+		// do not format libraries vendored by jsonnet-bundler when formatting the whole tree
+		vendor := jb.DefaultVendorDir
+		if app := config.App(); app != nil {
+			vendor = app.VendorDir()
+		}
+		config.opts.Exclusions = append(config.opts.Exclusions, vendor)
 	}
 	config.formatTypes = make(map[string]bool)
 	isSupported := func(s string) bool {
@@ -96,6 +103,7 @@ func newFmtCommand(cp ctxProvider) *cobra.Command {
 
 	config := fmtCommandConfig{}
 	c.Flags().BoolVarP(&config.check, "check-errors", "e", false, "check for unformatted files")
+	c.Flags().BoolVar(&config.check, "check", false, "alias for --check-errors")
 	c.Flags().BoolVarP(&config.write, "write", "w", false, "write result to (source) file instead of stdout")
 	c.Flags().StringSliceVarP(&config.specifiedTypes, "type", "t", []string{"jsonnet"}, "file types that should be formatted")
 	excludeFn := fswalk.AddExclusions(c.Flags())
@@ -141,7 +149,7 @@ func processFile(config *fmtCommandConfig, filename string, in io.Reader, out io
 			if config.check {
 				fmt.Println(outErr)
 			} else {
-				_, _ = fmt.Fprintln(os.Stderr, sio.ErrorString(outErr.Error()))
+				sio.Errorln(outErr)
 			}
 		}
 	}()
@@ -249,6 +249,22 @@ func TestFmtCommand(t *testing.T) {
 	s.assertOutputLineMatch(regexp.MustCompile(`      - service2`))
 }
 
+func TestFmtCheckSkipsVendor(t *testing.T) {
+	t.Run("tree", func(t *testing.T) {
+		s := newCustomScaffold(t, "testdata/projects/fmt-vendor")
+		defer s.reset()
+		err := s.executeCommand("fmt", "--check")
+		require.NoError(t, err)
+	})
+	t.Run("explicit", func(t *testing.T) {
+		s := newCustomScaffold(t, "testdata/projects/fmt-vendor")
+		defer s.reset()
+		err := s.executeCommand("fmt", "--check", "vendor")
+		require.Error(t, err)
+		assert.Equal(t, "1 error encountered", err.Error())
+	})
+}
+
 func TestInvalidFormatType(t *testing.T) {
 	s := newScaffold(t)
 	defer s.reset()
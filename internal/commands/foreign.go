@@ -0,0 +1,134 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/splunk/qbec/internal/sio"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// foreignKey identifies an object independent of its API version.
+type foreignKey struct {
+	gk        schema.GroupKind
+	namespace string
+	name      string
+}
+
+// listForeign returns the objects managed by qbec for other apps, tags or environments that have the same types
+// and are in the same namespaces as the supplied objects.
+func listForeign(ctx context.Context, envCtx cmd.EnvContext, client cmd.KubeClient, objects []model.K8sLocalObject) ([]model.K8sQbecMeta, error) {
+	if len(objects) == 0 {
+		return nil, nil
+	}
+	lister, scope, err := newRemoteLister(ctx, client, objects, envCtx.App().DefaultNamespace(envCtx.Env()))
+	if err != nil {
+		return nil, err
+	}
+	kinds := map[schema.GroupKind]bool{}
+	for _, o := range objects {
+		kinds[o.GroupVersionKind().GroupKind()] = true
+	}
+	lister.start(ctx, remote.ListQueryConfig{
+		Application:        envCtx.App().Name(),
+		Tag:                envCtx.App().Tag(),
+		Environment:        envCtx.Env(),
+		KindFilter:         func(gvk schema.GroupVersionKind) bool { return kinds[gvk.GroupKind()] },
+		ListQueryScope:     scope,
+		ClusterScopedLists: len(scope.Namespaces) > 1 && envCtx.App().ClusterScopedLists(),
+		Limit:              envCtx.ListPageSize(),
+		Concurrency:        envCtx.ListConcurrency(),
+		Observer:           envCtx.ListObserver(),
+		Foreign:            true,
+	})
+	return lister.objects()
+}
+
+func foreignOwner(o model.QbecMeta) string {
+	ret := fmt.Sprintf("app=%s", o.Application())
+	if o.Tag() != "" {
+		ret += fmt.Sprintf(", tag=%s", o.Tag())
+	}
+	return ret + fmt.Sprintf(", environment=%s", o.Environment())
+}
+
+// reportForeign lists objects managed by qbec for other apps, tags or environments that may conflict with the
+// supplied objects. Foreign objects with the same kind, namespace and name as one of the supplied objects are
+// reported as conflicts.
+func reportForeign(ctx context.Context, envCtx cmd.EnvContext, client cmd.KubeClient, objects []model.K8sLocalObject) error {
+	log := sio.For(ctx)
+	foreign, err := listForeign(ctx, envCtx, client, objects)
+	if err != nil {
+		return err
+	}
+	if len(foreign) == 0 {
+		log.Noticeln("no foreign objects found")
+		return nil
+	}
+	defaultNs := envCtx.App().DefaultNamespace(envCtx.Env())
+	keyFor := func(o model.K8sMeta) (foreignKey, error) {
+		ns := ""
+		namespaced, err := client.IsNamespaced(o.GroupVersionKind())
+		if err != nil {
+			return foreignKey{}, err
+		}
+		if namespaced {
+			ns = o.GetNamespace()
+			if ns == "" {
+				ns = defaultNs
+			}
+		}
+		return foreignKey{gk: o.GroupVersionKind().GroupKind(), namespace: ns, name: o.GetName()}, nil
+	}
+	local := map[foreignKey]bool{}
+	for _, o := range objects {
+		k, err := keyFor(o)
+		if err != nil {
+			continue // unknown types are not listed
+		}
+		local[k] = true
+	}
+	var conflicts, others []string
+	for _, o := range foreign {
+		k, err := keyFor(o)
+		if err != nil {
+			return err
+		}
+		line := fmt.Sprintf("%s (%s)", client.DisplayName(o), foreignOwner(o))
+		if local[k] {
+			conflicts = append(conflicts, line)
+		} else {
+			others = append(others, line)
+		}
+	}
+	sort.Strings(conflicts)
+	sort.Strings(others)
+	log.Noticef("%d foreign object(s) managed by other apps, tags or environments, not modified:\n", len(foreign))
+	for _, line := range conflicts {
+		log.Warnf("conflict: %s\n", line)
+	}
+	for _, line := range others {
+		log.Noticef("\t%s\n", line)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/objsort"
+)
+
+const (
+	gcActionDelete              = "delete"
+	gcActionSkip                = "skip"
+	gcActionDeleteWithNamespace = "delete-with-namespace"
+)
+
+// gcCandidate is a remote object that would be garbage collected.
+type gcCandidate struct {
+	Action     string `json:"action"`
+	Component  string `json:"component"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+type gcPreviewCommandConfig struct {
+	cmd.AppContext
+	format     string
+	filterFunc func() (model.Filters, error)
+	gcScope    gcScope
+}
+
+func listGCCandidates(candidates []gcCandidate, format string, w io.Writer) error {
+	switch format {
+	case "":
+		fmt.Fprintf(w, "%-22s %-30s %-30s %-40s %s\n", "ACTION", "COMPONENT", "KIND", "NAME", "NAMESPACE")
+		for _, c := range candidates {
+			fmt.Fprintf(w, "%-22s %-30s %-30s %-40s %s\n", c.Action, c.Component, c.Kind, c.Name, c.Namespace)
+		}
+		return nil
+	case "yaml":
+		b, err := yaml.Marshal(candidates)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "---")
+		fmt.Fprintf(w, "%s\n", b)
+		return nil
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(candidates)
+	default:
+		return cmd.NewUsageError(fmt.Sprintf("listGCCandidates: unsupported format %q", format))
+	}
+}
+
+func doGCPreview(ctx context.Context, args []string, config gcPreviewCommandConfig) error {
+	if len(args) != 1 {
+		return cmd.NewUsageError(fmt.Sprintf("exactly one environment required, but provided: %q", args))
+	}
+	env, err := config.ResolveEnv(args[0])
+	if err != nil {
+		return err
+	}
+	if env == model.Baseline {
+		return cmd.NewUsageError("cannot garbage collect baseline environment, use a real environment")
+	}
+	if config.format != "" && config.format != "json" && config.format != "yaml" {
+		return cmd.NewUsageError(fmt.Sprintf("invalid output format: %q", config.format))
+	}
+	fp, err := config.filterFunc()
+	if err != nil {
+		return err
+	}
+	envCtx, err := config.EnvContext(env)
+	if err != nil {
+		return err
+	}
+	client, err := envCtx.Client()
+	if err != nil {
+		return err
+	}
+	lister, retainObjects, err := startRemoteList(ctx, envCtx, client, fp, remoteListOptions{gc: config.gcScope})
+	if err != nil {
+		return err
+	}
+	deletions, err := lister.deletions(retainObjects, fp.Match)
+	if err != nil {
+		return err
+	}
+	deletions = withoutHookObjects(deletions)
+	deletions = objsort.SortMeta(deletions, sortConfig(client.IsNamespaced))
+
+	dp := newDeletePolicy(client.IsNamespaced, config.App().DefaultNamespace(env))
+	deletions, implied := collapseNamespaceDeletions(deletions, dp)
+
+	candidate := func(ob model.K8sQbecMeta, action string) gcCandidate {
+		return gcCandidate{
+			Action:     action,
+			Component:  ob.Component(),
+			APIVersion: ob.GroupVersionKind().GroupVersion().String(),
+			Kind:       ob.GetKind(),
+			Namespace:  ob.GetNamespace(),
+			Name:       ob.GetName(),
+		}
+	}
+	candidates := []gcCandidate{}
+	// list in the order in which apply would delete objects
+	for i := len(deletions) - 1; i >= 0; i-- {
+		ob := deletions[i]
+		action := gcActionDelete
+		if dp.disableDelete(ob) {
+			action = gcActionSkip
+		}
+		candidates = append(candidates, candidate(ob, action))
+	}
+	for _, ob := range implied {
+		candidates = append(candidates, candidate(ob, gcActionDeleteWithNamespace))
+	}
+	return listGCCandidates(candidates, config.format, config.Stdout())
+}
+
+func newGCPreviewCommand(cp ctxProvider) *cobra.Command {
+	c := &cobra.Command{
+		Use:     "gc-preview [-o <format>] <environment>",
+		Short:   "list remote objects that would be garbage collected by apply, without changing anything",
+		Example: gcPreviewExamples(),
+	}
+
+	config := gcPreviewCommandConfig{
+		filterFunc: addFilterParams(c, true),
+	}
+	c.Flags().StringVarP(&config.format, "format", "o", "", "use json|yaml to display machine readable output")
+	gcScopeFn := addGCScopeFlags(c)
+
+	c.RunE = func(c *cobra.Command, args []string) error {
+		config.AppContext = cp()
+		var err error
+		config.gcScope, err = gcScopeFn()
+		if err != nil {
+			return cmd.WrapError(err)
+		}
+		return cmd.WrapError(doGCPreview(c.Context(), args, config))
+	}
+	return c
+}
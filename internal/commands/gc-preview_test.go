@@ -0,0 +1,162 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGCPreviewBasic(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	s.client.listFunc = stdLister
+	s.client.deleteFunc = func(ctx context.Context, obj model.K8sMeta, opts remote.DeleteOptions) (*remote.SyncResult, error) {
+		t.Fatalf("unexpected delete of %s", obj.GetName())
+		return nil, nil
+	}
+	err := s.executeCommand("gc-preview", "dev")
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`^ACTION\s+COMPONENT\s+KIND\s+NAME\s+NAMESPACE$`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^delete\s+service2\s+Deployment\s+svc2-previous-deploy\s+bar-system$`))
+	s.assertOutputLineNoMatch(regexp.MustCompile(`svc2-deploy`))
+}
+
+func TestGCPreviewJSON(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	s.client.listFunc = stdLister
+	err := s.executeCommand("gc-preview", "dev", "-o", "json")
+	require.NoError(t, err)
+	var data []gcCandidate
+	require.NoError(t, s.jsonOutput(&data))
+	assert.EqualValues(t, []gcCandidate{
+		{
+			Action:     gcActionDelete,
+			Component:  "service2",
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Namespace:  "bar-system",
+			Name:       "svc2-previous-deploy",
+		},
+	}, data)
+}
+
+func TestGCPreviewFilter(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	s.client.listFunc = stdLister
+	err := s.executeCommand("gc-preview", "dev", "-c", "service1", "-o", "yaml")
+	require.NoError(t, err)
+	out, err := s.yamlOutput()
+	require.NoError(t, err)
+	assert.EqualValues(t, []interface{}{[]interface{}{}}, out)
+}
+
+func TestGCPreviewScope(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		namespaces []string
+		cluster    bool
+		owned      bool
+		candidates int
+	}{
+		{name: "namespace", args: []string{"--gc-namespace", "bar-system"}, namespaces: []string{"bar-system"}, candidates: 1},
+		{name: "other namespace", args: []string{"--gc-namespace", "kube-system"}, namespaces: []string{"kube-system"}},
+		{name: "kind", args: []string{"--gc-kind", "deployments"}, namespaces: []string{"bar-system", "default"}, cluster: true, candidates: 1},
+		{name: "other kind", args: []string{"--gc-kind", "secret"}, namespaces: []string{"bar-system", "default"}, cluster: true},
+		{name: "owned", args: []string{"--gc-include-owned"}, namespaces: []string{"bar-system", "default"}, cluster: true, owned: true, candidates: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newScaffold(t)
+			defer s.reset()
+			var captured remote.ListQueryConfig
+			s.client.listFunc = func(ctx context.Context, scope remote.ListQueryConfig) (remote.Collection, error) {
+				captured = scope
+				coll, err := stdLister(ctx, scope)
+				if err != nil {
+					return nil, err
+				}
+				// honor the kind filter like the remote client does
+				var remove []model.K8sQbecMeta
+				for _, o := range coll.ToList() {
+					if !scope.KindFilter(o.GroupVersionKind()) {
+						remove = append(remove, o)
+					}
+				}
+				return coll, coll.Remove(remove)
+			}
+			err := s.executeCommand(append([]string{"gc-preview", "dev", "-o", "json"}, test.args...)...)
+			require.NoError(t, err)
+			assert.Equal(t, test.namespaces, captured.Namespaces)
+			assert.Equal(t, test.cluster, captured.ClusterObjects)
+			assert.Equal(t, test.owned, captured.IncludeOwned)
+			var data []gcCandidate
+			require.NoError(t, s.jsonOutput(&data))
+			assert.Equal(t, test.candidates, len(data))
+		})
+	}
+}
+
+func TestGCPreviewNegative(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		asserter func(s *scaffold, err error)
+	}{
+		{
+			name: "no env",
+			args: []string{"gc-preview"},
+			asserter: func(s *scaffold, err error) {
+				require.Error(t, err)
+				assert.Equal(t, "exactly one environment required, but provided: []", err.Error())
+			},
+		},
+		{
+			name: "baseline",
+			args: []string{"gc-preview", "_"},
+			asserter: func(s *scaffold, err error) {
+				require.Error(t, err)
+				assert.Equal(t, "cannot garbage collect baseline environment, use a real environment", err.Error())
+			},
+		},
+		{
+			name: "bad format",
+			args: []string{"gc-preview", "dev", "-o", "table"},
+			asserter: func(s *scaffold, err error) {
+				require.Error(t, err)
+				assert.Equal(t, `invalid output format: "table"`, err.Error())
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newScaffold(t)
+			defer s.reset()
+			err := s.executeCommand(test.args...)
+			test.asserter(s, err)
+		})
+	}
+}
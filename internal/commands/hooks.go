@@ -0,0 +1,228 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/eval"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/splunk/qbec/internal/rollout"
+	"github.com/splunk/qbec/internal/sio"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// hook phases
+const (
+	hookPreApply  = "preApply"
+	hookPostApply = "postApply"
+)
+
+// hookComponentPrefix is the prefix of the component name set on objects produced by jsonnet hooks.
+const hookComponentPrefix = "hook."
+
+func hookComponent(phase, name string) string {
+	return hookComponentPrefix + phase + "." + name
+}
+
+// withoutHookObjects removes objects produced by hooks from the supplied list. Hook objects are re-created every
+// time a hook is run and are never garbage collected by apply.
+func withoutHookObjects(list []model.K8sQbecMeta) []model.K8sQbecMeta {
+	var ret []model.K8sQbecMeta
+	for _, o := range list {
+		if strings.HasPrefix(o.Component(), hookComponentPrefix) {
+			continue
+		}
+		ret = append(ret, o)
+	}
+	return ret
+}
+
+// postApplyInput is the JSON document supplied on the standard input of post-apply exec hooks.
+type postApplyInput struct {
+	App         string      `json:"app"`
+	Tag         string      `json:"tag,omitempty"`
+	Environment string      `json:"environment"`
+	Stats       *applyStats `json:"stats"`
+}
+
+// hook deletion polling settings, overridden in tests
+var (
+	hookDeletePollInterval = 500 * time.Millisecond
+	hookDeleteTimeout      = 2 * time.Minute
+)
+
+// hookRunner runs pre- and post-apply hooks for an environment.
+type hookRunner struct {
+	envCtx      cmd.EnvContext
+	client      cmd.KubeClient
+	dryRun      bool
+	waitTimeout time.Duration
+}
+
+func (h *hookRunner) run(ctx context.Context, phase string, hooks []model.Hook, input []byte) error {
+	for _, hook := range hooks {
+		if h.dryRun {
+			sio.For(ctx).Noticef("[dry-run] run %s hook %s\n", phase, hook.Name)
+			continue
+		}
+		sio.For(ctx).Noticef("run %s hook %s\n", phase, hook.Name)
+		var err error
+		if hook.Exec != nil {
+			err = h.runExec(ctx, phase, hook, input)
+		} else {
+			err = h.runJsonnet(ctx, phase, hook)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "%s hook %s", phase, hook.Name)
+		}
+	}
+	return nil
+}
+
+func (h *hookRunner) runExec(ctx context.Context, phase string, hook model.Hook, input []byte) error {
+	app := h.envCtx.App()
+	c := exec.CommandContext(ctx, hook.Exec.Command, hook.Exec.Args...)
+	env := append(os.Environ(),
+		"QBEC_APP="+app.Name(),
+		"QBEC_TAG="+app.Tag(),
+		"QBEC_ENV="+h.envCtx.Env(),
+		"QBEC_DEFAULT_NS="+app.DefaultNamespace(h.envCtx.Env()),
+		"QBEC_HOOK="+hook.Name,
+		"QBEC_HOOK_PHASE="+phase,
+	)
+	var keys []string
+	for k := range hook.Exec.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+hook.Exec.Env[k])
+	}
+	c.Env = env
+	// commands are run from the app root so that relative paths in the hook resolve the same way
+	// regardless of the working directory
+	c.Dir = app.Root()
+	c.Stdin = bytes.NewReader(input)
+	out := sio.For(ctx).NewLineWriter(hook.Name)
+	defer out.Flush()
+	c.Stdout = out
+	c.Stderr = out
+	return c.Run()
+}
+
+func (h *hookRunner) runJsonnet(ctx context.Context, phase string, hook model.Hook) error {
+	component := model.Component{
+		Name:  hookComponent(phase, hook.Name),
+		Files: []string{h.envCtx.App().ResolvePath(hook.Jsonnet)},
+	}
+	objects, err := eval.Components([]model.Component{component}, h.envCtx.EvalContext(false), h.envCtx.ObjectProducer())
+	if err != nil {
+		return cmd.WithCode(cmd.ErrorCodeEval, err)
+	}
+	if err := checkWaitStatus(objects); err != nil {
+		return err
+	}
+	// objects such as jobs cannot be updated, delete existing objects before creating new ones.
+	for _, o := range objects {
+		if err := h.deleteExisting(ctx, o); err != nil {
+			return err
+		}
+	}
+	var waitObjects []model.K8sMeta
+	for _, o := range objects {
+		res, err := h.client.Sync(ctx, o, remote.SyncOptions{DisableUpdateFn: func(model.K8sMeta) bool { return false }})
+		if err != nil {
+			return err
+		}
+		if res.GeneratedName != "" {
+			o = nameWrap{name: res.GeneratedName, K8sLocalObject: o}
+		}
+		sio.For(ctx).Noticef("create %s\n", h.client.DisplayName(o))
+		waitObjects = append(waitObjects, metaWrap{K8sMeta: o})
+	}
+	defaultNs := h.envCtx.App().DefaultNamespace(h.envCtx.Env())
+	return waitError(applyWaitFn(waitObjects,
+		func(obj model.K8sMeta) (watch.Interface, error) {
+			return waitWatcher(ctx, h.client.ResourceInterface, nsWrap{K8sMeta: obj, ns: defaultNs})
+		},
+		rollout.WaitOptions{
+			Listener:          &waitListener{log: sio.For(ctx), displayNameFn: h.client.DisplayName},
+			Timeout:           h.waitTimeout,
+			StatusExpressions: h.envCtx.App().WaitStatusExpressions(),
+		},
+	))
+}
+
+// deleteExisting deletes the supplied object if it exists and waits for it to be gone.
+func (h *hookRunner) deleteExisting(ctx context.Context, o model.K8sLocalObject) error {
+	if o.GetName() == "" {
+		return nil
+	}
+	res, err := h.client.Delete(ctx, o, remote.DeleteOptions{DisableDeleteFn: func(model.K8sMeta) bool { return false }})
+	if err != nil {
+		return err
+	}
+	if res.Type != remote.SyncDeleted {
+		return nil
+	}
+	name := h.client.DisplayName(o)
+	sio.For(ctx).Noticef("delete previous %s\n", name)
+	deadline := time.Now().Add(hookDeleteTimeout)
+	for {
+		_, err := h.client.Get(ctx, o)
+		if err == remote.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "get %s", name)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for %s to be deleted", name)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(hookDeletePollInterval):
+		}
+	}
+}
+
+// postApplyHookInput returns the JSON input for post-apply hooks.
+func postApplyHookInput(envCtx cmd.EnvContext, stats *applyStats) ([]byte, error) {
+	b, err := json.MarshalIndent(postApplyInput{
+		App:         envCtx.App().Name(),
+		Tag:         envCtx.App().Tag(),
+		Environment: envCtx.Env(),
+		Stats:       stats,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
@@ -0,0 +1,162 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/splunk/qbec/internal/rollout"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func setupHookScaffold(t *testing.T) (*scaffold, *[]string, string) {
+	out := filepath.Join(t.TempDir(), "post-apply.out")
+	t.Setenv("HOOK_OUT", out)
+	origWait, origPoll := applyWaitFn, hookDeletePollInterval
+	applyWaitFn = func(objects []model.K8sMeta, wp rollout.WatchProvider, opts rollout.WaitOptions) (finalErr error) {
+		return nil
+	}
+	hookDeletePollInterval = time.Millisecond
+	t.Cleanup(func() { applyWaitFn, hookDeletePollInterval = origWait, origPoll })
+
+	s := newCustomScaffold(t, "testdata/projects/hooks")
+	var ops []string
+	deleted := false
+	s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+		ops = append(ops, "sync "+s.client.DisplayName(obj)+" "+obj.Component())
+		return &remote.SyncResult{Type: remote.SyncCreated}, nil
+	}
+	s.client.deleteFunc = func(ctx context.Context, obj model.K8sMeta, opts remote.DeleteOptions) (*remote.SyncResult, error) {
+		ops = append(ops, "delete "+s.client.DisplayName(obj))
+		deleted = true
+		return &remote.SyncResult{Type: remote.SyncDeleted}, nil
+	}
+	s.client.getFunc = func(ctx context.Context, obj model.K8sMeta) (*unstructured.Unstructured, error) {
+		if deleted {
+			deleted = false
+			return &unstructured.Unstructured{}, nil
+		}
+		return nil, remote.ErrNotFound
+	}
+	return s, &ops, out
+}
+
+func TestApplyHooks(t *testing.T) {
+	s, ops, out := setupHookScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("apply", "local", "--gc=false")
+	require.NoError(t, err)
+	a := assert.New(t)
+	a.Equal([]string{
+		"delete Job::migrate",
+		"sync Job::migrate hook.preApply.migrate",
+		"sync ConfigMap::app-config app",
+	}, *ops)
+	s.assertErrorLineMatch(regexp.MustCompile(`run preApply hook migrate`))
+	s.assertErrorLineMatch(regexp.MustCompile(`run postApply hook notify`))
+
+	b, err := os.ReadFile(out)
+	require.NoError(t, err)
+	lines := strings.SplitN(strings.TrimSpace(string(b)), "\n", -1)
+	a.Equal("postApply local hello", lines[len(lines)-1])
+	var input postApplyInput
+	require.NoError(t, json.Unmarshal([]byte(strings.Join(lines[:len(lines)-1], "\n")), &input))
+	a.Equal("hooks", input.App)
+	a.Equal("local", input.Environment)
+	a.Equal([]string{"ConfigMap::app-config"}, input.Stats.Created)
+}
+
+func TestApplyHooksDryRun(t *testing.T) {
+	s, ops, out := setupHookScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("apply", "local", "--gc=false", "-n")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sync ConfigMap::app-config app"}, *ops)
+	s.assertErrorLineMatch(regexp.MustCompile(`\[dry-run\] run preApply hook migrate`))
+	s.assertErrorLineMatch(regexp.MustCompile(`\[dry-run\] run postApply hook notify`))
+	_, err = os.Stat(out)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestApplySkipHooks(t *testing.T) {
+	s, ops, out := setupHookScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("apply", "local", "--gc=false", "--skip-hooks")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sync ConfigMap::app-config app"}, *ops)
+	_, err = os.Stat(out)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestWithoutHookObjects(t *testing.T) {
+	objs := []model.K8sQbecMeta{
+		&basicObject{objectKey: objectKey{gvk: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, name: "cm"}, component: "app"},
+		&basicObject{objectKey: objectKey{gvk: schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}, name: "migrate"}, component: hookComponent(hookPreApply, "migrate")},
+	}
+	ret := withoutHookObjects(objs)
+	require.Len(t, ret, 1)
+	assert.Equal(t, "cm", ret[0].GetName())
+}
+
+func TestHooksRunFromAppRoot(t *testing.T) {
+	s, ops, out := setupHookScaffold(t)
+	defer s.reset()
+	root, err := os.Getwd()
+	require.NoError(t, err)
+	reset := setPwd(t, t.TempDir())
+	defer reset()
+
+	app, err := model.NewApp(filepath.Join(root, "qbec.yaml"), nil, "")
+	require.NoError(t, err)
+	c := &cobra.Command{Use: "qbec-test"}
+	ctxFn := cmd.NewContext(c, cmd.Options{SkipConfirm: true})
+	require.NoError(t, c.ParseFlags(nil))
+	ctx, err := ctxFn()
+	require.NoError(t, err)
+	appCtx, err := ctx.AppContext(app)
+	require.NoError(t, err)
+	envCtx, err := appCtx.EnvContext("local")
+	require.NoError(t, err)
+
+	h := &hookRunner{envCtx: envCtx, client: s.client}
+	require.NoError(t, h.run(context.Background(), hookPreApply, app.PreApplyHooks(), nil))
+	assert.Equal(t, []string{"delete Job::migrate", "sync Job::migrate hook.preApply.migrate"}, *ops)
+
+	hooks := []model.Hook{{Name: "pwd", Exec: &model.ExecHook{Command: "sh", Args: []string{"-c", `pwd > "$HOOK_OUT"`}}}}
+	require.NoError(t, h.run(context.Background(), hookPostApply, hooks, nil))
+	b, err := os.ReadFile(out)
+	require.NoError(t, err)
+	wantDir, err := filepath.EvalSymlinks(root)
+	require.NoError(t, err)
+	gotDir, err := filepath.EvalSymlinks(strings.TrimSpace(string(b)))
+	require.NoError(t, err)
+	assert.Equal(t, wantDir, gotDir)
+}
@@ -0,0 +1,69 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"fmt"
+
+	"github.com/splunk/qbec/internal/model"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// scaledObjects tracks objects that are the scale targets of horizontal pod autoscalers produced by the app, such that
+// their replica counts, which are managed by the autoscalers, are not reverted to the ones in the source code.
+type scaledObjects struct {
+	defaultNS string
+	targets   map[string]bool
+}
+
+// newScaledObjects returns the scale targets of the horizontal pod autoscalers in the supplied objects. The target
+// of an autoscaler is in the same namespace as the autoscaler itself.
+func newScaledObjects(objects []model.K8sLocalObject, defaultNS string) *scaledObjects {
+	s := &scaledObjects{defaultNS: defaultNS, targets: map[string]bool{}}
+	for _, ob := range objects {
+		gvk := ob.GroupVersionKind()
+		if gvk.Group != "autoscaling" || gvk.Kind != "HorizontalPodAutoscaler" {
+			continue
+		}
+		ref, _, _ := unstructured.NestedStringMap(ob.ToUnstructured().Object, "spec", "scaleTargetRef")
+		if ref["kind"] == "" || ref["name"] == "" {
+			continue
+		}
+		gv, err := schema.ParseGroupVersion(ref["apiVersion"])
+		if err != nil {
+			continue
+		}
+		s.targets[s.key(gv.Group, ref["kind"], ob.GetNamespace(), ref["name"])] = true
+	}
+	return s
+}
+
+func (s *scaledObjects) key(group, kind, namespace, name string) string {
+	if namespace == "" {
+		namespace = s.defaultNS
+	}
+	return fmt.Sprintf("%s:%s:%s:%s", group, kind, namespace, name)
+}
+
+// isScaled returns true if the supplied object is the scale target of an autoscaler.
+func (s *scaledObjects) isScaled(ob model.K8sMeta) bool {
+	if s == nil || len(s.targets) == 0 {
+		return false
+	}
+	return s.targets[s.key(ob.GroupVersionKind().Group, ob.GetKind(), ob.GetNamespace(), ob.GetName())]
+}
@@ -0,0 +1,136 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestScaledObjects(t *testing.T) {
+	obj := func(apiVersion, kind, namespace, name string, spec map[string]interface{}) model.K8sLocalObject {
+		return model.NewK8sLocalObject(map[string]interface{}{
+			"apiVersion": apiVersion,
+			"kind":       kind,
+			"metadata": map[string]interface{}{
+				"namespace": namespace,
+				"name":      name,
+			},
+			"spec": spec,
+		}, model.LocalAttrs{App: "app", Component: "c", Env: "dev"})
+	}
+	hpa := func(namespace, apiVersion, kind, name string) model.K8sLocalObject {
+		return obj("autoscaling/v1", "HorizontalPodAutoscaler", namespace, "hpa-"+name, map[string]interface{}{
+			"scaleTargetRef": map[string]interface{}{"apiVersion": apiVersion, "kind": kind, "name": name},
+		})
+	}
+	s := newScaledObjects([]model.K8sLocalObject{
+		hpa("", "apps/v1", "Deployment", "web"),
+		hpa("other", "apps/v1", "StatefulSet", "db"),
+		hpa("", "", "", "bad"),
+	}, "default")
+	a := assert.New(t)
+	a.True(s.isScaled(obj("apps/v1", "Deployment", "default", "web", nil)))
+	a.True(s.isScaled(obj("apps/v1", "Deployment", "", "web", nil)))
+	a.True(s.isScaled(obj("apps/v1beta1", "Deployment", "", "web", nil)))
+	a.True(s.isScaled(obj("apps/v1", "StatefulSet", "other", "db", nil)))
+	a.False(s.isScaled(obj("apps/v1", "StatefulSet", "default", "db", nil)))
+	a.False(s.isScaled(obj("apps/v1", "Deployment", "other", "web", nil)))
+	a.False(s.isScaled(obj("v1", "ConfigMap", "", "web", nil)))
+	var none *scaledObjects
+	a.False(none.isScaled(obj("apps/v1", "Deployment", "", "web", nil)))
+}
+
+func TestDiffHPAReplicas(t *testing.T) {
+	// the live objects do not have a last applied configuration and have been scaled
+	get := func(ctx context.Context, obj model.K8sMeta) (*unstructured.Unstructured, error) {
+		live := obj.(model.K8sLocalObject).ToUnstructured().DeepCopy()
+		if _, ok, _ := unstructured.NestedFieldNoCopy(live.Object, "spec", "replicas"); ok {
+			require.NoError(t, unstructured.SetNestedField(live.Object, int64(5), "spec", "replicas"))
+		}
+		return live, nil
+	}
+	tests := []struct {
+		name    string
+		args    []string
+		changes interface{}
+	}{
+		{
+			name:    "default",
+			changes: []interface{}{"StatefulSet::db"},
+		},
+		{
+			name:    "disabled",
+			args:    []string{"--keep-hpa-replicas=false"},
+			changes: []interface{}{"Deployment::web", "StatefulSet::db"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newCustomScaffold(t, "testdata/projects/hpa")
+			defer s.reset()
+			s.client.getFunc = get
+			err := s.executeCommand(append([]string{"diff", "local", "--show-deletes=false"}, test.args...)...)
+			require.NoError(t, err)
+			assert.EqualValues(t, test.changes, s.outputStats()["changes"])
+		})
+	}
+}
+
+func TestApplyHPAReplicas(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		scaled map[string]bool
+	}{
+		{
+			name:   "default",
+			scaled: map[string]bool{"web": true},
+		},
+		{
+			name:   "disabled",
+			args:   []string{"--keep-hpa-replicas=false"},
+			scaled: map[string]bool{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newCustomScaffold(t, "testdata/projects/hpa")
+			defer s.reset()
+			var l sync.Mutex
+			scaled := map[string]bool{}
+			s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+				l.Lock()
+				defer l.Unlock()
+				if opts.KeepReplicasFn != nil && opts.KeepReplicasFn(obj) {
+					scaled[obj.GetName()] = true
+				}
+				return &remote.SyncResult{Type: remote.SyncUpdated}, nil
+			}
+			err := s.executeCommand(append([]string{"apply", "local", "--gc=false", "--wait-all=false"}, test.args...)...)
+			require.NoError(t, err)
+			assert.Equal(t, test.scaled, scaled)
+		})
+	}
+}
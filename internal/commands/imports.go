@@ -0,0 +1,183 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/eval"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/vm"
+)
+
+// importGraph is the import graph of one or more root files.
+type importGraph struct {
+	Roots   []string               `json:"roots"`   // the files whose imports are listed
+	Files   []string               `json:"files"`   // all files and data sources used by the roots, including the roots
+	Imports map[string][]vm.Import `json:"imports"` // imports keyed by the location of the importing file
+}
+
+// isDataSourceImport returns true if the supplied location refers to data source output.
+func isDataSourceImport(location string) bool {
+	return strings.HasPrefix(location, "data://")
+}
+
+// newImportGraph returns the import graph of the supplied files. Only jsonnet and JSON files are parsed for imports.
+func newImportGraph(files []string, ctx eval.BaseContext) (*importGraph, error) {
+	g := &importGraph{Roots: files, Imports: map[string][]vm.Import{}}
+	seen := map[string]bool{}
+	add := func(f string) {
+		if !seen[f] {
+			seen[f] = true
+			g.Files = append(g.Files, f)
+		}
+	}
+	for _, file := range files {
+		add(file)
+		switch filepath.Ext(file) {
+		case ".jsonnet", ".libsonnet", ".json":
+		default:
+			continue
+		}
+		imports, err := eval.Imports(file, ctx)
+		if err != nil {
+			return nil, err
+		}
+		for from, list := range imports {
+			g.Imports[from] = list
+			for _, imp := range list {
+				add(imp.FoundAt)
+			}
+		}
+	}
+	sort.Strings(g.Files)
+	return g, nil
+}
+
+func (g *importGraph) writeText(w io.Writer) {
+	for _, f := range g.Files {
+		fmt.Fprintln(w, f)
+	}
+}
+
+func (g *importGraph) writeDOT(w io.Writer) {
+	fmt.Fprintln(w, "digraph imports {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	for _, f := range g.Files {
+		style := "shape=box"
+		if isDataSourceImport(f) {
+			style = "shape=cylinder"
+		}
+		fmt.Fprintf(w, "  %q [%s];\n", f, style)
+	}
+	var froms []string
+	for from := range g.Imports {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+	for _, from := range froms {
+		for _, imp := range g.Imports[from] {
+			if imp.Code {
+				fmt.Fprintf(w, "  %q -> %q;\n", from, imp.FoundAt)
+			} else {
+				fmt.Fprintf(w, "  %q -> %q [style=dashed];\n", from, imp.FoundAt)
+			}
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
+
+type importsCommandConfig struct {
+	cmd.AppContext
+	env    string
+	format string
+}
+
+func doImports(args []string, config importsCommandConfig) error {
+	if len(args) != 1 {
+		return cmd.NewUsageError("exactly one component or file must be specified")
+	}
+	switch config.format {
+	case "text", "json", "dot":
+	default:
+		return cmd.NewUsageError(fmt.Sprintf("invalid output format %q, must be one of text, json or dot", config.format))
+	}
+	var files []string
+	for _, c := range config.App().AllComponents() {
+		if c.Name == args[0] {
+			files = c.Files
+			break
+		}
+	}
+	if files == nil {
+		if _, err := os.Stat(args[0]); err != nil {
+			return cmd.NewUsageError(fmt.Sprintf("%s is neither a component nor a file", args[0]))
+		}
+		files = []string{filepath.ToSlash(filepath.Clean(args[0]))}
+	}
+	env := config.env
+	if env != model.Baseline {
+		var err error
+		env, err = config.ResolveEnv(env)
+		if err != nil {
+			return err
+		}
+	}
+	envCtx, err := config.EnvContext(env)
+	if err != nil {
+		return err
+	}
+	g, err := newImportGraph(files, envCtx.EvalContext(cleanEvalMode).BaseContext)
+	if err != nil {
+		return cmd.WithCode(cmd.ErrorCodeEval, err)
+	}
+	switch config.format {
+	case "json":
+		encoder := json.NewEncoder(config.Stdout())
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(g)
+	case "dot":
+		g.writeDOT(config.Stdout())
+	default:
+		g.writeText(config.Stdout())
+	}
+	return nil
+}
+
+func newImportsCommand(cp ctxProvider) *cobra.Command {
+	c := &cobra.Command{
+		Use:     "imports <component>|<file>",
+		Short:   "list the files and data sources transitively imported by a component or jsonnet file",
+		Example: importsExamples(),
+	}
+	config := importsCommandConfig{}
+	c.Flags().StringVar(&config.env, "env", model.Baseline, "qbec environment whose variables are used to run data sources, defaults to the baseline environment")
+	c.Flags().StringVarP(&config.format, "format", "o", "text", "output format, one of text, json or dot")
+	c.RunE = func(c *cobra.Command, args []string) error {
+		config.AppContext = cp()
+		return cmd.WrapError(doImports(args, config))
+	}
+	return c
+}
@@ -0,0 +1,116 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/vm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportsComponent(t *testing.T) {
+	s := newCustomScaffold(t, "testdata/projects/imports")
+	defer s.reset()
+	err := s.executeCommand("alpha", "imports", "web")
+	require.NoError(t, err)
+	assert.Equal(t, `components/web.jsonnet
+data://echo/web
+lib/common.libsonnet
+lib/labels.libsonnet
+lib/motd.txt
+`, s.stdout())
+}
+
+func TestImportsFileJSON(t *testing.T) {
+	s := newCustomScaffold(t, "testdata/projects/imports")
+	defer s.reset()
+	err := s.executeCommand("alpha", "imports", "./lib/labels.libsonnet", "-o", "json")
+	require.NoError(t, err)
+	var g importGraph
+	require.NoError(t, json.Unmarshal([]byte(s.stdout()), &g))
+	a := assert.New(t)
+	a.Equal([]string{"lib/labels.libsonnet"}, g.Roots)
+	a.Equal([]string{"lib/common.libsonnet", "lib/labels.libsonnet"}, g.Files)
+	a.Equal([]vm.Import{{Path: "common.libsonnet", FoundAt: "lib/common.libsonnet", Code: true}}, g.Imports["lib/labels.libsonnet"])
+}
+
+func TestImportsDOT(t *testing.T) {
+	s := newCustomScaffold(t, "testdata/projects/imports")
+	defer s.reset()
+	err := s.executeCommand("alpha", "imports", "web", "-o", "dot")
+	require.NoError(t, err)
+	a := assert.New(t)
+	a.Contains(s.stdout(), `"data://echo/web" [shape=cylinder];`)
+	a.Contains(s.stdout(), `"components/web.jsonnet" -> "lib/labels.libsonnet";`)
+	a.Contains(s.stdout(), `"components/web.jsonnet" -> "lib/motd.txt" [style=dashed];`)
+}
+
+func TestImportsStaticComponent(t *testing.T) {
+	s := newCustomScaffold(t, "testdata/projects/imports")
+	defer s.reset()
+	err := s.executeCommand("alpha", "imports", "static")
+	require.NoError(t, err)
+	assert.Equal(t, "components/static.yaml\n", s.stdout())
+}
+
+func TestImportsNegative(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		code   string
+		errMsg string
+	}{
+		{
+			name:   "no-args",
+			args:   []string{"alpha", "imports"},
+			code:   cmd.ErrorCodeUsage,
+			errMsg: "exactly one component or file must be specified",
+		},
+		{
+			name:   "bad-format",
+			args:   []string{"alpha", "imports", "web", "-o", "yaml"},
+			code:   cmd.ErrorCodeUsage,
+			errMsg: `invalid output format "yaml", must be one of text, json or dot`,
+		},
+		{
+			name:   "unknown",
+			args:   []string{"alpha", "imports", "foo"},
+			code:   cmd.ErrorCodeUsage,
+			errMsg: "foo is neither a component nor a file",
+		},
+		{
+			name:   "bad-env",
+			args:   []string{"alpha", "imports", "web", "--env", "prod"},
+			code:   cmd.ErrorCodeRuntime,
+			errMsg: `invalid environment "prod"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newCustomScaffold(t, "testdata/projects/imports")
+			defer s.reset()
+			err := s.executeCommand(test.args...)
+			require.Error(t, err)
+			assert.Equal(t, test.code, cmd.ErrorCode(err))
+			assert.Contains(t, err.Error(), test.errMsg)
+		})
+	}
+}
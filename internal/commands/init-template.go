@@ -0,0 +1,226 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/sio"
+)
+
+// templateSuffix is the suffix of template files that are expanded when the project is created.
+const templateSuffix = ".tmpl"
+
+//go:embed templates
+var builtinTemplates embed.FS
+
+// projectTemplateData is the data made available to template files.
+type projectTemplateData struct {
+	Name      string // the app name
+	Server    string // the server URL of the default environment
+	Namespace string // the default namespace of the default environment
+}
+
+// builtinTemplateNames returns the sorted names of the templates that are built into qbec.
+func builtinTemplateNames() []string {
+	entries, err := builtinTemplates.ReadDir("templates")
+	if err != nil {
+		return nil
+	}
+	var ret []string
+	for _, e := range entries {
+		if e.IsDir() {
+			ret = append(ret, e.Name())
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// isGitURL returns true if the supplied template reference looks like a git repository URL.
+func isGitURL(s string) bool {
+	for _, prefix := range []string{"https://", "http://", "ssh://", "git://", "file://", "git@"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return strings.HasSuffix(s, ".git")
+}
+
+// parseGitURL splits a template URL of the form <repo>[//<subdir>][#<ref>] into its parts.
+func parseGitURL(u string) (repo, subdir, ref string) {
+	if pos := strings.LastIndex(u, "#"); pos >= 0 {
+		u, ref = u[:pos], u[pos+1:]
+	}
+	start := 0
+	if pos := strings.Index(u, "://"); pos >= 0 {
+		start = pos + len("://")
+	}
+	if pos := strings.Index(u[start:], "//"); pos >= 0 {
+		return u[:start+pos], strings.Trim(u[start+pos+2:], "/"), ref
+	}
+	return u, "", ref
+}
+
+// cloneTemplate clones the git repository for the supplied URL into a temporary directory and returns the
+// template directory along with a function to remove the clone.
+func cloneTemplate(u string) (string, func(), error) {
+	repo, subdir, ref := parseGitURL(u)
+	tmpDir, err := ioutil.TempDir("", "qbec-template-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { _ = os.RemoveAll(tmpDir) }
+	args := []string{"clone", "--depth", "1"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	args = append(args, repo, tmpDir)
+	sio.Noticef("cloning template from %s\n", repo)
+	var stderr bytes.Buffer
+	c := exec.Command("git", args...)
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("clone template %s: %v\n%s", repo, err, strings.TrimSpace(stderr.String()))
+	}
+	dir := filepath.Join(tmpDir, filepath.FromSlash(subdir))
+	if st, err := os.Stat(dir); err != nil || !st.IsDir() {
+		cleanup()
+		return "", nil, fmt.Errorf("template directory %s not found in %s", subdir, repo)
+	}
+	return dir, cleanup, nil
+}
+
+// openTemplate returns the file system for the supplied template, which is the name of a built-in template,
+// a local directory or a git URL. The returned function must be called to release resources once the
+// template is no longer needed.
+func openTemplate(name string) (fs.FS, func(), error) {
+	noop := func() {}
+	if !strings.ContainsAny(name, `/\`) {
+		if _, err := fs.Stat(builtinTemplates, path.Join("templates", name)); err == nil {
+			sub, err := fs.Sub(builtinTemplates, path.Join("templates", name))
+			return sub, noop, err
+		}
+	}
+	if isGitURL(name) {
+		dir, cleanup, err := cloneTemplate(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return os.DirFS(dir), cleanup, nil
+	}
+	if st, err := os.Stat(name); err == nil && st.IsDir() {
+		return os.DirFS(name), noop, nil
+	}
+	if name == "cue" {
+		return nil, nil, fmt.Errorf("there is no cue template, qbec evaluates components written in jsonnet, YAML or JSON")
+	}
+	return nil, nil, fmt.Errorf("unknown template %q, must be one of [%s], a local directory or a git URL",
+		name, strings.Join(builtinTemplateNames(), ", "))
+}
+
+// writeProjectTemplate writes the files of the supplied template into dir, expanding files that have the
+// template suffix using the supplied data.
+func writeProjectTemplate(dir string, tfs fs.FS, data projectTemplateData) error {
+	hasAppFile := false
+	for _, f := range model.AppFiles {
+		for _, name := range []string{f + templateSuffix, f} {
+			if _, err := fs.Stat(tfs, name); err == nil {
+				hasAppFile = true
+			}
+		}
+	}
+	if !hasAppFile {
+		return fmt.Errorf("template does not have any of %s", strings.Join(model.AppFiles, ", "))
+	}
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+	err := fs.WalkDir(tfs, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return fs.SkipDir
+			}
+			return os.MkdirAll(filepath.Join(dir, filepath.FromSlash(p)), 0755)
+		}
+		b, err := fs.ReadFile(tfs, p)
+		if err != nil {
+			return err
+		}
+		target := p
+		if strings.HasSuffix(p, templateSuffix) {
+			target = strings.TrimSuffix(p, templateSuffix)
+			t, err := template.New(p).Option("missingkey=error").Parse(string(b))
+			if err != nil {
+				return errors.Wrapf(err, "parse template %s", p)
+			}
+			var w bytes.Buffer
+			if err := t.Execute(&w, data); err != nil {
+				return fmt.Errorf("unable to expand template for file %s, %v", p, err)
+			}
+			b = w.Bytes()
+		}
+		file := filepath.Join(dir, filepath.FromSlash(target))
+		if err := ioutil.WriteFile(file, b, 0644); err != nil {
+			return err
+		}
+		sio.Noticeln("wrote", file)
+		return nil
+	})
+	if err != nil {
+		_ = os.RemoveAll(dir) // do not leave a partially rendered project behind
+	}
+	return err
+}
+
+// initFromTemplate creates a project in dir using the supplied template and verifies that the generated
+// app can be loaded.
+func initFromTemplate(dir string, name string, data projectTemplateData) error {
+	tfs, cleanup, err := openTemplate(name)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if err := writeProjectTemplate(dir, tfs, data); err != nil {
+		return err
+	}
+	file, err := model.FindAppFile(dir)
+	if err != nil {
+		return errors.Wrapf(err, "load app created from template %s", name)
+	}
+	if _, err := model.NewApp(file, nil, ""); err != nil {
+		return errors.Wrapf(err, "load app created from template %s", name)
+	}
+	return nil
+}
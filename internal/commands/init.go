@@ -23,6 +23,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/ghodss/yaml"
 	"github.com/spf13/cobra"
@@ -34,7 +35,8 @@ import (
 
 type initCommandConfig struct {
 	cmd.AppContext
-	withExample bool // create a hello world example
+	withExample bool   // create a hello world example
+	template    string // project template to use
 }
 
 var baseParamsTemplate = template.Must(template.New("base").Parse(`
@@ -203,7 +205,7 @@ func writeFiles(dir string, app model.QbecApp, config initCommandConfig) error {
 	if err != nil {
 		return fmt.Errorf("yaml marshal: %v", err)
 	}
-	file := filepath.Join(dir, "qbec.yaml")
+	file := filepath.Join(dir, model.DefaultAppFile)
 	if err := ioutil.WriteFile(file, b, 0644); err != nil {
 		return err
 	}
@@ -216,6 +218,9 @@ func doInit(args []string, config initCommandConfig) error {
 		return fmt.Errorf("a single app name argument must be supplied")
 	}
 	name := args[0]
+	if config.withExample && config.template != "" {
+		return cmd.NewUsageError("--with-example cannot be used with --template")
+	}
 	_, err := os.Stat(name)
 	if err == nil {
 		return fmt.Errorf("directory %s already exists", name)
@@ -233,6 +238,17 @@ func doInit(args []string, config initCommandConfig) error {
 		}
 	}
 	sio.Noticef("using server URL %q and default namespace %q for the default environment\n", ctx.ServerURL, ctx.Namespace)
+	if config.template != "" {
+		ns := ctx.Namespace
+		if ns == "" {
+			ns = "default"
+		}
+		return initFromTemplate(name, config.template, projectTemplateData{
+			Name:      name,
+			Server:    ctx.ServerURL,
+			Namespace: ns,
+		})
+	}
 	app := model.QbecApp{
 		Kind:       "App",
 		APIVersion: model.LatestAPIVersion,
@@ -253,12 +269,14 @@ func doInit(args []string, config initCommandConfig) error {
 
 func newInitCommand(cp ctxProvider) *cobra.Command {
 	c := &cobra.Command{
-		Use:   "init <app-name>",
-		Short: "initialize a qbec app",
+		Use:     "init <app-name>",
+		Short:   "initialize a qbec app",
+		Example: initExamples(),
 	}
 
 	config := initCommandConfig{}
 	c.Flags().BoolVar(&config.withExample, "with-example", false, "create a hello world sample component")
+	c.Flags().StringVar(&config.template, "template", "", fmt.Sprintf("project template to use, one of [%s], a local directory or a git URL of the form <repo>[//<subdir>][#<ref>]", strings.Join(builtinTemplateNames(), ", ")))
 
 	c.RunE = func(c *cobra.Command, args []string) error {
 		config.AppContext = cp()
@@ -0,0 +1,254 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadInitApp(t *testing.T, dir string) *model.App {
+	reset := setPwd(t, dir)
+	defer reset()
+	file, err := appFile()
+	require.NoError(t, err)
+	app, err := model.NewApp(file, nil, "")
+	require.NoError(t, err)
+	return app
+}
+
+func TestInitBasic(t *testing.T) {
+	s := newCustomScaffold(t, t.TempDir())
+	defer s.reset()
+	err := s.executeCommand("init", "foo", "--with-example")
+	require.NoError(t, err)
+	for _, f := range []string{"qbec.yaml", "params.libsonnet", "environments/base.libsonnet", "environments/default.libsonnet", "components/hello.jsonnet"} {
+		assert.FileExists(t, filepath.Join("foo", f))
+	}
+	app := loadInitApp(t, "foo")
+	assert.Equal(t, "foo", app.Name())
+}
+
+func TestInitBuiltinTemplates(t *testing.T) {
+	tests := []struct {
+		template   string
+		files      []string
+		components []string
+	}{
+		{
+			template:   "helm-wrapper",
+			files:      []string{"components/chart/datasource.libsonnet", "components/chart/index.jsonnet"},
+			components: []string{"chart"},
+		},
+		{
+			template:   "multi-ns",
+			files:      []string{"components/namespaces.jsonnet", "environments/base.libsonnet"},
+			components: []string{"backend", "frontend", "namespaces"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.template, func(t *testing.T) {
+			s := newCustomScaffold(t, t.TempDir())
+			defer s.reset()
+			err := s.executeCommand("init", "foo", "--template", test.template)
+			require.NoError(t, err)
+			for _, f := range test.files {
+				assert.FileExists(t, filepath.Join("foo", f))
+			}
+			assert.NoFileExists(t, filepath.Join("foo", "qbec.yaml.tmpl"))
+			app := loadInitApp(t, "foo")
+			assert.Equal(t, "foo", app.Name())
+			comps, err := app.ComponentsForEnvironment("default", nil, nil)
+			require.NoError(t, err)
+			var names []string
+			for _, c := range comps {
+				names = append(names, c.Name)
+			}
+			assert.Equal(t, test.components, names)
+		})
+	}
+}
+
+func TestInitLocalTemplate(t *testing.T) {
+	tdir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tdir, "components"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(tdir, ".git"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tdir, "qbec.yaml.tmpl"), []byte(`apiVersion: qbec.io/v1alpha1
+kind: App
+metadata:
+  name: {{ .Name }}
+spec:
+  environments:
+    dev:
+      server: {{ printf "%q" .Server }}
+      defaultNamespace: {{ .Name }}-ns
+`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tdir, "components", "c1.yaml"), []byte("{{ not expanded }}\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tdir, ".git", "HEAD"), []byte("ref"), 0644))
+
+	s := newCustomScaffold(t, t.TempDir())
+	defer s.reset()
+	err := s.executeCommand("init", "bar", "--template", tdir)
+	require.NoError(t, err)
+	b, err := ioutil.ReadFile(filepath.Join("bar", "components", "c1.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "{{ not expanded }}\n", string(b))
+	assert.NoDirExists(t, filepath.Join("bar", ".git"))
+	app := loadInitApp(t, "bar")
+	assert.Equal(t, "bar-ns", app.DefaultNamespace("dev"))
+}
+
+func TestInitJsonnetTemplate(t *testing.T) {
+	tdir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tdir, "components"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tdir, "qbec.jsonnet.tmpl"), []byte(`{
+  apiVersion: 'qbec.io/v1alpha1',
+  kind: 'App',
+  metadata: { name: '{{ .Name }}' },
+  spec: {
+    environments: {
+      dev: { server: {{ printf "%q" .Server }}, defaultNamespace: '{{ .Name }}-ns' },
+    },
+  },
+}
+`), 0644))
+
+	s := newCustomScaffold(t, t.TempDir())
+	defer s.reset()
+	err := s.executeCommand("init", "bar", "--template", tdir)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join("bar", "qbec.jsonnet"))
+	app := loadInitApp(t, "bar")
+	assert.Equal(t, "bar-ns", app.DefaultNamespace("dev"))
+}
+
+func TestInitGitTemplate(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	repo := t.TempDir()
+	tdir := filepath.Join(repo, "tmpl")
+	require.NoError(t, os.MkdirAll(filepath.Join(tdir, "components"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tdir, "qbec.yaml.tmpl"), []byte(`apiVersion: qbec.io/v1alpha1
+kind: App
+metadata:
+  name: {{ .Name }}
+spec:
+  environments:
+    default:
+      server: {{ printf "%q" .Server }}
+`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tdir, "components", "c1.yaml"), []byte("{}\n"), 0644))
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "template"},
+	} {
+		c := exec.Command("git", args...)
+		c.Dir = repo
+		out, err := c.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	s := newCustomScaffold(t, t.TempDir())
+	defer s.reset()
+	err := s.executeCommand("init", "baz", "--template", "file://"+filepath.ToSlash(repo)+"//tmpl")
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join("baz", "components", "c1.yaml"))
+	loadInitApp(t, "baz")
+}
+
+func TestInitNegative(t *testing.T) {
+	badTemplate := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(badTemplate, "components"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(badTemplate, "components", "c1.yaml"), []byte("{}\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(badTemplate, "qbec.yaml.tmpl"), []byte("name: {{ .Missing }}\n"), 0644))
+	tests := []struct {
+		name     string
+		args     []string
+		asserter func(s *scaffold, err error)
+	}{
+		{
+			name: "no name",
+			args: []string{"init"},
+			asserter: func(s *scaffold, err error) {
+				assert.Equal(s.t, "a single app name argument must be supplied", err.Error())
+			},
+		},
+		{
+			name: "example and template",
+			args: []string{"init", "foo", "--with-example", "--template", "multi-ns"},
+			asserter: func(s *scaffold, err error) {
+				assert.True(s.t, cmd.IsUsageError(err))
+				assert.Equal(s.t, "--with-example cannot be used with --template", err.Error())
+			},
+		},
+		{
+			name: "unknown template",
+			args: []string{"init", "foo", "--template", "nope"},
+			asserter: func(s *scaffold, err error) {
+				assert.Equal(s.t, `unknown template "nope", must be one of [helm-wrapper, multi-ns], a local directory or a git URL`, err.Error())
+			},
+		},
+		{
+			name: "cue template",
+			args: []string{"init", "foo", "--template", "cue"},
+			asserter: func(s *scaffold, err error) {
+				assert.Equal(s.t, "there is no cue template, qbec evaluates components written in jsonnet, YAML or JSON", err.Error())
+			},
+		},
+		{
+			name: "bad template",
+			args: []string{"init", "foo", "--template", badTemplate},
+			asserter: func(s *scaffold, err error) {
+				assert.Contains(s.t, err.Error(), "unable to expand template for file qbec.yaml.tmpl")
+			},
+		},
+		{
+			name: "no app file",
+			args: []string{"init", "foo", "--template", "."},
+			asserter: func(s *scaffold, err error) {
+				assert.Equal(s.t, "template does not have any of qbec.yaml, qbec.jsonnet", err.Error())
+			},
+		},
+		{
+			name: "bad git url",
+			args: []string{"init", "foo", "--template", "file:///non/existent/repo.git"},
+			asserter: func(s *scaffold, err error) {
+				assert.Contains(s.t, err.Error(), "clone template file:///non/existent/repo.git")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newCustomScaffold(t, t.TempDir())
+			defer s.reset()
+			err := s.executeCommand(test.args...)
+			require.Error(t, err)
+			test.asserter(s, err)
+			assert.NoDirExists(t, "foo")
+		})
+	}
+}
@@ -156,8 +156,9 @@ func doLint(args []string, config *lintCommandConfig, ac cmd.AppContext) error {
 		}
 	}
 	cfg := vm.Config{
-		LibPaths:    libPaths,
-		DataSources: dataSources,
+		LibPaths:           libPaths,
+		DataSources:        dataSources,
+		MaxDataSourceBytes: ac.Context.MaxDataSourceBytes(),
 	}
 	config.vm = vm.New(cfg)
 	config.opts.VerboseWalk = ac.Context.Verbosity() > 0
@@ -0,0 +1,291 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/sio"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var leaseGVK = schema.GroupVersionKind{Group: "coordination.k8s.io", Version: "v1", Kind: "Lease"}
+
+// lock settings, variables to allow overrides in tests.
+var (
+	lockLeaseDuration = time.Minute     // duration after which a lock that is not renewed expires
+	lockPollInterval  = 2 * time.Second // interval at which a held lock is checked for release
+)
+
+// lockOptions are the options for locking the remote environment while it is being changed.
+type lockOptions struct {
+	enabled     bool
+	timeout     time.Duration
+	forceUnlock bool
+}
+
+func addLockFlags(c *cobra.Command) *lockOptions {
+	var opts lockOptions
+	c.Flags().BoolVar(&opts.enabled, "lock", false, "acquire a lock for the environment in its default namespace before making changes")
+	c.Flags().DurationVar(&opts.timeout, "lock-timeout", 0, "time to wait for a lock held by someone else to be released, implies --lock")
+	c.Flags().BoolVar(&opts.forceUnlock, "force-unlock", false, "break a lock held by someone else, implies --lock")
+	return &opts
+}
+
+func (o lockOptions) required() bool {
+	return o.enabled || o.timeout > 0 || o.forceUnlock
+}
+
+var invalidLockChars = regexp.MustCompile(`[^a-z0-9.-]`)
+
+// lockName returns the name of the lease used to lock the supplied app, tag and environment.
+func lockName(app, tag, env string) string {
+	parts := []string{"qbec", app}
+	if tag != "" {
+		parts = append(parts, tag)
+	}
+	parts = append(parts, env)
+	name := invalidLockChars.ReplaceAllString(strings.ToLower(strings.Join(parts, "-")), "-")
+	if len(name) > 253 {
+		name = name[:253]
+	}
+	return strings.Trim(name, "-.")
+}
+
+// lockHolder returns the identity recorded as the holder of locks acquired by this process.
+func lockHolder() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s@%s (pid %d)", name, host, os.Getpid())
+}
+
+const leaseTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// remoteLock is a lock for an environment backed by a lease object in the cluster.
+type remoteLock struct {
+	log      sio.Logger
+	ri       dynamic.ResourceInterface
+	name     string
+	holder   string
+	duration time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	l        sync.Mutex
+}
+
+// acquireLock acquires the lock with the supplied name, waiting up to the timeout in the options for a lock held by
+// someone else to be released. Expired locks, locks held by the same holder and, when forced, locks held by
+// someone else are taken over. The lock is renewed in the background until it is released.
+func acquireLock(ctx context.Context, client cmd.KubeClient, namespace, name string, opts lockOptions) (*remoteLock, error) {
+	ri, err := client.ResourceInterface(leaseGVK, namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "get resource interface for leases")
+	}
+	l := &remoteLock{
+		log:      sio.For(ctx),
+		ri:       ri,
+		name:     name,
+		holder:   lockHolder(),
+		duration: lockLeaseDuration,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	deadline := time.Now().Add(opts.timeout)
+	force := opts.forceUnlock
+	waiting := false
+	for {
+		acquired, holder, err := l.tryAcquire(ctx, force)
+		if err != nil {
+			return nil, errors.Wrapf(err, "acquire lock %s/%s", namespace, name)
+		}
+		if acquired {
+			l.log.Debugf("acquired lock %s/%s\n", namespace, name)
+			go l.renew()
+			return l, nil
+		}
+		force = false
+		if holder == "" { // lost a race with someone else, retry immediately
+			continue
+		}
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("lock %s/%s is held by %s, use --lock-timeout to wait for it or --force-unlock to break it", namespace, name, holder)
+		}
+		if !waiting {
+			waiting = true
+			l.log.Noticef("waiting for lock %s/%s held by %s\n", namespace, name, holder)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+func (l *remoteLock) leaseSpec(acquireTime string) map[string]interface{} {
+	now := time.Now().UTC().Format(leaseTimeFormat)
+	if acquireTime == "" {
+		acquireTime = now
+	}
+	return map[string]interface{}{
+		"holderIdentity":       l.holder,
+		"leaseDurationSeconds": int64(l.duration / time.Second),
+		"acquireTime":          acquireTime,
+		"renewTime":            now,
+	}
+}
+
+// expired returns true if the supplied lease has not been renewed within its duration.
+func expired(lease *unstructured.Unstructured) bool {
+	renew, _, _ := unstructured.NestedString(lease.Object, "spec", "renewTime")
+	secs, _, _ := unstructured.NestedInt64(lease.Object, "spec", "leaseDurationSeconds")
+	t, err := time.Parse(leaseTimeFormat, renew)
+	if err != nil {
+		return true
+	}
+	return time.Now().After(t.Add(time.Duration(secs) * time.Second))
+}
+
+// tryAcquire attempts to acquire the lock once. It returns the current holder of the lock when it could not be
+// acquired, or a blank holder if the lock was concurrently modified by someone else.
+func (l *remoteLock) tryAcquire(ctx context.Context, force bool) (bool, string, error) {
+	lease, err := l.ri.Get(ctx, l.name, metav1.GetOptions{})
+	if err != nil {
+		if !apiErrors.IsNotFound(err) {
+			return false, "", err
+		}
+		lease = &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": leaseGVK.GroupVersion().String(),
+			"kind":       leaseGVK.Kind,
+			"metadata":   map[string]interface{}{"name": l.name},
+			"spec":       l.leaseSpec(""),
+		}}
+		_, err := l.ri.Create(ctx, lease, metav1.CreateOptions{})
+		if apiErrors.IsAlreadyExists(err) {
+			return false, "", nil
+		}
+		return err == nil, "", err
+	}
+	holder, _, _ := unstructured.NestedString(lease.Object, "spec", "holderIdentity")
+	switch {
+	case holder == "" || holder == l.holder || expired(lease):
+	case force:
+		l.log.Warnf("breaking lock %s held by %s\n", l.name, holder)
+	default:
+		return false, holder, nil
+	}
+	lease.Object["spec"] = l.leaseSpec("")
+	_, err = l.ri.Update(ctx, lease, metav1.UpdateOptions{}) // fails with a conflict if modified concurrently
+	if apiErrors.IsConflict(err) {
+		return false, "", nil
+	}
+	return err == nil, "", err
+}
+
+// renew periodically renews the lease until the lock is released.
+func (l *remoteLock) renew() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.duration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			if err := l.update(context.Background()); err != nil {
+				l.log.Warnf("unable to renew lock %s: %v\n", l.name, err)
+			}
+		}
+	}
+}
+
+func (l *remoteLock) update(ctx context.Context) error {
+	l.l.Lock()
+	defer l.l.Unlock()
+	lease, err := l.ri.Get(ctx, l.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if holder, _, _ := unstructured.NestedString(lease.Object, "spec", "holderIdentity"); holder != l.holder {
+		return fmt.Errorf("lock is now held by %s", holder)
+	}
+	acquireTime, _, _ := unstructured.NestedString(lease.Object, "spec", "acquireTime")
+	lease.Object["spec"] = l.leaseSpec(acquireTime)
+	_, err = l.ri.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
+
+// release stops renewing the lock and deletes the lease if it is still held.
+func (l *remoteLock) release(ctx context.Context) {
+	close(l.stop)
+	<-l.done
+	l.l.Lock()
+	defer l.l.Unlock()
+	lease, err := l.ri.Get(ctx, l.name, metav1.GetOptions{})
+	if err != nil {
+		l.log.Warnf("unable to release lock %s: %v\n", l.name, err)
+		return
+	}
+	if holder, _, _ := unstructured.NestedString(lease.Object, "spec", "holderIdentity"); holder != l.holder {
+		l.log.Warnf("not releasing lock %s since it is now held by %s\n", l.name, holder)
+		return
+	}
+	var opts metav1.DeleteOptions
+	if uid := lease.GetUID(); uid != "" {
+		opts.Preconditions = &metav1.Preconditions{UID: &uid}
+	}
+	err = l.ri.Delete(ctx, l.name, opts)
+	if err != nil && !apiErrors.IsNotFound(err) {
+		l.log.Warnf("unable to release lock %s: %v\n", l.name, err)
+		return
+	}
+	l.log.Debugf("released lock %s\n", l.name)
+}
+
+// lockEnvironment acquires the lock for the supplied environment when locking is requested and returns a function
+// to release it. The returned function is never nil.
+func lockEnvironment(ctx context.Context, config cmd.AppContext, client cmd.KubeClient, env string, opts lockOptions) (func(), error) {
+	if !opts.required() {
+		return func() {}, nil
+	}
+	app := config.App()
+	l, err := acquireLock(ctx, client, app.DefaultNamespace(env), lockName(app.Name(), app.Tag(), env), opts)
+	if err != nil {
+		return nil, err
+	}
+	return func() { l.release(context.Background()) }, nil
+}
@@ -0,0 +1,191 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+var leasesGVR = schema.GroupVersionResource{Group: "coordination.k8s.io", Version: "v1", Resource: "leases"}
+
+func testLease(ns, name, holder string, renewed time.Time) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "coordination.k8s.io/v1",
+		"kind":       "Lease",
+		"metadata":   map[string]interface{}{"namespace": ns, "name": name},
+		"spec": map[string]interface{}{
+			"holderIdentity":       holder,
+			"leaseDurationSeconds": int64(60),
+			"renewTime":            renewed.UTC().Format(leaseTimeFormat),
+		},
+	}}
+}
+
+func setupLeases(s *scaffold, leases ...runtime.Object) dynamic.Interface {
+	dc := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		leasesGVR: "LeaseList",
+	}, leases...)
+	s.client.riFunc = func(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+		if gvk != leaseGVK {
+			return nil, fmt.Errorf("unexpected kind %s", gvk.Kind)
+		}
+		return dc.Resource(leasesGVR).Namespace(namespace), nil
+	}
+	return dc
+}
+
+func getLease(t *testing.T, dc dynamic.Interface, ns, name string) *unstructured.Unstructured {
+	l, err := dc.Resource(leasesGVR).Namespace(ns).Get(context.Background(), name, metav1.GetOptions{})
+	if apiErrors.IsNotFound(err) {
+		return nil
+	}
+	require.NoError(t, err)
+	return l
+}
+
+func TestLockName(t *testing.T) {
+	assert.Equal(t, "qbec-my-app-dev", lockName("My_App", "", "dev"))
+	assert.Equal(t, "qbec-app-t1-dev", lockName("app", "t1", "dev"))
+}
+
+func TestLockAcquireRelease(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	dc := setupLeases(s)
+	l, err := acquireLock(context.Background(), s.client, "ns1", "qbec-app-dev", lockOptions{enabled: true})
+	require.NoError(t, err)
+	lease := getLease(t, dc, "ns1", "qbec-app-dev")
+	require.NotNil(t, lease)
+	holder, _, _ := unstructured.NestedString(lease.Object, "spec", "holderIdentity")
+	assert.Equal(t, lockHolder(), holder)
+	assert.False(t, expired(lease))
+	require.NoError(t, l.update(context.Background()))
+	l.release(context.Background())
+	assert.Nil(t, getLease(t, dc, "ns1", "qbec-app-dev"))
+}
+
+func TestLockHeld(t *testing.T) {
+	tests := []struct {
+		name    string
+		renewed time.Time
+		opts    lockOptions
+		err     string
+	}{
+		{
+			name:    "held",
+			renewed: time.Now(),
+			opts:    lockOptions{enabled: true},
+			err:     "lock ns1/qbec-app-dev is held by someone else, use --lock-timeout to wait for it or --force-unlock to break it",
+		},
+		{
+			name:    "held with timeout",
+			renewed: time.Now(),
+			opts:    lockOptions{timeout: 30 * time.Millisecond},
+			err:     "lock ns1/qbec-app-dev is held by someone else, use --lock-timeout to wait for it or --force-unlock to break it",
+		},
+		{
+			name:    "expired",
+			renewed: time.Now().Add(-2 * time.Minute),
+			opts:    lockOptions{enabled: true},
+		},
+		{
+			name:    "forced",
+			renewed: time.Now(),
+			opts:    lockOptions{forceUnlock: true},
+		},
+	}
+	origPoll := lockPollInterval
+	lockPollInterval = 10 * time.Millisecond
+	defer func() { lockPollInterval = origPoll }()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newScaffold(t)
+			defer s.reset()
+			setupLeases(s, testLease("ns1", "qbec-app-dev", "someone else", test.renewed))
+			l, err := acquireLock(context.Background(), s.client, "ns1", "qbec-app-dev", test.opts)
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			l.release(context.Background())
+		})
+	}
+}
+
+func TestLockWaitForRelease(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	origPoll := lockPollInterval
+	lockPollInterval = 10 * time.Millisecond
+	defer func() { lockPollInterval = origPoll }()
+	dc := setupLeases(s, testLease("ns1", "qbec-app-dev", "someone else", time.Now()))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = dc.Resource(leasesGVR).Namespace("ns1").Delete(context.Background(), "qbec-app-dev", metav1.DeleteOptions{})
+	}()
+	l, err := acquireLock(context.Background(), s.client, "ns1", "qbec-app-dev", lockOptions{timeout: 5 * time.Second})
+	require.NoError(t, err)
+	l.release(context.Background())
+	s.assertErrorLineMatch(regexp.MustCompile(`waiting for lock ns1/qbec-app-dev held by someone else`))
+}
+
+func TestApplyWithLock(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	dc := setupLeases(s)
+	synced := false
+	s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+		synced = true
+		require.NotNil(t, getLease(t, dc, "default", "qbec-example1-dev"))
+		return &remote.SyncResult{Type: remote.SyncObjectsIdentical}, nil
+	}
+	s.client.listFunc = stdLister
+	err := s.executeCommand("apply", "dev", "--lock", "--gc=false", "--wait-all=false")
+	require.NoError(t, err)
+	assert.True(t, synced)
+	assert.Nil(t, getLease(t, dc, "default", "qbec-example1-dev"))
+}
+
+func TestApplyLockHeld(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	setupLeases(s, testLease("default", "qbec-example1-dev", "ci-job", time.Now()))
+	s.client.syncFunc = func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error) {
+		return nil, fmt.Errorf("should not be called")
+	}
+	err := s.executeCommand("apply", "dev", "--lock")
+	require.Error(t, err)
+	assert.Equal(t, "lock default/qbec-example1-dev is held by ci-job, use --lock-timeout to wait for it or --force-unlock to break it", err.Error())
+}
@@ -0,0 +1,281 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/splunk/qbec/internal/sio"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var podGVK = schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+
+type logsCommandConfig struct {
+	cmd.AppContext
+	follow     bool
+	tail       int64
+	since      time.Duration
+	container  string
+	filterFunc func() (model.Filters, error)
+}
+
+// podContainer is a single container in a pod whose logs are streamed.
+type podContainer struct {
+	namespace string
+	pod       string
+	container string
+}
+
+func (p podContainer) prefix() string {
+	return fmt.Sprintf("[%s/%s] ", p.pod, p.container)
+}
+
+// podSelector returns the label selector for the pods managed by the supplied workload object. It returns a nil
+// selector for objects that are not workloads or for which pods cannot be determined.
+func podSelector(obj model.K8sLocalObject) (labels.Selector, error) {
+	u := obj.ToUnstructured()
+	gk := obj.GroupVersionKind().GroupKind()
+	switch gk {
+	case schema.GroupKind{Group: "apps", Kind: "Deployment"},
+		schema.GroupKind{Group: "apps", Kind: "StatefulSet"},
+		schema.GroupKind{Group: "apps", Kind: "DaemonSet"},
+		schema.GroupKind{Group: "apps", Kind: "ReplicaSet"},
+		schema.GroupKind{Group: "batch", Kind: "Job"}:
+	default:
+		return nil, nil
+	}
+	sel, found, err := unstructured.NestedMap(u.Object, "spec", "selector")
+	if err != nil {
+		return nil, errors.Wrapf(err, "get selector")
+	}
+	if !found {
+		if gk.Kind == "Job" { // selectors for jobs are typically generated by the server
+			if obj.GetName() == "" { // generated name, unknown until the job is created
+				return nil, nil
+			}
+			return labels.SelectorFromSet(labels.Set{"job-name": obj.GetName()}), nil
+		}
+		return nil, fmt.Errorf("no pod selector found")
+	}
+	var ls metav1.LabelSelector
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(sel, &ls); err != nil {
+		return nil, errors.Wrapf(err, "convert selector")
+	}
+	return metav1.LabelSelectorAsSelector(&ls)
+}
+
+// podContainers returns the containers for all pods belonging to the supplied objects.
+func podContainers(ctx context.Context, client cmd.KubeClient, objects []model.K8sLocalObject, defaultNs string, container string) ([]podContainer, error) {
+	seen := map[string]bool{}
+	var ret []podContainer
+	addPod := func(pod *unstructured.Unstructured) error {
+		key := pod.GetNamespace() + "/" + pod.GetName()
+		if seen[key] {
+			return nil
+		}
+		seen[key] = true
+		containers, _, err := unstructured.NestedSlice(pod.Object, "spec", "containers")
+		if err != nil {
+			return errors.Wrapf(err, "get containers for pod %s", key)
+		}
+		for _, c := range containers {
+			cMap, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := cMap["name"].(string)
+			if container != "" && name != container {
+				continue
+			}
+			ret = append(ret, podContainer{namespace: pod.GetNamespace(), pod: pod.GetName(), container: name})
+		}
+		return nil
+	}
+
+	for _, obj := range objects {
+		ns := obj.GetNamespace()
+		if ns == "" {
+			ns = defaultNs
+		}
+		ri, err := client.ResourceInterface(podGVK, ns)
+		if err != nil {
+			return nil, errors.Wrap(err, "get resource interface for pods")
+		}
+		if obj.GroupVersionKind().GroupKind() == podGVK.GroupKind() {
+			pod, err := ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+			if err != nil {
+				return nil, errors.Wrapf(err, "get pod %s", obj.GetName())
+			}
+			if err := addPod(pod); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		sel, err := podSelector(obj)
+		if err != nil {
+			return nil, errors.Wrap(err, client.DisplayName(obj))
+		}
+		if sel == nil {
+			continue
+		}
+		list, err := ri.List(ctx, metav1.ListOptions{LabelSelector: sel.String()})
+		if err != nil {
+			return nil, errors.Wrapf(err, "list pods for %s", client.DisplayName(obj))
+		}
+		for i := range list.Items {
+			if err := addPod(&list.Items[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].prefix() < ret[j].prefix()
+	})
+	return ret, nil
+}
+
+// prefixWriter writes lines from multiple sources to a single writer without interleaving partial lines.
+type prefixWriter struct {
+	l sync.Mutex
+	w io.Writer
+}
+
+func (p *prefixWriter) copy(prefix string, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		p.l.Lock()
+		_, err := fmt.Fprintf(p.w, "%s%s\n", prefix, scanner.Text())
+		p.l.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func doLogs(ctx context.Context, args []string, config logsCommandConfig) error {
+	if len(args) != 1 {
+		return cmd.NewUsageError(fmt.Sprintf("exactly one environment required, but provided: %q", args))
+	}
+	env, err := config.ResolveEnv(args[0])
+	if err != nil {
+		return err
+	}
+	if env == model.Baseline {
+		return cmd.NewUsageError("cannot show logs for baseline environment, use a real environment")
+	}
+	fp, err := config.filterFunc()
+	if err != nil {
+		return err
+	}
+	envCtx, err := config.EnvContext(env)
+	if err != nil {
+		return err
+	}
+	client, err := envCtx.Client()
+	if err != nil {
+		return err
+	}
+	objects, err := generateObjects(ctx, envCtx, makeFilterOpts(fp, client))
+	if err != nil {
+		return err
+	}
+	containers, err := podContainers(ctx, client, objects, config.App().DefaultNamespace(env), config.container)
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return fmt.Errorf("no pods found for the selected objects")
+	}
+	sio.Debugf("streaming logs for %d container(s)\n", len(containers))
+
+	opts := remote.PodLogOptions{
+		Follow:    config.follow,
+		TailLines: config.tail,
+		Since:     config.since,
+	}
+	pw := &prefixWriter{w: config.Stdout()}
+	errs := make([]error, len(containers))
+	var wg sync.WaitGroup
+	wg.Add(len(containers))
+	for i, pc := range containers {
+		go func(i int, pc podContainer) {
+			defer wg.Done()
+			o := opts
+			o.Container = pc.container
+			stream, err := client.PodLogs(ctx, pc.namespace, pc.pod, o)
+			if err == nil {
+				err = pw.copy(pc.prefix(), stream)
+				_ = stream.Close()
+			}
+			if err != nil {
+				sio.Errorf("%s%v\n", pc.prefix(), err)
+				errs[i] = err
+			}
+		}(i, pc)
+	}
+	wg.Wait()
+	var failed int
+	for _, e := range errs {
+		if e != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to stream logs for %d container(s)", failed)
+	}
+	return nil
+}
+
+func newLogsCommand(cp ctxProvider) *cobra.Command {
+	c := &cobra.Command{
+		Use:     "logs [-f] <environment>",
+		Short:   "show logs for pods of workloads in one or more components",
+		Example: logsExamples(),
+	}
+
+	config := logsCommandConfig{
+		filterFunc: addFilterParams(c, true),
+	}
+	c.Flags().BoolVarP(&config.follow, "follow", "f", false, "stream logs as they are produced")
+	c.Flags().Int64Var(&config.tail, "tail", 10, "lines of recent logs to show for every container, -1 to show all lines")
+	c.Flags().DurationVar(&config.since, "since", 0, "only show logs newer than this duration (e.g. 5m)")
+	c.Flags().StringVar(&config.container, "container", "", "only show logs for containers with this name")
+
+	c.RunE = func(c *cobra.Command, args []string) error {
+		config.AppContext = cp()
+		return cmd.WrapError(doLogs(c.Context(), args, config))
+	}
+	return c
+}
@@ -0,0 +1,226 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func testPod(ns, name string, labels map[string]interface{}, containers ...string) *unstructured.Unstructured {
+	var cs []interface{}
+	for _, c := range containers {
+		cs = append(cs, map[string]interface{}{"name": c, "image": "nginx"})
+	}
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"metadata": map[string]interface{}{
+				"namespace": ns,
+				"name":      name,
+				"labels":    labels,
+			},
+			"spec": map[string]interface{}{
+				"containers": cs,
+			},
+		},
+	}
+}
+
+func setupPods(s *scaffold, pods ...runtime.Object) {
+	podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	dc := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		podsGVR: "PodList",
+	}, pods...)
+	s.client.riFunc = func(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+		if gvk.Kind != "Pod" {
+			return nil, fmt.Errorf("unexpected kind %s", gvk.Kind)
+		}
+		return dc.Resource(podsGVR).Namespace(namespace), nil
+	}
+}
+
+func TestLogsBasic(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	setupPods(s,
+		testPod("bar-system", "svc2-deploy-1", map[string]interface{}{"app": "svc2-deploy"}, "main", "sidecar"),
+		testPod("bar-system", "svc2-deploy-2", map[string]interface{}{"app": "svc2-deploy"}, "main"),
+		testPod("bar-system", "other", map[string]interface{}{"app": "other"}, "main"),
+	)
+	s.client.podLogsFunc = func(ctx context.Context, namespace, pod string, opts remote.PodLogOptions) (io.ReadCloser, error) {
+		assert.Equal(t, "bar-system", namespace)
+		assert.Equal(t, int64(5), opts.TailLines)
+		assert.True(t, opts.Follow)
+		return ioutil.NopCloser(strings.NewReader(fmt.Sprintf("hello from %s\nbye from %s\n", pod, opts.Container))), nil
+	}
+	err := s.executeCommand("logs", "dev", "-c", "service2", "-f", "--tail=5")
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`^\[svc2-deploy-1/main\] hello from svc2-deploy-1$`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^\[svc2-deploy-1/sidecar\] bye from sidecar$`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^\[svc2-deploy-2/main\] bye from main$`))
+	s.assertOutputLineNoMatch(regexp.MustCompile(`other`))
+}
+
+func TestLogsContainerFilter(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	setupPods(s,
+		testPod("bar-system", "svc2-deploy-1", map[string]interface{}{"app": "svc2-deploy"}, "main", "sidecar"),
+	)
+	s.client.podLogsFunc = func(ctx context.Context, namespace, pod string, opts remote.PodLogOptions) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("log line\n")), nil
+	}
+	err := s.executeCommand("logs", "dev", "--container", "sidecar")
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`^\[svc2-deploy-1/sidecar\] log line$`))
+	s.assertOutputLineNoMatch(regexp.MustCompile(`/main\]`))
+}
+
+func TestLogsNegative(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		init     func(s *scaffold)
+		asserter func(s *scaffold, err error)
+	}{
+		{
+			name: "no env",
+			args: []string{"logs"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.True(cmd.IsUsageError(err))
+				a.Equal("exactly one environment required, but provided: []", err.Error())
+			},
+		},
+		{
+			name: "baseline",
+			args: []string{"logs", "_"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.True(cmd.IsUsageError(err))
+				a.Equal("cannot show logs for baseline environment, use a real environment", err.Error())
+			},
+		},
+		{
+			name: "no pods",
+			args: []string{"logs", "dev"},
+			init: func(s *scaffold) { setupPods(s) },
+			asserter: func(s *scaffold, err error) {
+				require.Error(s.t, err)
+				assert.Equal(s.t, "no pods found for the selected objects", err.Error())
+			},
+		},
+		{
+			name: "stream error",
+			args: []string{"logs", "dev"},
+			init: func(s *scaffold) {
+				setupPods(s, testPod("bar-system", "svc2-deploy-1", map[string]interface{}{"app": "svc2-deploy"}, "main"))
+				s.client.podLogsFunc = func(ctx context.Context, namespace, pod string, opts remote.PodLogOptions) (io.ReadCloser, error) {
+					return nil, fmt.Errorf("container is waiting to start")
+				}
+			},
+			asserter: func(s *scaffold, err error) {
+				require.Error(s.t, err)
+				assert.Equal(s.t, "failed to stream logs for 1 container(s)", err.Error())
+				assert.Contains(s.t, s.stderr(), "[svc2-deploy-1/main] container is waiting to start")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newScaffold(t)
+			defer s.reset()
+			if test.init != nil {
+				test.init(s)
+			}
+			err := s.executeCommand(test.args...)
+			require.NotNil(t, err)
+			test.asserter(s, err)
+		})
+	}
+}
+
+func TestPodSelector(t *testing.T) {
+	obj := func(data map[string]interface{}) model.K8sLocalObject {
+		return model.NewK8sLocalObject(data, model.LocalAttrs{App: "app", Component: "c", Env: "dev"})
+	}
+	sel, err := podSelector(obj(map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "StatefulSet",
+		"metadata":   map[string]interface{}{"name": "ss"},
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{"app": "ss"},
+				"matchExpressions": []interface{}{
+					map[string]interface{}{"key": "tier", "operator": "In", "values": []interface{}{"web"}},
+				},
+			},
+		},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "app=ss,tier in (web)", sel.String())
+
+	sel, err = podSelector(obj(map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata":   map[string]interface{}{"name": "j1"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "job-name=j1", sel.String())
+
+	sel, err = podSelector(obj(map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata":   map[string]interface{}{"generateName": "j-"},
+	}))
+	require.NoError(t, err)
+	assert.Nil(t, sel)
+
+	sel, err = podSelector(obj(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "cm"},
+	}))
+	require.NoError(t, err)
+	assert.Nil(t, sel)
+
+	_, err = podSelector(obj(map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "d"},
+	}))
+	require.Error(t, err)
+	assert.Equal(t, "no pod selector found", err.Error())
+}
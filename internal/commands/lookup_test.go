@@ -0,0 +1,60 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestClusterLookups(t *testing.T) {
+	s := newCustomScaffold(t, "testdata/projects/lookups")
+	defer s.reset()
+	var lookups []string
+	s.client.getFunc = func(ctx context.Context, obj model.K8sMeta) (*unstructured.Unstructured, error) {
+		lookups = append(lookups, obj.GetKind()+":"+obj.GetNamespace()+":"+obj.GetName())
+		if obj.GetKind() != "ConfigMap" {
+			return nil, remote.ErrNotFound
+		}
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"namespace": "shared", "name": "settings"},
+			"data":       map[string]interface{}{"region": "us-west-2"},
+		}}, nil
+	}
+	err := s.executeCommand("show", "dev", "--allow-cluster-lookups")
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`region: us-west-2`))
+	s.assertOutputLineMatch(regexp.MustCompile(`tokenVersion: none`))
+	assert.Equal(t, []string{"ConfigMap:shared:settings", "Secret::token"}, lookups)
+}
+
+func TestClusterLookupsDisabled(t *testing.T) {
+	s := newCustomScaffold(t, "testdata/projects/lookups")
+	defer s.reset()
+	err := s.executeCommand("show", "dev")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cluster lookups are not enabled, use --allow-cluster-lookups to enable them")
+}
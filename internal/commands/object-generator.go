@@ -20,11 +20,13 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/splunk/qbec/internal/cmd"
 	"github.com/splunk/qbec/internal/eval"
 	"github.com/splunk/qbec/internal/model"
 	"github.com/splunk/qbec/internal/sio"
+	"github.com/splunk/qbec/internal/statusexpr"
 )
 
 // cleanEvalMode is set to true by the show command when clean mode is in effect and drives a qbec external variable
@@ -44,6 +46,23 @@ func addFilterParams(c *cobra.Command, includeAllFilters bool) func() (model.Fil
 	}
 }
 
+// addComponentDirParams adds the --component-dir option to the supplied command and returns a function
+// that adds the specified ad-hoc components to an app.
+func addComponentDirParams(c *cobra.Command) func(app *model.App) error {
+	var paths []string
+	c.Flags().StringArrayVar(&paths, "component-dir", nil,
+		"directory or file to treat as an additional component for this invocation, relative to the qbec root, can be specified multiple times")
+	return func(app *model.App) error {
+		if len(paths) == 0 {
+			return nil
+		}
+		if err := app.AddComponents(paths); err != nil {
+			return cmd.NewUsageError(err.Error())
+		}
+		return nil
+	}
+}
+
 func displayName(obj model.K8sLocalObject) string {
 	group := obj.GroupVersionKind().Group
 	if group != "" {
@@ -74,10 +93,33 @@ func checkDuplicates(objects []model.K8sLocalObject, kf keyFunc) error {
 	return nil
 }
 
+// checkWaitStatus verifies that the status expressions set on objects using the wait-status directive are valid,
+// such that bad expressions are reported before any object is applied.
+func checkWaitStatus(objects []model.K8sLocalObject) error {
+	for _, o := range objects {
+		expr := o.GetAnnotations()[model.QbecNames.Directives.WaitStatus]
+		if expr == "" {
+			continue
+		}
+		if _, err := statusexpr.Parse(expr); err != nil {
+			return errors.Wrap(err, displayName(o))
+		}
+	}
+	return nil
+}
+
 type filterOpts struct {
-	filters model.Filters
-	client  model.Namespaced
-	keyFunc keyFunc
+	filters       model.Filters
+	client        model.Namespaced
+	keyFunc       keyFunc
+	preserveOrder bool // keep objects in the order emitted by components
+	checkPolicies bool // check objects against the policies of the app
+}
+
+// withPolicies returns a copy of the options that also checks objects against the policies of the app.
+func (f filterOpts) withPolicies() filterOpts {
+	f.checkPolicies = true
+	return f
 }
 
 func emptyFilterOpts() filterOpts {
@@ -88,20 +130,29 @@ func makeFilterOpts(filters model.Filters, client cmd.KubeClient) filterOpts {
 	return filterOpts{filters: filters, client: client, keyFunc: client.ObjectKey}
 }
 
-func generateObjects(_ context.Context, envCtx cmd.EnvContext, opts filterOpts) ([]model.K8sLocalObject, error) {
+func generateObjects(ctx context.Context, envCtx cmd.EnvContext, opts filterOpts) ([]model.K8sLocalObject, error) {
 	fp := opts.filters
 	client := opts.client
 	components, err := envCtx.App().ComponentsForEnvironment(envCtx.Env(), fp.ComponentIncludes(), fp.ComponentExcludes())
 	if err != nil {
 		return nil, err
 	}
-	output, err := eval.Components(components, envCtx.EvalContext(cleanEvalMode), envCtx.ObjectProducer())
-	if err != nil {
+	evalCtx := envCtx.EvalContext(cleanEvalMode)
+	evalCtx.PreserveOrder = opts.preserveOrder
+	evalCtx.TraceContext = ctx
+	if err := validateParams(ctx, envCtx, evalCtx, components); err != nil {
 		return nil, err
 	}
+	output, err := eval.Components(components, evalCtx, envCtx.ObjectProducer())
+	if err != nil {
+		return nil, cmd.WithCode(cmd.ErrorCodeEval, err)
+	}
 	if err := checkDuplicates(output, opts.keyFunc); err != nil {
 		return nil, err
 	}
+	if err := checkWaitStatus(output); err != nil {
+		return nil, err
+	}
 	if len(output) == 0 {
 		return output, nil
 	}
@@ -125,7 +176,73 @@ func generateObjects(_ context.Context, envCtx cmd.EnvContext, opts filterOpts)
 		}
 	}
 	if len(ret) == 0 {
-		sio.Warnf("0 of %d matches after applying filters, check for typos and kind abbreviations\n", len(output))
+		sio.For(ctx).Warnf("0 of %d matches after applying filters, check for typos and kind abbreviations\n", len(output))
+	}
+	if opts.checkPolicies {
+		if err := checkPolicies(ctx, envCtx, evalCtx, ret); err != nil {
+			return nil, err
+		}
 	}
 	return ret, nil
 }
+
+// validateParams validates the parameters of the supplied components that declare a parameter schema. The
+// parameters file is only evaluated when at least one such component exists. Validation failures are reported as
+// errors and cause an error to be returned.
+func validateParams(ctx context.Context, envCtx cmd.EnvContext, evalCtx eval.Context, components []model.Component) error {
+	app := envCtx.App()
+	var names []string
+	for _, c := range components {
+		if app.HasParamsSchema(c.Name) {
+			names = append(names, c.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	paramsObject, err := eval.Params(app.ParamsFile(), evalCtx)
+	if err != nil {
+		return cmd.WithCode(cmd.ErrorCodeEval, err)
+	}
+	params, ok := paramsObject["components"].(map[string]interface{})
+	if !ok {
+		return cmd.WithCode(cmd.ErrorCodeEval, fmt.Errorf("%s: unable to find 'components' key in the parameter object", app.ParamsFile()))
+	}
+	invalid := 0
+	for _, name := range names {
+		p, ok := params[name]
+		if !ok {
+			p = map[string]interface{}{}
+		}
+		for _, err := range app.ValidateParams(name, p) {
+			invalid++
+			sio.For(ctx).Errorf("%s: %v\n", envCtx.Env(), err)
+		}
+	}
+	if invalid > 0 {
+		return cmd.WithCode(cmd.ErrorCodeEval, fmt.Errorf("%d parameter validation error(s) found", invalid))
+	}
+	return nil
+}
+
+// checkPolicies checks the supplied objects against the policies of the app for the environment. Violations of
+// policies with the warn level are reported as warnings, and an error is returned for violations of other policies.
+func checkPolicies(ctx context.Context, envCtx cmd.EnvContext, evalCtx eval.Context, objects []model.K8sLocalObject) error {
+	violations, err := eval.CheckPolicies(objects, envCtx.App().Policies(envCtx.Env()), evalCtx)
+	if err != nil {
+		return cmd.WithCode(cmd.ErrorCodeEval, err)
+	}
+	denied := 0
+	for _, v := range violations {
+		if v.Policy.Level == model.PolicyWarn {
+			sio.For(ctx).Warnf("policy %s: %s: %s\n", v.Policy.Name, displayName(v.Object), v.Message)
+			continue
+		}
+		denied++
+		sio.For(ctx).Errorf("policy %s: %s: %s\n", v.Policy.Name, displayName(v.Object), v.Message)
+	}
+	if denied > 0 {
+		return fmt.Errorf("%d policy violation(s) found", denied)
+	}
+	return nil
+}
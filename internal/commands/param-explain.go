@@ -0,0 +1,278 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/eval"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/sio"
+	"github.com/splunk/qbec/vm"
+)
+
+// sources of parameter values, from the lowest to the highest layer
+const (
+	sourceBaseline       = "baseline"       // the parameters for the baseline environment
+	sourceBaseProperties = "baseProperties" // the base properties in qbec.yaml
+	sourceEnvironment    = "environment"    // environment-specific parameters
+	sourceEnvProperties  = "properties"     // the properties of the environment in qbec.yaml
+)
+
+// explainedParam is a leaf parameter value along with the layer that produced it.
+type explainedParam struct {
+	Component string      `json:"component"`
+	Name      string      `json:"name"`
+	Value     interface{} `json:"value"`
+	Source    string      `json:"source"`
+}
+
+var simpleParamKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// flattenParams adds all leaf values of the supplied value to the supplied map keyed by path. Lists are leaf values.
+func flattenParams(prefix string, v interface{}, out map[string]interface{}) {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		out[prefix] = v
+		return
+	}
+	for k, child := range m {
+		p := prefix + "." + k
+		if !simpleParamKey.MatchString(k) {
+			p = fmt.Sprintf("%s[%q]", prefix, k)
+		}
+		flattenParams(p, child, out)
+	}
+}
+
+// perturb returns a copy of the supplied value where every scalar has been changed, such that parameter values
+// that are derived from it also change.
+func perturb(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		ret := map[string]interface{}{}
+		for k, child := range val {
+			ret[k] = perturb(child)
+		}
+		return ret
+	case []interface{}:
+		ret := make([]interface{}, len(val))
+		for i, child := range val {
+			ret[i] = perturb(child)
+		}
+		return ret
+	case string:
+		return val + "~"
+	case float64:
+		return val + 1
+	case int64:
+		return val + 1
+	case int:
+		return val + 1
+	case bool:
+		return !val
+	default:
+		return v
+	}
+}
+
+// paramLayer is a version of the parameters evaluated without a layer. Values that differ from the version with
+// the layer were produced by it.
+type paramLayer struct {
+	source string
+	params map[string]map[string]interface{} // flattened parameters keyed by component, nil when not evaluated
+}
+
+type paramExplainCommandConfig struct {
+	cmd.AppContext
+	format     string
+	filterFunc func() (model.Filters, error)
+}
+
+// evalFlatParams evaluates the parameters using the supplied context, optionally with the supplied properties, and
+// returns the flattened parameters of the components that match the supplied filters.
+func evalFlatParams(config paramExplainCommandConfig, ctx eval.Context, props map[string]interface{}, fp model.Filters) (map[string]map[string]interface{}, error) {
+	if props != nil {
+		b, err := json.Marshal(props)
+		if err != nil {
+			return nil, err
+		}
+		ctx.Vars = ctx.Vars.WithVars(vm.NewCodeVar(model.QbecNames.EnvPropsVarName, string(b)))
+	}
+	paramsObject, err := eval.Params(config.App().ParamsFile(), ctx)
+	if err != nil {
+		return nil, err
+	}
+	components, err := extractComponentParams(paramsObject, fp)
+	if err != nil {
+		return nil, err
+	}
+	ret := map[string]map[string]interface{}{}
+	for c, v := range components {
+		flat := map[string]interface{}{}
+		flattenParams("", v, flat)
+		ret[c] = flat
+	}
+	return ret, nil
+}
+
+func doParamExplain(args []string, config paramExplainCommandConfig) error {
+	if len(args) != 1 {
+		return cmd.NewUsageError(fmt.Sprintf("exactly one environment required, but provided: %q", args))
+	}
+	switch config.format {
+	case "", "json", "yaml":
+	default:
+		return cmd.NewUsageError(fmt.Sprintf("unsupported format %q", config.format))
+	}
+	env, err := config.ResolveEnv(args[0])
+	if err != nil {
+		return err
+	}
+	if env != model.Baseline {
+		if _, err := config.App().ServerURL(env); err != nil {
+			return err
+		}
+	}
+	fp, err := config.filterFunc()
+	if err != nil {
+		return err
+	}
+	envCtx, err := config.EnvContext(env)
+	if err != nil {
+		return err
+	}
+	actual, err := evalFlatParams(config, envCtx.EvalContext(cleanEvalMode), nil, fp)
+	if err != nil {
+		return cmd.WithCode(cmd.ErrorCodeEval, err)
+	}
+
+	// evaluate versions of the parameters without each layer, from the highest to the lowest
+	baseProps := config.App().BaseProperties()
+	var layers []paramLayer
+	without := func(source string, fn func() (map[string]map[string]interface{}, error)) {
+		params, err := fn()
+		if err != nil {
+			sio.Warnf("unable to evaluate parameters without %s, values produced by it are attributed to lower layers: %v\n", source, err)
+		}
+		layers = append(layers, paramLayer{source: source, params: params})
+	}
+	if env != model.Baseline {
+		without(sourceEnvProperties, func() (map[string]map[string]interface{}, error) {
+			return evalFlatParams(config, envCtx.EvalContext(cleanEvalMode), baseProps, fp)
+		})
+		without(sourceEnvironment, func() (map[string]map[string]interface{}, error) {
+			baseCtx, err := config.EnvContext(model.Baseline)
+			if err != nil {
+				return nil, err
+			}
+			return evalFlatParams(config, baseCtx.EvalContext(cleanEvalMode), nil, fp)
+		})
+	}
+	if len(baseProps) > 0 {
+		without(sourceBaseProperties, func() (map[string]map[string]interface{}, error) {
+			baseCtx, err := config.EnvContext(model.Baseline)
+			if err != nil {
+				return nil, err
+			}
+			return evalFlatParams(config, baseCtx.EvalContext(cleanEvalMode), perturb(baseProps).(map[string]interface{}), fp)
+		})
+	}
+
+	// a value is produced by the highest layer without which it is different. Since every layer is compared to the
+	// one above it, a value overridden by a higher layer is attributed to that layer.
+	var ret []explainedParam
+	for c, flat := range actual {
+		for name, value := range flat {
+			source := sourceBaseline
+			current := value
+			for _, l := range layers {
+				if l.params == nil {
+					continue
+				}
+				prev, ok := l.params[c][name]
+				if !ok || !reflect.DeepEqual(prev, current) {
+					source = l.source
+					break
+				}
+				current = prev
+			}
+			ret = append(ret, explainedParam{Component: c, Name: strings.TrimPrefix(name, "."), Value: value, Source: source})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Component != ret[j].Component {
+			return ret[i].Component < ret[j].Component
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	return printExplainedParams(ret, config.format, config.Stdout())
+}
+
+func printExplainedParams(params []explainedParam, format string, w io.Writer) error {
+	switch format {
+	case "yaml":
+		b, err := yaml.Marshal(params)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "---")
+		fmt.Fprintf(w, "%s\n", b)
+		return nil
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(params)
+	default:
+		fmt.Fprintf(w, "%-30s %-40s %-15s %s\n", "COMPONENT", "NAME", "SOURCE", "VALUE")
+		for _, p := range params {
+			valBytes, _ := json.Marshal(p.Value)
+			valStr := string(valBytes)
+			if len(valStr) > maxDisplayValueLength {
+				valStr = valStr[:maxDisplayValueLength-3] + "..."
+			}
+			fmt.Fprintf(w, "%-30s %-40s %-15s %s\n", p.Component, p.Name, p.Source, valStr)
+		}
+		return nil
+	}
+}
+
+func newParamExplainCommand(cp ctxProvider) *cobra.Command {
+	c := &cobra.Command{
+		Use:     "explain [-c component]...  <environment>|_",
+		Short:   "list all parameter values for an environment along with the layer that produced each value",
+		Example: paramExplainExamples(),
+	}
+	config := paramExplainCommandConfig{
+		filterFunc: addFilterParams(c, false),
+	}
+	c.Flags().StringVarP(&config.format, "format", "o", "", "use json|yaml to display machine readable input")
+	c.RunE = func(c *cobra.Command, args []string) error {
+		config.AppContext = cp()
+		return cmd.WrapError(doParamExplain(args, config))
+	}
+	return c
+}
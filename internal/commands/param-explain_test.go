@@ -0,0 +1,124 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParamExplain(t *testing.T) {
+	s := newCustomScaffold(t, "testdata/projects/param-sources")
+	defer s.reset()
+	err := s.executeCommand("param", "explain", "prod")
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`COMPONENT\s+NAME\s+SOURCE\s+VALUE`))
+	s.assertOutputLineMatch(regexp.MustCompile(`web\s+image\s+baseline\s+"nginx:1.25"`))
+	s.assertOutputLineMatch(regexp.MustCompile(`web\s+host\s+baseProperties\s+"web.example.com"`))
+	s.assertOutputLineMatch(regexp.MustCompile(`web\s+logLevel\s+properties\s+"warn"`))
+	s.assertOutputLineMatch(regexp.MustCompile(`web\s+replicas\s+properties\s+5`))
+	s.assertOutputLineMatch(regexp.MustCompile(`web\s+resources.cpu\s+environment\s+"1"`))
+	s.assertOutputLineMatch(regexp.MustCompile(`web\s+resources.memory\s+baseline\s+"128Mi"`))
+}
+
+func TestParamExplainFormats(t *testing.T) {
+	tests := []struct {
+		env      string
+		expected map[string]string
+	}{
+		{
+			env: "dev",
+			expected: map[string]string{
+				"image":            "baseline",
+				"host":             "baseProperties",
+				"logLevel":         "baseProperties",
+				"replicas":         "properties",
+				"resources.cpu":    "baseline",
+				"resources.memory": "baseline",
+			},
+		},
+		{
+			env: "_",
+			expected: map[string]string{
+				"image":            "baseline",
+				"host":             "baseProperties",
+				"logLevel":         "baseProperties",
+				"replicas":         "baseProperties",
+				"resources.cpu":    "baseline",
+				"resources.memory": "baseline",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.env, func(t *testing.T) {
+			s := newCustomScaffold(t, "testdata/projects/param-sources")
+			defer s.reset()
+			err := s.executeCommand("param", "explain", test.env, "-o", "json")
+			require.NoError(t, err)
+			var data []map[string]interface{}
+			require.NoError(t, s.jsonOutput(&data))
+			sources := map[string]string{}
+			for _, p := range data {
+				assert.Equal(t, "web", p["component"])
+				sources[p["name"].(string)] = p["source"].(string)
+			}
+			assert.Equal(t, test.expected, sources)
+		})
+	}
+}
+
+func TestParamExplainNegative(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"no env", []string{"param", "explain"}, `exactly one environment required, but provided: []`},
+		{"bad format", []string{"param", "explain", "dev", "-o", "table"}, `unsupported format "table"`},
+		{"bad env", []string{"param", "explain", "stage"}, `invalid environment "stage"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newCustomScaffold(t, "testdata/projects/param-sources")
+			defer s.reset()
+			err := s.executeCommand(test.args...)
+			require.Error(t, err)
+			assert.Equal(t, test.expected, err.Error())
+		})
+	}
+}
+
+func TestFlattenParams(t *testing.T) {
+	out := map[string]interface{}{}
+	flattenParams("", map[string]interface{}{
+		"a":   map[string]interface{}{"b": 1, "c.d": "x", "e": map[string]interface{}{}},
+		"l":   []interface{}{1, 2},
+		"n-1": nil,
+	}, out)
+	assert.Equal(t, map[string]interface{}{
+		".a.b":      1,
+		`.a["c.d"]`: "x",
+		".a.e":      map[string]interface{}{},
+		".l":        []interface{}{1, 2},
+		".n-1":      nil,
+	}, out)
+	assert.Equal(t, map[string]interface{}{"s": "v~", "n": float64(2), "b": false, "l": []interface{}{"x~"}},
+		perturb(map[string]interface{}{"s": "v", "n": float64(1), "b": true, "l": []interface{}{"x"}}))
+}
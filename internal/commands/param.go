@@ -37,10 +37,10 @@ var maxDisplayValueLength = 1024
 func newParamCommand(cp ctxProvider) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "param <subcommand>",
-		Short:   "parameter lists and diffs",
+		Short:   "parameter lists, diffs and explanations",
 		Aliases: []string{"params"},
 	}
-	cmd.AddCommand(newParamListCommand(cp), newParamDiffCommand(cp))
+	cmd.AddCommand(newParamListCommand(cp), newParamDiffCommand(cp), newParamExplainCommand(cp))
 	return cmd
 }
 
@@ -131,7 +131,10 @@ func doParamList(args []string, config paramListCommandConfig) error {
 	if len(args) != 1 {
 		return cmd.NewUsageError(fmt.Sprintf("exactly one environment required, but provided: %q", args))
 	}
-	env := args[0]
+	env, err := config.ResolveEnv(args[0])
+	if err != nil {
+		return err
+	}
 	if env != model.Baseline {
 		_, err := config.App().ServerURL(env)
 		if err != nil {
@@ -145,7 +148,7 @@ func doParamList(args []string, config paramListCommandConfig) error {
 	}
 	paramsObject, err := eval.Params(paramsFile, envCtx.EvalContext(cleanEvalMode))
 	if err != nil {
-		return err
+		return cmd.WithCode(cmd.ErrorCodeEval, err)
 	}
 	fp, err := config.filterFunc()
 	if err != nil {
@@ -192,6 +195,14 @@ func doParamDiff(args []string, config paramDiffCommandConfig) error {
 	default:
 		return cmd.NewUsageError("one or two environments required")
 	}
+	leftEnv, err := config.ResolveEnv(leftEnv)
+	if err != nil {
+		return err
+	}
+	rightEnv, err = config.ResolveEnv(rightEnv)
+	if err != nil {
+		return err
+	}
 
 	fp, err := config.filterFunc()
 	if err != nil {
@@ -211,7 +222,7 @@ func doParamDiff(args []string, config paramDiffCommandConfig) error {
 		}
 		paramsObject, err := eval.Params(paramsFile, envCtx.EvalContext(cleanEvalMode))
 		if err != nil {
-			return "", "", err
+			return "", "", cmd.WithCode(cmd.ErrorCodeEval, err)
 		}
 		components, err := extractComponentParams(paramsObject, fp)
 		if err != nil {
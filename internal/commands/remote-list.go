@@ -21,6 +21,7 @@ import (
 	"sort"
 	"time"
 
+	"github.com/spf13/cobra"
 	"github.com/splunk/qbec/internal/model"
 	"github.com/splunk/qbec/internal/remote"
 	"github.com/splunk/qbec/internal/sio"
@@ -41,6 +42,75 @@ type lister interface {
 	// deletions returns a list of objects to be deleted given a list of objects to be retained and
 	// a filter function that should return true for other objects if they can be deleted.
 	deletions(ignore []model.K8sLocalObject, filter listFilterFunc) ([]model.K8sQbecMeta, error)
+	// objects returns all remote objects that were listed. It must be called before deletions.
+	objects() ([]model.K8sQbecMeta, error)
+}
+
+// gcScope restricts garbage collection to objects in specific namespaces and of specific kinds, such that
+// unrelated namespaces and types are neither listed nor deleted.
+type gcScope struct {
+	namespaces   []string     // namespaces to list, cluster-scoped objects are not listed when set
+	kinds        model.Filter // kinds to list
+	includeOwned bool         // include objects owned by a controller
+}
+
+// addGCScopeFlags adds flags to restrict the scope of garbage collection to the supplied command and returns a
+// function that returns the scope.
+func addGCScopeFlags(c *cobra.Command) func() (gcScope, error) {
+	var namespaces, kinds []string
+	var includeOwned bool
+	c.Flags().StringArrayVar(&namespaces, "gc-namespace", nil, "limit garbage collection to objects in this namespace, cluster-scoped objects are not collected when specified")
+	c.Flags().StringArrayVar(&kinds, "gc-kind", nil, "limit garbage collection to objects of this kind")
+	c.Flags().BoolVar(&includeOwned, "gc-include-owned", false, "include objects that have a controller owner reference in garbage collection")
+	return func() (gcScope, error) {
+		kf, err := model.NewKindFilter(kinds, nil)
+		if err != nil {
+			return gcScope{}, err
+		}
+		return gcScope{namespaces: namespaces, kinds: kf, includeOwned: includeOwned}, nil
+	}
+}
+
+// restrict restricts the supplied list query config to the scope.
+func (g gcScope) restrict(cfg *remote.ListQueryConfig) {
+	cfg.IncludeOwned = g.includeOwned
+	if len(g.namespaces) > 0 {
+		ns := append([]string{}, g.namespaces...)
+		sort.Strings(ns)
+		cfg.Namespaces = ns
+		cfg.ClusterObjects = false
+	}
+	if g.kinds != nil && g.kinds.HasFilters() {
+		orig := cfg.KindFilter
+		cfg.KindFilter = func(gvk schema.GroupVersionKind) bool {
+			return g.kinds.ShouldInclude(gvk.Kind) && (orig == nil || orig(gvk))
+		}
+	}
+}
+
+// filter returns a filter that additionally excludes objects outside the namespaces of the scope. This is
+// required since cluster-scoped list queries return objects from all namespaces.
+func (g gcScope) filter(fn listFilterFunc) listFilterFunc {
+	if len(g.namespaces) == 0 {
+		return fn
+	}
+	return func(obj model.K8sQbecMeta, client model.Namespaced, defaultNS string) (bool, error) {
+		ns := obj.GetNamespace()
+		if ns == "" {
+			ns = defaultNS
+		}
+		found := false
+		for _, n := range g.namespaces {
+			if n == ns {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+		return fn(obj, client, defaultNS)
+	}
 }
 
 type stubLister struct{}
@@ -49,13 +119,19 @@ func (s *stubLister) start(ctx context.Context, config remote.ListQueryConfig) {
 func (s *stubLister) deletions(ignore []model.K8sLocalObject, filter listFilterFunc) ([]model.K8sQbecMeta, error) {
 	return nil, nil
 }
+func (s *stubLister) objects() ([]model.K8sQbecMeta, error) {
+	return nil, nil
+}
 
 type remoteLister struct {
+	log          sio.Logger
 	client       listClient
 	ch           chan listResult
 	cfg          remote.ListQueryConfig
 	defaultNS    string
 	unknownTypes map[schema.GroupVersionKind]bool
+	result       *listResult
+	scope        gcScope
 }
 
 type listResult struct {
@@ -64,7 +140,7 @@ type listResult struct {
 	err      error
 }
 
-func newRemoteLister(client listClient, allObjects []model.K8sLocalObject, defaultNs string) (*remoteLister, remote.ListQueryScope, error) {
+func newRemoteLister(ctx context.Context, client listClient, allObjects []model.K8sLocalObject, defaultNs string) (*remoteLister, remote.ListQueryScope, error) {
 	nsMap := map[string]bool{}
 	if defaultNs != "" {
 		nsMap[defaultNs] = true
@@ -77,7 +153,7 @@ func newRemoteLister(client listClient, allObjects []model.K8sLocalObject, defau
 		b, err := client.IsNamespaced(kind)
 		if err != nil {
 			if !unknown[kind] {
-				sio.Warnf("unable to get metadata for %v, continue\n", o.GroupVersionKind())
+				sio.For(ctx).Warnf("unable to get metadata for %v, continue\n", o.GroupVersionKind())
 				unknown[kind] = true
 			}
 			continue
@@ -99,6 +175,7 @@ func newRemoteLister(client listClient, allObjects []model.K8sLocalObject, defau
 	sort.Strings(nsList)
 
 	return &remoteLister{
+			log:          sio.For(ctx),
 			client:       client,
 			ch:           make(chan listResult, 1),
 			unknownTypes: unknown,
@@ -130,15 +207,34 @@ func (r *remoteLister) start(ctx context.Context, config remote.ListQueryConfig)
 	}()
 }
 
-func (r *remoteLister) deletions(all []model.K8sLocalObject, filter listFilterFunc) ([]model.K8sQbecMeta, error) {
-	if len(r.ch) == 0 {
-		sio.Debugln("waiting for deletion list to be returned")
+// wait waits for the list query to complete and returns its result.
+func (r *remoteLister) wait() listResult {
+	if r.result == nil {
+		if len(r.ch) == 0 {
+			r.log.Debugln("waiting for deletion list to be returned")
+		}
+		lr := <-r.ch
+		if lr.err == nil {
+			r.log.Debugf("server objects load took %v\n", lr.duration)
+		}
+		r.result = &lr
 	}
-	lr := <-r.ch
+	return *r.result
+}
+
+func (r *remoteLister) objects() ([]model.K8sQbecMeta, error) {
+	lr := r.wait()
+	if lr.err != nil {
+		return nil, lr.err
+	}
+	return lr.data.ToList(), nil
+}
+
+func (r *remoteLister) deletions(all []model.K8sLocalObject, filter listFilterFunc) ([]model.K8sQbecMeta, error) {
+	lr := r.wait()
 	if lr.err != nil {
 		return nil, lr.err
 	}
-	sio.Debugf("server objects load took %v\n", lr.duration)
 
 	cfg := r.cfg
 
@@ -159,6 +255,7 @@ func (r *remoteLister) deletions(all []model.K8sLocalObject, filter listFilterFu
 	}
 
 	retained := coll.ToList()
+	filter = r.scope.filter(filter)
 	var ret []model.K8sQbecMeta
 	for _, o := range retained {
 		flag, err := filter(o, r.client, r.defaultNS)
@@ -0,0 +1,44 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/remote"
+)
+
+// addRetryFlags adds flags for retrying object operations that fail with transient server errors to the supplied
+// command and returns a function to get the retry policy once flags are parsed.
+func addRetryFlags(c *cobra.Command) func() (remote.RetryPolicy, error) {
+	var p remote.RetryPolicy
+	c.Flags().IntVar(&p.Retries, "retries", 0, "number of times an object operation is retried when the server returns a transient error like 429 or 5xx")
+	c.Flags().DurationVar(&p.Backoff, "retry-backoff", time.Second, "delay before the first retry, doubled for every subsequent retry")
+	c.Flags().DurationVar(&p.MaxBackoff, "retry-max-backoff", 30*time.Second, "maximum delay between retries")
+	return func() (remote.RetryPolicy, error) {
+		if p.Retries < 0 {
+			return p, cmd.NewUsageError(fmt.Sprintf("invalid retries: %d, must not be negative", p.Retries))
+		}
+		if p.Backoff < 0 || p.MaxBackoff < 0 {
+			return p, cmd.NewUsageError("retry backoff durations must not be negative")
+		}
+		return p, nil
+	}
+}
@@ -0,0 +1,117 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/splunk/qbec/internal/sio"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// rollbackEntry records how to undo the change made to a single object.
+type rollbackEntry struct {
+	obj      model.K8sLocalObject       // the object as applied
+	previous *unstructured.Unstructured // the remote object before the change, nil if it was created
+}
+
+// rollbackRecorder records the state of objects before they are changed by apply, such that the changes
+// can be undone when the applied objects fail to become healthy.
+type rollbackRecorder struct {
+	client  cmd.KubeClient
+	entries []rollbackEntry
+}
+
+// snapshot returns the current remote state of the supplied object, or nil if it does not exist.
+func (r *rollbackRecorder) snapshot(ctx context.Context, ob model.K8sLocalObject) (*unstructured.Unstructured, error) {
+	if ob.GetName() == "" { // generated names are always created
+		return nil, nil
+	}
+	prev, err := r.client.Get(ctx, ob)
+	if err != nil {
+		if err == remote.ErrNotFound {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "get %s for rollback", r.client.DisplayName(ob))
+	}
+	return prev, nil
+}
+
+// record adds the supplied object to the list of rollback entries if it was created or updated.
+func (r *rollbackRecorder) record(ob model.K8sLocalObject, previous *unstructured.Unstructured, res *remote.SyncResult) {
+	switch res.Type {
+	case remote.SyncCreated:
+		r.entries = append(r.entries, rollbackEntry{obj: ob})
+	case remote.SyncUpdated:
+		if previous != nil {
+			r.entries = append(r.entries, rollbackEntry{obj: ob, previous: previous})
+		}
+	}
+}
+
+// rollback undoes all recorded changes in reverse order, deleting objects that were created and re-applying
+// the previously applied configuration of objects that were updated.
+func (r *rollbackRecorder) rollback(ctx context.Context) error {
+	log := sio.For(ctx)
+	if len(r.entries) == 0 {
+		return nil
+	}
+	log.Warnf("rolling back %d object(s)\n", len(r.entries))
+	var failed int
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		e := r.entries[i]
+		name := r.client.DisplayName(e.obj)
+		if err := r.undo(ctx, e); err != nil {
+			log.Errorf("rollback %s failed: %v\n", name, err)
+			failed++
+			continue
+		}
+		if e.previous == nil {
+			log.Noticef("rollback: delete %s\n", name)
+		} else {
+			log.Noticef("rollback: restore %s\n", name)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("rollback failed for %d object(s)", failed)
+	}
+	return nil
+}
+
+func (r *rollbackRecorder) undo(ctx context.Context, e rollbackEntry) error {
+	if e.previous == nil {
+		_, err := r.client.Delete(ctx, e.obj, remote.DeleteOptions{})
+		return err
+	}
+	pristine := remote.GetPristineVersionForRollback(e.previous)
+	if pristine == nil {
+		return fmt.Errorf("no previously applied configuration found")
+	}
+	prev := model.NewK8sLocalObject(pristine.Object, model.LocalAttrs{
+		App:       e.obj.Application(),
+		Tag:       e.obj.Tag(),
+		Component: e.obj.Component(),
+		Env:       e.obj.Environment(),
+	})
+	_, err := r.client.Sync(ctx, prev, remote.SyncOptions{})
+	return err
+}
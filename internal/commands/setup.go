@@ -119,7 +119,10 @@ Use "%s options" for a list of global options available to all commands.
 `, rootCmd)
 }
 
-var expectedFiles = []string{"qbec.yaml"}
+// appFile returns the file that defines the app in the current directory.
+func appFile() (string, error) {
+	return model.FindAppFile(".")
+}
 
 // setWorkDir sets the working dir of the current process as the top-level
 // directory of the source tree. The current working directory of the process
@@ -127,12 +130,12 @@ var expectedFiles = []string{"qbec.yaml"}
 // then it is returned provided it is a valid root.
 func setWorkDir(specified string) error {
 	isRootDir := func(dir string) bool {
-		for _, f := range expectedFiles {
-			if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
-				return false
+		for _, f := range model.AppFiles {
+			if _, err := os.Stat(filepath.Join(dir, f)); err == nil {
+				return true
 			}
 		}
-		return true
+		return false
 	}
 	cwd, err := os.Getwd()
 	if err != nil {
@@ -174,11 +177,13 @@ func setWorkDir(specified string) error {
 }
 
 var noQbecContext = map[string]bool{
-	"version":    true,
-	"init":       true,
-	"completion": true,
-	"options":    true,
-	"fmt":        true,
+	"version":                       true,
+	"init":                          true,
+	"completion":                    true,
+	"options":                       true,
+	"fmt":                           true,
+	cobra.ShellCompRequestCmd:       true, // completion functions load the app themselves when needed
+	cobra.ShellCompNoDescRequestCmd: true,
 }
 
 func doSetup(root *cobra.Command, opts cmd.Options) {
@@ -193,21 +198,30 @@ func doSetup(root *cobra.Command, opts cmd.Options) {
 		defer func() {
 			outErr = cmd.WrapError(outErr)
 		}()
+		// the argo-render command takes its options from environment variables set by ArgoCD
+		if c.Name() == "argo-render" {
+			if err := applyArgoEnv(c.Flags()); err != nil {
+				return err
+			}
+		}
 		ctx, err := ccFn()
 		if err != nil {
 			return err
 		}
 		sio.EnableColors(ctx.Colorize())
+		sio.EnableQuiet(ctx.Quiet())
+		sio.EnableJSON(ctx.JSONLogs())
 		cmd.RegisterSignalHandlers()
 
 		skipApp := noQbecContext[c.Name()]
-		// for the eval command, require qbec machinery only if the env option is specified
+		// for the eval command, require qbec machinery only if an environment is specified using the env option
+		// or as the first of two arguments
 		if c.Name() == "eval" {
 			e, err := c.Flags().GetString("env")
 			if err != nil {
 				return err
 			}
-			if e == "" {
+			if e == "" && len(args) < 2 {
 				skipApp = true
 			}
 		}
@@ -243,7 +257,11 @@ func doSetup(root *cobra.Command, opts cmd.Options) {
 		if err := setWorkDir(ctx.RootDir()); err != nil {
 			return err
 		}
-		app, err := model.NewApp("qbec.yaml", envFiles, ctx.AppTag())
+		file, err := appFile()
+		if err != nil {
+			return err
+		}
+		app, err := model.NewAppWithVars(file, envFiles, ctx.AppTag(), ctx.InterpolationVars())
 		if err != nil {
 			return err
 		}
@@ -265,8 +283,13 @@ func doSetup(root *cobra.Command, opts cmd.Options) {
 	})
 }
 
+// Version returns the qbec version.
+func Version() string {
+	return version
+}
+
 // Setup sets up all sub-commands for the supplied root command and adds facilities for commands
 // to access common options.
 func Setup(root *cobra.Command) {
-	doSetup(root, cmd.Options{})
+	doSetup(root, cmd.Options{Version: version})
 }
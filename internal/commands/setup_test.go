@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"regexp"
 	"testing"
 
 	"github.com/spf13/cobra"
@@ -71,6 +72,7 @@ func TestOptionsCommand(t *testing.T) {
 		"--eval-concurrency",
 		"--force:k8s-context",
 		"--k8s:as",
+		"--max-data-source-bytes",
 		"--root",
 		"--strict-vars",
 		"--verbose",
@@ -272,3 +274,41 @@ func TestSetupEnvironments(t *testing.T) {
 
 	}
 }
+
+func TestSetupJsonnetApp(t *testing.T) {
+	s := newCustomScaffold(t, "testdata/projects/jsonnet-app/components")
+	defer s.reset()
+	err := s.executeCommand("env", "list")
+	require.NoError(t, err)
+	assert.Equal(t, "dev-eu-west\ndev-us-east\nprod-eu-west\nprod-us-east\n", s.stdout())
+
+	s.reset()
+	s = newCustomScaffold(t, "testdata/projects/jsonnet-app")
+	err = s.executeCommand("show", "prod-eu-west")
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`^\s+region: eu-west$`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^\s+stage: prod$`))
+}
+
+func TestAppFile(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer func() { _ = os.Chdir(wd) }()
+	a := assert.New(t)
+
+	_, err = appFile()
+	require.Error(t, err)
+	a.Equal("none of qbec.yaml, qbec.jsonnet found", err.Error())
+
+	require.NoError(t, os.WriteFile("qbec.jsonnet", []byte("{}"), 0644))
+	f, err := appFile()
+	require.NoError(t, err)
+	a.Equal("qbec.jsonnet", f)
+
+	require.NoError(t, os.WriteFile("qbec.yaml", []byte("{}"), 0644))
+	_, err = appFile()
+	require.Error(t, err)
+	a.Equal("multiple app files found: qbec.yaml, qbec.jsonnet, only one is allowed", err.Error())
+}
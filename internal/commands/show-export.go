@@ -0,0 +1,96 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/splunk/qbec/internal/objyaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// clusterScopeDir is the directory name used for objects that do not have a namespace.
+const clusterScopeDir = "_cluster"
+
+// exportedObject is an object to be written to its own file, along with the component that produced it.
+type exportedObject struct {
+	component string
+	obj       *unstructured.Unstructured
+}
+
+// exportPath returns the path of the file for the supplied object relative to the export directory, in the form
+// component/namespace/kind-name.ext
+func exportPath(eo exportedObject, ext string) string {
+	ns := eo.obj.GetNamespace()
+	if ns == "" {
+		ns = clusterScopeDir
+	}
+	name := eo.obj.GetName()
+	if name == "" {
+		name = strings.TrimSuffix(eo.obj.GetGenerateName(), "-")
+	}
+	file := fmt.Sprintf("%s-%s.%s", strings.ToLower(eo.obj.GetKind()), name, ext)
+	return filepath.Join(eo.component, ns, file)
+}
+
+// exportObjects writes every supplied object to its own file under the supplied directory, which must either
+// not exist or be empty such that files for objects that no longer exist are never left behind. It returns an
+// error without writing any files if two objects map to the same file.
+func exportObjects(dir string, objects []exportedObject, format string, order objyaml.FieldOrder) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("export directory %s is not empty", dir)
+	}
+	paths := map[string]string{}
+	for _, eo := range objects {
+		p := exportPath(eo, format)
+		display := fmt.Sprintf("%s %s/%s", eo.obj.GetAPIVersion(), eo.obj.GetKind(), eo.obj.GetName())
+		if prev, ok := paths[p]; ok {
+			return fmt.Errorf("objects %s and %s would both be exported to %s", prev, display, p)
+		}
+		paths[p] = display
+	}
+	for _, eo := range objects {
+		var b []byte
+		switch format {
+		case "json":
+			b, err = json.MarshalIndent(eo.obj, "", "  ")
+			b = append(b, '\n')
+		default:
+			b, err = objyaml.Marshal(eo.obj, order)
+		}
+		if err != nil {
+			return err
+		}
+		file := filepath.Join(dir, exportPath(eo, format))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(file, b, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func exportTestObject(apiVersion, kind, namespace, name, generateName string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetGenerateName(generateName)
+	return obj
+}
+
+func TestExportPath(t *testing.T) {
+	a := assert.New(t)
+	a.Equal(filepath.Join("c1", "ns1", "configmap-foo.yaml"),
+		exportPath(exportedObject{component: "c1", obj: exportTestObject("v1", "ConfigMap", "ns1", "foo", "")}, "yaml"))
+	a.Equal(filepath.Join("c1", "_cluster", "namespace-ns1.json"),
+		exportPath(exportedObject{component: "c1", obj: exportTestObject("v1", "Namespace", "", "ns1", "")}, "json"))
+	a.Equal(filepath.Join("c1", "ns1", "job-migrate.yaml"),
+		exportPath(exportedObject{component: "c1", obj: exportTestObject("batch/v1", "Job", "ns1", "", "migrate-")}, "yaml"))
+}
+
+func TestExportObjectsConflict(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+	err := exportObjects(dir, []exportedObject{
+		{component: "c1", obj: exportTestObject("extensions/v1beta1", "Ingress", "ns1", "foo", "")},
+		{component: "c1", obj: exportTestObject("networking.k8s.io/v1", "Ingress", "ns1", "foo", "")},
+	}, "yaml", "")
+	require.Error(t, err)
+	assert.Equal(t, "objects extensions/v1beta1 Ingress/foo and networking.k8s.io/v1 Ingress/foo would both be exported to "+
+		filepath.Join("c1", "ns1", "ingress-foo.yaml"), err.Error())
+	assert.NoDirExists(t, dir)
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/splunk/qbec/internal/cmd"
 	"github.com/splunk/qbec/internal/model"
 	"github.com/splunk/qbec/internal/objsort"
+	"github.com/splunk/qbec/internal/objyaml"
 	"github.com/splunk/qbec/internal/sio"
 	"github.com/splunk/qbec/internal/types"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -90,7 +91,10 @@ type showCommandConfig struct {
 	format          string
 	formatSpecified bool
 	sortAsApply     bool
+	noSort          bool
 	namesOnly       bool
+	fieldOrder      string
+	exportDir       string
 	filterFunc      func() (model.Filters, error)
 }
 
@@ -131,11 +135,18 @@ func doShow(ctx context.Context, args []string, config showCommandConfig) error
 	if len(args) != 1 {
 		return cmd.NewUsageError(fmt.Sprintf("exactly one environment required, but provided: %q", args))
 	}
-	env := args[0]
+	env, err := config.ResolveEnv(args[0])
+	if err != nil {
+		return err
+	}
 	format := config.format
 	if format != "json" && format != "yaml" {
 		return cmd.NewUsageError(fmt.Sprintf("invalid output format: %q", format))
 	}
+	fieldOrder, err := objyaml.ParseFieldOrder(config.fieldOrder)
+	if err != nil {
+		return cmd.NewUsageError(err.Error())
+	}
 	fp, err := config.filterFunc()
 	if err != nil {
 		return err
@@ -148,12 +159,20 @@ func doShow(ctx context.Context, args []string, config showCommandConfig) error
 		return fmt.Sprintf("%s:%s:%s:%s", gvk.Group, gvk.Kind, ns, obj.GetName())
 	}
 
+	if config.noSort && config.sortAsApply {
+		return cmd.NewUsageError("cannot specify both --no-sort and --sort-apply")
+	}
+	if config.namesOnly && config.exportDir != "" {
+		return cmd.NewUsageError("cannot specify both --objects and --export")
+	}
+
 	envCtx, err := config.EnvContext(env)
 	if err != nil {
 		return err
 	}
 
-	objects, err := generateObjects(ctx, envCtx, filterOpts{keyFunc: keyFunc, filters: fp})
+	preserveOrder := !config.sortAsApply && (config.noSort || config.App().PreserveObjectOrder())
+	objects, err := generateObjects(ctx, envCtx, filterOpts{keyFunc: keyFunc, filters: fp, preserveOrder: preserveOrder}.withPolicies())
 	if err != nil {
 		return err
 	}
@@ -191,6 +210,18 @@ func doShow(ctx context.Context, args []string, config showCommandConfig) error
 		displayObjects = append(displayObjects, mapper(o))
 	}
 
+	if config.exportDir != "" {
+		var exported []exportedObject
+		for i, o := range objects {
+			exported = append(exported, exportedObject{component: o.Component(), obj: displayObjects[i]})
+		}
+		if err := exportObjects(config.exportDir, exported, format, fieldOrder); err != nil {
+			return err
+		}
+		sio.Noticef("exported %d object(s) to %s\n", len(exported), config.exportDir)
+		return nil
+	}
+
 	switch format {
 	case "json":
 		encoder := json.NewEncoder(config.Stdout())
@@ -198,7 +229,7 @@ func doShow(ctx context.Context, args []string, config showCommandConfig) error
 		return encoder.Encode(displayObjects)
 	default:
 		for _, o := range displayObjects {
-			b, err := yaml.Marshal(o)
+			b, err := objyaml.Marshal(o, fieldOrder)
 			if err != nil {
 				return err
 			}
@@ -219,16 +250,23 @@ func newShowCommand(cp ctxProvider) *cobra.Command {
 	config := showCommandConfig{
 		filterFunc: addFilterParams(c, true),
 	}
+	addComponents := addComponentDirParams(c)
 
 	var clean bool
 	c.Flags().StringVarP(&config.format, "format", "o", "yaml", "Output format. Supported values are: json, yaml")
 	c.Flags().BoolVarP(&config.namesOnly, "objects", "O", false, "Only print names of objects instead of their contents")
 	c.Flags().BoolVar(&config.sortAsApply, "sort-apply", false, "sort output in apply order (requires cluster access)")
+	c.Flags().BoolVar(&config.noSort, "no-sort", false, "show objects in the order in which they are emitted by components")
+	c.Flags().StringVar(&config.fieldOrder, "field-order", string(objyaml.Alphabetical), "order of object fields in YAML output, one of alpha or kubectl (apiVersion, kind and metadata first)")
+	c.Flags().StringVar(&config.exportDir, "export", "", "write every object to its own file under the supplied directory, organized as component/namespace/kind-name")
 	c.Flags().BoolVar(&clean, "clean", false, "do not display qbec-generated labels and annotations")
 	c.Flags().BoolVarP(&config.showSecrets, "show-secrets", "S", false, "do not obfuscate secret values in the output")
 
 	c.RunE = func(c *cobra.Command, args []string) error {
 		config.AppContext = cp()
+		if err := addComponents(config.App()); err != nil {
+			return cmd.WrapError(err)
+		}
 		config.formatSpecified = c.Flags().Changed("format")
 		cleanEvalMode = clean
 		return cmd.WrapError(doShow(c.Context(), args, config))
@@ -17,12 +17,18 @@
 package commands
 
 import (
+	"archive/zip"
 	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
 
 	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -67,6 +73,115 @@ func TestShowBasicClean(t *testing.T) {
 	a.True(pos1 < pos2) // namespace before psp in std sort
 }
 
+func TestShowKubectlFieldOrder(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("show", "dev", "-c", "service2", "-k", "deployment", "--field-order", "kubectl")
+	require.NoError(t, err)
+	out := s.stdout()
+	a := assert.New(t)
+	a.True(strings.HasPrefix(out, "---\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: svc2-deploy\n  namespace: bar-system\n  labels:\n"), out)
+	a.True(strings.Index(out, "\n  annotations:") < strings.Index(out, "\nspec:"))
+}
+
+func TestShowPolicies(t *testing.T) {
+	t.Run("warn", func(t *testing.T) {
+		s := newCustomScaffold(t, "testdata/projects/object-policies")
+		defer s.reset()
+		err := s.executeCommand("show", "local")
+		require.NoError(t, err)
+		a := assert.New(t)
+		a.Contains(s.stderr(), "policy images: apps/Deployment web (component: web): image nginx is not from registry.example.com")
+		a.Contains(s.stderr(), "policy owner: ConfigMap web-config (component: web): config map web-config has no owner label")
+		a.NotContains(s.stderr(), "policy limits")
+		a.Contains(s.stdout(), "name: web-config")
+	})
+	t.Run("deny", func(t *testing.T) {
+		s := newCustomScaffold(t, "testdata/projects/object-policies")
+		defer s.reset()
+		err := s.executeCommand("show", "prod")
+		require.Error(t, err)
+		a := assert.New(t)
+		a.Equal("1 policy violation(s) found", err.Error())
+		a.Contains(s.stderr(), "policy limits: apps/Deployment web (component: web): container main has no resource limits")
+		a.Equal("", s.stdout())
+	})
+	t.Run("filtered", func(t *testing.T) {
+		s := newCustomScaffold(t, "testdata/projects/object-policies")
+		defer s.reset()
+		err := s.executeCommand("show", "prod", "-k", "configmaps")
+		require.NoError(t, err)
+	})
+}
+
+func TestShowParamsSchema(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		s := newCustomScaffold(t, "testdata/projects/params-schema")
+		defer s.reset()
+		err := s.executeCommand("show", "dev")
+		require.NoError(t, err)
+		assert.Contains(t, s.stdout(), "name: web")
+	})
+	t.Run("invalid", func(t *testing.T) {
+		s := newCustomScaffold(t, "testdata/projects/params-schema")
+		defer s.reset()
+		err := s.executeCommand("show", "prod")
+		require.Error(t, err)
+		a := assert.New(t)
+		a.Equal("2 parameter validation error(s) found", err.Error())
+		a.Equal(cmd.ErrorCodeEval, cmd.ErrorCode(err))
+		a.Contains(s.stderr(), "prod: components.web.imag in body is a forbidden property")
+		a.Contains(s.stderr(), "prod: components.web.replicas in body must be of type integer")
+		a.Equal("", s.stdout())
+	})
+	t.Run("not-selected", func(t *testing.T) {
+		s := newCustomScaffold(t, "testdata/projects/params-schema")
+		defer s.reset()
+		err := s.executeCommand("show", "prod", "-c", "other")
+		require.NoError(t, err)
+	})
+}
+
+func TestShowExport(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	dir := filepath.Join(t.TempDir(), "out")
+	err := s.executeCommand("show", "dev", "--export", dir, "--clean")
+	require.NoError(t, err)
+	a := assert.New(t)
+	a.Equal("", s.stdout())
+	var files []string
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	require.NoError(t, err)
+	a.Equal([]string{
+		"cluster-objects/_cluster/clusterrole-allow-root-psp-policy.yaml",
+		"cluster-objects/_cluster/clusterrolebinding-allow-root-psp-policy.yaml",
+		"cluster-objects/_cluster/clusterrolebinding-default-psp-policy.yaml",
+		"cluster-objects/_cluster/namespace-bar-system.yaml",
+		"cluster-objects/_cluster/namespace-foo-system.yaml",
+		"cluster-objects/_cluster/podsecuritypolicy-100-default.yaml",
+		"cluster-objects/_cluster/podsecuritypolicy-200-allow-root.yaml",
+		"service2/bar-system/configmap-svc2-cm.yaml",
+		"service2/bar-system/deployment-svc2-deploy.yaml",
+		"service2/bar-system/secret-svc2-secret.yaml",
+		"test-job/_cluster/job-tj.yaml",
+	}, files)
+	b, err := ioutil.ReadFile(filepath.Join(dir, "service2", "bar-system", "deployment-svc2-deploy.yaml"))
+	require.NoError(t, err)
+	a.True(strings.HasPrefix(string(b), "apiVersion: apps/v1\n"))
+	a.NotContains(string(b), "qbec.io/")
+
+	err = s.executeCommand("show", "dev", "--export", dir)
+	require.Error(t, err)
+	a.Equal(fmt.Sprintf("export directory %s is not empty", dir), err.Error())
+}
+
 func TestShowApplySort(t *testing.T) {
 	s := newScaffold(t)
 	defer s.reset()
@@ -115,6 +230,50 @@ func TestShowObjects(t *testing.T) {
 	s.assertOutputLineMatch(regexp.MustCompile(`cluster-objects\s+Namespace\s+bar-system`))
 }
 
+func TestShowNoSort(t *testing.T) {
+	namespaces := func(args ...string) []string {
+		s := newScaffold(t)
+		defer s.reset()
+		err := s.executeCommand(append([]string{"show", "dev", "-c", "cluster-objects", "-k", "namespace", "-O", "-o", "json"}, args...)...)
+		require.NoError(t, err)
+		var data []map[string]interface{}
+		err = s.jsonOutput(&data)
+		require.NoError(t, err)
+		var ret []string
+		for _, d := range data {
+			ret = append(ret, d["name"].(string))
+		}
+		return ret
+	}
+	assert.Equal(t, []string{"bar-system", "foo-system"}, namespaces())
+	assert.Equal(t, []string{"foo-system", "bar-system"}, namespaces("--no-sort"))
+}
+
+func TestShowNoSortWithApplySort(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("show", "dev", "--no-sort", "--sort-apply")
+	require.Error(t, err)
+	assert.True(t, cmd.IsUsageError(err))
+	assert.Equal(t, "cannot specify both --no-sort and --sort-apply", err.Error())
+}
+
+func TestShowAnnotateSource(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("show", "dev", "-c", "cluster-objects", "-o", "json", "--annotate-source")
+	require.NoError(t, err)
+	var data []map[string]interface{}
+	err = s.jsonOutput(&data)
+	require.NoError(t, err)
+	require.True(t, len(data) > 0)
+	for _, d := range data {
+		anns := d["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})
+		assert.Contains(t, anns, model.QbecNames.SourceAnnotations.QbecVersion)
+		assert.NotContains(t, anns, model.QbecNames.SourceAnnotations.RenderedTime)
+	}
+}
+
 func TestShowObjectsAsYAML(t *testing.T) {
 	s := newScaffold(t)
 	defer s.reset()
@@ -240,6 +399,22 @@ func TestShowOpenStringSecrets(t *testing.T) {
 	s.assertOutputLineMatch(regexp.MustCompile(secretValue))
 }
 
+func TestShowRedactions(t *testing.T) {
+	s := newCustomScaffold(t, "testdata/projects/redactions")
+	defer s.reset()
+	err := s.executeCommand("show", "local")
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`basicAuthPassword: redacted\.`))
+	s.assertOutputLineNoMatch(regexp.MustCompile("changeme"))
+	s.assertOutputLineMatch(regexp.MustCompile("url: http://prometheus:9090"))
+
+	s.reset()
+	s = newCustomScaffold(t, "testdata/projects/redactions")
+	err = s.executeCommand("show", "local", "-S")
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile("basicAuthPassword: changeme"))
+}
+
 func TestShowNegative(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -265,6 +440,24 @@ func TestShowNegative(t *testing.T) {
 				a.Equal("exactly one environment required, but provided: [\"dev\" \"prod\"]", err.Error())
 			},
 		},
+		{
+			name: "export with objects",
+			args: []string{"show", "dev", "-O", "--export", "out"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.True(cmd.IsUsageError(err))
+				a.Equal("cannot specify both --objects and --export", err.Error())
+			},
+		},
+		{
+			name: "bad field order",
+			args: []string{"show", "dev", "--field-order", "random"},
+			asserter: func(s *scaffold, err error) {
+				a := assert.New(s.t)
+				a.True(cmd.IsUsageError(err))
+				a.Equal(`invalid field order "random", must be one of "alpha" or "kubectl"`, err.Error())
+			},
+		},
 		{
 			name: "empty string env",
 			args: []string{"apply", ""},
@@ -340,3 +533,79 @@ func TestShowNegative(t *testing.T) {
 		})
 	}
 }
+
+func TestShowLibraryBundle(t *testing.T) {
+	dir := copyProject(t, "testdata/projects/simple-service")
+	f, err := os.Create(filepath.Join(dir, "lib.zip"))
+	require.NoError(t, err)
+	w := zip.NewWriter(f)
+	fw, err := w.Create("cm.libsonnet")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte(`{ configMap(name):: { apiVersion: 'v1', kind: 'ConfigMap', metadata: { name: name }, data: { from: 'bundle' } } }`))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	qbecFile := filepath.Join(dir, "qbec.yaml")
+	b, err := ioutil.ReadFile(qbecFile)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(qbecFile, append(b, []byte("  libraryBundles:\n    common: lib.zip\n")...), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "components", "bundled.jsonnet"),
+		[]byte(`(import 'qbec-lib/common/cm.libsonnet').configMap('bundled-cm')`), 0644))
+
+	s := newCustomScaffold(t, dir)
+	defer s.reset()
+	err = s.executeCommand("show", "local", "-c", "bundled")
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`^\s+name: bundled-cm`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^\s+from: bundle`))
+}
+
+func TestShowComponentDir(t *testing.T) {
+	extra := filepath.Join(t.TempDir(), "adhoc")
+	require.NoError(t, os.Mkdir(extra, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(extra, "index.jsonnet"),
+		[]byte(`{ apiVersion: 'v1', kind: 'ConfigMap', metadata: { name: 'adhoc-cm' }, data: { env: std.extVar('qbec.io/env') } }`), 0644))
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("show", "dev", "-c", "adhoc", "--component-dir", extra)
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`^\s+name: adhoc-cm`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^\s+env: dev`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^\s+qbec.io/component: adhoc`))
+}
+
+func TestShowComponentDirNegative(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	err := s.executeCommand("show", "dev", "--component-dir", "components/service2.jsonnet")
+	require.Error(t, err)
+	assert.True(t, cmd.IsUsageError(err))
+	assert.Equal(t, "duplicate component service2, found components/service2.jsonnet and components/service2.jsonnet", err.Error())
+}
+
+func TestShowDataSourceRecordReplay(t *testing.T) {
+	dir := copyProject(t, "../../examples/external-data-app")
+	recordings := t.TempDir()
+
+	s := newCustomScaffold(t, dir)
+	err := s.executeCommand("show", "local", "--ds-record", recordings)
+	s.reset()
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`^\s+path: /some/path`))
+
+	// replay works without the data source implementation
+	require.NoError(t, os.Remove(filepath.Join(dir, "config-map.sh")))
+	s = newCustomScaffold(t, dir)
+	err = s.executeCommand("show", "local", "--ds-replay", recordings)
+	s.reset()
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`^\s+path: /some/path`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^\s+name: my-cm-local`))
+
+	s = newCustomScaffold(t, dir)
+	err = s.executeCommand("show", "local", "--ds-replay", t.TempDir())
+	s.reset()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no recorded output for path /some/path")
+}
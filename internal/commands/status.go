@@ -0,0 +1,230 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/objsort"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/splunk/qbec/internal/rollout"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// object statuses reported by the status command
+const (
+	objectStatusReady    = "ready"
+	objectStatusNotReady = "not-ready"
+	objectStatusFailed   = "failed"
+	objectStatusMissing  = "missing"
+	objectStatusUnknown  = "-"
+)
+
+// lastApplied is the metadata recorded on an object when it was last applied.
+type lastApplied struct {
+	Commit      string `json:"commit,omitempty"`
+	Dirty       string `json:"dirty,omitempty"`
+	QbecVersion string `json:"qbecVersion,omitempty"`
+	RenderedAt  string `json:"renderedAt,omitempty"`
+	ContentHash string `json:"contentHash,omitempty"`
+}
+
+// objectStatus is the status of a single remote object.
+type objectStatus struct {
+	Component   string       `json:"component"`
+	APIVersion  string       `json:"apiVersion"`
+	Kind        string       `json:"kind"`
+	Namespace   string       `json:"namespace,omitempty"`
+	Name        string       `json:"name"`
+	Status      string       `json:"status"`
+	Details     string       `json:"details,omitempty"`
+	LastApplied *lastApplied `json:"lastApplied,omitempty"`
+}
+
+type statusCommandConfig struct {
+	cmd.AppContext
+	format     string
+	filterFunc func() (model.Filters, error)
+}
+
+func lastAppliedFrom(anns map[string]string) *lastApplied {
+	names := model.QbecNames.SourceAnnotations
+	ret := lastApplied{
+		Commit:      anns[names.Commit],
+		Dirty:       anns[names.Dirty],
+		QbecVersion: anns[names.QbecVersion],
+		RenderedAt:  anns[names.RenderedTime],
+		ContentHash: anns[model.QbecNames.ContentHash],
+	}
+	if ret == (lastApplied{}) {
+		return nil
+	}
+	return &ret
+}
+
+// getObjectStatus returns the status of the supplied remote object.
+func getObjectStatus(ctx context.Context, client cmd.KubeClient, ob model.K8sQbecMeta, exprs map[schema.GroupKind]string) objectStatus {
+	ret := objectStatus{
+		Component:  ob.Component(),
+		APIVersion: ob.GroupVersionKind().GroupVersion().String(),
+		Kind:       ob.GetKind(),
+		Namespace:  ob.GetNamespace(),
+		Name:       ob.GetName(),
+		Status:     objectStatusUnknown,
+	}
+	un, err := client.Get(ctx, ob)
+	if err != nil {
+		if err == remote.ErrNotFound {
+			ret.Status = objectStatusMissing
+			return ret
+		}
+		ret.Status = objectStatusFailed
+		ret.Details = err.Error()
+		return ret
+	}
+	ret.LastApplied = lastAppliedFrom(un.GetAnnotations())
+	live := model.NewK8sObject(un.Object)
+	fn, err := rollout.StatusFuncFor(live, exprs)
+	if err != nil {
+		ret.Status = objectStatusFailed
+		ret.Details = err.Error()
+		return ret
+	}
+	if fn == nil {
+		return ret
+	}
+	status, err := fn(un, 0)
+	if err != nil {
+		ret.Status = objectStatusFailed
+		ret.Details = err.Error()
+		return ret
+	}
+	ret.Status = objectStatusNotReady
+	if status.Done {
+		ret.Status = objectStatusReady
+	}
+	ret.Details = status.Description
+	return ret
+}
+
+func listObjectStatuses(statuses []objectStatus, format string, w io.Writer) error {
+	switch format {
+	case "":
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "COMPONENT\tKIND\tNAMESPACE\tNAME\tSTATUS\tCOMMIT\tDETAILS")
+		for _, s := range statuses {
+			commit := "-"
+			if s.LastApplied != nil && s.LastApplied.Commit != "" {
+				commit = s.LastApplied.Commit
+				if len(commit) > 8 {
+					commit = commit[:8]
+				}
+			}
+			ns := s.Namespace
+			if ns == "" {
+				ns = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Component, s.Kind, ns, s.Name, s.Status, commit, s.Details)
+		}
+		return tw.Flush()
+	case "yaml":
+		b, err := yaml.Marshal(statuses)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "---")
+		fmt.Fprintf(w, "%s\n", b)
+		return nil
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(statuses)
+	default:
+		return cmd.NewUsageError(fmt.Sprintf("listObjectStatuses: unsupported format %q", format))
+	}
+}
+
+func doStatus(ctx context.Context, args []string, config statusCommandConfig) error {
+	if len(args) != 1 {
+		return cmd.NewUsageError(fmt.Sprintf("exactly one environment required, but provided: %q", args))
+	}
+	env, err := config.ResolveEnv(args[0])
+	if err != nil {
+		return err
+	}
+	if env == model.Baseline {
+		return cmd.NewUsageError("cannot get status for baseline environment, use a real environment")
+	}
+	if config.format != "" && config.format != "json" && config.format != "yaml" {
+		return cmd.NewUsageError(fmt.Sprintf("invalid output format: %q", config.format))
+	}
+	fp, err := config.filterFunc()
+	if err != nil {
+		return err
+	}
+	envCtx, err := config.EnvContext(env)
+	if err != nil {
+		return err
+	}
+	client, err := envCtx.Client()
+	if err != nil {
+		return err
+	}
+	lister, _, err := startRemoteList(ctx, envCtx, client, fp, remoteListOptions{})
+	if err != nil {
+		return err
+	}
+	// with no objects retained, the deletion list is the list of all remote objects that match the filters
+	objects, err := lister.deletions(nil, fp.Match)
+	if err != nil {
+		return err
+	}
+	objects = objsort.SortMeta(objects, sortConfig(client.IsNamespaced))
+	exprs := config.App().WaitStatusExpressions()
+	statuses := []objectStatus{}
+	for _, ob := range objects {
+		statuses = append(statuses, getObjectStatus(ctx, client, ob, exprs))
+	}
+	return listObjectStatuses(statuses, config.format, config.Stdout())
+}
+
+func newStatusCommand(cp ctxProvider) *cobra.Command {
+	c := &cobra.Command{
+		Use:     "status [-o <format>] <environment>",
+		Short:   "list objects on the server for an environment along with their readiness, without changing anything",
+		Example: statusExamples(),
+	}
+
+	config := statusCommandConfig{
+		filterFunc: addFilterParams(c, true),
+	}
+	c.Flags().StringVarP(&config.format, "format", "o", "", "use json|yaml to display machine readable output")
+
+	c.RunE = func(c *cobra.Command, args []string) error {
+		config.AppContext = cp()
+		return cmd.WrapError(doStatus(c.Context(), args, config))
+	}
+	return c
+}
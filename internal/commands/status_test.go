@@ -0,0 +1,118 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func statusGetter(ctx context.Context, obj model.K8sMeta) (*unstructured.Unstructured, error) {
+	switch obj.GetName() {
+	case "svc2-deploy":
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name":       "svc2-deploy",
+				"namespace":  "bar-system",
+				"generation": int64(2),
+				"annotations": map[string]interface{}{
+					model.QbecNames.SourceAnnotations.Commit:      "0123456789abcdef",
+					model.QbecNames.SourceAnnotations.QbecVersion: "1.0.0",
+				},
+			},
+			"spec": map[string]interface{}{"replicas": int64(2)},
+			"status": map[string]interface{}{
+				"observedGeneration": int64(2),
+				"replicas":           int64(2),
+				"updatedReplicas":    int64(2),
+				"availableReplicas":  int64(1),
+			},
+		}}, nil
+	case "svc2-previous-deploy":
+		return nil, remote.ErrNotFound
+	default:
+		return nil, fmt.Errorf("unexpected get for %s", obj.GetName())
+	}
+}
+
+func TestStatusBasic(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	s.client.listFunc = stdLister
+	s.client.getFunc = statusGetter
+	err := s.executeCommand("status", "dev")
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`^COMPONENT\s+KIND\s+NAMESPACE\s+NAME\s+STATUS\s+COMMIT\s+DETAILS$`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^service1\s+Deployment\s+bar-system\s+svc2-deploy\s+not-ready\s+01234567\s+1 of 2 updated replicas are available`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^service2\s+Deployment\s+bar-system\s+svc2-previous-deploy\s+missing\s+-`))
+}
+
+func TestStatusJSON(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	s.client.listFunc = stdLister
+	s.client.getFunc = statusGetter
+	err := s.executeCommand("status", "dev", "-c", "service1", "-o", "json")
+	require.NoError(t, err)
+	var data []objectStatus
+	require.NoError(t, s.jsonOutput(&data))
+	assert.EqualValues(t, []objectStatus{
+		{
+			Component:   "service1",
+			APIVersion:  "apps/v1",
+			Kind:        "Deployment",
+			Namespace:   "bar-system",
+			Name:        "svc2-deploy",
+			Status:      objectStatusNotReady,
+			Details:     "1 of 2 updated replicas are available",
+			LastApplied: &lastApplied{Commit: "0123456789abcdef", QbecVersion: "1.0.0"},
+		},
+	}, data)
+}
+
+func TestStatusNegative(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		msg  string
+	}{
+		{"no env", []string{"status"}, `exactly one environment required, but provided: []`},
+		{"baseline", []string{"status", "_"}, "cannot get status for baseline environment, use a real environment"},
+		{"bad format", []string{"status", "dev", "-o", "table"}, `invalid output format: "table"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newScaffold(t)
+			defer s.reset()
+			err := s.executeCommand(test.args...)
+			require.Error(t, err)
+			assert.True(t, cmd.IsUsageError(err))
+			assert.Equal(t, test.msg, err.Error())
+		})
+	}
+}
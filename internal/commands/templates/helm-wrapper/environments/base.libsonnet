@@ -0,0 +1,8 @@
+// this file has the baseline default parameters
+{
+  components: {
+    chart: {
+      values: {},
+    },
+  },
+}
@@ -0,0 +1,10 @@
+// this file has the param overrides for the default environment
+local base = import './base.libsonnet';
+
+base {
+  components+: {
+    chart+: {
+      values+: {},
+    },
+  },
+}
@@ -0,0 +1,3 @@
+#!/bin/sh
+echo "cannot render diff" >&2
+exit 2
@@ -0,0 +1,6 @@
+#!/bin/sh
+# fake diff program that prints its arguments and the names of the files it is called with, along with the
+# lines of the right file that are not present in the left file
+echo "fake-diff $1 $(basename $(dirname $2))/$(basename $2) $(basename $(dirname $3))/$(basename $3)"
+grep -v -x -F -f "$2" "$3"
+exit 1
@@ -0,0 +1,3 @@
+{
+  labels: { app: 'changed' },
+}
@@ -0,0 +1,6 @@
+local common = import 'common.libsonnet';
+
+{
+  port: 8080,
+  labels: common.labels { tier: 'web' },
+}
@@ -0,0 +1,3 @@
+{
+  check(object):: if std.length(object.metadata.name) > 20 then ['name too long'] else [],
+}
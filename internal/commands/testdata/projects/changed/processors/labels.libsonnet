@@ -0,0 +1 @@
+{ team: 'changed' }
@@ -0,0 +1,2 @@
+#!/bin/sh
+echo "{\"path\": \"$__DS_PATH__\"}"
@@ -0,0 +1 @@
+{ team: 'platform' }
@@ -0,0 +1 @@
+(import 'common.libsonnet') + { tier: 'web' }
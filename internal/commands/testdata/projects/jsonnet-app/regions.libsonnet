@@ -0,0 +1 @@
+['us-east', 'eu-west']
@@ -0,0 +1,7 @@
+# config maps must have an owner label
+package qbec.owner
+
+deny[msg] {
+	not input.metadata.labels.owner
+	msg := sprintf("config map %s has no owner label", [input.metadata.name])
+}
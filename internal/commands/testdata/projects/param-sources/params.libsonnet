@@ -0,0 +1,26 @@
+local props = std.extVar('qbec.io/envProperties');
+local env = std.extVar('qbec.io/env');
+
+local base = {
+  components: {
+    web: {
+      image: 'nginx:1.25',
+      replicas: props.replicas,
+      logLevel: props.logLevel,
+      host: 'web.' + props.domain,
+      resources: { cpu: '100m', memory: '128Mi' },
+    },
+  },
+};
+
+local overrides = {
+  prod: {
+    components+: {
+      web+: {
+        resources+: { cpu: '1' },
+      },
+    },
+  },
+};
+
+base + (if std.objectHas(overrides, env) then overrides[env] else {})
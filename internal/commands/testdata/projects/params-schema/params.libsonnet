@@ -0,0 +1,23 @@
+local env = std.extVar('qbec.io/env');
+local base = {
+  components: {
+    web: {
+      replicas: 1,
+      image: 'nginx',
+    },
+    other: {
+      anything: true,
+    },
+  },
+};
+local overrides = {
+  prod: {
+    components+: {
+      web+: {
+        replicas: 'three',
+        imag: 'nginx:1.2',
+      },
+    },
+  },
+};
+base + std.get(overrides, env, {})
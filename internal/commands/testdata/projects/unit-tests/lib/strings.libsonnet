@@ -0,0 +1,3 @@
+{
+  upper(s):: std.asciiUpper(s),
+}
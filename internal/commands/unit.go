@@ -0,0 +1,196 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/eval"
+	"github.com/splunk/qbec/internal/fswalk"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/vm"
+)
+
+// unitTestSuffix is the suffix of jsonnet test files discovered by the unit command.
+const unitTestSuffix = "_test.jsonnet"
+
+type unitCommandConfig struct {
+	cmd.AppContext
+	env        string
+	run        string
+	exclusions func() []string
+}
+
+// testCollector collects test files, and jsonnet files explicitly specified by the user.
+type testCollector struct {
+	files []string
+}
+
+func (t *testCollector) Matches(path string, f fs.FileInfo, userSpecified bool) bool {
+	if userSpecified {
+		return filepath.Ext(path) == ".jsonnet"
+	}
+	return strings.HasSuffix(path, unitTestSuffix)
+}
+
+func (t *testCollector) Process(path string, f fs.FileInfo) error {
+	t.files = append(t.files, path)
+	return nil
+}
+
+// unitResult is the result of running a single test.
+type unitResult struct {
+	name string
+	err  error
+}
+
+// runUnitTests runs the tests in the supplied file, which must evaluate to an object of tests keyed by name.
+// A test passes when its value is true. Tests are evaluated individually such that an error in one test does
+// not affect others.
+func runUnitTests(file string, ctx eval.BaseContext, match *regexp.Regexp) ([]unitResult, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	b, _ := json.Marshal(abs)
+	tests := fmt.Sprintf("(import %s)", b)
+	// only test names are evaluated up front, such that failing tests do not fail the whole file
+	out, err := eval.Code(file, vm.MakeCode(fmt.Sprintf("local t = %s; if std.isObject(t) then std.objectFields(t) else null", tests)), ctx)
+	if err != nil {
+		return nil, err
+	}
+	var allNames []string
+	if err := json.Unmarshal([]byte(out), &allNames); err != nil || allNames == nil {
+		return nil, fmt.Errorf("%s: test file must evaluate to an object of tests keyed by name", file)
+	}
+	var names []string
+	for _, name := range allNames {
+		if match == nil || match.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var ret []unitResult
+	for _, name := range names {
+		b, _ := json.Marshal(name)
+		out, err := eval.Code(file, vm.MakeCode(fmt.Sprintf("%s[%s]", tests, b)), ctx)
+		if err == nil && strings.TrimSpace(out) != "true" {
+			err = fmt.Errorf("expected true, got %s", strings.TrimSpace(out))
+		}
+		ret = append(ret, unitResult{name: name, err: err})
+	}
+	return ret, nil
+}
+
+func doUnit(args []string, config unitCommandConfig) error {
+	var match *regexp.Regexp
+	if config.run != "" {
+		var err error
+		match, err = regexp.Compile(config.run)
+		if err != nil {
+			return cmd.NewUsageError(fmt.Sprintf("invalid test pattern %q: %v", config.run, err))
+		}
+	}
+	env := config.env
+	if env != model.Baseline {
+		var err error
+		env, err = config.ResolveEnv(env)
+		if err != nil {
+			return err
+		}
+	}
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	exclusions := config.exclusions()
+	if vendor := config.App().VendorDir(); vendor != "" {
+		exclusions = append(exclusions, vendor)
+	}
+	collector := &testCollector{}
+	if err := fswalk.Process(args, fswalk.Options{Exclusions: exclusions}, collector); err != nil {
+		return err
+	}
+	if len(collector.files) == 0 {
+		return fmt.Errorf("no test files found")
+	}
+	envCtx, err := config.EnvContext(env)
+	if err != nil {
+		return err
+	}
+	ctx := envCtx.EvalContext(cleanEvalMode).BaseContext
+
+	w := config.Stdout()
+	var passed, failed int
+	for _, file := range collector.files {
+		results, err := runUnitTests(file, ctx, match)
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "FAIL %s\n%s\n", file, indent(err.Error()))
+			continue
+		}
+		for _, r := range results {
+			if r.err != nil {
+				failed++
+				fmt.Fprintf(w, "FAIL %s: %s\n%s\n", file, r.name, indent(r.err.Error()))
+				continue
+			}
+			passed++
+			if config.Verbosity() > 0 {
+				fmt.Fprintf(w, "PASS %s: %s\n", file, r.name)
+			}
+		}
+	}
+	fmt.Fprintf(w, "%d passed, %d failed\n", passed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d test(s) failed", failed)
+	}
+	return nil
+}
+
+// indent indents every line of the supplied string for display under a test result.
+func indent(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = "    " + l
+	}
+	return strings.Join(lines, "\n")
+}
+
+func newUnitCommand(cp ctxProvider) *cobra.Command {
+	c := &cobra.Command{
+		Use:     "unit [path...]",
+		Short:   "run jsonnet unit tests found in *_test.jsonnet files with the library paths and variables of the app",
+		Example: unitExamples(),
+	}
+	config := unitCommandConfig{}
+	c.Flags().StringVar(&config.env, "env", model.Baseline, "qbec environment whose variables are set for tests, defaults to the baseline environment")
+	c.Flags().StringVar(&config.run, "run", "", "only run tests whose names match this regular expression")
+	config.exclusions = fswalk.AddExclusions(c.Flags())
+	c.RunE = func(c *cobra.Command, args []string) error {
+		config.AppContext = cp()
+		return cmd.WrapError(doUnit(args, config))
+	}
+	return c
+}
@@ -0,0 +1,81 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/splunk/qbec/internal/cmd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnitPass(t *testing.T) {
+	s := newCustomScaffold(t, "testdata/projects/unit-tests")
+	defer s.reset()
+	err := s.executeCommand("unit", "lib", "--verbose=1")
+	require.NoError(t, err)
+	s.assertOutputLineMatch(regexp.MustCompile(`^PASS lib/strings_test.jsonnet: baseline$`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^PASS lib/strings_test.jsonnet: greeting$`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^PASS lib/strings_test.jsonnet: upper$`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^3 passed, 0 failed$`))
+}
+
+func TestUnitEnvAndRun(t *testing.T) {
+	s := newCustomScaffold(t, "testdata/projects/unit-tests")
+	defer s.reset()
+	err := s.executeCommand("unit", "lib", "--env", "dev", "--run", "^(baseline|upper)$")
+	require.Error(t, err)
+	assert.Equal(t, "1 test(s) failed", err.Error())
+	s.assertOutputLineMatch(regexp.MustCompile(`^FAIL lib/strings_test.jsonnet: baseline$`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^\s+expected true, got false$`))
+	s.assertOutputLineNoMatch(regexp.MustCompile(`upper`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^1 passed, 1 failed$`))
+}
+
+func TestUnitFailures(t *testing.T) {
+	s := newCustomScaffold(t, "testdata/projects/unit-tests")
+	defer s.reset()
+	err := s.executeCommand("unit")
+	require.Error(t, err)
+	assert.Equal(t, "3 test(s) failed", err.Error())
+	s.assertOutputLineMatch(regexp.MustCompile(`^FAIL tests/strings_test.jsonnet: assert equal$`))
+	s.assertOutputLineMatch(regexp.MustCompile(`Assertion failed. FOO != Foo`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^FAIL tests/strings_test.jsonnet: false value$`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^FAIL tests/bad/list_test.jsonnet$`))
+	s.assertOutputLineMatch(regexp.MustCompile(`test file must evaluate to an object of tests keyed by name`))
+	s.assertOutputLineNoMatch(regexp.MustCompile(`vendor`))
+	s.assertOutputLineMatch(regexp.MustCompile(`^4 passed, 3 failed$`))
+}
+
+func TestUnitNegative(t *testing.T) {
+	s := newCustomScaffold(t, "testdata/projects/unit-tests")
+	defer s.reset()
+	err := s.executeCommand("unit", "--run", "(")
+	require.Error(t, err)
+	assert.True(t, cmd.IsUsageError(err))
+	assert.Contains(t, err.Error(), `invalid test pattern "("`)
+}
+
+func TestUnitNoTests(t *testing.T) {
+	s := newCustomScaffold(t, "testdata/projects/unit-tests")
+	defer s.reset()
+	err := s.executeCommand("unit", "components")
+	require.Error(t, err)
+	assert.Equal(t, "no test files found", err.Error())
+}
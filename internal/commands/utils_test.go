@@ -109,13 +109,17 @@ func (c *coll) ToList() []model.K8sQbecMeta {
 }
 
 type client struct {
-	nsFunc        func(kind schema.GroupVersionKind) (bool, error)
-	getFunc       func(ctx context.Context, obj model.K8sMeta) (*unstructured.Unstructured, error)
-	syncFunc      func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error)
-	validatorFunc func(ctx context.Context, gvk schema.GroupVersionKind) (k8smeta.Validator, error)
-	listFunc      func(ctx context.Context, scope remote.ListQueryConfig) (remote.Collection, error)
-	deleteFunc    func(ctx context.Context, obj model.K8sMeta, opts remote.DeleteOptions) (*remote.SyncResult, error)
-	objectKeyFunc func(obj model.K8sMeta) string
+	nsFunc             func(kind schema.GroupVersionKind) (bool, error)
+	getFunc            func(ctx context.Context, obj model.K8sMeta) (*unstructured.Unstructured, error)
+	syncFunc           func(ctx context.Context, obj model.K8sLocalObject, opts remote.SyncOptions) (*remote.SyncResult, error)
+	validatorFunc      func(ctx context.Context, gvk schema.GroupVersionKind) (k8smeta.Validator, error)
+	listFunc           func(ctx context.Context, scope remote.ListQueryConfig) (remote.Collection, error)
+	deleteFunc         func(ctx context.Context, obj model.K8sMeta, opts remote.DeleteOptions) (*remote.SyncResult, error)
+	objectKeyFunc      func(obj model.K8sMeta) string
+	riFunc             func(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error)
+	podLogsFunc        func(ctx context.Context, namespace, pod string, opts remote.PodLogOptions) (io.ReadCloser, error)
+	dryRunFunc         func(ctx context.Context, obj model.K8sLocalObject) (*unstructured.Unstructured, error)
+	serverValidateFunc func(ctx context.Context, obj model.K8sLocalObject) ([]string, error)
 }
 
 func (c *client) DisplayName(o model.K8sMeta) string {
@@ -175,9 +179,33 @@ func (c *client) ObjectKey(obj model.K8sMeta) string {
 }
 
 func (c *client) ResourceInterface(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	if c.riFunc != nil {
+		return c.riFunc(gvk, namespace)
+	}
 	return nil, fmt.Errorf("resource-interface: not implemented")
 }
 
+func (c *client) PodLogs(ctx context.Context, namespace, pod string, opts remote.PodLogOptions) (io.ReadCloser, error) {
+	if c.podLogsFunc != nil {
+		return c.podLogsFunc(ctx, namespace, pod, opts)
+	}
+	return nil, errors.New("pod-logs: not implemented")
+}
+
+func (c *client) ServerDryRun(ctx context.Context, obj model.K8sLocalObject) (*unstructured.Unstructured, error) {
+	if c.dryRunFunc != nil {
+		return c.dryRunFunc(ctx, obj)
+	}
+	return nil, errors.New("server-dry-run: not implemented")
+}
+
+func (c *client) ServerValidate(ctx context.Context, obj model.K8sLocalObject) ([]string, error) {
+	if c.serverValidateFunc != nil {
+		return c.serverValidateFunc(ctx, obj)
+	}
+	return nil, errors.New("server-validate: not implemented")
+}
+
 func setPwd(t *testing.T, dir string) func() {
 	wd, err := os.Getwd()
 	require.NoError(t, err)
@@ -318,6 +346,8 @@ func newBaseScaffold(t *testing.T, dir string, clientProvider cmd.ClientProvider
 		reset()
 		sio.Output = oldOut
 		sio.EnableColors(oldColors)
+		sio.EnableQuiet(false)
+		sio.EnableJSON(false)
 	}
 	return s
 }
@@ -26,6 +26,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/splunk/qbec/internal/cmd"
 	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
 	"github.com/splunk/qbec/internal/remote/k8smeta"
 )
 
@@ -38,11 +39,13 @@ const (
 	unicodeCheck    = "\u2714"
 	unicodeX        = "\u2718"
 	unicodeQuestion = "\u003f"
+	unicodeWarning  = "\u0021"
 )
 
 type validatorStats struct {
 	l          sync.Mutex
 	ValidCount int      `json:"valid,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
 	Unknown    []string `json:"unknown,omitempty"`
 	Invalid    []string `json:"invalid,omitempty"`
 	Errors     []string `json:"errors,omitempty"`
@@ -54,6 +57,12 @@ func (v *validatorStats) valid(s string) {
 	v.ValidCount++
 }
 
+func (v *validatorStats) warned(s string) {
+	v.l.Lock()
+	defer v.l.Unlock()
+	v.Warnings = append(v.Warnings, s)
+}
+
 func (v *validatorStats) invalid(s string) {
 	v.l.Lock()
 	defer v.l.Unlock()
@@ -78,9 +87,13 @@ type validator struct {
 	stats                  validatorStats
 	red, green, dim, reset string
 	silent                 bool
+	serverSide             bool
 }
 
 func (v *validator) validate(ctx context.Context, obj model.K8sLocalObject) error {
+	if v.serverSide {
+		return v.validateOnServer(ctx, obj)
+	}
 	name := v.client.DisplayName(obj)
 	schema, err := v.client.ValidatorFor(ctx, obj.GroupVersionKind())
 	if err != nil {
@@ -112,11 +125,44 @@ func (v *validator) validate(ctx context.Context, obj model.K8sLocalObject) erro
 	return nil
 }
 
-func validateObjects(ctx context.Context, objs []model.K8sLocalObject, client cmd.KubeClient, parallel int, colors bool, out io.Writer, silent bool) error {
+// validateOnServer validates the supplied object by submitting it to the server in dry-run mode, such that admission
+// webhooks are also run. Warnings returned by the server are reported but do not make the object invalid.
+func (v *validator) validateOnServer(ctx context.Context, obj model.K8sLocalObject) error {
+	name := v.client.DisplayName(obj)
+	warnings, err := v.client.ServerValidate(ctx, obj)
+	var lines []string
+	for _, w := range warnings {
+		lines = append(lines, "warning: "+w)
+	}
+	switch {
+	case err == remote.ErrMetadataNotFound:
+		if !v.silent {
+			fmt.Fprintf(v.w, "%s%s %s: type not found on server, cannot validate%s\n", v.dim, unicodeQuestion, name, v.reset)
+		}
+		v.stats.unknown(name)
+	case err != nil:
+		lines = append(lines, err.Error())
+		fmt.Fprintf(v.w, "%s%s %s is invalid\n\t- %s%s\n", v.red, unicodeX, name, strings.Join(lines, "\n\t- "), v.reset)
+		v.stats.invalid(name)
+	case len(lines) > 0:
+		fmt.Fprintf(v.w, "%s%s %s is valid with warnings\n\t- %s%s\n", v.dim, unicodeWarning, name, strings.Join(lines, "\n\t- "), v.reset)
+		v.stats.valid(name)
+		v.stats.warned(name)
+	default:
+		if !v.silent {
+			fmt.Fprintf(v.w, "%s%s %s is valid%s\n", v.green, unicodeCheck, name, v.reset)
+		}
+		v.stats.valid(name)
+	}
+	return nil
+}
+
+func validateObjects(ctx context.Context, objs []model.K8sLocalObject, client cmd.KubeClient, parallel int, colors bool, out io.Writer, silent, serverSide bool) error {
 	v := &validator{
-		w:      &lockWriter{Writer: out},
-		client: client,
-		silent: silent,
+		w:          &lockWriter{Writer: out},
+		client:     client,
+		silent:     silent,
+		serverSide: serverSide,
 	}
 	if colors {
 		v.green = escGreen
@@ -142,6 +188,7 @@ type validateCommandConfig struct {
 	cmd.AppContext
 	parallel   int
 	silent     bool
+	serverSide bool
 	filterFunc func() (model.Filters, error)
 }
 
@@ -149,7 +196,10 @@ func doValidate(ctx context.Context, args []string, config validateCommandConfig
 	if len(args) != 1 {
 		return cmd.NewUsageError(fmt.Sprintf("exactly one environment required, but provided: %q", args))
 	}
-	env := args[0]
+	env, err := config.ResolveEnv(args[0])
+	if err != nil {
+		return err
+	}
 	if env == model.Baseline {
 		return cmd.NewUsageError("cannot validate baseline environment, use a real environment")
 	}
@@ -165,11 +215,11 @@ func doValidate(ctx context.Context, args []string, config validateCommandConfig
 	if err != nil {
 		return err
 	}
-	objects, err := generateObjects(ctx, envCtx, makeFilterOpts(fp, client))
+	objects, err := generateObjects(ctx, envCtx, makeFilterOpts(fp, client).withPolicies())
 	if err != nil {
 		return err
 	}
-	return validateObjects(ctx, objects, client, config.parallel, config.Colorize(), config.Stdout(), config.silent)
+	return validateObjects(ctx, objects, client, config.parallel, config.Colorize(), config.Stdout(), config.silent, config.serverSide)
 
 }
 
@@ -186,6 +236,7 @@ func newValidateCommand(cp ctxProvider) *cobra.Command {
 
 	c.Flags().IntVar(&config.parallel, "parallel", 5, "number of parallel routines to run")
 	c.Flags().BoolVar(&config.silent, "silent", false, "do not print success messages for every object")
+	c.Flags().BoolVar(&config.serverSide, "server-side", false, "validate objects by submitting them to the server in dry-run mode, running admission webhooks, instead of using the schema")
 	c.RunE = func(c *cobra.Command, args []string) error {
 		config.AppContext = cp()
 		return cmd.WrapError(doValidate(c.Context(), args, config))
@@ -23,6 +23,8 @@ import (
 	"testing"
 
 	"github.com/splunk/qbec/internal/cmd"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote"
 	"github.com/splunk/qbec/internal/remote/k8smeta"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -70,6 +72,40 @@ func TestValidateSilent(t *testing.T) {
 	s.assertOutputLineMatch(regexp.MustCompile(`- bad config map`))
 }
 
+func TestValidateServerSide(t *testing.T) {
+	s := newScaffold(t)
+	defer s.reset()
+	s.client.validatorFunc = func(ctx context.Context, gvk schema.GroupVersionKind) (k8smeta.Validator, error) {
+		return nil, fmt.Errorf("schema should not be used")
+	}
+	s.client.serverValidateFunc = func(ctx context.Context, obj model.K8sLocalObject) ([]string, error) {
+		switch {
+		case obj.GetKind() == "PodSecurityPolicy":
+			return nil, remote.ErrMetadataNotFound
+		case obj.GetName() == "svc2-cm":
+			return []string{"deprecated field"}, fmt.Errorf(`admission webhook "policy.example.com" denied the request: missing owner label`)
+		case obj.GetKind() == "Deployment":
+			return []string{"spec.template.spec.containers[0].image: latest tag"}, nil
+		default:
+			return nil, nil
+		}
+	}
+	err := s.executeCommand("validate", "dev", "--server-side", "--silent")
+	require.NotNil(t, err)
+	a := assert.New(t)
+	a.Equal("1 invalid objects found", err.Error())
+	s.assertOutputLineNoMatch(regexp.MustCompile(`✔ ClusterRole::allow-root-psp-policy is valid`))
+	s.assertOutputLineNoMatch(regexp.MustCompile(`type not found on server`))
+	s.assertOutputLineMatch(regexp.MustCompile(`✘ ConfigMap:bar-system:svc2-cm is invalid`))
+	s.assertOutputLineMatch(regexp.MustCompile(`- warning: deprecated field`))
+	s.assertOutputLineMatch(regexp.MustCompile(`- admission webhook "policy.example.com" denied the request: missing owner label`))
+	s.assertOutputLineMatch(regexp.MustCompile(`! Deployment:bar-system:svc2-deploy is valid with warnings`))
+	s.assertOutputLineMatch(regexp.MustCompile(`- warning: spec.template.spec.containers\[0\].image: latest tag`))
+	stats := s.outputStats()
+	a.EqualValues([]interface{}{"ConfigMap:bar-system:svc2-cm"}, stats["invalid"])
+	a.ElementsMatch([]interface{}{"PodSecurityPolicy::100-default", "PodSecurityPolicy::200-allow-root"}, stats["unknown"])
+}
+
 func TestValidateNegative(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -23,7 +23,9 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/cmd"
 	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/rollout"
 	"github.com/splunk/qbec/internal/sio"
 	"github.com/splunk/qbec/internal/types"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -34,6 +36,7 @@ import (
 
 // waitListener listens to rollout status updates and provides feedback to the user.
 type waitListener struct {
+	log           sio.Logger                      // logger for messages, scoped to the environment being waited for
 	start         time.Time                       // start time using which relative progress times are printed
 	displayNameFn func(meta model.K8sMeta) string // MUST produce distinct strings for each object, name used as internal key
 	l             sync.Mutex                      // locks concurrent access to field below
@@ -48,13 +51,13 @@ func (w *waitListener) since() time.Duration {
 func (w *waitListener) OnInit(objects []model.K8sMeta) {
 	w.start = time.Now()
 	w.remaining = map[string]bool{}
-	sio.Noticef("waiting for readiness of %d objects\n", len(objects))
+	w.log.Noticef("waiting for readiness of %d objects\n", len(objects))
 	for _, o := range objects {
 		name := w.displayNameFn(o)
 		w.remaining[name] = true
-		sio.Printf("  - %s\n", w.displayNameFn(o))
+		w.log.Printf("  - %s\n", w.displayNameFn(o))
 	}
-	sio.Println()
+	w.log.Println()
 }
 
 // OnStatusChange prints the updated status of the object and removes it from the internal list of remaining items
@@ -65,36 +68,45 @@ func (w *waitListener) OnStatusChange(object model.K8sMeta, rs types.RolloutStat
 	if rs.Done {
 		name := w.displayNameFn(object)
 		delete(w.remaining, name)
-		sio.Noticef("✓ %-6s: %s :: %s (%d remaining)\n", w.since(), w.displayNameFn(object), rs.Description, len(w.remaining))
+		w.log.Noticef("✓ %-6s: %s :: %s (%d remaining)\n", w.since(), w.displayNameFn(object), rs.Description, len(w.remaining))
 		return
 	}
-	sio.Debugf("  %-6s: %s :: %s\n", w.since(), w.displayNameFn(object), rs.Description)
+	w.log.Debugf("  %-6s: %s :: %s\n", w.since(), w.displayNameFn(object), rs.Description)
 }
 
 // OnError prints the error for the object to console.
 func (w *waitListener) OnError(object model.K8sMeta, err error) {
 	w.l.Lock()
 	defer w.l.Unlock()
-	sio.Errorf("%-6s: %s :: %v\n", w.since(), w.displayNameFn(object), err)
+	w.log.Errorf("%-6s: %s :: %v\n", w.since(), w.displayNameFn(object), err)
 }
 
 // OnEnd prints a list of objects that are not marked complete.
 func (w *waitListener) OnEnd(err error) {
 	w.l.Lock()
 	defer w.l.Unlock()
-	sio.Println()
+	w.log.Println()
 	if len(w.remaining) > 0 {
-		sio.Printf("%s: rollout not complete for the following %d objects\n", w.since(), len(w.remaining))
+		w.log.Printf("%s: rollout not complete for the following %d objects\n", w.since(), len(w.remaining))
 		for name := range w.remaining {
-			sio.Printf("  - %s\n", name)
+			w.log.Printf("  - %s\n", name)
 		}
 	}
 	if err == nil {
-		sio.Noticef("✓ %s: rollout complete\n", w.since())
+		w.log.Noticef("✓ %s: rollout complete\n", w.since())
 		return
 	}
 }
 
+// waitError classifies wait timeouts using the corresponding error code.
+func waitError(err error) error {
+	var te *rollout.TimeoutError
+	if errors.As(err, &te) {
+		return cmd.WithCode(cmd.ErrorCodeWaitTimeout, err)
+	}
+	return err
+}
+
 type resourceInterfaceProvider func(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error)
 
 func waitWatcher(ctx context.Context, ri resourceInterfaceProvider, obj model.K8sMeta) (watch.Interface, error) {
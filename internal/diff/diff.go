@@ -20,9 +20,9 @@ package diff
 import (
 	"strings"
 
-	"github.com/ghodss/yaml"
 	"github.com/pkg/errors"
 	godiff "github.com/pmezard/go-difflib/difflib"
+	"github.com/splunk/qbec/internal/objyaml"
 )
 
 const (
@@ -38,6 +38,8 @@ type Options struct {
 	RightName string // name of right side
 	Context   int    // number of context lines in the diff, defaults to 3
 	Colorize  bool   // added colors to the diff
+	// FieldOrder is the order in which object keys are rendered, alphabetical by default.
+	FieldOrder objyaml.FieldOrder
 }
 
 // Strings diffs the left and right strings and returns
@@ -85,7 +87,7 @@ func Objects(left, right interface{}, opts Options) ([]byte, error) {
 		if data == nil {
 			return []byte{}, nil
 		}
-		return yaml.Marshal(data)
+		return objyaml.Marshal(data, opts.FieldOrder)
 	}
 	l, err := asYaml(left)
 	if err != nil {
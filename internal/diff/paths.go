@@ -0,0 +1,139 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package diff
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ChangeType is the type of change made to a single path.
+type ChangeType string
+
+// Supported change types.
+const (
+	Added   ChangeType = "added"   // the path only exists on the right side
+	Removed ChangeType = "removed" // the path only exists on the left side
+	Changed ChangeType = "changed" // the path has different values on both sides
+)
+
+// Change is a difference between the left and right values at a specific path.
+type Change struct {
+	Path  string      `json:"path"`            // the path to the value, e.g. spec.containers[0].image
+	Type  ChangeType  `json:"type"`            // the type of change
+	Left  interface{} `json:"left,omitempty"`  // the left value, not set for additions
+	Right interface{} `json:"right,omitempty"` // the right value, not set for removals
+}
+
+// Paths compares the left and right values, which are expected to be the result of unmarshaling JSON, and returns
+// the changes between them in path order. Maps are compared key by key and lists element by element such that
+// a change deep inside an object is reported at the innermost path possible.
+func Paths(left, right interface{}) []Change {
+	var ret []Change
+	comparePaths("", left, right, &ret)
+	return ret
+}
+
+func joinKey(base, key string) string {
+	if strings.ContainsAny(key, ".[]") {
+		key = fmt.Sprintf("[%q]", key)
+		return base + key
+	}
+	if base == "" {
+		return key
+	}
+	return base + "." + key
+}
+
+func comparePaths(path string, left, right interface{}, out *[]Change) {
+	switch l := left.(type) {
+	case map[string]interface{}:
+		r, ok := right.(map[string]interface{})
+		if !ok {
+			break
+		}
+		var keys []string
+		for k := range l {
+			keys = append(keys, k)
+		}
+		for k := range r {
+			if _, ok := l[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			lv, lok := l[k]
+			rv, rok := r[k]
+			p := joinKey(path, k)
+			switch {
+			case !rok:
+				*out = append(*out, Change{Path: p, Type: Removed, Left: lv})
+			case !lok:
+				*out = append(*out, Change{Path: p, Type: Added, Right: rv})
+			default:
+				comparePaths(p, lv, rv, out)
+			}
+		}
+		return
+	case []interface{}:
+		r, ok := right.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(l) || i < len(r); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(r):
+				*out = append(*out, Change{Path: p, Type: Removed, Left: l[i]})
+			case i >= len(l):
+				*out = append(*out, Change{Path: p, Type: Added, Right: r[i]})
+			default:
+				comparePaths(p, l[i], r[i], out)
+			}
+		}
+		return
+	}
+	if lf, ok := toFloat(left); ok {
+		if rf, ok := toFloat(right); ok && lf == rf {
+			return
+		}
+	}
+	if !reflect.DeepEqual(left, right) {
+		*out = append(*out, Change{Path: path, Type: Changed, Left: left, Right: right})
+	}
+}
+
+// toFloat returns the supplied number as a float such that numbers decoded as different types compare equal.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
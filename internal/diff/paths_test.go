@@ -0,0 +1,70 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaths(t *testing.T) {
+	left := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name": "foo",
+			"annotations": map[string]interface{}{
+				"example.com/a": "b",
+			},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+			"containers": []interface{}{
+				map[string]interface{}{"name": "main", "image": "nginx"},
+			},
+			"removed": true,
+		},
+	}
+	right := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name": "foo",
+			"annotations": map[string]interface{}{
+				"example.com/a": "c",
+			},
+		},
+		"spec": map[string]interface{}{
+			"replicas": float64(2),
+			"containers": []interface{}{
+				map[string]interface{}{"name": "main", "image": "nginx:1.21", "imagePullPolicy": "IfNotPresent"},
+				map[string]interface{}{"name": "sidecar", "image": "proxy"},
+			},
+		},
+	}
+	changes := Paths(left, right)
+	assert.Equal(t, []Change{
+		{Path: `metadata.annotations["example.com/a"]`, Type: Changed, Left: "b", Right: "c"},
+		{Path: "spec.containers[0].image", Type: Changed, Left: "nginx", Right: "nginx:1.21"},
+		{Path: "spec.containers[0].imagePullPolicy", Type: Added, Right: "IfNotPresent"},
+		{Path: "spec.containers[1]", Type: Added, Right: map[string]interface{}{"name": "sidecar", "image": "proxy"}},
+		{Path: "spec.removed", Type: Removed, Left: true},
+	}, changes)
+}
+
+func TestPathsTypeChange(t *testing.T) {
+	changes := Paths(map[string]interface{}{"a": "1"}, map[string]interface{}{"a": []interface{}{"1"}})
+	assert.Equal(t, []Change{{Path: "a", Type: Changed, Left: "1", Right: []interface{}{"1"}}}, changes)
+	assert.Nil(t, Paths(map[string]interface{}{"a": nil}, map[string]interface{}{"a": nil}))
+}
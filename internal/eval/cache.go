@@ -0,0 +1,160 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package eval
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/sio"
+	"github.com/splunk/qbec/vm"
+)
+
+// cacheMaxAge is the age after which unused cache entries are removed.
+const cacheMaxAge = 7 * 24 * time.Hour
+
+// Cache stores the objects produced by components on disk, keyed by a hash of all the inputs of the component,
+// such that unchanged components need not be evaluated again by subsequent invocations.
+type Cache struct {
+	dir     string
+	version string
+	once    sync.Once
+}
+
+// NewCache returns a cache that stores entries in the supplied directory. The version is that of the program
+// producing the entries, such that entries are not shared across versions.
+func NewCache(dir string, version string) *Cache {
+	return &Cache{dir: dir, version: version}
+}
+
+// prune removes entries that have not been used for a while.
+func (c *Cache) prune() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		if time.Since(info.ModTime()) > cacheMaxAge {
+			_ = os.Remove(filepath.Join(c.dir, e.Name()))
+		}
+	}
+}
+
+// key returns the cache key for the supplied component evaluated using the supplied context.
+func (c *Cache) key(ctx Context, comp model.Component, pe []postProc) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "version:%s\ncomponent:%s\ntlas:%s\nlibs:%s\n", c.version, comp.Name,
+		strings.Join(comp.TopLevelVars, ","), strings.Join(ctx.LibPaths, ","))
+	for _, file := range comp.Files {
+		if err := writeFileHash(h, ctx, file, ctx.componentVars(ctx.Vars, comp.TopLevelVars)); err != nil {
+			return "", err
+		}
+	}
+	for _, pp := range pe {
+		if err := writeFileHash(h, ctx, pp.file, ctx.componentVars(ctx.Vars, nil)); err != nil {
+			return "", err
+		}
+	}
+	b, err := json.Marshal(ctx.Transforms)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "transforms:%s\n", b)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// writeFileHash writes the hash of the inputs of the supplied file to the supplied writer.
+func writeFileHash(w io.Writer, ctx Context, file string, vars vm.VariableSet) error {
+	if strings.HasSuffix(file, ".yaml") || strings.HasSuffix(file, ".json") {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "file:%s:%x\n", file, sha256.Sum256(b))
+		return nil
+	}
+	h, err := ctx.jvm.InputHash(file, vars)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "file:%s:%s\n", file, h)
+	return nil
+}
+
+func (c *Cache) file(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// get returns the objects stored for the supplied key, if any.
+func (c *Cache) get(key string) ([]map[string]interface{}, bool) {
+	c.once.Do(c.prune)
+	file := c.file(key)
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, false
+	}
+	var ret []map[string]interface{}
+	if err := json.Unmarshal(b, &ret); err != nil {
+		sio.Warnf("ignore invalid eval cache entry %s: %v\n", file, err)
+		return nil, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(file, now, now)
+	return ret, true
+}
+
+// put stores the supplied objects for the supplied key. The file is written atomically such that concurrent
+// readers never see partial contents.
+func (c *Cache) put(key string, objs []map[string]interface{}) error {
+	if objs == nil {
+		objs = []map[string]interface{}{}
+	}
+	b, err := json.Marshal(objs)
+	if err != nil {
+		return err
+	}
+	// cached objects may contain secrets from data sources, so only the current user has access to them
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(c.dir, key+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.file(key))
+}
@@ -0,0 +1,107 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package eval
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/vm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvalComponentsCache(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.libsonnet")
+	file := filepath.Join(dir, "cm.jsonnet")
+	require.NoError(t, ioutil.WriteFile(lib, []byte(`{ value: 'v1' }`), 0644))
+	require.NoError(t, ioutil.WriteFile(file, []byte(`
+local lib = import 'lib.libsonnet';
+{ apiVersion: 'v1', kind: 'ConfigMap', metadata: { name: 'cm' }, data: { foo: std.extVar('foo'), lib: lib.value } }
+`), 0644))
+	cache := NewCache(filepath.Join(dir, "cache"), "test")
+	comps := []model.Component{{Name: "cm", Files: []string{file}}}
+
+	evalWith := func(foo string) (string, map[string]interface{}) {
+		ctx := decorate(Context{
+			BaseContext: BaseContext{Vars: vm.VariableSet{}.WithVars(vm.NewVar("foo", foo))},
+			Cache:       cache,
+		})
+		keyCtx := ctx
+		keyCtx.init(0)
+		key, err := cache.key(keyCtx, comps[0], nil)
+		require.NoError(t, err)
+		objs, err := Components(comps, ctx, producer)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(objs))
+		return key, objs[0].ToUnstructured().Object["data"].(map[string]interface{})
+	}
+
+	a := assert.New(t)
+	k1, data := evalWith("bar")
+	a.Equal(map[string]interface{}{"foo": "bar", "lib": "v1"}, data)
+	_, err := os.Stat(cache.file(k1))
+	require.NoError(t, err)
+	info, err := os.Stat(cache.dir)
+	require.NoError(t, err)
+	a.Equal(os.FileMode(0700), info.Mode().Perm())
+
+	// a cached entry is used when inputs have not changed
+	require.NoError(t, ioutil.WriteFile(cache.file(k1),
+		[]byte(`[{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm"},"data":{"foo":"cached"}}]`), 0644))
+	k2, data := evalWith("bar")
+	a.Equal(k1, k2)
+	a.Equal(map[string]interface{}{"foo": "cached"}, data)
+
+	// changing a variable changes the key
+	k3, data := evalWith("baz")
+	a.NotEqual(k1, k3)
+	a.Equal(map[string]interface{}{"foo": "baz", "lib": "v1"}, data)
+
+	// changing an imported file changes the key
+	require.NoError(t, ioutil.WriteFile(lib, []byte(`{ value: 'v2' }`), 0644))
+	k4, data := evalWith("baz")
+	a.NotEqual(k3, k4)
+	a.Equal(map[string]interface{}{"foo": "baz", "lib": "v2"}, data)
+}
+
+func TestEvalComponentsCacheExternalState(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "cm.jsonnet")
+	require.NoError(t, ioutil.WriteFile(file, []byte(`
+local expand = std.native('expandHelmTemplate');
+{ apiVersion: 'v1', kind: 'ConfigMap', metadata: { name: 'cm' }, data: { foo: 'bar' } }
+`), 0644))
+	cache := NewCache(filepath.Join(dir, "cache"), "test")
+	comps := []model.Component{{Name: "cm", Files: []string{file}}}
+	ctx := decorate(Context{Cache: cache})
+	keyCtx := ctx
+	keyCtx.init(0)
+	_, err := cache.key(keyCtx, comps[0], nil)
+	require.Error(t, err)
+	assert.Equal(t, vm.ErrExternalInputs, err)
+
+	objs, err := Components(comps, ctx, producer)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(objs))
+	_, err = os.Stat(cache.dir)
+	assert.True(t, os.IsNotExist(err))
+}
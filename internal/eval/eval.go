@@ -18,8 +18,12 @@
 package eval
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
@@ -30,15 +34,19 @@ import (
 	"github.com/pkg/errors"
 	"github.com/splunk/qbec/internal/model"
 	"github.com/splunk/qbec/internal/sio"
+	"github.com/splunk/qbec/internal/sops"
+	"github.com/splunk/qbec/internal/telemetry"
 	"github.com/splunk/qbec/vm"
 	"github.com/splunk/qbec/vm/datasource"
 	"github.com/splunk/qbec/vm/vmutil"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
 	defaultConcurrency = 5
 	maxDisplayErrors   = 3
 	postprocessTLAVar  = "object"
+	decryptTimeout     = time.Minute // max time to decrypt a component file
 )
 
 // LocalObjectProducer converts a data object that has basic Kubernetes attributes
@@ -86,22 +94,39 @@ func (p postProc) run(obj map[string]interface{}) (map[string]interface{}, error
 
 // BaseContext is the context required to evaluate a single file
 type BaseContext struct {
-	LibPaths    []string                // library paths
-	DataSources []datasource.DataSource // data sources
-	Vars        vm.VariableSet          // variables for the VM
-	Verbose     bool                    // show generated code
-	jvm         vm.VM
+	LibPaths           []string                // library paths
+	DataSources        []datasource.DataSource // data sources
+	Vars               vm.VariableSet          // variables for the VM
+	Verbose            bool                    // show generated code
+	MaxDataSourceBytes int64                   // maximum size of the output of a data source for a single import
+	Libraries          map[string]fs.FS        // libraries backed by virtual file systems
+	HTTPImports        *vm.HTTPImports         // imports from HTTP library paths
+	jvm                vm.VM
 }
 
-func (c *BaseContext) newVM() vm.VM {
+func (c *BaseContext) newVM(warmup int) vm.VM {
 	return vm.New(vm.Config{
-		DataSources: c.DataSources,
-		LibPaths:    c.LibPaths,
+		DataSources:        c.DataSources,
+		LibPaths:           c.LibPaths,
+		Warmup:             warmup,
+		MaxDataSourceBytes: c.MaxDataSourceBytes,
+		Libraries:          c.Libraries,
+		HTTPImports:        c.HTTPImports,
 	})
 }
 
+// WithSharedVM returns a copy of the base context that reuses the same pool of VMs for all evaluations
+// performed using it. This is useful when evaluating many small snippets in sequence.
+func (c BaseContext) WithSharedVM() BaseContext {
+	c.jvm = c.newVM(1)
+	return c
+}
+
 func (c *BaseContext) evalCode(diagnosticFile string, code vm.Code, vars vm.VariableSet) (jsonData string, err error) {
-	jvm := c.newVM()
+	if c.jvm != nil {
+		return c.jvm.EvalCode(diagnosticFile, code, vars)
+	}
+	jvm := c.newVM(0)
 	return jvm.EvalCode(diagnosticFile, code, vars)
 }
 
@@ -109,7 +134,7 @@ func (c *BaseContext) evalFile(file string, vars vm.VariableSet) (jsonData strin
 	if c.jvm != nil {
 		return c.jvm.EvalFile(file, vars)
 	}
-	jvm := c.newVM()
+	jvm := c.newVM(0)
 	return jvm.EvalFile(file, vars)
 }
 
@@ -123,22 +148,47 @@ func File(file string, ctx BaseContext) (jsonData string, err error) {
 	return ctx.evalFile(file, ctx.Vars)
 }
 
+// Imports returns the import graph of the supplied file using the base context. Data sources imported by the
+// file or any of its transitive imports are run.
+func Imports(file string, ctx BaseContext) (map[string][]vm.Import, error) {
+	if ctx.jvm != nil {
+		return ctx.jvm.ImportGraph(file)
+	}
+	return ctx.newVM(0).ImportGraph(file)
+}
+
 // Context is the evaluation context
 type Context struct {
 	BaseContext
 	Concurrency      int               // concurrent components to evaluate, default 5
+	ComponentTimeout time.Duration     // max time to evaluate a single component, no timeout when zero
+	PreserveOrder    bool              // return objects in the order emitted by components instead of sorting them
+	PreProcessFiles  []string          // files that contain pre-processing code evaluated before components
 	PostProcessFiles []string          // files that contains post-processing code for all objects
+	Transforms       []model.Transform // patches applied to matching objects after post-processing
+	TraceContext     context.Context   // parent context for tracing spans, the command context when not set
+	Cache            *Cache            // optional cache for the objects produced by components
 	tlaVars          map[string]vm.Var // all top level string vars specified for the command
 }
 
-func (c *Context) init() {
+// init initializes the context for evaluation, creating a VM pool with the specified number of
+// VMs ready for use.
+func (c *Context) init(warmup int) {
 	tlas := c.Vars.TopLevelVars()
 	c.Vars = c.Vars.WithoutTopLevel()
 	c.tlaVars = map[string]vm.Var{}
 	for _, v := range tlas {
 		c.tlaVars[v.Name] = v
 	}
-	c.jvm = c.newVM()
+	c.jvm = c.newVM(warmup)
+}
+
+// traceContext returns the parent context for tracing spans.
+func (c Context) traceContext() context.Context {
+	if c.TraceContext != nil {
+		return c.TraceContext
+	}
+	return telemetry.CommandContext()
 }
 
 func (c Context) componentVars(base vm.VariableSet, tlas []string) vm.VariableSet {
@@ -159,6 +209,24 @@ func (c Context) componentVars(base vm.VariableSet, tlas []string) vm.VariableSe
 	return vs.WithTopLevelVars(add...)
 }
 
+// runPreProcessors evaluates pre-processor files in order and adds their outputs as code variables named
+// after the base name of each file. Every pre-processor can use the outputs of the ones before it. It is an
+// error for the name of a pre-processor variable to be the same as that of an existing external variable.
+func (c *Context) runPreProcessors() error {
+	for _, file := range c.PreProcessFiles {
+		name := baseName(file)
+		if c.Vars.HasVar(name) {
+			return fmt.Errorf("pre-processor %s: external variable %q is already defined, rename the file or the variable", file, name)
+		}
+		out, err := c.evalFile(file, c.componentVars(c.Vars, nil))
+		if err != nil {
+			return errors.Wrapf(err, "run pre-processor %s", file)
+		}
+		c.Vars = c.Vars.WithVars(vm.NewCodeVar(name, out))
+	}
+	return nil
+}
+
 func (c Context) postProcessors() []postProc {
 	var ret []postProc
 	for _, file := range c.PostProcessFiles {
@@ -174,17 +242,33 @@ func (c Context) postProcessors() []postProc {
 // parameters file and returns the result.
 func Components(components []model.Component, ctx Context, lop LocalObjectProducer) (_ []model.K8sLocalObject, finalErr error) {
 	start := time.Now()
+	tctx, span := telemetry.Start(ctx.traceContext(), "evaluate components", attribute.Int("qbec.components", len(components)))
+	ctx.TraceContext = tctx
 	defer func() {
+		telemetry.End(span, finalErr)
 		if finalErr == nil {
-			sio.Debugf("%d components evaluated in %v\n", len(components), time.Since(start).Round(time.Millisecond))
+			sio.For(ctx.TraceContext).Debugf("%d components evaluated in %v\n", len(components), time.Since(start).Round(time.Millisecond))
 		}
 	}()
-	ctx.init()
+	concurrency := ctx.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	if concurrency > len(components) {
+		concurrency = len(components)
+	}
+	ctx.init(concurrency)
+	if err := ctx.runPreProcessors(); err != nil {
+		return nil, err
+	}
 	pe := ctx.postProcessors()
 	ret, err := evalComponents(components, ctx, pe, lop)
 	if err != nil {
 		return nil, err
 	}
+	if ctx.PreserveOrder {
+		return ret, nil
+	}
 
 	sort.Slice(ret, func(i, j int) bool {
 		left := ret[i]
@@ -199,13 +283,16 @@ func Components(components []model.Component, ctx Context, lop LocalObjectProduc
 // Params evaluates the supplied parameters file in the supplied VM and
 // returns it as a JSON object.
 func Params(file string, ctx Context) (map[string]interface{}, error) {
-	ctx.init()
+	ctx.init(0)
+	if err := ctx.runPreProcessors(); err != nil {
+		return nil, err
+	}
 	output, err := ctx.evalFile(file, ctx.componentVars(ctx.Vars, nil))
 	if err != nil {
 		return nil, err
 	}
 	if ctx.Verbose {
-		sio.Debugln("Eval params output:\n" + prettyJSON(output))
+		sio.For(ctx.traceContext()).Debugln("Eval params output:\n" + prettyJSON(output))
 	}
 	var ret map[string]interface{}
 	if err := json.Unmarshal([]byte(output), &ret); err != nil {
@@ -227,25 +314,65 @@ func openFile(file string) (*os.File, error) {
 	return f, nil
 }
 
+// parseDataFile parses the contents of the supplied YAML or JSON file.
+func parseDataFile(file string, r io.Reader) (interface{}, error) {
+	if strings.HasSuffix(file, ".json") {
+		return vmutil.ParseJSON(r)
+	}
+	return vmutil.ParseYAMLDocuments(r)
+}
+
+// readDataFile parses the supplied YAML or JSON file and returns its data along with whether the file was encrypted
+// using sops, in which case the data is still encrypted.
+func readDataFile(file string) (data interface{}, encrypted bool, _ error) {
+	f, err := openFile(file)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	data, err = parseDataFile(file, f)
+	if err != nil {
+		return nil, false, err
+	}
+	if docs, ok := data.([]interface{}); ok && strings.HasSuffix(file, ".yaml") {
+		for _, doc := range docs {
+			if sops.IsEncrypted(doc) {
+				return data, true, nil
+			}
+		}
+		return data, false, nil
+	}
+	return data, sops.IsEncrypted(data), nil
+}
+
+// hasEncryptedFiles returns true if any of the supplied YAML or JSON files was encrypted using sops.
+func hasEncryptedFiles(files []string) bool {
+	for _, file := range files {
+		if !strings.HasSuffix(file, ".yaml") && !strings.HasSuffix(file, ".json") {
+			continue
+		}
+		if _, encrypted, err := readDataFile(file); err == nil && encrypted {
+			return true
+		}
+	}
+	return false
+}
+
 func evaluationCode(c Context, file string) evalFn {
 	switch {
-	case strings.HasSuffix(file, ".yaml"):
+	case strings.HasSuffix(file, ".yaml"), strings.HasSuffix(file, ".json"):
 		return func(file string, component string, tlas []string) (interface{}, error) {
-			f, err := openFile(file)
-			if err != nil {
-				return nil, err
+			data, encrypted, err := readDataFile(file)
+			if err != nil || !encrypted {
+				return data, err
 			}
-			defer f.Close()
-			return vmutil.ParseYAMLDocuments(f)
-		}
-	case strings.HasSuffix(file, ".json"):
-		return func(file string, component string, tlas []string) (interface{}, error) {
-			f, err := openFile(file)
+			ctx, cancel := context.WithTimeout(context.Background(), decryptTimeout)
+			defer cancel()
+			b, err := sops.Decrypt(ctx, "", file)
 			if err != nil {
 				return nil, err
 			}
-			defer f.Close()
-			return vmutil.ParseJSON(f)
+			return parseDataFile(file, bytes.NewReader(b))
 		}
 	default:
 		return func(file string, component string, tlas []string) (interface{}, error) {
@@ -262,7 +389,37 @@ func evaluationCode(c Context, file string) evalFn {
 	}
 }
 
-func evalComponent(ctx Context, c model.Component, pe []postProc, lop LocalObjectProducer) ([]model.K8sLocalObject, error) {
+func evalComponent(ctx Context, c model.Component, pe []postProc, lop LocalObjectProducer) (_ []model.K8sLocalObject, finalErr error) {
+	_, span := telemetry.Start(ctx.traceContext(), "evaluate component", attribute.String("qbec.component", c.Name))
+	defer func() { telemetry.End(span, finalErr) }()
+	log := sio.For(ctx.traceContext())
+
+	var cacheKey string
+	// outputs of components with encrypted files are never cached since that would write decrypted data to disk
+	if ctx.Cache != nil && !hasEncryptedFiles(c.Files) {
+		key, err := ctx.Cache.key(ctx, c, pe)
+		if errors.Is(err, vm.ErrExternalInputs) {
+			// the output depends on inputs that cannot be hashed, such as helm charts, so it is not cached
+			if ctx.Verbose {
+				log.Debugf("not caching output for component %s, %v\n", c.Name, err)
+			}
+		} else if err != nil {
+			log.Warnf("unable to compute eval cache key for %s, %v\n", c.Name, err)
+		} else if objs, ok := ctx.Cache.get(key); ok {
+			span.SetAttributes(attribute.Bool("qbec.cached", true))
+			if ctx.Verbose {
+				log.Debugf("using cached output for component %s\n", c.Name)
+			}
+			var ret []model.K8sLocalObject
+			for _, o := range objs {
+				ret = append(ret, lop(c.Name, o))
+			}
+			return ret, nil
+		} else {
+			cacheKey = key
+		}
+	}
+
 	var data []interface{}
 	for _, file := range c.Files {
 		fn := evaluationCode(ctx, file)
@@ -294,33 +451,86 @@ func evalComponent(ctx Context, c model.Component, pe []postProc, lop LocalObjec
 		return obj, nil
 	}
 
-	var processed []model.K8sLocalObject
+	var outputs []map[string]interface{}
 	for _, o := range objs {
 		proc, err := runPostProcessors(o)
 		if err != nil {
 			return nil, err
 		}
+		proc, err = runTransforms(ctx.Transforms, c.Name, proc)
+		if err != nil {
+			return nil, err
+		}
 		if err := model.AssertMetadataValid(proc); err != nil {
 			return nil, err
 		}
-		processed = append(processed, lop(c.Name, proc))
+		outputs = append(outputs, proc)
+	}
+	// the cache entry is written before objects are produced since that adds qbec metadata in place
+	if cacheKey != "" {
+		if err := ctx.Cache.put(cacheKey, outputs); err != nil {
+			log.Warnf("unable to write eval cache entry for %s, %v\n", c.Name, err)
+		}
+	}
+	var processed []model.K8sLocalObject
+	for _, o := range outputs {
+		processed = append(processed, lop(c.Name, o))
 	}
+	span.SetAttributes(attribute.Int("qbec.objects", len(processed)))
 	return processed, nil
 }
 
+// evalComponentWithTimeout evaluates the supplied component, failing if it takes longer than the
+// timeout of the component or, when it does not have one, the configured component timeout. Since jsonnet
+// evaluation cannot be interrupted, a timed out evaluation is abandoned rather than canceled and keeps running
+// in the background until the process exits. The returned boolean is true when the evaluation timed out.
+func evalComponentWithTimeout(ctx Context, c model.Component, pe []postProc, lop LocalObjectProducer) ([]model.K8sLocalObject, bool, error) {
+	timeout := ctx.ComponentTimeout
+	if c.Timeout > 0 {
+		timeout = c.Timeout
+	}
+	if timeout <= 0 {
+		objs, err := evalComponent(ctx, c, pe, lop)
+		return objs, false, err
+	}
+	type result struct {
+		objs []model.K8sLocalObject
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		objs, err := evalComponent(ctx, c, pe, lop)
+		ch <- result{objs: objs, err: err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-ch:
+		return r.objs, false, r.err
+	case <-timer.C:
+		return nil, true, fmt.Errorf("evaluate '%s': timed out after %v", c.Name, timeout)
+	}
+}
+
 func evalComponents(list []model.Component, ctx Context, pe []postProc, lop LocalObjectProducer) ([]model.K8sLocalObject, error) {
 	var ret []model.K8sLocalObject
 	if len(list) == 0 {
 		return ret, nil
 	}
 
-	ch := make(chan model.Component, len(list))
-	for _, c := range list {
-		ch <- c
+	type indexedComponent struct {
+		index int
+		model.Component
+	}
+	ch := make(chan indexedComponent, len(list))
+	for i, c := range list {
+		ch <- indexedComponent{index: i, Component: c}
 	}
 	close(ch)
+	results := make([][]model.K8sLocalObject, len(list))
 
 	var errs []error
+	var timedOut bool
 	var l sync.Mutex
 
 	concurrency := ctx.Concurrency
@@ -337,12 +547,21 @@ func evalComponents(list []model.Component, ctx Context, pe []postProc, lop Loca
 		go func() {
 			defer wg.Done()
 			for c := range ch {
-				objs, err := evalComponent(ctx, c, pe, lop)
+				l.Lock()
+				stop := timedOut
+				l.Unlock()
+				if stop { // do not start new evaluations once a component has timed out
+					continue
+				}
+				objs, abandoned, err := evalComponentWithTimeout(ctx, c.Component, pe, lop)
 				l.Lock()
 				if err != nil {
 					errs = append(errs, err)
 				} else {
-					ret = append(ret, objs...)
+					results[c.index] = objs
+				}
+				if abandoned {
+					timedOut = true
 				}
 				l.Unlock()
 			}
@@ -360,6 +579,10 @@ func evalComponents(list []model.Component, ctx Context, pe []postProc, lop Loca
 		}
 		return nil, errors.New(strings.Join(msgs, "\n"))
 	}
+	// return objects in component order regardless of the order in which evaluations completed
+	for _, objs := range results {
+		ret = append(ret, objs...)
+	}
 	return ret, nil
 }
 
@@ -22,10 +22,14 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/splunk/qbec/internal/model"
 	"github.com/splunk/qbec/vm"
+	"github.com/splunk/qbec/vm/datasource"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -96,6 +100,17 @@ func TestEvalCode(t *testing.T) {
 	a.EqualValues("bar", base["foo"])
 }
 
+func TestEvalCodeSharedVM(t *testing.T) {
+	ctx := BaseContext{}.WithSharedVM()
+	code := vm.MakeCode(`std.extVar('foo')`)
+	for _, v := range []string{"bar", "baz"} {
+		ctx.Vars = vm.VariableSet{}.WithVars(vm.NewVar("foo", v))
+		data, err := Code("shared", code, ctx)
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%q", v), strings.TrimSpace(data))
+	}
+}
+
 func TestEvalParams(t *testing.T) {
 	paramsMap, err := Params("testdata/params.libsonnet", decorate(Context{
 		BaseContext: BaseContext{Verbose: true},
@@ -329,6 +344,201 @@ func TestEvalComponentsEdges(t *testing.T) {
 	}
 }
 
+type slowSource struct {
+	delay time.Duration
+}
+
+func (s slowSource) Name() string { return "slow" }
+
+func (s slowSource) Resolve(_ string) (string, error) {
+	time.Sleep(s.delay)
+	return `{ "apiVersion": "v1", "kind": "ConfigMap", "metadata": { "name": "slow" } }`, nil
+}
+
+func TestEvalComponentsTimeout(t *testing.T) {
+	components := []model.Component{
+		{
+			Name:  "a",
+			Files: []string{"testdata/components/a.json"},
+		},
+		{
+			Name:  "slow",
+			Files: []string{"testdata/slow-components/slow.jsonnet"},
+		},
+	}
+	ctx := Context{
+		BaseContext:      BaseContext{DataSources: []datasource.DataSource{slowSource{delay: time.Second}}},
+		ComponentTimeout: 50 * time.Millisecond,
+	}
+	_, err := Components(components, decorate(ctx), producer)
+	require.NotNil(t, err)
+	assert.Equal(t, "evaluate 'slow': timed out after 50ms", err.Error())
+
+	ctx = Context{
+		BaseContext:      BaseContext{DataSources: []datasource.DataSource{slowSource{delay: 10 * time.Millisecond}}},
+		ComponentTimeout: 5 * time.Second,
+	}
+	objs, err := Components(components, decorate(ctx), producer)
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(objs))
+}
+
+// trackingSource is a slow data source that records the number of concurrent and total calls.
+type trackingSource struct {
+	delay  time.Duration
+	l      sync.Mutex
+	active int
+	max    int
+	calls  int
+}
+
+func (s *trackingSource) Name() string { return "slow" }
+
+func (s *trackingSource) Resolve(_ string) (string, error) {
+	s.l.Lock()
+	s.active++
+	s.calls++
+	if s.active > s.max {
+		s.max = s.active
+	}
+	s.l.Unlock()
+	time.Sleep(s.delay)
+	s.l.Lock()
+	s.active--
+	s.l.Unlock()
+	return `{ "apiVersion": "v1", "kind": "ConfigMap", "metadata": { "name": "slow" } }`, nil
+}
+
+func TestEvalComponentsTimeoutConcurrency(t *testing.T) {
+	components := []model.Component{
+		{Name: "slow", Files: []string{"testdata/slow-components/slow.jsonnet"}},
+		{Name: "slow2", Files: []string{"testdata/slow-components/slow2.jsonnet"}},
+		{Name: "a", Files: []string{"testdata/components/a.json"}},
+	}
+	ds := &trackingSource{delay: time.Second}
+	ctx := Context{
+		BaseContext:      BaseContext{DataSources: []datasource.DataSource{ds}},
+		Concurrency:      1,
+		ComponentTimeout: 50 * time.Millisecond,
+	}
+	start := time.Now()
+	_, err := Components(components, decorate(ctx), producer)
+	require.NotNil(t, err)
+	a := assert.New(t)
+	a.Equal("evaluate 'slow': timed out after 50ms", err.Error())
+	a.True(time.Since(start) < ds.delay, "evaluation did not return before the abandoned evaluation completed")
+	ds.l.Lock()
+	defer ds.l.Unlock()
+	a.Equal(1, ds.max)
+	a.Equal(1, ds.calls) // no components are started after a timeout
+}
+
+func TestEvalComponentsComponentTimeout(t *testing.T) {
+	ds := &trackingSource{delay: time.Second}
+	ctx := Context{BaseContext: BaseContext{DataSources: []datasource.DataSource{ds}}}
+	start := time.Now()
+	_, err := Components([]model.Component{
+		{Name: "slow", Files: []string{"testdata/slow-components/slow.jsonnet"}, Timeout: 50 * time.Millisecond},
+	}, decorate(ctx), producer)
+	require.NotNil(t, err)
+	assert.Equal(t, "evaluate 'slow': timed out after 50ms", err.Error())
+	assert.True(t, time.Since(start) < ds.delay)
+
+	// the timeout of the component overrides the default timeout
+	ds = &trackingSource{delay: 100 * time.Millisecond}
+	ctx = Context{BaseContext: BaseContext{DataSources: []datasource.DataSource{ds}}, ComponentTimeout: 50 * time.Millisecond}
+	objs, err := Components([]model.Component{
+		{Name: "slow", Files: []string{"testdata/slow-components/slow.jsonnet"}, Timeout: 5 * time.Second},
+	}, decorate(ctx), producer)
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(objs))
+}
+
+func TestEvalComponentsPreProcessors(t *testing.T) {
+	ret, err := Components([]model.Component{
+		{
+			Name:  "cm",
+			Files: []string{"testdata/pre-processors/cm.jsonnet"},
+		},
+	}, decorate(Context{
+		PreProcessFiles: []string{"testdata/pre-processors/labels.jsonnet", "testdata/pre-processors/defaults.libsonnet"},
+	}), producer)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(ret))
+	labels := ret[0].ToUnstructured().GetLabels()
+	a := assert.New(t)
+	a.Equal("platform", labels["team"])
+	a.Equal("dev", labels["env"])
+	a.Equal("web", labels["tier"])
+}
+
+func TestEvalComponentsBadPreProcessor(t *testing.T) {
+	_, err := Components([]model.Component{
+		{
+			Name:  "a",
+			Files: []string{"testdata/components/a.json"},
+		},
+	}, decorate(Context{PreProcessFiles: []string{"testdata/pre-processors/bad.jsonnet"}}), producer)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "run pre-processor testdata/pre-processors/bad.jsonnet:")
+	require.Contains(t, err.Error(), "no-such-var")
+}
+
+func TestEvalComponentsPreserveOrder(t *testing.T) {
+	components := []model.Component{
+		{
+			Name:  "ordered",
+			Files: []string{"testdata/components/ordered.yaml"},
+		},
+	}
+	names := func(objs []model.K8sLocalObject) []string {
+		var ret []string
+		for _, o := range objs {
+			ret = append(ret, o.GetName())
+		}
+		return ret
+	}
+	sorted, err := Components(components, decorate(Context{}), producer)
+	require.NoError(t, err)
+	preserved, err := Components(components, decorate(Context{PreserveOrder: true}), producer)
+	require.NoError(t, err)
+	a := assert.New(t)
+	a.Equal([]string{"ordered-cm1", "ordered-cm2", "ordered-secret"}, names(sorted))
+	a.Equal([]string{"ordered-secret", "ordered-cm2", "ordered-cm1"}, names(preserved))
+}
+
+func TestEvalComponentsEncrypted(t *testing.T) {
+	dir, err := filepath.Abs("testdata/sops-components")
+	require.NoError(t, err)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	cache := NewCache(filepath.Join(t.TempDir(), "cache"), "test")
+	objs, err := Components([]model.Component{
+		{
+			Name:  "secret",
+			Files: []string{"testdata/sops-components/secret.yaml"},
+		},
+	}, decorate(Context{Cache: cache}), producer)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(objs))
+	a := assert.New(t)
+	a.Equal(map[string]interface{}{"password": "s3cret"}, objs[0].ToUnstructured().Object["stringData"])
+	_, err = os.Stat(cache.dir)
+	a.True(os.IsNotExist(err))
+}
+
+func TestEvalComponentsPreProcessorVarCollision(t *testing.T) {
+	ctx := decorate(Context{PreProcessFiles: []string{"testdata/pre-processors/labels.jsonnet"}})
+	ctx.Vars = ctx.Vars.WithVars(vm.NewVar("labels", "user-value"))
+	_, err := Components([]model.Component{
+		{
+			Name:  "cm",
+			Files: []string{"testdata/pre-processors/cm.jsonnet"},
+		},
+	}, ctx, producer)
+	require.NotNil(t, err)
+	assert.Equal(t, `pre-processor testdata/pre-processors/labels.jsonnet: external variable "labels" is already defined, rename the file or the variable`, err.Error())
+}
+
 func TestEvalComponentsBadJson(t *testing.T) {
 	_, err := Components([]model.Component{
 		{
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
@@ -98,7 +99,14 @@ func walkObjects(path string, data interface{}, ctx interface{}) ([]map[string]i
 			}
 			ret = append(ret, t)
 		default:
-			for k, v := range t {
+			// walk keys in sorted order, the order in which jsonnet emits object fields
+			keys := make([]string, 0, len(t))
+			for k := range t {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				v := t[k]
 				objects, err := walkObjects(fmt.Sprintf("%s.%s", path, k), v, data)
 				if err != nil {
 					return nil, err
@@ -0,0 +1,149 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/vm"
+)
+
+// regoDenyRule is the rule of a rego policy that produces violation messages.
+const regoDenyRule = "deny"
+
+// PolicyViolation is a violation of a policy by an object.
+type PolicyViolation struct {
+	Policy  model.Policy         // the policy that was violated
+	Object  model.K8sLocalObject // the object that violates the policy
+	Message string               // the violation message returned by the policy
+}
+
+// isRegoPolicy returns true if the supplied policy is written in rego.
+func isRegoPolicy(p model.Policy) bool {
+	return strings.HasSuffix(p.File, ".rego")
+}
+
+// prepareRegoPolicy compiles the supplied rego policy file into a query for the deny rule of its package.
+func prepareRegoPolicy(ctx context.Context, file string) (rego.PreparedEvalQuery, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return rego.PreparedEvalQuery{}, err
+	}
+	module, err := ast.ParseModule(file, string(b))
+	if err != nil {
+		return rego.PreparedEvalQuery{}, err
+	}
+	if module == nil {
+		return rego.PreparedEvalQuery{}, fmt.Errorf("%s: empty policy", file)
+	}
+	query := fmt.Sprintf("%s.%s", module.Package.Path, regoDenyRule)
+	return rego.New(rego.Query(query), rego.Module(file, string(b))).PrepareForEval(ctx)
+}
+
+// runRegoPolicy evaluates the supplied rego query for the object and returns the violation messages.
+func runRegoPolicy(ctx context.Context, q rego.PreparedEvalQuery, obj model.K8sLocalObject) ([]string, error) {
+	rs, err := q.Eval(ctx, rego.EvalInput(obj.ToUnstructured().Object))
+	if err != nil {
+		return nil, err
+	}
+	// the result set is empty when the policy does not define the deny rule
+	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+		return nil, nil
+	}
+	values, ok := rs[0].Expressions[0].Value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("policy %s rule is not a set of strings", regoDenyRule)
+	}
+	var messages []string
+	for _, v := range values {
+		m, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("policy %s rule produced a value that is not a string, %v", regoDenyRule, v)
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+// runPolicy evaluates the policy file for the supplied object and returns the violation messages.
+func runPolicy(ctx Context, p model.Policy, obj model.K8sLocalObject) ([]string, error) {
+	b, err := json.Marshal(obj.ToUnstructured().Object)
+	if err != nil {
+		return nil, errors.Wrap(err, "json marshal")
+	}
+	baseVars := ctx.Vars.WithTopLevelVars(vm.NewCodeVar(postprocessTLAVar, string(b)))
+	evalCode, err := ctx.evalFile(p.File, ctx.componentVars(baseVars, nil))
+	if err != nil {
+		return nil, err
+	}
+	var messages []string
+	if err := json.Unmarshal([]byte(evalCode), &messages); err != nil {
+		return nil, fmt.Errorf("policy did not return an array of strings, %s", strings.TrimSpace(evalCode))
+	}
+	return messages, nil
+}
+
+// CheckPolicies checks the supplied objects against the policies that target them and returns the violations found,
+// ordered by object and policy.
+func CheckPolicies(objects []model.K8sLocalObject, policies []model.Policy, ctx Context) ([]PolicyViolation, error) {
+	if len(objects) == 0 || len(policies) == 0 {
+		return nil, nil
+	}
+	ctx.init(1)
+	// rego policies are compiled once and evaluated for every object
+	regoQueries := map[string]rego.PreparedEvalQuery{}
+	for _, p := range policies {
+		if !isRegoPolicy(p) {
+			continue
+		}
+		q, err := prepareRegoPolicy(ctx.traceContext(), p.File)
+		if err != nil {
+			return nil, errors.Wrapf(err, "load policy %s", p.Name)
+		}
+		regoQueries[p.Name] = q
+	}
+	var ret []PolicyViolation
+	for _, obj := range objects {
+		for _, p := range policies {
+			if !p.Target.Matches(obj.Component(), obj) {
+				continue
+			}
+			var messages []string
+			var err error
+			if isRegoPolicy(p) {
+				messages, err = runRegoPolicy(ctx.traceContext(), regoQueries[p.Name], obj)
+			} else {
+				messages, err = runPolicy(ctx, p, obj)
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "check policy %s for %s %s", p.Name, obj.GetKind(), model.NameForDisplay(obj))
+			}
+			for _, m := range messages {
+				ret = append(ret, PolicyViolation{Policy: p, Object: obj, Message: m})
+			}
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,116 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package eval
+
+import (
+	"testing"
+
+	"github.com/splunk/qbec/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func policyTestObject(kind, name string, containers ...interface{}) model.K8sLocalObject {
+	return model.NewK8sLocalObject(map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{"containers": containers},
+			},
+		},
+	}, model.LocalAttrs{App: "app", Component: "web", Env: "dev"})
+}
+
+func TestCheckPolicies(t *testing.T) {
+	objects := []model.K8sLocalObject{
+		policyTestObject("Deployment", "good", map[string]interface{}{
+			"name":      "main",
+			"image":     "registry.example.com/nginx",
+			"resources": map[string]interface{}{"limits": map[string]interface{}{"cpu": "1"}},
+		}),
+		policyTestObject("Deployment", "bad",
+			map[string]interface{}{"name": "main", "image": "nginx"},
+			map[string]interface{}{"name": "sidecar", "image": "registry.example.com/envoy"},
+		),
+		policyTestObject("StatefulSet", "db", map[string]interface{}{"name": "main", "image": "postgres"}),
+	}
+	policies := []model.Policy{
+		{Name: "limits", File: "testdata/policies/limits.jsonnet", Level: model.PolicyDeny, Target: model.TransformTarget{Kind: "Deployment"}},
+		{Name: "images", File: "testdata/policies/images.jsonnet", Level: model.PolicyWarn},
+	}
+	violations, err := CheckPolicies(objects, policies, decorate(Context{}))
+	require.NoError(t, err)
+	var messages []string
+	for _, v := range violations {
+		messages = append(messages, v.Policy.Name+": "+v.Object.GetName()+": "+v.Message)
+	}
+	assert.Equal(t, []string{
+		"limits: bad: container main has no resource limits",
+		"limits: bad: container sidecar has no resource limits",
+		"images: bad: image nginx is not from the approved registry, dev",
+		"images: db: image postgres is not from the approved registry, dev",
+	}, messages)
+}
+
+func TestCheckPoliciesNegative(t *testing.T) {
+	objects := []model.K8sLocalObject{policyTestObject("Deployment", "web")}
+	_, err := CheckPolicies(objects, []model.Policy{{Name: "bad", File: "testdata/policies/bad.jsonnet"}}, decorate(Context{}))
+	require.Error(t, err)
+	assert.Equal(t, `check policy bad for Deployment web: policy did not return an array of strings, "not a list"`, err.Error())
+
+	_, err = CheckPolicies(objects, []model.Policy{{Name: "missing", File: "testdata/policies/missing.jsonnet"}}, decorate(Context{}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "check policy missing for Deployment web")
+}
+
+func TestCheckRegoPolicies(t *testing.T) {
+	withReplicas := func(name string, replicas int) model.K8sLocalObject {
+		return model.NewK8sLocalObject(map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": name},
+			"spec":       map[string]interface{}{"replicas": replicas},
+		}, model.LocalAttrs{App: "app", Component: "web", Env: "dev"})
+	}
+	objects := []model.K8sLocalObject{withReplicas("small", 2), withReplicas("large", 5)}
+	policies := []model.Policy{
+		{Name: "replicas", File: "testdata/policies/replicas.rego", Level: model.PolicyWarn},
+	}
+	violations, err := CheckPolicies(objects, policies, decorate(Context{}))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(violations))
+	assert.Equal(t, "large", violations[0].Object.GetName())
+	assert.Equal(t, "large has 5 replicas, at most 3 are allowed", violations[0].Message)
+	assert.Equal(t, model.PolicyWarn, violations[0].Policy.Level)
+}
+
+func TestCheckRegoPoliciesNegative(t *testing.T) {
+	objects := []model.K8sLocalObject{policyTestObject("Deployment", "web")}
+	_, err := CheckPolicies(objects, []model.Policy{{Name: "bad", File: "testdata/policies/bad.rego"}}, decorate(Context{}))
+	require.Error(t, err)
+	assert.Equal(t, "check policy bad for Deployment web: policy deny rule produced a value that is not a string, 10", err.Error())
+
+	_, err = CheckPolicies(objects, []model.Policy{{Name: "syntax", File: "testdata/policies/syntax.rego"}}, decorate(Context{}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "load policy syntax")
+
+	_, err = CheckPolicies(objects, []model.Policy{{Name: "missing", File: "testdata/policies/missing.rego"}}, decorate(Context{}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "load policy missing")
+}
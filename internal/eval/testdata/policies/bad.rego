@@ -0,0 +1,5 @@
+package qbec.bad
+
+deny[n] {
+	n := 10
+}
@@ -0,0 +1,6 @@
+package qbec.replicas
+
+deny[msg] {
+	input.spec.replicas > 3
+	msg := sprintf("%s has %d replicas, at most 3 are allowed", [input.metadata.name, input.spec.replicas])
+}
@@ -0,0 +1,3 @@
+package qbec.syntax
+
+deny[msg] {
@@ -0,0 +1,3 @@
+{
+  labels: std.extVar('labels') { tier: 'web' },
+}
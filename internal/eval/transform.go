@@ -0,0 +1,102 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/model"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func transformMatches(t model.Transform, component string, obj map[string]interface{}) bool {
+	return t.Target.Matches(component, &unstructured.Unstructured{Object: obj})
+}
+
+// applyTransform applies the patch in the supplied transform to the object. Strategic merge patches are used
+// for types registered in the client scheme, and JSON merge patches for all other types.
+func applyTransform(t model.Transform, obj map[string]interface{}) (map[string]interface{}, error) {
+	original, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var patched []byte
+	if t.JSONPatch != nil {
+		b, err := json.Marshal(t.JSONPatch)
+		if err != nil {
+			return nil, err
+		}
+		p, err := jsonpatch.DecodePatch(b)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode JSON patch")
+		}
+		patched, err = p.Apply(original)
+		if err != nil {
+			return nil, errors.Wrap(err, "apply JSON patch")
+		}
+	} else {
+		b, err := json.Marshal(t.Patch)
+		if err != nil {
+			return nil, err
+		}
+		gvk := (&unstructured.Unstructured{Object: obj}).GroupVersionKind()
+		versioned, err := scheme.Scheme.New(gvk)
+		switch {
+		case err == nil:
+			patched, err = strategicpatch.StrategicMergePatch(original, b, versioned)
+			if err != nil {
+				return nil, errors.Wrap(err, "apply strategic merge patch")
+			}
+		case runtime.IsNotRegisteredError(err):
+			patched, err = jsonpatch.MergePatch(original, b)
+			if err != nil {
+				return nil, errors.Wrap(err, "apply merge patch")
+			}
+		default:
+			return nil, err
+		}
+	}
+	var ret map[string]interface{}
+	if err := json.Unmarshal(patched, &ret); err != nil {
+		return nil, err
+	}
+	if getRawObjectType(ret) != leafType {
+		return nil, fmt.Errorf("patched object is not a K8s object")
+	}
+	return ret, nil
+}
+
+// runTransforms applies all matching transforms to the supplied object in order.
+func runTransforms(transforms []model.Transform, component string, obj map[string]interface{}) (map[string]interface{}, error) {
+	for i, t := range transforms {
+		if !transformMatches(t, component, obj) {
+			continue
+		}
+		var err error
+		obj, err = applyTransform(t, obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "transform %d", i)
+		}
+	}
+	return obj, nil
+}
@@ -0,0 +1,141 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package eval
+
+import (
+	"testing"
+
+	"github.com/splunk/qbec/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDeployment() map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "ns1"},
+		"spec": map[string]interface{}{
+			"replicas": float64(1),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "main", "image": "nginx:1"},
+						map[string]interface{}{"name": "sidecar", "image": "envoy:1"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestTransformStrategicMerge(t *testing.T) {
+	out, err := runTransforms([]model.Transform{
+		{
+			Target: model.TransformTarget{Kind: "Deployment", Name: "web"},
+			Patch: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"replicas": 3,
+					"template": map[string]interface{}{
+						"spec": map[string]interface{}{
+							"containers": []interface{}{
+								map[string]interface{}{"name": "sidecar", "image": "envoy:2"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, "web", testDeployment())
+	require.NoError(t, err)
+	a := assert.New(t)
+	spec := out["spec"].(map[string]interface{})
+	a.EqualValues(3, spec["replicas"])
+	containers := spec["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})
+	require.Equal(t, 2, len(containers))
+	a.Equal("nginx:1", containers[0].(map[string]interface{})["image"])
+	a.Equal("envoy:2", containers[1].(map[string]interface{})["image"])
+}
+
+func TestTransformMergePatchForCustomTypes(t *testing.T) {
+	obj := map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata":   map[string]interface{}{"name": "w"},
+		"spec":       map[string]interface{}{"items": []interface{}{"a", "b"}, "size": "small"},
+	}
+	out, err := runTransforms([]model.Transform{
+		{Patch: map[string]interface{}{"spec": map[string]interface{}{"items": []interface{}{"c"}, "size": nil}}},
+	}, "widgets", obj)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"items": []interface{}{"c"}}, out["spec"])
+}
+
+func TestTransformJSONPatch(t *testing.T) {
+	out, err := runTransforms([]model.Transform{
+		{
+			Target: model.TransformTarget{Namespace: "ns1", Component: "web"},
+			JSONPatch: []interface{}{
+				map[string]interface{}{"op": "replace", "path": "/spec/template/spec/containers/0/image", "value": "nginx:2"},
+				map[string]interface{}{"op": "remove", "path": "/spec/template/spec/containers/1"},
+			},
+		},
+	}, "web", testDeployment())
+	require.NoError(t, err)
+	containers := out["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})
+	require.Equal(t, 1, len(containers))
+	assert.Equal(t, "nginx:2", containers[0].(map[string]interface{})["image"])
+}
+
+func TestTransformTargets(t *testing.T) {
+	patch := map[string]interface{}{"metadata": map[string]interface{}{"labels": map[string]interface{}{"patched": "true"}}}
+	tests := []struct {
+		name    string
+		target  model.TransformTarget
+		matched bool
+	}{
+		{name: "all", matched: true},
+		{name: "kind", target: model.TransformTarget{Kind: "Deployment"}, matched: true},
+		{name: "other kind", target: model.TransformTarget{Kind: "ConfigMap"}},
+		{name: "other name", target: model.TransformTarget{Kind: "Deployment", Name: "api"}},
+		{name: "other namespace", target: model.TransformTarget{Namespace: "ns2"}},
+		{name: "other component", target: model.TransformTarget{Component: "api"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := runTransforms([]model.Transform{{Target: test.target, Patch: patch}}, "web", testDeployment())
+			require.NoError(t, err)
+			_, found := out["metadata"].(map[string]interface{})["labels"]
+			assert.Equal(t, test.matched, found)
+		})
+	}
+}
+
+func TestTransformNegative(t *testing.T) {
+	_, err := runTransforms([]model.Transform{
+		{Target: model.TransformTarget{Kind: "ConfigMap"}, Patch: map[string]interface{}{"data": map[string]interface{}{"foo": "bar"}}},
+		{JSONPatch: []interface{}{map[string]interface{}{"op": "remove", "path": "/spec/foo"}}},
+	}, "web", testDeployment())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "transform 1: apply JSON patch")
+
+	_, err = runTransforms([]model.Transform{
+		{JSONPatch: []interface{}{map[string]interface{}{"op": "remove", "path": "/kind"}}},
+	}, "web", testDeployment())
+	require.Error(t, err)
+	assert.Equal(t, "transform 0: patched object is not a K8s object", err.Error())
+}
@@ -0,0 +1,255 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package fieldpath provides a subset of JSONPath to identify fields of Kubernetes objects.
+package fieldpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// segment is a single step of a path.
+type segment struct {
+	key      string // map key, when not an index or wildcard
+	index    int    // list index, when isIndex is set
+	isIndex  bool
+	wildcard bool // matches all keys of a map or all elements of a list
+}
+
+// Path identifies zero or more fields of an object. The supported syntax is an optional leading $ followed by
+// any number of .name, ["name"], ['name'], [index] and [*] elements, for example
+// .metadata.annotations["deployment.kubernetes.io/revision"] or .spec.template.spec.containers[*].image
+type Path struct {
+	expr     string
+	segments []segment
+}
+
+// String returns the expression from which the path was parsed.
+func (p Path) String() string {
+	return p.expr
+}
+
+// Parse parses the supplied expression into a path.
+func Parse(expr string) (Path, error) {
+	bad := func(format string, args ...interface{}) (Path, error) {
+		return Path{}, fmt.Errorf("invalid path %q: %s", expr, fmt.Sprintf(format, args...))
+	}
+	s := strings.TrimPrefix(strings.TrimSpace(expr), "$")
+	if s == "" {
+		return bad("no fields specified")
+	}
+	var segments []segment
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			name := s[:end]
+			if name == "" {
+				return bad("empty field name")
+			}
+			s = s[end:]
+			if name == "*" {
+				segments = append(segments, segment{wildcard: true})
+			} else {
+				segments = append(segments, segment{key: name})
+			}
+		case '[':
+			s = s[1:]
+			if len(s) > 0 && (s[0] == '"' || s[0] == '\'') {
+				key, rest, err := quoted(s)
+				if err != nil {
+					return bad("%v", err)
+				}
+				if !strings.HasPrefix(rest, "]") {
+					return bad("missing ] after quoted name")
+				}
+				segments = append(segments, segment{key: key})
+				s = rest[1:]
+				break
+			}
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return bad("missing ]")
+			}
+			inner := s[:end]
+			s = s[end+1:]
+			if inner == "*" {
+				segments = append(segments, segment{wildcard: true})
+				break
+			}
+			n, err := strconv.Atoi(inner)
+			if err != nil || n < 0 {
+				return bad("index %q is not a non-negative integer, use quotes for names", inner)
+			}
+			segments = append(segments, segment{index: n, isIndex: true})
+		default:
+			return bad("expected . or [ at %q", s)
+		}
+	}
+	return Path{expr: expr, segments: segments}, nil
+}
+
+// quoted returns the contents of the quoted string at the start of s, and the remainder of s after the
+// closing quote. A backslash escapes the character that follows it.
+func quoted(s string) (string, string, error) {
+	q := s[0]
+	var sb strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				sb.WriteByte(s[i])
+			}
+		case q:
+			return sb.String(), s[i+1:], nil
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return "", "", fmt.Errorf("unterminated quoted name")
+}
+
+// Remove removes all fields identified by the path from the supplied object. Maps that become empty as a result
+// are removed as well, such that removing a field that exists on only one of two objects makes them equal.
+func (p Path) Remove(obj map[string]interface{}) {
+	remove(obj, p.segments)
+}
+
+// remove removes the fields identified by the supplied segments from the supplied value and returns the
+// updated value and whether it was changed.
+func remove(v interface{}, segments []segment) (interface{}, bool) {
+	seg, rest := segments[0], segments[1:]
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if seg.isIndex {
+			return val, false
+		}
+		keys := []string{seg.key}
+		if seg.wildcard {
+			keys = keys[:0]
+			for k := range val {
+				keys = append(keys, k)
+			}
+		}
+		changed := false
+		for _, k := range keys {
+			child, ok := val[k]
+			if !ok {
+				continue
+			}
+			if len(rest) == 0 {
+				delete(val, k)
+				changed = true
+				continue
+			}
+			child, childChanged := remove(child, rest)
+			if !childChanged {
+				continue
+			}
+			changed = true
+			if m, ok := child.(map[string]interface{}); ok && len(m) == 0 {
+				delete(val, k)
+			} else {
+				val[k] = child
+			}
+		}
+		return val, changed
+	case []interface{}:
+		if !seg.isIndex && !seg.wildcard {
+			return val, false
+		}
+		if len(rest) == 0 {
+			if seg.wildcard {
+				return []interface{}{}, len(val) > 0
+			}
+			if seg.index >= len(val) {
+				return val, false
+			}
+			return append(val[:seg.index:seg.index], val[seg.index+1:]...), true
+		}
+		changed := false
+		for i := range val {
+			if seg.isIndex && i != seg.index {
+				continue
+			}
+			child, childChanged := remove(val[i], rest)
+			if childChanged {
+				val[i] = child
+				changed = true
+			}
+		}
+		return val, changed
+	default:
+		return v, false
+	}
+}
+
+// Update replaces the values of all fields identified by the path in the supplied object with the values returned
+// by the supplied function, and returns true if any field was found.
+func (p Path) Update(obj map[string]interface{}, fn func(v interface{}) interface{}) bool {
+	return update(obj, p.segments, fn)
+}
+
+// update replaces the values of fields identified by the supplied segments in the supplied value and returns true
+// if any field was found.
+func update(v interface{}, segments []segment, fn func(v interface{}) interface{}) bool {
+	seg, rest := segments[0], segments[1:]
+	found := false
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if seg.isIndex {
+			return false
+		}
+		for k, child := range val {
+			if !seg.wildcard && k != seg.key {
+				continue
+			}
+			if len(rest) == 0 {
+				val[k] = fn(child)
+				found = true
+				continue
+			}
+			if update(child, rest, fn) {
+				found = true
+			}
+		}
+	case []interface{}:
+		if !seg.isIndex && !seg.wildcard {
+			return false
+		}
+		for i, child := range val {
+			if seg.isIndex && i != seg.index {
+				continue
+			}
+			if len(rest) == 0 {
+				val[i] = fn(child)
+				found = true
+				continue
+			}
+			if update(child, rest, fn) {
+				found = true
+			}
+		}
+	}
+	return found
+}
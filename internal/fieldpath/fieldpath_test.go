@@ -0,0 +1,193 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testObject = `{
+	"apiVersion": "apps/v1",
+	"kind": "Deployment",
+	"metadata": {
+		"name": "foo",
+		"annotations": {
+			"deployment.kubernetes.io/revision": "3"
+		},
+		"labels": {
+			"app": "foo",
+			"team": "x"
+		}
+	},
+	"spec": {
+		"replicas": 3,
+		"template": {
+			"spec": {
+				"containers": [
+					{ "name": "a", "image": "a:1", "resources": { "limits": { "cpu": "1" } } },
+					{ "name": "b", "image": "b:1" }
+				]
+			}
+		}
+	}
+}`
+
+func TestRemove(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{
+			path:     ".spec.replicas",
+			expected: `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"annotations":{"deployment.kubernetes.io/revision":"3"},"labels":{"app":"foo","team":"x"},"name":"foo"},"spec":{"template":{"spec":{"containers":[{"image":"a:1","name":"a","resources":{"limits":{"cpu":"1"}}},{"image":"b:1","name":"b"}]}}}}`,
+		},
+		{
+			path:     `$.metadata.annotations["deployment.kubernetes.io/revision"]`,
+			expected: `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"labels":{"app":"foo","team":"x"},"name":"foo"},"spec":{"replicas":3,"template":{"spec":{"containers":[{"image":"a:1","name":"a","resources":{"limits":{"cpu":"1"}}},{"image":"b:1","name":"b"}]}}}}`,
+		},
+		{
+			path:     `.metadata.labels['team']`,
+			expected: `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"annotations":{"deployment.kubernetes.io/revision":"3"},"labels":{"app":"foo"},"name":"foo"},"spec":{"replicas":3,"template":{"spec":{"containers":[{"image":"a:1","name":"a","resources":{"limits":{"cpu":"1"}}},{"image":"b:1","name":"b"}]}}}}`,
+		},
+		{
+			path:     `.metadata.labels.*`,
+			expected: `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"annotations":{"deployment.kubernetes.io/revision":"3"},"name":"foo"},"spec":{"replicas":3,"template":{"spec":{"containers":[{"image":"a:1","name":"a","resources":{"limits":{"cpu":"1"}}},{"image":"b:1","name":"b"}]}}}}`,
+		},
+		{
+			path:     `.spec.template.spec.containers[*].image`,
+			expected: `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"annotations":{"deployment.kubernetes.io/revision":"3"},"labels":{"app":"foo","team":"x"},"name":"foo"},"spec":{"replicas":3,"template":{"spec":{"containers":[{"name":"a","resources":{"limits":{"cpu":"1"}}},{"name":"b"}]}}}}`,
+		},
+		{
+			path:     `.spec.template.spec.containers[0].resources.limits.cpu`,
+			expected: `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"annotations":{"deployment.kubernetes.io/revision":"3"},"labels":{"app":"foo","team":"x"},"name":"foo"},"spec":{"replicas":3,"template":{"spec":{"containers":[{"image":"a:1","name":"a"},{"image":"b:1","name":"b"}]}}}}`,
+		},
+		{
+			path:     `.spec.template.spec.containers[1]`,
+			expected: `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"annotations":{"deployment.kubernetes.io/revision":"3"},"labels":{"app":"foo","team":"x"},"name":"foo"},"spec":{"replicas":3,"template":{"spec":{"containers":[{"image":"a:1","name":"a","resources":{"limits":{"cpu":"1"}}}]}}}}`,
+		},
+		{
+			path:     `.spec.template.spec.containers[5].image`,
+			expected: `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"annotations":{"deployment.kubernetes.io/revision":"3"},"labels":{"app":"foo","team":"x"},"name":"foo"},"spec":{"replicas":3,"template":{"spec":{"containers":[{"image":"a:1","name":"a","resources":{"limits":{"cpu":"1"}}},{"image":"b:1","name":"b"}]}}}}`,
+		},
+		{
+			path:     `.spec.missing.field`,
+			expected: `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"annotations":{"deployment.kubernetes.io/revision":"3"},"labels":{"app":"foo","team":"x"},"name":"foo"},"spec":{"replicas":3,"template":{"spec":{"containers":[{"image":"a:1","name":"a","resources":{"limits":{"cpu":"1"}}},{"image":"b:1","name":"b"}]}}}}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			var obj map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(testObject), &obj))
+			p, err := Parse(test.path)
+			require.NoError(t, err)
+			assert.Equal(t, test.path, p.String())
+			p.Remove(obj)
+			b, err := json.Marshal(obj)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(b))
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	upper := func(v interface{}) interface{} {
+		if s, ok := v.(string); ok {
+			return "X" + s
+		}
+		return v
+	}
+	tests := []struct {
+		path     string
+		found    bool
+		expected string
+	}{
+		{
+			path:     `.metadata.labels.*`,
+			found:    true,
+			expected: `{"annotations":{"deployment.kubernetes.io/revision":"3"},"labels":{"app":"Xfoo","team":"Xx"},"name":"foo"}`,
+		},
+		{
+			path:     `.spec.template.spec.containers[*].image`,
+			found:    true,
+			expected: `{"annotations":{"deployment.kubernetes.io/revision":"3"},"labels":{"app":"foo","team":"x"},"name":"foo"}`,
+		},
+		{
+			path:     `.metadata.missing`,
+			expected: `{"annotations":{"deployment.kubernetes.io/revision":"3"},"labels":{"app":"foo","team":"x"},"name":"foo"}`,
+		},
+		{
+			path:     `.metadata[0]`,
+			expected: `{"annotations":{"deployment.kubernetes.io/revision":"3"},"labels":{"app":"foo","team":"x"},"name":"foo"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			var obj map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(testObject), &obj))
+			p, err := Parse(test.path)
+			require.NoError(t, err)
+			assert.Equal(t, test.found, p.Update(obj, upper))
+			b, err := json.Marshal(obj["metadata"])
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(b))
+		})
+	}
+	var obj map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(testObject), &obj))
+	p, err := Parse(`.spec.template.spec.containers[1].image`)
+	require.NoError(t, err)
+	assert.True(t, p.Update(obj, upper))
+	containers := obj["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})
+	assert.Equal(t, "a:1", containers[0].(map[string]interface{})["image"])
+	assert.Equal(t, "Xb:1", containers[1].(map[string]interface{})["image"])
+}
+
+func TestParseNegative(t *testing.T) {
+	tests := []struct {
+		path string
+		msg  string
+	}{
+		{"", `invalid path "": no fields specified`},
+		{"$", `invalid path "$": no fields specified`},
+		{"spec", `invalid path "spec": expected . or [ at "spec"`},
+		{".spec..replicas", `invalid path ".spec..replicas": empty field name`},
+		{".spec[0", `invalid path ".spec[0": missing ]`},
+		{".spec[foo]", `invalid path ".spec[foo]": index "foo" is not a non-negative integer, use quotes for names`},
+		{".spec[-1]", `invalid path ".spec[-1]": index "-1" is not a non-negative integer, use quotes for names`},
+		{`.spec["foo]`, `invalid path ".spec[\"foo]": unterminated quoted name`},
+		{`.spec["foo"`, `invalid path ".spec[\"foo\"": missing ] after quoted name`},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			_, err := Parse(test.path)
+			require.Error(t, err)
+			assert.Equal(t, test.msg, err.Error())
+		})
+	}
+}
+
+func TestParseEscapes(t *testing.T) {
+	p, err := Parse(`.metadata.annotations['it\'s']`)
+	require.NoError(t, err)
+	obj := map[string]interface{}{"metadata": map[string]interface{}{"annotations": map[string]interface{}{"it's": "x", "y": "z"}}}
+	p.Remove(obj)
+	assert.Equal(t, map[string]interface{}{"metadata": map[string]interface{}{"annotations": map[string]interface{}{"y": "z"}}}, obj)
+}
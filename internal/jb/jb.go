@@ -0,0 +1,312 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package jb provides support for jsonnet libraries managed using jsonnet-bundler. It reads jsonnetfile.json
+// and jsonnetfile.lock.json files and vendors dependencies using the same directory layout as jb.
+package jb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ManifestFile is the name of the file that declares dependencies.
+	ManifestFile = "jsonnetfile.json"
+	// LockFile is the name of the file that pins dependencies to specific versions.
+	LockFile = "jsonnetfile.lock.json"
+	// DefaultVendorDir is the default directory into which dependencies are vendored.
+	DefaultVendorDir = "vendor"
+)
+
+// GitSource is a dependency that is fetched from a git repository.
+type GitSource struct {
+	Remote string `json:"remote"`
+	Subdir string `json:"subdir"`
+}
+
+// LocalSource is a dependency on a local directory.
+type LocalSource struct {
+	Directory string `json:"directory"`
+}
+
+// Source is the source of a dependency, exactly one attribute is set.
+type Source struct {
+	Git   *GitSource   `json:"git,omitempty"`
+	Local *LocalSource `json:"local,omitempty"`
+}
+
+// Dependency is a single dependency declared in a manifest or lock file.
+type Dependency struct {
+	Source  Source `json:"source"`
+	Version string `json:"version"`
+	Name    string `json:"name,omitempty"`
+}
+
+// Manifest is the contents of a jsonnetfile.json or jsonnetfile.lock.json file.
+type Manifest struct {
+	Version       int          `json:"version"`
+	Dependencies  []Dependency `json:"dependencies"`
+	LegacyImports *bool        `json:"legacyImports,omitempty"`
+}
+
+// legacyImports returns true if legacy import paths should be created for dependencies. jb defaults this to true.
+func (m *Manifest) legacyImports() bool {
+	return m.LegacyImports == nil || *m.LegacyImports
+}
+
+// HasManifest returns true if the supplied directory has a jsonnetfile.json file.
+func HasManifest(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ManifestFile))
+	return err == nil
+}
+
+func loadManifest(file string) (*Manifest, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, errors.Wrapf(err, "unmarshal %s", file)
+	}
+	for i, d := range m.Dependencies {
+		if (d.Source.Git == nil) == (d.Source.Local == nil) {
+			return nil, fmt.Errorf("%s: dependency %d must have exactly one of a git or local source", file, i)
+		}
+		if d.Source.Git != nil && d.Source.Git.Remote == "" {
+			return nil, fmt.Errorf("%s: dependency %d does not have a git remote", file, i)
+		}
+		if d.Source.Local != nil && d.Source.Local.Directory == "" {
+			return nil, fmt.Errorf("%s: dependency %d does not have a local directory", file, i)
+		}
+	}
+	return &m, nil
+}
+
+// repoPath returns the host and path of the supplied git remote, without a .git suffix.
+func repoPath(remote string) string {
+	p := remote
+	if u, err := url.Parse(remote); err == nil && u.Scheme != "" {
+		p = u.Host + u.Path
+	} else if i := strings.Index(remote, ":"); i > 0 { // scp-like syntax, git@github.com:user/repo.git
+		p = remote[:i] + "/" + remote[i+1:]
+		if j := strings.Index(p, "@"); j >= 0 {
+			p = p[j+1:]
+		}
+	}
+	return strings.Trim(strings.TrimSuffix(p, ".git"), "/")
+}
+
+// key returns a key that identifies the supplied dependency independent of its version.
+func (d Dependency) key() string {
+	if d.Source.Local != nil {
+		return "local:" + d.Source.Local.Directory
+	}
+	return "git:" + repoPath(d.Source.Git.Remote) + "/" + strings.Trim(d.Source.Git.Subdir, "/")
+}
+
+// Path returns the path of the dependency relative to the vendor directory.
+func (d Dependency) Path() string {
+	if d.Source.Local != nil {
+		return d.LegacyName()
+	}
+	return path.Join(repoPath(d.Source.Git.Remote), strings.Trim(d.Source.Git.Subdir, "/"))
+}
+
+// LegacyName returns the short name under which the dependency may also be imported.
+func (d Dependency) LegacyName() string {
+	if d.Name != "" {
+		return d.Name
+	}
+	if d.Source.Local != nil {
+		return filepath.Base(d.Source.Local.Directory)
+	}
+	return path.Base(d.Path())
+}
+
+// Status is the outcome of ensuring a single dependency.
+type Status string
+
+// Possible statuses.
+const (
+	StatusPresent Status = "present" // the dependency was already vendored
+	StatusFetched Status = "fetched" // the dependency was fetched
+)
+
+// Result is the result of ensuring a single dependency.
+type Result struct {
+	Dependency Dependency
+	Status     Status
+}
+
+func runGit(dir string, args ...string) error {
+	var stderr bytes.Buffer
+	c := exec.Command("git", args...)
+	c.Dir = dir
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("git %s: %v\n%s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// fetchGit fetches the supplied version of the repository and moves the requested sub-directory to the target path.
+// The repository is cloned under the vendor directory such that the move does not cross file systems.
+func fetchGit(src *GitSource, version, vendor, target string) error {
+	if version == "" {
+		version = "HEAD"
+	}
+	if err := os.MkdirAll(vendor, 0755); err != nil {
+		return err
+	}
+	tmpDir, err := ioutil.TempDir(vendor, ".clone-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"fetch", "--quiet", "--depth", "1", src.Remote, version},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	} {
+		if err := runGit(tmpDir, args...); err != nil {
+			return errors.Wrapf(err, "fetch %s@%s", src.Remote, version)
+		}
+	}
+	dir := filepath.Join(tmpDir, filepath.FromSlash(strings.Trim(src.Subdir, "/")))
+	if st, err := os.Stat(dir); err != nil || !st.IsDir() {
+		return fmt.Errorf("directory %q not found in %s@%s", src.Subdir, src.Remote, version)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, ".git")); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return os.Rename(dir, target)
+}
+
+// symlink creates a relative symbolic link at the supplied location pointing to the target, if it does not exist.
+func symlink(target, link string) error {
+	if _, err := os.Lstat(link); err == nil {
+		return nil
+	}
+	rel, err := filepath.Rel(filepath.Dir(link), target)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		return err
+	}
+	return os.Symlink(rel, link)
+}
+
+// pending is a dependency to be ensured, along with the directory against which its local source is resolved.
+type pending struct {
+	dep Dependency
+	dir string
+}
+
+// Ensure makes sure that every dependency declared in the jsonnetfile.json in the root directory is present in the
+// vendor directory, which is relative to the root, along with every dependency in the lock file and the transitive
+// dependencies declared by the jsonnetfile.json files of vendored packages. Missing dependencies are fetched at the
+// version recorded in the lock file, or the version declared in the manifest when they are not locked. Dependencies
+// that are already present are not updated.
+func Ensure(root, vendorDir string) ([]Result, error) {
+	m, err := loadManifest(filepath.Join(root, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var queue []pending
+	for _, d := range m.Dependencies {
+		queue = append(queue, pending{dep: d, dir: root})
+	}
+	locked := map[string]string{}
+	lockFile := filepath.Join(root, LockFile)
+	if _, err := os.Stat(lockFile); err == nil {
+		lm, err := loadManifest(lockFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range lm.Dependencies {
+			locked[d.key()] = d.Version
+			queue = append(queue, pending{dep: d, dir: root})
+		}
+	}
+	vendor := filepath.Join(root, vendorDir)
+	seen := map[string]bool{}
+	var ret []Result
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		d := p.dep
+		if seen[d.key()] {
+			continue
+		}
+		seen[d.key()] = true
+		target := filepath.Join(vendor, filepath.FromSlash(d.Path()))
+		status := StatusPresent
+		if _, err := os.Stat(target); err != nil {
+			switch {
+			case d.Source.Local != nil:
+				dir := d.Source.Local.Directory
+				if !filepath.IsAbs(dir) {
+					dir = filepath.Join(p.dir, dir)
+				}
+				if err := symlink(dir, target); err != nil {
+					return nil, errors.Wrapf(err, "link %s", d.Source.Local.Directory)
+				}
+			default:
+				version := d.Version
+				if v, ok := locked[d.key()]; ok {
+					version = v
+				}
+				if err := fetchGit(d.Source.Git, version, vendor, target); err != nil {
+					return nil, err
+				}
+			}
+			status = StatusFetched
+		}
+		if d.Source.Git != nil && m.legacyImports() && d.LegacyName() != d.Path() {
+			if err := symlink(target, filepath.Join(vendor, d.LegacyName())); err != nil {
+				return nil, errors.Wrapf(err, "create legacy import path for %s", d.Path())
+			}
+		}
+		ret = append(ret, Result{Dependency: d, Status: status})
+		// dependencies of the package are resolved relative to the package directory
+		if HasManifest(target) {
+			nested, err := loadManifest(filepath.Join(target, ManifestFile))
+			if err != nil {
+				return nil, err
+			}
+			for _, nd := range nested.Dependencies {
+				queue = append(queue, pending{dep: nd, dir: target})
+			}
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,249 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package jb
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func git(t *testing.T, dir string, args ...string) string {
+	c := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	c.Dir = dir
+	out, err := c.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+func writeFile(t *testing.T, file, contents string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+	require.NoError(t, ioutil.WriteFile(file, []byte(contents), 0644))
+}
+
+func readFile(t *testing.T, file string) string {
+	b, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	return string(b)
+}
+
+// newRepo creates a git repository with two commits of lib/k.libsonnet and returns its path and the first commit.
+func newRepo(t *testing.T) (string, string) {
+	dir := filepath.Join(t.TempDir(), "libs.git")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	git(t, dir, "init", "--quiet")
+	writeFile(t, filepath.Join(dir, "lib", "k.libsonnet"), "{ version: 1 }")
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "--quiet", "-m", "v1")
+	first := git(t, dir, "rev-parse", "HEAD")
+	writeFile(t, filepath.Join(dir, "lib", "k.libsonnet"), "{ version: 2 }")
+	git(t, dir, "commit", "--quiet", "-am", "v2")
+	return dir, first
+}
+
+func TestRepoPath(t *testing.T) {
+	tests := map[string]string{
+		"https://github.com/grafana/jsonnet-libs.git": "github.com/grafana/jsonnet-libs",
+		"https://github.com/grafana/jsonnet-libs":     "github.com/grafana/jsonnet-libs",
+		"git@github.com:grafana/jsonnet-libs.git":     "github.com/grafana/jsonnet-libs",
+		"ssh://git@github.com/grafana/jsonnet-libs":   "github.com/grafana/jsonnet-libs",
+		"file:///tmp/libs.git":                        "tmp/libs",
+	}
+	for remote, expected := range tests {
+		t.Run(remote, func(t *testing.T) {
+			assert.Equal(t, expected, repoPath(remote))
+		})
+	}
+}
+
+func TestDependencyPaths(t *testing.T) {
+	a := assert.New(t)
+	d := Dependency{Source: Source{Git: &GitSource{Remote: "https://github.com/grafana/jsonnet-libs.git", Subdir: "ksonnet-util"}}}
+	a.Equal("github.com/grafana/jsonnet-libs/ksonnet-util", d.Path())
+	a.Equal("ksonnet-util", d.LegacyName())
+	d.Name = "kausal"
+	a.Equal("kausal", d.LegacyName())
+	d = Dependency{Source: Source{Local: &LocalSource{Directory: "../common/lib"}}}
+	a.Equal("lib", d.Path())
+	a.Equal("lib", d.LegacyName())
+}
+
+func TestEnsure(t *testing.T) {
+	repo, first := newRepo(t)
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "local-lib", "l.libsonnet"), "{ local: true }")
+	writeFile(t, filepath.Join(root, ManifestFile), `{
+  "version": 1,
+  "dependencies": [
+    { "source": { "git": { "remote": "file://`+repo+`", "subdir": "lib" } }, "version": "HEAD", "name": "klib" },
+    { "source": { "local": { "directory": "local-lib" } }, "version": "" }
+  ]
+}`)
+	writeFile(t, filepath.Join(root, LockFile), `{
+  "version": 1,
+  "dependencies": [
+    { "source": { "git": { "remote": "file://`+repo+`", "subdir": "lib" } }, "version": "`+first+`" }
+  ]
+}`)
+	require.True(t, HasManifest(root))
+
+	results, err := Ensure(root, DefaultVendorDir)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(results))
+	a := assert.New(t)
+	a.Equal(StatusFetched, results[0].Status)
+	a.Equal(StatusFetched, results[1].Status)
+
+	vendor := filepath.Join(root, DefaultVendorDir)
+	libPath := filepath.Join(vendor, filepath.FromSlash(repoPath("file://"+repo)), "lib")
+	a.Equal("{ version: 1 }", readFile(t, filepath.Join(libPath, "k.libsonnet")))
+	a.Equal("{ version: 1 }", readFile(t, filepath.Join(vendor, "klib", "k.libsonnet")))
+	a.Equal("{ local: true }", readFile(t, filepath.Join(vendor, "local-lib", "l.libsonnet")))
+
+	results, err = Ensure(root, DefaultVendorDir)
+	require.NoError(t, err)
+	a.Equal(StatusPresent, results[0].Status)
+	a.Equal(StatusPresent, results[1].Status)
+
+	// without a lock file, the declared version is used
+	require.NoError(t, os.Remove(filepath.Join(root, LockFile)))
+	_, err = Ensure(root, "other-vendor")
+	require.NoError(t, err)
+	a.Equal("{ version: 2 }", readFile(t, filepath.Join(root, "other-vendor", "klib", "k.libsonnet")))
+}
+
+func TestEnsureTransitive(t *testing.T) {
+	// base is a dependency of the library in repo, which declares it in its own manifest
+	base := filepath.Join(t.TempDir(), "base.git")
+	require.NoError(t, os.MkdirAll(base, 0755))
+	git(t, base, "init", "--quiet")
+	writeFile(t, filepath.Join(base, "base.libsonnet"), "{ base: true }")
+	git(t, base, "add", ".")
+	git(t, base, "commit", "--quiet", "-m", "base")
+	// locked is only present in the lock file, as a transitive dependency recorded by jb
+	lockedRepo := filepath.Join(t.TempDir(), "locked.git")
+	require.NoError(t, os.MkdirAll(lockedRepo, 0755))
+	git(t, lockedRepo, "init", "--quiet")
+	writeFile(t, filepath.Join(lockedRepo, "locked.libsonnet"), "{ locked: true }")
+	git(t, lockedRepo, "add", ".")
+	git(t, lockedRepo, "commit", "--quiet", "-m", "locked")
+
+	repo := filepath.Join(t.TempDir(), "libs.git")
+	require.NoError(t, os.MkdirAll(repo, 0755))
+	git(t, repo, "init", "--quiet")
+	writeFile(t, filepath.Join(repo, "lib", "k.libsonnet"), "{ version: 1 }")
+	writeFile(t, filepath.Join(repo, "lib", ManifestFile), `{
+  "version": 1,
+  "dependencies": [
+    { "source": { "git": { "remote": "file://`+base+`", "subdir": "" } }, "version": "HEAD" }
+  ]
+}`)
+	git(t, repo, "add", ".")
+	git(t, repo, "commit", "--quiet", "-m", "v1")
+
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ManifestFile), `{
+  "version": 1,
+  "dependencies": [
+    { "source": { "git": { "remote": "file://`+repo+`", "subdir": "lib" } }, "version": "HEAD" }
+  ]
+}`)
+	writeFile(t, filepath.Join(root, LockFile), `{
+  "version": 1,
+  "dependencies": [
+    { "source": { "git": { "remote": "file://`+lockedRepo+`", "subdir": "" } }, "version": "HEAD" }
+  ]
+}`)
+	results, err := Ensure(root, DefaultVendorDir)
+	require.NoError(t, err)
+	a := assert.New(t)
+	require.Equal(t, 3, len(results))
+	for _, r := range results {
+		a.Equal(StatusFetched, r.Status)
+	}
+	vendor := filepath.Join(root, DefaultVendorDir)
+	a.Equal("{ base: true }", readFile(t, filepath.Join(vendor, filepath.FromSlash(repoPath("file://"+base)), "base.libsonnet")))
+	a.Equal("{ locked: true }", readFile(t, filepath.Join(vendor, filepath.FromSlash(repoPath("file://"+lockedRepo)), "locked.libsonnet")))
+
+	// no temporary clones are left behind in the vendor directory
+	entries, err := ioutil.ReadDir(vendor)
+	require.NoError(t, err)
+	for _, e := range entries {
+		a.False(strings.HasPrefix(e.Name(), ".clone-"), e.Name())
+	}
+}
+
+func TestEnsureNegative(t *testing.T) {
+	repo, _ := newRepo(t)
+	tests := []struct {
+		name     string
+		manifest string
+		asserter func(t *testing.T, err error)
+	}{
+		{
+			name: "no-manifest",
+			asserter: func(t *testing.T, err error) {
+				assert.True(t, os.IsNotExist(err))
+			},
+		},
+		{
+			name:     "bad-json",
+			manifest: `{ "version": 1, `,
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "unmarshal")
+			},
+		},
+		{
+			name:     "bad-source",
+			manifest: `{ "version": 1, "dependencies": [ { "source": {} } ] }`,
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "dependency 0 must have exactly one of a git or local source")
+			},
+		},
+		{
+			name:     "bad-subdir",
+			manifest: `{ "version": 1, "dependencies": [ { "source": { "git": { "remote": "file://` + repo + `", "subdir": "foo" } }, "version": "HEAD" } ] }`,
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), `directory "foo" not found in file://`+repo+`@HEAD`)
+			},
+		},
+		{
+			name:     "bad-version",
+			manifest: `{ "version": 1, "dependencies": [ { "source": { "git": { "remote": "file://` + repo + `", "subdir": "lib" } }, "version": "v99" } ] }`,
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "fetch file://"+repo+"@v99")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			if test.manifest != "" {
+				writeFile(t, filepath.Join(root, ManifestFile), test.manifest)
+			}
+			_, err := Ensure(root, DefaultVendorDir)
+			require.Error(t, err)
+			test.asserter(t, err)
+		})
+	}
+}
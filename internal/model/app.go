@@ -29,9 +29,16 @@ import (
 	"time"
 
 	"github.com/ghodss/yaml"
+	"github.com/go-openapi/spec"
 	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/fieldpath"
 	"github.com/splunk/qbec/internal/filematcher"
+	"github.com/splunk/qbec/internal/jb"
 	"github.com/splunk/qbec/internal/sio"
+	"github.com/splunk/qbec/internal/statusexpr"
+	"github.com/splunk/qbec/vm"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 // Baseline is a special environment name that represents the baseline environment with no customizations.
@@ -54,19 +61,25 @@ var httpClient = &http.Client{Timeout: time.Second * 10}
 
 // Component is one or more logically related files that contains objects to be applied to a cluster.
 type Component struct {
-	Name         string   // component name
-	Files        []string // path to main component file and possibly additional files
-	TopLevelVars []string // the top-level variables used by the component
+	Name         string        // component name
+	Files        []string      // path to main component file and possibly additional files
+	TopLevelVars []string      // the top-level variables used by the component
+	DependsOn    []string      // the components that must be applied before this component
+	Timeout      time.Duration // maximum time to evaluate the component, the default timeout applies when zero
 }
 
 // App is a qbec application wrapped with some runtime attributes.
 type App struct {
-	inner             QbecApp              // the app object from serialization
-	overrideNs        string               // any override to the default namespace
-	tag               string               // the tag to be used for the current command invocation
-	root              string               // derived root directory of the app
-	allComponents     map[string]Component // all components whether or not included anywhere
-	defaultComponents map[string]Component // all components enabled by default
+	inner             QbecApp                 // the app object from serialization
+	overrideNs        string                  // any override to the default namespace
+	tag               string                  // the tag to be used for the current command invocation
+	root              string                  // derived root directory of the app
+	base              string                  // directory of the app file as supplied, against which configured paths are resolved
+	allComponents     map[string]Component    // all components whether or not included anywhere
+	defaultComponents map[string]Component    // all components enabled by default
+	paramsSchemas     map[string]*spec.Schema // parameter schemas keyed by component name
+	configFiles       []string                // local files from which the app configuration was loaded
+	extraEnvFiles     []string                // environment files supplied in addition to those configured for the app
 }
 
 func makeValError(file string, errs []error) error {
@@ -78,7 +91,7 @@ func makeValError(file string, errs []error) error {
 
 }
 
-func downloadEnvFile(url string) ([]byte, error) {
+func downloadFile(url string) ([]byte, error) {
 	res, err := httpClient.Get(url)
 	if err != nil {
 		return nil, err
@@ -97,7 +110,7 @@ func downloadEnvFile(url string) ([]byte, error) {
 
 func readEnvFile(file string) ([]byte, error) {
 	if filematcher.IsRemoteFile(file) {
-		b, err := downloadEnvFile(file)
+		b, err := downloadFile(file)
 		if err != nil {
 			return nil, errors.Wrapf(err, "download environments from %s", file)
 		}
@@ -106,7 +119,195 @@ func readEnvFile(file string) ([]byte, error) {
 	return ioutil.ReadFile(file)
 }
 
-func loadEnvFiles(app *QbecApp, additionalFiles []string, v *validator) error {
+// DefaultAppFile is the file that defines apps created by qbec.
+const DefaultAppFile = "qbec.yaml"
+
+// AppFiles are the files that can define an app, exactly one of which must exist in the root directory of the app.
+var AppFiles = []string{DefaultAppFile, "qbec.jsonnet"}
+
+// FindAppFile returns the path of the file that defines the app in the supplied directory.
+func FindAppFile(dir string) (string, error) {
+	var found, paths []string
+	for _, f := range AppFiles {
+		p := filepath.Join(dir, f)
+		if _, err := os.Stat(p); err == nil {
+			found = append(found, f)
+			paths = append(paths, p)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("none of %s found", strings.Join(AppFiles, ", "))
+	case 1:
+		return paths[0], nil
+	default:
+		return "", fmt.Errorf("multiple app files found: %s, only one is allowed", strings.Join(found, ", "))
+	}
+}
+
+// readAppFile returns the contents of the supplied app file. A jsonnet file is evaluated using a VM without any
+// variables or data sources, and its JSON output is returned.
+func readAppFile(file string) ([]byte, error) {
+	if !strings.HasSuffix(file, ".jsonnet") {
+		return ioutil.ReadFile(file)
+	}
+	out, err := vm.New(vm.Config{}).EvalFile(file, vm.VariableSet{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "evaluate %s", file)
+	}
+	return []byte(out), nil
+}
+
+func readIncludeFile(file string) ([]byte, error) {
+	if filematcher.IsRemoteFile(file) {
+		b, err := downloadFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "download app fragment from %s", file)
+		}
+		return b, nil
+	}
+	return ioutil.ReadFile(file)
+}
+
+// mergeVars returns the variables in the base list followed by the additions, such that a variable in the
+// additions replaces a variable with the same name in the base list in place.
+func mergeVars[T any](base, additions []T, name func(T) string) []T {
+	pos := map[string]int{}
+	var ret []T
+	for _, list := range [][]T{base, additions} {
+		for _, x := range list {
+			if i, ok := pos[name(x)]; ok {
+				ret[i] = x
+				continue
+			}
+			pos[name(x)] = len(ret)
+			ret = append(ret, x)
+		}
+	}
+	return ret
+}
+
+// mergeFragment merges the supplied fragment into the variables, environments and excludes collected from
+// previous fragments, replacing definitions with the same name.
+func mergeFragment(merged *QbecAppFragmentSpec, frag QbecAppFragmentSpec) {
+	merged.Vars.External = mergeVars(merged.Vars.External, frag.Vars.External, func(v ExternalVar) string { return v.Name })
+	merged.Vars.TopLevel = mergeVars(merged.Vars.TopLevel, frag.Vars.TopLevel, func(v TopLevelVar) string { return v.Name })
+	merged.Vars.Computed = mergeVars(merged.Vars.Computed, frag.Vars.Computed, func(v ComputedVar) string { return v.Name })
+	for k, v := range frag.Environments {
+		merged.Environments[k] = v
+	}
+	merged.Excludes = mergeLists(merged.Excludes, nil, frag.Excludes)
+}
+
+// loadIncludes merges the app fragments included by the app into its spec. Fragments are merged in the order
+// specified and the app's own variables and environments take precedence over the ones in fragments.
+// Computed variables from fragments are evaluated before the ones declared by the app.
+func loadIncludes(app *QbecApp, base string, v *validator) ([]string, error) {
+	if len(app.Spec.Includes) == 0 {
+		return nil, nil
+	}
+	var allFiles []string
+	for _, filePattern := range app.Spec.Includes {
+		matchedFiles, err := filematcher.Match(resolvePath(base, filePattern))
+		if err != nil {
+			return nil, err
+		}
+		allFiles = append(allFiles, matchedFiles...)
+	}
+	merged := QbecAppFragmentSpec{Environments: map[string]Environment{}}
+	for _, file := range allFiles {
+		b, err := readIncludeFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var frag QbecAppFragment
+		if err := yaml.Unmarshal(b, &frag); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("%s: unmarshal YAML", file))
+		}
+		errs := v.validateFragmentYAML(b)
+		if len(errs) > 0 {
+			return nil, makeValError(file, errs)
+		}
+		mergeFragment(&merged, frag.Spec)
+	}
+	mergeFragment(&merged, QbecAppFragmentSpec{
+		Vars:         app.Spec.Vars,
+		Environments: app.Spec.Environments,
+		Excludes:     app.Spec.Excludes,
+	})
+	app.Spec.Vars = merged.Vars
+	app.Spec.Environments = merged.Environments
+	app.Spec.Excludes = merged.Excludes
+	return localFiles(allFiles), nil
+}
+
+// resolvePath returns the supplied path, configured relative to the app root, relative to the supplied base
+// directory. Absolute paths and remote URLs are returned as-is.
+func resolvePath(base, p string) string {
+	if base == "." || p == "" || filepath.IsAbs(p) || filematcher.IsRemoteFile(p) {
+		return p
+	}
+	return filepath.Join(base, p)
+}
+
+// resolvePaths returns the supplied paths resolved using resolvePath.
+func resolvePaths(base string, paths []string) []string {
+	if base == "." || len(paths) == 0 {
+		return paths
+	}
+	ret := make([]string, 0, len(paths))
+	for _, p := range paths {
+		ret = append(ret, resolvePath(base, p))
+	}
+	return ret
+}
+
+// rootRelative returns the supplied file path relative to the root directory in slash form, if it is under that
+// directory. Other paths are returned as-is.
+func rootRelative(root, file string) string {
+	abs := file
+	if !filepath.IsAbs(file) {
+		p, err := filepath.Abs(file)
+		if err != nil {
+			return filepath.ToSlash(filepath.Clean(file))
+		}
+		abs = p
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		if !filepath.IsAbs(file) {
+			return filepath.ToSlash(filepath.Clean(file))
+		}
+		return file
+	}
+	return filepath.ToSlash(rel)
+}
+
+// localFiles returns the supplied files without remote URLs.
+func localFiles(files []string) []string {
+	var ret []string
+	for _, f := range files {
+		if !filematcher.IsRemoteFile(f) {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+const providerSourcePrefix = "provider "
+
+// describeSource returns a description of the source of an environment definition for messages.
+func describeSource(source string) string {
+	if strings.HasPrefix(source, providerSourcePrefix) {
+		return source
+	}
+	return "file " + source
+}
+
+// loadEnvFiles merges the environments from environment files, followed by the environments produced by
+// the providers of the app, into the app spec. Environment files declared by the app are resolved relative to the
+// supplied base directory. Commands run by providers are run from the supplied directory.
+func loadEnvFiles(app *QbecApp, base, dir string, additionalFiles []string, v *validator) ([]string, error) {
 	if app.Spec.Environments == nil {
 		app.Spec.Environments = map[string]Environment{}
 	}
@@ -114,46 +315,205 @@ func loadEnvFiles(app *QbecApp, additionalFiles []string, v *validator) error {
 	for k := range app.Spec.Environments {
 		sources[k] = "inline"
 	}
+	// load merges the environment map in the supplied bytes, recording the supplied source for each environment.
+	load := func(source string, b []byte) error {
+		var qEnvs QbecEnvironmentMap
+		if err := yaml.Unmarshal(b, &qEnvs); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("%s: unmarshal YAML", source))
+		}
+		errs := v.validateEnvYAML(b)
+		if len(errs) > 0 {
+			return makeValError(source, errs)
+		}
+		for k, v := range qEnvs.Spec.Environments {
+			old, ok := sources[k]
+			if ok {
+				sio.Warnf("override env definition '%s' from %s (previous: %s)\n", k, describeSource(source), old)
+			}
+			sources[k] = source
+			app.Spec.Environments[k] = v
+		}
+		return nil
+	}
 
 	var envFiles []string
-	envFiles = append(envFiles, app.Spec.EnvFiles...)
+	envFiles = append(envFiles, resolvePaths(base, app.Spec.EnvFiles)...)
 	envFiles = append(envFiles, additionalFiles...)
 	var allFiles []string
 	for _, filePattern := range envFiles {
 		matchedFiles, err := filematcher.Match(filePattern)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		allFiles = append(allFiles, matchedFiles...)
 	}
 	for _, file := range allFiles {
 		b, err := readEnvFile(file)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		var qEnvs QbecEnvironmentMap
-		if err := yaml.Unmarshal(b, &qEnvs); err != nil {
-			return errors.Wrap(err, fmt.Sprintf("%s: unmarshal YAML", file))
+		if err := load(file, b); err != nil {
+			return nil, err
 		}
-		errs := v.validateEnvYAML(b)
-		if len(errs) > 0 {
-			return makeValError(file, errs)
+	}
+
+	seen := map[string]bool{}
+	for _, p := range app.Spec.EnvProviders {
+		if seen[p.Name] {
+			return nil, fmt.Errorf("duplicate environment provider %s", p.Name)
 		}
-		for k, v := range qEnvs.Spec.Environments {
-			old, ok := sources[k]
-			if ok {
-				sio.Warnf("override env definition '%s' from file %s (previous: %s)\n", k, file, old)
+		seen[p.Name] = true
+	}
+	for _, p := range app.Spec.EnvProviders {
+		source := providerSourcePrefix + p.Name
+		err := runEnvProvider(app.Metadata.Name, dir, p, func(b []byte) error { return load(source, b) })
+		if err != nil {
+			return nil, errors.Wrapf(err, "environment provider %s", p.Name)
+		}
+	}
+	return localFiles(allFiles), nil
+}
+
+// mergeLists returns the union of the supplied lists, after removing the items in the excludes list from
+// the base list.
+func mergeLists(base, excludes, additions []string) []string {
+	seen := map[string]bool{}
+	for _, x := range excludes {
+		seen[x] = true
+	}
+	var ret []string
+	for _, list := range [][]string{base, additions} {
+		for _, x := range list {
+			if !seen[x] {
+				seen[x] = true
+				ret = append(ret, x)
 			}
-			sources[k] = file
-			app.Spec.Environments[k] = v
+		}
+	}
+	return ret
+}
+
+// inheritEnv returns the environment produced by merging the child environment over its parent. Properties are deep-merged,
+// includes and excludes are combined such that the child settings win, and the server, context and default namespace are
+// inherited when not set by the child. Credentials are inherited along with the server.
+func inheritEnv(parent, child Environment) Environment {
+	ret := child
+	if ret.Server == "" && ret.Context == "" {
+		ret.Server = parent.Server
+		ret.Context = parent.Context
+		if ret.Credentials == nil {
+			ret.Credentials = parent.Credentials
+		}
+	}
+	if ret.DefaultNamespace == "" {
+		ret.DefaultNamespace = parent.DefaultNamespace
+	}
+	if ret.AppTags == nil {
+		ret.AppTags = parent.AppTags
+	}
+	if ret.Impersonate == nil {
+		ret.Impersonate = parent.Impersonate
+	}
+	ret.Client = inheritClient(parent.Client, child.Client)
+	ret.Eval = inheritEval(parent.Eval, child.Eval)
+	if parent.Properties != nil || child.Properties != nil {
+		ret.Properties = deepMerge(parent.Properties, child.Properties)
+	}
+	ret.Includes = mergeLists(parent.Includes, child.Excludes, child.Includes)
+	ret.Excludes = mergeLists(parent.Excludes, child.Includes, child.Excludes)
+	if len(parent.VarFiles) > 0 {
+		ret.VarFiles = append(append([]string{}, parent.VarFiles...), child.VarFiles...)
+	}
+	return ret
+}
+
+func inheritEval(parent, child *EvalSettings) *EvalSettings {
+	if parent == nil || child == nil {
+		if child != nil {
+			return child
+		}
+		return parent
+	}
+	ret := *child
+	if ret.Concurrency == 0 {
+		ret.Concurrency = parent.Concurrency
+	}
+	if ret.ComponentTimeout == "" {
+		ret.ComponentTimeout = parent.ComponentTimeout
+	}
+	if ret.MaxDataSourceBytes == nil {
+		ret.MaxDataSourceBytes = parent.MaxDataSourceBytes
+	}
+	return &ret
+}
+
+func inheritClient(parent, child *ClientSettings) *ClientSettings {
+	if parent == nil || child == nil {
+		if child != nil {
+			return child
+		}
+		return parent
+	}
+	ret := *child
+	if ret.QPS == 0 {
+		ret.QPS = parent.QPS
+	}
+	if ret.Burst == 0 {
+		ret.Burst = parent.Burst
+	}
+	return &ret
+}
+
+// resolveEnvInheritance updates every environment that inherits from another with attributes merged from its
+// ancestors.
+func resolveEnvInheritance(envs map[string]Environment) error {
+	resolved := map[string]bool{}
+	var resolve func(name string, chain []string) error
+	resolve = func(name string, chain []string) error {
+		if resolved[name] {
+			return nil
+		}
+		for _, c := range chain {
+			if c == name {
+				return fmt.Errorf("environment inheritance cycle: %s", strings.Join(append(chain, name), " -> "))
+			}
+		}
+		env := envs[name]
+		if env.Inherits != "" {
+			if _, ok := envs[env.Inherits]; !ok {
+				return fmt.Errorf("environment %s inherits from unknown environment %s", name, env.Inherits)
+			}
+			if err := resolve(env.Inherits, append(chain, name)); err != nil {
+				return err
+			}
+			envs[name] = inheritEnv(envs[env.Inherits], env)
+		}
+		resolved[name] = true
+		return nil
+	}
+	var names []string
+	for name := range envs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := resolve(name, nil); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-// NewApp returns an app loading its details from the supplied file.
+// NewApp returns an app loading its details from the supplied YAML file, or jsonnet file that produces the app.
 func NewApp(file string, envFiles []string, tag string) (*App, error) {
-	b, err := ioutil.ReadFile(file)
+	return NewAppWithVars(file, envFiles, tag, nil)
+}
+
+// NewAppWithVars returns an app loading its details from the supplied file, expanding templates in the server,
+// default namespace and properties of environments (e.g. "{{ .team }}-prod") using the supplied variable values.
+// Variables not supplied take the default values of the external variables declared for the app.
+func NewAppWithVars(file string, envFiles []string, tag string, vars map[string]string) (*App, error) {
+	b, err := readAppFile(file)
 	if err != nil {
 		return nil, err
 	}
@@ -172,7 +532,27 @@ func NewApp(file string, envFiles []string, tag string) (*App, error) {
 		return nil, makeValError(file, errs)
 	}
 
-	if err := loadEnvFiles(&qApp, envFiles, v); err != nil {
+	base := filepath.Dir(file)
+	dir := base
+	if !filepath.IsAbs(dir) {
+		var err error
+		dir, err = filepath.Abs(dir)
+		if err != nil {
+			return nil, errors.Wrap(err, "abs path for "+dir)
+		}
+	}
+	// paths are resolved relative to the directory of the app file unless it is the working directory
+	if wd, err := os.Getwd(); err == nil && wd == dir {
+		base = "."
+	}
+
+	includeFiles, err := loadIncludes(&qApp, base, v)
+	if err != nil {
+		return nil, err
+	}
+
+	loadedEnvFiles, err := loadEnvFiles(&qApp, base, dir, envFiles, v)
+	if err != nil {
 		return nil, err
 	}
 
@@ -180,22 +560,26 @@ func NewApp(file string, envFiles []string, tag string) (*App, error) {
 		return nil, fmt.Errorf("%s: no environments defined for app", file)
 	}
 
+	if err := resolveEnvInheritance(qApp.Spec.Environments); err != nil {
+		return nil, err
+	}
+
+	if err := interpolateEnvironments(&qApp, vars); err != nil {
+		return nil, err
+	}
+
 	for name, env := range qApp.Spec.Environments {
 		if err := env.assertValid(); err != nil {
 			return nil, errors.Wrapf(err, "verify environment %s", name)
 		}
 	}
 
-	app := App{inner: qApp}
-	dir := filepath.Dir(file)
-	if !filepath.IsAbs(dir) {
-		var err error
-		dir, err = filepath.Abs(dir)
-		if err != nil {
-			return nil, errors.Wrap(err, "abs path for "+dir)
-		}
-	}
+	app := App{inner: qApp, extraEnvFiles: envFiles}
 	app.root = dir
+	app.base = base
+	for _, f := range append(append([]string{file}, includeFiles...), loadedEnvFiles...) {
+		app.configFiles = append(app.configFiles, rootRelative(dir, f))
+	}
 	app.setupDefaults()
 	app.allComponents, err = app.loadComponents()
 	if err != nil {
@@ -210,14 +594,55 @@ func NewApp(file string, envFiles []string, tag string) (*App, error) {
 	if err := app.verifyProcessors(); err != nil {
 		return nil, err
 	}
+	if err := app.verifyCommonMetadata(); err != nil {
+		return nil, err
+	}
+	if err := app.verifyComponentTimeout(); err != nil {
+		return nil, err
+	}
+	if err := app.verifyEvalSettings(); err != nil {
+		return nil, err
+	}
+	if err := app.verifyCanonicalVersions(); err != nil {
+		return nil, err
+	}
+	if err := app.verifyWaitStatus(); err != nil {
+		return nil, err
+	}
+	if err := app.verifyComponentDependencies(); err != nil {
+		return nil, err
+	}
+	if err := app.verifyParamsSchemas(); err != nil {
+		return nil, err
+	}
+	if err := app.verifyTransforms(); err != nil {
+		return nil, err
+	}
+	if err := app.verifyDiffIgnores(); err != nil {
+		return nil, err
+	}
+	if err := app.verifyPolicies(); err != nil {
+		return nil, err
+	}
+	if err := app.verifyRedactions(); err != nil {
+		return nil, err
+	}
+	if err := app.verifyLibraryBundles(); err != nil {
+		return nil, err
+	}
+	if err := app.verifyHooks(); err != nil {
+		return nil, err
+	}
 
 	app.updateComponentTopLevelVars()
+	app.updateComponentDependencies()
+	app.updateComponentTimeouts()
 
 	app.defaultComponents = make(map[string]Component, len(app.allComponents))
 	for k, v := range app.allComponents {
 		app.defaultComponents[k] = v
 	}
-	for _, k := range app.inner.Spec.Excludes {
+	for _, k := range app.expandComponentRefs(app.inner.Spec.Excludes) {
 		delete(app.defaultComponents, k)
 	}
 
@@ -259,9 +684,36 @@ func (a *App) Tag() string {
 	return a.tag
 }
 
+// AssertAppTagAllowed returns an error if the app tag for the current invocation is not accepted by the
+// app tag rules of the supplied environment.
+func (a *App) AssertAppTagAllowed(env string) error {
+	if a.tag == "" {
+		return nil
+	}
+	envObj, ok := a.inner.Spec.Environments[env]
+	if !ok {
+		return nil
+	}
+	if !envObj.AppTags.accepts(a.tag) {
+		return fmt.Errorf("environment %s does not allow app tag %q", env, a.tag)
+	}
+	return nil
+}
+
+// Root returns the absolute path of the root directory of the app.
+func (a *App) Root() string {
+	return a.root
+}
+
+// ResolvePath returns the supplied path, interpreted as relative to the root directory of the app, in a form
+// that can be used from the working directory. Absolute paths and remote files are returned as-is.
+func (a *App) ResolvePath(p string) string {
+	return resolvePath(a.base, p)
+}
+
 // ParamsFile returns the runtime parameters file for the app.
 func (a *App) ParamsFile() string {
-	return a.inner.Spec.ParamsFile
+	return resolvePath(a.base, a.inner.Spec.ParamsFile)
 }
 
 func splitPath(s string) []string {
@@ -271,14 +723,54 @@ func splitPath(s string) []string {
 	return strings.Split(s, ":")
 }
 
+// PreProcessors returns the pre processor files for the app.
+func (a *App) PreProcessors() []string {
+	return resolvePaths(a.base, splitPath(a.inner.Spec.PreProcessor))
+}
+
 // PostProcessors returns the post processor files for the app.
 func (a *App) PostProcessors() []string {
-	return splitPath(a.inner.Spec.PostProcessor)
+	return resolvePaths(a.base, splitPath(a.inner.Spec.PostProcessor))
 }
 
 // LibPaths returns the library paths set up for the app.
 func (a *App) LibPaths() []string {
-	return a.inner.Spec.LibPaths
+	libPaths := resolvePaths(a.base, a.inner.Spec.LibPaths)
+	if !jb.HasManifest(a.root) {
+		return libPaths
+	}
+	vendorDir := a.VendorDir()
+	for _, p := range a.inner.Spec.LibPaths {
+		if filepath.Clean(p) == vendorDir {
+			return libPaths
+		}
+	}
+	return append(append([]string{}, libPaths...), resolvePath(a.base, vendorDir))
+}
+
+// VendorDir returns the directory containing libraries vendored by jsonnet-bundler.
+func (a *App) VendorDir() string {
+	if a.inner.Spec.VendorDir == "" {
+		return jb.DefaultVendorDir
+	}
+	return filepath.Clean(a.inner.Spec.VendorDir)
+}
+
+// HTTPLibPaths returns the base URLs from which jsonnet files are imported when they are not found locally.
+func (a *App) HTTPLibPaths() []HTTPLibPath {
+	return a.inner.Spec.HTTPLibPaths
+}
+
+// LibraryBundles returns the zip files containing jsonnet libraries keyed by library name.
+func (a *App) LibraryBundles() map[string]string {
+	if a.base == "." || len(a.inner.Spec.LibraryBundles) == 0 {
+		return a.inner.Spec.LibraryBundles
+	}
+	ret := map[string]string{}
+	for name, file := range a.inner.Spec.LibraryBundles {
+		ret[name] = resolvePath(a.base, file)
+	}
+	return ret
 }
 
 // AddComponentLabel returns if the qbec component name should be added as an object label in addition to the
@@ -287,6 +779,119 @@ func (a *App) AddComponentLabel() bool {
 	return a.inner.Spec.AddComponentLabel
 }
 
+// ComponentTimeout returns the maximum time allowed to evaluate a single component. A zero value
+// means that no timeout is enforced.
+func (a *App) ComponentTimeout() time.Duration {
+	d, _ := time.ParseDuration(a.inner.Spec.ComponentTimeout) // already verified at load time
+	return d
+}
+
+func (a *App) evalSettings(env string) EvalSettings {
+	if env == Baseline {
+		return EvalSettings{}
+	}
+	e, err := a.envObject(env)
+	if err != nil || e.Eval == nil {
+		return EvalSettings{}
+	}
+	return *e.Eval
+}
+
+// EvalConcurrency returns the concurrency with which components should be evaluated for the supplied environment,
+// or 0 if the environment does not specify it.
+func (a *App) EvalConcurrency(env string) int {
+	return a.evalSettings(env).Concurrency
+}
+
+// EnvComponentTimeout returns the maximum time allowed to evaluate a single component for the supplied environment,
+// falling back to the app-level timeout when the environment does not specify one.
+func (a *App) EnvComponentTimeout(env string) time.Duration {
+	t := a.evalSettings(env).ComponentTimeout
+	if t == "" {
+		return a.ComponentTimeout()
+	}
+	d, _ := time.ParseDuration(t) // already verified at load time
+	return d
+}
+
+// MaxDataSourceBytes returns the maximum size of the output of a data source for the supplied environment and
+// true, or false if the environment does not specify it.
+func (a *App) MaxDataSourceBytes(env string) (int64, bool) {
+	limit := a.evalSettings(env).MaxDataSourceBytes
+	if limit == nil {
+		return 0, false
+	}
+	return *limit, true
+}
+
+// PreserveObjectOrder returns true if objects should be displayed in the order in which they were emitted by
+// components.
+func (a *App) PreserveObjectOrder() bool {
+	return a.inner.Spec.PreserveObjectOrder
+}
+
+// SourceAnnotations returns the source annotation settings for the app. The returned value is never nil.
+func (a *App) SourceAnnotations() SourceAnnotations {
+	ret := SourceAnnotations{Timestamp: SourceTimestampNone}
+	if sa := a.inner.Spec.AnnotateSource; sa != nil {
+		ret.Enabled = sa.Enabled
+		if sa.Timestamp != "" {
+			ret.Timestamp = sa.Timestamp
+		}
+	}
+	return ret
+}
+
+// CanonicalVersions returns the versions to use as canonical versions for specific types.
+func (a *App) CanonicalVersions() map[schema.GroupKind]string {
+	if len(a.inner.Spec.CanonicalVersions) == 0 {
+		return nil
+	}
+	ret := map[schema.GroupKind]string{}
+	for k, v := range a.inner.Spec.CanonicalVersions {
+		ret[schema.ParseGroupKind(k)] = v
+	}
+	return ret
+}
+
+// WaitStatusExpressions returns the status expressions that determine when objects of specific types are ready.
+func (a *App) WaitStatusExpressions() map[schema.GroupKind]string {
+	if len(a.inner.Spec.WaitStatus) == 0 {
+		return nil
+	}
+	ret := map[schema.GroupKind]string{}
+	for k, v := range a.inner.Spec.WaitStatus {
+		ret[schema.ParseGroupKind(k)] = v
+	}
+	return ret
+}
+
+// Redactions returns the fields whose values are hidden in output for objects of specific types.
+func (a *App) Redactions() map[schema.GroupKind][]fieldpath.Path {
+	if len(a.inner.Spec.Redactions) == 0 {
+		return nil
+	}
+	ret := map[schema.GroupKind][]fieldpath.Path{}
+	for _, r := range a.inner.Spec.Redactions {
+		gk := schema.ParseGroupKind(r.Kind)
+		for _, expr := range r.Paths {
+			p, _ := fieldpath.Parse(expr) // already verified
+			ret[gk] = append(ret[gk], p)
+		}
+	}
+	return ret
+}
+
+// CommonLabels returns the labels that should be added to all objects.
+func (a *App) CommonLabels() map[string]string {
+	return a.inner.Spec.CommonLabels
+}
+
+// CommonAnnotations returns the annotations that should be added to all objects.
+func (a *App) CommonAnnotations() map[string]string {
+	return a.inner.Spec.CommonAnnotations
+}
+
 func (a *App) envObject(env string) (Environment, error) {
 	envObj, ok := a.inner.Spec.Environments[env]
 	if !ok {
@@ -301,16 +906,58 @@ func (a *App) ServerURL(env string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return e.Server, nil
+	return e.Server, nil
+}
+
+// Context returns the context for the supplied environment, if set.
+func (a *App) Context(env string) (string, error) {
+	e, err := a.envObject(env)
+	if err != nil {
+		return "", err
+	}
+	return e.Context, nil
+}
+
+// Credentials returns the credentials for the supplied environment with file paths resolved relative to the root
+// directory of the app, or nil if the environment does not define any.
+func (a *App) Credentials(env string) (*Credentials, error) {
+	e, err := a.envObject(env)
+	if err != nil {
+		return nil, err
+	}
+	if e.Credentials == nil {
+		return nil, nil
+	}
+	ret := *e.Credentials
+	resolve := func(file string) string {
+		if file == "" || filepath.IsAbs(file) {
+			return file
+		}
+		return filepath.Join(a.root, file)
+	}
+	ret.CertificateAuthority = resolve(ret.CertificateAuthority)
+	ret.TokenFile = resolve(ret.TokenFile)
+	return &ret, nil
+}
+
+// Impersonation returns the user and groups to impersonate for the supplied environment, or nil if the environment
+// does not define any.
+func (a *App) Impersonation(env string) (*Impersonation, error) {
+	e, err := a.envObject(env)
+	if err != nil {
+		return nil, err
+	}
+	return e.Impersonate, nil
 }
 
-// Context returns the context for the supplied environment, if set.
-func (a *App) Context(env string) (string, error) {
+// ClientRateLimits returns the QPS and burst configured for the kubernetes client of the supplied environment,
+// with zero values for limits that are not set.
+func (a *App) ClientRateLimits(env string) (qps int, burst int) {
 	e, err := a.envObject(env)
-	if err != nil {
-		return "", err
+	if err != nil || e.Client == nil {
+		return 0, 0
 	}
-	return e.Context, nil
+	return e.Client.QPS, e.Client.Burst
 }
 
 // BaseProperties returns the baseline properties defined for the app.
@@ -362,6 +1009,31 @@ func (a *App) Properties(env string) (map[string]interface{}, error) {
 	return deepMerge(a.BaseProperties(), eProps), nil
 }
 
+// ConfigFiles returns the local files from which the app configuration was loaded. These are the app file followed
+// by any included files and environment files, relative to the app root when possible. Remote files are not returned.
+func (a *App) ConfigFiles() []string {
+	return a.configFiles
+}
+
+// ExtraEnvFiles returns the environment files with which the app was loaded in addition to those configured
+// in the app file, as supplied to NewApp.
+func (a *App) ExtraEnvFiles() []string {
+	return a.extraEnvFiles
+}
+
+// VarFiles returns the dotenv files containing default values for external string variables for the supplied
+// environment, in the order in which they should be loaded. Files from inherited environments are returned first.
+func (a *App) VarFiles(env string) []string {
+	if env == Baseline {
+		return nil
+	}
+	e, err := a.envObject(env)
+	if err != nil {
+		return nil
+	}
+	return resolvePaths(a.base, e.VarFiles)
+}
+
 // DefaultNamespace returns the default namespace for the environment, potentially
 // suffixing it with any app-tag, if configured.
 func (a *App) DefaultNamespace(env string) string {
@@ -423,13 +1095,13 @@ func (a *App) ComponentsForEnvironment(env string, includes, excludes []string)
 		for k, v := range a.defaultComponents {
 			ret[k] = v
 		}
-		for _, k := range e.Excludes {
+		for _, k := range a.expandComponentRefs(e.Excludes) {
 			if _, ok := ret[k]; !ok {
 				sio.Warnf("component %s excluded from %s is already excluded by default\n", k, env)
 			}
 			delete(ret, k)
 		}
-		for _, k := range e.Includes {
+		for _, k := range a.expandComponentRefs(e.Includes) {
 			if _, ok := ret[k]; ok {
 				sio.Warnf("component %s included from %s is already included by default\n", k, env)
 			}
@@ -440,7 +1112,7 @@ func (a *App) ComponentsForEnvironment(env string, includes, excludes []string)
 		return toList(ret), nil
 	}
 
-	for _, k := range includes {
+	for _, k := range a.expandComponentRefs(includes) {
 		if _, ok := ret[k]; !ok {
 			sio.Noticef("not including component %s since it is not part of the component list for %s\n", k, env)
 		}
@@ -455,11 +1127,146 @@ func (a *App) ComponentsForEnvironment(env string, includes, excludes []string)
 	return toList(subret), nil
 }
 
+// AllComponents returns all components of the app sorted by name, whether or not they are included in any
+// environment.
+func (a *App) AllComponents() []Component {
+	var ret []Component
+	for _, v := range a.allComponents {
+		ret = append(ret, v)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// AddComponents adds ad-hoc components to the app that are included in every environment. Each path is either
+// a component file or a component directory that has an index.jsonnet or index.yaml file. Component names
+// must not conflict with existing components.
+func (a *App) AddComponents(paths []string) error {
+	for _, p := range paths {
+		s, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		var c *Component
+		if s.IsDir() {
+			c, err = dirComponent(p)
+			if err != nil {
+				return err
+			}
+			if c == nil {
+				return fmt.Errorf("%s is not a component directory, no index.jsonnet or index.yaml file found", p)
+			}
+		} else {
+			extension := filepath.Ext(p)
+			if !supportedExtensions[extension] {
+				return fmt.Errorf("%s is not a component file, extension must be one of .jsonnet, .json or .yaml", p)
+			}
+			c = &Component{
+				Name:  strings.TrimSuffix(filepath.Base(p), extension),
+				Files: []string{p},
+			}
+		}
+		if old, ok := a.allComponents[c.Name]; ok {
+			return fmt.Errorf("duplicate component %s, found %s and %s", c.Name, old.Files[0], c.Files[0])
+		}
+		a.allComponents[c.Name] = *c
+		a.defaultComponents[c.Name] = *c
+	}
+	return nil
+}
+
+// ComponentDependencies returns the names of components that the supplied component depends on.
+func (a *App) ComponentDependencies(name string) []string {
+	return a.allComponents[name].DependsOn
+}
+
+// Transforms returns the transforms that apply to the supplied environment, in the order in which they are declared.
+func (a *App) Transforms(env string) []Transform {
+	var ret []Transform
+	for _, t := range a.inner.Spec.Transforms {
+		if len(t.Environments) == 0 {
+			ret = append(ret, t)
+			continue
+		}
+		for _, e := range t.Environments {
+			if e == env {
+				ret = append(ret, t)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// Policies returns the policies checked for the supplied environment, in the order in which they are declared. The
+// level of every returned policy is set.
+func (a *App) Policies(env string) []Policy {
+	var ret []Policy
+	for _, p := range a.inner.Spec.Policies {
+		if p.Level == "" {
+			p.Level = PolicyDeny
+		}
+		p.File = resolvePath(a.base, p.File)
+		if len(p.Environments) == 0 {
+			ret = append(ret, p)
+			continue
+		}
+		for _, e := range p.Environments {
+			if e == env {
+				ret = append(ret, p)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// DiffIgnores returns the fields ignored by diffs for the supplied environment, in the order in which they are declared.
+func (a *App) DiffIgnores(env string) []DiffIgnore {
+	var ret []DiffIgnore
+	for _, di := range a.inner.Spec.DiffIgnores {
+		if len(di.Environments) == 0 {
+			ret = append(ret, di)
+			continue
+		}
+		for _, e := range di.Environments {
+			if e == env {
+				ret = append(ret, di)
+				break
+			}
+		}
+	}
+	return ret
+}
+
 // Environments returns the environments defined for the app.
 func (a *App) Environments() map[string]Environment {
 	return a.inner.Spec.Environments
 }
 
+// EnvironmentsForContext returns the sorted names of environments that correspond to the supplied kubeconfig
+// context name and server URL. Environments that explicitly refer to the context, or to a pattern matching it, are
+// returned when present, otherwise environments whose server URL matches are returned.
+func (a *App) EnvironmentsForContext(contextName, serverURL string) []string {
+	var byContext, byServer []string
+	for name, e := range a.inner.Spec.Environments {
+		switch {
+		case e.Context != "" && ContextMatches(e.Context, contextName):
+			byContext = append(byContext, name)
+		case e.Server != "" && e.Server == serverURL:
+			byServer = append(byServer, name)
+		}
+	}
+	ret := byContext
+	if len(ret) == 0 {
+		ret = byServer
+	}
+	sort.Strings(ret)
+	return ret
+}
+
 // DeclaredVars returns defaults for all declared external variables, keyed by variable name.
 func (a *App) DeclaredVars() map[string]interface{} {
 	ret := map[string]interface{}{}
@@ -498,14 +1305,70 @@ func (a *App) DataSourceExamples() map[string]interface{} {
 	return ret
 }
 
+// DataSourceTestPath returns the path to be resolved when testing the data source with the supplied name, or the
+// empty string if no path has been configured.
+func (a *App) DataSourceTestPath(name string) string {
+	return a.inner.Spec.DataSourceTestPaths[name]
+}
+
+// dirComponent returns the component for the supplied directory, which is either defined by an index.jsonnet file
+// or is the set of static files in the directory when an index.yaml file exists. It returns nil when the directory
+// is not a component directory.
+func dirComponent(path string) (*Component, error) {
+	files, err := filepath.Glob(filepath.Join(path, "*"))
+	if err != nil {
+		return nil, err
+	}
+	var staticFiles []string
+	hasIndexJsonnet := false
+	hasIndexYAML := false
+	for _, f := range files {
+		stat, err := os.Stat(f)
+		if err != nil {
+			return nil, err
+		}
+		if stat.IsDir() {
+			continue
+		}
+		switch filepath.Base(f) {
+		case "index.jsonnet":
+			hasIndexJsonnet = true
+		case "index.yaml":
+			hasIndexYAML = true
+		}
+		if strings.HasSuffix(f, ".json") || strings.HasSuffix(f, ".yaml") {
+			staticFiles = append(staticFiles, f)
+		}
+	}
+	switch {
+	case hasIndexJsonnet:
+		return &Component{
+			Name:  filepath.Base(path),
+			Files: []string{filepath.Join(path, "index.jsonnet")},
+		}, nil
+	case hasIndexYAML:
+		return &Component{
+			Name:  filepath.Base(path),
+			Files: staticFiles,
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
 // loadComponents loads metadata for all components for the app. It first expands the components directory
 // for glob patterns and loads components from all directories that match. It does _not_ recurse
-// into subdirectories. The data is returned as a map keyed by component name.
-// Note that component names must be unique across all directories. Support for multiple directories is just a
-// way to partition classes of components and does not introduce any namespace semantics.
+// into subdirectories unless component namespaces are enabled, in which case subdirectories that are not
+// components are loaded as namespaces one level deep, or recursion is enabled, in which case they are loaded at
+// any depth, with the subdirectory path prefixed to the names of the components they contain. The data is returned
+// as a map keyed by component name.
+// Note that component names must be unique across all directories, and so must their component label values, which
+// replace the slashes of namespaced names with dots. Support for multiple directories is just a way to partition
+// classes of components and does not introduce any namespaces.
 func (a *App) loadComponents() (map[string]Component, error) {
 	var list []Component
-	loadDirComponents := func(dir string) error {
+	var loadDirComponents func(dir string, prefix string) error
+	loadDirComponents = func(dir string, prefix string) error {
 		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
@@ -514,49 +1377,24 @@ func (a *App) loadComponents() (map[string]Component, error) {
 				return nil
 			}
 			if info.IsDir() {
-				files, err := filepath.Glob(filepath.Join(path, "*"))
+				c, err := dirComponent(path)
 				if err != nil {
 					return err
 				}
-				var staticFiles []string
-				hasIndexJsonnet := false
-				hasIndexYAML := false
-				for _, f := range files {
-					stat, err := os.Stat(f)
-					if err != nil {
+				if c != nil {
+					c.Name = prefix + c.Name
+					list = append(list, *c)
+				} else if a.inner.Spec.ComponentsDirRecurse || (prefix == "" && a.inner.Spec.ComponentNamespaces) {
+					if err := loadDirComponents(path, prefix+filepath.Base(path)+"/"); err != nil {
 						return err
 					}
-					if stat.IsDir() {
-						continue
-					}
-					switch filepath.Base(f) {
-					case "index.jsonnet":
-						hasIndexJsonnet = true
-					case "index.yaml":
-						hasIndexYAML = true
-					}
-					if strings.HasSuffix(f, ".json") || strings.HasSuffix(f, ".yaml") {
-						staticFiles = append(staticFiles, f)
-					}
-				}
-				switch {
-				case hasIndexJsonnet:
-					list = append(list, Component{
-						Name:  filepath.Base(path),
-						Files: []string{filepath.Join(path, "index.jsonnet")},
-					})
-				case hasIndexYAML:
-					list = append(list, Component{
-						Name:  filepath.Base(path),
-						Files: staticFiles,
-					})
 				}
 				return filepath.SkipDir
 			}
 			extension := filepath.Ext(path)
 			if supportedExtensions[extension] {
 				list = append(list, Component{
-					Name:  strings.TrimSuffix(filepath.Base(path), extension),
+					Name:  prefix + strings.TrimSuffix(filepath.Base(path), extension),
 					Files: []string{path},
 				})
 			}
@@ -564,7 +1402,7 @@ func (a *App) loadComponents() (map[string]Component, error) {
 		})
 		return err
 	}
-	ds, err := filepath.Glob(a.inner.Spec.ComponentsDir)
+	ds, err := filepath.Glob(resolvePath(a.base, a.inner.Spec.ComponentsDir))
 	if err != nil {
 		return nil, err
 	}
@@ -582,25 +1420,59 @@ func (a *App) loadComponents() (map[string]Component, error) {
 		return nil, fmt.Errorf("no component directories found after expanding %s", a.inner.Spec.ComponentsDir)
 	}
 	for _, d := range dirs {
-		err := loadDirComponents(d)
+		err := loadDirComponents(d, "")
 		if err != nil {
 			return nil, err
 		}
 	}
 	m := make(map[string]Component, len(list))
+	labelValues := map[string]string{}
 	for _, c := range list {
 		if old, ok := m[c.Name]; ok {
 			return nil, fmt.Errorf("duplicate component %s, found %s and %s", c.Name, old.Files[0], c.Files[0])
 		}
+		lv := componentLabelValue(c.Name)
+		if other, ok := labelValues[lv]; ok {
+			return nil, fmt.Errorf("components %s and %s cannot be told apart by the component label value %s, rename one of them", other, c.Name, lv)
+		}
 		m[c.Name] = c
+		labelValues[lv] = c.Name
 	}
 	return m, nil
 }
 
+// componentRefs returns the names of components matched by the supplied reference, which is either a component
+// name or a namespace prefix ending with a slash that matches all components in the namespace.
+func (a *App) componentRefs(ref string) []string {
+	if !strings.HasSuffix(ref, "/") {
+		if _, ok := a.allComponents[ref]; ok {
+			return []string{ref}
+		}
+		return nil
+	}
+	var ret []string
+	for name := range a.allComponents {
+		if strings.HasPrefix(name, ref) {
+			ret = append(ret, name)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// expandComponentRefs returns the component names for the supplied references, expanding namespace prefixes.
+func (a *App) expandComponentRefs(refs []string) []string {
+	var ret []string
+	for _, ref := range refs {
+		ret = append(ret, a.componentRefs(ref)...)
+	}
+	return ret
+}
+
 func (a *App) verifyComponentList(src string, comps []string) error {
 	var bad []string
 	for _, c := range comps {
-		if _, ok := a.allComponents[c]; !ok {
+		if len(a.componentRefs(c)) == 0 {
 			bad = append(bad, c)
 		}
 	}
@@ -696,17 +1568,299 @@ func checkProcessors(pType string, files []string) error {
 }
 
 func (a *App) verifyProcessors() error {
+	if err := checkProcessors("pre", a.PreProcessors()); err != nil {
+		return err
+	}
 	if err := checkProcessors("post", a.PostProcessors()); err != nil {
 		return err
 	}
 	return nil
 }
 
+func (a *App) verifyCommonMetadata() error {
+	check := func(kind string, m map[string]string, checkValue bool) error {
+		for k, v := range m {
+			if strings.HasPrefix(k, QBECMetadataPrefix) {
+				return fmt.Errorf("invalid common %s '%s', keys with prefix %s are reserved for qbec", kind, k, QBECMetadataPrefix)
+			}
+			if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+				return fmt.Errorf("invalid common %s '%s': %s", kind, k, strings.Join(errs, ", "))
+			}
+			if !checkValue {
+				continue
+			}
+			if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+				return fmt.Errorf("invalid value '%s' for common %s '%s': %s", v, kind, k, strings.Join(errs, ", "))
+			}
+		}
+		return nil
+	}
+	if err := check("label", a.inner.Spec.CommonLabels, true); err != nil {
+		return err
+	}
+	return check("annotation", a.inner.Spec.CommonAnnotations, false)
+}
+
+func verifyTimeout(t string) error {
+	if t == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(t)
+	if err != nil {
+		return fmt.Errorf("invalid component timeout '%s': %v", t, err)
+	}
+	if d < 0 {
+		return fmt.Errorf("invalid component timeout '%s': must not be negative", t)
+	}
+	return nil
+}
+
+func (a *App) verifyComponentTimeout() error {
+	if err := verifyTimeout(a.inner.Spec.ComponentTimeout); err != nil {
+		return err
+	}
+	var names []string
+	for name := range a.inner.Spec.Components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := verifyTimeout(a.inner.Spec.Components[name].Timeout); err != nil {
+			return errors.Wrapf(err, "component %s", name)
+		}
+	}
+	return nil
+}
+
+func (a *App) verifyEvalSettings() error {
+	var names []string
+	for name := range a.inner.Spec.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		e := a.inner.Spec.Environments[name]
+		if e.Eval == nil {
+			continue
+		}
+		if e.Eval.Concurrency < 0 {
+			return fmt.Errorf("environment %s: invalid eval concurrency %d, must not be negative", name, e.Eval.Concurrency)
+		}
+		if err := verifyTimeout(e.Eval.ComponentTimeout); err != nil {
+			return errors.Wrapf(err, "environment %s", name)
+		}
+		if m := e.Eval.MaxDataSourceBytes; m != nil && *m < 0 {
+			return fmt.Errorf("environment %s: invalid data source size limit %d, must not be negative", name, *m)
+		}
+	}
+	return nil
+}
+
+func (a *App) verifyCanonicalVersions() error {
+	for k, v := range a.inner.Spec.CanonicalVersions {
+		gk := schema.ParseGroupKind(k)
+		if gk.Kind == "" || v == "" {
+			return fmt.Errorf("invalid canonical version %s: %q, must be a version for a type of the form Kind.group", k, v)
+		}
+	}
+	return nil
+}
+
+func (a *App) verifyWaitStatus() error {
+	for k, v := range a.inner.Spec.WaitStatus {
+		gk := schema.ParseGroupKind(k)
+		if gk.Kind == "" || strings.TrimSpace(v) == "" {
+			return fmt.Errorf("invalid wait status %s: %q, must be a status expression for a type of the form Kind.group", k, v)
+		}
+		if _, err := statusexpr.Parse(v); err != nil {
+			return errors.Wrapf(err, "invalid wait status %s", k)
+		}
+	}
+	return nil
+}
+
+func (a *App) verifyComponentDependencies() error {
+	comps := a.inner.Spec.Components
+	var names []string
+	for name, spec := range comps {
+		if _, ok := a.allComponents[name]; !ok {
+			return fmt.Errorf("component configuration: bad component reference %s", name)
+		}
+		if err := a.verifyComponentList("dependencies for component "+name, spec.DependsOn); err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checked := map[string]bool{}
+	var check func(name string, chain []string) error
+	check = func(name string, chain []string) error {
+		if checked[name] {
+			return nil
+		}
+		for _, c := range chain {
+			if c == name {
+				return fmt.Errorf("component dependency cycle: %s", strings.Join(append(chain, name), " -> "))
+			}
+		}
+		for _, dep := range a.expandComponentRefs(comps[name].DependsOn) {
+			if err := check(dep, append(chain, name)); err != nil {
+				return err
+			}
+		}
+		checked[name] = true
+		return nil
+	}
+	for _, name := range names {
+		if err := check(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *App) verifyLibraryBundles() error {
+	for name, file := range a.inner.Spec.LibraryBundles {
+		if !reLabelValue.MatchString(name) {
+			return fmt.Errorf("invalid library bundle name %s, must match %s", name, reLabelValue)
+		}
+		if file == "" {
+			return fmt.Errorf("no file specified for library bundle %s", name)
+		}
+	}
+	return nil
+}
+
+var jsonPatchOps = map[string]bool{"add": true, "remove": true, "replace": true, "move": true, "copy": true, "test": true}
+
+func (a *App) verifyTransforms() error {
+	for i, t := range a.inner.Spec.Transforms {
+		prefix := fmt.Sprintf("transform %d", i)
+		for _, e := range t.Environments {
+			if _, ok := a.inner.Spec.Environments[e]; !ok && e != Baseline {
+				return fmt.Errorf("%s: invalid environment %q", prefix, e)
+			}
+		}
+		if t.Target.Component != "" {
+			if _, ok := a.allComponents[t.Target.Component]; !ok {
+				return fmt.Errorf("%s: bad component reference %s", prefix, t.Target.Component)
+			}
+		}
+		if (t.Patch == nil) == (t.JSONPatch == nil) {
+			return fmt.Errorf("%s: exactly one of patch or jsonPatch must be specified", prefix)
+		}
+		for j, op := range t.JSONPatch {
+			m, ok := op.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: JSON patch operation %d is not an object", prefix, j)
+			}
+			name, _ := m["op"].(string)
+			if !jsonPatchOps[name] {
+				return fmt.Errorf("%s: JSON patch operation %d: invalid op %q", prefix, j, name)
+			}
+			if _, ok := m["path"].(string); !ok {
+				return fmt.Errorf("%s: JSON patch operation %d: no path specified", prefix, j)
+			}
+		}
+	}
+	return nil
+}
+
+func (a *App) verifyPolicies() error {
+	seen := map[string]bool{}
+	for i, p := range a.inner.Spec.Policies {
+		prefix := fmt.Sprintf("policy %d", i)
+		if p.Name == "" {
+			return fmt.Errorf("%s: name not specified", prefix)
+		}
+		prefix = fmt.Sprintf("policy %s", p.Name)
+		if seen[p.Name] {
+			return fmt.Errorf("%s: duplicate policy name", prefix)
+		}
+		seen[p.Name] = true
+		if p.File == "" {
+			return fmt.Errorf("%s: file not specified", prefix)
+		}
+		if strings.HasSuffix(p.File, ".cue") {
+			return fmt.Errorf("%s: CUE policies are not supported, use a jsonnet or rego policy", prefix)
+		}
+		if p.Level != "" && p.Level != PolicyDeny && p.Level != PolicyWarn {
+			return fmt.Errorf("%s: invalid level %q, must be one of %q or %q", prefix, p.Level, PolicyDeny, PolicyWarn)
+		}
+		for _, e := range p.Environments {
+			if _, ok := a.inner.Spec.Environments[e]; !ok && e != Baseline {
+				return fmt.Errorf("%s: invalid environment %q", prefix, e)
+			}
+		}
+		if p.Target.Component != "" {
+			if _, ok := a.allComponents[p.Target.Component]; !ok {
+				return fmt.Errorf("%s: bad component reference %s", prefix, p.Target.Component)
+			}
+		}
+	}
+	return nil
+}
+
+func (a *App) verifyDiffIgnores() error {
+	for i, di := range a.inner.Spec.DiffIgnores {
+		prefix := fmt.Sprintf("diff ignore %d", i)
+		for _, e := range di.Environments {
+			if _, ok := a.inner.Spec.Environments[e]; !ok && e != Baseline {
+				return fmt.Errorf("%s: invalid environment %q", prefix, e)
+			}
+		}
+		if di.Target.Component != "" {
+			if _, ok := a.allComponents[di.Target.Component]; !ok {
+				return fmt.Errorf("%s: bad component reference %s", prefix, di.Target.Component)
+			}
+		}
+		for _, p := range di.Paths {
+			if _, err := fieldpath.Parse(p); err != nil {
+				return fmt.Errorf("%s: %v", prefix, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (a *App) verifyRedactions() error {
+	for i, r := range a.inner.Spec.Redactions {
+		for _, p := range r.Paths {
+			if _, err := fieldpath.Parse(p); err != nil {
+				return fmt.Errorf("redaction %d: %v", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (a *App) updateComponentDependencies() {
+	for name, spec := range a.inner.Spec.Components {
+		if len(spec.DependsOn) == 0 {
+			continue
+		}
+		comp := a.allComponents[name]
+		comp.DependsOn = a.expandComponentRefs(spec.DependsOn)
+		a.allComponents[name] = comp
+	}
+}
+
+func (a *App) updateComponentTimeouts() {
+	for name, spec := range a.inner.Spec.Components {
+		if spec.Timeout == "" {
+			continue
+		}
+		comp := a.allComponents[name]
+		comp.Timeout, _ = time.ParseDuration(spec.Timeout) // already verified at load time
+		a.allComponents[name] = comp
+	}
+}
+
 func (a *App) updateComponentTopLevelVars() {
 	componentTLAMap := map[string][]string{}
 
 	for _, tla := range a.inner.Spec.Vars.TopLevel {
-		for _, comp := range tla.Components {
+		for _, comp := range a.expandComponentRefs(tla.Components) {
 			componentTLAMap[comp] = append(componentTLAMap[comp], tla.Name)
 		}
 	}
@@ -718,6 +1872,45 @@ func (a *App) updateComponentTopLevelVars() {
 	}
 }
 
+// PreApplyHooks returns the hooks to be run before objects are applied.
+func (a *App) PreApplyHooks() []Hook {
+	if a.inner.Spec.Hooks == nil {
+		return nil
+	}
+	return a.inner.Spec.Hooks.PreApply
+}
+
+// PostApplyHooks returns the hooks to be run after objects are applied.
+func (a *App) PostApplyHooks() []Hook {
+	if a.inner.Spec.Hooks == nil {
+		return nil
+	}
+	return a.inner.Spec.Hooks.PostApply
+}
+
+func (a *App) verifyHooks() error {
+	if a.inner.Spec.Hooks == nil {
+		return nil
+	}
+	check := func(phase string, hooks []Hook) error {
+		seen := map[string]bool{}
+		for _, h := range hooks {
+			if seen[h.Name] {
+				return fmt.Errorf("duplicate %s hook %s", phase, h.Name)
+			}
+			seen[h.Name] = true
+			if (h.Exec == nil) == (h.Jsonnet == "") {
+				return fmt.Errorf("%s hook %s: exactly one of exec or jsonnet must be specified", phase, h.Name)
+			}
+		}
+		return nil
+	}
+	if err := check("preApply", a.inner.Spec.Hooks.PreApply); err != nil {
+		return err
+	}
+	return check("postApply", a.inner.Spec.Hooks.PostApply)
+}
+
 // ClusterScopedLists returns the value of the qbec app attribute to determine if cluster scope
 // lists should be performed when multiple namespaces are present.
 func (a *App) ClusterScopedLists() bool {
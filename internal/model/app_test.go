@@ -36,6 +36,7 @@ import (
 	"github.com/splunk/qbec/internal/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 func setPwd(t *testing.T, dir string) func() {
@@ -108,6 +109,8 @@ func TestAppSimple(t *testing.T) {
 	a.Contains(app.allComponents, "service2")
 	a.NotContains(app.defaultComponents, "service2")
 	a.Equal(false, app.AddComponentLabel())
+	a.Equal(SourceAnnotations{Timestamp: SourceTimestampNone}, app.SourceAnnotations())
+	a.Equal([]string{"qbec.yaml", "prod-env.yaml", "stage-env.yaml"}, app.ConfigFiles())
 
 	comps, err := app.ComponentsForEnvironment("_", nil, nil)
 	require.Nil(t, err)
@@ -340,6 +343,159 @@ func TestAppComponentLoadMultidirs(t *testing.T) {
 	a.Contains(comp.Files, filepath.Join("components", "dir2", "b", "index.jsonnet"))
 }
 
+func TestAppComponentNamespaces(t *testing.T) {
+	reset := setPwd(t, "testdata/namespace-app")
+	defer reset()
+	app, err := NewApp("qbec.yaml", nil, "")
+	require.NoError(t, err)
+	names := func(comps []Component) []string {
+		var ret []string
+		for _, c := range comps {
+			ret = append(ret, c.Name)
+		}
+		return ret
+	}
+	a := assert.New(t)
+	a.Equal([]string{"team-a/svc1", "team-a/svc2", "team-b/svc3", "team-b/svc4", "top"}, names(app.AllComponents()))
+	a.Equal(filepath.Join("components", "team-a", "svc2", "index.jsonnet"), app.AllComponents()[1].Files[0])
+	a.Equal([]string{"team-a/svc1", "team-a/svc2"}, app.ComponentDependencies("top"))
+	a.Equal([]string{"team-a/svc1"}, app.ComponentDependencies("team-b/svc4"))
+
+	_, err = NewApp("qbec-deps-cycle.yaml", nil, "")
+	require.Error(t, err)
+	a.Equal("component dependency cycle: team-a/svc2 -> top -> team-a/svc2", err.Error())
+
+	comps, err := app.ComponentsForEnvironment("dev", nil, nil)
+	require.NoError(t, err)
+	a.Equal([]string{"team-a/svc1", "team-a/svc2", "team-b/svc3", "top"}, names(comps))
+
+	comps, err = app.ComponentsForEnvironment("prod", nil, nil)
+	require.NoError(t, err)
+	a.Equal([]string{"top"}, names(comps))
+
+	comps, err = app.ComponentsForEnvironment("dev", []string{"team-a/"}, nil)
+	require.NoError(t, err)
+	a.Equal([]string{"team-a/svc1", "team-a/svc2"}, names(comps))
+
+	comps, err = app.ComponentsForEnvironment("dev", nil, []string{"team-a/", "top"})
+	require.NoError(t, err)
+	a.Equal([]string{"team-b/svc3"}, names(comps))
+
+	_, err = app.ComponentsForEnvironment("dev", []string{"team-c/"}, nil)
+	require.Error(t, err)
+	a.Equal("specified components: bad component reference(s): team-c/", err.Error())
+}
+
+func TestAppComponentNamespaceLabelCollision(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "components", "team-a"), 0755))
+	for _, f := range []string{"team-a/svc1.jsonnet", "team-a.svc1.jsonnet"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "components", filepath.FromSlash(f)), []byte("{}"), 0644))
+	}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "qbec.yaml"), []byte(`apiVersion: qbec.io/v1alpha1
+kind: App
+metadata:
+  name: collide-app
+spec:
+  componentNamespaces: true
+  environments:
+    dev:
+      server: https://dev-server
+`), 0644))
+	_, err := NewApp(filepath.Join(dir, "qbec.yaml"), nil, "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "components team-a/svc1 and team-a.svc1 cannot be told apart by the component label value team-a.svc1")
+}
+
+func TestAppComponentsDirRecurse(t *testing.T) {
+	reset := setPwd(t, "testdata/recurse-app")
+	defer reset()
+	app, err := NewApp("qbec.yaml", nil, "")
+	require.NoError(t, err)
+	names := func(comps []Component) []string {
+		var ret []string
+		for _, c := range comps {
+			ret = append(ret, c.Name)
+		}
+		return ret
+	}
+	a := assert.New(t)
+	all := app.AllComponents()
+	a.Equal([]string{"apps/web/static", "platform/ingress/nginx", "platform/monitoring/alerts", "platform/monitoring/prom", "top"}, names(all))
+	a.Equal([]string{
+		filepath.Join("components", "apps", "web", "static", "cm.yaml"),
+		filepath.Join("components", "apps", "web", "static", "index.yaml"),
+	}, all[0].Files)
+
+	comps, err := app.ComponentsForEnvironment("dev", nil, nil)
+	require.NoError(t, err)
+	a.Equal([]string{"apps/web/static", "platform/ingress/nginx", "top"}, names(comps))
+
+	comps, err = app.ComponentsForEnvironment(Baseline, []string{"platform/"}, nil)
+	require.NoError(t, err)
+	a.Equal([]string{"platform/ingress/nginx", "platform/monitoring/alerts", "platform/monitoring/prom"}, names(comps))
+}
+
+func TestAppAddComponents(t *testing.T) {
+	reset := setPwd(t, "testdata/multi-dir-app")
+	defer reset()
+	app, err := NewApp("qbec.yaml", nil, "")
+	require.Nil(t, err)
+	err = app.AddComponents([]string{
+		filepath.Join("..", "subdir-app", "components", "comp1"),
+		filepath.Join("..", "subdir-app", "components", "comp2", "cm1.yaml"),
+	})
+	require.Nil(t, err)
+	comps, err := app.ComponentsForEnvironment("dev", nil, nil)
+	require.Nil(t, err)
+	a := assert.New(t)
+	a.Equal(4, len(comps))
+	a.Equal("cm1", comps[2].Name)
+	a.Equal([]string{filepath.Join("..", "subdir-app", "components", "comp2", "cm1.yaml")}, comps[2].Files)
+	a.Equal("comp1", comps[3].Name)
+	a.Equal([]string{filepath.Join("..", "subdir-app", "components", "comp1", "index.jsonnet")}, comps[3].Files)
+
+	comps, err = app.ComponentsForEnvironment("dev", []string{"comp1"}, nil)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(comps))
+	a.Equal("comp1", comps[0].Name)
+}
+
+func TestAppAddComponentsNegative(t *testing.T) {
+	tests := []struct {
+		path string
+		msg  string
+	}{
+		{
+			path: filepath.Join("components", "dir1", "a.jsonnet"),
+			msg:  "duplicate component a, found " + filepath.Join("components", "dir1", "a.jsonnet") + " and " + filepath.Join("components", "dir1", "a.jsonnet"),
+		},
+		{
+			path: filepath.Join("components", "README.md"),
+			msg:  filepath.Join("components", "README.md") + " is not a component file, extension must be one of .jsonnet, .json or .yaml",
+		},
+		{
+			path: "components",
+			msg:  "components is not a component directory, no index.jsonnet or index.yaml file found",
+		},
+		{
+			path: "missing",
+			msg:  "stat missing: no such file or directory",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			reset := setPwd(t, "testdata/multi-dir-app")
+			defer reset()
+			app, err := NewApp("qbec.yaml", nil, "")
+			require.Nil(t, err)
+			err = app.AddComponents([]string{test.path})
+			require.NotNil(t, err)
+			assert.Equal(t, test.msg, err.Error())
+		})
+	}
+}
+
 func TestAppComponentNoDirs(t *testing.T) {
 	reset := setPwd(t, "testdata/no-dirs-app")
 	defer reset()
@@ -545,6 +701,12 @@ func TestAppNegative(t *testing.T) {
 				assert.Contains(t, err.Error(), "verify environment foo: context for environment ('__current__') may not start with __")
 			},
 		},
+		{
+			file: "bad-dup-preproc.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "invalid pre-processor 'lib2/foo.jsonnet', has the same base name as 'lib/foo.jsonnet'")
+			},
+		},
 		{
 			file: "bad-dup-postproc.yaml",
 			asserter: func(t *testing.T, err error) {
@@ -557,6 +719,280 @@ func TestAppNegative(t *testing.T) {
 				assert.Contains(t, err.Error(), "duplicate external variable foo")
 			},
 		},
+		{
+			file: "bad-common-label-prefix.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "invalid common label 'qbec.io/foo', keys with prefix qbec.io/ are reserved for qbec")
+			},
+		},
+		{
+			file: "bad-common-label-value.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "invalid value 'a team' for common label 'team'")
+			},
+		},
+		{
+			file: "bad-common-annotation-key.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "invalid common annotation 'example.com/with space'")
+			},
+		},
+		{
+			file: "bad-component-timeout.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "invalid component timeout '10 minutes'")
+			},
+		},
+		{
+			file: "bad-component-spec-timeout.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, "component a: invalid component timeout '-1s': must not be negative", err.Error())
+			},
+		},
+		{
+			file: "bad-hook-both.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, "preApply hook migrate: exactly one of exec or jsonnet must be specified", err.Error())
+			},
+		},
+		{
+			file: "bad-hook-dup.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, "duplicate postApply hook notify", err.Error())
+			},
+		},
+		{
+			file: "bad-hook-name.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "spec.hooks.preApply.name")
+			},
+		},
+		{
+			file: "bad-env-eval-timeout.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, "environment dev: invalid component timeout '-1m': must not be negative", err.Error())
+			},
+		},
+		{
+			file: "bad-env-creds-no-server.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "verify environment dev: credentials may only be set for environments that specify a server")
+			},
+		},
+		{
+			file: "bad-env-creds-multi.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "verify environment dev: credentials: only one of tokenFile, tokenEnv or exec may be set")
+			},
+		},
+		{
+			file: "bad-env-creds-ca.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "verify environment dev: credentials: invalid certificateAuthorityData")
+			},
+		},
+		{
+			file: "bad-env-creds-exec.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "spec.environments.dev.credentials.exec")
+				assert.Contains(t, err.Error(), "command")
+			},
+		},
+		{
+			file: "bad-env-impersonate.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "spec.environments.dev.impersonate")
+				assert.Contains(t, err.Error(), "user")
+			},
+		},
+		{
+			file: "bad-env-client-qps.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "spec.environments.dev.client.qps")
+			},
+		},
+		{
+			file: "bad-env-eval-concurrency.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "spec.environments.dev.eval.concurrency")
+			},
+		},
+		{
+			file: "bad-annotate-source.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "spec.annotateSource.timestamp")
+			},
+		},
+		{
+			file: "bad-canonical-versions.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, `invalid canonical version .kyverno.io: "v1", must be a version for a type of the form Kind.group`, err.Error())
+			},
+		},
+		{
+			file: "bad-wait-status.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, `invalid wait status Certificate.cert-manager.io: "", must be a status expression for a type of the form Kind.group`, err.Error())
+			},
+		},
+		{
+			file: "bad-wait-status-expr.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, `invalid wait status Certificate.cert-manager.io: invalid status expression ".status.conditions[?type==Ready].status", must be of the form '<path> == <value>' or '<path> != <value>'`, err.Error())
+			},
+		},
+		{
+			file: "bad-component-deps-cycle.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, "component dependency cycle: a -> c -> b -> a", err.Error())
+			},
+		},
+		{
+			file: "bad-component-deps-ref.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, "dependencies for component a: bad component reference(s): d", err.Error())
+			},
+		},
+		{
+			file: "bad-component-config-ref.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, "component configuration: bad component reference d", err.Error())
+			},
+		},
+		{
+			file: "bad-library-bundle.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "invalid library bundle name k8s/lib, must match")
+			},
+		},
+		{
+			file: "bad-include.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "invalid-fragment.yaml, 1 schema validation error(s): spec.componentsDir in body is a forbidden property")
+			},
+		},
+		{
+			file: "bad-include-kind.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "invalid-env.yaml, 1 schema validation error(s): bad kind property, expected AppFragment")
+			},
+		},
+		{
+			file: "bad-http-lib-path.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "spec.httpLibPaths.url in body should match '^https://'")
+				assert.Contains(t, err.Error(), "spec.httpLibPaths.sha256.k.libsonnet in body should match")
+			},
+		},
+		{
+			file: "bad-transform-env.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, `transform 0: invalid environment "prod"`, err.Error())
+			},
+		},
+		{
+			file: "bad-transform-component.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, "transform 0: bad component reference d", err.Error())
+			},
+		},
+		{
+			file: "bad-transform-no-patch.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, "transform 0: exactly one of patch or jsonPatch must be specified", err.Error())
+			},
+		},
+		{
+			file: "bad-transform-json-op.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, `transform 0: JSON patch operation 0: invalid op "merge"`, err.Error())
+			},
+		},
+		{
+			file: "bad-diff-ignore-env.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, `diff ignore 0: invalid environment "prod"`, err.Error())
+			},
+		},
+		{
+			file: "bad-diff-ignore-path.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, `diff ignore 0: invalid path "spec.replicas": expected . or [ at "spec.replicas"`, err.Error())
+			},
+		},
+		{
+			file: "bad-diff-ignore-no-paths.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "spec.diffIgnores.paths in body is required")
+			},
+		},
+		{
+			file: "bad-policy-env.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, `policy limits: invalid environment "prod"`, err.Error())
+			},
+		},
+		{
+			file: "bad-policy-dup.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, `policy limits: duplicate policy name`, err.Error())
+			},
+		},
+		{
+			file: "bad-policy-level.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "spec.policies.level in body should be one of [deny warn]")
+			},
+		},
+		{
+			file: "bad-policy-cue.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, "policy limits: CUE policies are not supported, use a jsonnet or rego policy", err.Error())
+			},
+		},
+		{
+			file: "bad-eval.jsonnet",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "evaluate bad-eval.jsonnet")
+				assert.Contains(t, err.Error(), "no name")
+			},
+		},
+		{
+			file: "bad-schema.jsonnet",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "spec.environments.dev.server in body must be of type string")
+			},
+		},
+		{
+			file: "bad-redaction-path.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, `redaction 0: invalid path "data": expected . or [ at "data"`, err.Error())
+			},
+		},
+		{
+			file: "bad-redaction-no-kind.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Contains(t, err.Error(), "spec.redactions.kind in body is required")
+			},
+		},
+		{
+			file: "bad-env-inherits-cycle.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, "environment inheritance cycle: a -> c -> b -> a", err.Error())
+			},
+		},
+		{
+			file: "bad-app-tag-pattern.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, `verify environment dev: invalid app tag pattern "pr-[": syntax error in pattern`, err.Error())
+			},
+		},
+		{
+			file: "bad-env-inherits-unknown.yaml",
+			asserter: func(t *testing.T, err error) {
+				assert.Equal(t, "environment dev inherits from unknown environment base", err.Error())
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -607,4 +1043,387 @@ func TestAppLabel(t *testing.T) {
 	require.Nil(t, err)
 	a := assert.New(t)
 	a.Equal(true, app.AddComponentLabel())
+	a.Equal(map[string]string{"team": "platform"}, app.CommonLabels())
+	a.Equal(map[string]string{"example.com/owner": "platform team"}, app.CommonAnnotations())
+	a.Equal(90*time.Second, app.ComponentTimeout())
+	for _, c := range app.AllComponents() {
+		if c.Name == "index" {
+			a.Equal(5*time.Minute, c.Timeout)
+		} else {
+			a.Equal(time.Duration(0), c.Timeout)
+		}
+	}
+	a.Equal([]HTTPLibPath{{
+		URL:    "https://example.com/lib/v1",
+		SHA256: map[string]string{"k.libsonnet": "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"},
+	}}, app.HTTPLibPaths())
+	a.Equal(SourceAnnotations{Enabled: true, Timestamp: SourceTimestampCommit}, app.SourceAnnotations())
+	a.Equal(map[schema.GroupKind]string{
+		{Group: "kyverno.io", Kind: "ClusterPolicy"}: "v1",
+		{Kind: "Deployment"}:                         "v1",
+	}, app.CanonicalVersions())
+	a.Equal(map[schema.GroupKind]string{
+		{Group: "cert-manager.io", Kind: "Certificate"}: ".status.conditions[?type==Ready].status == True",
+	}, app.WaitStatusExpressions())
+	redactions := app.Redactions()
+	a.Equal(2, len(redactions))
+	a.Equal(1, len(redactions[schema.GroupKind{Group: "external-secrets.io", Kind: "ExternalSecret"}]))
+	cmPaths := redactions[schema.GroupKind{Kind: "ConfigMap"}]
+	require.Equal(t, 2, len(cmPaths))
+	a.Equal(".data.token", cmPaths[1].String())
+	a.Equal([]string{"cm"}, app.ComponentDependencies("index"))
+	a.Nil(app.ComponentDependencies("cm"))
+	a.Equal(1, len(app.Transforms("dev")))
+	prod := app.Transforms("prod")
+	require.Equal(t, 2, len(prod))
+	a.Equal("ConfigMap", prod[0].Target.Kind)
+	a.Equal("index", prod[1].Target.Component)
+}
+
+func TestAppJsonnet(t *testing.T) {
+	reset := setPwd(t, "testdata/jsonnet-app")
+	defer reset()
+	app, err := NewApp("qbec.jsonnet", nil, "")
+	require.NoError(t, err)
+	a := assert.New(t)
+	a.Equal("jsonnet-app", app.Name())
+	envs := app.Environments()
+	a.Equal(4, len(envs))
+	a.Equal("https://prod.eu-west.example.com", envs["prod-eu-west"].Server)
+	props, err := app.Properties("dev-us-east")
+	require.NoError(t, err)
+	a.Equal(map[string]interface{}{"region": "us-east", "stage": "dev"}, props)
+	comps, err := app.ComponentsForEnvironment("dev-us-east", nil, nil)
+	require.NoError(t, err)
+	a.Equal(1, len(comps))
+}
+
+func TestAppEnvInheritance(t *testing.T) {
+	reset := setPwd(t, "testdata/inherit-app")
+	defer reset()
+	app, err := NewApp("qbec.yaml", nil, "")
+	require.NoError(t, err)
+	a := assert.New(t)
+
+	envs := app.Environments()
+	a.Equal("https://base-server", envs["dev"].Server)
+	a.Equal("https://dev2-server", envs["dev2"].Server)
+	a.Equal("prod-context", envs["prod"].Context)
+
+	a.Equal("base-ns", app.DefaultNamespace("dev"))
+	a.Equal("base-ns", app.DefaultNamespace("dev2"))
+	a.Equal("prod-ns", app.DefaultNamespace("prod"))
+
+	creds, err := app.Credentials("dev")
+	require.NoError(t, err)
+	require.NotNil(t, creds)
+	a.Equal(filepath.Join(app.root, "certs", "ca.pem"), creds.CertificateAuthority)
+	a.Equal("/var/run/secrets/token", creds.TokenFile)
+	creds, err = app.Credentials("dev2")
+	require.NoError(t, err)
+	a.Nil(creds)
+	creds, err = app.Credentials("prod")
+	require.NoError(t, err)
+	a.Nil(creds)
+
+	imp, err := app.Impersonation("dev2")
+	require.NoError(t, err)
+	a.Equal(&Impersonation{User: "deployer", Groups: []string{"deployers"}}, imp)
+	imp, err = app.Impersonation("prod")
+	require.NoError(t, err)
+	a.Equal(&Impersonation{User: "prod-deployer"}, imp)
+
+	qps, burst := app.ClientRateLimits("dev")
+	a.Equal(50, qps)
+	a.Equal(100, burst)
+	qps, burst = app.ClientRateLimits("dev2")
+	a.Equal(20, qps)
+	a.Equal(100, burst)
+	qps, burst = app.ClientRateLimits("prod")
+	a.Equal(0, qps)
+	a.Equal(0, burst)
+
+	a.Equal([]string{"base.env"}, app.VarFiles("dev"))
+	a.Equal([]string{"base.env", "dev2.env"}, app.VarFiles("dev2"))
+	a.Nil(app.VarFiles("prod"))
+	a.Nil(app.VarFiles(Baseline))
+
+	a.Equal(4, app.EvalConcurrency("dev"))
+	a.Equal(2, app.EvalConcurrency("dev2"))
+	a.Equal(0, app.EvalConcurrency("prod"))
+	a.Equal(time.Minute, app.EnvComponentTimeout("dev2"))
+	a.Equal(time.Duration(0), app.EnvComponentTimeout("prod"))
+	limit, ok := app.MaxDataSourceBytes("dev2")
+	a.True(ok)
+	a.EqualValues(1024, limit)
+	_, ok = app.MaxDataSourceBytes("prod")
+	a.False(ok)
+
+	props, err := app.Properties("dev")
+	require.NoError(t, err)
+	a.EqualValues(map[string]interface{}{
+		"replicas":  float64(1),
+		"resources": map[string]interface{}{"cpu": "100m", "memory": "128Mi"},
+	}, props)
+	props, err = app.Properties("dev2")
+	require.NoError(t, err)
+	a.EqualValues(map[string]interface{}{
+		"replicas":  float64(2),
+		"resources": map[string]interface{}{"cpu": "100m", "memory": "128Mi"},
+	}, props)
+
+	names := func(env string) []string {
+		comps, err := app.ComponentsForEnvironment(env, nil, nil)
+		require.NoError(t, err)
+		var ret []string
+		for _, c := range comps {
+			ret = append(ret, c.Name)
+		}
+		return ret
+	}
+	a.Equal([]string{"cm", "index"}, names("dev"))
+	a.Equal([]string{"cm"}, names("dev2"))
+	a.Equal([]string{"cm"}, names("prod"))
+}
+
+func TestAppInterpolation(t *testing.T) {
+	reset := setPwd(t, "testdata/interpolate-app")
+	defer reset()
+	a := assert.New(t)
+
+	app, err := NewApp("qbec.yaml", nil, "")
+	require.NoError(t, err)
+	envs := app.Environments()
+	a.Equal("https://us-west-2.example.com", envs["base"].Server)
+	a.Equal("https://us-west-2.example.com", envs["prod"].Server)
+	a.Equal("payments-base", app.DefaultNamespace("base"))
+	a.Equal("payments-prod", app.DefaultNamespace("prod"))
+	a.Equal("plain", app.DefaultNamespace("plain"))
+	props, err := app.Properties("prod")
+	require.NoError(t, err)
+	a.EqualValues(map[string]interface{}{
+		"owner":    "payments",
+		"replicas": "2",
+		"hosts":    []interface{}{"payments.us-west-2.example.com"},
+		"static":   float64(10),
+		"escaped":  "{{ .team }}",
+		"quoted":   "{{ $labels.instance }}",
+	}, props)
+
+	app, err = NewAppWithVars("qbec.yaml", nil, "", map[string]string{"team": "search", "region": "eu-west-1"})
+	require.NoError(t, err)
+	a.Equal("https://eu-west-1.example.com", app.Environments()["prod"].Server)
+	a.Equal("search-prod", app.DefaultNamespace("prod"))
+	props, err = app.Properties("base")
+	require.NoError(t, err)
+	a.Equal("search", props["owner"])
+}
+
+func TestAppInterpolationOptIn(t *testing.T) {
+	reset := setPwd(t, "testdata/interpolate-app")
+	defer reset()
+	app, err := NewAppWithVars("qbec-literal.yaml", nil, "", map[string]string{"missing": "value"})
+	require.NoError(t, err)
+	props, err := app.Properties("prod")
+	require.NoError(t, err)
+	assert.EqualValues(t, map[string]interface{}{
+		"alertSummary":     "{{ $labels.instance }} is down",
+		"alertDescription": "{{ .missing }}",
+	}, props)
+}
+
+func TestAppInterpolationNegative(t *testing.T) {
+	reset := setPwd(t, "testdata/interpolate-app")
+	defer reset()
+	tests := []struct {
+		name     string
+		vars     map[string]string
+		expected string
+	}{
+		{
+			name:     "bad namespace",
+			vars:     map[string]string{"team": "Search_Team"},
+			expected: `interpolate environment base: defaultNamespace: "Search_Team-base" is not a valid namespace`,
+		},
+		{
+			name:     "bad server",
+			vars:     map[string]string{"region": "/"},
+			expected: `interpolate environment base: server: "https:///.example.com" is not a valid URL`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewAppWithVars("qbec.yaml", nil, "", test.vars)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expected)
+		})
+	}
+}
+
+func TestInterpolateEnvironment(t *testing.T) {
+	vars := map[string]interface{}{"team": "payments"}
+	tests := []struct {
+		name     string
+		env      Environment
+		expected string
+	}{
+		{
+			name:     "missing variable",
+			env:      Environment{Server: "https://{{ .region }}.example.com"},
+			expected: `server: template: :1:11: executing "" at <.region>: map has no entry for key "region"`,
+		},
+		{
+			name:     "bad template",
+			env:      Environment{DefaultNamespace: "{{ .team "},
+			expected: `defaultNamespace: template: :1: unclosed action`,
+		},
+		{
+			name:     "bad property",
+			env:      Environment{Properties: map[string]interface{}{"hosts": []interface{}{"{{ .host }}"}}},
+			expected: `properties.hosts[0]: template: :1:3: executing "" at <.host>: map has no entry for key "host"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.env.interpolate(vars)
+			require.Error(t, err)
+			assert.Equal(t, test.expected, err.Error())
+		})
+	}
+}
+
+func TestAppIncludes(t *testing.T) {
+	reset := setPwd(t, "testdata/include-app")
+	defer reset()
+	app, err := NewApp("qbec.yaml", nil, "")
+	require.NoError(t, err)
+	a := assert.New(t)
+
+	envs := app.Environments()
+	a.Equal(3, len(envs))
+	a.Equal("https://dev-server-override", envs["dev"].Server)
+	a.Equal("payments-dev", envs["dev"].DefaultNamespace)
+	a.Equal("https://stage-server-2", envs["stage"].Server)
+	a.Equal("https://prod-server", envs["prod"].Server)
+
+	a.EqualValues(map[string]interface{}{"team": "payments", "region": "us-east-1"}, app.DeclaredVars())
+	var computed []string
+	for _, c := range app.DeclaredComputedVars() {
+		computed = append(computed, c.Name)
+	}
+	a.Equal([]string{"orgLabels", "appLabels"}, computed)
+	a.Equal([]string{"qbec.yaml", "fragments/a-common.yaml", "fragments/b-prod.yaml"}, app.ConfigFiles())
+
+	comps, err := app.ComponentsForEnvironment("dev", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(comps))
+	a.Equal("index", comps[0].Name)
+}
+
+func TestAppOutsideWorkingDir(t *testing.T) {
+	a := assert.New(t)
+	app, err := NewApp(filepath.Join("testdata", "include-app", "qbec.yaml"), nil, "")
+	require.NoError(t, err)
+	a.Equal(3, len(app.Environments()))
+	a.Equal([]string{"qbec.yaml", "fragments/a-common.yaml", "fragments/b-prod.yaml"}, app.ConfigFiles())
+	comps, err := app.ComponentsForEnvironment("dev", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(comps))
+	a.Equal([]string{filepath.Join("testdata", "include-app", "components", "index.jsonnet")}, comps[0].Files)
+	a.Equal(filepath.Join("testdata", "include-app", "params.libsonnet"), app.ParamsFile())
+	abs, err := filepath.Abs(filepath.Join("testdata", "include-app"))
+	require.NoError(t, err)
+	a.Equal(abs, app.Root())
+
+	app, err = NewApp(filepath.Join("testdata", "inherit-app", "qbec.yaml"), nil, "")
+	require.NoError(t, err)
+	a.Equal([]string{filepath.Join("testdata", "inherit-app", "base.env"), filepath.Join("testdata", "inherit-app", "dev2.env")}, app.VarFiles("dev2"))
+	creds, err := app.Credentials("dev")
+	require.NoError(t, err)
+	a.Equal(filepath.Join(abs, "..", "inherit-app", "certs", "ca.pem"), filepath.Clean(creds.CertificateAuthority))
+
+	app, err = NewApp(filepath.Join("testdata", "jb-app", "qbec.yaml"), nil, "")
+	require.NoError(t, err)
+	a.Equal([]string{filepath.Join("testdata", "jb-app", "lib"), filepath.Join("testdata", "jb-app", "jsonnet-vendor")}, app.LibPaths())
+	a.Equal("jsonnet-vendor", app.VendorDir())
+}
+
+func TestAppJsonnetBundler(t *testing.T) {
+	reset := setPwd(t, "testdata/jb-app")
+	defer reset()
+	app, err := NewApp("qbec.yaml", nil, "")
+	require.NoError(t, err)
+	a := assert.New(t)
+	a.Equal("jsonnet-vendor", app.VendorDir())
+	a.Equal([]string{"lib", "jsonnet-vendor"}, app.LibPaths())
+
+	app.inner.Spec.LibPaths = []string{"jsonnet-vendor/", "lib"}
+	a.Equal([]string{"jsonnet-vendor/", "lib"}, app.LibPaths())
+
+	app.inner.Spec.VendorDir = ""
+	a.Equal("vendor", app.VendorDir())
+	a.Equal([]string{"jsonnet-vendor/", "lib", "vendor"}, app.LibPaths())
+}
+
+func TestAppTagRules(t *testing.T) {
+	reset := setPwd(t, "testdata/inherit-app")
+	defer reset()
+	app, err := NewApp("qbec.yaml", nil, "")
+	require.NoError(t, err)
+	a := assert.New(t)
+	a.NoError(app.AssertAppTagAllowed("dev"))
+	a.NoError(app.AssertAppTagAllowed("prod"))
+
+	app, err = NewApp("qbec.yaml", nil, "pr-12")
+	require.NoError(t, err)
+	a.NoError(app.AssertAppTagAllowed("base"))
+	a.NoError(app.AssertAppTagAllowed("dev2"))
+	err = app.AssertAppTagAllowed("prod")
+	require.Error(t, err)
+	a.Equal(`environment prod does not allow app tag "pr-12"`, err.Error())
+
+	app, err = NewApp("qbec.yaml", nil, "feature")
+	require.NoError(t, err)
+	err = app.AssertAppTagAllowed("dev")
+	require.Error(t, err)
+	a.Equal(`environment dev does not allow app tag "feature"`, err.Error())
+}
+
+func TestAppEnvironmentsForContext(t *testing.T) {
+	reset := setPwd(t, "testdata/inherit-app")
+	defer reset()
+	app, err := NewApp("qbec.yaml", nil, "")
+	require.NoError(t, err)
+	a := assert.New(t)
+	a.Equal([]string{"prod"}, app.EnvironmentsForContext("prod-context", "https://prod-server"))
+	a.Equal([]string{"prod"}, app.EnvironmentsForContext("prod-context", "https://base-server"))
+	a.Equal([]string{"base", "dev"}, app.EnvironmentsForContext("other", "https://base-server"))
+	a.Equal([]string{"dev2"}, app.EnvironmentsForContext("other", "https://dev2-server"))
+	a.Empty(app.EnvironmentsForContext("other", "https://other-server"))
+
+	app.inner.Spec.Environments["stage"] = Environment{Context: "gke_*_stage-?"}
+	a.Equal([]string{"stage"}, app.EnvironmentsForContext("gke_my-project_us-west1_stage-a", "https://stage-server"))
+}
+
+func TestContextMatches(t *testing.T) {
+	tests := []struct {
+		context string
+		name    string
+		match   bool
+	}{
+		{"prod-context", "prod-context", true},
+		{"prod-context", "prod-context-2", false},
+		{"prod-*", "prod-context", true},
+		{"gke_my-project_*_cluster-a", "gke_my-project_us-west1-a_cluster-a", true},
+		{"gke_my-project_*_cluster-a", "gke_my-project_us-west1-a_cluster-b", false},
+		{"arn:aws:eks:*:cluster/prod", "arn:aws:eks:us-west-2:123456789012:cluster/prod", true},
+		{"cluster-?", "cluster-a", true},
+		{"cluster-?", "cluster-ab", false},
+		{"cluster.*", "clusterX1", false},
+	}
+	for _, test := range tests {
+		t.Run(test.context+"/"+test.name, func(t *testing.T) {
+			assert.Equal(t, test.match, ContextMatches(test.context, test.name))
+		})
+	}
 }
@@ -0,0 +1,216 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package model
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/sio"
+)
+
+const defaultEnvProviderTimeout = 30 * time.Second
+
+// envProvider returns the contents of an environment map produced at runtime.
+type envProvider interface {
+	fetch(ctx context.Context) ([]byte, error)
+}
+
+// execEnvProvider runs a command from the qbec root and returns its standard output.
+type execEnvProvider struct {
+	app  string
+	dir  string
+	exec ExecHook
+}
+
+func (e *execEnvProvider) fetch(ctx context.Context) ([]byte, error) {
+	c := exec.CommandContext(ctx, e.exec.Command, e.exec.Args...)
+	c.Dir = e.dir
+	env := append(os.Environ(), "QBEC_APP="+e.app)
+	var keys []string
+	for k := range e.exec.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+e.exec.Env[k])
+	}
+	c.Env = env
+	var stdout, stderr bytes.Buffer
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		return nil, fmt.Errorf("run %s: %v\n%s", e.exec.Command, err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
+
+// httpEnvProvider fetches the environment map from a URL.
+type httpEnvProvider struct {
+	url   string
+	token string
+}
+
+func (h *httpEnvProvider) fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status : %s", res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// newEnvProvider returns the provider implementation for the supplied configuration.
+func newEnvProvider(app, dir string, p EnvProvider) (envProvider, error) {
+	if (p.Exec == nil) == (p.URL == "") {
+		return nil, fmt.Errorf("exactly one of exec or url must be specified")
+	}
+	if p.Exec != nil {
+		return &execEnvProvider{app: app, dir: dir, exec: *p.Exec}, nil
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse url")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("url %s must use the http or https scheme", p.URL)
+	}
+	var token string
+	if p.TokenEnv != "" {
+		token = os.Getenv(p.TokenEnv)
+		if token == "" {
+			return nil, fmt.Errorf("environment variable %s not set", p.TokenEnv)
+		}
+	}
+	return &httpEnvProvider{url: p.URL, token: token}, nil
+}
+
+// envProviderCacheDir returns the directory under which the output of environment providers is cached.
+func envProviderCacheDir() (string, error) {
+	if dir := os.Getenv("QBEC_CACHE_DIR"); dir != "" {
+		return filepath.Join(dir, "env-providers"), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.Wrap(err, "get user cache dir")
+	}
+	return filepath.Join(dir, "qbec", "env-providers"), nil
+}
+
+// envProviderCacheFile returns the cache file for the provider of the app at the supplied root, such that
+// a change to the provider configuration does not reuse previously cached output.
+func envProviderCacheFile(dir string, p EnvProvider) (string, error) {
+	cacheDir, err := envProviderCacheDir()
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(dir+"\n"), b...))
+	return filepath.Join(cacheDir, fmt.Sprintf("%s-%s.yaml", p.Name, hex.EncodeToString(sum[:8]))), nil
+}
+
+func parseProviderDuration(name, value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s': %v", name, value, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s '%s': must be positive", name, value)
+	}
+	return d, nil
+}
+
+// runEnvProvider passes the environment map produced by the supplied provider to the load function, using
+// cached output when it is fresher than the cache TTL of the provider. Newly produced output is only cached
+// when it is loaded successfully.
+func runEnvProvider(app, dir string, p EnvProvider, load func(b []byte) error) error {
+	timeout, err := parseProviderDuration("timeout", p.Timeout, defaultEnvProviderTimeout)
+	if err != nil {
+		return err
+	}
+	ttl, err := parseProviderDuration("cacheTTL", p.CacheTTL, 0)
+	if err != nil {
+		return err
+	}
+	provider, err := newEnvProvider(app, dir, p)
+	if err != nil {
+		return err
+	}
+	var cacheFile string
+	if ttl > 0 {
+		cacheFile, err = envProviderCacheFile(dir, p)
+		if err != nil {
+			return err
+		}
+		if st, err := os.Stat(cacheFile); err == nil && time.Since(st.ModTime()) < ttl {
+			sio.Debugf("use cached environments for provider %s from %s\n", p.Name, cacheFile)
+			b, err := ioutil.ReadFile(cacheFile)
+			if err != nil {
+				return err
+			}
+			return load(b)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	b, err := provider.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	if err := load(b); err != nil {
+		return err
+	}
+	if cacheFile != "" {
+		// environments may be fetched using credentials, so only the current user is allowed to read them
+		if err := os.MkdirAll(filepath.Dir(cacheFile), 0700); err != nil {
+			return errors.Wrap(err, "create cache dir")
+		}
+		if err := ioutil.WriteFile(cacheFile, b, 0600); err != nil {
+			return errors.Wrap(err, "write cache file")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const providerEnvs = `
+apiVersion: qbec.io/v1alpha1
+kind: EnvironmentMap
+spec:
+  environments:
+    cluster1:
+      server: https://cluster1
+    cluster2:
+      server: https://cluster2
+`
+
+// writeProviderApp writes an app with the supplied providers into a temp directory, changes to it, and
+// returns the path to its qbec.yaml file.
+func writeProviderApp(t *testing.T, providers string) string {
+	dir := t.TempDir()
+	t.Cleanup(setPwd(t, dir))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "components"), 0755))
+	app := `
+apiVersion: qbec.io/v1alpha1
+kind: App
+metadata:
+  name: fleet-app
+spec:
+  environments:
+    dev:
+      server: https://dev-server
+    cluster1:
+      server: https://old-cluster1
+  envProviders:
+` + providers
+	file := filepath.Join(dir, "qbec.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte(app), 0644))
+	return file
+}
+
+func writeProviderScript(t *testing.T, dir, contents string) {
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "envs.sh"), []byte("#!/bin/sh\n"+contents), 0755))
+}
+
+func TestEnvProviderExec(t *testing.T) {
+	t.Setenv("QBEC_CACHE_DIR", t.TempDir())
+	file := writeProviderApp(t, `
+    - name: fleet
+      exec:
+        command: ./envs.sh
+        args: [ cluster2 ]
+        env:
+          SERVER_PREFIX: https://
+`)
+	writeProviderScript(t, filepath.Dir(file), `cat <<EOF
+kind: EnvironmentMap
+apiVersion: qbec.io/v1alpha1
+spec:
+  environments:
+    cluster1:
+      server: ${SERVER_PREFIX}cluster1
+      properties:
+        app: ${QBEC_APP}
+    $1:
+      server: ${SERVER_PREFIX}$1
+EOF
+`)
+	app, err := NewApp(file, nil, "")
+	require.NoError(t, err)
+	envs := app.Environments()
+	a := assert.New(t)
+	a.Equal(3, len(envs))
+	a.Equal("https://cluster1", envs["cluster1"].Server)
+	a.Equal("https://cluster2", envs["cluster2"].Server)
+	a.Equal(map[string]interface{}{"app": "fleet-app"}, envs["cluster1"].Properties)
+}
+
+func TestEnvProviderHTTPCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv("QBEC_CACHE_DIR", cacheDir)
+	t.Setenv("FLEET_TOKEN", "s3cr3t")
+	var calls int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("Authorization") != "Bearer s3cr3t" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(providerEnvs))
+	}))
+	defer s.Close()
+	file := writeProviderApp(t, fmt.Sprintf(`
+    - name: fleet
+      url: %s
+      tokenEnv: FLEET_TOKEN
+      cacheTTL: 1h
+`, s.URL))
+	for i := 0; i < 2; i++ {
+		app, err := NewApp(file, nil, "")
+		require.NoError(t, err)
+		assert.Equal(t, 3, len(app.Environments()))
+	}
+	assert.Equal(t, 1, calls)
+	files, err := filepath.Glob(filepath.Join(cacheDir, "env-providers", "*"))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(files))
+	st, err := os.Stat(files[0])
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), st.Mode().Perm())
+	st, err = os.Stat(filepath.Dir(files[0]))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), st.Mode().Perm())
+}
+
+func TestEnvProviderNoCacheOnFailure(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv("QBEC_CACHE_DIR", cacheDir)
+	file := writeProviderApp(t, `
+    - name: fleet
+      exec:
+        command: ./envs.sh
+      cacheTTL: 1h
+`)
+	writeProviderScript(t, filepath.Dir(file), "echo '{ \"spec\": { \"environments\": { \"foo\": { \"server\": 10 } } } }'\n")
+	_, err := NewApp(file, nil, "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "environment provider fleet: file: provider fleet, ")
+	_, err = os.Stat(filepath.Join(cacheDir, "env-providers"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestEnvProviderNegative(t *testing.T) {
+	t.Setenv("QBEC_CACHE_DIR", t.TempDir())
+	tests := []struct {
+		name      string
+		providers string
+		script    string
+		errMsg    string
+	}{
+		{
+			name: "both",
+			providers: `
+    - name: fleet
+      url: https://example.com/envs
+      exec:
+        command: ./envs.sh
+`,
+			errMsg: "environment provider fleet: exactly one of exec or url must be specified",
+		},
+		{
+			name: "bad-scheme",
+			providers: `
+    - name: fleet
+      url: file:///tmp/envs.yaml
+`,
+			errMsg: "environment provider fleet: url file:///tmp/envs.yaml must use the http or https scheme",
+		},
+		{
+			name: "no-token",
+			providers: `
+    - name: fleet
+      url: https://example.com/envs
+      tokenEnv: QBEC_TEST_NO_SUCH_VAR
+`,
+			errMsg: "environment provider fleet: environment variable QBEC_TEST_NO_SUCH_VAR not set",
+		},
+		{
+			name: "bad-timeout",
+			providers: `
+    - name: fleet
+      url: https://example.com/envs
+      timeout: '10'
+`,
+			errMsg: "environment provider fleet: invalid timeout '10'",
+		},
+		{
+			name: "duplicate",
+			providers: `
+    - name: fleet
+      exec:
+        command: ./envs.sh
+    - name: fleet
+      exec:
+        command: ./envs.sh
+`,
+			errMsg: "duplicate environment provider fleet",
+		},
+		{
+			name: "command-fails",
+			providers: `
+    - name: fleet
+      exec:
+        command: ./envs.sh
+`,
+			script: "echo 'no clusters' >&2; exit 3\n",
+			errMsg: "environment provider fleet: run ./envs.sh: exit status 3\nno clusters",
+		},
+		{
+			name: "timeout",
+			providers: `
+    - name: fleet
+      timeout: 100ms
+      exec:
+        command: ./envs.sh
+`,
+			script: "exec sleep 5\n",
+			errMsg: "environment provider fleet: run ./envs.sh: signal: killed",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file := writeProviderApp(t, test.providers)
+			if test.script != "" {
+				writeProviderScript(t, filepath.Dir(file), test.script)
+			}
+			_, err := NewApp(file, nil, "")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.errMsg)
+		})
+	}
+}
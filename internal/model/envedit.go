@@ -0,0 +1,209 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/vm/vmutil"
+)
+
+// EnvironmentEdit describes changes to an environment definition. Nil fields are left unchanged and
+// fields set to an empty string are removed.
+type EnvironmentEdit struct {
+	Server           *string
+	Context          *string
+	DefaultNamespace *string
+	Inherits         *string
+	Properties       map[string]string // top-level properties to set, an empty value removes the property
+}
+
+// envDocument is a parsed qbec.yaml or environment file that retains comments and key order.
+type envDocument struct {
+	doc  *vmutil.YAMLDocument
+	kind string
+}
+
+func parseEnvDocument(content []byte) (*envDocument, error) {
+	doc, err := vmutil.ParseYAMLDocument(content)
+	if err != nil {
+		return nil, err
+	}
+	root, _, err := doc.Get()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := root.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("document is not an object")
+	}
+	d := &envDocument{doc: doc}
+	if k, _, _ := doc.Get("kind"); k != nil {
+		d.kind = fmt.Sprint(k)
+	}
+	if d.kind != "App" && d.kind != "EnvironmentMap" {
+		return nil, fmt.Errorf("bad kind property %q, expected App or EnvironmentMap", d.kind)
+	}
+	return d, nil
+}
+
+// envPath returns the path to the environment with the supplied name, optionally followed by attributes.
+func envPath(name string, attrs ...string) []string {
+	return append([]string{"spec", "environments", name}, attrs...)
+}
+
+// environment returns the definition of the supplied environment and whether it exists.
+func (d *envDocument) environment(name string) (interface{}, bool, error) {
+	return d.doc.Get(envPath(name)...)
+}
+
+// bytes validates the document against the schema and returns its serialized form.
+func (d *envDocument) bytes() ([]byte, error) {
+	b, err := d.doc.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	v, err := newValidator()
+	if err != nil {
+		return nil, errors.Wrap(err, "create schema validator")
+	}
+	var errs []error
+	if d.kind == "App" {
+		errs = v.validateYAML(b)
+	} else {
+		errs = v.validateEnvYAML(b)
+	}
+	if len(errs) > 0 {
+		return nil, makeValError("updated document", errs)
+	}
+	return b, nil
+}
+
+func (d *envDocument) applyEdit(name string, edit EnvironmentEdit) error {
+	setString := func(key string, value *string) error {
+		if value == nil {
+			return nil
+		}
+		if *value == "" {
+			d.doc.Delete(envPath(name, key)...)
+			return nil
+		}
+		return d.doc.Set(*value, envPath(name, key)...)
+	}
+	// server and context are mutually exclusive, setting one removes the other
+	if edit.Server != nil && *edit.Server != "" {
+		d.doc.Delete(envPath(name, "context")...)
+	}
+	if edit.Context != nil && *edit.Context != "" {
+		d.doc.Delete(envPath(name, "server")...)
+	}
+	for _, attr := range []struct {
+		key   string
+		value *string
+	}{
+		{"server", edit.Server},
+		{"context", edit.Context},
+		{"defaultNamespace", edit.DefaultNamespace},
+		{"inherits", edit.Inherits},
+	} {
+		if err := setString(attr.key, attr.value); err != nil {
+			return err
+		}
+	}
+	if len(edit.Properties) > 0 {
+		var keys []string
+		for k := range edit.Properties {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := edit.Properties[k]
+			if v == "" {
+				d.doc.Delete(envPath(name, "properties", k)...)
+				continue
+			}
+			if err := d.doc.Set(v, envPath(name, "properties", k)...); err != nil {
+				return err
+			}
+		}
+		if len(d.doc.Keys(envPath(name, "properties")...)) == 0 {
+			d.doc.Delete(envPath(name, "properties")...)
+		}
+	}
+	return nil
+}
+
+// AddEnvironment returns the supplied qbec.yaml or environment file contents with a new environment added.
+// Comments and the order of existing keys are retained.
+func AddEnvironment(content []byte, name string, edit EnvironmentEdit) ([]byte, error) {
+	if name == Baseline {
+		return nil, fmt.Errorf("cannot use _ as an environment name since it has a special meaning")
+	}
+	if !reLabelValue.MatchString(name) {
+		return nil, fmt.Errorf("invalid environment %s, must match %s", name, reLabelValue)
+	}
+	d, err := parseEnvDocument(content)
+	if err != nil {
+		return nil, err
+	}
+	if _, found, _ := d.environment(name); found {
+		return nil, fmt.Errorf("environment %s already exists", name)
+	}
+	if err := d.doc.Set(map[string]interface{}{}, envPath(name)...); err != nil {
+		return nil, err
+	}
+	if err := d.applyEdit(name, edit); err != nil {
+		return nil, err
+	}
+	return d.bytes()
+}
+
+// UpdateEnvironment returns the supplied qbec.yaml or environment file contents with changes made to an
+// existing environment. Comments and the order of existing keys are retained.
+func UpdateEnvironment(content []byte, name string, edit EnvironmentEdit) ([]byte, error) {
+	d, err := parseEnvDocument(content)
+	if err != nil {
+		return nil, err
+	}
+	env, found, err := d.environment(name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("environment %s not found", name)
+	}
+	if _, ok := env.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("environment %s is not an object", name)
+	}
+	if err := d.applyEdit(name, edit); err != nil {
+		return nil, err
+	}
+	return d.bytes()
+}
+
+// RemoveEnvironment returns the supplied qbec.yaml or environment file contents with an environment removed.
+func RemoveEnvironment(content []byte, name string) ([]byte, error) {
+	d, err := parseEnvDocument(content)
+	if err != nil {
+		return nil, err
+	}
+	if !d.doc.Delete(envPath(name)...) {
+		return nil, fmt.Errorf("environment %s not found", name)
+	}
+	return d.bytes()
+}
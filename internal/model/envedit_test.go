@@ -0,0 +1,169 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const editApp = `apiVersion: qbec.io/v1alpha1
+kind: App
+metadata:
+  name: edit-app
+spec:
+  # the environments for the app
+  environments:
+    dev:
+      server: https://dev-server # dev cluster
+      properties:
+        replicas: 1
+`
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestAddEnvironment(t *testing.T) {
+	out, err := AddEnvironment([]byte(editApp), "prod", EnvironmentEdit{
+		Server:           strPtr("https://prod-server"),
+		DefaultNamespace: strPtr("prod-ns"),
+		Properties:       map[string]string{"tier": "gold"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `apiVersion: qbec.io/v1alpha1
+kind: App
+metadata:
+  name: edit-app
+spec:
+  # the environments for the app
+  environments:
+    dev:
+      server: https://dev-server # dev cluster
+      properties:
+        replicas: 1
+    prod:
+      server: https://prod-server
+      defaultNamespace: prod-ns
+      properties:
+        tier: gold
+`, string(out))
+}
+
+func TestAddEnvironmentToEnvFile(t *testing.T) {
+	out, err := AddEnvironment([]byte("apiVersion: qbec.io/v1alpha1\nkind: EnvironmentMap\nspec:\n  environments: {}\n"), "prod", EnvironmentEdit{
+		Context: strPtr("prod-context"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "apiVersion: qbec.io/v1alpha1\nkind: EnvironmentMap\nspec:\n  environments: {prod: {context: prod-context}}\n", string(out))
+}
+
+func TestUpdateEnvironment(t *testing.T) {
+	out, err := UpdateEnvironment([]byte(editApp), "dev", EnvironmentEdit{
+		Context:    strPtr("minikube"),
+		Inherits:   strPtr(""),
+		Properties: map[string]string{"replicas": "", "tier": "silver"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `apiVersion: qbec.io/v1alpha1
+kind: App
+metadata:
+  name: edit-app
+spec:
+  # the environments for the app
+  environments:
+    dev:
+      properties:
+        tier: silver
+      context: minikube
+`, string(out))
+}
+
+func TestRemoveEnvironment(t *testing.T) {
+	added, err := AddEnvironment([]byte(editApp), "prod", EnvironmentEdit{Server: strPtr("https://prod-server")})
+	require.NoError(t, err)
+	out, err := RemoveEnvironment(added, "prod")
+	require.NoError(t, err)
+	assert.Equal(t, editApp, string(out))
+}
+
+func TestEditEnvironmentNegative(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func() ([]byte, error)
+		err  string
+	}{
+		{
+			name: "add existing",
+			fn: func() ([]byte, error) {
+				return AddEnvironment([]byte(editApp), "dev", EnvironmentEdit{Server: strPtr("https://dev-server")})
+			},
+			err: "environment dev already exists",
+		},
+		{
+			name: "add baseline",
+			fn: func() ([]byte, error) {
+				return AddEnvironment([]byte(editApp), "_", EnvironmentEdit{Server: strPtr("https://dev-server")})
+			},
+			err: "cannot use _ as an environment name since it has a special meaning",
+		},
+		{
+			name: "add bad name",
+			fn: func() ([]byte, error) {
+				return AddEnvironment([]byte(editApp), "foo/bar", EnvironmentEdit{Server: strPtr("https://dev-server")})
+			},
+			err: "invalid environment foo/bar, must match",
+		},
+		{
+			name: "update missing",
+			fn: func() ([]byte, error) {
+				return UpdateEnvironment([]byte(editApp), "prod", EnvironmentEdit{Server: strPtr("https://prod-server")})
+			},
+			err: "environment prod not found",
+		},
+		{
+			name: "remove missing",
+			fn: func() ([]byte, error) {
+				return RemoveEnvironment([]byte(editApp), "prod")
+			},
+			err: "environment prod not found",
+		},
+		{
+			name: "remove last",
+			fn: func() ([]byte, error) {
+				return RemoveEnvironment([]byte(editApp), "dev")
+			},
+			err: "schema validation error(s)",
+		},
+		{
+			name: "bad kind",
+			fn: func() ([]byte, error) {
+				return RemoveEnvironment([]byte("kind: Foo\n"), "dev")
+			},
+			err: `bad kind property "Foo", expected App or EnvironmentMap`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.fn()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+}
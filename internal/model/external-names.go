@@ -24,10 +24,22 @@ const QBECDirectivesNamespace = "directives.qbec.io/"
 
 // Directives is the list of directive names we support.
 type Directives struct {
-	ApplyOrder   string // numeric apply order for object
-	DeletePolicy string // delete policy "default" | "never"
-	UpdatePolicy string // update policy "default" | "never"
-	WaitPolicy   string // wait policy "default" | "never"
+	ApplyOrder         string // numeric apply order for object
+	DeletePolicy       string // delete policy "default" | "never"
+	UpdatePolicy       string // update policy "default" | "never"
+	WaitPolicy         string // wait policy "default" | "never"
+	WaitStatus         string // status expression that determines when the object is ready
+	GenerateNamePolicy string // generate name policy "default" | "track"
+	IgnoreUpdates      string // ignore updates "true" | "false", same as the "never" update policy
+	IgnoreDelete       string // ignore deletes "true" | "false", same as the "never" delete policy
+}
+
+// SourceAnnotationNames is the list of annotations used to stamp objects with source metadata.
+type SourceAnnotationNames struct {
+	Commit       string // the git commit SHA of the source
+	Dirty        string // whether the source had uncommitted changes
+	QbecVersion  string // the qbec version used to render the object
+	RenderedTime string // the timestamp as determined by the timestamp policy
 }
 
 // QbecNames is the set of names used by Qbec.
@@ -38,6 +50,9 @@ var QbecNames = struct {
 	ComponentLabel      string // the label to use for tagging an object with a component
 	EnvironmentLabel    string // the label to use for tagging an object with an annotation
 	PristineAnnotation  string // the annotation to use for storing the pristine object
+	ContentHash         string // the annotation to use for storing the hash of the applied object
+	IdentityLabel       string // the label to use for tracking objects with generated names across applies
+	SourceAnnotations   SourceAnnotationNames
 	EnvVarName          string // the name of the external variable that has the environment name
 	EnvPropsVarName     string // the name of the external variable that has the environment properties object
 	TagVarName          string // the name of the external variable that has the tag name
@@ -51,15 +66,27 @@ var QbecNames = struct {
 	ComponentLabel:      QBECMetadataPrefix + "component",
 	EnvironmentLabel:    QBECMetadataPrefix + "environment",
 	PristineAnnotation:  QBECMetadataPrefix + "last-applied",
-	EnvVarName:          QBECMetadataPrefix + "env",
-	EnvPropsVarName:     QBECMetadataPrefix + "envProperties",
-	TagVarName:          QBECMetadataPrefix + "tag",
-	DefaultNsVarName:    QBECMetadataPrefix + "defaultNs",
-	CleanModeVarName:    QBECMetadataPrefix + "cleanMode",
+	ContentHash:         QBECMetadataPrefix + "content-hash",
+	IdentityLabel:       QBECMetadataPrefix + "identity",
+	SourceAnnotations: SourceAnnotationNames{
+		Commit:       QBECMetadataPrefix + "source-commit",
+		Dirty:        QBECMetadataPrefix + "source-dirty",
+		QbecVersion:  QBECMetadataPrefix + "qbec-version",
+		RenderedTime: QBECMetadataPrefix + "rendered-at",
+	},
+	EnvVarName:       QBECMetadataPrefix + "env",
+	EnvPropsVarName:  QBECMetadataPrefix + "envProperties",
+	TagVarName:       QBECMetadataPrefix + "tag",
+	DefaultNsVarName: QBECMetadataPrefix + "defaultNs",
+	CleanModeVarName: QBECMetadataPrefix + "cleanMode",
 	Directives: Directives{
-		ApplyOrder:   QBECDirectivesNamespace + "apply-order",
-		DeletePolicy: QBECDirectivesNamespace + "delete-policy",
-		UpdatePolicy: QBECDirectivesNamespace + "update-policy",
-		WaitPolicy:   QBECDirectivesNamespace + "wait-policy",
+		ApplyOrder:         QBECDirectivesNamespace + "apply-order",
+		DeletePolicy:       QBECDirectivesNamespace + "delete-policy",
+		UpdatePolicy:       QBECDirectivesNamespace + "update-policy",
+		WaitPolicy:         QBECDirectivesNamespace + "wait-policy",
+		WaitStatus:         QBECDirectivesNamespace + "wait-status",
+		GenerateNamePolicy: QBECDirectivesNamespace + "generate-name-policy",
+		IgnoreUpdates:      QBECDirectivesNamespace + "ignore-updates",
+		IgnoreDelete:       QBECDirectivesNamespace + "ignore-delete",
 	},
 }
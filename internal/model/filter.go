@@ -76,9 +76,23 @@ func newBaseFilter(pluralKind string, includes, excludes []string, fn aliasFn) (
 	}, nil
 }
 
-// NewComponentFilter returns a filter for component names.
+// NewComponentFilter returns a filter for component names. Includes and excludes ending with a slash
+// match all components in the namespace with that prefix.
 func NewComponentFilter(includes, excludes []string) (Filter, error) {
-	return newStringFilter("components", includes, excludes)
+	aliases := func(s string) []string {
+		ret := []string{s}
+		for i := len(s) - 1; i >= 0; i-- {
+			if s[i] == '/' {
+				ret = append(ret, s[:i+1])
+			}
+		}
+		return ret
+	}
+	cf, err := newBaseFilter("components", includes, excludes, aliases)
+	if err != nil {
+		return nil, err
+	}
+	return cf, nil
 }
 
 // newStringFilter returns a filter for exact string matches.
@@ -90,9 +104,9 @@ func newStringFilter(kind string, includes, excludes []string) (Filter, error) {
 	return nf, nil
 }
 
-// newKindFilter returns a filter for object kinds that ignores case and takes
+// NewKindFilter returns a filter for object kinds that ignores case and takes
 // pluralization into account.
-func newKindFilter(includes, excludes []string) (Filter, error) {
+func NewKindFilter(includes, excludes []string) (Filter, error) {
 	aliases := func(s string) []string {
 		n := namer.NewAllLowercasePluralNamer(nil)
 		kind := strings.ToLower(s)
@@ -43,6 +43,22 @@ func TestComponentFilterExcludes(t *testing.T) {
 	a.True(filter.ShouldInclude("baz"))
 }
 
+func TestComponentFilterNamespaces(t *testing.T) {
+	filter, err := NewComponentFilter([]string{"team-a/", "team-b/svc1"}, nil)
+	require.Nil(t, err)
+	a := assert.New(t)
+	a.True(filter.ShouldInclude("team-a/svc1"))
+	a.True(filter.ShouldInclude("team-a/sub/svc1"))
+	a.True(filter.ShouldInclude("team-b/svc1"))
+	a.False(filter.ShouldInclude("team-b/svc2"))
+	a.False(filter.ShouldInclude("team-a"))
+
+	filter, err = NewComponentFilter(nil, []string{"team-a/"})
+	require.Nil(t, err)
+	a.False(filter.ShouldInclude("team-a/svc1"))
+	a.True(filter.ShouldInclude("team-b/svc1"))
+}
+
 func TestComponentFilterOpen(t *testing.T) {
 	filter, err := NewComponentFilter(nil, nil)
 	require.Nil(t, err)
@@ -60,7 +76,7 @@ func TestComponentFilterBad(t *testing.T) {
 }
 
 func TestKindFilterIncludes(t *testing.T) {
-	filter, err := newKindFilter([]string{"foo", "icy"}, []string{})
+	filter, err := NewKindFilter([]string{"foo", "icy"}, []string{})
 	require.Nil(t, err)
 	a := assert.New(t)
 	a.True(filter.HasFilters())
@@ -71,7 +87,7 @@ func TestKindFilterIncludes(t *testing.T) {
 }
 
 func TestKindFilterIncludesPlural(t *testing.T) {
-	filter, err := newKindFilter([]string{"foos", "icies", "classes"}, []string{})
+	filter, err := NewKindFilter([]string{"foos", "icies", "classes"}, []string{})
 	require.Nil(t, err)
 	a := assert.New(t)
 	a.True(filter.HasFilters())
@@ -84,7 +100,7 @@ func TestKindFilterIncludesPlural(t *testing.T) {
 }
 
 func TestKindFilterExcludes(t *testing.T) {
-	filter, err := newKindFilter(nil, []string{"foo", "bar"})
+	filter, err := NewKindFilter(nil, []string{"foo", "bar"})
 	require.Nil(t, err)
 	a := assert.New(t)
 	a.True(filter.HasFilters())
@@ -94,7 +110,7 @@ func TestKindFilterExcludes(t *testing.T) {
 }
 
 func TestKindFilterExcludesPlural(t *testing.T) {
-	filter, err := newKindFilter(nil, []string{"foos", "bars"})
+	filter, err := NewKindFilter(nil, []string{"foos", "bars"})
 	require.Nil(t, err)
 	a := assert.New(t)
 	a.True(filter.HasFilters())
@@ -106,7 +122,7 @@ func TestKindFilterExcludesPlural(t *testing.T) {
 }
 
 func TestKindFilterOpen(t *testing.T) {
-	filter, err := newKindFilter(nil, nil)
+	filter, err := NewKindFilter(nil, nil)
 	require.Nil(t, err)
 	a := assert.New(t)
 	a.False(filter.HasFilters())
@@ -116,7 +132,7 @@ func TestKindFilterOpen(t *testing.T) {
 }
 
 func TestKindFilterBad(t *testing.T) {
-	_, err := newKindFilter([]string{"foo", "bar"}, []string{"baz"})
+	_, err := NewKindFilter([]string{"foo", "bar"}, []string{"baz"})
 	require.NotNil(t, err)
 	require.Equal(t, "cannot include as well as exclude kinds, specify one or the other", err.Error())
 }
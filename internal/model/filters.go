@@ -54,7 +54,7 @@ func NewFilters(flags *pflag.FlagSet, includeAllFilters bool) func() (Filters, e
 		flags.BoolVar(&includeClusterScopedObjects, "include-cluster-objects", true, "include cluster scoped objects, false by default when namespace filters present")
 	}
 	return func() (Filters, error) {
-		of, err := newKindFilter(kindIncludes, kindExcludes)
+		of, err := NewKindFilter(kindIncludes, kindExcludes)
 		if err != nil {
 			return Filters{}, err
 		}
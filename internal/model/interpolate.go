@@ -0,0 +1,156 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// interpolationVars returns the values of variables that may be referenced by templates in environment fields.
+// These are the defaults of declared external variables, overridden by the supplied values.
+func interpolationVars(spec AppSpec, supplied map[string]string) map[string]interface{} {
+	ret := map[string]interface{}{}
+	for _, v := range spec.Vars.External {
+		if v.Default != nil {
+			ret[v.Name] = v.Default
+		}
+	}
+	for k, v := range supplied {
+		ret[k] = v
+	}
+	return ret
+}
+
+// interpolate expands the supplied string as a template using the supplied variables. Strings that do not
+// contain template actions are returned as-is.
+func interpolate(s string, vars map[string]interface{}) (string, bool, error) {
+	if !strings.Contains(s, "{{") {
+		return s, false, nil
+	}
+	t, err := template.New("").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", false, err
+	}
+	var sb strings.Builder
+	if err := t.Execute(&sb, vars); err != nil {
+		return "", false, err
+	}
+	return sb.String(), true, nil
+}
+
+// interpolateValue expands all strings found in the supplied value, which is a property of an environment.
+func interpolateValue(path string, v interface{}, vars map[string]interface{}) (interface{}, error) {
+	switch value := v.(type) {
+	case string:
+		out, _, err := interpolate(value, vars)
+		if err != nil {
+			return nil, errors.Wrap(err, path)
+		}
+		return out, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		ret := make(map[string]interface{}, len(value))
+		for _, k := range keys {
+			out, err := interpolateValue(path+"."+k, value[k], vars)
+			if err != nil {
+				return nil, err
+			}
+			ret[k] = out
+		}
+		return ret, nil
+	case []interface{}:
+		ret := make([]interface{}, 0, len(value))
+		for i, item := range value {
+			out, err := interpolateValue(fmt.Sprintf("%s[%d]", path, i), item, vars)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, out)
+		}
+		return ret, nil
+	default:
+		return v, nil
+	}
+}
+
+// interpolate expands templates in the server, default namespace and properties of the environment using the
+// supplied variables, and validates the server and default namespace that are produced by templates.
+func (e *Environment) interpolate(vars map[string]interface{}) error {
+	server, changed, err := interpolate(e.Server, vars)
+	if err != nil {
+		return errors.Wrap(err, "server")
+	}
+	if changed {
+		if u, err := url.Parse(server); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("server: %q is not a valid URL", server)
+		}
+		e.Server = server
+	}
+	ns, changed, err := interpolate(e.DefaultNamespace, vars)
+	if err != nil {
+		return errors.Wrap(err, "defaultNamespace")
+	}
+	if changed {
+		if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+			return fmt.Errorf("defaultNamespace: %q is not a valid namespace, %s", ns, strings.Join(errs, ", "))
+		}
+		e.DefaultNamespace = ns
+	}
+	if e.Properties != nil {
+		props, err := interpolateValue("properties", e.Properties, vars)
+		if err != nil {
+			return err
+		}
+		e.Properties = props.(map[string]interface{})
+	}
+	return nil
+}
+
+// interpolateEnvironments expands templates in the fields of all environments of the supplied app. It is a no-op
+// unless the app opts in to interpolation, such that properties holding templates for other tools (e.g. alerting
+// rules) are used as-is.
+func interpolateEnvironments(qApp *QbecApp, supplied map[string]string) error {
+	if !qApp.Spec.Interpolate {
+		return nil
+	}
+	vars := interpolationVars(qApp.Spec, supplied)
+	var names []string
+	for name := range qApp.Spec.Environments {
+		names = append(names, name)
+	}
+	// process environments in a stable order such that the same error is always reported
+	sort.Strings(names)
+	for _, name := range names {
+		env := qApp.Spec.Environments[name]
+		if err := env.interpolate(vars); err != nil {
+			return errors.Wrapf(err, "interpolate environment %s", name)
+		}
+		qApp.Spec.Environments[name] = env
+	}
+	return nil
+}
@@ -17,6 +17,7 @@
 package model
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -133,6 +134,31 @@ func NewK8sObject(data map[string]interface{}) K8sObject {
 	return &ko{Unstructured: toUnstructured(data)}
 }
 
+// GenerateNamePolicyTrack is the value of the generate name policy directive that causes objects with generated
+// names to be updated across applies instead of being created every time.
+const GenerateNamePolicyTrack = "track"
+
+// generatedNameIdentity returns a stable identity for an object with a generated name in the supplied component.
+// The identity is used as a label value and is therefore limited to 63 characters.
+func generatedNameIdentity(obj *unstructured.Unstructured, component string) string {
+	gk := obj.GroupVersionKind().GroupKind()
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%s:%s:%s", gk.Group, gk.Kind, obj.GetGenerateName(), component)))
+	return fmt.Sprintf("%x", sum)[:40]
+}
+
+// TrackedIdentity returns the identity label value for a local object that has a generated name and is
+// tracked across applies. It returns a blank string for all other objects.
+func TrackedIdentity(obj K8sMeta) string {
+	if obj.GetName() != "" {
+		return ""
+	}
+	o, ok := obj.(K8sObject)
+	if !ok {
+		return ""
+	}
+	return o.ToUnstructured().GetLabels()[QbecNames.IdentityLabel]
+}
+
 // LocalAttrs are the attributes used to create local k8s objects.
 type LocalAttrs struct {
 	App               string
@@ -140,9 +166,18 @@ type LocalAttrs struct {
 	Component         string
 	Env               string
 	SetComponentLabel bool
+	CommonLabels      map[string]string // labels added to the object unless it already defines them
+	CommonAnnotations map[string]string // annotations added to the object unless it already defines them
+	SourceAnnotations map[string]string // source metadata annotations that are always set on the object
 }
 
 // NewK8sLocalObject wraps a K8sLocalObject implementation around the unstructured object data specified as a bag
+// componentLabelValue returns the value of the component label for the supplied component. Label values cannot
+// have slashes, so the slashes of namespaced components are replaced with dots.
+func componentLabelValue(component string) string {
+	return strings.ReplaceAll(component, "/", ".")
+}
+
 // of attributes for the supplied application, component and environment.
 func NewK8sLocalObject(data map[string]interface{}, attrs LocalAttrs) K8sLocalObject {
 	base := toUnstructured(data)
@@ -151,13 +186,22 @@ func NewK8sLocalObject(data map[string]interface{}, attrs LocalAttrs) K8sLocalOb
 	if labels == nil {
 		labels = map[string]string{}
 	}
+	for k, v := range attrs.CommonLabels {
+		if _, ok := labels[k]; !ok {
+			labels[k] = v
+		}
+	}
 	labels[QbecNames.ApplicationLabel] = attrs.App
 	if attrs.Tag != "" {
 		labels[QbecNames.TagLabel] = attrs.Tag
 	}
 	labels[QbecNames.EnvironmentLabel] = attrs.Env
 	if attrs.SetComponentLabel {
-		labels[QbecNames.ComponentLabel] = attrs.Component
+		labels[QbecNames.ComponentLabel] = componentLabelValue(attrs.Component)
+	}
+	if base.GetName() == "" && base.GetGenerateName() != "" &&
+		base.GetAnnotations()[QbecNames.Directives.GenerateNamePolicy] == GenerateNamePolicyTrack {
+		labels[QbecNames.IdentityLabel] = generatedNameIdentity(base, attrs.Component)
 	}
 	base.SetLabels(labels)
 
@@ -165,6 +209,14 @@ func NewK8sLocalObject(data map[string]interface{}, attrs LocalAttrs) K8sLocalOb
 	if anns == nil {
 		anns = map[string]string{}
 	}
+	for k, v := range attrs.CommonAnnotations {
+		if _, ok := anns[k]; !ok {
+			anns[k] = v
+		}
+	}
+	for k, v := range attrs.SourceAnnotations {
+		anns[k] = v
+	}
 	anns[QbecNames.ComponentAnnotation] = attrs.Component
 	base.SetAnnotations(anns)
 	return ret
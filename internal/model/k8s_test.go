@@ -106,6 +106,71 @@ func TestK8sLocalObjectWithComponentLabel(t *testing.T) {
 	a.Equal("e1", labels[QbecNames.EnvironmentLabel])
 	a.Equal("t1", labels[QbecNames.TagLabel])
 	a.Equal("c1", labels[QbecNames.ComponentLabel])
+
+	obj = NewK8sLocalObject(toData(cm), LocalAttrs{App: "app1", Component: "team-a/c1", Env: "e1", SetComponentLabel: true})
+	a.Equal("team-a/c1", obj.Component())
+	a.Equal("team-a.c1", obj.ToUnstructured().GetLabels()[QbecNames.ComponentLabel])
+	a.Equal("team-a/c1", obj.ToUnstructured().GetAnnotations()[QbecNames.ComponentAnnotation])
+}
+
+func TestK8sLocalObjectWithCommonMetadata(t *testing.T) {
+	data := toData(cm)
+	data["metadata"].(map[string]interface{})["labels"] = map[string]interface{}{"team": "override"}
+	obj := NewK8sLocalObject(data, LocalAttrs{
+		App:               "app1",
+		Component:         "c1",
+		Env:               "e1",
+		CommonLabels:      map[string]string{"team": "platform", "cost-center": "42"},
+		CommonAnnotations: map[string]string{"example.com/owner": "platform team"},
+	})
+	a := assert.New(t)
+	labels := obj.ToUnstructured().GetLabels()
+	a.Equal("app1", labels[QbecNames.ApplicationLabel])
+	a.Equal("override", labels["team"])
+	a.Equal("42", labels["cost-center"])
+	anns := obj.ToUnstructured().GetAnnotations()
+	a.Equal("c1", anns[QbecNames.ComponentAnnotation])
+	a.Equal("platform team", anns["example.com/owner"])
+}
+
+func TestK8sLocalObjectWithSourceAnnotations(t *testing.T) {
+	data := toData(cm)
+	data["metadata"].(map[string]interface{})["annotations"] = map[string]interface{}{
+		QbecNames.SourceAnnotations.Commit: "override",
+	}
+	obj := NewK8sLocalObject(data, LocalAttrs{
+		App:               "app1",
+		Component:         "c1",
+		Env:               "e1",
+		SourceAnnotations: map[string]string{QbecNames.SourceAnnotations.Commit: "abc123"},
+	})
+	anns := obj.ToUnstructured().GetAnnotations()
+	assert.Equal(t, "abc123", anns[QbecNames.SourceAnnotations.Commit])
+	assert.Equal(t, "c1", anns[QbecNames.ComponentAnnotation])
+}
+
+func TestK8sLocalObjectTrackedGeneratedName(t *testing.T) {
+	newObject := func(comp string, policy string) K8sLocalObject {
+		data := toData(cm)
+		md := data["metadata"].(map[string]interface{})
+		delete(md, "name")
+		md["generateName"] = "cm-"
+		if policy != "" {
+			md["annotations"] = map[string]interface{}{QbecNames.Directives.GenerateNamePolicy: policy}
+		}
+		return NewK8sLocalObject(data, LocalAttrs{App: "app1", Component: comp, Env: "e1"})
+	}
+	a := assert.New(t)
+	tracked := newObject("c1", GenerateNamePolicyTrack)
+	id := TrackedIdentity(tracked)
+	a.Len(id, 40)
+	a.Equal(id, tracked.ToUnstructured().GetLabels()[QbecNames.IdentityLabel])
+	a.Equal(id, TrackedIdentity(newObject("c1", GenerateNamePolicyTrack)))
+	a.NotEqual(id, TrackedIdentity(newObject("c2", GenerateNamePolicyTrack)))
+	a.Equal("", TrackedIdentity(newObject("c1", "")))
+	a.Equal("", TrackedIdentity(newObject("c1", "default")))
+	named := NewK8sLocalObject(toData(cm), LocalAttrs{App: "app1", Component: "c1", Env: "e1"})
+	a.Equal("", TrackedIdentity(named))
 }
 
 func TestAssertMetadata(t *testing.T) {
@@ -0,0 +1,96 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	"github.com/go-openapi/spec"
+	"github.com/go-openapi/strfmt"
+	"github.com/go-openapi/validate"
+)
+
+// loadParamsSchema loads the JSON schema from the supplied JSON or YAML file.
+func loadParamsSchema(file string) (*spec.Schema, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	b, err = yaml.YAMLToJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	var schema spec.Schema
+	if err := json.Unmarshal(b, &schema); err != nil {
+		return nil, fmt.Errorf("%s: invalid schema: %v", file, err)
+	}
+	return &schema, nil
+}
+
+func (a *App) verifyParamsSchemas() error {
+	a.paramsSchemas = map[string]*spec.Schema{}
+	for name, c := range a.inner.Spec.Components {
+		if c.ParamsSchema == "" {
+			continue
+		}
+		schema, err := loadParamsSchema(resolvePath(a.base, c.ParamsSchema))
+		if err != nil {
+			return fmt.Errorf("params schema for component %s: %v", name, err)
+		}
+		a.paramsSchemas[name] = schema
+	}
+	return nil
+}
+
+// ParamsSchemaFiles returns the sorted parameter schema files declared for components.
+func (a *App) ParamsSchemaFiles() []string {
+	var ret []string
+	for _, c := range a.inner.Spec.Components {
+		if c.ParamsSchema != "" {
+			ret = append(ret, resolvePath(a.base, c.ParamsSchema))
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// HasParamsSchema returns true if a parameter schema has been declared for the supplied component.
+func (a *App) HasParamsSchema(component string) bool {
+	_, ok := a.paramsSchemas[component]
+	return ok
+}
+
+// ValidateParams validates the supplied parameters of a component against the schema declared for it and returns
+// the validation errors sorted by message. Messages start with the path to the failing parameter in the form
+// components.<component>.<path>. No errors are returned for components that do not have a schema.
+func (a *App) ValidateParams(component string, params interface{}) []error {
+	schema, ok := a.paramsSchemas[component]
+	if !ok {
+		return nil
+	}
+	v := validate.NewSchemaValidator(schema, nil, "components."+component, strfmt.Default)
+	res := v.Validate(params)
+	errs := res.Errors
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+	return errs
+}
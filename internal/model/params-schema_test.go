@@ -0,0 +1,97 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSchemaApp writes an app with a single component that uses the supplied schema file into a temp directory,
+// changes to it, and returns the path to its qbec.yaml file.
+func writeSchemaApp(t *testing.T, schemaFile, schema string) string {
+	dir := t.TempDir()
+	t.Cleanup(setPwd(t, dir))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "components"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "components", "web.jsonnet"), []byte("{}"), 0644))
+	if schema != "" {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, schemaFile), []byte(schema), 0644))
+	}
+	app := `
+apiVersion: qbec.io/v1alpha1
+kind: App
+metadata:
+  name: schema-app
+spec:
+  environments:
+    dev:
+      server: https://dev-server
+  components:
+    web:
+      paramsSchema: ` + schemaFile + `
+`
+	file := filepath.Join(dir, "qbec.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte(app), 0644))
+	return file
+}
+
+func TestAppValidateParams(t *testing.T) {
+	file := writeSchemaApp(t, "web.schema.json", `{
+  "type": "object",
+  "required": [ "replicas" ],
+  "properties": {
+    "replicas": { "type": "integer", "minimum": 1 },
+    "ports": { "type": "array", "items": { "type": "integer" } }
+  }
+}`)
+	app, err := NewApp(file, nil, "")
+	require.NoError(t, err)
+	a := assert.New(t)
+	a.True(app.HasParamsSchema("web"))
+	a.False(app.HasParamsSchema("other"))
+	a.Nil(app.ValidateParams("web", map[string]interface{}{"replicas": 2}))
+	a.Nil(app.ValidateParams("other", map[string]interface{}{"replicas": "two"}))
+
+	errs := app.ValidateParams("web", map[string]interface{}{"replicas": 0, "ports": []interface{}{80, "443"}})
+	require.Equal(t, 2, len(errs))
+	a.Contains(errs[0].Error(), "components.web.ports in body must be of type integer")
+	a.Contains(errs[1].Error(), "components.web.replicas in body should be greater than or equal to 1")
+
+	errs = app.ValidateParams("web", map[string]interface{}{})
+	require.Equal(t, 1, len(errs))
+	a.Contains(errs[0].Error(), "components.web.replicas in body is required")
+}
+
+func TestAppParamsSchemaNegative(t *testing.T) {
+	t.Run("missing", func(t *testing.T) {
+		file := writeSchemaApp(t, "missing.json", "")
+		_, err := NewApp(file, nil, "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "params schema for component web: open missing.json: no such file or directory")
+	})
+	t.Run("invalid", func(t *testing.T) {
+		file := writeSchemaApp(t, "bad.yaml", "type: [ 10 ]\n")
+		_, err := NewApp(file, nil, "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "params schema for component web: bad.yaml: invalid schema:")
+	})
+}
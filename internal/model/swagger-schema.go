@@ -1,20 +1,6 @@
-// Copyright 2021 Splunk Inc.
-//
-// Licensed under the Apache License, Version 2.0 (the "License");
-// you may not use this file except in compliance with the License.
-// You may obtain a copy of the License at
-//
-//      http://www.apache.org/licenses/LICENSE-2.0
-//
-// Unless required by applicable law or agreed to in writing, software
-// distributed under the License is distributed on an "AS IS" BASIS,
-// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-// See the License for the specific language governing permissions and
-// limitations under the License.
-
 package model
 
-// generated by gen-qbec-swagger from internal/model/swagger.yaml at 2021-07-04 17:05:07.106385 +0000 UTC
+// generated by gen-qbec-swagger from internal/model/swagger.yaml at 2026-10-18 04:30:01.49839267 +0000 UTC
 // Do NOT edit this file by hand
 
 var swaggerJSON = `
@@ -49,6 +35,53 @@ var swaggerJSON = `
             "title": "QbecApp is a set of components that can be applied to multiple environments with tweaked runtime configurations.",
             "type": "object"
         },
+        "qbec.io.v1alpha1.AppFragment": {
+            "additionalProperties": false,
+            "properties": {
+                "apiVersion": {
+                    "description": "requested API version",
+                    "type": "string"
+                },
+                "kind": {
+                    "description": "object kind",
+                    "pattern": "^AppFragment$",
+                    "type": "string"
+                },
+                "spec": {
+                    "$ref": "#/definitions/qbec.io.v1alpha1.AppFragmentSpec"
+                }
+            },
+            "required": [
+                "kind",
+                "apiVersion",
+                "spec"
+            ],
+            "title": "AppFragment is a partial app specification that is included by apps.",
+            "type": "object"
+        },
+        "qbec.io.v1alpha1.AppFragmentSpec": {
+            "additionalProperties": false,
+            "properties": {
+                "environments": {
+                    "additionalProperties": {
+                        "$ref": "#/definitions/qbec.io.v1alpha1.Environment"
+                    },
+                    "description": "set of environments shared by apps that include the fragment",
+                    "type": "object"
+                },
+                "excludes": {
+                    "description": "list of components to exclude by default for every environment",
+                    "items": {
+                        "type": "string"
+                    },
+                    "type": "array"
+                },
+                "vars": {
+                    "$ref": "#/definitions/qbec.io.v1alpha1.Variables"
+                }
+            },
+            "type": "object"
+        },
         "qbec.io.v1alpha1.AppMeta": {
             "additionalProperties": false,
             "properties": {
@@ -70,18 +103,61 @@ var swaggerJSON = `
                     "description": "add component name as label to Kubernetes objects",
                     "type": "boolean"
                 },
+                "annotateSource": {
+                    "$ref": "#/definitions/qbec.io.v1alpha1.SourceAnnotations"
+                },
                 "baseProperties": {
                     "description": "properties for the baseline environment",
                     "type": "object"
                 },
+                "canonicalVersions": {
+                    "additionalProperties": {
+                        "type": "string"
+                    },
+                    "description": "versions to use as canonical versions for specific types, keyed by Kind.group",
+                    "type": "object"
+                },
                 "clusterScopedLists": {
                     "description": "whether remote lists should use cluster scoped queries when multiple namespaces present",
                     "type": "boolean"
                 },
+                "commonAnnotations": {
+                    "additionalProperties": {
+                        "type": "string"
+                    },
+                    "description": "annotations to add to all Kubernetes objects, in addition to the ones set by qbec",
+                    "type": "object"
+                },
+                "commonLabels": {
+                    "additionalProperties": {
+                        "type": "string"
+                    },
+                    "description": "labels to add to all Kubernetes objects, in addition to the ones set by qbec",
+                    "type": "object"
+                },
+                "componentNamespaces": {
+                    "description": "when true, subdirectories of component directories that are not themselves components are treated as\nnamespaces and the components in them are named \u003csubdirectory\u003e/\u003cname\u003e.",
+                    "type": "boolean"
+                },
+                "componentTimeout": {
+                    "description": "maximum time allowed to evaluate a single component, as a duration string (e.g. 2m)",
+                    "type": "string"
+                },
+                "components": {
+                    "additionalProperties": {
+                        "$ref": "#/definitions/qbec.io.v1alpha1.ComponentSpec"
+                    },
+                    "description": "additional configuration for components, keyed by component name",
+                    "type": "object"
+                },
                 "componentsDir": {
                     "description": "directory containing component files, default to components/",
                     "type": "string"
                 },
+                "componentsDirRecurse": {
+                    "description": "when true, subdirectories of component directories are searched for components at any depth and the\ncomponents found are named using their path relative to the component directory.",
+                    "type": "boolean"
+                },
                 "dataSources": {
                     "description": "a list of data sources to be defined for the qbec app.",
                     "items": {
@@ -89,10 +165,24 @@ var swaggerJSON = `
                     },
                     "type": "array"
                 },
+                "diffIgnores": {
+                    "description": "fields of matching objects that are ignored when objects are diffed",
+                    "items": {
+                        "$ref": "#/definitions/qbec.io.v1alpha1.DiffIgnore"
+                    },
+                    "type": "array"
+                },
                 "dsExamples": {
                     "description": "sample output for every datasource for use by the linter",
                     "type": "object"
                 },
+                "dsTestPaths": {
+                    "additionalProperties": {
+                        "type": "string"
+                    },
+                    "description": "paths resolved by data sources when they are tested using the datasource test command, keyed by data source name",
+                    "type": "object"
+                },
                 "envFiles": {
                     "description": "list of additional files containing environment definitions to load.\nenvironment definitions are merged in the order specified starting with any inline environments.\nAn environment defined in a later file takes precedence over the the same environment already loaded\nand replaces it.",
                     "items": {
@@ -100,6 +190,13 @@ var swaggerJSON = `
                     },
                     "type": "array"
                 },
+                "envProviders": {
+                    "description": "providers that produce additional environment definitions at runtime.\nProviders are loaded in the order specified after all environment files, with later definitions\nreplacing earlier ones.",
+                    "items": {
+                        "$ref": "#/definitions/qbec.io.v1alpha1.EnvProvider"
+                    },
+                    "type": "array"
+                },
                 "environments": {
                     "additionalProperties": {
                         "$ref": "#/definitions/qbec.io.v1alpha1.Environment"
@@ -115,6 +212,27 @@ var swaggerJSON = `
                     },
                     "type": "array"
                 },
+                "hooks": {
+                    "$ref": "#/definitions/qbec.io.v1alpha1.Hooks"
+                },
+                "httpLibPaths": {
+                    "description": "base URLs from which jsonnet files are imported when they are not found locally",
+                    "items": {
+                        "$ref": "#/definitions/qbec.io.v1alpha1.HTTPLibPath"
+                    },
+                    "type": "array"
+                },
+                "includes": {
+                    "description": "list of files or URLs containing app fragments to merge into the app, in the order specified.\nDefinitions in the app take precedence over those in fragments and definitions in a later fragment\ntake precedence over those in an earlier one.",
+                    "items": {
+                        "type": "string"
+                    },
+                    "type": "array"
+                },
+                "interpolate": {
+                    "description": "when true, templates in the server, default namespace and properties of environments are expanded\nusing the values of variables when the app is loaded.",
+                    "type": "boolean"
+                },
                 "libPaths": {
                     "description": "list of library paths to add to the jsonnet VM at evaluation",
                     "items": {
@@ -122,6 +240,13 @@ var swaggerJSON = `
                     },
                     "type": "array"
                 },
+                "libraryBundles": {
+                    "additionalProperties": {
+                        "type": "string"
+                    },
+                    "description": "zip files containing jsonnet libraries keyed by library name, imported as qbec-lib/\u003cname\u003e/\u003cpath\u003e",
+                    "type": "object"
+                },
                 "namespaceTagSuffix": {
                     "description": "suffix default namespace when app-tag provided, with the supplied tag",
                     "type": "boolean"
@@ -130,17 +255,117 @@ var swaggerJSON = `
                     "description": "standard file containing parameters for all environments returning correct values based on qbec.io/env external\nvariable, defaults to params.libsonnet",
                     "type": "string"
                 },
+                "policies": {
+                    "description": "policies that objects produced by components are checked against",
+                    "items": {
+                        "$ref": "#/definitions/qbec.io.v1alpha1.Policy"
+                    },
+                    "type": "array"
+                },
                 "postProcessor": {
                     "description": "file containing jsonnet code that can be used to post-process all objects, typically adding metadata like\nannotations",
                     "type": "string"
                 },
+                "preProcessor": {
+                    "description": "file containing jsonnet code that is evaluated before components, with its output made available to\ncomponent code as an external code variable named after the base name of the file. Multiple files may be\nseparated by a colon.",
+                    "type": "string"
+                },
+                "preserveObjectOrder": {
+                    "description": "show objects in the order in which they are emitted by components instead of sorting them",
+                    "type": "boolean"
+                },
+                "redactions": {
+                    "description": "fields of objects of specific types whose values are hidden in output, in addition to secret data",
+                    "items": {
+                        "$ref": "#/definitions/qbec.io.v1alpha1.Redaction"
+                    },
+                    "type": "array"
+                },
+                "transforms": {
+                    "description": "patches applied to matching objects after evaluation, in the order specified",
+                    "items": {
+                        "$ref": "#/definitions/qbec.io.v1alpha1.Transform"
+                    },
+                    "type": "array"
+                },
                 "vars": {
                     "$ref": "#/definitions/qbec.io.v1alpha1.Variables"
+                },
+                "vendorDir": {
+                    "description": "directory containing libraries vendored by jsonnet-bundler, defaults to vendor. It is added to the library\npaths when the app has a jsonnetfile.json file.",
+                    "type": "string"
+                },
+                "waitStatus": {
+                    "additionalProperties": {
+                        "type": "string"
+                    },
+                    "description": "status expressions that determine when objects of specific types are ready, keyed by Kind.group",
+                    "type": "object"
                 }
             },
             "title": "AppSpec is the user-supplied configuration of the qbec app.",
             "type": "object"
         },
+        "qbec.io.v1alpha1.AppTagRules": {
+            "additionalProperties": false,
+            "properties": {
+                "allow": {
+                    "description": "glob patterns for app tags allowed for the environment, all tags are allowed when not specified",
+                    "items": {
+                        "type": "string"
+                    },
+                    "type": "array"
+                },
+                "deny": {
+                    "description": "glob patterns for app tags that are not allowed for the environment, takes precedence over allow",
+                    "items": {
+                        "type": "string"
+                    },
+                    "type": "array"
+                }
+            },
+            "title": "AppTagRules restricts the app tags that may be used with an environment.",
+            "type": "object"
+        },
+        "qbec.io.v1alpha1.ClientSettings": {
+            "additionalProperties": false,
+            "description": "settings for the kubernetes client, overridden by the --k8s:client-qps and --k8s:client-burst options",
+            "properties": {
+                "burst": {
+                    "description": "maximum burst of queries for the client, 0 for the client default. Never lower than the QPS.",
+                    "minimum": 0,
+                    "type": "integer"
+                },
+                "qps": {
+                    "description": "queries per second allowed for the client, 0 for the client default",
+                    "minimum": 0,
+                    "type": "integer"
+                }
+            },
+            "type": "object"
+        },
+        "qbec.io.v1alpha1.ComponentSpec": {
+            "additionalProperties": false,
+            "properties": {
+                "dependsOn": {
+                    "description": "names of components that must be applied and ready before the component is applied",
+                    "items": {
+                        "type": "string"
+                    },
+                    "type": "array"
+                },
+                "paramsSchema": {
+                    "description": "JSON or YAML file containing a JSON schema that the parameters of the component are validated against",
+                    "type": "string"
+                },
+                "timeout": {
+                    "description": "maximum time to evaluate the component, overrides the component timeout of the app and environment",
+                    "type": "string"
+                }
+            },
+            "title": "ComponentSpec is additional configuration for a single component.",
+            "type": "object"
+        },
         "qbec.io.v1alpha1.ComputedVar": {
             "additionalProperties": false,
             "properties": {
@@ -161,33 +386,151 @@ var swaggerJSON = `
             "title": "ComputedVar is a named code variable that is computed using inline jsonnet code.\nThe computation is allowed to refer to other external variables including those set by qbec for an environment\nas well as previously computed variables. Inline code is evaluated as though it were defined in a file in the qbec root.\nThis means that relative references to imports will be resolved as expected.",
             "type": "object"
         },
+        "qbec.io.v1alpha1.Credentials": {
+            "additionalProperties": false,
+            "description": "credentials to connect to the server of the environment without a kubeconfig file. File paths are relative to the root directory of the app.",
+            "properties": {
+                "certificateAuthority": {
+                    "description": "path to a CA bundle used to verify the server certificate",
+                    "type": "string"
+                },
+                "certificateAuthorityData": {
+                    "description": "base64-encoded CA bundle used to verify the server certificate",
+                    "type": "string"
+                },
+                "exec": {
+                    "$ref": "#/definitions/qbec.io.v1alpha1.ExecCredentials"
+                },
+                "insecureSkipTLSVerify": {
+                    "description": "do not verify the server certificate",
+                    "type": "boolean"
+                },
+                "tokenEnv": {
+                    "description": "name of an environment variable containing a bearer token",
+                    "type": "string"
+                },
+                "tokenFile": {
+                    "description": "path to a file containing a bearer token",
+                    "type": "string"
+                }
+            },
+            "type": "object"
+        },
+        "qbec.io.v1alpha1.DiffIgnore": {
+            "additionalProperties": false,
+            "properties": {
+                "environments": {
+                    "description": "the environments for which the fields are ignored, all environments when not specified",
+                    "items": {
+                        "type": "string"
+                    },
+                    "type": "array"
+                },
+                "paths": {
+                    "description": "JSONPath expressions for the fields to ignore, for example .spec.replicas",
+                    "items": {
+                        "type": "string"
+                    },
+                    "minItems": 1,
+                    "type": "array"
+                },
+                "target": {
+                    "$ref": "#/definitions/qbec.io.v1alpha1.TransformTarget"
+                }
+            },
+            "required": [
+                "paths"
+            ],
+            "title": "DiffIgnore is a set of fields that are ignored when objects matching a target are diffed.",
+            "type": "object"
+        },
+        "qbec.io.v1alpha1.EnvProvider": {
+            "additionalProperties": false,
+            "properties": {
+                "cacheTTL": {
+                    "description": "duration for which the environment map is cached on disk and reused, no caching when not specified",
+                    "type": "string"
+                },
+                "exec": {
+                    "$ref": "#/definitions/qbec.io.v1alpha1.ExecHook"
+                },
+                "name": {
+                    "description": "the name of the provider, unique within the app",
+                    "minLength": 1,
+                    "type": "string"
+                },
+                "timeout": {
+                    "description": "maximum time allowed to run the command or fetch the URL, defaults to 30s",
+                    "type": "string"
+                },
+                "tokenEnv": {
+                    "description": "environment variable containing a bearer token sent with requests to the URL",
+                    "type": "string"
+                },
+                "url": {
+                    "description": "the http(s) URL from which the environment map is fetched",
+                    "type": "string"
+                }
+            },
+            "required": [
+                "name"
+            ],
+            "title": "EnvProvider produces an environment map at runtime. Exactly one of exec or url must be specified.",
+            "type": "object"
+        },
         "qbec.io.v1alpha1.Environment": {
             "additionalProperties": false,
             "properties": {
+                "appTags": {
+                    "$ref": "#/definitions/qbec.io.v1alpha1.AppTagRules"
+                },
+                "client": {
+                    "$ref": "#/definitions/qbec.io.v1alpha1.ClientSettings"
+                },
                 "context": {
                     "type": "string"
                 },
+                "credentials": {
+                    "$ref": "#/definitions/qbec.io.v1alpha1.Credentials"
+                },
                 "defaultNamespace": {
                     "type": "string"
                 },
+                "eval": {
+                    "$ref": "#/definitions/qbec.io.v1alpha1.EvalSettings"
+                },
                 "excludes": {
                     "items": {
                         "type": "string"
                     },
                     "type": "array"
                 },
+                "impersonate": {
+                    "$ref": "#/definitions/qbec.io.v1alpha1.Impersonation"
+                },
                 "includes": {
                     "items": {
                         "type": "string"
                     },
                     "type": "array"
                 },
+                "inherits": {
+                    "description": "name of an environment from which properties, includes, excludes, var files, the default namespace and the server/ context are inherited.",
+                    "type": "string"
+                },
                 "properties": {
                     "description": "open-ended object containing additional environment properties.",
                     "type": "object"
                 },
                 "server": {
                     "type": "string"
+                },
+                "varFiles": {
+                    "description": "files in dotenv format containing default values for external string variables for the environment, relative to the root directory of the app.",
+                    "items": {
+                        "type": "string"
+                    },
+                    "type": "array"
                 }
             },
             "title": "Environment points to a specific destination and has its own set of runtime parameters.",
@@ -231,6 +574,96 @@ var swaggerJSON = `
                 "environments"
             ]
         },
+        "qbec.io.v1alpha1.EvalSettings": {
+            "additionalProperties": false,
+            "properties": {
+                "componentTimeout": {
+                    "description": "maximum time allowed to evaluate a single component, as a duration string (e.g. 2m), overrides the app-level setting",
+                    "type": "string"
+                },
+                "concurrency": {
+                    "description": "concurrency with which to evaluate components, overridden by the --eval-concurrency option",
+                    "minimum": 0,
+                    "type": "integer"
+                },
+                "maxDataSourceBytes": {
+                    "description": "maximum size in bytes of the output of a data source for a single import, 0 for no limit, overridden by the --max-data-source-bytes option",
+                    "minimum": 0,
+                    "type": "integer"
+                }
+            },
+            "type": "object"
+        },
+        "qbec.io.v1alpha1.ExecCredentials": {
+            "additionalProperties": false,
+            "description": "exec credential plugin that produces credentials for the server, as for kubeconfig users",
+            "properties": {
+                "apiVersion": {
+                    "description": "the client.authentication.k8s.io API version used by the plugin, defaults to client.authentication.k8s.io/v1beta1",
+                    "type": "string"
+                },
+                "args": {
+                    "description": "arguments to pass to the command",
+                    "items": {
+                        "type": "string"
+                    },
+                    "type": "array"
+                },
+                "command": {
+                    "description": "the command to execute",
+                    "minLength": 1,
+                    "type": "string"
+                },
+                "env": {
+                    "additionalProperties": {
+                        "type": "string"
+                    },
+                    "description": "additional environment variables to set for the command",
+                    "type": "object"
+                },
+                "installHint": {
+                    "description": "message displayed when the command cannot be found",
+                    "type": "string"
+                },
+                "provideClusterInfo": {
+                    "description": "pass cluster information to the plugin in the KUBERNETES_EXEC_INFO environment variable",
+                    "type": "boolean"
+                }
+            },
+            "required": [
+                "command"
+            ],
+            "type": "object"
+        },
+        "qbec.io.v1alpha1.ExecHook": {
+            "additionalProperties": false,
+            "properties": {
+                "args": {
+                    "description": "arguments to the command",
+                    "items": {
+                        "type": "string"
+                    },
+                    "type": "array"
+                },
+                "command": {
+                    "description": "the command to run, relative paths are resolved from the qbec root",
+                    "minLength": 1,
+                    "type": "string"
+                },
+                "env": {
+                    "additionalProperties": {
+                        "type": "string"
+                    },
+                    "description": "additional environment variables for the command",
+                    "type": "object"
+                }
+            },
+            "required": [
+                "command"
+            ],
+            "title": "ExecHook is a command run as a hook.",
+            "type": "object"
+        },
         "qbec.io.v1alpha1.ExternalVar": {
             "additionalProperties": false,
             "properties": {
@@ -250,6 +683,179 @@ var swaggerJSON = `
             "title": "ExternalVar is a variable that is set as an extVar in the jsonnet VM",
             "type": "object"
         },
+        "qbec.io.v1alpha1.HTTPLibPath": {
+            "additionalProperties": false,
+            "properties": {
+                "sha256": {
+                    "additionalProperties": {
+                        "pattern": "^[0-9a-f]{64}$",
+                        "type": "string"
+                    },
+                    "description": "hex encoded sha256 digests of files keyed by path relative to the URL",
+                    "type": "object"
+                },
+                "url": {
+                    "description": "the https base URL",
+                    "pattern": "^https://",
+                    "type": "string"
+                }
+            },
+            "required": [
+                "url"
+            ],
+            "title": "HTTPLibPath is a base URL from which jsonnet files are imported, with optional digests to pin file contents.",
+            "type": "object"
+        },
+        "qbec.io.v1alpha1.Hook": {
+            "additionalProperties": false,
+            "properties": {
+                "exec": {
+                    "$ref": "#/definitions/qbec.io.v1alpha1.ExecHook"
+                },
+                "jsonnet": {
+                    "description": "a jsonnet or YAML file producing objects such as jobs that are applied and waited for",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "the name of the hook, unique within a phase",
+                    "pattern": "^[a-z0-9]([-a-z0-9]*[a-z0-9])?$",
+                    "type": "string"
+                }
+            },
+            "required": [
+                "name"
+            ],
+            "title": "Hook is a command or a file producing objects. Exactly one of exec or jsonnet must be specified.",
+            "type": "object"
+        },
+        "qbec.io.v1alpha1.Hooks": {
+            "additionalProperties": false,
+            "properties": {
+                "postApply": {
+                    "description": "hooks run after all objects have been applied, receiving the apply summary as JSON on stdin",
+                    "items": {
+                        "$ref": "#/definitions/qbec.io.v1alpha1.Hook"
+                    },
+                    "type": "array"
+                },
+                "preApply": {
+                    "description": "hooks run after changes are confirmed and before any object is applied",
+                    "items": {
+                        "$ref": "#/definitions/qbec.io.v1alpha1.Hook"
+                    },
+                    "type": "array"
+                }
+            },
+            "title": "Hooks are run before and after objects are applied.",
+            "type": "object"
+        },
+        "qbec.io.v1alpha1.Impersonation": {
+            "additionalProperties": false,
+            "description": "user and groups to impersonate for all requests to the server, overridden by the --k8s:as, --k8s:as-uid and --k8s:as-group options",
+            "properties": {
+                "groups": {
+                    "description": "groups to impersonate",
+                    "items": {
+                        "type": "string"
+                    },
+                    "type": "array"
+                },
+                "uid": {
+                    "description": "the uid of the user to impersonate",
+                    "type": "string"
+                },
+                "user": {
+                    "description": "the user to impersonate",
+                    "minLength": 1,
+                    "type": "string"
+                }
+            },
+            "required": [
+                "user"
+            ],
+            "type": "object"
+        },
+        "qbec.io.v1alpha1.Policy": {
+            "additionalProperties": false,
+            "properties": {
+                "environments": {
+                    "description": "the environments for which the policy is checked, all environments when not specified",
+                    "items": {
+                        "type": "string"
+                    },
+                    "type": "array"
+                },
+                "file": {
+                    "description": "the jsonnet file that returns an array of violation messages for the object passed as the top-level variable named object, or a rego file whose deny rule produces violation messages for the object passed as input",
+                    "type": "string"
+                },
+                "level": {
+                    "description": "deny (the default) to fail for violations, warn to report them as warnings",
+                    "enum": [
+                        "deny",
+                        "warn"
+                    ],
+                    "type": "string"
+                },
+                "name": {
+                    "description": "the name of the policy, used in messages",
+                    "type": "string"
+                },
+                "target": {
+                    "$ref": "#/definitions/qbec.io.v1alpha1.TransformTarget"
+                }
+            },
+            "required": [
+                "name",
+                "file"
+            ],
+            "title": "Policy is a check that objects matching a target must pass.",
+            "type": "object"
+        },
+        "qbec.io.v1alpha1.Redaction": {
+            "additionalProperties": false,
+            "properties": {
+                "kind": {
+                    "description": "the kind of objects, qualified by the API group for non-core types (e.g. ExternalSecret.external-secrets.io)",
+                    "minLength": 1,
+                    "type": "string"
+                },
+                "paths": {
+                    "description": "JSONPath expressions for the fields whose values are hidden, for example .spec.template.data",
+                    "items": {
+                        "type": "string"
+                    },
+                    "minItems": 1,
+                    "type": "array"
+                }
+            },
+            "required": [
+                "kind",
+                "paths"
+            ],
+            "title": "Redaction is a set of fields whose values are hidden in output for objects of a specific type.",
+            "type": "object"
+        },
+        "qbec.io.v1alpha1.SourceAnnotations": {
+            "additionalProperties": false,
+            "properties": {
+                "enabled": {
+                    "description": "add source annotations to all objects",
+                    "type": "boolean"
+                },
+                "timestamp": {
+                    "description": "timestamp policy for the render time annotation, defaults to none",
+                    "enum": [
+                        "none",
+                        "commit",
+                        "render"
+                    ],
+                    "type": "string"
+                }
+            },
+            "title": "SourceAnnotations controls the source metadata annotations that qbec adds to all objects.",
+            "type": "object"
+        },
         "qbec.io.v1alpha1.TopLevelVar": {
             "additionalProperties": false,
             "properties": {
@@ -274,6 +880,60 @@ var swaggerJSON = `
             "title": "TopLevelVar is a variable that is set as a TLA in the jsonnet VM. Note that there is no provision to set\na default value - default values should be set in the jsonnet code instead.",
             "type": "object"
         },
+        "qbec.io.v1alpha1.Transform": {
+            "additionalProperties": false,
+            "properties": {
+                "environments": {
+                    "description": "the environments for which the transform is applied, all environments when not specified",
+                    "items": {
+                        "type": "string"
+                    },
+                    "type": "array"
+                },
+                "jsonPatch": {
+                    "description": "a list of JSON patch (RFC 6902) operations",
+                    "items": {
+                        "type": "object"
+                    },
+                    "type": "array"
+                },
+                "patch": {
+                    "description": "a strategic merge patch for the object, a JSON merge patch is used for types without strategic merge support",
+                    "type": "object"
+                },
+                "target": {
+                    "$ref": "#/definitions/qbec.io.v1alpha1.TransformTarget"
+                }
+            },
+            "required": [
+                "target"
+            ],
+            "title": "Transform is a patch that is applied to objects matching a target after evaluation.",
+            "type": "object"
+        },
+        "qbec.io.v1alpha1.TransformTarget": {
+            "additionalProperties": false,
+            "properties": {
+                "component": {
+                    "description": "the component that produces the objects",
+                    "type": "string"
+                },
+                "kind": {
+                    "description": "the kind of objects to match",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "the name of objects to match",
+                    "type": "string"
+                },
+                "namespace": {
+                    "description": "the namespace of objects to match, as set by the component",
+                    "type": "string"
+                }
+            },
+            "title": "TransformTarget selects the objects to which a transform is applied.",
+            "type": "object"
+        },
         "qbec.io.v1alpha1.Variables": {
             "additionalProperties": false,
             "properties": {
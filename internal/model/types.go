@@ -17,7 +17,10 @@
 package model
 
 import (
+	"encoding/base64"
 	"fmt"
+	"path"
+	"regexp"
 	"strings"
 )
 
@@ -25,12 +28,152 @@ import (
 
 // Environment points to a specific destination and has its own set of runtime parameters.
 type Environment struct {
-	DefaultNamespace string                 `json:"defaultNamespace"`     // default namespace to set for k8s context
-	Server           string                 `json:"server,omitempty"`     // server URL of server, must be present unless
-	Context          string                 `json:"context,omitempty"`    // named context to use instead of deriving from server URL
-	Includes         []string               `json:"includes,omitempty"`   // components to be included in this env even if excluded at the app level
-	Excludes         []string               `json:"excludes,omitempty"`   // additional components to exclude for this env
-	Properties       map[string]interface{} `json:"properties,omitempty"` // properties attached to the environment, exposed via an extvar
+	DefaultNamespace string                 `json:"defaultNamespace"`      // default namespace to set for k8s context
+	Server           string                 `json:"server,omitempty"`      // server URL of server, must be present unless
+	Context          string                 `json:"context,omitempty"`     // named context to use instead of deriving from server URL
+	Includes         []string               `json:"includes,omitempty"`    // components to be included in this env even if excluded at the app level
+	Excludes         []string               `json:"excludes,omitempty"`    // additional components to exclude for this env
+	Properties       map[string]interface{} `json:"properties,omitempty"`  // properties attached to the environment, exposed via an extvar
+	Inherits         string                 `json:"inherits,omitempty"`    // name of environment from which to inherit attributes
+	AppTags          *AppTagRules           `json:"appTags,omitempty"`     // rules for app tags accepted by this environment
+	VarFiles         []string               `json:"varFiles,omitempty"`    // dotenv files with default values for external string variables
+	Eval             *EvalSettings          `json:"eval,omitempty"`        // evaluation settings for this environment
+	Credentials      *Credentials           `json:"credentials,omitempty"` // credentials to connect to the server without a kubeconfig
+	Impersonate      *Impersonation         `json:"impersonate,omitempty"` // user and groups to impersonate for requests to the server
+	Client           *ClientSettings        `json:"client,omitempty"`      // settings for the kubernetes client
+}
+
+// Credentials allow qbec to connect to the server of an environment without a kubeconfig file. File paths are
+// relative to the root directory of the app. Secrets are never specified inline and are instead read from files,
+// environment variables or exec credential plugins.
+type Credentials struct {
+	CertificateAuthority     string           `json:"certificateAuthority,omitempty"`     // path to a CA bundle for the server
+	CertificateAuthorityData string           `json:"certificateAuthorityData,omitempty"` // base64-encoded CA bundle for the server
+	InsecureSkipTLSVerify    bool             `json:"insecureSkipTLSVerify,omitempty"`    // do not verify the server certificate
+	TokenFile                string           `json:"tokenFile,omitempty"`                // path to a file containing a bearer token
+	TokenEnv                 string           `json:"tokenEnv,omitempty"`                 // environment variable containing a bearer token
+	Exec                     *ExecCredentials `json:"exec,omitempty"`                     // exec credential plugin
+}
+
+// ExecCredentials configures an exec credential plugin in the same way as a kubeconfig user.
+type ExecCredentials struct {
+	APIVersion         string            `json:"apiVersion,omitempty"`         // the client.authentication.k8s.io API version of the plugin
+	Command            string            `json:"command"`                      // command to execute
+	Args               []string          `json:"args,omitempty"`               // arguments to the command
+	Env                map[string]string `json:"env,omitempty"`                // additional environment variables for the command
+	InstallHint        string            `json:"installHint,omitempty"`        // message displayed when the command is not found
+	ProvideClusterInfo bool              `json:"provideClusterInfo,omitempty"` // pass cluster information to the plugin
+}
+
+// Impersonation is the user and groups to impersonate for all requests to the server, for example to verify that
+// an environment can be managed with least-privilege RBAC profiles.
+type Impersonation struct {
+	User   string   `json:"user"`             // the user to impersonate
+	UID    string   `json:"uid,omitempty"`    // the uid of the user to impersonate
+	Groups []string `json:"groups,omitempty"` // groups to impersonate
+}
+
+func (c *Credentials) assertValid() error {
+	if c.CertificateAuthority != "" && c.CertificateAuthorityData != "" {
+		return fmt.Errorf("only one of certificateAuthority or certificateAuthorityData may be set")
+	}
+	if c.CertificateAuthorityData != "" {
+		if _, err := base64.StdEncoding.DecodeString(c.CertificateAuthorityData); err != nil {
+			return fmt.Errorf("invalid certificateAuthorityData: %v", err)
+		}
+	}
+	var count int
+	for _, set := range []bool{c.TokenFile != "", c.TokenEnv != "", c.Exec != nil} {
+		if set {
+			count++
+		}
+	}
+	if count > 1 {
+		return fmt.Errorf("only one of tokenFile, tokenEnv or exec may be set")
+	}
+	return nil
+}
+
+// EvalSettings tunes component evaluation for an environment. Unset values fall back to app-level settings and
+// defaults. Values specified on the command line take precedence.
+type EvalSettings struct {
+	Concurrency        int    `json:"concurrency,omitempty"`        // concurrency with which to evaluate components
+	ComponentTimeout   string `json:"componentTimeout,omitempty"`   // maximum time to evaluate a single component
+	MaxDataSourceBytes *int64 `json:"maxDataSourceBytes,omitempty"` // maximum size of the output of a data source
+}
+
+// ClientSettings tunes the kubernetes client for an environment, for example to raise client-side rate limits for
+// large apps. Values specified on the command line take precedence.
+type ClientSettings struct {
+	QPS   int `json:"qps,omitempty"`   // queries per second allowed for the client, 0 for default
+	Burst int `json:"burst,omitempty"` // maximum burst of queries for the client, 0 for default
+}
+
+// AppTagRules restricts the app tags that may be used with an environment. Rules are glob patterns
+// matched against the tag. A tag is accepted if it matches at least one allow pattern (or no allow patterns are
+// specified) and does not match any deny pattern.
+type AppTagRules struct {
+	Allow []string `json:"allow,omitempty"` // patterns for allowed tags, all tags allowed when empty
+	Deny  []string `json:"deny,omitempty"`  // patterns for denied tags, takes precedence over allowed patterns
+}
+
+func (r *AppTagRules) assertValid() error {
+	for _, list := range [][]string{r.Allow, r.Deny} {
+		for _, p := range list {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid app tag pattern %q: %v", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+func matchesAny(patterns []string, tag string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, tag); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// accepts returns true if the supplied tag is accepted by the rules.
+func (r *AppTagRules) accepts(tag string) bool {
+	if r == nil {
+		return true
+	}
+	if len(r.Allow) > 0 && !matchesAny(r.Allow, tag) {
+		return false
+	}
+	return !matchesAny(r.Deny, tag)
+}
+
+// IsContextPattern returns true if the supplied kubeconfig context of an environment is a glob pattern.
+func IsContextPattern(context string) bool {
+	return strings.ContainsAny(context, "*?")
+}
+
+// ContextMatches returns true if the supplied kubeconfig context name matches the context of an environment.
+// The context of the environment may be a glob pattern where * matches any sequence of characters, including
+// path separators, and ? matches any single character.
+func ContextMatches(context, name string) bool {
+	if !IsContextPattern(context) {
+		return context == name
+	}
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range context {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String()).MatchString(name)
 }
 
 func (e Environment) assertValid() error {
@@ -43,6 +186,19 @@ func (e Environment) assertValid() error {
 	if strings.HasPrefix(e.Context, "__") { // do not allow context to be a keyword
 		return fmt.Errorf("context for environment ('%s') may not start with __", e.Context)
 	}
+	if e.AppTags != nil {
+		if err := e.AppTags.assertValid(); err != nil {
+			return err
+		}
+	}
+	if e.Credentials != nil {
+		if e.Server == "" {
+			return fmt.Errorf("credentials may only be set for environments that specify a server")
+		}
+		if err := e.Credentials.assertValid(); err != nil {
+			return fmt.Errorf("credentials: %v", err)
+		}
+	}
 	return nil
 }
 
@@ -71,6 +227,21 @@ type ComputedVar struct {
 	Code string `json:"code"` // inline code
 }
 
+// Timestamp policies for source annotations.
+const (
+	SourceTimestampNone   = "none"   // do not add a timestamp
+	SourceTimestampCommit = "commit" // use the commit time of the source
+	SourceTimestampRender = "render" // use the time at which objects were rendered
+)
+
+// SourceAnnotations controls the source metadata annotations that qbec adds to all objects.
+type SourceAnnotations struct {
+	// add source annotations to all objects
+	Enabled bool `json:"enabled,omitempty"`
+	// timestamp policy, one of "none", "commit" or "render". Defaults to "none".
+	Timestamp string `json:"timestamp,omitempty"`
+}
+
 // Variables is a collection of external and top-level variables.
 type Variables struct {
 	External []ExternalVar `json:"external,omitempty"` // collection of ext vars
@@ -88,9 +259,18 @@ type AppMeta struct {
 type AppSpec struct {
 	// directory containing component files, default to components/
 	ComponentsDir string `json:"componentsDir,omitempty"`
+	// when true, subdirectories of component directories that are not themselves components are treated as
+	// namespaces and the components in them are named <subdirectory>/<name>.
+	ComponentNamespaces bool `json:"componentNamespaces,omitempty"`
+	// when true, subdirectories of component directories are searched for components at any depth and the
+	// components found are named using their path relative to the component directory.
+	ComponentsDirRecurse bool `json:"componentsDirRecurse,omitempty"`
 	// standard file containing parameters for all environments returning correct values based on qbec.io/env external
 	// variable, defaults to params.libsonnet
 	ParamsFile string `json:"paramsFile,omitempty"`
+	// file containing jsonnet code that is evaluated before components, with its output made available as an
+	// external code variable named after the base name of the file, typically used to inject defaults.
+	PreProcessor string `json:"preProcessor,omitempty"`
 	// file containing jsonnet code that can be used to post-process all objects, typically adding metadata like
 	// annotations.
 	PostProcessor string `json:"postProcessor,omitempty"`
@@ -100,14 +280,30 @@ type AppSpec struct {
 	DataSources []string `json:"dataSources,omitempty"`
 	// example outputs for data sources for linter use
 	DataSourceExamples map[string]interface{} `json:"dsExamples,omitempty"`
+	// paths resolved by data sources when they are tested, keyed by data source name
+	DataSourceTestPaths map[string]string `json:"dsTestPaths,omitempty"`
 	// set of environments for the app
 	Environments map[string]Environment `json:"environments"`
 	// additional environments pulled in from external files
 	EnvFiles []string `json:"envFiles,omitempty"`
+	// providers that produce additional environments at runtime, loaded after environment files
+	EnvProviders []EnvProvider `json:"envProviders,omitempty"`
+	// when true, templates in the server, default namespace and properties of environments are expanded using
+	// the values of variables when the app is loaded.
+	Interpolate bool `json:"interpolate,omitempty"`
+	// files or URLs containing app fragments with vars, environments and excludes merged into the app
+	Includes []string `json:"includes,omitempty"`
 	// list of components to exclude by default for every environment
 	Excludes []string `json:"excludes,omitempty"`
 	// list of library paths to add to the jsonnet VM at evaluation
 	LibPaths []string `json:"libPaths,omitempty"`
+	// zip files containing jsonnet libraries keyed by library name, imported as qbec-lib/<name>/<path>
+	LibraryBundles map[string]string `json:"libraryBundles,omitempty"`
+	// directory containing libraries vendored by jsonnet-bundler, defaults to vendor. It is added to the library paths
+	// when the app has a jsonnetfile.json file.
+	VendorDir string `json:"vendorDir,omitempty"`
+	// base URLs from which jsonnet files are imported when they are not found locally
+	HTTPLibPaths []HTTPLibPath `json:"httpLibPaths,omitempty"`
 	// automatically suffix default namespace defined for environment when app-tag provided.
 	NamespaceTagSuffix bool `json:"namespaceTagSuffix,omitempty"`
 	// properties for the baseline environment, can be used to define what env properties should look like
@@ -117,6 +313,183 @@ type AppSpec struct {
 	ClusterScopedLists bool `json:"clusterScopedLists,omitempty"`
 	// add component name as label to Kubernetes objects, default to false
 	AddComponentLabel bool `json:"addComponentLabel,omitempty"`
+	// labels to add to all Kubernetes objects, in addition to the ones set by qbec
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+	// annotations to add to all Kubernetes objects, in addition to the ones set by qbec
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+	// maximum time allowed to evaluate a single component, specified as a duration string (e.g. "2m").
+	// No timeout is enforced when not specified.
+	ComponentTimeout string `json:"componentTimeout,omitempty"`
+	// show objects in the order in which they are emitted by components instead of sorting them.
+	// Does not affect the order in which objects are applied.
+	PreserveObjectOrder bool `json:"preserveObjectOrder,omitempty"`
+	// annotate all objects with source metadata like the git commit and qbec version.
+	AnnotateSource *SourceAnnotations `json:"annotateSource,omitempty"`
+	// versions to use as canonical versions for specific types, keyed by Kind.group (e.g. ClusterPolicy.kyverno.io).
+	// The canonical version determines how remote objects are matched to local objects for diffs and garbage collection.
+	CanonicalVersions map[string]string `json:"canonicalVersions,omitempty"`
+	// status expressions that determine when objects of specific types are ready, keyed by Kind.group
+	// (e.g. Certificate.cert-manager.io). Used when waiting for objects after they have been applied.
+	WaitStatus map[string]string `json:"waitStatus,omitempty"`
+	// additional configuration for components, keyed by component name.
+	Components map[string]ComponentSpec `json:"components,omitempty"`
+	// patches applied to matching objects after evaluation, in the order specified.
+	Transforms []Transform `json:"transforms,omitempty"`
+	// hooks that are run before and after objects are applied.
+	Hooks *Hooks `json:"hooks,omitempty"`
+	// fields of matching objects that are ignored when objects are diffed.
+	DiffIgnores []DiffIgnore `json:"diffIgnores,omitempty"`
+	// policies that objects produced by components are checked against.
+	Policies []Policy `json:"policies,omitempty"`
+	// fields of objects of specific types whose values are hidden in output, in addition to secret data.
+	Redactions []Redaction `json:"redactions,omitempty"`
+}
+
+// Hooks are lists of hooks that are run, in order, before and after objects are applied.
+type Hooks struct {
+	// hooks run after the user confirms changes and before any object is applied
+	PreApply []Hook `json:"preApply,omitempty"`
+	// hooks run after all objects have been applied and waited for, receiving the apply summary as JSON on stdin
+	PostApply []Hook `json:"postApply,omitempty"`
+}
+
+// Hook is a command or a jsonnet file producing objects, such as jobs, that are applied and waited for.
+// Exactly one of Exec or Jsonnet must be specified.
+type Hook struct {
+	// the name of the hook, unique within a phase
+	Name string `json:"name"`
+	// the command to run
+	Exec *ExecHook `json:"exec,omitempty"`
+	// a jsonnet or YAML file, relative to the qbec root, that produces the objects for the hook
+	Jsonnet string `json:"jsonnet,omitempty"`
+}
+
+// ExecHook is a command run as a hook from the qbec root directory.
+type ExecHook struct {
+	// the command to run, relative paths are resolved from the qbec root
+	Command string `json:"command"`
+	// arguments to the command
+	Args []string `json:"args,omitempty"`
+	// additional environment variables for the command
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// EnvProvider produces an environment map at runtime by running a command or fetching a URL.
+// Exactly one of Exec or URL must be specified.
+type EnvProvider struct {
+	// the name of the provider, unique within the app
+	Name string `json:"name"`
+	// the command that writes an environment map to standard output
+	Exec *ExecHook `json:"exec,omitempty"`
+	// the http(s) URL from which the environment map is fetched
+	URL string `json:"url,omitempty"`
+	// environment variable containing a bearer token sent with requests to the URL
+	TokenEnv string `json:"tokenEnv,omitempty"`
+	// maximum time allowed to run the command or fetch the URL, as a duration string, defaults to 30s
+	Timeout string `json:"timeout,omitempty"`
+	// duration for which the environment map is cached on disk and reused, no caching when not specified
+	CacheTTL string `json:"cacheTTL,omitempty"`
+}
+
+// TransformTarget selects the objects to which a transform is applied. Empty attributes match all objects.
+type TransformTarget struct {
+	// the kind of objects to match
+	Kind string `json:"kind,omitempty"`
+	// the name of objects to match
+	Name string `json:"name,omitempty"`
+	// the namespace of objects to match, as set by the component
+	Namespace string `json:"namespace,omitempty"`
+	// the component that produces the objects
+	Component string `json:"component,omitempty"`
+}
+
+// Matches returns true if the target selects the supplied object produced by the supplied component.
+func (t TransformTarget) Matches(component string, obj K8sMeta) bool {
+	switch {
+	case t.Kind != "" && t.Kind != obj.GetKind():
+		return false
+	case t.Name != "" && t.Name != obj.GetName():
+		return false
+	case t.Namespace != "" && t.Namespace != obj.GetNamespace():
+		return false
+	case t.Component != "" && t.Component != component:
+		return false
+	}
+	return true
+}
+
+// HTTPLibPath is a base URL from which jsonnet files are imported, with optional digests to pin file contents.
+type HTTPLibPath struct {
+	// the https base URL
+	URL string `json:"url"`
+	// hex encoded sha256 digests of files keyed by path relative to the URL
+	SHA256 map[string]string `json:"sha256,omitempty"`
+}
+
+// Transform is a patch that is applied to objects matching a target after they have been evaluated and post-processed.
+// Exactly one of Patch and JSONPatch must be specified.
+type Transform struct {
+	// the environments for which the transform is applied, all environments when not specified
+	Environments []string `json:"environments,omitempty"`
+	// the objects to which the transform is applied
+	Target TransformTarget `json:"target"`
+	// a strategic merge patch for the object, a JSON merge patch is used for types without strategic merge support
+	Patch map[string]interface{} `json:"patch,omitempty"`
+	// a list of JSON patch (RFC 6902) operations
+	JSONPatch []interface{} `json:"jsonPatch,omitempty"`
+}
+
+// DiffIgnore is a set of fields that are ignored when objects matching a target are diffed, typically because
+// they are managed by controllers in the cluster.
+type DiffIgnore struct {
+	// the environments for which the fields are ignored, all environments when not specified
+	Environments []string `json:"environments,omitempty"`
+	// the objects for which the fields are ignored, all objects when not specified
+	Target TransformTarget `json:"target,omitempty"`
+	// JSONPath expressions for the fields to ignore, for example .spec.replicas
+	Paths []string `json:"paths"`
+}
+
+// policy levels
+const (
+	PolicyDeny = "deny" // violations are errors
+	PolicyWarn = "warn" // violations are reported as warnings
+)
+
+// Policy is a check that objects matching a target must pass. The policy file is either a jsonnet file that is
+// evaluated with the object as the top-level variable named "object" and returns an array of violation messages,
+// which is empty when the object complies with the policy, or a rego file (with a .rego extension) whose deny rule
+// produces the violation messages for the object supplied as input.
+type Policy struct {
+	// the name of the policy, used in messages.
+	Name string `json:"name"`
+	// the jsonnet or rego file that checks an object.
+	File string `json:"file"`
+	// deny (the default) to fail for violations, warn to report them as warnings.
+	Level string `json:"level,omitempty"`
+	// the environments for which the policy is checked, all environments when not specified
+	Environments []string `json:"environments,omitempty"`
+	// the objects that are checked, all objects when not specified
+	Target TransformTarget `json:"target,omitempty"`
+}
+
+// Redaction is a set of fields whose values are hidden in diff, apply and show output for objects of a specific
+// type, typically because they embed credentials.
+type Redaction struct {
+	// the kind of objects, qualified by the API group for non-core types (e.g. ExternalSecret.external-secrets.io)
+	Kind string `json:"kind"`
+	// JSONPath expressions for the fields whose values are hidden, for example .spec.template.data
+	Paths []string `json:"paths"`
+}
+
+// ComponentSpec is additional configuration for a single component.
+type ComponentSpec struct {
+	// names of components that must be applied and ready before the component is applied.
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// JSON or YAML file containing a JSON schema that the parameters of the component are validated against.
+	ParamsSchema string `json:"paramsSchema,omitempty"`
+	// maximum time to evaluate the component, overrides the component timeout of the app and environment.
+	Timeout string `json:"timeout,omitempty"`
 }
 
 // QbecEnvironmentMapSpec is the spec for a QbecEnvironmentMap object.
@@ -140,6 +513,31 @@ type QbecEnvironmentMap struct {
 	Spec QbecEnvironmentMapSpec `json:"spec"`
 }
 
+// QbecAppFragmentSpec is the spec for a QbecAppFragment object.
+type QbecAppFragmentSpec struct {
+	// the interface for jsonnet variables
+	Vars Variables `json:"vars,omitempty"`
+	// set of environments, keyed by name
+	Environments map[string]Environment `json:"environments,omitempty"`
+	// list of components to exclude by default for every environment
+	Excludes []string `json:"excludes,omitempty"`
+}
+
+// QbecAppFragment is a partial app specification that is included by apps. It allows common variables,
+// environments and excludes to be shared across many apps.
+type QbecAppFragment struct {
+	// object kind
+	// required: true
+	// pattern: ^AppFragment$
+	Kind string `json:"kind"`
+	// requested API version
+	// required: true
+	APIVersion string `json:"apiVersion"`
+	// fragment spec
+	// required: true
+	Spec QbecAppFragmentSpec `json:"spec"`
+}
+
 // QbecApp is a set of components that can be applied to multiple environments with tweaked runtime configurations.
 // The list of all components for the app is derived as all the supported (jsonnet, json, yaml) files in the components subdirectory.
 // swagger:model App
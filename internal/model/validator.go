@@ -78,6 +78,15 @@ func (v *validator) validateYAML(content []byte) []error {
 }
 
 func (v *validator) validateEnvYAML(content []byte) []error {
+	return v.validateKind(content, "EnvironmentMap")
+}
+
+func (v *validator) validateFragmentYAML(content []byte) []error {
+	return v.validateKind(content, "AppFragment")
+}
+
+// validateKind validates the supplied content as an object of the supplied kind.
+func (v *validator) validateKind(content []byte, expectedKind string) []error {
 	wrap := func(err error) []error {
 		return []error{err}
 	}
@@ -93,8 +102,8 @@ func (v *validator) validateEnvYAML(content []byte) []error {
 	if !ok {
 		return wrap(fmt.Errorf("missing or invalid kind property"))
 	}
-	if kind != "EnvironmentMap" {
-		return wrap(fmt.Errorf("bad kind property, expected EnvironmentMap"))
+	if kind != expectedKind {
+		return wrap(fmt.Errorf("bad kind property, expected %s", expectedKind))
 	}
 
 	dataType := strings.Replace(apiVersion, "/", ".", -1) + "." + kind
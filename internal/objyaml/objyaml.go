@@ -0,0 +1,144 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package objyaml renders Kubernetes objects as YAML.
+package objyaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	yamlv2 "gopkg.in/yaml.v2"
+)
+
+// FieldOrder determines the order in which keys of maps are rendered.
+type FieldOrder string
+
+const (
+	// Alphabetical renders all keys in alphabetical order.
+	Alphabetical FieldOrder = "alpha"
+	// Kubectl renders the apiVersion, kind and metadata keys of objects first, and the well-known keys of the
+	// metadata in the order used by kubectl. All other keys are rendered in alphabetical order.
+	Kubectl FieldOrder = "kubectl"
+)
+
+// ParseFieldOrder returns the field order for the supplied string, which must be one of "alpha" or "kubectl".
+func ParseFieldOrder(s string) (FieldOrder, error) {
+	switch FieldOrder(s) {
+	case Alphabetical, Kubectl:
+		return FieldOrder(s), nil
+	default:
+		return "", fmt.Errorf("invalid field order %q, must be one of %q or %q", s, Alphabetical, Kubectl)
+	}
+}
+
+var (
+	objectKeys   = []string{"apiVersion", "kind", "metadata"}
+	metadataKeys = []string{"name", "generateName", "namespace", "labels", "annotations"}
+)
+
+// Marshal renders the supplied object as YAML using the supplied field order. The zero value of the field order
+// renders keys alphabetically.
+func Marshal(obj interface{}, order FieldOrder) ([]byte, error) {
+	if order != Kubectl {
+		return yaml.Marshal(obj)
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var data interface{}
+	if err := dec.Decode(&data); err != nil {
+		return nil, err
+	}
+	v, err := ordered(data, false)
+	if err != nil {
+		return nil, err
+	}
+	return yamlv2.Marshal(v)
+}
+
+// ordered returns a representation of the supplied JSON value where maps are replaced by map slices with keys
+// in the order in which they should be rendered. A map is treated as an object when it has both the apiVersion
+// and kind keys, such that objects nested in lists are ordered in the same way as top-level objects.
+func ordered(data interface{}, isMetadata bool) (interface{}, error) {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		_, hasVersion := v["apiVersion"]
+		_, hasKind := v["kind"]
+		isObject := hasVersion && hasKind
+		var priority []string
+		switch {
+		case isMetadata:
+			priority = metadataKeys
+		case isObject:
+			priority = objectKeys
+		}
+		rank := map[string]int{}
+		for i, k := range priority {
+			rank[k] = i + 1
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			ri, rj := rank[keys[i]], rank[keys[j]]
+			switch {
+			case ri > 0 && rj > 0:
+				return ri < rj
+			case ri > 0 || rj > 0:
+				return ri > 0
+			default:
+				return keys[i] < keys[j]
+			}
+		})
+		var ret yamlv2.MapSlice
+		for _, k := range keys {
+			child, err := ordered(v[k], isObject && k == "metadata")
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, yamlv2.MapItem{Key: k, Value: child})
+		}
+		if ret == nil {
+			return map[string]interface{}{}, nil
+		}
+		return ret, nil
+	case []interface{}:
+		ret := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			child, err := ordered(item, false)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, child)
+		}
+		return ret, nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		return v.Float64()
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,119 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package objyaml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testObject() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"ratio":    1.5,
+			"version":  "1.0",
+			"empty":    map[string]interface{}{},
+			"list":     []interface{}{"b", "a"},
+		},
+		"kind":       "Deployment",
+		"status":     nil,
+		"apiVersion": "apps/v1",
+		"metadata": map[string]interface{}{
+			"uid":         "1234",
+			"annotations": map[string]interface{}{"b": "1", "a": "2"},
+			"labels":      map[string]interface{}{"app": "foo"},
+			"namespace":   "ns",
+			"name":        "foo",
+		},
+	}}
+}
+
+func TestMarshalKubectl(t *testing.T) {
+	b, err := Marshal(testObject(), Kubectl)
+	require.NoError(t, err)
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: foo
+  namespace: ns
+  labels:
+    app: foo
+  annotations:
+    a: "2"
+    b: "1"
+  uid: "1234"
+spec:
+  empty: {}
+  list:
+  - b
+  - a
+  ratio: 1.5
+  replicas: 3
+  version: "1.0"
+status: null
+`, string(b))
+}
+
+func TestMarshalKubectlList(t *testing.T) {
+	list := map[string]interface{}{
+		"kind":       "List",
+		"apiVersion": "v1",
+		"items": []interface{}{
+			map[string]interface{}{
+				"data":       map[string]interface{}{"kind": "x"},
+				"metadata":   map[string]interface{}{"namespace": "ns", "name": "cm"},
+				"kind":       "ConfigMap",
+				"apiVersion": "v1",
+			},
+		},
+	}
+	b, err := Marshal(list, Kubectl)
+	require.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: cm
+    namespace: ns
+  data:
+    kind: x
+`, string(b))
+}
+
+func TestMarshalAlphabetical(t *testing.T) {
+	b, err := Marshal(testObject(), "")
+	require.NoError(t, err)
+	b2, err := Marshal(testObject(), Alphabetical)
+	require.NoError(t, err)
+	assert.Equal(t, string(b), string(b2))
+	assert.Contains(t, string(b), "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  annotations:")
+}
+
+func TestParseFieldOrder(t *testing.T) {
+	o, err := ParseFieldOrder("kubectl")
+	require.NoError(t, err)
+	assert.Equal(t, Kubectl, o)
+	_, err = ParseFieldOrder("random")
+	require.Error(t, err)
+	assert.Equal(t, `invalid field order "random", must be one of "alpha" or "kubectl"`, err.Error())
+}
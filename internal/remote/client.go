@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ghodss/yaml"
@@ -29,7 +30,9 @@ import (
 	"github.com/splunk/qbec/internal/model"
 	"github.com/splunk/qbec/internal/remote/k8smeta"
 	"github.com/splunk/qbec/internal/sio"
+	"github.com/splunk/qbec/internal/telemetry"
 	"github.com/splunk/qbec/internal/types"
+	"go.opentelemetry.io/otel/attribute"
 	apiErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -37,6 +40,7 @@ import (
 	apiTypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
 )
 
 const (
@@ -49,17 +53,32 @@ const (
 var (
 	ErrForbidden        = errors.New("forbidden")             // returned due to an authn/ authz error
 	ErrNotFound         = errors.New("not found")             // returned when a remote object does not exist
-	errMetadataNotFound = errors.New("server type not found") // returned when metadata could not be found for a gvk
+	ErrMetadataNotFound = errors.New("server type not found") // returned when metadata could not be found for a gvk
 )
 
 // this file contains the client definition and supported CRUD operations.
 
 // TypeWaitOptions are options for waiting on a custom type.
 type TypeWaitOptions struct {
+	NoWait  bool          // do not wait for applied custom resource definitions to be established
 	Timeout time.Duration // the total time to wait
 	Poll    time.Duration // poll interval
 }
 
+func (t TypeWaitOptions) timeout() time.Duration {
+	if t.Timeout == 0 {
+		return 2 * time.Minute
+	}
+	return t.Timeout
+}
+
+func (t TypeWaitOptions) poll() time.Duration {
+	if t.Poll == 0 {
+		return 2 * time.Second
+	}
+	return t.Poll
+}
+
 // ConditionFunc returns if a specific condition tests as true for the supplied object.
 type ConditionFunc func(obj model.K8sMeta) bool
 
@@ -70,12 +89,16 @@ type SyncOptions struct {
 	DisableUpdateFn ConditionFunc   // do not update an existing object
 	WaitOptions     TypeWaitOptions // opts for waiting
 	ShowSecrets     bool            // show secrets in patches and creations
+	ContentHash     bool            // stamp a content hash on objects and skip patching objects whose live hash matches
+	KeepReplicasFn  ConditionFunc   // keep the replica count of an existing object, for objects scaled by autoscalers
+	Retry           RetryPolicy     // retries for transient server errors, not used for objects with generated names
 }
 
 // DeleteOptions provides the caller with options for the delete operation.
 type DeleteOptions struct {
 	DryRun          bool          // do not actually delete, return what would happen
 	DisableDeleteFn ConditionFunc // test to see if deletion should be disabled.
+	Retry           RetryPolicy   // retries for transient server errors
 }
 
 type internalSyncOptions struct {
@@ -86,6 +109,7 @@ type internalSyncOptions struct {
 
 type resourceClient interface {
 	clientForGroupVersionKind(kind schema.GroupVersionKind) (dynamic.Interface, error)
+	clientWithWarnings(kind schema.GroupVersionKind, h rest.WarningHandler) (dynamic.Interface, error)
 }
 
 // Client is a thick remote client that provides high-level operations for commands as opposed to
@@ -97,11 +121,30 @@ type Client struct {
 	disco     k8smeta.ResourceDiscovery // the discovery interface
 	defaultNs string                    // the default namespace to set for namespaced objects that do not define one
 	verbosity int                       // log verbosity
+	pods      podLogger                 // the interface to stream pod logs
+	cluster   string                    // identifies the cluster for list caching
+	cache     *k8smeta.DiskCache        // the cache from which server metadata was loaded, nil when not cached
+	resOpts   k8smeta.ResourceOpts      // options with which server metadata is loaded
+
+	listOnce      sync.Once          // guards loading of listable resources
+	listResources *k8smeta.Resources // server metadata loaded from the server for listing, when cached metadata is in use
+	listErr       error              // error loading listable resources
 }
 
-func newClient(pool resourceClient, disco discovery.DiscoveryInterface, ns string, verbosity int) (*Client, error) {
+// newClient returns a client that loads server metadata using the supplied metadata discovery interface, which may
+// be cached, and uses the discovery interface to find the resources of types that have just been created.
+func newClient(pool resourceClient, disco discovery.DiscoveryInterface, meta k8smeta.Discovery, opts ConnectOpts) (*Client, error) {
 	start := time.Now()
-	resources, err := k8smeta.NewResources(disco, k8smeta.ResourceOpts{WarnFn: sio.Warnln})
+	ns, verbosity := opts.Namespace, opts.Verbosity
+	// resources for API groups are discovered only when types in those groups are referenced, which avoids loading
+	// every group version on clusters with a large number of CRDs when no listing is required. All groups are
+	// discovered when listing objects for garbage collection, and up front when verbose such that they can be dumped.
+	resOpts := k8smeta.ResourceOpts{
+		WarnFn:            sio.Warnln,
+		CanonicalVersions: opts.CanonicalVersions,
+		Lazy:              verbosity == 0,
+	}
+	resources, err := k8smeta.NewResources(meta, resOpts)
 	if err != nil {
 		return nil, errors.Wrap(err, "get server metadata")
 	}
@@ -111,7 +154,7 @@ func newClient(pool resourceClient, disco discovery.DiscoveryInterface, ns strin
 	duration := time.Since(start).Round(time.Millisecond)
 	sio.Debugln("cluster metadata load took", duration)
 
-	ss := k8smeta.NewServerSchema(disco)
+	ss := k8smeta.NewServerSchema(meta)
 	c := &Client{
 		resources: resources,
 		schema:    ss,
@@ -119,6 +162,12 @@ func newClient(pool resourceClient, disco discovery.DiscoveryInterface, ns strin
 		disco:     disco,
 		defaultNs: ns,
 		verbosity: verbosity,
+		cluster:   opts.ServerURL,
+		resOpts:   resOpts,
+	}
+	c.cache, _ = meta.(*k8smeta.DiskCache)
+	if opts.ForceContext != "" {
+		c.cluster = "context:" + opts.ForceContext
 	}
 	return c, nil
 }
@@ -133,7 +182,7 @@ func (c *Client) ValidatorFor(ctx context.Context, gvk schema.GroupVersionKind)
 // the default namespace when the object does not have one set. It does not fail if the
 // object type is not known and just returns whatever is specified for the object.
 func (c *Client) objectNamespace(o model.K8sMeta) string {
-	info := c.resources.APIResource(o.GroupVersionKind())
+	info, _ := c.apiResourceFor(o.GroupVersionKind())
 	ns := o.GetNamespace()
 	if info != nil {
 		if info.Namespaced {
@@ -149,9 +198,8 @@ func (c *Client) objectNamespace(o model.K8sMeta) string {
 
 // DisplayName returns the display name of the supplied K8s object.
 func (c *Client) DisplayName(o model.K8sMeta) string {
-	sm := c.resources
 	gvk := o.GroupVersionKind()
-	info := sm.APIResource(gvk)
+	info, _ := c.apiResourceFor(gvk)
 
 	displayType := func() string {
 		if info != nil {
@@ -180,6 +228,11 @@ func (c *Client) DisplayName(o model.K8sMeta) string {
 
 func (c *Client) apiResourceFor(gvk schema.GroupVersionKind) (*metav1.APIResource, error) {
 	info := c.resources.APIResource(gvk)
+	if info == nil && c.cache != nil { // the type may have been created after the metadata was cached
+		if res, err := c.jitResource(gvk); err == nil {
+			return res, nil
+		}
+	}
 	if info == nil {
 		return nil, fmt.Errorf("resource not found for %s/%s %s", gvk.Group, gvk.Version, gvk.Kind)
 	}
@@ -199,12 +252,28 @@ func (c *Client) canonicalGroupVersionKind(in schema.GroupVersionKind) (schema.G
 	return c.resources.CanonicalGroupVersionKind(in)
 }
 
+// objectAttributes returns the tracing attributes for the supplied object.
+func objectAttributes(obj model.K8sMeta) []attribute.KeyValue {
+	gvk := obj.GroupVersionKind()
+	return []attribute.KeyValue{
+		attribute.String("k8s.group", gvk.Group),
+		attribute.String("k8s.kind", gvk.Kind),
+		attribute.String("k8s.namespace", obj.GetNamespace()),
+		attribute.String("k8s.name", model.NameForDisplay(obj)),
+	}
+}
+
 // Get returns the remote object matching the supplied metadata as an unstructured bag of attributes.
+// For local objects with generated names that are tracked across applies, the previously created object
+// is returned.
 func (c *Client) Get(ctx context.Context, obj model.K8sMeta) (*unstructured.Unstructured, error) {
 	rc, err := c.resourceInterfaceWithDefaultNs(obj.GroupVersionKind(), obj.GetNamespace())
 	if err != nil {
 		return nil, err
 	}
+	if id := model.TrackedIdentity(obj); id != "" {
+		return findTracked(ctx, rc, obj.(model.K8sObject), id)
+	}
 	u, err := rc.Get(ctx, obj.GetName(), metav1.GetOptions{})
 	if err != nil {
 		if apiErrors.IsNotFound(err) {
@@ -241,14 +310,46 @@ type GVKFilter func(gvk schema.GroupVersionKind) bool
 
 // ListQueryConfig is the config with which to execute list queries.
 type ListQueryConfig struct {
-	Application        string    // must be non-blank
-	Tag                string    // may be blank
-	Environment        string    // must be non-blank
-	ListQueryScope               // the query scope for namespaces and non-namespaced resources
-	KindFilter         GVKFilter // filters for group version kind
-	Concurrency        int       // concurrent queries to execute
-	ClusterScopedLists bool      // perform list queries across namespaces when multiple namespaces in picture
-	Limit              int64     // chunk limit for query
+	Application        string       // must be non-blank
+	Tag                string       // may be blank
+	Environment        string       // must be non-blank
+	ListQueryScope                  // the query scope for namespaces and non-namespaced resources
+	KindFilter         GVKFilter    // filters for group version kind
+	Concurrency        int          // concurrent queries to execute
+	ClusterScopedLists bool         // perform list queries across namespaces when multiple namespaces in picture
+	Limit              int64        // chunk limit for query
+	Foreign            bool         // list objects managed by qbec for other apps, tags or environments instead
+	Cache              *ListCache   // optional cache for list results, all kinds are listed when set
+	ConsumeCache       bool         // remove the cached results once used, for commands that change the cluster
+	IncludeOwned       bool         // include objects that have a controller owner reference
+	Observer           ListObserver // optional observer notified of the progress of list queries
+}
+
+// ListObserver is notified of the progress of the list queries executed for a single list query config.
+type ListObserver interface {
+	// Start is called with the number of list queries that will be executed.
+	Start(queries int)
+	// Done is called, possibly concurrently, when the list query for the supplied type and namespace completes.
+	Done(gvk schema.GroupVersionKind, namespace string, objects int, elapsed time.Duration, err error)
+}
+
+// LabelSelector returns the label selector for list queries.
+func (s ListQueryConfig) LabelSelector() string {
+	if s.Foreign {
+		return model.QbecNames.ApplicationLabel
+	}
+	ls := fmt.Sprintf("%s=%s,%s=%s", model.QbecNames.ApplicationLabel, s.Application, model.QbecNames.EnvironmentLabel, s.Environment)
+	if s.Tag == "" {
+		return fmt.Sprintf("%s,!%s", ls, model.QbecNames.TagLabel)
+	}
+	return fmt.Sprintf("%s,%s=%s", ls, model.QbecNames.TagLabel, s.Tag)
+}
+
+// isOwn returns true if the supplied labels are those of objects managed by the app, tag and environment of the query.
+func (s ListQueryConfig) isOwn(labels map[string]string) bool {
+	return labels[model.QbecNames.ApplicationLabel] == s.Application &&
+		labels[model.QbecNames.EnvironmentLabel] == s.Environment &&
+		labels[model.QbecNames.TagLabel] == s.Tag
 }
 
 // Collection represents a set of k8s objects with the ability to remove a subset of objects from it.
@@ -259,10 +360,21 @@ type Collection interface {
 
 // ListObjects returns all objects for the application and environment for the namespace /cluster scopes
 // and kind filtering indicated by the query configuration.
-func (c *Client) ListObjects(ctx context.Context, scope ListQueryConfig) (Collection, error) {
+func (c *Client) ListObjects(ctx context.Context, scope ListQueryConfig) (_ Collection, finalErr error) {
+	ctx, span := telemetry.Start(ctx, "list objects",
+		attribute.StringSlice("qbec.namespaces", scope.Namespaces),
+		attribute.Bool("qbec.cluster_objects", scope.ClusterObjects),
+	)
+	defer func() { telemetry.End(span, finalErr) }()
 	if scope.KindFilter == nil {
 		scope.KindFilter = func(_ schema.GroupVersionKind) bool { return true }
 	}
+	if scope.Cache != nil {
+		if coll, ok := c.cachedObjects(ctx, scope); ok {
+			span.SetAttributes(attribute.Bool("qbec.cached", true))
+			return coll, nil
+		}
+	}
 
 	// handle special cases
 	filterEligibleTypes := func(types []schema.GroupVersionKind) []schema.GroupVersionKind {
@@ -273,7 +385,7 @@ func (c *Client) ListObjects(ctx context.Context, scope ListQueryConfig) (Collec
 			// propagates its own labels to it. These have not been created by qbec.
 			case t.Group == "" && t.Kind == "Endpoints":
 				if c.verbosity > 0 {
-					sio.Debugf("not listing objects of type %v\n", t)
+					sio.For(ctx).Debugf("not listing objects of type %v\n", t)
 				}
 			default:
 				ret = append(ret, t)
@@ -282,8 +394,12 @@ func (c *Client) ListObjects(ctx context.Context, scope ListQueryConfig) (Collec
 		return ret
 	}
 
+	resources, err := c.listableResources()
+	if err != nil {
+		return nil, err
+	}
 	var namespacedTypes, clusterTypes []schema.GroupVersionKind
-	for _, v := range c.resources.CanonicalResources() {
+	for _, v := range resources.CanonicalResources() {
 		gvk := schema.GroupVersionKind{Group: v.Group, Version: v.Version, Kind: v.Kind}
 		if v.Namespaced {
 			namespacedTypes = append(namespacedTypes, gvk)
@@ -292,6 +408,12 @@ func (c *Client) ListObjects(ctx context.Context, scope ListQueryConfig) (Collec
 		}
 	}
 
+	// when caching, all kinds are listed such that the results can be used regardless of kind filters, and the
+	// filter is applied to the results instead.
+	kindFilter := scope.KindFilter
+	if scope.Cache != nil {
+		scope.KindFilter = func(_ schema.GroupVersionKind) bool { return true }
+	}
 	qc := queryConfig{
 		scope:            scope,
 		resourceProvider: c.ResourceInterface,
@@ -304,9 +426,60 @@ func (c *Client) ListObjects(ctx context.Context, scope ListQueryConfig) (Collec
 	if err := ol.serverObjects(ctx, coll); err != nil {
 		return nil, err
 	}
+	if scope.Cache != nil {
+		if !scope.ConsumeCache {
+			var objects []cachedObject
+			for _, o := range coll.objects {
+				objects = append(objects, toCachedObject(o.(*basicObject)))
+			}
+			if err := scope.Cache.put(scope.cacheKey(c.cluster), objects); err != nil {
+				sio.For(ctx).Warnln("unable to update list cache:", err)
+			}
+		}
+		coll.filter(kindFilter)
+	}
 	return coll, nil
 }
 
+// listableResources returns the server metadata that determines the types listed for garbage collection. Cached
+// metadata may miss types created after it was cached, whose objects would then never be collected, and may contain
+// types that no longer exist, which would fail the listing. So the metadata is loaded from the server when a cache
+// is in use, and the cache is updated with it.
+func (c *Client) listableResources() (*k8smeta.Resources, error) {
+	if c.cache == nil {
+		return c.resources, nil
+	}
+	c.listOnce.Do(func() {
+		opts := c.resOpts
+		opts.Lazy = false
+		c.listResources, c.listErr = k8smeta.NewResources(c.cache.Refresh(), opts)
+		if c.listErr != nil {
+			c.listErr = errors.Wrap(c.listErr, "get server metadata")
+		}
+	})
+	return c.listResources, c.listErr
+}
+
+// cachedObjects returns a collection of cached objects for the supplied query config, if available.
+func (c *Client) cachedObjects(ctx context.Context, scope ListQueryConfig) (*collection, bool) {
+	objects, ok, err := scope.Cache.get(scope.cacheKey(c.cluster), scope.ConsumeCache)
+	if err != nil {
+		sio.For(ctx).Warnln("unable to use list cache:", err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+	sio.For(ctx).Debugln("using cached list results from", scope.Cache.File())
+	coll := newCollection(c.defaultNs, c)
+	for _, o := range objects {
+		bo := o.toBasicObject()
+		coll.objects[bo.objectKey] = bo
+	}
+	coll.filter(scope.KindFilter)
+	return coll, true
+}
+
 type updateResult struct {
 	SkipReason    string             `json:"skip,omitempty"`
 	Operation     string             `json:"operation,omitempty"`
@@ -366,6 +539,15 @@ const (
 	SyncDeleted                         // object was deleted
 )
 
+// syncResultNames are the names of sync result types used for tracing.
+var syncResultNames = map[SyncResultType]string{
+	SyncObjectsIdentical: "identical",
+	SyncSkip:             "skipped",
+	SyncCreated:          "created",
+	SyncUpdated:          "updated",
+	SyncDeleted:          "deleted",
+}
+
 // SyncResult is the result of a sync operation. There is no difference in the output for a real versus
 // a dry-run.
 type SyncResult struct {
@@ -374,20 +556,12 @@ type SyncResult struct {
 	Details       string         // additional details that are safe to print to console (e.g. no secrets)
 }
 
-func (c *Client) ensureType(gvk schema.GroupVersionKind, opts SyncOptions) error {
+func (c *Client) ensureType(ctx context.Context, gvk schema.GroupVersionKind, opts SyncOptions) error {
 	if _, err := c.apiResourceFor(gvk); err == nil {
 		return nil
 	}
-	waitTime := opts.WaitOptions.Timeout
-	if waitTime == 0 {
-		waitTime = 2 * time.Minute
-	}
+	waitTime := opts.WaitOptions.timeout()
 	end := time.Now().Add(waitTime)
-
-	waitPoll := opts.WaitOptions.Poll
-	if waitPoll == 0 {
-		waitPoll = 2 * time.Second
-	}
 	first := true
 	for {
 		_, err := c.jitResource(gvk)
@@ -396,18 +570,20 @@ func (c *Client) ensureType(gvk schema.GroupVersionKind, opts SyncOptions) error
 		}
 		if first {
 			first = false
-			sio.Noticef("waiting for type %s to be available for up to %s\n", gvk, waitTime.Round(time.Second))
+			sio.For(ctx).Noticef("waiting for type %s to be available for up to %s\n", gvk, waitTime.Round(time.Second))
 		}
 		if time.Now().After(end) {
 			return err
 		}
-		time.Sleep(waitPoll)
+		time.Sleep(opts.WaitOptions.poll())
 	}
 }
 
 // Sync syncs the local object by either creating a new one or patching an existing one.
 // It does not do anything in dry-run mode. It also does not create new objects if the caller has disabled the feature.
 func (c *Client) Sync(ctx context.Context, original model.K8sLocalObject, opts SyncOptions) (_ *SyncResult, finalError error) {
+	ctx, span := telemetry.Start(ctx, "sync object", objectAttributes(original)...)
+	defer func() { telemetry.End(span, finalError) }()
 	// set up the pristine strategy.
 	var prw pristineReadWriter = qbecPristine{}
 	sensitive := types.HasSensitiveInfo(original.ToUnstructured())
@@ -429,50 +605,95 @@ func (c *Client) Sync(ctx context.Context, original model.K8sLocalObject, opts S
 	}()
 
 	if !opts.DryRun {
-		if err := c.ensureType(original.GroupVersionKind(), opts); err != nil {
+		if err := c.ensureType(ctx, original.GroupVersionKind(), opts); err != nil {
 			return nil, err
 		}
 	}
 
-	result, err := c.doSync(ctx, original, opts, internal)
+	// syncs are only retried when they are idempotent, which is not the case for objects with server-generated
+	// names since a create that failed in flight may still have created an object. Tracked objects are looked up
+	// by their identity on every attempt, such that an object created by a failed attempt is found and updated.
+	retry := opts.Retry
+	if original.GetName() == "" && model.TrackedIdentity(original) == "" {
+		retry = RetryPolicy{}
+	}
+	op := "sync " + c.DisplayName(original)
+	var result *updateResult
+	err := retry.run(ctx, op, func() (err error) {
+		result, err = c.doSync(ctx, original, opts, internal)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// exit if we are done
-	if !internal.secretDryRun || opts.DryRun {
-		return result.toSyncResult(), nil
-	}
-	internal.secretDryRun = false
-	_, err = c.doSync(ctx, original, opts, internal) // do the real sync
-	if err != nil {
-		return nil, err
+	if internal.secretDryRun && !opts.DryRun {
+		internal.secretDryRun = false
+		err = retry.run(ctx, op, func() error {
+			_, err := c.doSync(ctx, original, opts, internal) // do the real sync
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return result.toSyncResult(), err
+	ret := result.toSyncResult()
+	span.SetAttributes(attribute.String("qbec.result", syncResultNames[ret.Type]))
+	if !opts.DryRun && !opts.WaitOptions.NoWait && isCRD(original) && (ret.Type == SyncCreated || ret.Type == SyncUpdated) {
+		if err := c.waitForCRD(ctx, original, opts.WaitOptions); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
 }
 
 func (c *Client) doSync(ctx context.Context, original model.K8sLocalObject, opts SyncOptions, internal internalSyncOptions) (*updateResult, error) {
 	var remObj *unstructured.Unstructured
 	var objErr error
-	if original.GetName() != "" {
+	tracked := model.TrackedIdentity(original) != ""
+	if original.GetName() != "" || tracked {
 		remObj, objErr = c.Get(ctx, original)
 	}
 	switch {
 	// empty name, always create
-	case original.GetName() == "":
+	case original.GetName() == "" && !tracked:
 		break
 	// ignore object not found errors
 	case objErr == ErrNotFound:
 		break
 	// treat metadata errors (server type not found) as a "not found" error if dry-run mode is active
-	case objErr == errMetadataNotFound && opts.DryRun:
+	case objErr == ErrMetadataNotFound && opts.DryRun:
 		break
 	// report all other errors
 	case objErr != nil:
 		return nil, errors.Wrap(objErr, "get object")
 	}
 
+	// update the previously generated object for tracked objects, if one exists
+	generatedName := ""
+	if tracked && remObj != nil {
+		generatedName = remObj.GetName()
+		original = withGeneratedName(original, generatedName)
+	}
+
+	if opts.ContentHash {
+		hash, err := contentHash(original.ToUnstructured())
+		if err != nil {
+			return nil, errors.Wrap(err, "content hash")
+		}
+		if remObj != nil && remObj.GetAnnotations()[model.QbecNames.ContentHash] == hash {
+			return &updateResult{SkipReason: identicalObjects}, nil
+		}
+		// the hash is stamped before the pristine version is created such that turning off the option
+		// removes the annotation from the live object.
+		original = stampContentHash(original, hash)
+	}
+
+	if remObj != nil && opts.KeepReplicasFn != nil && opts.KeepReplicasFn(original) {
+		original = withReplicas(original, remObj)
+	}
+
 	var obj model.K8sLocalObject
 	if internal.secretDryRun {
 		opts.DryRun = true // won't affect caller since passed by value
@@ -506,6 +727,9 @@ func (c *Client) doSync(ctx context.Context, original model.K8sLocalObject, opts
 	if err != nil {
 		return nil, err
 	}
+	if generatedName != "" {
+		result.GeneratedName = generatedName
+	}
 
 	// create a prettier patch for display, if needed
 	result.DisplayPatch = string(result.patch)
@@ -523,6 +747,8 @@ func (c *Client) doSync(ctx context.Context, original model.K8sLocalObject, opts
 
 // Delete delete the supplied object if it exists. It does not do anything in dry-run mode.
 func (c *Client) Delete(ctx context.Context, obj model.K8sMeta, opts DeleteOptions) (_ *SyncResult, finalError error) {
+	ctx, span := telemetry.Start(ctx, "delete object", objectAttributes(obj)...)
+	defer func() { telemetry.End(span, finalError) }()
 	if opts.DisableDeleteFn(obj) {
 		upr := &updateResult{
 			SkipReason: "deletion disabled due to user request",
@@ -547,7 +773,9 @@ func (c *Client) Delete(ctx context.Context, obj model.K8sMeta, opts DeleteOptio
 	}
 
 	pp := metav1.DeletePropagationForeground
-	err = ri.Delete(ctx, obj.GetName(), metav1.DeleteOptions{PropagationPolicy: &pp})
+	err = opts.Retry.run(ctx, "delete "+c.DisplayName(obj), func() error {
+		return ri.Delete(ctx, obj.GetName(), metav1.DeleteOptions{PropagationPolicy: &pp})
+	})
 	if err != nil {
 		if apiErrors.IsNotFound(err) {
 			ret.Type = SyncSkip
@@ -589,11 +817,16 @@ func (c *Client) ResourceInterface(gvk schema.GroupVersionKind, namespace string
 	if err != nil {
 		return nil, err
 	}
+	return c.resourceInterfaceFor(client, gvk, namespace)
+}
+
+// resourceInterfaceFor returns the resource interface for the supplied type and namespace using the supplied client.
+func (c *Client) resourceInterfaceFor(client dynamic.Interface, gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
 	res, err := c.apiResourceFor(gvk)
 	if err != nil { // could be a resource for a CRD that was just created, re-query discovery
 		res, err = c.jitResource(gvk)
 		if err != nil {
-			return nil, errMetadataNotFound
+			return nil, ErrMetadataNotFound
 		}
 	}
 	base := client.Resource(schema.GroupVersionResource{
@@ -639,16 +872,136 @@ func (c *Client) maybeCreate(ctx context.Context, obj model.K8sLocalObject, opts
 	if err != nil {
 		return nil, errors.Wrap(err, "get resource interface")
 	}
-	out, err := ri.Create(ctx, obj.ToUnstructured(), metav1.CreateOptions{})
+	if obj.GetName() != "" {
+		if _, err := ri.Create(ctx, obj.ToUnstructured(), metav1.CreateOptions{}); err != nil {
+			return nil, errors.Wrap(err, "create object")
+		}
+		return result, nil
+	}
+	out, err := createWithGeneratedName(ctx, ri, obj.ToUnstructured(), clockwork.NewRealClock())
 	if err != nil {
 		return nil, errors.Wrap(err, "create object")
 	}
-	if obj.GetName() == "" {
-		result.GeneratedName = out.GetName()
-	}
+	result.GeneratedName = out.GetName()
 	return result, nil
 }
 
+// withGeneratedName returns a copy of the supplied local object that has the supplied name instead of a
+// generated name.
+func withGeneratedName(obj model.K8sLocalObject, name string) model.K8sLocalObject {
+	u := obj.ToUnstructured().DeepCopy()
+	u.SetName(name)
+	u.SetGenerateName("")
+	return model.NewK8sLocalObject(u.Object, model.LocalAttrs{
+		App:       obj.Application(),
+		Tag:       obj.Tag(),
+		Component: obj.Component(),
+		Env:       obj.Environment(),
+	})
+}
+
+// withReplicas returns a copy of the supplied object with the replica count of the supplied live object, if both have
+// one. The last applied configuration then records the live count such that it is not reverted by later applies.
+func withReplicas(obj model.K8sLocalObject, live *unstructured.Unstructured) model.K8sLocalObject {
+	replicas, found, err := unstructured.NestedFieldNoCopy(live.Object, "spec", "replicas")
+	if err != nil || !found {
+		return obj
+	}
+	if _, found, _ := unstructured.NestedFieldNoCopy(obj.ToUnstructured().Object, "spec", "replicas"); !found {
+		return obj
+	}
+	u := obj.ToUnstructured().DeepCopy()
+	if err := unstructured.SetNestedField(u.Object, replicas, "spec", "replicas"); err != nil {
+		return obj
+	}
+	return model.NewK8sLocalObject(u.Object, model.LocalAttrs{
+		App:       obj.Application(),
+		Tag:       obj.Tag(),
+		Component: obj.Component(),
+		Env:       obj.Environment(),
+	})
+}
+
+// findTracked returns the object previously created for the supplied local object with a generated name, looking
+// it up by its identity and qbec labels. When more than one such object exists, the most recently created one
+// is returned.
+func findTracked(ctx context.Context, ri dynamic.ResourceInterface, obj model.K8sObject, id string) (*unstructured.Unstructured, error) {
+	labels := obj.ToUnstructured().GetLabels()
+	selector := fmt.Sprintf("%s=%s,%s=%s,%s=%s", model.QbecNames.IdentityLabel, id,
+		model.QbecNames.ApplicationLabel, labels[model.QbecNames.ApplicationLabel],
+		model.QbecNames.EnvironmentLabel, labels[model.QbecNames.EnvironmentLabel])
+	if tag := labels[model.QbecNames.TagLabel]; tag != "" {
+		selector += fmt.Sprintf(",%s=%s", model.QbecNames.TagLabel, tag)
+	} else {
+		selector += ",!" + model.QbecNames.TagLabel
+	}
+	list, err := ri.List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		if apiErrors.IsForbidden(err) {
+			return nil, ErrForbidden
+		}
+		return nil, err
+	}
+	var ret *unstructured.Unstructured
+	for i := range list.Items {
+		item := &list.Items[i]
+		if item.GetDeletionTimestamp() != nil {
+			continue
+		}
+		if ret == nil || ret.GetCreationTimestamp().Time.Before(item.GetCreationTimestamp().Time) {
+			ret = item
+		}
+	}
+	if ret == nil {
+		return nil, ErrNotFound
+	}
+	if len(list.Items) > 1 {
+		sio.For(ctx).Warnf("found %d objects for %s, using the most recent one %s\n", len(list.Items), model.NameForDisplay(obj), ret.GetName())
+	}
+	return ret, nil
+}
+
+// retry settings for creating objects with generated names.
+var (
+	generateNameRetries = 5
+	generateNameBackoff = 250 * time.Millisecond
+)
+
+// isNameCollision returns true if the supplied error from a create call for an object with a generated name
+// means that the generated name collided with an existing object. Collisions are reported by the server as
+// conflicts or, by older servers, as server timeouts, and no object is created in that case.
+func isNameCollision(err error) bool {
+	return apiErrors.IsAlreadyExists(err) || apiErrors.IsServerTimeout(err)
+}
+
+// createWithGeneratedName creates an object that has generateName set, retrying with exponential backoff
+// when the generated name collides with an existing object. A new name is generated on every attempt. Other
+// failures are never retried since a create that failed in flight may still have created an object, and
+// retrying it would create a duplicate.
+func createWithGeneratedName(ctx context.Context, ri dynamic.ResourceInterface, obj *unstructured.Unstructured, clock clockwork.Clock) (*unstructured.Unstructured, error) {
+	delay := generateNameBackoff
+	for attempt := 1; ; attempt++ {
+		out, err := ri.Create(ctx, obj, metav1.CreateOptions{})
+		if err == nil {
+			return out, nil
+		}
+		if attempt > generateNameRetries || !isNameCollision(err) {
+			return nil, err
+		}
+		wait := delay
+		if secs, ok := apiErrors.SuggestsClientDelay(err); ok && time.Duration(secs)*time.Second > wait {
+			wait = time.Duration(secs) * time.Second
+		}
+		sio.For(ctx).Warnf("create %s<xxxxx> failed (attempt %d), retrying in %v: %v\n", obj.GetGenerateName(), attempt, wait, err)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-clock.After(wait):
+		}
+		delay *= 2
+	}
+}
+
 func (c *Client) maybeUpdate(ctx context.Context, obj model.K8sLocalObject, remObj *unstructured.Unstructured, opts SyncOptions) (*updateResult, error) {
 	if opts.DisableUpdateFn(model.NewK8sObject(remObj.Object)) {
 		return &updateResult{
@@ -0,0 +1,211 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote/k8smeta"
+	"github.com/splunk/qbec/internal/sio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCreateWithGeneratedName(t *testing.T) {
+	origBackoff := generateNameBackoff
+	generateNameBackoff = time.Millisecond
+	defer func() { generateNameBackoff = origBackoff }()
+
+	gvr := schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}
+	job := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata":   map[string]interface{}{"namespace": "ns", "generateName": "job-"},
+	}}
+
+	tests := []struct {
+		name     string
+		failures []error
+		calls    int
+		err      string
+	}{
+		{
+			name:  "no failures",
+			calls: 1,
+		},
+		{
+			name: "collisions",
+			failures: []error{
+				apiErrors.NewAlreadyExists(gvr.GroupResource(), "job-abcde"),
+				apiErrors.NewServerTimeout(gvr.GroupResource(), "create", 0),
+			},
+			calls: 3,
+		},
+		{
+			name:     "ambiguous failure",
+			failures: []error{apiErrors.NewInternalError(fmt.Errorf("connection reset"))},
+			calls:    1,
+			err:      `Internal error occurred: connection reset`,
+		},
+		{
+			name:     "gateway timeout",
+			failures: []error{apiErrors.NewTimeoutError("request timed out", 0)},
+			calls:    1,
+			err:      `Timeout: request timed out`,
+		},
+		{
+			name:     "non-retriable",
+			failures: []error{apiErrors.NewForbidden(gvr.GroupResource(), "", fmt.Errorf("denied"))},
+			calls:    1,
+			err:      `jobs.batch is forbidden: denied`,
+		},
+		{
+			name: "too many collisions",
+			failures: []error{
+				apiErrors.NewAlreadyExists(gvr.GroupResource(), "job-1"),
+				apiErrors.NewAlreadyExists(gvr.GroupResource(), "job-2"),
+				apiErrors.NewAlreadyExists(gvr.GroupResource(), "job-3"),
+				apiErrors.NewAlreadyExists(gvr.GroupResource(), "job-4"),
+				apiErrors.NewAlreadyExists(gvr.GroupResource(), "job-5"),
+				apiErrors.NewAlreadyExists(gvr.GroupResource(), "job-6"),
+			},
+			calls: 6,
+			err:   `jobs.batch "job-6" already exists`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dc := fake.NewSimpleDynamicClient(runtime.NewScheme())
+			calls := 0
+			dc.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				calls++
+				if calls <= len(test.failures) {
+					return true, nil, test.failures[calls-1]
+				}
+				obj := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured).DeepCopy()
+				obj.SetName(fmt.Sprintf("%s%d", obj.GetGenerateName(), calls))
+				return true, obj, nil
+			})
+			out, err := createWithGeneratedName(context.Background(), dc.Resource(gvr).Namespace("ns"), job, clockwork.NewRealClock())
+			assert.Equal(t, test.calls, calls)
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, fmt.Sprintf("job-%d", calls), out.GetName())
+		})
+	}
+}
+
+func TestWithReplicas(t *testing.T) {
+	local := func(spec map[string]interface{}) model.K8sLocalObject {
+		return model.NewK8sLocalObject(map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "web"},
+			"spec":       spec,
+		}, model.LocalAttrs{App: "app", Component: "web", Env: "dev"})
+	}
+	live := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"replicas": int64(5)},
+	}}
+	a := assert.New(t)
+
+	obj := local(map[string]interface{}{"replicas": int64(2)})
+	out := withReplicas(obj, live)
+	replicas, _, _ := unstructured.NestedInt64(out.ToUnstructured().Object, "spec", "replicas")
+	a.EqualValues(5, replicas)
+	a.Equal("web", out.Component())
+	replicas, _, _ = unstructured.NestedInt64(obj.ToUnstructured().Object, "spec", "replicas")
+	a.EqualValues(2, replicas)
+
+	obj = local(map[string]interface{}{})
+	a.Equal(obj, withReplicas(obj, live))
+
+	obj = local(map[string]interface{}{"replicas": int64(2)})
+	a.Equal(obj, withReplicas(obj, &unstructured.Unstructured{Object: map[string]interface{}{}}))
+}
+
+func TestListableResourcesRefreshCache(t *testing.T) {
+	verbs := metav1.Verbs{"create", "delete", "get", "list"}
+	disco := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "configmaps", Namespaced: true, Kind: "ConfigMap", Verbs: verbs}}},
+	}}}
+	cache := k8smeta.NewDiskCache(&openAPIV3Discovery{DiscoveryInterface: disco}, t.TempDir(), time.Hour)
+	resources, err := k8smeta.NewResources(cache, k8smeta.ResourceOpts{})
+	require.NoError(t, err)
+
+	// a CRD is installed after the metadata was cached
+	disco.Resources = append(disco.Resources, &metav1.APIResourceList{
+		GroupVersion: "example.com/v1",
+		APIResources: []metav1.APIResource{{Name: "widgets", Namespaced: true, Kind: "Widget", Verbs: verbs}},
+	})
+	widget := schema.GroupKind{Group: "example.com", Kind: "Widget"}
+	c := &Client{resources: resources, cache: cache}
+	_, ok := c.resources.CanonicalResources()[widget]
+	assert.False(t, ok)
+	listable, err := c.listableResources()
+	require.NoError(t, err)
+	_, ok = listable.CanonicalResources()[widget]
+	assert.True(t, ok)
+
+	// the cache is updated for subsequent invocations
+	resources, err = k8smeta.NewResources(cache, k8smeta.ResourceOpts{})
+	require.NoError(t, err)
+	_, ok = resources.CanonicalResources()[widget]
+	assert.True(t, ok)
+
+	c = &Client{resources: resources}
+	listable, err = c.listableResources()
+	require.NoError(t, err)
+	assert.Same(t, resources, listable)
+}
+
+func TestEnsureTypeWaitsWithoutCRDWait(t *testing.T) {
+	var buf bytes.Buffer
+	orig := sio.Output
+	defer func() { sio.Output = orig }()
+	sio.Output = &buf
+
+	disco := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{}}
+	resources, err := k8smeta.NewResources(disco, k8smeta.ResourceOpts{})
+	require.NoError(t, err)
+	c := &Client{resources: resources, disco: disco}
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+	// types of objects being synced are waited for even when waiting for applied CRDs is disabled
+	err = c.ensureType(context.Background(), gvk, SyncOptions{
+		WaitOptions: TypeWaitOptions{NoWait: true, Timeout: 10 * time.Millisecond, Poll: time.Millisecond},
+	})
+	require.Error(t, err)
+	assert.Contains(t, buf.String(), "waiting for type example.com/v1, Kind=Widget to be available")
+}
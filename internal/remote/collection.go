@@ -41,6 +41,7 @@ type basicObject struct {
 	component string
 	env       string
 	anns      map[string]string
+	identity  string // identity label for objects with tracked generated names
 }
 
 func (b *basicObject) Application() string               { return b.app }
@@ -101,24 +102,50 @@ func (c *collection) add(object model.K8sQbecMeta) error {
 }
 
 // Remove removes objects from its internal collection for each
-// matching object supplied.
+// matching object supplied. Objects with generated names that are tracked across applies
+// match remote objects that have the same identity.
 func (c *collection) Remove(objs []model.K8sQbecMeta) error {
 	sub := newCollection(c.defaultNs, c.meta)
+	tracked := map[objectKey]bool{}
 	for _, o := range objs {
+		if id := model.TrackedIdentity(o); id != "" {
+			gvk, err := c.meta.canonicalGroupVersionKind(o.GroupVersionKind())
+			if err != nil {
+				return err
+			}
+			tracked[objectKey{gvk: gvk, namespace: c.meta.objectNamespace(o), name: id}] = true
+			continue
+		}
 		if err := sub.add(o); err != nil {
 			return err
 		}
 	}
 	retainedSet := map[objectKey]model.K8sQbecMeta{}
 	for k, v := range c.objects {
-		if _, ok := sub.objects[k]; !ok {
-			retainedSet[k] = v
+		if _, ok := sub.objects[k]; ok {
+			continue
+		}
+		if b, ok := v.(*basicObject); ok && b.identity != "" && tracked[objectKey{gvk: k.gvk, namespace: k.namespace, name: b.identity}] {
+			continue
 		}
+		retainedSet[k] = v
 	}
 	c.objects = retainedSet
 	return nil
 }
 
+// filter removes objects whose group version kind does not match the supplied filter, if any.
+func (c *collection) filter(fn GVKFilter) {
+	if fn == nil {
+		return
+	}
+	for k := range c.objects {
+		if !fn(k.gvk) {
+			delete(c.objects, k)
+		}
+	}
+}
+
 // ToList returns the list of objects in this collection in arbitrary order.
 func (c *collection) ToList() []model.K8sQbecMeta {
 	var ret []model.K8sQbecMeta
@@ -0,0 +1,80 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package remote
+
+import (
+	"testing"
+
+	"github.com/splunk/qbec/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type identityMeta struct {
+	defaultNs string
+}
+
+func (m identityMeta) objectNamespace(obj model.K8sMeta) string {
+	if obj.GetNamespace() == "" {
+		return m.defaultNs
+	}
+	return obj.GetNamespace()
+}
+
+func (m identityMeta) canonicalGroupVersionKind(in schema.GroupVersionKind) (schema.GroupVersionKind, error) {
+	return in, nil
+}
+
+func TestCollectionRemoveTracked(t *testing.T) {
+	newLocal := func(namespace string) model.K8sLocalObject {
+		md := map[string]interface{}{
+			"generateName": "cm-",
+			"annotations": map[string]interface{}{
+				model.QbecNames.Directives.GenerateNamePolicy: model.GenerateNamePolicyTrack,
+			},
+		}
+		if namespace != "" {
+			md["namespace"] = namespace
+		}
+		return model.NewK8sLocalObject(map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   md,
+		}, model.LocalAttrs{App: "app", Component: "c1", Env: "env"})
+	}
+	local := newLocal("")
+	id := model.TrackedIdentity(local)
+	require.NotEqual(t, "", id)
+
+	coll := newCollection("default", identityMeta{defaultNs: "default"})
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	add := func(ns, name, identity string) {
+		key := objectKey{gvk: gvk, namespace: ns, name: name}
+		coll.objects[key] = &basicObject{objectKey: key, app: "app", env: "env", identity: identity}
+	}
+	add("default", "cm-abcde", id)
+	add("other", "cm-fghij", id)
+	add("default", "cm-klmno", "")
+
+	require.NoError(t, coll.Remove([]model.K8sQbecMeta{local}))
+	var names []string
+	for _, o := range coll.ToList() {
+		names = append(names, o.GetNamespace()+"/"+o.GetName())
+	}
+	assert.ElementsMatch(t, []string{"other/cm-fghij", "default/cm-klmno"}, names)
+}
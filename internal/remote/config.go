@@ -18,17 +18,27 @@
 package remote
 
 import (
+	"encoding/base64"
 	"fmt"
+	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote/k8smeta"
 	"github.com/splunk/qbec/internal/sio"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 // Constants for special context values.
@@ -45,17 +55,28 @@ type ConnectOpts struct {
 	Namespace    string // the default namespace to set for the context
 	Verbosity    int    // verbosity of client interactions
 	ForceContext string // __incluster__ or __current or named context
+	// versions to use as canonical versions for specific types
+	CanonicalVersions map[schema.GroupKind]string
+	// credentials to connect to the server without a kubeconfig, ignored when a context is forced
+	Credentials *model.Credentials
+	// user and groups to impersonate, overridden by impersonation flags on the command line
+	Impersonate *model.Impersonation
+	QPS         int // QPS for the client, overridden by the client-qps flag, 0 for default
+	Burst       int // burst for the client, overridden by the client-burst flag, 0 for default
 }
 
 // Config provides clients for specific contexts out of a kubeconfig file, with overrides for auth.
 type Config struct {
-	loadingRules *clientcmd.ClientConfigLoadingRules
-	overrides    *clientcmd.ConfigOverrides
-	l            sync.Mutex
-	kubeconfig   clientcmd.ClientConfig
-	qps          int
-	burst        int
-	ListPageSize int64
+	loadingRules    *clientcmd.ClientConfigLoadingRules
+	overrides       *clientcmd.ConfigOverrides
+	l               sync.Mutex
+	kubeconfig      clientcmd.ClientConfig
+	qps             int
+	burst           int
+	ListPageSize    int64
+	ListConcurrency int
+	cacheTTL        time.Duration
+	noCache         bool
 }
 
 // NewConfig returns a new configuration, adding flags to the supplied command to set k8s access overrides, prefixed by
@@ -71,6 +92,9 @@ func NewConfig(cmd *cobra.Command, prefix string) *Config {
 	cmd.PersistentFlags().IntVar(&cfg.qps, prefix+"client-qps", 0, "QPS to use for K8s client, 0 for default")
 	cmd.PersistentFlags().IntVar(&cfg.burst, prefix+"client-burst", 0, "Burst to use for K8s client, 0 for default")
 	cmd.PersistentFlags().Int64Var(&cfg.ListPageSize, prefix+"list-page-size", 1000, "Maximum number of responses per page to return for a list call. 0 for no limit")
+	cmd.PersistentFlags().DurationVar(&cfg.cacheTTL, prefix+"discovery-cache-ttl", 10*time.Minute, "Maximum age of server resources and OpenAPI schemas cached on disk")
+	cmd.PersistentFlags().BoolVar(&cfg.noCache, prefix+"no-discovery-cache", false, "Load server resources and OpenAPI schemas from the server instead of the disk cache")
+	cmd.PersistentFlags().IntVar(&cfg.ListConcurrency, prefix+"list-concurrency", 5, "Number of list calls to execute concurrently when listing objects of all types")
 	clientcmd.BindOverrideFlags(overrides, cmd.PersistentFlags(), clientcmd.ConfigOverrideFlags{
 		AuthOverrideFlags: clientcmd.RecommendedAuthOverrideFlags(prefix),
 		Timeout: clientcmd.FlagInfo{
@@ -121,9 +145,9 @@ func (c *Config) setupOverrides(opts ConnectOpts) error {
 			return err
 		}
 	default: // assume named context
-		wantCtx := opts.ForceContext
-		if _, ok := rc.Contexts[wantCtx]; !ok {
-			return fmt.Errorf("attempt to use context %s, but no such context was found", wantCtx)
+		wantCtx, err := resolveContext(rc.Contexts, opts.ForceContext)
+		if err != nil {
+			return err
 		}
 		sio.Warnf("force context %s\n", wantCtx)
 		overrideCtx(wantCtx)
@@ -131,32 +155,177 @@ func (c *Config) setupOverrides(opts ConnectOpts) error {
 	return nil
 }
 
-func (c *Config) withQPS(cfg *rest.Config) *rest.Config {
+// resolveContext returns the name of the context in the supplied contexts that matches the wanted context,
+// which may be a glob pattern. A pattern must match exactly one context.
+func resolveContext(contexts map[string]*clientcmdapi.Context, want string) (string, error) {
+	if _, ok := contexts[want]; ok {
+		return want, nil
+	}
+	if !model.IsContextPattern(want) {
+		return "", fmt.Errorf("attempt to use context %s, but no such context was found", want)
+	}
+	var matches []string
+	for name := range contexts {
+		if model.ContextMatches(want, name) {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("attempt to use context pattern %s, but no matching context was found", want)
+	case 1:
+		sio.Noticef("context pattern %s matched %s\n", want, matches[0])
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("context pattern %s matches multiple contexts: %s", want, strings.Join(matches, ", "))
+	}
+}
+
+// withRateLimits sets the client-side rate limits for the supplied config. Values specified on the command line
+// take precedence over those of the environment. The burst is never lower than the QPS and client defaults are
+// used for unset values.
+func (c *Config) withRateLimits(cfg *rest.Config, opts ConnectOpts) *rest.Config {
 	q := c.qps
+	if q == 0 {
+		q = opts.QPS
+	}
 	b := c.burst
+	if b == 0 {
+		b = opts.Burst
+	}
 	if b < q {
 		b = q
 	}
 	if q > 0 {
 		cfg.QPS = float32(q)
+	}
+	if b > 0 {
 		cfg.Burst = b
 	}
 	return cfg
 }
 
+// defaultExecAPIVersion is the API version used for exec credential plugins when none is specified.
+const defaultExecAPIVersion = "client.authentication.k8s.io/v1beta1"
+
+// useCredentials returns true if the connection should use the credentials in the options instead of a kubeconfig.
+func useCredentials(opts ConnectOpts) bool {
+	return opts.Credentials != nil && opts.ForceContext == ""
+}
+
+// credentialsRESTConfig returns a REST config for the server URL in the supplied options that uses the
+// credentials in the options without consulting any kubeconfig file.
+func (c *Config) credentialsRESTConfig(opts ConnectOpts) (*rest.Config, error) {
+	creds := opts.Credentials
+	cfg := &rest.Config{
+		Host: opts.ServerURL,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAFile:   creds.CertificateAuthority,
+			Insecure: creds.InsecureSkipTLSVerify,
+		},
+		BearerTokenFile: creds.TokenFile,
+	}
+	if creds.CertificateAuthorityData != "" {
+		b, err := base64.StdEncoding.DecodeString(creds.CertificateAuthorityData)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode certificate authority data")
+		}
+		cfg.TLSClientConfig.CAData = b
+	}
+	if creds.TokenEnv != "" {
+		token := os.Getenv(creds.TokenEnv)
+		if token == "" {
+			return nil, fmt.Errorf("environment variable %s for the bearer token of env %s is not set", creds.TokenEnv, opts.EnvName)
+		}
+		cfg.BearerToken = token
+	}
+	if creds.Exec != nil {
+		apiVersion := creds.Exec.APIVersion
+		if apiVersion == "" {
+			apiVersion = defaultExecAPIVersion
+		}
+		var names []string
+		for k := range creds.Exec.Env {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		var env []clientcmdapi.ExecEnvVar
+		for _, name := range names {
+			env = append(env, clientcmdapi.ExecEnvVar{Name: name, Value: creds.Exec.Env[name]})
+		}
+		cfg.ExecProvider = &clientcmdapi.ExecConfig{
+			APIVersion:         apiVersion,
+			Command:            creds.Exec.Command,
+			Args:               creds.Exec.Args,
+			Env:                env,
+			InstallHint:        creds.Exec.InstallHint,
+			ProvideClusterInfo: creds.Exec.ProvideClusterInfo,
+			InteractiveMode:    clientcmdapi.NeverExecInteractiveMode,
+		}
+	}
+	if t := c.overrides.Timeout; t != "" && t != "0" {
+		// durations without units are seconds, as for the kubeconfig timeout
+		if _, err := strconv.Atoi(t); err == nil {
+			t += "s"
+		}
+		timeout, err := time.ParseDuration(t)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse request timeout")
+		}
+		cfg.Timeout = timeout
+	}
+	return cfg, nil
+}
+
+// withImpersonation sets up impersonation for the supplied config. Impersonation flags on the command line take
+// precedence over the impersonation settings of the environment which, in turn, take precedence over those in
+// the kubeconfig.
+func (c *Config) withImpersonation(cfg *rest.Config, opts ConnectOpts) *rest.Config {
+	auth := c.overrides.AuthInfo
+	switch {
+	case auth.Impersonate != "" || auth.ImpersonateUID != "" || len(auth.ImpersonateGroups) > 0:
+		cfg.Impersonate = rest.ImpersonationConfig{
+			UserName: auth.Impersonate,
+			UID:      auth.ImpersonateUID,
+			Groups:   auth.ImpersonateGroups,
+		}
+	case opts.Impersonate != nil:
+		cfg.Impersonate = rest.ImpersonationConfig{
+			UserName: opts.Impersonate.User,
+			UID:      opts.Impersonate.UID,
+			Groups:   opts.Impersonate.Groups,
+		}
+	}
+	if cfg.Impersonate.UserName != "" {
+		msg := fmt.Sprintf("impersonate user %s", cfg.Impersonate.UserName)
+		if len(cfg.Impersonate.Groups) > 0 {
+			msg += fmt.Sprintf(" with groups %s", strings.Join(cfg.Impersonate.Groups, ", "))
+		}
+		sio.Noticeln(msg)
+	}
+	return cfg
+}
+
 func (c *Config) getRESTConfig(opts ConnectOpts) (*rest.Config, error) {
-	if opts.ForceContext == ForceInClusterContext {
+	var restConfig *rest.Config
+	var err error
+	switch {
+	case opts.ForceContext == ForceInClusterContext:
 		sio.Warnln("force in-cluster config")
-		return rest.InClusterConfig()
-	}
-	if err := c.setupOverrides(opts); err != nil {
-		return nil, err
+		restConfig, err = rest.InClusterConfig()
+	case useCredentials(opts):
+		restConfig, err = c.credentialsRESTConfig(opts)
+	default:
+		if err := c.setupOverrides(opts); err != nil {
+			return nil, err
+		}
+		restConfig, err = c.kubeconfig.ClientConfig()
 	}
-	restConfig, err := c.kubeconfig.ClientConfig()
 	if err != nil {
 		return nil, err
 	}
-	return c.withQPS(restConfig), nil
+	return c.withImpersonation(c.withRateLimits(restConfig, opts), opts), nil
 }
 
 // KubeAttributes is a collection k8s attributes pertaining to an connection.
@@ -169,6 +338,12 @@ type KubeAttributes struct {
 
 // KubeAttributes returns client attributes for the supplied connection options.
 func (c *Config) KubeAttributes(opts ConnectOpts) (*KubeAttributes, error) {
+	if useCredentials(opts) {
+		return &KubeAttributes{
+			Cluster:   opts.ServerURL,
+			Namespace: opts.Namespace,
+		}, nil
+	}
 	if err := c.setupOverrides(opts); err != nil {
 		return nil, err
 	}
@@ -186,7 +361,8 @@ func (c *Config) KubeAttributes(opts ConnectOpts) (*KubeAttributes, error) {
 
 // Client returns a client that correctly points to the server as specified in the connection options.
 // For this to work correctly, the kubernetes config that is used *must* have a cluster that has the supplied
-// server URL as an endpoint, so that correct TLS certs are used for authenticating the server.
+// server URL as an endpoint, so that correct TLS certs are used for authenticating the server. The kubernetes
+// config is not used when the options have credentials and no context is forced.
 func (c *Config) Client(opts ConnectOpts) (*Client, error) {
 	c.l.Lock()
 	defer c.l.Unlock()
@@ -199,7 +375,53 @@ func (c *Config) Client(opts ConnectOpts) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newClient(newResourceClient(conf), disco, opts.Namespace, opts.Verbosity)
+	client, err := newClient(newResourceClient(conf), disco, c.metadataDiscovery(conf, disco), opts)
+	if err != nil {
+		return nil, err
+	}
+	client.pods, err = newPodLogger(conf)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// discoveryCacheDir returns the directory under which server metadata is cached.
+func discoveryCacheDir() (string, error) {
+	if dir := os.Getenv("QBEC_CACHE_DIR"); dir != "" {
+		return filepath.Join(dir, "discovery"), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.Wrap(err, "get user cache dir")
+	}
+	return filepath.Join(dir, "qbec", "discovery"), nil
+}
+
+var unsafeCacheChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// metadataDiscovery returns the discovery interface used to load server resources and schemas. Unless caching
+// has been turned off, this is a disk cache keyed by the server URL and version, such that an upgrade of the
+// server does not use stale metadata.
+func (c *Config) metadataDiscovery(conf *rest.Config, disco discovery.DiscoveryInterface) k8smeta.Discovery {
+	meta := &openAPIV3Discovery{DiscoveryInterface: disco}
+	if c.noCache || c.cacheTTL <= 0 {
+		return meta
+	}
+	dir, err := discoveryCacheDir()
+	if err != nil {
+		sio.Debugln("disable discovery cache:", err)
+		return meta
+	}
+	v, err := disco.ServerVersion()
+	if err != nil {
+		sio.Debugln("disable discovery cache, unable to get server version:", err)
+		return meta
+	}
+	host := strings.TrimPrefix(strings.TrimPrefix(conf.Host, "https://"), "http://")
+	dir = filepath.Join(dir, unsafeCacheChars.ReplaceAllString(host, "_"), unsafeCacheChars.ReplaceAllString(v.GitVersion, "_"))
+	sio.Debugln("using discovery cache", dir)
+	return k8smeta.NewDiskCache(meta, dir, c.cacheTTL)
 }
 
 // ContextInfo has information we care about a K8s context
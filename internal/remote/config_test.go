@@ -20,10 +20,18 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/remote/k8smeta"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 var (
@@ -107,6 +115,47 @@ func TestConfigResolution(t *testing.T) {
 				assert.Equal(t, `attempt to use context garbage, but no such context was found`, err.Error())
 			},
 		},
+		{
+			name:       "context pattern",
+			kubeconfig: mainKubeConfig,
+			opts: ConnectOpts{
+				EnvName:      "first",
+				ServerURL:    "https://xxx-server",
+				ForceContext: "*v2",
+				Namespace:    "xxx",
+			},
+			assertFn: func(c *Config, err error) {
+				require.Nil(t, err)
+				assert.Equal(t, "dev2", c.overrides.CurrentContext)
+				assert.Equal(t, "dev2", c.overrides.Context.Cluster)
+			},
+		},
+		{
+			name:       "ambiguous context pattern",
+			kubeconfig: mainKubeConfig,
+			opts: ConnectOpts{
+				EnvName:      "first",
+				ServerURL:    "https://xxx-server",
+				ForceContext: "dev?",
+			},
+			assertFn: func(c *Config, err error) {
+				require.NotNil(t, err)
+				assert.Equal(t, `context pattern dev? matches multiple contexts: dev1, dev2`, err.Error())
+			},
+		},
+		{
+			name:       "unmatched context pattern",
+			kubeconfig: mainKubeConfig,
+			opts: ConnectOpts{
+				EnvName:      "first",
+				ServerURL:    "https://xxx-server",
+				ForceContext: "prod-*",
+			},
+			assertFn: func(c *Config, err error) {
+				require.NotNil(t, err)
+				assert.Equal(t, `attempt to use context pattern prod-*, but no matching context was found`, err.Error())
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -122,3 +171,154 @@ func TestConfigResolution(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigCredentials(t *testing.T) {
+	os.Setenv("KUBECONFIG", filepath.Join("testdata", "kube", "non-existent.yaml"))
+	defer os.Unsetenv("KUBECONFIG")
+	os.Setenv("QBEC_TEST_TOKEN", "secret-token")
+	defer os.Unsetenv("QBEC_TEST_TOKEN")
+	c := &Config{
+		loadingRules: clientcmd.NewDefaultClientConfigLoadingRules(),
+		overrides:    &clientcmd.ConfigOverrides{Timeout: "30"},
+		qps:          50,
+	}
+	opts := ConnectOpts{
+		EnvName:   "ci",
+		ServerURL: "https://ci-server",
+		Namespace: "ci",
+		Credentials: &model.Credentials{
+			CertificateAuthorityData: "Y2EtZGF0YQ==",
+			TokenEnv:                 "QBEC_TEST_TOKEN",
+		},
+	}
+	rc, err := c.getRESTConfig(opts)
+	require.NoError(t, err)
+	assert.Equal(t, "https://ci-server", rc.Host)
+	assert.Equal(t, []byte("ca-data"), rc.TLSClientConfig.CAData)
+	assert.Equal(t, "secret-token", rc.BearerToken)
+	assert.Nil(t, rc.ExecProvider)
+	assert.Equal(t, 30*time.Second, rc.Timeout)
+	assert.EqualValues(t, 50, rc.QPS)
+
+	attrs, err := c.KubeAttributes(opts)
+	require.NoError(t, err)
+	assert.Equal(t, KubeAttributes{Cluster: "https://ci-server", Namespace: "ci"}, *attrs)
+
+	opts.Credentials = &model.Credentials{
+		CertificateAuthority: "/path/to/ca.pem",
+		Exec: &model.ExecCredentials{
+			Command: "get-token",
+			Args:    []string{"--cluster", "ci"},
+			Env:     map[string]string{"B": "2", "A": "1"},
+		},
+	}
+	rc, err = c.getRESTConfig(opts)
+	require.NoError(t, err)
+	assert.Equal(t, "/path/to/ca.pem", rc.TLSClientConfig.CAFile)
+	assert.Equal(t, "", rc.BearerToken)
+	require.NotNil(t, rc.ExecProvider)
+	assert.Equal(t, "client.authentication.k8s.io/v1beta1", rc.ExecProvider.APIVersion)
+	assert.Equal(t, "get-token", rc.ExecProvider.Command)
+	assert.Equal(t, []string{"--cluster", "ci"}, rc.ExecProvider.Args)
+	assert.Equal(t, []clientcmdapi.ExecEnvVar{{Name: "A", Value: "1"}, {Name: "B", Value: "2"}}, rc.ExecProvider.Env)
+	assert.Equal(t, clientcmdapi.NeverExecInteractiveMode, rc.ExecProvider.InteractiveMode)
+
+	opts.Credentials = &model.Credentials{TokenEnv: "QBEC_TEST_NO_SUCH_TOKEN"}
+	_, err = c.getRESTConfig(opts)
+	require.Error(t, err)
+	assert.Equal(t, "environment variable QBEC_TEST_NO_SUCH_TOKEN for the bearer token of env ci is not set", err.Error())
+
+	// forcing a context ignores the credentials and uses the kubeconfig
+	opts.ForceContext = "dev1"
+	_, err = c.getRESTConfig(opts)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "attempt to use context dev1, but no such context was found")
+}
+
+func TestConfigImpersonation(t *testing.T) {
+	c := &Config{
+		loadingRules: clientcmd.NewDefaultClientConfigLoadingRules(),
+		overrides:    &clientcmd.ConfigOverrides{},
+	}
+	opts := ConnectOpts{
+		EnvName:     "ci",
+		ServerURL:   "https://ci-server",
+		Credentials: &model.Credentials{InsecureSkipTLSVerify: true},
+		Impersonate: &model.Impersonation{User: "deployer", Groups: []string{"ci-deployers"}},
+	}
+	rc, err := c.getRESTConfig(opts)
+	require.NoError(t, err)
+	assert.Equal(t, rest.ImpersonationConfig{UserName: "deployer", Groups: []string{"ci-deployers"}}, rc.Impersonate)
+
+	c.overrides.AuthInfo.Impersonate = "admin"
+	c.overrides.AuthInfo.ImpersonateGroups = []string{"system:masters"}
+	rc, err = c.getRESTConfig(opts)
+	require.NoError(t, err)
+	assert.Equal(t, rest.ImpersonationConfig{UserName: "admin", Groups: []string{"system:masters"}}, rc.Impersonate)
+
+	os.Setenv("KUBECONFIG", mainKubeConfig)
+	defer os.Unsetenv("KUBECONFIG")
+	c = &Config{
+		loadingRules: clientcmd.NewDefaultClientConfigLoadingRules(),
+		overrides:    &clientcmd.ConfigOverrides{},
+	}
+	opts = ConnectOpts{
+		EnvName:     "first",
+		ServerURL:   "https://dev1-server",
+		Impersonate: &model.Impersonation{User: "viewer"},
+	}
+	rc, err = c.getRESTConfig(opts)
+	require.NoError(t, err)
+	assert.Equal(t, rest.ImpersonationConfig{UserName: "viewer"}, rc.Impersonate)
+}
+
+func TestConfigRateLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		flagQPS   int
+		flagBurst int
+		opts      ConnectOpts
+		qps       float32
+		burst     int
+	}{
+		{name: "defaults"},
+		{name: "env", opts: ConnectOpts{QPS: 50, Burst: 100}, qps: 50, burst: 100},
+		{name: "env burst too low", opts: ConnectOpts{QPS: 50, Burst: 10}, qps: 50, burst: 50},
+		{name: "env burst only", opts: ConnectOpts{Burst: 20}, burst: 20},
+		{name: "flags win", flagQPS: 200, flagBurst: 400, opts: ConnectOpts{QPS: 50, Burst: 100}, qps: 200, burst: 400},
+		{name: "flag qps only", flagQPS: 200, opts: ConnectOpts{QPS: 50, Burst: 100}, qps: 200, burst: 200},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &Config{qps: test.flagQPS, burst: test.flagBurst}
+			rc := c.withRateLimits(&rest.Config{}, test.opts)
+			assert.Equal(t, test.qps, rc.QPS)
+			assert.Equal(t, test.burst, rc.Burst)
+		})
+	}
+}
+
+func TestConfigMetadataDiscovery(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("QBEC_CACHE_DIR", dir)
+	defer os.Unsetenv("QBEC_CACHE_DIR")
+	disco := &fakediscovery.FakeDiscovery{
+		Fake:               &k8stesting.Fake{},
+		FakedServerVersion: &version.Info{GitVersion: "v1.22.3+k3s1"},
+	}
+	conf := &rest.Config{Host: "https://10.0.0.1:6443"}
+
+	c := &Config{cacheTTL: time.Minute}
+	cached, ok := c.metadataDiscovery(conf, disco).(*k8smeta.DiskCache)
+	require.True(t, ok)
+	_, err := cached.ServerResourcesForGroupVersion("v1")
+	require.Error(t, err) // no resources in the fake
+	_, err = cached.ServerGroups()
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "discovery", "10.0.0.1_6443", "v1.22.3_k3s1", "servergroups.json"))
+
+	c = &Config{cacheTTL: time.Minute, noCache: true}
+	assert.Equal(t, &openAPIV3Discovery{DiscoveryInterface: disco}, c.metadataDiscovery(conf, disco))
+	c = &Config{}
+	assert.Equal(t, &openAPIV3Discovery{DiscoveryInterface: disco}, c.metadataDiscovery(conf, disco))
+}
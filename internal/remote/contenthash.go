@@ -0,0 +1,73 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/model"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// withoutContentHash returns the supplied object with the content hash annotation removed. The input is returned
+// as-is when it does not have the annotation.
+func withoutContentHash(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	anns := obj.GetAnnotations()
+	if _, ok := anns[model.QbecNames.ContentHash]; !ok {
+		return obj
+	}
+	ret := obj.DeepCopy()
+	anns = ret.GetAnnotations()
+	delete(anns, model.QbecNames.ContentHash)
+	if len(anns) == 0 {
+		anns = nil
+	}
+	ret.SetAnnotations(anns)
+	return ret
+}
+
+// contentHash returns the hex-encoded SHA256 hash of the normalized JSON representation of the supplied object,
+// ignoring any content hash annotation that it may already have.
+func contentHash(obj *unstructured.Unstructured) (string, error) {
+	// JSON serialization of maps sorts keys, which makes the output stable for the same content.
+	b, err := json.Marshal(withoutContentHash(obj).Object)
+	if err != nil {
+		return "", errors.Wrap(err, "json marshal")
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// stampContentHash returns a copy of the supplied object with the content hash annotation set to the supplied value.
+func stampContentHash(obj model.K8sLocalObject, hash string) model.K8sLocalObject {
+	u := obj.ToUnstructured().DeepCopy()
+	anns := u.GetAnnotations()
+	if anns == nil {
+		anns = map[string]string{}
+	}
+	anns[model.QbecNames.ContentHash] = hash
+	u.SetAnnotations(anns)
+	return model.NewK8sLocalObject(u.Object, model.LocalAttrs{
+		App:       obj.Application(),
+		Tag:       obj.Tag(),
+		Component: obj.Component(),
+		Env:       obj.Environment(),
+	})
+}
@@ -0,0 +1,86 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package remote
+
+import (
+	"testing"
+
+	"github.com/splunk/qbec/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestContentHash(t *testing.T) {
+	a := assert.New(t)
+	un := loadFile(t, "input.yaml")
+	h1, err := contentHash(un)
+	require.NoError(t, err)
+	a.Len(h1, 64)
+
+	// key order does not matter
+	clone := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	for k, v := range un.DeepCopy().Object {
+		clone.Object[k] = v
+	}
+	h2, err := contentHash(clone)
+	require.NoError(t, err)
+	a.Equal(h1, h2)
+
+	// stamping the hash does not change it
+	obj := model.NewK8sLocalObject(un.Object, model.LocalAttrs{App: "app", Component: "comp1", Env: "dev"})
+	h1, err = contentHash(obj.ToUnstructured())
+	require.NoError(t, err)
+	stamped := stampContentHash(obj, h1)
+	a.Equal(h1, stamped.ToUnstructured().GetAnnotations()[model.QbecNames.ContentHash])
+	a.Equal("app", stamped.Application())
+	a.Equal("comp1", stamped.Component())
+	a.Equal("", obj.ToUnstructured().GetAnnotations()[model.QbecNames.ContentHash])
+	h3, err := contentHash(stamped.ToUnstructured())
+	require.NoError(t, err)
+	a.Equal(h1, h3)
+
+	// changing the content does
+	changed := un.DeepCopy()
+	changed.SetLabels(map[string]string{"foo": "bar"})
+	h4, err := contentHash(changed)
+	require.NoError(t, err)
+	a.NotEqual(h1, h4)
+}
+
+func TestContentHashPristineForDiff(t *testing.T) {
+	a := assert.New(t)
+	un := loadFile(t, "input.yaml")
+	obj := model.NewK8sLocalObject(un.Object, model.LocalAttrs{App: "app", Component: "comp1", Env: "dev"})
+	h, err := contentHash(obj.ToUnstructured())
+	require.NoError(t, err)
+	annotated, err := qbecPristine{}.createFromPristine(stampContentHash(obj, h))
+	require.NoError(t, err)
+	live := annotated.ToUnstructured()
+
+	p, _ := GetPristineVersionForDiff(live)
+	require.NotNil(t, p)
+	a.EqualValues(obj.ToUnstructured().Object, p.Object)
+
+	p, _ = GetPristineVersionFromAnnotations(live.GetAnnotations())
+	require.NotNil(t, p)
+	a.EqualValues(obj.ToUnstructured().Object, p.Object)
+
+	p = GetPristineVersionForRollback(live)
+	require.NotNil(t, p)
+	a.Equal(h, p.GetAnnotations()[model.QbecNames.ContentHash])
+}
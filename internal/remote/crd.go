@@ -0,0 +1,126 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/splunk/qbec/internal/model"
+	"github.com/splunk/qbec/internal/sio"
+	"github.com/splunk/qbec/internal/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var crdGroupKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+func isCRD(obj model.K8sMeta) bool {
+	return obj.GroupVersionKind().GroupKind() == crdGroupKind
+}
+
+// crdTypes returns the group version kinds served by the supplied custom resource definition.
+func crdTypes(crd *unstructured.Unstructured) []schema.GroupVersionKind {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	if group == "" || kind == "" {
+		return nil
+	}
+	var ret []schema.GroupVersionKind
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, v := range versions {
+		vm, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(vm, "name")
+		served, found, _ := unstructured.NestedBool(vm, "served")
+		if name == "" || (found && !served) {
+			continue
+		}
+		ret = append(ret, schema.GroupVersionKind{Group: group, Version: name, Kind: kind})
+	}
+	if len(ret) == 0 { // v1beta1 definitions with a single version
+		if version, _, _ := unstructured.NestedString(crd.Object, "spec", "version"); version != "" {
+			ret = append(ret, schema.GroupVersionKind{Group: group, Version: version, Kind: kind})
+		}
+	}
+	return ret
+}
+
+// crdReady returns true if the named custom resource definition is established and all the types it serves
+// are available in discovery. When it is not ready, a description of what is pending is returned.
+func crdReady(ctx context.Context, ri dynamic.ResourceInterface, lookup func(gvk schema.GroupVersionKind) (*metav1.APIResource, error), name string) (bool, string, error) {
+	crd, err := ri.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return false, "", err
+	}
+	status, err := types.StatusFuncFor(crd)(crd, 0)
+	if err != nil {
+		return false, "", err
+	}
+	if !status.Done {
+		return false, status.Description, nil
+	}
+	for _, gvk := range crdTypes(crd) {
+		if _, err := lookup(gvk); err != nil {
+			return false, fmt.Sprintf("waiting for type %s to be available", gvk), nil
+		}
+	}
+	return true, "", nil
+}
+
+// waitForTypes waits until the named custom resource definition is established and its types can be used.
+func waitForTypes(ctx context.Context, ri dynamic.ResourceInterface, lookup func(gvk schema.GroupVersionKind) (*metav1.APIResource, error), name string, opts TypeWaitOptions) error {
+	waitTime := opts.timeout()
+	end := time.Now().Add(waitTime)
+	last := ""
+	for {
+		done, desc, err := crdReady(ctx, ri, lookup, name)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		if desc != last {
+			last = desc
+			sio.For(ctx).Noticef("custom resource definition %s: %s\n", name, desc)
+		}
+		if time.Now().After(end) {
+			return fmt.Errorf("custom resource definition %s not ready after %s: %s", name, waitTime.Round(time.Second), desc)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(opts.poll()):
+		}
+	}
+}
+
+// waitForCRD waits for the supplied custom resource definition to be established and for its types to be
+// available in discovery, such that custom resources of these types can be created right after.
+func (c *Client) waitForCRD(ctx context.Context, crd model.K8sMeta, opts TypeWaitOptions) error {
+	ri, err := c.ResourceInterface(crd.GroupVersionKind(), "")
+	if err != nil {
+		return err
+	}
+	return waitForTypes(ctx, ri, c.jitResource, crd.GetName(), opts)
+}
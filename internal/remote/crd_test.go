@@ -0,0 +1,134 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func testCRD(established, namesAccepted string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": "widgets.example.com"},
+		"spec": map[string]interface{}{
+			"group": "example.com",
+			"names": map[string]interface{}{"kind": "Widget", "plural": "widgets"},
+			"versions": []interface{}{
+				map[string]interface{}{"name": "v1", "served": true},
+				map[string]interface{}{"name": "v1beta1", "served": false},
+			},
+		},
+		"status": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "NamesAccepted", "status": namesAccepted, "message": "names conflict"},
+				map[string]interface{}{"type": "Established", "status": established},
+			},
+		},
+	}}
+}
+
+func TestCRDTypes(t *testing.T) {
+	a := assert.New(t)
+	a.Equal([]schema.GroupVersionKind{{Group: "example.com", Version: "v1", Kind: "Widget"}}, crdTypes(testCRD("True", "True")))
+
+	legacy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"group":   "example.com",
+			"version": "v1alpha1",
+			"names":   map[string]interface{}{"kind": "Widget"},
+		},
+	}}
+	a.Equal([]schema.GroupVersionKind{{Group: "example.com", Version: "v1alpha1", Kind: "Widget"}}, crdTypes(legacy))
+	a.Nil(crdTypes(&unstructured.Unstructured{Object: map[string]interface{}{}}))
+}
+
+func TestWaitForTypes(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+	opts := TypeWaitOptions{Poll: time.Millisecond, Timeout: time.Second}
+
+	tests := []struct {
+		name     string
+		statuses []*unstructured.Unstructured
+		lookups  int
+		opts     TypeWaitOptions
+		err      string
+	}{
+		{
+			name:     "established",
+			statuses: []*unstructured.Unstructured{testCRD("False", "True"), testCRD("True", "True")},
+			lookups:  2,
+		},
+		{
+			name:     "names not accepted",
+			statuses: []*unstructured.Unstructured{testCRD("False", "False")},
+			err:      "names not accepted: names conflict",
+		},
+		{
+			name:     "timeout",
+			statuses: []*unstructured.Unstructured{testCRD("False", "True")},
+			opts:     TypeWaitOptions{Poll: time.Millisecond, Timeout: 10 * time.Millisecond},
+			err:      "custom resource definition widgets.example.com not ready after 0s: waiting for the definition to be established",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dc := fake.NewSimpleDynamicClient(runtime.NewScheme())
+			gets := 0
+			dc.PrependReactor("get", "customresourcedefinitions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				s := test.statuses[gets]
+				if gets < len(test.statuses)-1 {
+					gets++
+				}
+				return true, s, nil
+			})
+			lookups := 0
+			lookup := func(gvk schema.GroupVersionKind) (*metav1.APIResource, error) {
+				assert.Equal(t, schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, gvk)
+				lookups++
+				if lookups < test.lookups {
+					return nil, fmt.Errorf("server does not recognize gvk %s", gvk)
+				}
+				return &metav1.APIResource{Kind: gvk.Kind}, nil
+			}
+			o := opts
+			if test.opts.Timeout != 0 {
+				o = test.opts
+			}
+			err := waitForTypes(context.Background(), dc.Resource(gvr), lookup, "widgets.example.com", o)
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.lookups, lookups)
+		})
+	}
+}
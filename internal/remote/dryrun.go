@@ -0,0 +1,107 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/model"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apiTypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// dryRunFieldManager is the field manager used for server-side dry-runs.
+const dryRunFieldManager = "qbec"
+
+// ServerDryRun submits the supplied object to the server in dry-run mode and returns the object that the server
+// would persist. The returned object reflects defaults set by the server as well as changes made by mutating
+// admission webhooks. Nothing is changed on the server.
+func (c *Client) ServerDryRun(ctx context.Context, obj model.K8sLocalObject) (_ *unstructured.Unstructured, finalError error) {
+	defer func() {
+		if finalError != nil {
+			finalError = errors.Wrap(finalError, "server dry-run "+c.DisplayName(obj))
+		}
+	}()
+	ri, err := c.resourceInterfaceWithDefaultNs(obj.GroupVersionKind(), obj.GetNamespace())
+	if err != nil {
+		return nil, errors.Wrap(err, "get resource interface")
+	}
+	return serverDryRun(ctx, ri, obj.ToUnstructured())
+}
+
+// WithoutServerFields returns a copy of the supplied object without the fields that only exist in objects returned
+// by the server, like the status, managed fields and resource version, and without null creation timestamps in
+// nested metadata. These are never part of the desired state of an object.
+func WithoutServerFields(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	ret := obj.DeepCopy()
+	removeServerFields(ret.Object)
+	return ret
+}
+
+// ServerValidate submits the supplied object to the server in dry-run mode and returns the warnings returned by the
+// server. Objects that the server does not accept, including those rejected by validating admission webhooks,
+// are reported as errors. Warnings are returned even if the object is not accepted. ErrMetadataNotFound is returned
+// for objects whose types are not known to the server.
+func (c *Client) ServerValidate(ctx context.Context, obj model.K8sLocalObject) ([]string, error) {
+	gvk := obj.GroupVersionKind()
+	var wc warningCollector
+	client, err := c.pool.clientWithWarnings(gvk, &wc)
+	if err != nil {
+		return nil, errors.Wrap(err, "get client")
+	}
+	ns := obj.GetNamespace()
+	if ns == "" {
+		ns = c.defaultNs
+	}
+	ri, err := c.resourceInterfaceFor(client, gvk, ns)
+	if err != nil {
+		return nil, err
+	}
+	_, err = serverDryRun(ctx, ri, obj.ToUnstructured())
+	return wc.get(), err
+}
+
+// serverDryRun applies the supplied object using a server-side apply in dry-run mode. Objects with generated names
+// cannot be applied and are created in dry-run mode instead.
+func serverDryRun(ctx context.Context, ri dynamic.ResourceInterface, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	dryRun := []string{metav1.DryRunAll}
+	if obj.GetName() == "" {
+		out, err := ri.Create(ctx, obj, metav1.CreateOptions{DryRun: dryRun, FieldManager: dryRunFieldManager})
+		if err != nil {
+			return nil, errors.Wrap(err, "create object")
+		}
+		return out, nil
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, errors.Wrap(err, "json marshal")
+	}
+	force := true
+	out, err := ri.Patch(ctx, obj.GetName(), apiTypes.ApplyPatchType, b, metav1.PatchOptions{
+		DryRun:       dryRun,
+		Force:        &force,
+		FieldManager: dryRunFieldManager,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "apply object")
+	}
+	return out, nil
+}
@@ -0,0 +1,107 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apiTypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestServerDryRun(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	dc := fake.NewSimpleDynamicClient(runtime.NewScheme())
+	var patchType apiTypes.PatchType
+	dc.PrependReactor("patch", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pa := action.(k8stesting.PatchAction)
+		patchType = pa.GetPatchType()
+		var data map[string]interface{}
+		if err := json.Unmarshal(pa.GetPatch(), &data); err != nil {
+			return true, nil, err
+		}
+		out := &unstructured.Unstructured{Object: data}
+		out.SetUID("1234")
+		out.SetLabels(map[string]string{"injected": "true"})
+		return true, out, nil
+	})
+	dc.PrependReactor("create", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured).DeepCopy()
+		obj.SetName(obj.GetGenerateName() + "abcde")
+		return true, obj, nil
+	})
+	ri := dc.Resource(gvr).Namespace("ns")
+
+	cm := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"namespace": "ns", "name": "cm"},
+		"data":       map[string]interface{}{"foo": "bar"},
+	}}
+	out, err := serverDryRun(context.Background(), ri, cm)
+	require.NoError(t, err)
+	a := assert.New(t)
+	a.Equal(apiTypes.ApplyPatchType, patchType)
+	a.Equal("cm", out.GetName())
+	a.Equal(map[string]string{"injected": "true"}, out.GetLabels())
+
+	gen := cm.DeepCopy()
+	gen.SetName("")
+	gen.SetGenerateName("cm-")
+	out, err = serverDryRun(context.Background(), ri, gen)
+	require.NoError(t, err)
+	a.Equal("cm-abcde", out.GetName())
+	a.Equal("create", dc.Fake.Actions()[1].GetVerb())
+}
+
+func TestWithoutServerFields(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":            "cm",
+			"resourceVersion": "10",
+			"managedFields":   []interface{}{map[string]interface{}{"manager": "qbec"}},
+		},
+		"data":   map[string]interface{}{"foo": "bar"},
+		"status": map[string]interface{}{"phase": "Active"},
+	}}
+	ret := WithoutServerFields(obj)
+	assert.Equal(t, map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "cm"},
+		"data":       map[string]interface{}{"foo": "bar"},
+	}, ret.Object)
+	assert.Equal(t, "10", obj.GetResourceVersion())
+}
+
+func TestWarningCollector(t *testing.T) {
+	var wc warningCollector
+	wc.HandleWarningHeader(299, "", "field is deprecated")
+	wc.HandleWarningHeader(199, "", "miscellaneous")
+	wc.HandleWarningHeader(299, "", "")
+	assert.Equal(t, []string{"field is deprecated"}, wc.get())
+}
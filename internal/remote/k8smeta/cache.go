@@ -0,0 +1,186 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package k8smeta
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	openapi_v2 "github.com/googleapis/gnostic/openapiv2"
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/sio"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Discovery is the discovery interface for server resources and the OpenAPI schema.
+type Discovery interface {
+	ResourceDiscovery
+	SchemaDiscovery
+}
+
+// DiskCache is a discovery interface that caches server groups, resources and the OpenAPI schema of a
+// single server in a directory, such that they are not loaded from the server by every invocation.
+// Cached data is used until it is older than the TTL. Errors are never cached and failures to read or
+// write cache files are ignored, in which case the data is loaded from the server.
+type DiskCache struct {
+	delegate Discovery
+	dir      string
+	ttl      time.Duration
+	refresh  bool // ignore cached data and overwrite it with data loaded from the server
+}
+
+// NewDiskCache returns a discovery interface that caches the results of the supplied delegate in the supplied
+// directory. The directory must be specific to the server and its version.
+func NewDiskCache(delegate Discovery, dir string, ttl time.Duration) *DiskCache {
+	return &DiskCache{delegate: delegate, dir: dir, ttl: ttl}
+}
+
+// Refresh returns a discovery interface that always loads data from the server and updates the cache with it.
+func (d *DiskCache) Refresh() *DiskCache {
+	return &DiskCache{delegate: d.delegate, dir: d.dir, ttl: d.ttl, refresh: true}
+}
+
+// read returns the contents of the supplied cache file if it exists and has not expired.
+func (d *DiskCache) read(file string) ([]byte, bool) {
+	if d.refresh {
+		return nil, false
+	}
+	st, err := os.Stat(file)
+	if err != nil || time.Since(st.ModTime()) > d.ttl {
+		return nil, false
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// write atomically writes the supplied cache file, ignoring errors.
+func (d *DiskCache) write(file string, b []byte) {
+	if err := writeFileAtomic(file, b); err != nil {
+		sio.Debugf("unable to write discovery cache file %s: %v\n", file, err)
+	}
+}
+
+func writeFileAtomic(file string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), file)
+}
+
+// cachedJSON unmarshals the supplied cache file into the supplied object if possible. Otherwise, it fetches
+// the object and caches it.
+func (d *DiskCache) cachedJSON(file string, obj interface{}, fetch func() (interface{}, error)) (interface{}, error) {
+	if b, ok := d.read(file); ok {
+		if err := json.Unmarshal(b, obj); err == nil {
+			return obj, nil
+		}
+	}
+	live, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(live)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal discovery data")
+	}
+	d.write(file, b)
+	return live, nil
+}
+
+// ServerGroups implements the ResourceDiscovery interface.
+func (d *DiskCache) ServerGroups() (*metav1.APIGroupList, error) {
+	ret, err := d.cachedJSON(filepath.Join(d.dir, "servergroups.json"), &metav1.APIGroupList{}, func() (interface{}, error) {
+		return d.delegate.ServerGroups()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret.(*metav1.APIGroupList), nil
+}
+
+// ServerResourcesForGroupVersion implements the ResourceDiscovery interface.
+func (d *DiskCache) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	file := filepath.Join(d.dir, filepath.FromSlash(groupVersion), "serverresources.json")
+	ret, err := d.cachedJSON(file, &metav1.APIResourceList{}, func() (interface{}, error) {
+		return d.delegate.ServerResourcesForGroupVersion(groupVersion)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret.(*metav1.APIResourceList), nil
+}
+
+// OpenAPIV3Schema implements the OpenAPIV3Discovery interface. It returns ErrOpenAPIV3NotFound if the delegate
+// does not implement the interface.
+func (d *DiskCache) OpenAPIV3Schema(ctx context.Context, gv schema.GroupVersion) ([]byte, error) {
+	v3disco, ok := d.delegate.(OpenAPIV3Discovery)
+	if !ok {
+		return nil, ErrOpenAPIV3NotFound
+	}
+	file := filepath.Join(d.dir, "openapi", "v3", filepath.FromSlash(gv.String())+".json")
+	if b, ok := d.read(file); ok {
+		return b, nil
+	}
+	b, err := v3disco.OpenAPIV3Schema(ctx, gv)
+	if err != nil {
+		return nil, err
+	}
+	d.write(file, b)
+	return b, nil
+}
+
+// OpenAPISchema implements the SchemaDiscovery interface.
+func (d *DiskCache) OpenAPISchema() (*openapi_v2.Document, error) {
+	file := filepath.Join(d.dir, "openapi.pb")
+	if b, ok := d.read(file); ok {
+		var doc openapi_v2.Document
+		if err := proto.Unmarshal(b, &doc); err == nil {
+			return &doc, nil
+		}
+	}
+	doc, err := d.delegate.OpenAPISchema()
+	if err != nil {
+		return nil, err
+	}
+	b, err := proto.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal OpenAPI document")
+	}
+	d.write(file, b)
+	return doc, nil
+}
@@ -0,0 +1,155 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package k8smeta
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	openapi_v2 "github.com/googleapis/gnostic/openapiv2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// cacheTestDisco counts the calls made to the server.
+type cacheTestDisco struct {
+	disco
+	sd
+	calls map[string]int
+}
+
+func newCountingDisco(t *testing.T) *cacheTestDisco {
+	var d disco
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "metadata.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &d))
+	return &cacheTestDisco{disco: d, calls: map[string]int{}}
+}
+
+func (c *cacheTestDisco) ServerGroups() (*metav1.APIGroupList, error) {
+	c.calls["groups"]++
+	return c.disco.ServerGroups()
+}
+
+func (c *cacheTestDisco) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	c.calls[groupVersion]++
+	return c.disco.ServerResourcesForGroupVersion(groupVersion)
+}
+
+func (c *cacheTestDisco) OpenAPISchema() (*openapi_v2.Document, error) {
+	c.calls["openapi"]++
+	return c.sd.OpenAPISchema()
+}
+
+func TestDiskCache(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	d := newCountingDisco(t)
+
+	load := func() *Resources {
+		r, err := NewResources(NewDiskCache(d, dir, time.Hour), ResourceOpts{})
+		require.NoError(t, err)
+		return r
+	}
+	live, err := NewResources(&d.disco, ResourceOpts{})
+	require.NoError(t, err)
+	first := load()
+	a.Equal(1, d.calls["groups"])
+	a.Equal(1, d.calls["apps/v1"])
+	second := load()
+	a.Equal(1, d.calls["groups"])
+	a.Equal(1, d.calls["apps/v1"])
+	a.Equal(live.CanonicalResources(), first.CanonicalResources())
+	a.Equal(live.CanonicalResources(), second.CanonicalResources())
+	a.FileExists(filepath.Join(dir, "servergroups.json"))
+	a.FileExists(filepath.Join(dir, "apps", "v1", "serverresources.json"))
+	a.FileExists(filepath.Join(dir, "v1", "serverresources.json"))
+
+	// errors are not cached
+	_, err = NewDiskCache(d, dir, time.Hour).ServerResourcesForGroupVersion("foo.example.com/v1")
+	require.Error(t, err)
+	_, err = NewDiskCache(d, dir, time.Hour).ServerResourcesForGroupVersion("foo.example.com/v1")
+	require.Error(t, err)
+	a.Equal(2, d.calls["foo.example.com/v1"])
+
+	// expired files are refreshed
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "servergroups.json"), old, old))
+	load()
+	a.Equal(2, d.calls["groups"])
+	a.Equal(1, d.calls["apps/v1"])
+
+	// corrupt files are ignored
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "apps", "v1", "serverresources.json"), []byte("{"), 0644))
+	load()
+	a.Equal(2, d.calls["apps/v1"])
+
+	// refreshes ignore cached data and update it
+	_, err = NewResources(NewDiskCache(d, dir, time.Hour).Refresh(), ResourceOpts{})
+	require.NoError(t, err)
+	a.Equal(3, d.calls["groups"])
+	a.Equal(3, d.calls["apps/v1"])
+	load()
+	a.Equal(3, d.calls["groups"])
+	a.Equal(3, d.calls["apps/v1"])
+}
+
+func TestDiskCacheOpenAPI(t *testing.T) {
+	dir := t.TempDir()
+	d := newCountingDisco(t)
+	expected, err := d.sd.OpenAPISchema()
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		doc, err := NewDiskCache(d, dir, time.Hour).OpenAPISchema()
+		require.NoError(t, err)
+		assert.Equal(t, expected.GetInfo().GetTitle(), doc.GetInfo().GetTitle())
+		assert.Equal(t, len(expected.GetDefinitions().GetAdditionalProperties()), len(doc.GetDefinitions().GetAdditionalProperties()))
+	}
+	assert.Equal(t, 1, d.calls["openapi"])
+	_, err = NewServerSchema(NewDiskCache(d, dir, time.Hour)).OpenAPIResources()
+	require.NoError(t, err)
+	assert.Equal(t, 1, d.calls["openapi"])
+}
+
+func TestDiskCacheOpenAPIV3(t *testing.T) {
+	dir := t.TempDir()
+	d := &v3sd{docs: map[schema.GroupVersion]string{{Group: "apps", Version: "v1"}: "openapi-v3-apps.json"}}
+	c := NewDiskCache(struct {
+		ResourceDiscovery
+		*v3sd
+	}{&disco{}, d}, dir, time.Hour)
+	expected, err := ioutil.ReadFile(filepath.Join("testdata", "openapi-v3-apps.json"))
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		b, err := c.OpenAPIV3Schema(context.TODO(), schema.GroupVersion{Group: "apps", Version: "v1"})
+		require.NoError(t, err)
+		assert.Equal(t, expected, b)
+	}
+	assert.Equal(t, 1, d.calls)
+	assert.FileExists(t, filepath.Join(dir, "openapi", "v3", "apps", "v1.json"))
+	_, err = c.OpenAPIV3Schema(context.TODO(), schema.GroupVersion{Version: "v1"})
+	assert.Equal(t, ErrOpenAPIV3NotFound, err)
+	_, err = NewDiskCache(&cacheTestDisco{}, dir, time.Hour).OpenAPIV3Schema(context.TODO(), schema.GroupVersion{Group: "apps", Version: "v1"})
+	assert.Equal(t, ErrOpenAPIV3NotFound, err)
+}
@@ -19,14 +19,17 @@ package k8smeta
 
 import (
 	"fmt"
-	"os"
 	"sort"
 	"strings"
 	"sync"
 
 	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/sio"
+	"github.com/splunk/qbec/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
 )
 
 var defaultVerbs = []string{"create", "delete", "get", "list"}
@@ -44,23 +47,32 @@ type ResourceDiscovery interface {
 	ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error)
 }
 
-// Resources provides resource information for a K8s cluster.
+// Resources provides resource information for a K8s cluster. In lazy mode, resources for an API group are only
+// discovered when a type in that group (or an equivalent group) is first referenced, and all groups are discovered
+// when the full set of canonical resources is requested.
 type Resources struct {
-	disco    ResourceDiscovery
-	registry map[schema.GroupVersionKind]*gvkInfo
+	disco      ResourceDiscovery
+	opts       ResourceOpts
+	l          sync.Mutex
+	groups     []metav1.APIGroup                    // server groups in discovery order
+	groupOrder map[string]int                       // discovery order of groups, used to resolve equivalences
+	loaded     map[string]bool                      // groups for which resources have been discovered
+	resolvers  []*resolver                          // resolvers for all discovered group versions
+	warned     map[schema.GroupKind]bool            // pinned versions that have already been warned about
+	registry   map[schema.GroupVersionKind]*gvkInfo // the registry computed from all discovered group versions
 }
 
 // ResourceOpts is optional information for loading resources.
 type ResourceOpts struct {
-	RequiredVerbs []string             // verbs that a resource must support in order to be loaded. Defaults to create/delete/get/list
-	WarnFn        func(...interface{}) // a function that can print warnings in the resource discovery.
+	RequiredVerbs     []string                    // verbs that a resource must support in order to be loaded. Defaults to create/delete/get/list
+	WarnFn            func(...interface{})        // a function that can print warnings in the resource discovery.
+	CanonicalVersions map[schema.GroupKind]string // versions to use as canonical versions for specific types, overriding the preferred versions
+	Lazy              bool                        // discover resources for groups on first use instead of up front
 }
 
 func (o *ResourceOpts) setDefaults() {
 	if o.WarnFn == nil {
-		o.WarnFn = func(args ...interface{}) {
-			fmt.Fprintln(os.Stderr, args...)
-		}
+		o.WarnFn = sio.Warnln
 	}
 	if len(o.RequiredVerbs) == 0 {
 		o.RequiredVerbs = defaultVerbs
@@ -69,20 +81,30 @@ func (o *ResourceOpts) setDefaults() {
 
 // NewResources loads server resources using the supplied discovery interface.
 func NewResources(disco ResourceDiscovery, opts ResourceOpts) (*Resources, error) {
+	opts.setDefaults()
 	sm := &Resources{
-		disco:    disco,
-		registry: map[schema.GroupVersionKind]*gvkInfo{},
+		disco:      disco,
+		opts:       opts,
+		groupOrder: map[string]int{},
+		loaded:     map[string]bool{},
+		warned:     map[schema.GroupKind]bool{},
+		registry:   map[schema.GroupVersionKind]*gvkInfo{},
 	}
-	opts.setDefaults()
-	if err := sm.init(opts); err != nil {
+	if err := sm.init(); err != nil {
 		return nil, err
 	}
+	if !opts.Lazy {
+		sm.loadAll()
+	}
 	return sm, nil
 }
 
 // APIResource returns the API resource for the supplied group version kind or nil
 // if no resource could be found.
 func (r *Resources) APIResource(gvk schema.GroupVersionKind) *metav1.APIResource {
+	r.ensureGroup(gvk.Group)
+	r.l.Lock()
+	defer r.l.Unlock()
 	r0, ok := r.registry[gvk]
 	if !ok {
 		return nil
@@ -93,6 +115,9 @@ func (r *Resources) APIResource(gvk schema.GroupVersionKind) *metav1.APIResource
 
 // CanonicalResources returns a map of API resources keyed by group-kind.
 func (r *Resources) CanonicalResources() map[schema.GroupKind]metav1.APIResource {
+	r.loadAll()
+	r.l.Lock()
+	defer r.l.Unlock()
 	canonical := map[schema.GroupVersionKind]bool{}
 	for _, v := range r.registry {
 		canonical[v.canonical] = true
@@ -113,6 +138,9 @@ func (r *Resources) CanonicalResources() map[schema.GroupKind]metav1.APIResource
 // CanonicalGroupVersionKind provides the preferred/ canonical group version kind for the supplied input.
 // It takes aliases into account (e.g. extensions/Deployment same as apps/Deployment) for doing so.
 func (r *Resources) CanonicalGroupVersionKind(gvk schema.GroupVersionKind) (schema.GroupVersionKind, error) {
+	r.ensureGroup(gvk.Group)
+	r.l.Lock()
+	defer r.l.Unlock()
 	res, ok := r.registry[gvk]
 	if !ok {
 		return gvk, fmt.Errorf("server does not recognize gvk %s", gvk)
@@ -122,6 +150,8 @@ func (r *Resources) CanonicalGroupVersionKind(gvk schema.GroupVersionKind) (sche
 
 // Dump dumps resource mappings using the supplied println function.
 func (r *Resources) Dump(println func(...interface{})) {
+	r.l.Lock()
+	defer r.l.Unlock()
 	var display []string
 	for k, v := range r.registry {
 		l := fmt.Sprintf("%s/%s:%s", k.Group, k.Version, k.Kind)
@@ -178,6 +208,19 @@ var equivalences = []equivalence{
 	},
 }
 
+// highestVersion returns the highest priority version of the supplied group kind, using Kubernetes version
+// ordering (e.g. v2, v1, v1beta2, v1beta1, v1alpha1).
+func highestVersion(gk schema.GroupKind, gvks []schema.GroupVersionKind) schema.GroupVersionKind {
+	var versions []string
+	for _, gvk := range gvks {
+		versions = append(versions, gvk.Version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return version.CompareKubeAwareVersionStrings(versions[i], versions[j]) > 0
+	})
+	return gk.WithVersion(versions[0])
+}
+
 func eligibleResource(r metav1.APIResource, requiredVerbs []string) bool {
 	for _, n := range requiredVerbs {
 		found := false
@@ -203,12 +246,11 @@ type resolver struct {
 	preferredVersion string
 	registry         map[schema.GroupVersionKind]*gvkInfo
 	tracker          map[schema.GroupKind][]schema.GroupVersionKind
-	err              error
 }
 
 func (r *resolver) resolve(disco ResourceDiscovery) {
 	if r.warnFn == nil {
-		r.warnFn = func(args ...interface{}) { fmt.Fprintln(os.Stderr, args...) }
+		r.warnFn = sio.Warnln
 	}
 	reg := map[schema.GroupVersionKind]*gvkInfo{}
 	tracker := map[schema.GroupKind][]schema.GroupVersionKind{}
@@ -244,34 +286,97 @@ func (r *resolver) resolve(disco ResourceDiscovery) {
 	r.tracker = tracker
 }
 
-func (r *Resources) init(opts ResourceOpts) error {
+// relatedGroups returns the supplied group along with all groups that have types equivalent to types in it.
+func relatedGroups(group string) []string {
+	ret := []string{group}
+	for _, eq := range equivalences {
+		switch group {
+		case eq.gk1.Group:
+			ret = append(ret, eq.gk2.Group)
+		case eq.gk2.Group:
+			ret = append(ret, eq.gk1.Group)
+		}
+	}
+	return ret
+}
+
+// init loads the server groups. Resources for the groups are discovered separately.
+func (r *Resources) init() (finalErr error) {
+	_, span := telemetry.Start(telemetry.CommandContext(), "discover server groups")
+	defer func() { telemetry.End(span, finalErr) }()
 	groups, err := r.disco.ServerGroups()
 	if err != nil {
 		return errors.Wrap(err, "get server groups")
 	}
+	for i, group := range groups.Groups {
+		r.groupOrder[group.Name] = i + 1
+	}
+	r.groups = groups.Groups
+	return nil
+}
 
-	order := 0
-	groupOrderMap := map[string]int{}
+// ensureGroup discovers resources for the supplied group and its equivalent groups, if not already done.
+func (r *Resources) ensureGroup(group string) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	if r.loaded[group] {
+		return
+	}
+	r.load(relatedGroups(group))
+}
 
+// loadAll discovers resources for all server groups, if not already done.
+func (r *Resources) loadAll() {
+	r.l.Lock()
+	defer r.l.Unlock()
+	var names []string
+	for _, group := range r.groups {
+		names = append(names, group.Name)
+	}
+	r.load(names)
+}
+
+// load discovers resources for all versions of the supplied groups that have not already been loaded and
+// recomputes the registry. It must be called with the lock held.
+func (r *Resources) load(names []string) {
+	wanted := map[string]bool{}
+	for _, name := range names {
+		if !r.loaded[name] {
+			wanted[name] = true
+		}
+		r.loaded[name] = true
+	}
+	if len(wanted) == 0 {
+		return
+	}
 	var resolvers []*resolver
-	for _, group := range groups.Groups {
-		groupName := group.Name
-		order++
-		groupOrderMap[groupName] = order
-		preferredVersionName := group.PreferredVersion.Version
+	for _, group := range r.groups {
+		if !wanted[group.Name] {
+			continue
+		}
 		for _, gv := range group.Versions {
-			versionName := gv.Version
 			resolvers = append(resolvers, &resolver{
-				warnFn:           opts.WarnFn,
-				requiredVerbs:    opts.RequiredVerbs,
-				group:            groupName,
-				version:          versionName,
-				preferredVersion: preferredVersionName,
+				warnFn:           r.opts.WarnFn,
+				requiredVerbs:    r.opts.RequiredVerbs,
+				group:            group.Name,
+				version:          gv.Version,
+				preferredVersion: group.PreferredVersion.Version,
 				groupVersion:     gv.GroupVersion,
 			})
 		}
 	}
 
+	var groupNames []string
+	for name := range wanted {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+	_, span := telemetry.Start(telemetry.CommandContext(), "discover resources",
+		attribute.StringSlice("qbec.groups", groupNames),
+		attribute.Int("qbec.group_versions", len(resolvers)),
+	)
+	defer span.End()
+
 	var wg sync.WaitGroup
 	wg.Add(len(resolvers))
 	for _, r0 := range resolvers {
@@ -281,37 +386,59 @@ func (r *Resources) init(opts ResourceOpts) error {
 		}(r0)
 	}
 	wg.Wait()
+	r.resolvers = append(r.resolvers, resolvers...)
+	r.computeRegistry()
+}
 
+// computeRegistry computes the registry from all resolvers that have been run so far.
+func (r *Resources) computeRegistry() {
 	reg := map[schema.GroupVersionKind]*gvkInfo{}
 	// tracker tracks all known versions for a given group kind for the purposes of updating
 	// the canonical versions for equivalences.
 	tracker := map[schema.GroupKind][]schema.GroupVersionKind{}
-	for _, r := range resolvers {
-		if r.err != nil {
-			return r.err
-		}
-		for k, v := range r.registry {
+	for _, rs := range r.resolvers {
+		for k, v := range rs.registry {
 			reg[k] = v
 		}
-		for k, v := range r.tracker {
+		for k, v := range rs.tracker {
 			tracker[k] = append(tracker[k], v...)
 		}
 	}
 
-	// now deal with incorrect preferred versions when specific types do not exist for those
-	var fixTypes []schema.GroupVersionKind // collect list of types to be fixed
-	for k, v := range reg {
-		canon := v.canonical
-		if reg[canon] == nil {
-			fixTypes = append(fixTypes, k)
+	// now deal with incorrect preferred versions when specific types do not exist for those. This is common for
+	// CRDs where different kinds in the same group are served at different versions. In this case, all versions of
+	// the type are mapped to the highest priority version that is served such that the canonical version is the same
+	// regardless of the version used to refer to an object.
+	setCanonical := func(gvks []schema.GroupVersionKind, canon schema.GroupVersionKind) {
+		for _, gvk := range gvks {
+			reg[gvk] = &gvkInfo{
+				canonical: canon,
+				resource:  reg[gvk].resource,
+			}
 		}
 	}
-	for _, k := range fixTypes {
-		v := reg[k]
-		reg[k] = &gvkInfo{
-			canonical: k,
-			resource:  v.resource,
+	for gk, gvks := range tracker {
+		if reg[reg[gvks[0]].canonical] != nil {
+			continue
+		}
+		setCanonical(gvks, highestVersion(gk, gvks))
+	}
+
+	// then process explicitly pinned versions
+	for gk, version := range r.opts.CanonicalVersions {
+		gvks, ok := tracker[gk]
+		if !ok {
+			continue
 		}
+		canon := gk.WithVersion(version)
+		if reg[canon] == nil {
+			if !r.warned[gk] {
+				r.warned[gk] = true
+				r.opts.WarnFn(fmt.Sprintf("canonical version %s for %s is not served, ignored", version, gk))
+			}
+			continue
+		}
+		setCanonical(gvks, canon)
 	}
 
 	// then process aliases
@@ -323,8 +450,8 @@ func (r *Resources) init(opts ResourceOpts) error {
 		if !(gk1Present && gk2Present) {
 			continue
 		}
-		g1Order := groupOrderMap[gk1.Group]
-		g2Order := groupOrderMap[gk2.Group]
+		g1Order := r.groupOrder[gk1.Group]
+		g2Order := r.groupOrder[gk2.Group]
 		var canonicalGK, aliasGK schema.GroupKind
 		if g1Order < g2Order {
 			canonicalGK, aliasGK = eq.gk1, eq.gk2
@@ -342,5 +469,4 @@ func (r *Resources) init(opts ResourceOpts) error {
 	}
 
 	r.registry = reg
-	return nil
 }
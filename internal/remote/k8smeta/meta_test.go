@@ -21,7 +21,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/splunk/qbec/internal/model"
@@ -151,3 +153,117 @@ func TestMetadataOther(t *testing.T) {
 	_, err := sm.CanonicalGroupVersionKind(schema.GroupVersionKind{Group: "", Version: "v1", Kind: "FooBar"})
 	require.NotNil(t, err)
 }
+
+func crdDisco() *disco {
+	res := func(kind string) metav1.APIResource {
+		return metav1.APIResource{Name: strings.ToLower(kind) + "s", Kind: kind, Verbs: defaultVerbs}
+	}
+	return &disco{
+		Groups: &metav1.APIGroupList{
+			Groups: []metav1.APIGroup{
+				{
+					Name: "kyverno.io",
+					Versions: []metav1.GroupVersionForDiscovery{
+						{GroupVersion: "kyverno.io/v2beta1", Version: "v2beta1"},
+						{GroupVersion: "kyverno.io/v1", Version: "v1"},
+						{GroupVersion: "kyverno.io/v1beta1", Version: "v1beta1"},
+					},
+					PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "kyverno.io/v2beta1", Version: "v2beta1"},
+				},
+			},
+		},
+		ResourceLists: map[string]*metav1.APIResourceList{
+			"kyverno.io:v2beta1": {APIResources: []metav1.APIResource{res("PolicyException")}},
+			"kyverno.io:v1":      {APIResources: []metav1.APIResource{res("ClusterPolicy"), res("PolicyException")}},
+			"kyverno.io:v1beta1": {APIResources: []metav1.APIResource{res("ClusterPolicy")}},
+		},
+	}
+}
+
+func TestMetadataCanonicalNotInPreferredVersion(t *testing.T) {
+	for i := 0; i < 10; i++ { // map iteration order must not matter
+		sm, err := NewResources(crdDisco(), ResourceOpts{})
+		require.NoError(t, err)
+		for _, v := range []string{"v1", "v1beta1"} {
+			canon, err := sm.CanonicalGroupVersionKind(schema.GroupVersionKind{Group: "kyverno.io", Version: v, Kind: "ClusterPolicy"})
+			require.NoError(t, err)
+			assert.Equal(t, schema.GroupVersionKind{Group: "kyverno.io", Version: "v1", Kind: "ClusterPolicy"}, canon)
+		}
+		canon, err := sm.CanonicalGroupVersionKind(schema.GroupVersionKind{Group: "kyverno.io", Version: "v1", Kind: "PolicyException"})
+		require.NoError(t, err)
+		assert.Equal(t, "v2beta1", canon.Version)
+		res := sm.CanonicalResources()[schema.GroupKind{Group: "kyverno.io", Kind: "ClusterPolicy"}]
+		assert.Equal(t, "v1", res.Version)
+	}
+}
+
+func TestMetadataPinnedVersions(t *testing.T) {
+	var warnings []string
+	sm, err := NewResources(crdDisco(), ResourceOpts{
+		WarnFn: func(args ...interface{}) { warnings = append(warnings, fmt.Sprint(args...)) },
+		CanonicalVersions: map[schema.GroupKind]string{
+			{Group: "kyverno.io", Kind: "ClusterPolicy"}:   "v1beta1",
+			{Group: "kyverno.io", Kind: "PolicyException"}: "v3",
+			{Group: "example.com", Kind: "Foo"}:            "v1",
+		},
+	})
+	require.NoError(t, err)
+	canon, err := sm.CanonicalGroupVersionKind(schema.GroupVersionKind{Group: "kyverno.io", Version: "v1", Kind: "ClusterPolicy"})
+	require.NoError(t, err)
+	assert.Equal(t, "v1beta1", canon.Version)
+	canon, err = sm.CanonicalGroupVersionKind(schema.GroupVersionKind{Group: "kyverno.io", Version: "v1", Kind: "PolicyException"})
+	require.NoError(t, err)
+	assert.Equal(t, "v2beta1", canon.Version)
+	assert.Equal(t, []string{"canonical version v3 for PolicyException.kyverno.io is not served, ignored"}, warnings)
+}
+
+type countingDisco struct {
+	*disco
+	l      sync.Mutex
+	groups map[string]bool
+}
+
+func (c *countingDisco) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	c.l.Lock()
+	gv, _ := schema.ParseGroupVersion(groupVersion)
+	c.groups[gv.Group] = true
+	c.l.Unlock()
+	return c.disco.ServerResourcesForGroupVersion(groupVersion)
+}
+
+func (c *countingDisco) queried() []string {
+	var ret []string
+	for g := range c.groups {
+		ret = append(ret, g)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func TestMetadataLazy(t *testing.T) {
+	var d disco
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "metadata.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &d))
+	cd := &countingDisco{disco: &d, groups: map[string]bool{}}
+	sm, err := NewResources(cd, ResourceOpts{Lazy: true})
+	require.NoError(t, err)
+	assert.Empty(t, cd.queried())
+
+	canon, err := sm.CanonicalGroupVersionKind(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+	require.NoError(t, err)
+	assert.Equal(t, schema.GroupVersionKind{Group: "extensions", Version: "v1beta1", Kind: "Deployment"}, canon)
+	assert.Equal(t, []string{"apps", "extensions"}, cd.queried())
+
+	res := sm.APIResource(schema.GroupVersionKind{Group: "", Version: "v1", Kind: "ConfigMap"})
+	require.NotNil(t, res)
+	assert.True(t, res.Namespaced)
+	assert.Equal(t, []string{"", "apps", "events.k8s.io", "extensions"}, cd.queried())
+
+	res = sm.APIResource(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Foo"})
+	assert.Nil(t, res)
+
+	full := getServerMetadata(t, 0)
+	assert.Equal(t, full.CanonicalResources(), sm.CanonicalResources())
+	assert.Equal(t, len(d.Groups.Groups), len(cd.queried()))
+}
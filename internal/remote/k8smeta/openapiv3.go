@@ -0,0 +1,244 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package k8smeta
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-openapi/pkg/util/proto"
+	"k8s.io/kube-openapi/pkg/util/proto/validation"
+)
+
+// ErrOpenAPIV3NotFound is returned when the server does not publish an OpenAPI v3 document for a group version.
+var ErrOpenAPIV3NotFound = errors.New("OpenAPI v3 document not found")
+
+// OpenAPIV3Discovery is implemented by discovery interfaces that can retrieve OpenAPI v3 documents.
+type OpenAPIV3Discovery interface {
+	// OpenAPIV3Schema returns the OpenAPI v3 document in JSON format for the supplied group version,
+	// or ErrOpenAPIV3NotFound if the server does not publish one.
+	OpenAPIV3Schema(ctx context.Context, gv schema.GroupVersion) ([]byte, error)
+}
+
+const v3RefPrefix = "#/components/schemas/"
+
+// v3Schema is the subset of an OpenAPI v3 schema that is required for structural validation.
+type v3Schema struct {
+	Ref                  string               `json:"$ref,omitempty"`
+	Type                 string               `json:"type,omitempty"`
+	Properties           map[string]*v3Schema `json:"properties,omitempty"`
+	AdditionalProperties *v3Additional        `json:"additionalProperties,omitempty"`
+	Items                *v3Schema            `json:"items,omitempty"`
+	Required             []string             `json:"required,omitempty"`
+	AllOf                []*v3Schema          `json:"allOf,omitempty"`
+	IntOrString          bool                 `json:"x-kubernetes-int-or-string,omitempty"`
+	PreserveUnknown      bool                 `json:"x-kubernetes-preserve-unknown-fields,omitempty"`
+	EmbeddedResource     bool                 `json:"x-kubernetes-embedded-resource,omitempty"`
+	GroupVersionKinds    []struct {
+		Group   string `json:"group"`
+		Version string `json:"version"`
+		Kind    string `json:"kind"`
+	} `json:"x-kubernetes-group-version-kind,omitempty"`
+}
+
+// v3Additional is the value of additionalProperties which is either a boolean or a schema.
+type v3Additional struct {
+	allowed bool
+	schema  *v3Schema
+}
+
+func (a *v3Additional) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &a.allowed); err == nil {
+		return nil
+	}
+	a.allowed = true
+	return json.Unmarshal(b, &a.schema)
+}
+
+// v3Document is an OpenAPI v3 document for a single group version.
+type v3Document struct {
+	Components struct {
+		Schemas map[string]*v3Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+func parseV3Document(b []byte) (*v3Document, error) {
+	var doc v3Document
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, errors.Wrap(err, "unmarshal OpenAPI v3 document")
+	}
+	return &doc, nil
+}
+
+// validatorFor returns a validator for the supplied type, or nil if the document has no schema for it.
+func (d *v3Document) validatorFor(gvk schema.GroupVersionKind) Validator {
+	var names []string
+	for name := range d.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, x := range d.Components.Schemas[name].GroupVersionKinds {
+			if x.Group == gvk.Group && x.Version == gvk.Version && x.Kind == gvk.Kind {
+				return &v3Validator{doc: d, name: name}
+			}
+		}
+	}
+	return nil
+}
+
+// v3Validator validates objects against an OpenAPI v3 schema, reporting the same kind of errors as the
+// validation of OpenAPI v2 models, which are unknown fields, missing required fields and invalid types.
+type v3Validator struct {
+	doc  *v3Document
+	name string
+}
+
+func (v *v3Validator) Validate(obj *unstructured.Unstructured) []error {
+	gvk := obj.GroupVersionKind()
+	return v.validate(proto.NewPath(fmt.Sprintf("%s.%s", gvk.Version, gvk.Kind)), obj.UnstructuredContent(), &v3Schema{Ref: v3RefPrefix + v.name}, "")
+}
+
+// kindOf returns the type name of the supplied value for error messages.
+func kindOf(v interface{}) string {
+	switch reflect.TypeOf(v).Kind() {
+	case reflect.Bool:
+		return proto.Boolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return proto.Integer
+	case reflect.Float32, reflect.Float64:
+		return proto.Number
+	case reflect.String:
+		return proto.String
+	case reflect.Array, reflect.Slice:
+		return "array"
+	case reflect.Map:
+		return "map"
+	default:
+		return reflect.TypeOf(v).Kind().String()
+	}
+}
+
+// validate validates a non-nil value at the supplied path against a schema with the supplied name.
+func (v *v3Validator) validate(path proto.Path, value interface{}, s *v3Schema, name string) []error {
+	if s.Ref != "" {
+		name = strings.TrimPrefix(s.Ref, v3RefPrefix)
+		s = v.doc.Components.Schemas[name]
+		if s == nil {
+			return nil
+		}
+	}
+	invalidType := func(expected string) []error {
+		return []error{validation.ValidationError{
+			Path: path.String(),
+			Err:  validation.InvalidTypeError{Path: name, Expected: expected, Actual: kindOf(value)},
+		}}
+	}
+	var errs []error
+	for _, sub := range s.AllOf {
+		errs = append(errs, v.validate(path, value, sub, name)...)
+	}
+	actual := kindOf(value)
+	switch {
+	case s.IntOrString:
+		if actual == "map" || actual == "array" || actual == proto.Boolean {
+			return append(errs, invalidType(proto.String)...)
+		}
+	case s.Type == "object" || (s.Type == "" && len(s.Properties) > 0):
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return append(errs, invalidType("map")...)
+		}
+		errs = append(errs, v.validateObject(path, m, s, name)...)
+	case s.Type == "array":
+		a, ok := value.([]interface{})
+		if !ok {
+			return append(errs, invalidType("array")...)
+		}
+		for i, item := range a {
+			p := path.ArrayPath(i)
+			if item == nil {
+				errs = append(errs, validation.ValidationError{Path: p.String(), Err: validation.InvalidObjectTypeError{Type: "nil", Path: p.String()}})
+				continue
+			}
+			if s.Items != nil {
+				errs = append(errs, v.validate(p, item, s.Items, name)...)
+			}
+		}
+	case s.Type == proto.String:
+		if actual == "map" || actual == "array" {
+			return append(errs, invalidType(proto.String)...)
+		}
+	case s.Type == proto.Integer || s.Type == proto.Number:
+		if actual != proto.Integer && actual != proto.Number {
+			return append(errs, invalidType(s.Type)...)
+		}
+	case s.Type == proto.Boolean:
+		if actual != proto.Boolean {
+			return append(errs, invalidType(proto.Boolean)...)
+		}
+	}
+	return errs
+}
+
+// validateObject validates the fields of an object.
+func (v *v3Validator) validateObject(path proto.Path, m map[string]interface{}, s *v3Schema, name string) []error {
+	var errs []error
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := m[key]
+		if value == nil {
+			continue
+		}
+		if prop, ok := s.Properties[key]; ok {
+			errs = append(errs, v.validate(path.FieldPath(key), value, prop, name+"."+key)...)
+			continue
+		}
+		switch {
+		case s.AdditionalProperties != nil && s.AdditionalProperties.schema != nil:
+			errs = append(errs, v.validate(path.FieldPath(key), value, s.AdditionalProperties.schema, name)...)
+		case len(s.Properties) == 0, s.PreserveUnknown, s.AdditionalProperties != nil && s.AdditionalProperties.allowed:
+		case s.EmbeddedResource && (key == "apiVersion" || key == "kind" || key == "metadata"):
+		default:
+			errs = append(errs, validation.ValidationError{
+				Path: path.String(),
+				Err:  validation.UnknownFieldError{Path: name, Field: key},
+			})
+		}
+	}
+	for _, required := range s.Required {
+		if v, ok := m[required]; !ok || v == nil {
+			errs = append(errs, validation.ValidationError{
+				Path: path.String(),
+				Err:  validation.MissingRequiredFieldError{Path: name, Field: required},
+			})
+		}
+	}
+	return errs
+}
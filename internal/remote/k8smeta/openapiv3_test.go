@@ -0,0 +1,197 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package k8smeta
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// v3sd is a schema discovery interface that also serves OpenAPI v3 documents from test data.
+type v3sd struct {
+	sd
+	docs  map[schema.GroupVersion]string
+	calls int
+}
+
+func (d *v3sd) OpenAPIV3Schema(ctx context.Context, gv schema.GroupVersion) ([]byte, error) {
+	d.calls++
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	file, ok := d.docs[gv]
+	if !ok {
+		return nil, ErrOpenAPIV3NotFound
+	}
+	return ioutil.ReadFile(filepath.Join("testdata", file))
+}
+
+func deployment(spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "d1", "labels": map[string]interface{}{"app": "d1"}},
+		"spec":       spec,
+	}}
+}
+
+func TestV3Validator(t *testing.T) {
+	d := &v3sd{docs: map[schema.GroupVersion]string{{Group: "apps", Version: "v1"}: "openapi-v3-apps.json"}}
+	ss := NewServerSchema(d)
+	v, err := ss.ValidatorFor(context.TODO(), schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+	require.NoError(t, err)
+	_, ok := v.(*v3Validator)
+	require.True(t, ok)
+
+	tests := []struct {
+		name     string
+		spec     map[string]interface{}
+		expected []string
+	}{
+		{
+			name: "good",
+			spec: map[string]interface{}{
+				"replicas": int64(2),
+				"paused":   false,
+				"selector": map[string]interface{}{"app": "d1"},
+				"strategy": map[string]interface{}{"maxSurge": "25%"},
+				"template": map[string]interface{}{"anything": map[string]interface{}{"goes": true}},
+				"containers": []interface{}{
+					map[string]interface{}{"name": "c1", "args": []interface{}{"a", int64(1)}},
+				},
+				"extra":  map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{}, "spec": map[string]interface{}{}},
+				"config": map[string]interface{}{"free": "form"},
+				"nulled": nil,
+			},
+		},
+		{
+			name: "unknown field",
+			spec: map[string]interface{}{"selector": map[string]interface{}{}, "foo": "bar"},
+			expected: []string{
+				`ValidationError(v1.Deployment.spec): unknown field "foo" in io.k8s.api.apps.v1.DeploymentSpec`,
+			},
+		},
+		{
+			name: "missing required field",
+			spec: map[string]interface{}{"containers": []interface{}{map[string]interface{}{"args": []interface{}{}}}},
+			expected: []string{
+				`ValidationError(v1.Deployment.spec.containers[0]): missing required field "name" in io.k8s.api.apps.v1.DeploymentSpec.containers`,
+				`ValidationError(v1.Deployment.spec): missing required field "selector" in io.k8s.api.apps.v1.DeploymentSpec`,
+			},
+		},
+		{
+			name: "invalid types",
+			spec: map[string]interface{}{
+				"selector":   map[string]interface{}{"app": map[string]interface{}{}},
+				"replicas":   "two",
+				"paused":     "no",
+				"strategy":   map[string]interface{}{"maxSurge": true},
+				"containers": map[string]interface{}{},
+			},
+			expected: []string{
+				`ValidationError(v1.Deployment.spec.containers): invalid type for io.k8s.api.apps.v1.DeploymentSpec.containers: got "map", expected "array"`,
+				`ValidationError(v1.Deployment.spec.paused): invalid type for io.k8s.api.apps.v1.DeploymentSpec.paused: got "string", expected "boolean"`,
+				`ValidationError(v1.Deployment.spec.replicas): invalid type for io.k8s.api.apps.v1.DeploymentSpec.replicas: got "string", expected "integer"`,
+				`ValidationError(v1.Deployment.spec.selector.app): invalid type for io.k8s.api.apps.v1.DeploymentSpec.selector: got "map", expected "string"`,
+				`ValidationError(v1.Deployment.spec.strategy.maxSurge): invalid type for io.k8s.api.apps.v1.DeploymentSpec.strategy.maxSurge: got "boolean", expected "string"`,
+			},
+		},
+		{
+			name: "nil array item",
+			spec: map[string]interface{}{"selector": map[string]interface{}{}, "containers": []interface{}{nil}},
+			expected: []string{
+				`ValidationError(v1.Deployment.spec.containers[0]): unknown object type "nil" in v1.Deployment.spec.containers[0]`,
+			},
+		},
+		{
+			name: "embedded resource",
+			spec: map[string]interface{}{"selector": map[string]interface{}{}, "extra": map[string]interface{}{"kind": "ConfigMap", "data": "x"}},
+			expected: []string{
+				`ValidationError(v1.Deployment.spec.extra): unknown field "data" in io.k8s.api.apps.v1.DeploymentSpec.extra`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var actual []string
+			for _, err := range v.Validate(deployment(test.spec)) {
+				actual = append(actual, err.Error())
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestV3CancelledContext(t *testing.T) {
+	a := assert.New(t)
+	d := &v3sd{docs: map[schema.GroupVersion]string{{Group: "apps", Version: "v1"}: "openapi-v3-apps.json"}}
+	ss := NewServerSchema(d)
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a.Nil(ss.v3ValidatorFor(ctx, gvk))
+
+	// failures due to cancellation are not cached
+	v := ss.v3ValidatorFor(context.Background(), gvk)
+	_, ok := v.(*v3Validator)
+	a.True(ok)
+	a.Equal(2, d.calls)
+}
+
+func TestV3Fallback(t *testing.T) {
+	a := assert.New(t)
+	d := &v3sd{docs: map[schema.GroupVersion]string{{Group: "apps", Version: "v1"}: "openapi-v3-apps.json"}}
+	ss := NewServerSchema(d)
+	ctx := context.TODO()
+
+	// no v3 document for the group version
+	v, err := ss.ValidatorFor(ctx, schema.GroupVersionKind{Version: "v1", Kind: "Namespace"})
+	require.NoError(t, err)
+	errs := v.Validate(loadObject(t, "ns-bad.json").ToUnstructured())
+	require.Equal(t, 1, len(errs))
+	a.Contains(errs[0].Error(), `unknown field "foo"`)
+	_, ok := v.(*v3Validator)
+	a.False(ok)
+
+	// no schema for the kind in the v3 document
+	v, err = ss.ValidatorFor(ctx, schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"})
+	require.NoError(t, err)
+	_, ok = v.(*v3Validator)
+	a.False(ok)
+
+	// v3 documents are retrieved once per group version
+	a.Equal(2, d.calls)
+	_, err = ss.ValidatorFor(ctx, schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+	require.NoError(t, err)
+	a.Equal(2, d.calls)
+
+	// documents that cannot be parsed
+	d = &v3sd{docs: map[schema.GroupVersion]string{{Version: "v1"}: "swagger-2.0.0.pb-v1"}}
+	ss = NewServerSchema(d)
+	v, err = ss.ValidatorFor(ctx, schema.GroupVersionKind{Version: "v1", Kind: "Namespace"})
+	require.NoError(t, err)
+	_, ok = v.(*v3Validator)
+	a.False(ok)
+}
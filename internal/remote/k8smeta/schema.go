@@ -23,6 +23,7 @@ import (
 
 	openapi_v2 "github.com/googleapis/gnostic/openapiv2"
 	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/sio"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/kube-openapi/pkg/util/proto"
@@ -92,23 +93,69 @@ type SchemaDiscovery interface {
 	OpenAPISchema() (*openapi_v2.Document, error)
 }
 
+// v3Result is the cached result of retrieving the OpenAPI v3 document for a group version.
+type v3Result struct {
+	doc *v3Document
+	err error
+}
+
 // ServerSchema is a representation of the resource schema of a Kubernetes server.
 type ServerSchema struct {
 	ol      sync.Mutex
 	oResult *openapiResourceResult
 	disco   SchemaDiscovery
+	vl      sync.Mutex
+	v3      map[schema.GroupVersion]*v3Result
 }
 
 // NewServerSchema returns a server schema that can supply validators for the given discovery
-// interface.
+// interface. When the discovery interface also implements OpenAPIV3Discovery, validators use the
+// OpenAPI v3 document for the group version of a type, if available, and the OpenAPI v2 document otherwise.
 func NewServerSchema(disco SchemaDiscovery) *ServerSchema {
 	return &ServerSchema{
 		disco: disco,
+		v3:    map[schema.GroupVersion]*v3Result{},
 	}
 }
 
+// v3ValidatorFor returns a validator for the supplied type from the OpenAPI v3 document of its group version,
+// or nil if it is not available. Failures caused by the cancellation of the supplied context are not cached.
+func (ss *ServerSchema) v3ValidatorFor(ctx context.Context, gvk schema.GroupVersionKind) Validator {
+	v3disco, ok := ss.disco.(OpenAPIV3Discovery)
+	if !ok {
+		return nil
+	}
+	ss.vl.Lock()
+	defer ss.vl.Unlock()
+	gv := gvk.GroupVersion()
+	r := ss.v3[gv]
+	if r == nil {
+		r = &v3Result{}
+		b, err := v3disco.OpenAPIV3Schema(ctx, gv)
+		if err == nil {
+			r.doc, err = parseV3Document(b)
+		}
+		if err != nil {
+			r.err = err
+			if err != ErrOpenAPIV3NotFound {
+				sio.Debugf("use OpenAPI v2 schema for %s: %v\n", gv, err)
+			}
+		}
+		if ctx.Err() == nil {
+			ss.v3[gv] = r
+		}
+	}
+	if r.err != nil {
+		return nil
+	}
+	return r.doc.validatorFor(gvk)
+}
+
 // ValidatorFor returns a validator for the supplied GroupVersionKind.
 func (ss *ServerSchema) ValidatorFor(ctx context.Context, gvk schema.GroupVersionKind) (Validator, error) {
+	if v := ss.v3ValidatorFor(ctx, gvk); v != nil {
+		return v, nil
+	}
 	_, v, err := ss.openAPIResources()
 	if err != nil {
 		return nil, err
@@ -0,0 +1,199 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ListCache is a file-backed cache of the results of list queries. It allows a command, like apply, to reuse the
+// objects listed by an earlier command, like diff, in the same pipeline instead of listing all types again. Entries
+// are keyed by the cluster, app, tag, environment and query scope and expire after a configured age.
+type ListCache struct {
+	file string
+	ttl  time.Duration
+	now  func() time.Time
+}
+
+// NewListCache returns a list cache that stores results in the supplied file. Entries that are older than the
+// supplied TTL are ignored.
+func NewListCache(file string, ttl time.Duration) *ListCache {
+	return &ListCache{file: file, ttl: ttl, now: time.Now}
+}
+
+// File returns the file backing the cache.
+func (c *ListCache) File() string {
+	return c.file
+}
+
+type cachedObject struct {
+	Group       string            `json:"group,omitempty"`
+	Version     string            `json:"version"`
+	Kind        string            `json:"kind"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Name        string            `json:"name"`
+	App         string            `json:"app,omitempty"`
+	Tag         string            `json:"tag,omitempty"`
+	Component   string            `json:"component,omitempty"`
+	Env         string            `json:"env,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Identity    string            `json:"identity,omitempty"`
+}
+
+func toCachedObject(o *basicObject) cachedObject {
+	return cachedObject{
+		Group:       o.gvk.Group,
+		Version:     o.gvk.Version,
+		Kind:        o.gvk.Kind,
+		Namespace:   o.namespace,
+		Name:        o.name,
+		App:         o.app,
+		Tag:         o.tag,
+		Component:   o.component,
+		Env:         o.env,
+		Annotations: o.anns,
+		Identity:    o.identity,
+	}
+}
+
+func (o cachedObject) toBasicObject() *basicObject {
+	return &basicObject{
+		objectKey: objectKey{
+			gvk:       schema.GroupVersionKind{Group: o.Group, Version: o.Version, Kind: o.Kind},
+			namespace: o.Namespace,
+			name:      o.Name,
+		},
+		app:       o.App,
+		tag:       o.Tag,
+		component: o.Component,
+		env:       o.Env,
+		anns:      o.Annotations,
+		identity:  o.Identity,
+	}
+}
+
+type cacheEntry struct {
+	Created time.Time      `json:"created"`
+	Objects []cachedObject `json:"objects"`
+}
+
+type cacheContents struct {
+	Entries map[string]cacheEntry `json:"entries"`
+}
+
+// cacheKey returns the cache key for the supplied query config against the supplied cluster.
+func (s ListQueryConfig) cacheKey(cluster string) string {
+	ns := append([]string{}, s.Namespaces...)
+	sort.Strings(ns)
+	return fmt.Sprintf("cluster=%s,app=%s,tag=%s,env=%s,namespaces=%s,clusterObjects=%t,clusterScopedLists=%t,foreign=%t,includeOwned=%t",
+		cluster, s.Application, s.Tag, s.Environment, strings.Join(ns, ":"), s.ClusterObjects, s.ClusterScopedLists, s.Foreign, s.IncludeOwned)
+}
+
+func (c *ListCache) load() (*cacheContents, error) {
+	ret := &cacheContents{Entries: map[string]cacheEntry{}}
+	b, err := ioutil.ReadFile(c.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ret, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, ret); err != nil {
+		return nil, fmt.Errorf("unmarshal %s: %v", c.file, err)
+	}
+	if ret.Entries == nil {
+		ret.Entries = map[string]cacheEntry{}
+	}
+	return ret, nil
+}
+
+// save writes the supplied contents to the cache file after removing expired entries. The file is written
+// atomically such that concurrent readers never see partial contents.
+func (c *ListCache) save(contents *cacheContents) error {
+	for k, e := range contents.Entries {
+		if c.expired(e) {
+			delete(contents.Entries, k)
+		}
+	}
+	b, err := json.MarshalIndent(contents, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(c.file)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(c.file)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.file)
+}
+
+func (c *ListCache) expired(e cacheEntry) bool {
+	return c.ttl > 0 && c.now().Sub(e.Created) > c.ttl
+}
+
+// get returns the cached objects for the supplied key, if present and not expired. When consume is true,
+// the entry is removed from the cache such that it cannot be used once the cluster has been changed.
+func (c *ListCache) get(key string, consume bool) ([]cachedObject, bool, error) {
+	contents, err := c.load()
+	if err != nil {
+		return nil, false, err
+	}
+	e, ok := contents.Entries[key]
+	if !ok || c.expired(e) {
+		return nil, false, nil
+	}
+	if consume {
+		delete(contents.Entries, key)
+		if err := c.save(contents); err != nil {
+			return nil, false, err
+		}
+	}
+	return e.Objects, true, nil
+}
+
+// put stores the supplied objects under the supplied key.
+func (c *ListCache) put(key string, objects []cachedObject) error {
+	contents, err := c.load()
+	if err != nil {
+		return err
+	}
+	if objects == nil {
+		objects = []cachedObject{}
+	}
+	contents.Entries[key] = cacheEntry{Created: c.now(), Objects: objects}
+	return c.save(contents)
+}
@@ -0,0 +1,86 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestListCache(t *testing.T) {
+	now := time.Now()
+	cache := NewListCache(filepath.Join(t.TempDir(), "cache", "lists.json"), time.Minute)
+	cache.now = func() time.Time { return now }
+	scope := ListQueryConfig{
+		Application:    "app",
+		Environment:    "dev",
+		ListQueryScope: ListQueryScope{Namespaces: []string{"ns2", "ns1"}, ClusterObjects: true},
+		Cache:          cache,
+	}
+	key := scope.cacheKey("https://dev-server")
+	assert.Equal(t, "cluster=https://dev-server,app=app,tag=,env=dev,namespaces=ns1:ns2,clusterObjects=true,clusterScopedLists=false,foreign=false,includeOwned=false", key)
+
+	_, ok, err := cache.get(key, false)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	objects := []cachedObject{
+		{Version: "v1", Kind: "ConfigMap", Namespace: "ns1", Name: "cm", App: "app", Env: "dev", Component: "c1"},
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole", Name: "cr", App: "app", Env: "dev", Component: "c2"},
+	}
+	require.NoError(t, cache.put(key, objects))
+
+	c := &Client{defaultNs: "default", cluster: "https://dev-server"}
+	coll, ok := c.cachedObjects(context.Background(), scope)
+	require.True(t, ok)
+	assert.Equal(t, 2, len(coll.ToList()))
+
+	scope.KindFilter = func(gvk schema.GroupVersionKind) bool { return gvk.Kind == "ConfigMap" }
+	coll, ok = c.cachedObjects(context.Background(), scope)
+	require.True(t, ok)
+	list := coll.ToList()
+	require.Equal(t, 1, len(list))
+	assert.Equal(t, "cm", list[0].GetName())
+	assert.Equal(t, "c1", list[0].Component())
+
+	// different clusters and scopes do not share entries
+	_, ok = (&Client{cluster: "context:kind"}).cachedObjects(context.Background(), scope)
+	assert.False(t, ok)
+	other := scope
+	other.Tag = "t1"
+	_, ok = c.cachedObjects(context.Background(), other)
+	assert.False(t, ok)
+
+	// expired entries are ignored
+	cache.now = func() time.Time { return now.Add(2 * time.Minute) }
+	_, ok = c.cachedObjects(context.Background(), scope)
+	assert.False(t, ok)
+	cache.now = func() time.Time { return now }
+
+	// consumed entries are removed
+	scope.ConsumeCache = true
+	_, ok = c.cachedObjects(context.Background(), scope)
+	assert.True(t, ok)
+	_, ok = c.cachedObjects(context.Background(), scope)
+	assert.False(t, ok)
+}
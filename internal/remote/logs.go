@@ -0,0 +1,80 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+// PodLogOptions are options to stream logs for a single pod container.
+type PodLogOptions struct {
+	Container string        // the container whose logs are streamed, required for multi-container pods
+	Follow    bool          // follow the log stream
+	TailLines int64         // number of lines from the end of the log to show, all lines if negative
+	Since     time.Duration // only return logs newer than this duration, if non-zero
+}
+
+type podLogger interface {
+	streamLogs(ctx context.Context, namespace, pod string, opts PodLogOptions) (io.ReadCloser, error)
+}
+
+type corePodLogger struct {
+	core typedcorev1.CoreV1Interface
+}
+
+func newPodLogger(conf *rest.Config) (podLogger, error) {
+	cs, err := kubernetes.NewForConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &corePodLogger{core: cs.CoreV1()}, nil
+}
+
+func (c *corePodLogger) streamLogs(ctx context.Context, namespace, pod string, opts PodLogOptions) (io.ReadCloser, error) {
+	lo := &corev1.PodLogOptions{
+		Container: opts.Container,
+		Follow:    opts.Follow,
+	}
+	if opts.TailLines >= 0 {
+		tail := opts.TailLines
+		lo.TailLines = &tail
+	}
+	if opts.Since > 0 {
+		secs := int64(opts.Since.Round(time.Second) / time.Second)
+		if secs == 0 {
+			secs = 1
+		}
+		lo.SinceSeconds = &secs
+	}
+	return c.core.Pods(namespace).GetLogs(pod, lo).Stream(ctx)
+}
+
+// PodLogs returns a stream of logs for the supplied pod.
+func (c *Client) PodLogs(ctx context.Context, namespace, pod string, opts PodLogOptions) (io.ReadCloser, error) {
+	if c.pods == nil {
+		return nil, fmt.Errorf("pod logs not supported by client")
+	}
+	return c.pods.streamLogs(ctx, namespace, pod, opts)
+}
@@ -0,0 +1,118 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/remote/k8smeta"
+	"github.com/splunk/qbec/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// openAPIV3Discovery adds the retrieval of OpenAPI v3 documents for group versions to a discovery interface.
+type openAPIV3Discovery struct {
+	discovery.DiscoveryInterface
+	l      sync.Mutex
+	loaded bool
+	paths  map[string]string // server relative URLs of documents keyed by path, for example apis/apps/v1
+	err    error
+}
+
+// loadPaths loads the paths of the OpenAPI v3 documents published by the server.
+func (o *openAPIV3Discovery) loadPaths(ctx context.Context) (map[string]string, error) {
+	rc := o.RESTClient()
+	if rc == nil {
+		return nil, k8smeta.ErrOpenAPIV3NotFound
+	}
+	b, err := rc.Get().AbsPath("/openapi/v3").Do(ctx).Raw()
+	if err != nil {
+		if apiErrors.IsNotFound(err) {
+			return nil, k8smeta.ErrOpenAPIV3NotFound
+		}
+		return nil, errors.Wrap(err, "get OpenAPI v3 paths")
+	}
+	var doc struct {
+		Paths map[string]struct {
+			ServerRelativeURL string `json:"serverRelativeURL"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, errors.Wrap(err, "unmarshal OpenAPI v3 paths")
+	}
+	ret := map[string]string{}
+	for p, v := range doc.Paths {
+		ret[p] = v.ServerRelativeURL
+	}
+	return ret, nil
+}
+
+// documentPaths returns the paths of the OpenAPI v3 documents, loading them once. Failures caused by
+// the cancellation of the supplied context are not remembered so that a subsequent call can retry.
+func (o *openAPIV3Discovery) documentPaths(ctx context.Context) (map[string]string, error) {
+	o.l.Lock()
+	defer o.l.Unlock()
+	if o.loaded {
+		return o.paths, o.err
+	}
+	paths, err := o.loadPaths(ctx)
+	if err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+	o.paths, o.err, o.loaded = paths, err, true
+	return o.paths, o.err
+}
+
+// OpenAPIV3Schema implements the k8smeta.OpenAPIV3Discovery interface.
+func (o *openAPIV3Discovery) OpenAPIV3Schema(ctx context.Context, gv schema.GroupVersion) (_ []byte, finalErr error) {
+	ctx, span := telemetry.Start(ctx, "get OpenAPI v3 document", attribute.String("qbec.groupVersion", gv.String()))
+	defer func() { telemetry.End(span, finalErr) }()
+	paths, err := o.documentPaths(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p := "apis/" + gv.String()
+	if gv.Group == "" {
+		p = "api/" + gv.Version
+	}
+	rel, ok := paths[p]
+	if !ok || rel == "" {
+		return nil, k8smeta.ErrOpenAPIV3NotFound
+	}
+	u, err := url.Parse(rel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse OpenAPI v3 URL %s", rel)
+	}
+	req := o.RESTClient().Get().AbsPath(u.Path)
+	for k, values := range u.Query() {
+		for _, v := range values {
+			req = req.Param(k, v)
+		}
+	}
+	b, err := req.Do(ctx).Raw()
+	if err != nil {
+		return nil, errors.Wrapf(err, "get OpenAPI v3 document for %s", gv)
+	}
+	return b, nil
+}
@@ -0,0 +1,103 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/splunk/qbec/internal/remote/k8smeta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+func newV3Discovery(t *testing.T, handler http.HandlerFunc) *openAPIV3Discovery {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	disco, err := discovery.NewDiscoveryClientForConfig(&rest.Config{Host: srv.URL})
+	require.NoError(t, err)
+	return &openAPIV3Discovery{DiscoveryInterface: disco}
+}
+
+func TestOpenAPIV3Discovery(t *testing.T) {
+	var pathCalls int
+	o := newV3Discovery(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/openapi/v3":
+			pathCalls++
+			w.Write([]byte(`{"paths":{"api/v1":{"serverRelativeURL":"/openapi/v3/api/v1?hash=abc"},"apis/apps/v1":{"serverRelativeURL":"/openapi/v3/apis/apps/v1?hash=def"}}}`))
+		case "/openapi/v3/api/v1", "/openapi/v3/apis/apps/v1":
+			w.Write([]byte(`{"path":"` + r.URL.Path + `","hash":"` + r.URL.Query().Get("hash") + `"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	b, err := o.OpenAPIV3Schema(context.TODO(), schema.GroupVersion{Version: "v1"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"path":"/openapi/v3/api/v1","hash":"abc"}`, string(b))
+	b, err = o.OpenAPIV3Schema(context.TODO(), schema.GroupVersion{Group: "apps", Version: "v1"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"path":"/openapi/v3/apis/apps/v1","hash":"def"}`, string(b))
+	_, err = o.OpenAPIV3Schema(context.TODO(), schema.GroupVersion{Group: "batch", Version: "v1"})
+	assert.Equal(t, k8smeta.ErrOpenAPIV3NotFound, err)
+	assert.Equal(t, 1, pathCalls)
+}
+
+func TestOpenAPIV3DiscoveryCancelled(t *testing.T) {
+	var pathCalls int
+	o := newV3Discovery(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/openapi/v3":
+			pathCalls++
+			w.Write([]byte(`{"paths":{"api/v1":{"serverRelativeURL":"/openapi/v3/api/v1"}}}`))
+		case "/openapi/v3/api/v1":
+			w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := o.OpenAPIV3Schema(ctx, schema.GroupVersion{Version: "v1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "context canceled")
+	assert.Equal(t, 0, pathCalls)
+
+	// the failure due to cancellation is not remembered
+	b, err := o.OpenAPIV3Schema(context.Background(), schema.GroupVersion{Version: "v1"})
+	require.NoError(t, err)
+	assert.Equal(t, `{}`, string(b))
+	assert.Equal(t, 1, pathCalls)
+}
+
+func TestOpenAPIV3DiscoveryNotSupported(t *testing.T) {
+	o := newV3Discovery(t, http.NotFound)
+	_, err := o.OpenAPIV3Schema(context.TODO(), schema.GroupVersion{Version: "v1"})
+	assert.Equal(t, k8smeta.ErrOpenAPIV3NotFound, err)
+
+	o = newV3Discovery(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	_, err = o.OpenAPIV3Schema(context.TODO(), schema.GroupVersion{Version: "v1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "get OpenAPI v3 paths")
+}
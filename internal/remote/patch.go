@@ -112,13 +112,50 @@ func deleteEmpty(parent map[string]interface{}, key string) {
 	}
 }
 
+// ServerMetadataFields are metadata fields that are always set by the server and are never part of the
+// desired state of an object.
+var ServerMetadataFields = []string{"uid", "resourceVersion", "creationTimestamp", "generation", "managedFields", "selfLink"}
+
+// removeServerFields removes fields that are populated by the server from the supplied unmarshaled patch. These are
+// the status of the object, the server-set metadata fields and null creation timestamps in nested metadata, like
+// that of pod templates, which are emitted by some tools and dropped by the server.
+func removeServerFields(root map[string]interface{}) {
+	delete(root, "status")
+	if meta, ok := root["metadata"].(map[string]interface{}); ok {
+		for _, f := range ServerMetadataFields {
+			delete(meta, f)
+		}
+	}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		value, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		if meta, ok := value["metadata"].(map[string]interface{}); ok {
+			if ts, ok := meta["creationTimestamp"]; ok && ts == nil {
+				delete(meta, "creationTimestamp")
+			}
+		}
+		for _, child := range value {
+			walk(child)
+		}
+	}
+	for k, v := range root {
+		if k != "metadata" {
+			walk(v)
+		}
+	}
+}
+
 // isEmptyPatch returns true if the unmarshaled version of the JSON patch is an empty object or only
-// contains empty objects. It makes an assumption that there is actually no reason an empty object
+// contains empty objects, once fields populated by the server have been removed from it. It makes an assumption that there is actually no reason an empty object
 // needs to be updated for a Kubernetes resource considering that the server would already have an object
 // there on initial create if needed. Things considered empty will be of the form:
 //  {}
 //  { metadata: { labels: {}, annotations: {} }
 //  { metadata: { labels: {}, annotations: {} }, spec: { foo: { bar: {} } } }
+//  { metadata: { generation: 2 }, spec: { template: { metadata: { creationTimestamp: null } } }, status: {...} }
 //
 func isEmptyPatch(patch []byte) bool {
 	var root map[string]interface{}
@@ -127,6 +164,7 @@ func isEmptyPatch(patch []byte) bool {
 		sio.Warnf("could not unmarshal patch %s", patch)
 		return false // assume the worst
 	}
+	removeServerFields(root)
 	for k := range root {
 		deleteEmpty(root, k)
 	}
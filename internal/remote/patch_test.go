@@ -0,0 +1,50 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package remote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsEmptyPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		empty bool
+	}{
+		{name: "empty", patch: `{}`, empty: true},
+		{name: "empty maps", patch: `{"metadata":{"labels":{},"annotations":{}},"spec":{"foo":{"bar":{}}}}`, empty: true},
+		{name: "status", patch: `{"status":{"replicas":3,"conditions":[{"type":"Ready"}]}}`, empty: true},
+		{name: "server metadata", patch: `{"metadata":{"generation":4,"creationTimestamp":null,"resourceVersion":"10","uid":"u1","managedFields":[],"selfLink":"/x"}}`, empty: true},
+		{name: "template timestamp", patch: `{"spec":{"template":{"metadata":{"creationTimestamp":null}}}}`, empty: true},
+		{name: "job template timestamp", patch: `{"spec":{"jobTemplate":{"spec":{"template":{"metadata":{"creationTimestamp":null}}}}}}`, empty: true},
+		{name: "list", patch: `{"spec":{"templates":[{"metadata":{"creationTimestamp":null}}]}}`, empty: false},
+		{name: "all server fields", patch: `{"metadata":{"generation":2},"spec":{"template":{"metadata":{"creationTimestamp":null}}},"status":{"observedGeneration":2}}`, empty: true},
+		{name: "template timestamp value", patch: `{"spec":{"template":{"metadata":{"creationTimestamp":"2021-01-01T00:00:00Z"}}}}`, empty: false},
+		{name: "nested generation", patch: `{"spec":{"template":{"metadata":{"generation":2}}}}`, empty: false},
+		{name: "label", patch: `{"metadata":{"generation":2,"labels":{"foo":"bar"}}}`, empty: false},
+		{name: "spec change", patch: `{"spec":{"replicas":2},"status":{"replicas":1}}`, empty: false},
+		{name: "bad", patch: `{`, empty: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.empty, isEmptyPatch([]byte(test.patch)))
+		})
+	}
+}
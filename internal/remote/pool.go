@@ -55,16 +55,53 @@ func (c *clientPoolImpl) clientForGroupVersionKind(kind schema.GroupVersionKind)
 		return existingClient, nil
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(c.configFor(kind))
+	if err != nil {
+		return nil, err
+	}
+	c.clients[gv] = dynamicClient
+	return dynamicClient, nil
+}
+
+// clientWithWarnings returns a new client for the specified groupVersion that reports warnings returned by the server
+// to the supplied handler. The client is not cached.
+func (c *clientPoolImpl) clientWithWarnings(kind schema.GroupVersionKind, h restclient.WarningHandler) (dynamic.Interface, error) {
+	c.lock.RLock()
+	conf := c.configFor(kind)
+	c.lock.RUnlock()
+	conf.WarningHandler = h
+	return dynamic.NewForConfig(conf)
+}
+
+// configFor returns a copy of the config for the specified groupVersion.
+func (c *clientPoolImpl) configFor(kind schema.GroupVersionKind) *restclient.Config {
 	// avoid changing the original config
 	confCopy := *c.config
 	conf := &confCopy
+	gv := kind.GroupVersion()
 	conf.APIPath = c.apiPathResolverFunc(kind)
 	conf.GroupVersion = &gv
+	return conf
+}
 
-	dynamicClient, err := dynamic.NewForConfig(conf)
-	if err != nil {
-		return nil, err
+// warningCollector is a warning handler that collects the warnings returned by the server.
+type warningCollector struct {
+	l        sync.Mutex
+	warnings []string
+}
+
+// HandleWarningHeader implements the rest.WarningHandler interface.
+func (w *warningCollector) HandleWarningHeader(code int, agent string, text string) {
+	if code != 299 || text == "" {
+		return
 	}
-	c.clients[gv] = dynamicClient
-	return dynamicClient, nil
+	w.l.Lock()
+	defer w.l.Unlock()
+	w.warnings = append(w.warnings, text)
+}
+
+func (w *warningCollector) get() []string {
+	w.l.Lock()
+	defer w.l.Unlock()
+	return w.warnings
 }
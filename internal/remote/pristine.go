@@ -183,7 +183,35 @@ func getPristineVersion(obj *unstructured.Unstructured, includeFallback bool) (*
 
 // GetPristineVersionForDiff interrogates annotations and extracts the pristine version of the supplied
 // live object. If no annotations are found, it halfheartedly deletes known runtime information that is
-// set on the server and returns the supplied object with those attributes removed.
+// set on the server and returns the supplied object with those attributes removed. The content hash annotation
+// is never part of the returned object.
 func GetPristineVersionForDiff(obj *unstructured.Unstructured) (*unstructured.Unstructured, string) {
-	return getPristineVersion(obj, true)
+	out, source := getPristineVersion(obj, true)
+	if out != nil {
+		out = withoutContentHash(out)
+	}
+	return out, source
+}
+
+// GetPristineVersionForRollback extracts the configuration last applied to the supplied live object from its
+// annotations. It returns nil if no such configuration was recorded.
+func GetPristineVersionForRollback(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	out, _ := getPristineVersion(obj.DeepCopy(), false)
+	return out
+}
+
+// GetPristineVersionFromAnnotations extracts the configuration last applied to an object from the supplied
+// annotations, typically obtained from a list query, without its content hash annotation. It returns nil if no
+// such configuration was recorded.
+func GetPristineVersionFromAnnotations(annotations map[string]string) (*unstructured.Unstructured, string) {
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	for _, p := range []pristineReader{qbecPristine{}, kubectlPristine{}} {
+		out, str := p.getPristine(annotations, nil)
+		if out != nil {
+			return withoutContentHash(out), str
+		}
+	}
+	return nil, ""
 }
@@ -222,3 +222,20 @@ func TestCreateFromPristine(t *testing.T) {
 	require.Nil(t, err)
 	a.EqualValues(un.Object, pObj)
 }
+
+func TestPristineForRollback(t *testing.T) {
+	un := loadFile(t, "input.yaml")
+	obj := model.NewK8sLocalObject(un.Object, model.LocalAttrs{App: "app", Component: "comp1", Env: "dev"})
+	annotated, err := qbecPristine{}.createFromPristine(obj)
+	require.NoError(t, err)
+	live := annotated.ToUnstructured()
+	live.SetResourceVersion("10")
+	p := GetPristineVersionForRollback(live)
+	require.NotNil(t, p)
+	a := assert.New(t)
+	a.EqualValues(obj.ToUnstructured().Object, p.Object)
+	a.Equal("10", live.GetResourceVersion())
+
+	live.SetAnnotations(nil)
+	a.Nil(GetPristineVersionForRollback(live))
+}
@@ -27,6 +27,8 @@ import (
 	"github.com/pkg/errors"
 	"github.com/splunk/qbec/internal/model"
 	"github.com/splunk/qbec/internal/sio"
+	"github.com/splunk/qbec/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
 	apiErrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -49,25 +51,25 @@ type objectLister struct {
 	queryConfig
 }
 
-func (o *objectLister) listObjectsOfType(ctx context.Context, gvk schema.GroupVersionKind, namespace string) ([]*basicObject, error) {
+func (o *objectLister) listObjectsOfType(ctx context.Context, gvk schema.GroupVersionKind, namespace string) (_ []*basicObject, finalErr error) {
+	ctx, span := telemetry.Start(ctx, "list objects of type",
+		attribute.String("k8s.group", gvk.Group),
+		attribute.String("k8s.kind", gvk.Kind),
+		attribute.String("k8s.namespace", namespace),
+	)
+	defer func() { telemetry.End(span, finalErr) }()
 	startTime := time.Now()
 	defer func() {
 		if o.verbosity > 0 {
-			sio.Debugf("list objects: type=%s,namespace=%q took %v\n", gvk, namespace, time.Since(startTime).Round(time.Millisecond))
+			sio.For(ctx).Debugf("list objects: type=%s,namespace=%q took %v\n", gvk, namespace, time.Since(startTime).Round(time.Millisecond))
 		}
 	}()
 	xface, err := o.resourceProvider(gvk, namespace)
 	if err != nil {
 		return nil, errors.Wrap(err, fmt.Sprintf("get resource interface for %s", gvk))
 	}
-	ls := fmt.Sprintf("%s=%s,%s=%s", model.QbecNames.ApplicationLabel, o.scope.Application, model.QbecNames.EnvironmentLabel, o.scope.Environment)
-	if o.scope.Tag == "" {
-		ls = fmt.Sprintf("%s,!%s", ls, model.QbecNames.TagLabel)
-	} else {
-		ls = fmt.Sprintf("%s,%s=%s", ls, model.QbecNames.TagLabel, o.scope.Tag)
-	}
 	initialOpts := &metav1.ListOptions{
-		LabelSelector: ls,
+		LabelSelector: o.scope.LabelSelector(),
 		Limit:         o.scope.Limit,
 	}
 	var list = &unstructured.UnstructuredList{}
@@ -83,7 +85,7 @@ func (o *objectLister) listObjectsOfType(ctx context.Context, gvk schema.GroupVe
 	})
 	if err != nil {
 		if apiErrors.IsForbidden(err) {
-			sio.Warnf("not authorized to list %s, error ignored\n", gvk)
+			sio.For(ctx).Warnf("not authorized to list %s, error ignored\n", gvk)
 			return nil, nil
 		}
 		return nil, err
@@ -102,14 +104,18 @@ outer:
 			return nil, fmt.Errorf("dunno how to process object of type %v", reflect.TypeOf(obj))
 		}
 
-		// check if the object has been created by a controller and, if so, skip it
-		refs := un.GetOwnerReferences()
-		for _, ref := range refs {
-			if ref.Controller != nil && *ref.Controller {
-				if o.verbosity > 0 {
-					sio.Debugf("ignore %s %s since it was created by a controller\n", gvk, un.GetName())
+		// check if the object has been created by a controller and, if so, skip it unless owned objects
+		// have been explicitly requested. Such objects often carry qbec labels propagated from their parents
+		// and are recreated by the controller when deleted.
+		if !o.scope.IncludeOwned {
+			refs := un.GetOwnerReferences()
+			for _, ref := range refs {
+				if ref.Controller != nil && *ref.Controller {
+					if o.verbosity > 0 {
+						sio.For(ctx).Debugf("ignore %s %s since it is owned by %s %s\n", gvk, un.GetName(), ref.Kind, ref.Name)
+					}
+					continue outer
 				}
-				continue outer
 			}
 		}
 
@@ -117,6 +123,9 @@ outer:
 		if labels == nil {
 			labels = map[string]string{}
 		}
+		if o.scope.Foreign && o.scope.isOwn(labels) {
+			continue
+		}
 		anns := un.GetAnnotations()
 		if anns == nil {
 			anns = map[string]string{}
@@ -128,12 +137,15 @@ outer:
 				name:      un.GetName(),
 			},
 			app:       labels[model.QbecNames.ApplicationLabel],
+			tag:       labels[model.QbecNames.TagLabel],
 			component: anns[model.QbecNames.ComponentAnnotation],
 			env:       labels[model.QbecNames.EnvironmentLabel],
 			anns:      un.GetAnnotations(),
+			identity:  labels[model.QbecNames.IdentityLabel],
 		}
 		ret = append(ret, mm)
 	}
+	span.SetAttributes(attribute.Int("qbec.objects", len(ret)))
 	return ret, nil
 }
 
@@ -181,7 +193,11 @@ func (o *objectLister) serverObjects(ctx context.Context, coll *collection) erro
 			}
 			localType := gvk
 			workers = append(workers, func() {
+				start := time.Now()
 				ret, err := o.listObjectsOfType(ctx, localType, ns)
+				if o.scope.Observer != nil {
+					o.scope.Observer.Done(localType, ns, len(ret), time.Since(start), err)
+				}
 				add(localType, ns, ret, err)
 			})
 		}
@@ -194,7 +210,7 @@ func (o *objectLister) serverObjects(ctx context.Context, coll *collection) erro
 				addQueries(o.namespacedTypes, ns)
 			}
 		default:
-			sio.Debugln("using cluster scoped queries for multiple namespaces")
+			sio.For(ctx).Debugln("using cluster scoped queries for multiple namespaces")
 			addQueries(o.namespacedTypes, "")
 		}
 	}
@@ -208,6 +224,10 @@ func (o *objectLister) serverObjects(ctx context.Context, coll *collection) erro
 		concurrency = 5
 	}
 
+	if o.scope.Observer != nil {
+		o.scope.Observer.Start(len(workers))
+	}
+
 	ch := make(chan func(), len(workers))
 	for _, w := range workers {
 		ch <- w
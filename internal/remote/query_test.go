@@ -3,9 +3,15 @@ package remote
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/model"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -101,3 +107,228 @@ func TestListPagination(t *testing.T) {
 		//	t.Fatalf("expected items to be %d but found %d", totalItemsInList, actual)
 	}
 }
+
+func TestListForeign(t *testing.T) {
+	tf := cmdtesting.NewTestFactory().WithNamespace("test")
+	defer tf.Cleanup()
+
+	listMapping := map[schema.GroupVersionResource]string{
+		{Group: "", Version: "v1", Resource: "secrets"}: "SecretList",
+	}
+	tf.FakeDynamicClient = dynamicfakeclient.NewSimpleDynamicClientWithCustomListKinds(scheme.Scheme, listMapping)
+	own := newUnstructured("v1", "Secret", "default", "own")
+	otherApp := newUnstructured("v1", "Secret", "default", "other-app")
+	otherApp.SetLabels(map[string]string{"qbec.io/application": "app2", "qbec.io/environment": "env"})
+	otherTag := newUnstructured("v1", "Secret", "default", "other-tag")
+	otherTag.SetLabels(map[string]string{"qbec.io/application": "app", "qbec.io/environment": "env", "qbec.io/tag": "t1"})
+	var selector string
+	tf.FakeDynamicClient.PrependReactor("list", "secrets", func(action faketesting.Action) (handled bool, ret runtime.Object, err error) {
+		selector = action.(faketesting.ListAction).GetListRestrictions().Labels.String()
+		return true, newUnstructuredList("v1", "SecretList", 0, own, otherApp, otherTag), nil
+	})
+	qc := queryConfig{
+		scope: ListQueryConfig{
+			Application: "app",
+			Environment: "env",
+			Foreign:     true,
+		},
+		resourceProvider: func(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+			return tf.FakeDynamicClient.Resource(schema.GroupVersionResource{Resource: "secrets", Version: "v1"}), nil
+		},
+	}
+	ol := objectLister{qc}
+	objs, err := ol.listObjectsOfType(context.TODO(), schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, "default")
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	if selector != "qbec.io/application" {
+		t.Fatalf("unexpected label selector %q", selector)
+	}
+	if len(objs) != 2 {
+		t.Fatalf("expected 2 foreign objects, got %d", len(objs))
+	}
+	if objs[0].GetName() != "other-app" || objs[0].Application() != "app2" {
+		t.Fatalf("unexpected first object %s, app %s", objs[0].GetName(), objs[0].Application())
+	}
+	if objs[1].GetName() != "other-tag" || objs[1].Tag() != "t1" {
+		t.Fatalf("unexpected second object %s, tag %s", objs[1].GetName(), objs[1].Tag())
+	}
+}
+
+func TestListOwned(t *testing.T) {
+	tf := cmdtesting.NewTestFactory().WithNamespace("test")
+	defer tf.Cleanup()
+
+	listMapping := map[schema.GroupVersionResource]string{
+		{Group: "", Version: "v1", Resource: "secrets"}: "SecretList",
+	}
+	tf.FakeDynamicClient = dynamicfakeclient.NewSimpleDynamicClientWithCustomListKinds(scheme.Scheme, listMapping)
+	plain := newUnstructured("v1", "Secret", "default", "plain")
+	owned := newUnstructured("v1", "Secret", "default", "owned")
+	isController := true
+	owned.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "example.com/v1", Kind: "Foo", Name: "foo", Controller: &isController}})
+	referenced := newUnstructured("v1", "Secret", "default", "referenced")
+	referenced.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "example.com/v1", Kind: "Foo", Name: "foo"}})
+	tf.FakeDynamicClient.PrependReactor("list", "secrets", func(action faketesting.Action) (handled bool, ret runtime.Object, err error) {
+		return true, newUnstructuredList("v1", "SecretList", 0, plain, owned, referenced), nil
+	})
+	for _, includeOwned := range []bool{false, true} {
+		qc := queryConfig{
+			scope: ListQueryConfig{
+				Application:  "app",
+				Environment:  "env",
+				IncludeOwned: includeOwned,
+			},
+			resourceProvider: func(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+				return tf.FakeDynamicClient.Resource(schema.GroupVersionResource{Resource: "secrets", Version: "v1"}), nil
+			},
+		}
+		ol := objectLister{qc}
+		objs, err := ol.listObjectsOfType(context.TODO(), schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, "default")
+		if err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+		var names []string
+		for _, o := range objs {
+			names = append(names, o.GetName())
+		}
+		expected := "plain,referenced"
+		if includeOwned {
+			expected = "plain,owned,referenced"
+		}
+		if strings.Join(names, ",") != expected {
+			t.Fatalf("include owned=%t: expected %s, got %v", includeOwned, expected, names)
+		}
+	}
+}
+
+func TestFindTracked(t *testing.T) {
+	tf := cmdtesting.NewTestFactory().WithNamespace("test")
+	defer tf.Cleanup()
+
+	listMapping := map[schema.GroupVersionResource]string{
+		{Group: "", Version: "v1", Resource: "configmaps"}: "ConfigMapList",
+	}
+	tf.FakeDynamicClient = dynamicfakeclient.NewSimpleDynamicClientWithCustomListKinds(scheme.Scheme, listMapping)
+	old := newUnstructured("v1", "ConfigMap", "default", "cm-abcde")
+	old.SetCreationTimestamp(metav1.NewTime(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)))
+	latest := newUnstructured("v1", "ConfigMap", "default", "cm-fghij")
+	latest.SetCreationTimestamp(metav1.NewTime(time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)))
+	deleting := newUnstructured("v1", "ConfigMap", "default", "cm-klmno")
+	deleting.SetCreationTimestamp(metav1.NewTime(time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC)))
+	now := metav1.Now()
+	deleting.SetDeletionTimestamp(&now)
+	var selector string
+	var items []*unstructured.Unstructured
+	tf.FakeDynamicClient.PrependReactor("list", "configmaps", func(action faketesting.Action) (handled bool, ret runtime.Object, err error) {
+		selector = action.(faketesting.ListAction).GetListRestrictions().Labels.String()
+		return true, newUnstructuredList("v1", "ConfigMapList", 0, items...), nil
+	})
+	local := model.NewK8sLocalObject(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"generateName": "cm-",
+			"annotations": map[string]interface{}{
+				model.QbecNames.Directives.GenerateNamePolicy: model.GenerateNamePolicyTrack,
+			},
+		},
+	}, model.LocalAttrs{App: "app", Component: "c1", Env: "env"})
+	id := model.TrackedIdentity(local)
+	ri := tf.FakeDynamicClient.Resource(schema.GroupVersionResource{Resource: "configmaps", Version: "v1"}).Namespace("default")
+
+	_, err := findTracked(context.TODO(), ri, local, id)
+	if err != ErrNotFound {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if expected := "qbec.io/application=app,qbec.io/environment=env,qbec.io/identity=" + id + ",!qbec.io/tag"; selector != expected {
+		t.Fatalf("expected label selector %q, got %q", expected, selector)
+	}
+	for _, u := range []*unstructured.Unstructured{old, latest, deleting} {
+		labels := u.GetLabels()
+		labels[model.QbecNames.IdentityLabel] = id
+		u.SetLabels(labels)
+	}
+	items = []*unstructured.Unstructured{old, latest, deleting}
+	obj, err := findTracked(context.TODO(), ri, local, id)
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	if obj.GetName() != "cm-fghij" {
+		t.Fatalf("expected most recent object, got %s", obj.GetName())
+	}
+}
+
+func TestListLabelSelector(t *testing.T) {
+	tests := []struct {
+		scope    ListQueryConfig
+		expected string
+	}{
+		{ListQueryConfig{Application: "app", Environment: "env"}, "qbec.io/application=app,qbec.io/environment=env,!qbec.io/tag"},
+		{ListQueryConfig{Application: "app", Environment: "env", Tag: "t1"}, "qbec.io/application=app,qbec.io/environment=env,qbec.io/tag=t1"},
+		{ListQueryConfig{Application: "app", Environment: "env", Foreign: true}, "qbec.io/application"},
+	}
+	for _, test := range tests {
+		if actual := test.scope.LabelSelector(); actual != test.expected {
+			t.Errorf("want %q, got %q", test.expected, actual)
+		}
+	}
+}
+
+type testListObserver struct {
+	l       sync.Mutex
+	queries int
+	done    []string
+}
+
+func (o *testListObserver) Start(queries int) {
+	o.queries = queries
+}
+
+func (o *testListObserver) Done(gvk schema.GroupVersionKind, namespace string, objects int, elapsed time.Duration, err error) {
+	o.l.Lock()
+	defer o.l.Unlock()
+	o.done = append(o.done, fmt.Sprintf("%s:%s:%d:%v", gvk.Kind, namespace, objects, err != nil))
+}
+
+func TestListObserver(t *testing.T) {
+	tf := cmdtesting.NewTestFactory().WithNamespace("test")
+	defer tf.Cleanup()
+
+	listMapping := map[schema.GroupVersionResource]string{
+		{Group: "", Version: "v1", Resource: "secrets"}: "SecretList",
+	}
+	tf.FakeDynamicClient = dynamicfakeclient.NewSimpleDynamicClientWithCustomListKinds(scheme.Scheme, listMapping)
+	tf.FakeDynamicClient.PrependReactor("list", "secrets", func(action faketesting.Action) (handled bool, ret runtime.Object, err error) {
+		if action.GetNamespace() == "ns2" {
+			return true, nil, errors.New("boom")
+		}
+		return true, newUnstructuredList("v1", "SecretList", 0, newUnstructured("v1", "Secret", action.GetNamespace(), "s1")), nil
+	})
+	o := &testListObserver{}
+	qc := queryConfig{
+		scope: ListQueryConfig{
+			Application:    "app",
+			Environment:    "env",
+			ListQueryScope: ListQueryScope{Namespaces: []string{"ns1", "ns2"}},
+			Concurrency:    1,
+			Observer:       o,
+		},
+		resourceProvider: func(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+			return tf.FakeDynamicClient.Resource(schema.GroupVersionResource{Resource: "secrets", Version: "v1"}).Namespace(namespace), nil
+		},
+		namespacedTypes: []schema.GroupVersionKind{{Version: "v1", Kind: "Secret"}},
+	}
+	ol := objectLister{qc}
+	err := ol.serverObjects(context.TODO(), newCollection("", nil))
+	if err == nil {
+		t.Fatal("expected list error")
+	}
+	if o.queries != 2 {
+		t.Fatalf("expected 2 queries, got %d", o.queries)
+	}
+	expected := []string{"Secret:ns1:1:false", "Secret:ns2:0:true"}
+	if !reflect.DeepEqual(expected, o.done) {
+		t.Fatalf("unexpected observations, want %v, got %v", expected, o.done)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/sio"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// RetryPolicy determines how idempotent operations that fail with transient server errors are retried.
+type RetryPolicy struct {
+	Retries    int           // number of retries after the first attempt, no retries when 0
+	Backoff    time.Duration // delay before the first retry, doubled for every subsequent retry
+	MaxBackoff time.Duration // maximum delay between retries, no maximum when 0
+}
+
+// isTransient returns true if the supplied error is a server error that may succeed when retried, such as
+// throttling, timeouts and 5xx responses.
+func isTransient(err error) bool {
+	switch {
+	case apiErrors.IsTooManyRequests(err),
+		apiErrors.IsServerTimeout(err),
+		apiErrors.IsTimeout(err),
+		apiErrors.IsInternalError(err),
+		apiErrors.IsServiceUnavailable(err),
+		apiErrors.IsUnexpectedServerError(err):
+		return true
+	}
+	var status apiErrors.APIStatus
+	if errors.As(err, &status) {
+		return status.Status().Code >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// delay returns the delay before the supplied retry, starting at 1. A delay suggested by the server takes
+// precedence over the configured backoff.
+func (p RetryPolicy) delay(retry int, err error) time.Duration {
+	if secs, ok := apiErrors.SuggestsClientDelay(err); ok && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	d := p.Backoff
+	for i := 1; i < retry && (p.MaxBackoff == 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// run runs the supplied function, retrying it when it fails with a transient error, as long as retries remain.
+// The operation name is only used for messages.
+func (p RetryPolicy) run(ctx context.Context, op string, fn func() error) error {
+	for retry := 0; ; retry++ {
+		err := fn()
+		if err == nil || !isTransient(err) {
+			return err
+		}
+		if retry >= p.Retries {
+			if retry > 0 {
+				return errors.Wrapf(err, "giving up after %d attempts", retry+1)
+			}
+			return err
+		}
+		d := p.delay(retry+1, err)
+		sio.For(ctx).Warnf("%s: transient error, retry %d of %d in %v: %v\n", op, retry+1, p.Retries, d, err)
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), op)
+		case <-time.After(d):
+		}
+	}
+}
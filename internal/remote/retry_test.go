@@ -0,0 +1,129 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestIsTransient(t *testing.T) {
+	gr := schema.GroupResource{Group: "apps", Resource: "deployments"}
+	a := assert.New(t)
+	a.True(isTransient(apiErrors.NewTooManyRequests("slow down", 0)))
+	a.True(isTransient(apiErrors.NewServerTimeout(gr, "patch", 0)))
+	a.True(isTransient(apiErrors.NewInternalError(fmt.Errorf("boom"))))
+	a.True(isTransient(apiErrors.NewServiceUnavailable("unavailable")))
+	a.True(isTransient(apiErrors.NewGenericServerResponse(502, "patch", gr, "foo", "bad gateway", 0, true)))
+	a.True(isTransient(errors.Wrap(apiErrors.NewTooManyRequests("slow down", 0), "get object")))
+	a.False(isTransient(apiErrors.NewNotFound(gr, "foo")))
+	a.False(isTransient(apiErrors.NewConflict(gr, "foo", fmt.Errorf("conflict"))))
+	a.False(isTransient(apiErrors.NewBadRequest("bad")))
+	a.False(isTransient(fmt.Errorf("some error")))
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := RetryPolicy{Retries: 5, Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	a := assert.New(t)
+	a.Equal(time.Second, p.delay(1, fmt.Errorf("x")))
+	a.Equal(2*time.Second, p.delay(2, fmt.Errorf("x")))
+	a.Equal(4*time.Second, p.delay(3, fmt.Errorf("x")))
+	a.Equal(5*time.Second, p.delay(4, fmt.Errorf("x")))
+	a.Equal(5*time.Second, p.delay(10, fmt.Errorf("x")))
+	a.Equal(7*time.Second, p.delay(1, apiErrors.NewTooManyRequests("slow down", 7)))
+	p.MaxBackoff = 0
+	a.Equal(8*time.Second, p.delay(4, fmt.Errorf("x")))
+}
+
+func TestRetryRun(t *testing.T) {
+	transient := apiErrors.NewServiceUnavailable("unavailable")
+	tests := []struct {
+		name     string
+		retries  int
+		failures []error
+		calls    int
+		err      string
+	}{
+		{
+			name:  "no failures",
+			calls: 1,
+		},
+		{
+			name:     "no retries",
+			failures: []error{transient},
+			calls:    1,
+			err:      "unavailable",
+		},
+		{
+			name:     "recovers",
+			retries:  3,
+			failures: []error{transient, transient},
+			calls:    3,
+		},
+		{
+			name:     "exhausted",
+			retries:  2,
+			failures: []error{transient, transient, transient, transient},
+			calls:    3,
+			err:      "giving up after 3 attempts: unavailable",
+		},
+		{
+			name:     "non-transient",
+			retries:  3,
+			failures: []error{transient, fmt.Errorf("bad object")},
+			calls:    2,
+			err:      "bad object",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := RetryPolicy{Retries: test.retries, Backoff: time.Millisecond}
+			calls := 0
+			err := p.run(context.Background(), "sync foo", func() error {
+				calls++
+				if calls <= len(test.failures) {
+					return test.failures[calls-1]
+				}
+				return nil
+			})
+			assert.Equal(t, test.calls, calls)
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRetryRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p := RetryPolicy{Retries: 3, Backoff: time.Hour}
+	err := p.run(ctx, "sync foo", func() error { return apiErrors.NewServiceUnavailable("unavailable") })
+	require.Error(t, err)
+	assert.Equal(t, "sync foo: context canceled", err.Error())
+}
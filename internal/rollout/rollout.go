@@ -27,6 +27,7 @@ import (
 	"github.com/splunk/qbec/internal/model"
 	"github.com/splunk/qbec/internal/types"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/watch"
 )
 
@@ -97,6 +98,10 @@ type WatchProvider func(obj model.K8sMeta) (watch.Interface, error)
 type WaitOptions struct {
 	Listener StatusListener
 	Timeout  time.Duration
+	// StatusExpressions are status expressions keyed by type that determine when objects of these types are ready.
+	// They take precedence over built-in status functions. Status expressions set on the object using the
+	// wait-status directive take precedence over these.
+	StatusExpressions map[schema.GroupKind]string
 }
 
 func (w *WaitOptions) setupDefaults() {
@@ -132,9 +137,36 @@ func (ec *errCounter) toSummaryError() error {
 	return fmt.Errorf("%d wait errors", ec.count)
 }
 
+// TimeoutError is returned when objects are not ready before the wait timeout.
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("wait timed out after %v", e.Timeout)
+}
+
 // allow standard status function map to be overridden for tests.
 var statusMapper = types.StatusFuncFor
 
+// StatusFuncFor returns the status function for the supplied object taking status expressions set on the object
+// and the supplied status expressions for its type into account. It returns nil if the readiness of the object
+// cannot be determined.
+func StatusFuncFor(obj model.K8sMeta, exprs map[schema.GroupKind]string) (types.RolloutStatusFunc, error) {
+	expr := obj.GetAnnotations()[model.QbecNames.Directives.WaitStatus]
+	if expr == "" {
+		expr = exprs[obj.GroupVersionKind().GroupKind()]
+	}
+	if expr == "" {
+		return statusMapper(obj), nil
+	}
+	se, err := types.ParseStatusExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	return se.StatusFunc(), nil
+}
+
 // WaitUntilComplete waits for the supplied objects to be ready and returns when they are. An error is returned
 // if the function times out before all objects are ready. Any status listener provider is notified of
 // individual status changes and errors during the wait. Individual watches having errors are turned into a
@@ -147,7 +179,10 @@ func WaitUntilComplete(objects []model.K8sMeta, wp WatchProvider, opts WaitOptio
 
 	// extract objects to wait for
 	for _, obj := range objects {
-		fn := statusMapper(obj)
+		fn, err := StatusFuncFor(obj, opts.StatusExpressions)
+		if err != nil {
+			return errors.Wrapf(err, "%s %s", obj.GetKind(), model.NameForDisplay(obj))
+		}
 		if fn != nil {
 			watchObjects = append(watchObjects, obj)
 			trackers = append(trackers, &statusTracker{obj: obj, fn: fn, wp: wp, listener: opts.Listener})
@@ -189,6 +224,6 @@ func WaitUntilComplete(objects []model.K8sMeta, wp WatchProvider, opts WaitOptio
 	case <-done:
 		return counter.toSummaryError()
 	case <-timeout:
-		return fmt.Errorf("wait timed out after %v", opts.Timeout)
+		return &TimeoutError{Timeout: opts.Timeout}
 	}
 }
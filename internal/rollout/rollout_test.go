@@ -525,3 +525,69 @@ func TestWaitNegative(t *testing.T) {
 		})
 	}
 }
+
+func withStatus(kind, name string, anns map[string]interface{}, status map[string]interface{}) map[string]interface{} {
+	obj := newObject(kind, name, nil, nil)
+	for k, v := range anns {
+		obj["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})[k] = v
+	}
+	if status != nil {
+		obj["status"] = status
+	}
+	return obj
+}
+
+func TestWaitStatusExpressions(t *testing.T) {
+	ready := func(v string) map[string]interface{} {
+		return map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Synced", "status": "True"},
+				map[string]interface{}{"type": "Ready", "status": v},
+			},
+		}
+	}
+	annotated := model.NewK8sObject(withStatus("Baz", "baz1", map[string]interface{}{
+		model.QbecNames.Directives.WaitStatus: ".status.phase == Running",
+	}, nil))
+	mapped := newTestMeta("Qux", "qux1")
+	wf := &watchFactory{
+		eventsMap: map[string][]testEvent{
+			testKey(annotated): {
+				{event: watch.Event{Type: watch.Modified, Object: &unstructured.Unstructured{Object: withStatus("Baz", "baz1", nil, nil)}}},
+				{event: watch.Event{Type: watch.Modified, Object: &unstructured.Unstructured{Object: withStatus("Baz", "baz1", nil, map[string]interface{}{"phase": "Running"})}}},
+			},
+			testKey(mapped): {
+				{event: watch.Event{Type: watch.Modified, Object: &unstructured.Unstructured{Object: withStatus("Qux", "qux1", nil, ready("False"))}}},
+				{event: watch.Event{Type: watch.Modified, Object: &unstructured.Unstructured{Object: withStatus("Qux", "qux1", nil, ready("True"))}}},
+			},
+		},
+	}
+	listener := newTestListener(t)
+	err := WaitUntilComplete([]model.K8sMeta{annotated, mapped}, wf.getWatcher, WaitOptions{
+		Listener: listener,
+		StatusExpressions: map[schema.GroupKind]string{
+			{Group: "apps", Kind: "Qux"}: ".status.conditions[?type==Ready].status == True",
+		},
+	})
+	require.NoError(t, err)
+	a := assert.New(t)
+	a.Equal(2, listener.initObjects)
+	a.Equal(0, listener.remainingObjects)
+	a.Equal([]string{
+		"waiting for .status.phase == Running, value not found",
+		".status.phase == Running satisfied",
+	}, listener.statuses[testKey(annotated)])
+	a.Equal([]string{
+		`waiting for .status.conditions[?type==Ready].status == True, current value "False"`,
+		".status.conditions[?type==Ready].status == True satisfied",
+	}, listener.statuses[testKey(mapped)])
+}
+
+func TestWaitStatusExpressionBad(t *testing.T) {
+	obj := model.NewK8sObject(withStatus("Baz", "baz1", map[string]interface{}{
+		model.QbecNames.Directives.WaitStatus: ".status.phase",
+	}, nil))
+	err := WaitUntilComplete([]model.K8sMeta{obj}, (&watchFactory{}).getWatcher, WaitOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Baz baz1: invalid status expression")
+}
@@ -18,11 +18,16 @@
 package sio
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 const esc = "\x1b["
@@ -55,6 +60,114 @@ func (ce *colors) set(flag bool) {
 
 var ce = &colors{enabled: true}
 
+type quietMode struct {
+	sync.RWMutex
+	enabled bool
+}
+
+func (q *quietMode) isEnabled() bool {
+	q.RLock()
+	defer q.RUnlock()
+	return q.enabled
+}
+
+func (q *quietMode) set(flag bool) {
+	q.Lock()
+	defer q.Unlock()
+	q.enabled = flag
+}
+
+var qm = &quietMode{}
+
+// EnableQuiet enables or disables quiet mode. In quiet mode, all output other than errors is suppressed.
+func EnableQuiet(flag bool) {
+	qm.set(flag)
+}
+
+// QuietEnabled returns true if quiet mode is enabled.
+func QuietEnabled() bool {
+	return qm.isEnabled()
+}
+
+type jsonMode struct {
+	sync.RWMutex
+	enabled bool
+}
+
+func (j *jsonMode) isEnabled() bool {
+	j.RLock()
+	defer j.RUnlock()
+	return j.enabled
+}
+
+func (j *jsonMode) set(flag bool) {
+	j.Lock()
+	defer j.Unlock()
+	j.enabled = flag
+}
+
+var jm = &jsonMode{}
+
+// EnableJSON enables or disables structured output. When enabled, every message is written as a single line
+// of JSON with its severity and timestamp, without colors.
+func EnableJSON(flag bool) {
+	jm.set(flag)
+}
+
+// JSONEnabled returns true if structured output is enabled.
+func JSONEnabled() bool {
+	return jm.isEnabled()
+}
+
+// severities of structured messages
+const (
+	levelInfo   = "info"
+	levelNotice = "notice"
+	levelDebug  = "debug"
+	levelWarn   = "warn"
+	levelError  = "error"
+)
+
+// record is a single structured message.
+type record struct {
+	Time    string                 `json:"time"`
+	Level   string                 `json:"level"`
+	Message string                 `json:"msg"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// now returns the time at which a structured message is written, replaced in tests.
+var now = time.Now
+
+// jsonLock serializes structured messages written from concurrent goroutines.
+var jsonLock sync.Mutex
+
+// writeJSON writes the supplied message as a structured record. Trailing newlines are removed and
+// blank messages are dropped.
+func writeJSON(level, msg string) {
+	writeRecord(level, msg, nil)
+}
+
+// writeRecord writes the supplied message and fields as a structured record.
+func writeRecord(level, msg string, fields map[string]interface{}) {
+	msg = strings.TrimRight(msg, "\n")
+	if strings.TrimSpace(msg) == "" {
+		return
+	}
+	b, err := json.Marshal(record{
+		Time:    now().UTC().Format(time.RFC3339Nano),
+		Level:   level,
+		Message: msg,
+		Fields:  fields,
+	})
+	if err != nil {
+		return
+	}
+	jsonLock.Lock()
+	defer jsonLock.Unlock()
+	fmt.Fprintln(Output, string(b))
+}
+
 // EnableColors enables or disables colored output
 func EnableColors(flag bool) {
 	ce.set(flag)
@@ -67,7 +180,7 @@ func ColorsEnabled() bool {
 
 // ErrorString returns a colorized string representing an error condition.
 func ErrorString(s string) string {
-	if ColorsEnabled() {
+	if ColorsEnabled() && !JSONEnabled() {
 		return fmt.Sprintf("%s%s%s%s", colorRed, attrBold, s, codeReset)
 	}
 	return s
@@ -86,81 +199,366 @@ func reset() {
 }
 
 // Output is the writer to which all prompts, errors and messages go.
-// This is set to standard error by default.
+// This is set to standard error by default. When quiet mode is enabled, only errors are written to it.
 var Output io.Writer = os.Stderr
 
+// statusLock serializes text output, such that messages are never spliced into the status line.
+var statusLock sync.Mutex
+
+// status is the transient line that is shown below all other text output, blank when there is none.
+var status string
+
+// clearLine moves the cursor to the start of the current line and erases it.
+const clearLine = "\r" + esc + "K"
+
+// writeText runs the supplied function that writes text output, temporarily removing the status line such that
+// the output is written above it.
+func writeText(fn func()) {
+	statusLock.Lock()
+	defer statusLock.Unlock()
+	if status != "" {
+		fmt.Fprint(Output, clearLine)
+	}
+	fn()
+	if status != "" {
+		fmt.Fprint(Output, status)
+	}
+}
+
+// SetStatus shows the supplied single line of text, such as a progress indicator, on the last line of the output,
+// replacing the previous status. Messages written while a status is shown are written above it. The status is
+// redrawn using terminal control sequences and is not shown in quiet or structured mode.
+func SetStatus(s string) {
+	if qm.isEnabled() || jm.isEnabled() {
+		return
+	}
+	statusLock.Lock()
+	defer statusLock.Unlock()
+	fmt.Fprint(Output, clearLine+s)
+	status = s
+}
+
+// ClearStatus removes the status line, if any.
+func ClearStatus() {
+	statusLock.Lock()
+	defer statusLock.Unlock()
+	if status != "" {
+		fmt.Fprint(Output, clearLine)
+		status = ""
+	}
+}
+
+// Logger writes messages in the same way as the package level functions, prefixing every line of text output
+// with the scope of the logger, if any. In structured mode, the scope is written as a field of every record.
+// The zero value is a logger without a scope.
+type Logger struct {
+	scope string
+}
+
+// std is the logger used by the package level functions.
+var std Logger
+
+type scopeKey struct{}
+
+// WithScope returns a context that carries the supplied scope, such as the name of an environment, for messages
+// written by loggers obtained from it. This allows output from concurrent operations to be told apart.
+func WithScope(ctx context.Context, scope string) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope)
+}
+
+// For returns a logger for the scope carried by the supplied context.
+func For(ctx context.Context) Logger {
+	scope, _ := ctx.Value(scopeKey{}).(string)
+	return Logger{scope: scope}
+}
+
+// scoped returns the supplied text with every line prefixed with the scope of the logger.
+func (l Logger) scoped(s string) string {
+	if l.scope == "" || s == "" {
+		return s
+	}
+	prefix := "[" + l.scope + "] "
+	trailing := strings.HasSuffix(s, "\n")
+	s = prefix + strings.ReplaceAll(strings.TrimSuffix(s, "\n"), "\n", "\n"+prefix)
+	if trailing {
+		s += "\n"
+	}
+	return s
+}
+
+// fields returns the supplied fields of a structured record along with the scope of the logger.
+func (l Logger) fields(fields map[string]interface{}) map[string]interface{} {
+	if l.scope == "" {
+		return fields
+	}
+	ret := map[string]interface{}{"scope": l.scope}
+	for k, v := range fields {
+		ret[k] = v
+	}
+	return ret
+}
+
+// write writes the supplied message as text with the supplied color codes, or as a structured record at the
+// supplied level.
+func (l Logger) write(level string, msg string, codes ...string) {
+	if jm.isEnabled() {
+		writeRecord(level, msg, l.fields(nil))
+		return
+	}
+	writeText(func() {
+		if len(codes) == 0 {
+			fmt.Fprint(Output, l.scoped(msg))
+			return
+		}
+		startColors(codes...)
+		fmt.Fprint(Output, l.scoped(msg))
+		reset()
+	})
+}
+
 // Println prints the supplied arguments to the standard writer
 func Println(args ...interface{}) {
-	fmt.Fprintln(Output, args...)
+	std.Println(args...)
+}
+
+// Println prints the supplied arguments to the standard writer
+func (l Logger) Println(args ...interface{}) {
+	if qm.isEnabled() {
+		return
+	}
+	l.write(levelInfo, fmt.Sprintln(args...))
 }
 
 // Printf prints the supplied arguments to the standard writer.
 func Printf(format string, args ...interface{}) {
-	fmt.Fprintf(Output, format, args...)
+	std.Printf(format, args...)
+}
+
+// Printf prints the supplied arguments to the standard writer.
+func (l Logger) Printf(format string, args ...interface{}) {
+	if qm.isEnabled() {
+		return
+	}
+	l.write(levelInfo, fmt.Sprintf(format, args...))
+}
+
+// PrintFields prints the supplied message along with fields that describe it. In structured mode, the fields are
+// written as an object under the record. Otherwise, they are printed after the message as key=value pairs in
+// key order.
+func PrintFields(msg string, fields map[string]interface{}) {
+	std.PrintFields(msg, fields)
+}
+
+// PrintFields prints the supplied message along with fields that describe it, like the package level function.
+func (l Logger) PrintFields(msg string, fields map[string]interface{}) {
+	if qm.isEnabled() {
+		return
+	}
+	if jm.isEnabled() {
+		writeRecord(levelInfo, msg, l.fields(fields))
+		return
+	}
+	var keys []string
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := []string{msg}
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%v", k, fields[k]))
+	}
+	writeText(func() {
+		fmt.Fprint(Output, l.scoped(strings.Join(parts, " ")+"\n"))
+	})
+}
+
+// LineWriter is a writer for the output of external programs, like commands run by data sources and hooks.
+// In structured mode, every line is written as a separate record with the source of the output in its fields.
+// Otherwise, output is written as-is, with every line prefixed with the scope of the logger that created the
+// writer, if any. Nothing is written in quiet mode.
+type LineWriter struct {
+	source string
+	log    Logger
+	l      sync.Mutex
+	buf    []byte
+}
+
+// NewLineWriter returns a writer for output produced by the supplied source.
+func NewLineWriter(source string) *LineWriter {
+	return std.NewLineWriter(source)
+}
+
+// NewLineWriter returns a writer for output produced by the supplied source.
+func (l Logger) NewLineWriter(source string) *LineWriter {
+	return &LineWriter{source: source, log: l}
+}
+
+// Write implements the io.Writer interface.
+func (w *LineWriter) Write(p []byte) (int, error) {
+	if qm.isEnabled() {
+		return len(p), nil
+	}
+	if !jm.isEnabled() && w.log.scope == "" {
+		var n int
+		var err error
+		writeText(func() { n, err = Output.Write(p) })
+		return n, err
+	}
+	w.l.Lock()
+	defer w.l.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		pos := bytes.IndexByte(w.buf, '\n')
+		if pos < 0 {
+			break
+		}
+		w.writeLine(string(w.buf[:pos]))
+		w.buf = w.buf[pos+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes a trailing line that is not terminated by a newline.
+func (w *LineWriter) Flush() {
+	w.l.Lock()
+	defer w.l.Unlock()
+	if len(w.buf) > 0 {
+		w.writeLine(string(w.buf))
+		w.buf = nil
+	}
+}
+
+func (w *LineWriter) writeLine(line string) {
+	line = strings.TrimRight(line, "\r")
+	if !jm.isEnabled() {
+		writeText(func() { fmt.Fprint(Output, w.log.scoped(line+"\n")) })
+		return
+	}
+	writeRecord(levelInfo, line, w.log.fields(map[string]interface{}{"source": w.source}))
 }
 
 // Noticeln prints the supplied arguments in a way that they will be noticed.
 // Use sparingly.
 func Noticeln(args ...interface{}) {
-	startColors(attrBold)
-	fmt.Fprintln(Output, args...)
-	reset()
+	std.Noticeln(args...)
+}
+
+// Noticeln prints the supplied arguments in a way that they will be noticed.
+// Use sparingly.
+func (l Logger) Noticeln(args ...interface{}) {
+	if qm.isEnabled() {
+		return
+	}
+	l.write(levelNotice, fmt.Sprintln(args...), attrBold)
 }
 
 // Noticef prints the supplied arguments in a way that they will be noticed.
 // Use sparingly.
 func Noticef(format string, args ...interface{}) {
-	startColors(attrBold)
-	fmt.Fprintf(Output, format, args...)
-	reset()
+	std.Noticef(format, args...)
+}
+
+// Noticef prints the supplied arguments in a way that they will be noticed.
+// Use sparingly.
+func (l Logger) Noticef(format string, args ...interface{}) {
+	if qm.isEnabled() {
+		return
+	}
+	l.write(levelNotice, fmt.Sprintf(format, args...), attrBold)
 }
 
 // Debugln prints the supplied arguments to the standard writer, de-emphasized
 func Debugln(args ...interface{}) {
-	startColors(attrDim)
-	fmt.Fprintln(Output, args...)
-	reset()
+	std.Debugln(args...)
+}
+
+// Debugln prints the supplied arguments to the standard writer, de-emphasized
+func (l Logger) Debugln(args ...interface{}) {
+	if qm.isEnabled() {
+		return
+	}
+	l.write(levelDebug, fmt.Sprintln(args...), attrDim)
 }
 
 // Debugf prints the supplied arguments to the standard writer, de-emphasized.
 func Debugf(format string, args ...interface{}) {
-	startColors(attrDim)
-	fmt.Fprintf(Output, format, args...)
-	reset()
+	std.Debugf(format, args...)
+}
+
+// Debugf prints the supplied arguments to the standard writer, de-emphasized.
+func (l Logger) Debugf(format string, args ...interface{}) {
+	if qm.isEnabled() {
+		return
+	}
+	l.write(levelDebug, fmt.Sprintf(format, args...), attrDim)
 }
 
 // Warnln prints the supplied arguments to the standard writer
 // with some indication for a warning.
 func Warnln(args ...interface{}) {
-	startColors(colorMagenta, attrBold)
-	fmt.Fprint(Output, "[warn] ")
-	fmt.Fprintln(Output, args...)
-	reset()
+	std.Warnln(args...)
+}
+
+// Warnln prints the supplied arguments to the standard writer
+// with some indication for a warning.
+func (l Logger) Warnln(args ...interface{}) {
+	if qm.isEnabled() {
+		return
+	}
+	l.warn(fmt.Sprintln(args...))
 }
 
 // Warnf prints the supplied arguments to the standard writer
 // with some indication for a warning.
 func Warnf(format string, args ...interface{}) {
-	startColors(colorMagenta, attrBold)
-	fmt.Fprint(Output, "[warn] ")
-	fmt.Fprintf(Output, format, args...)
-	reset()
+	std.Warnf(format, args...)
+}
+
+// Warnf prints the supplied arguments to the standard writer
+// with some indication for a warning.
+func (l Logger) Warnf(format string, args ...interface{}) {
+	if qm.isEnabled() {
+		return
+	}
+	l.warn(fmt.Sprintf(format, args...))
+}
+
+func (l Logger) warn(msg string) {
+	if jm.isEnabled() {
+		writeRecord(levelWarn, msg, l.fields(nil))
+		return
+	}
+	l.write(levelWarn, "[warn] "+msg, colorMagenta, attrBold)
 }
 
 // Errorln prints the supplied arguments to the standard writer
 // with some indication that an error has occurred.
 func Errorln(args ...interface{}) {
-	startColors(colorRed, attrBold)
-	fmt.Fprint(Output, unicodeX+" ")
-	fmt.Fprintln(Output, args...)
-	reset()
+	std.Errorln(args...)
+}
+
+// Errorln prints the supplied arguments to the standard writer
+// with some indication that an error has occurred.
+func (l Logger) Errorln(args ...interface{}) {
+	l.error(fmt.Sprintln(args...))
 }
 
 // Errorf prints the supplied arguments to the standard writer
 // with some indication that an error has occurred.
 func Errorf(format string, args ...interface{}) {
-	startColors(colorRed, attrBold)
-	fmt.Fprint(Output, unicodeX+" ")
-	fmt.Fprintf(Output, format, args...)
-	reset()
+	std.Errorf(format, args...)
+}
+
+// Errorf prints the supplied arguments to the standard writer
+// with some indication that an error has occurred.
+func (l Logger) Errorf(format string, args ...interface{}) {
+	l.error(fmt.Sprintf(format, args...))
+}
+
+func (l Logger) error(msg string) {
+	if jm.isEnabled() {
+		writeRecord(levelError, msg, l.fields(nil))
+		return
+	}
+	l.write(levelError, unicodeX+" "+msg, colorRed, attrBold)
 }
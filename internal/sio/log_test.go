@@ -18,8 +18,10 @@ package sio
 
 import (
 	"bytes"
+	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -106,3 +108,184 @@ func TestErrorString(t *testing.T) {
 	x = ErrorString("test")
 	a.NotContains(x, colorRed)
 }
+
+func TestOutputQuiet(t *testing.T) {
+	var buf bytes.Buffer
+	orig := Output
+	origC := ColorsEnabled()
+	defer func() { Output = orig; EnableColors(origC); EnableQuiet(false) }()
+	EnableColors(false)
+	EnableQuiet(true)
+	Output = &buf
+
+	a := assert.New(t)
+	a.True(QuietEnabled())
+
+	Println("this", "is", "a", "message")
+	Warnln("this", "is", "a", "warning")
+	Errorln("this", "is", "an", "error")
+	Noticeln("this", "is", "a", "notice")
+	Debugln("this", "is", "an", "extra")
+
+	Printf("This is %s %s\n", "a", "message")
+	Warnf("This is %s %s\n", "a", "warning")
+	Errorf("This is %s %s\n", "an", "error")
+	Noticef("This is %s %s\n", "a", "notice")
+	Debugf("This is %s %s\n", "an", "extra")
+
+	a.Equal(unicodeX+" this is an error\n"+unicodeX+" This is an error\n", buf.String())
+}
+
+func TestOutputJSON(t *testing.T) {
+	var buf bytes.Buffer
+	orig := Output
+	origC := ColorsEnabled()
+	defer func() { Output = orig; EnableColors(origC); EnableJSON(false); now = time.Now }()
+	EnableColors(true)
+	EnableJSON(true)
+	Output = &buf
+	now = func() time.Time { return time.Date(2021, 3, 4, 5, 6, 7, 0, time.FixedZone("PST", -8*3600)) }
+
+	a := assert.New(t)
+	a.True(JSONEnabled())
+
+	Println("this", "is", "a", "message")
+	Println()
+	Noticeln("this", "is", "a", "notice")
+	Debugf("This is %s %q\n", "an", "extra")
+	Warnf("This is\na %s\n\n", "warning")
+	Errorln("this", "is", "an", "error")
+	a.Equal(`{"time":"2021-03-04T13:06:07Z","level":"info","msg":"this is a message"}
+{"time":"2021-03-04T13:06:07Z","level":"notice","msg":"this is a notice"}
+{"time":"2021-03-04T13:06:07Z","level":"debug","msg":"This is an \"extra\""}
+{"time":"2021-03-04T13:06:07Z","level":"warn","msg":"This is\na warning"}
+{"time":"2021-03-04T13:06:07Z","level":"error","msg":"this is an error"}
+`, buf.String())
+	a.Equal("test", ErrorString("test"))
+
+	buf.Reset()
+	EnableQuiet(true)
+	defer EnableQuiet(false)
+	Warnln("this", "is", "a", "warning")
+	Errorf("This is %s %s\n", "an", "error")
+	a.Equal(`{"time":"2021-03-04T13:06:07Z","level":"error","msg":"This is an error"}
+`, buf.String())
+}
+
+func TestPrintFields(t *testing.T) {
+	var buf bytes.Buffer
+	orig := Output
+	defer func() { Output = orig; EnableJSON(false); now = time.Now }()
+	Output = &buf
+	now = func() time.Time { return time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC) }
+
+	fields := map[string]interface{}{"kind": "ConfigMap", "objects": 10}
+	PrintFields("remote list query", fields)
+	assert.Equal(t, "remote list query kind=ConfigMap objects=10\n", buf.String())
+
+	buf.Reset()
+	EnableJSON(true)
+	PrintFields("remote list query", fields)
+	assert.Equal(t, `{"time":"2021-03-04T05:06:07Z","level":"info","msg":"remote list query","fields":{"kind":"ConfigMap","objects":10}}
+`, buf.String())
+}
+
+func TestLineWriter(t *testing.T) {
+	var buf bytes.Buffer
+	orig := Output
+	defer func() { Output = orig; EnableJSON(false); EnableQuiet(false); now = time.Now }()
+	Output = &buf
+	now = func() time.Time { return time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC) }
+	a := assert.New(t)
+
+	w := NewLineWriter("helm")
+	_, _ = w.Write([]byte("line 1\nline"))
+	_, _ = w.Write([]byte(" 2"))
+	w.Flush()
+	a.Equal("line 1\nline 2", buf.String())
+
+	buf.Reset()
+	EnableJSON(true)
+	_, _ = w.Write([]byte("line 1\nline"))
+	_, _ = w.Write([]byte(" 2\r\nline 3"))
+	a.Equal(`{"time":"2021-03-04T05:06:07Z","level":"info","msg":"line 1","fields":{"source":"helm"}}
+{"time":"2021-03-04T05:06:07Z","level":"info","msg":"line 2","fields":{"source":"helm"}}
+`, buf.String())
+	w.Flush()
+	a.Contains(buf.String(), `"msg":"line 3"`)
+
+	buf.Reset()
+	EnableQuiet(true)
+	n, err := w.Write([]byte("line 1\n"))
+	a.NoError(err)
+	a.Equal(7, n)
+	w.Flush()
+	a.Equal("", buf.String())
+}
+
+func TestScopedOutput(t *testing.T) {
+	var buf bytes.Buffer
+	orig := Output
+	origC := ColorsEnabled()
+	defer func() { Output = orig; EnableColors(origC); EnableJSON(false); now = time.Now }()
+	EnableColors(false)
+	Output = &buf
+	now = func() time.Time { return time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC) }
+	a := assert.New(t)
+
+	a.Equal(Logger{}, For(context.Background()))
+	log := For(WithScope(context.Background(), "dev"))
+	log.Noticef("this is\na %s\n", "notice")
+	log.Warnln("this", "is", "a", "warning")
+	log.Errorf("this is an %s\n", "error")
+	log.PrintFields("remote list query", map[string]interface{}{"objects": 10})
+	w := log.NewLineWriter("helm")
+	_, _ = w.Write([]byte("line 1\nline"))
+	w.Flush()
+	a.Equal(`[dev] this is
+[dev] a notice
+[dev] [warn] this is a warning
+[dev] `+unicodeX+` this is an error
+[dev] remote list query objects=10
+[dev] line 1
+[dev] line
+`, buf.String())
+
+	buf.Reset()
+	EnableJSON(true)
+	log.Noticeln("this", "is", "a", "notice")
+	log.PrintFields("remote list query", map[string]interface{}{"objects": 10})
+	_, _ = w.Write([]byte("line 1\n"))
+	a.Equal(`{"time":"2021-03-04T05:06:07Z","level":"notice","msg":"this is a notice","fields":{"scope":"dev"}}
+{"time":"2021-03-04T05:06:07Z","level":"info","msg":"remote list query","fields":{"objects":10,"scope":"dev"}}
+{"time":"2021-03-04T05:06:07Z","level":"info","msg":"line 1","fields":{"scope":"dev","source":"helm"}}
+`, buf.String())
+}
+
+func TestStatus(t *testing.T) {
+	var buf bytes.Buffer
+	orig, origC := Output, ColorsEnabled()
+	defer func() { Output = orig; EnableColors(origC); EnableJSON(false); EnableQuiet(false) }()
+	Output = &buf
+	EnableColors(false)
+	a := assert.New(t)
+
+	SetStatus("1/2")
+	Warnln("slow")
+	_, _ = NewLineWriter("helm").Write([]byte("output\n"))
+	SetStatus("2/2")
+	ClearStatus()
+	ClearStatus()
+	Println("done")
+	a.Equal("\r\x1b[K1/2\r\x1b[K[warn] slow\n1/2\r\x1b[Koutput\n1/2\r\x1b[K2/2\r\x1b[Kdone\n", buf.String())
+
+	buf.Reset()
+	EnableJSON(true)
+	SetStatus("1/2")
+	ClearStatus()
+	EnableJSON(false)
+	EnableQuiet(true)
+	SetStatus("1/2")
+	ClearStatus()
+	a.Equal("", buf.String())
+}
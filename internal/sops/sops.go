@@ -0,0 +1,66 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package sops decrypts files encrypted using sops (https://github.com/getsops/sops) by running the sops command.
+package sops
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/splunk/qbec/internal/sio"
+)
+
+// DefaultCommand is the sops executable that is run when no command is specified.
+const DefaultCommand = "sops"
+
+// IsEncrypted returns true if the supplied document, parsed from a YAML or JSON file, has the metadata that sops
+// adds to encrypted files.
+func IsEncrypted(doc interface{}) bool {
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	meta, ok := m["sops"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = meta["mac"]
+	return ok
+}
+
+// Decrypt decrypts the supplied file using the supplied sops command, or the default command when empty, and
+// returns the decrypted contents in the same format as the file.
+func Decrypt(ctx context.Context, command string, file string) ([]byte, error) {
+	if command == "" {
+		command = DefaultCommand
+	}
+	args := []string{"--decrypt", file}
+	sio.Debugln(fmt.Sprintf("%s %s", command, strings.Join(args, " ")))
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("sops decrypt %s: %s\n%s", file, err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,50 @@
+/*
+   Copyright 2019 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package sops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsEncrypted(t *testing.T) {
+	a := assert.New(t)
+	a.True(IsEncrypted(map[string]interface{}{"foo": "ENC[...]", "sops": map[string]interface{}{"mac": "ENC[...]"}}))
+	a.False(IsEncrypted(map[string]interface{}{"foo": "bar"}))
+	a.False(IsEncrypted(map[string]interface{}{"sops": "bar"}))
+	a.False(IsEncrypted(map[string]interface{}{"sops": map[string]interface{}{"version": "3.8.1"}}))
+	a.False(IsEncrypted([]interface{}{"sops"}))
+}
+
+func TestDecrypt(t *testing.T) {
+	b, err := Decrypt(context.Background(), "./testdata/sops", "testdata/secret.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "password: s3cret\n", string(b))
+}
+
+func TestDecryptNegative(t *testing.T) {
+	_, err := Decrypt(context.Background(), "./testdata/sops", "testdata/missing.yaml")
+	require.Error(t, err)
+	assert.Equal(t, "sops decrypt testdata/missing.yaml: exit status 1\nError: cannot decrypt testdata/missing.yaml", err.Error())
+
+	_, err = Decrypt(context.Background(), "./testdata/no-such-sops", "testdata/secret.yaml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sops decrypt testdata/secret.yaml")
+}
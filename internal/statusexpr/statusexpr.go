@@ -0,0 +1,231 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package statusexpr implements status expressions that determine readiness for types, like custom resources, that
+// do not have built-in status functions. An expression is of the form:
+//
+//	<path> == <value>
+//	<path> != <value>
+//
+// where the path is a sequence of field references (.status.phase), list indexes ([0]) and list filters
+// ([?type==Ready]) that select the first list item whose field has the supplied value. Values may be quoted using
+// single or double quotes. An expression is satisfied when the value at the path, converted to a string, is equal
+// (or not equal) to the supplied value. A missing value is never satisfied.
+package statusexpr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type pathStep struct {
+	field       string // the field to select for a field reference
+	index       int    // the list index for an index reference
+	isIndex     bool   // true if the step is an index reference
+	filterField string // the field to match for a filter
+	filterValue string // the value to match for a filter
+}
+
+// Expression is a parsed status expression.
+type Expression struct {
+	source  string
+	steps   []pathStep
+	negated bool
+	value   string
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func parsePath(path string) ([]pathStep, error) {
+	if !strings.HasPrefix(path, ".") && !strings.HasPrefix(path, "[") {
+		return nil, fmt.Errorf("path %q must start with '.' or '['", path)
+	}
+	var steps []pathStep
+	rest := path
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			field := rest[:end]
+			if field == "" {
+				return nil, fmt.Errorf("path %q has an empty field reference", path)
+			}
+			steps = append(steps, pathStep{field: field})
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("path %q has an unterminated '['", path)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			if strings.HasPrefix(inner, "?") {
+				parts := strings.SplitN(inner[1:], "==", 2)
+				if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+					return nil, fmt.Errorf("path %q has an invalid filter %q, must be of the form [?field==value]", path, inner)
+				}
+				steps = append(steps, pathStep{
+					filterField: strings.TrimSpace(parts[0]),
+					filterValue: unquote(strings.TrimSpace(parts[1])),
+				})
+				continue
+			}
+			n, err := strconv.Atoi(inner)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("path %q has an invalid index %q", path, inner)
+			}
+			steps = append(steps, pathStep{index: n, isIndex: true})
+		default:
+			return nil, fmt.Errorf("path %q has unexpected character %q", path, rest[0])
+		}
+	}
+	return steps, nil
+}
+
+// splitOperator returns the position and the operator of the comparison in the supplied expression, ignoring
+// operators inside list filters.
+func splitOperator(expr string) (int, string) {
+	depth := 0
+	for i := 0; i < len(expr)-1; i++ {
+		switch expr[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '=', '!':
+			if depth == 0 && expr[i+1] == '=' {
+				return i, expr[i : i+2]
+			}
+		}
+	}
+	return -1, ""
+}
+
+// Parse parses the supplied status expression.
+func Parse(expr string) (*Expression, error) {
+	pos, op := splitOperator(expr)
+	if pos < 0 {
+		return nil, fmt.Errorf("invalid status expression %q, must be of the form '<path> == <value>' or '<path> != <value>'", expr)
+	}
+	path := strings.TrimSpace(expr[:pos])
+	value := strings.TrimSpace(expr[pos+len(op):])
+	if value == "" {
+		return nil, fmt.Errorf("invalid status expression %q, no value specified", expr)
+	}
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid status expression %q: %v", expr, err)
+	}
+	return &Expression{
+		source:  expr,
+		steps:   steps,
+		negated: op == "!=",
+		value:   unquote(value),
+	}, nil
+}
+
+// String returns the source of the expression.
+func (s *Expression) String() string {
+	return s.source
+}
+
+// fieldValue returns the value of the supplied field, matching the field name case-insensitively when
+// there is no exact match.
+func fieldValue(m map[string]interface{}, field string) (interface{}, bool) {
+	if v, ok := m[field]; ok {
+		return v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, field) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func toString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// lookup returns the value at the path of the expression in the supplied object.
+func (s *Expression) lookup(obj map[string]interface{}) (interface{}, bool) {
+	var current interface{} = obj
+	for _, step := range s.steps {
+		switch {
+		case step.field != "":
+			m, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if current, ok = fieldValue(m, step.field); !ok {
+				return nil, false
+			}
+		case step.isIndex:
+			l, ok := current.([]interface{})
+			if !ok || step.index >= len(l) {
+				return nil, false
+			}
+			current = l[step.index]
+		default:
+			l, ok := current.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			var found bool
+			for _, item := range l {
+				m, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if v, ok := fieldValue(m, step.filterField); ok && toString(v) == step.filterValue {
+					current, found = item, true
+					break
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		}
+	}
+	return current, true
+}
+
+// Evaluate returns true if the expression is satisfied for the supplied object, along with a description of
+// the outcome.
+func (s *Expression) Evaluate(obj map[string]interface{}) (bool, string) {
+	v, ok := s.lookup(obj)
+	if !ok {
+		return false, fmt.Sprintf("waiting for %s, value not found", s.source)
+	}
+	actual := toString(v)
+	if (actual == s.value) != s.negated {
+		return true, fmt.Sprintf("%s satisfied", s.source)
+	}
+	return false, fmt.Sprintf("waiting for %s, current value %q", s.source, actual)
+}
@@ -0,0 +1,51 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package statusexpr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	obj := map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "True"},
+			},
+		},
+	}
+	e, err := Parse(".status.conditions[?type==Ready].status == True")
+	require.NoError(t, err)
+	done, desc := e.Evaluate(obj)
+	assert.True(t, done)
+	assert.Equal(t, ".status.conditions[?type==Ready].status == True satisfied", desc)
+
+	e, err = Parse(".status.phase != Failed")
+	require.NoError(t, err)
+	done, desc = e.Evaluate(obj)
+	assert.False(t, done)
+	assert.Equal(t, "waiting for .status.phase != Failed, value not found", desc)
+}
+
+func TestParseNegative(t *testing.T) {
+	_, err := Parse(".status.phase")
+	require.Error(t, err)
+	assert.Equal(t, `invalid status expression ".status.phase", must be of the form '<path> == <value>' or '<path> != <value>'`, err.Error())
+}
@@ -0,0 +1,107 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package telemetry provides optional OpenTelemetry tracing for qbec operations. Tracing is enabled using the
+// standard OTEL environment variables and spans are exported using OTLP over HTTP. When tracing is not enabled,
+// all functions in this package are cheap no-ops.
+package telemetry
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/splunk/qbec"
+
+var (
+	l          sync.Mutex
+	commandCtx = context.Background() // context of the command span for code that does not have a context
+)
+
+// Enabled returns true if the environment requests traces to be exported. This is the case when an OTLP endpoint
+// is configured, the traces exporter has not been set to anything but "otlp" and the SDK has not been disabled.
+func Enabled() bool {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return false
+	}
+	if exp := os.Getenv("OTEL_TRACES_EXPORTER"); exp != "" && exp != "otlp" {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Init sets up the global tracer provider when tracing is enabled and returns a function that flushes pending spans
+// and shuts down the provider. The exporter is configured using the standard OTEL environment variables.
+func Init(ctx context.Context, version string) (shutdown func(context.Context) error, _ error) {
+	noop := func(context.Context) error { return nil }
+	if !Enabled() {
+		return noop, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return noop, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName("qbec"),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return noop, err
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// SetCommandContext sets the context that has the span for the running command.
+func SetCommandContext(ctx context.Context) {
+	l.Lock()
+	defer l.Unlock()
+	commandCtx = ctx
+}
+
+// CommandContext returns the context that has the span for the running command, for code that does not otherwise
+// have access to a context.
+func CommandContext() context.Context {
+	l.Lock()
+	defer l.Unlock()
+	return commandCtx
+}
+
+// Start starts a span with the supplied name and attributes as a child of the span in the supplied context.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends the supplied span, recording the error, if any.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
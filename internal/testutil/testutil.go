@@ -15,6 +15,7 @@
 package testutil
 
 import (
+	"fmt"
 	"runtime"
 )
 
@@ -26,3 +27,15 @@ func init() {
 		FileNotFoundMessage = "The system cannot find the file specified."
 	}
 }
+
+// VarProvider returns a function that looks up the supplied variables by name, failing for unknown variables.
+// It is typically used to supply configuration to data sources under test.
+func VarProvider(vars map[string]string) func(string) (string, error) {
+	return func(name string) (string, error) {
+		v, ok := vars[name]
+		if !ok {
+			return "", fmt.Errorf("no such var %s", name)
+		}
+		return v, nil
+	}
+}
@@ -22,13 +22,35 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
+	"sync"
 
+	"github.com/splunk/qbec/internal/fieldpath"
 	"github.com/splunk/qbec/internal/model"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 var randomKey []byte
 
+var (
+	redactionsLock sync.RWMutex
+	redactions     map[schema.GroupKind][]fieldpath.Path
+)
+
+// SetRedactions sets the fields whose values are hidden for objects of specific types, in addition to the data of
+// secrets. It replaces any redactions that were previously set.
+func SetRedactions(r map[schema.GroupKind][]fieldpath.Path) {
+	redactionsLock.Lock()
+	defer redactionsLock.Unlock()
+	redactions = r
+}
+
+func redactedPaths(gk schema.GroupKind) []fieldpath.Path {
+	redactionsLock.RLock()
+	defer redactionsLock.RUnlock()
+	return redactions[gk]
+}
+
 func initRandomKey() {
 	randomKey = make([]byte, sha256.New().BlockSize())
 	if _, err := rand.Read(randomKey); err != nil {
@@ -59,17 +81,45 @@ func obfuscateMap(in map[string]interface{}) map[string]interface{} {
 	return ret
 }
 
+// redactValue returns a value where all strings in the supplied value have been replaced with stable strings
+// derived from the supplied prefix and the string. Other scalars are returned as-is.
+func redactValue(prefix string, v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		ret := map[string]interface{}{}
+		for k, child := range val {
+			ret[k] = redactValue(prefix+"."+k, child)
+		}
+		return ret
+	case []interface{}:
+		ret := make([]interface{}, len(val))
+		for i, child := range val {
+			ret[i] = redactValue(fmt.Sprintf("%s[%d]", prefix, i), child)
+		}
+		return ret
+	case string:
+		return obfuscate(fmt.Sprintf("%s:%s", prefix, val))
+	default:
+		return v
+	}
+}
+
+func isSecret(gk schema.GroupKind) bool {
+	return gk.Group == "" && gk.Kind == "Secret"
+}
+
 // HasSensitiveInfo returns true if the supplied object has sensitive data that might need
-// to be hidden.
+// to be hidden. This is the case for secrets and objects of types for which redactions have been set.
 func HasSensitiveInfo(obj *unstructured.Unstructured) bool {
 	gk := obj.GroupVersionKind().GroupKind()
-	return gk.Group == "" && gk.Kind == "Secret"
+	return isSecret(gk) || len(redactedPaths(gk)) > 0
 }
 
 // HideSensitiveInfo creates a new object for secrets where secret values have been replaced with
-// stable strings that can still be diff-ed. It returns a boolean to indicate that the return value
-// was modified from the original object. When no modifications are needed, the original object
-// is returned as-is.
+// stable strings that can still be diff-ed. String values of fields for which redactions have been
+// set for the type of the object are replaced in the same way. It returns a boolean to indicate
+// that the return value was modified from the original object. When no modifications are needed,
+// the original object is returned as-is.
 func HideSensitiveInfo(obj *unstructured.Unstructured) (*unstructured.Unstructured, bool) {
 	if obj == nil {
 		return obj, false
@@ -78,14 +128,22 @@ func HideSensitiveInfo(obj *unstructured.Unstructured) (*unstructured.Unstructur
 		return obj, false
 	}
 
+	gk := obj.GroupVersionKind().GroupKind()
 	clone := obj.DeepCopy()
-	for _, section := range []string{"data", "stringData"} {
-		secretData, _, _ := unstructured.NestedMap(obj.Object, section)
-		changed := obfuscateMap(secretData)
-		if changed != nil {
-			clone.Object[section] = changed
+	if isSecret(gk) {
+		for _, section := range []string{"data", "stringData"} {
+			secretData, _, _ := unstructured.NestedMap(obj.Object, section)
+			changed := obfuscateMap(secretData)
+			if changed != nil {
+				clone.Object[section] = changed
+			}
 		}
 	}
+	for _, p := range redactedPaths(gk) {
+		p.Update(clone.Object, func(v interface{}) interface{} {
+			return redactValue(p.String(), v)
+		})
+	}
 	return clone, true
 }
 
@@ -22,8 +22,11 @@ import (
 	"testing"
 
 	"github.com/ghodss/yaml"
+	"github.com/splunk/qbec/internal/fieldpath"
 	"github.com/splunk/qbec/internal/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 var cm = `
@@ -74,3 +77,52 @@ func TestSecrets(t *testing.T) {
 	v := changed.ToUnstructured().Object["data"].(map[string]interface{})["foo"]
 	a.NotEqual(b64, v)
 }
+
+var externalSecret = `
+---
+apiVersion: external-secrets.io/v1beta1
+kind: ExternalSecret
+metadata:
+  namespace: ns1
+  name: es
+spec:
+  refreshInterval: 1h
+  target:
+    template:
+      data:
+        url: postgres://user:changeme@db
+        port: 5432
+        hosts: [ db1, db2 ]
+`
+
+func TestRedactions(t *testing.T) {
+	p, err := fieldpath.Parse(".spec.target.template.data")
+	require.NoError(t, err)
+	esGK := schema.GroupKind{Group: "external-secrets.io", Kind: "ExternalSecret"}
+	SetRedactions(map[schema.GroupKind][]fieldpath.Path{esGK: {p}})
+	defer SetRedactions(nil)
+
+	obj := model.NewK8sLocalObject(toData(externalSecret), model.LocalAttrs{App: "app1", Component: "c1", Env: "e1"})
+	a := assert.New(t)
+	a.True(HasSensitiveInfo(obj.ToUnstructured()))
+	changed, ok := HideSensitiveLocalInfo(obj)
+	a.True(ok)
+	spec := changed.ToUnstructured().Object["spec"].(map[string]interface{})
+	a.Equal("1h", spec["refreshInterval"])
+	data := spec["target"].(map[string]interface{})["template"].(map[string]interface{})["data"].(map[string]interface{})
+	a.Contains(data["url"], "redacted.")
+	a.NotContains(data["url"], "changeme")
+	a.EqualValues(5432, data["port"])
+	hosts := data["hosts"].([]interface{})
+	a.Contains(hosts[0], "redacted.")
+	a.NotEqual(hosts[0], hosts[1])
+
+	// redaction is stable such that objects can be diffed
+	again, _ := HideSensitiveLocalInfo(obj)
+	a.Equal(changed.ToUnstructured().Object, again.ToUnstructured().Object)
+	// the original object is not modified
+	a.Contains(fmt.Sprint(obj.ToUnstructured().Object["spec"]), "changeme")
+
+	SetRedactions(nil)
+	a.False(HasSensitiveInfo(obj.ToUnstructured()))
+}
@@ -0,0 +1,50 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package types
+
+import (
+	"github.com/splunk/qbec/internal/statusexpr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// StatusExpression is a parsed status expression, see the statusexpr package for its syntax.
+type StatusExpression struct {
+	expr *statusexpr.Expression
+}
+
+// ParseStatusExpression parses the supplied status expression.
+func ParseStatusExpression(expr string) (*StatusExpression, error) {
+	e, err := statusexpr.Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &StatusExpression{expr: e}, nil
+}
+
+// String returns the source of the expression.
+func (s *StatusExpression) String() string {
+	return s.expr.String()
+}
+
+// StatusFunc returns a status function that reports an object as done when the expression is satisfied.
+func (s *StatusExpression) StatusFunc() RolloutStatusFunc {
+	return func(obj *unstructured.Unstructured, _ int64) (*RolloutStatus, error) {
+		var ret RolloutStatus
+		done, desc := s.expr.Evaluate(obj.Object)
+		return ret.withDone(done).withDesc(desc), nil
+	}
+}
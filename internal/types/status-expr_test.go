@@ -0,0 +1,125 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestStatusExpressions(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"phase":    "Running",
+			"replicas": int64(3),
+			"ready":    true,
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Synced", "status": "True"},
+				map[string]interface{}{"type": "Ready", "status": "False", "reason": "Pending"},
+			},
+		},
+	}}
+	tests := []struct {
+		expr string
+		done bool
+		desc string
+	}{
+		{expr: ".status.phase == Running", done: true, desc: ".status.phase == Running satisfied"},
+		{expr: ".status.phase=='Running'", done: true, desc: ".status.phase=='Running' satisfied"},
+		{expr: `.status.phase != "Failed"`, done: true},
+		{expr: ".status.phase != Running", desc: `waiting for .status.phase != Running, current value "Running"`},
+		{expr: ".status.replicas == 3", done: true},
+		{expr: ".status.ready == true", done: true},
+		{expr: ".status.conditions[0].status == True", done: true},
+		{expr: ".status.conditions[?type==Ready].status == True", desc: `waiting for .status.conditions[?type==Ready].status == True, current value "False"`},
+		{expr: ".status.conditions[?Type==Ready].Reason == Pending", done: true},
+		{expr: ".status.conditions[?type == 'Synced'].status == True", done: true},
+		{expr: ".status.conditions[?type==Other].status == True", desc: "waiting for .status.conditions[?type==Other].status == True, value not found"},
+		{expr: ".status.conditions[5].status == True", desc: "waiting for .status.conditions[5].status == True, value not found"},
+		{expr: ".status.phase.foo == bar", desc: "waiting for .status.phase.foo == bar, value not found"},
+	}
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			se, err := ParseStatusExpression(test.expr)
+			require.NoError(t, err)
+			assert.Equal(t, test.expr, se.String())
+			status, err := se.StatusFunc()(obj, 0)
+			require.NoError(t, err)
+			assert.Equal(t, test.done, status.Done)
+			if test.desc != "" {
+				assert.Equal(t, test.desc, status.Description)
+			}
+		})
+	}
+}
+
+func TestStatusExpressionsNegative(t *testing.T) {
+	tests := []struct {
+		expr string
+		msg  string
+	}{
+		{".status.phase", `invalid status expression ".status.phase", must be of the form '<path> == <value>' or '<path> != <value>'`},
+		{".status.phase == ", `invalid status expression ".status.phase == ", no value specified`},
+		{"status.phase == Running", `invalid status expression "status.phase == Running": path "status.phase" must start with '.' or '['`},
+		{".status..phase == Running", `invalid status expression ".status..phase == Running": path ".status..phase" has an empty field reference`},
+		{".status.conditions[?type] == True", `invalid status expression ".status.conditions[?type] == True": path ".status.conditions[?type]" has an invalid filter "?type", must be of the form [?field==value]`},
+		{".status.conditions[x] == True", `invalid status expression ".status.conditions[x] == True": path ".status.conditions[x]" has an invalid index "x"`},
+		{".status.conditions[0 == True", `invalid status expression ".status.conditions[0 == True", must be of the form '<path> == <value>' or '<path> != <value>'`},
+	}
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			_, err := ParseStatusExpression(test.expr)
+			require.Error(t, err)
+			assert.Equal(t, test.msg, err.Error())
+		})
+	}
+}
+
+func TestJobStatus(t *testing.T) {
+	job := func(completions, succeeded int64, conditions ...interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "batch/v1",
+			"kind":       "Job",
+			"spec":       map[string]interface{}{"completions": completions},
+			"status":     map[string]interface{}{"succeeded": succeeded, "conditions": conditions},
+		}}
+	}
+	a := assert.New(t)
+	status, err := jobStatus(job(2, 1), 0)
+	require.NoError(t, err)
+	a.False(status.Done)
+	a.Equal("1 out of 2 tasks have been succeed", status.Description)
+
+	status, err = jobStatus(job(2, 2), 0)
+	require.NoError(t, err)
+	a.True(status.Done)
+
+	status, err = jobStatus(job(0, 0, map[string]interface{}{"type": "Complete", "status": "True"}), 0)
+	require.NoError(t, err)
+	a.True(status.Done)
+
+	_, err = jobStatus(job(1, 0, map[string]interface{}{"type": "Failed", "status": "True", "reason": "BackoffLimitExceeded"}), 0)
+	require.Error(t, err)
+	a.Equal("job exceeded backoff limit", err.Error())
+
+	_, err = jobStatus(job(1, 0, map[string]interface{}{"type": "Failed", "status": "True", "reason": "DeadlineExceeded", "message": "Job was active longer than specified deadline"}), 0)
+	require.Error(t, err)
+	a.Equal("job failed: DeadlineExceeded Job was active longer than specified deadline", err.Error())
+}
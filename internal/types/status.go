@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/splunk/qbec/internal/model"
@@ -65,6 +66,8 @@ func StatusFuncFor(obj model.K8sMeta) RolloutStatusFunc {
 		return statefulsetStatus
 	case schema.GroupKind{Group: "batch", Kind: "Job"}:
 		return jobStatus
+	case schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}:
+		return crdStatus
 	default:
 		return nil
 	}
@@ -254,8 +257,10 @@ func jobStatus(base *unstructured.Unstructured, _ int64) (*RolloutStatus, error)
 		Status struct {
 			Succeeded  int32
 			Conditions []struct {
-				Type   string
-				Reason string
+				Type    string
+				Status  string
+				Reason  string
+				Message string
 			}
 		}
 	}
@@ -269,6 +274,14 @@ func jobStatus(base *unstructured.Unstructured, _ int64) (*RolloutStatus, error)
 		if c.Type == "Failed" && c.Reason == "BackoffLimitExceeded" {
 			return nil, fmt.Errorf("job exceeded backoff limit")
 		}
+		if c.Type == "Failed" && c.Status == "True" {
+			return nil, fmt.Errorf("job failed: %s", strings.TrimSpace(c.Reason+" "+c.Message))
+		}
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == "Complete" && c.Status == "True" {
+			return ret.withDone(true).withDesc("successfully rolled out"), nil
+		}
 	}
 
 	if d.Status.Succeeded < d.Spec.Completions {
@@ -276,3 +289,33 @@ func jobStatus(base *unstructured.Unstructured, _ int64) (*RolloutStatus, error)
 	}
 	return ret.withDone(true).withDesc("successfully rolled out"), nil
 }
+
+func crdStatus(base *unstructured.Unstructured, _ int64) (*RolloutStatus, error) {
+	var d struct {
+		Status struct {
+			Conditions []struct {
+				Type    string
+				Status  string
+				Reason  string
+				Message string
+			}
+		}
+	}
+	if err := reserialize(base, &d); err != nil {
+		return nil, err
+	}
+
+	var ret RolloutStatus
+
+	for _, c := range d.Status.Conditions {
+		if c.Type == "NamesAccepted" && c.Status == "False" {
+			return nil, fmt.Errorf("names not accepted: %s", c.Message)
+		}
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == "Established" && c.Status == "True" {
+			return ret.withDone(true).withDesc("established"), nil
+		}
+	}
+	return ret.withDesc("waiting for the definition to be established"), nil
+}
@@ -101,6 +101,10 @@ func TestStatefulSetStatus(t *testing.T) {
 	testDir(t, "statefulset")
 }
 
+func TestCRDStatus(t *testing.T) {
+	testDir(t, "crd")
+}
+
 func TestUnknownObject(t *testing.T) {
 	obj := model.NewK8sObject(map[string]interface{}{
 		"kind":       "foo",
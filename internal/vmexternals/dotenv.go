@@ -0,0 +1,136 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package vmexternals
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// ParseEnvFile parses the supplied file in dotenv format and returns the variables that it defines. Every non-blank
+// line that is not a comment must have the form [export ]NAME=value. Values may be single-quoted, in which case they
+// are taken literally, or double-quoted, in which case the \n, \r, \t, \" and \\ escapes are processed. Unquoted values
+// are trimmed and may be followed by a comment that starts with " #".
+func ParseEnvFile(file string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := parseEnv(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "process env file %s", file)
+	}
+	return ret, nil
+}
+
+func parseEnv(b []byte) (map[string]string, error) {
+	ret := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	num := 0
+	for scanner.Scan() {
+		num++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, err := parseEnvLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", num, err)
+		}
+		ret[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func parseEnvLine(line string) (name string, value string, err error) {
+	line = strings.TrimPrefix(line, "export ")
+	parts := strings.SplitN(line, "=", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("no value specified for %s", strings.TrimSpace(line))
+	}
+	name = strings.TrimSpace(parts[0])
+	if !envNameRegex.MatchString(name) {
+		return "", "", fmt.Errorf("invalid variable name %q", name)
+	}
+	value = strings.TrimSpace(parts[1])
+	switch {
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated single-quoted value for %s", name)
+		}
+		if err := checkTrailer(value[end+2:]); err != nil {
+			return "", "", fmt.Errorf("%s: %v", name, err)
+		}
+		return name, value[1 : end+1], nil
+	case strings.HasPrefix(value, `"`):
+		var sb strings.Builder
+		for i := 1; i < len(value); i++ {
+			c := value[i]
+			switch {
+			case c == '"':
+				if err := checkTrailer(value[i+1:]); err != nil {
+					return "", "", fmt.Errorf("%s: %v", name, err)
+				}
+				return name, sb.String(), nil
+			case c == '\\' && i+1 < len(value):
+				i++
+				switch value[i] {
+				case 'n':
+					sb.WriteByte('\n')
+				case 'r':
+					sb.WriteByte('\r')
+				case 't':
+					sb.WriteByte('\t')
+				case '"', '\\':
+					sb.WriteByte(value[i])
+				default:
+					sb.WriteByte('\\')
+					sb.WriteByte(value[i])
+				}
+			default:
+				sb.WriteByte(c)
+			}
+		}
+		return "", "", fmt.Errorf("unterminated double-quoted value for %s", name)
+	default:
+		if pos := strings.Index(value, " #"); pos >= 0 {
+			value = strings.TrimSpace(value[:pos])
+		}
+		return name, value, nil
+	}
+}
+
+// checkTrailer ensures that only whitespace and an optional comment follow a quoted value.
+func checkTrailer(s string) error {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.HasPrefix(s, "#") {
+		return nil
+	}
+	return fmt.Errorf("unexpected characters after quoted value: %q", s)
+}
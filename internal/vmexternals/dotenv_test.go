@@ -0,0 +1,62 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package vmexternals
+
+import (
+	"testing"
+
+	"github.com/splunk/qbec/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	vars, err := ParseEnvFile("testdata/vars.env")
+	require.NoError(t, err)
+	assert.EqualValues(t, map[string]string{
+		"envVar1":  "e1",
+		"envVar2":  "e2 # not a comment",
+		"envVar3":  "line1\nline2",
+		"envVar4":  "value with spaces",
+		"listVar1": "fromEnvFile",
+	}, vars)
+}
+
+func TestParseEnvFileNegative(t *testing.T) {
+	_, err := ParseEnvFile("testdata/no-such-file.env")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), testutil.FileNotFoundMessage)
+
+	tests := []struct {
+		name  string
+		input string
+		msg   string
+	}{
+		{"no-value", "foo=bar\nbaz", "line 2: no value specified for baz"},
+		{"bad-name", "foo bar=baz", `line 1: invalid variable name "foo bar"`},
+		{"unterminated-single", "foo='bar", "line 1: unterminated single-quoted value for foo"},
+		{"unterminated-double", `foo="bar\"`, "line 1: unterminated double-quoted value for foo"},
+		{"trailer", `foo="bar" baz`, `line 1: foo: unexpected characters after quoted value: "baz"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseEnv([]byte(test.input))
+			require.Error(t, err)
+			assert.Equal(t, test.msg, err.Error())
+		})
+	}
+}
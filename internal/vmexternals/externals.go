@@ -86,9 +86,10 @@ func (c Externals) ToVariableSet() vm.VariableSet {
 }
 
 type strFiles struct {
-	strings []string
-	files   []string
-	lists   []string
+	strings  []string
+	files    []string
+	lists    []string
+	envFiles []string
 }
 
 func getValues(ret map[string]UserVal, name string, s strFiles, fn func(value string) UserVal) error {
@@ -144,6 +145,15 @@ func getValues(ret map[string]UserVal, name string, s strFiles, fn func(value st
 			return err
 		}
 	}
+	for _, f := range s.envFiles {
+		vars, err := ParseEnvFile(f)
+		if err != nil {
+			return err
+		}
+		for k, v := range vars {
+			ret[k] = fn(v)
+		}
+	}
 	for _, s := range s.strings {
 		if err := processStr(s, name+" "); err != nil {
 			return err
@@ -176,6 +186,7 @@ func FromCommandParams(cmd *cobra.Command, prefix string, addShortcuts bool) fun
 	}
 	fs.StringArrayVar(&extStrings.files, prefix+"ext-str-file", nil, "external string from file: <var>=<filename>")
 	fs.StringArrayVar(&extStrings.lists, prefix+"ext-str-list", nil, "file containing lines of the form <var>[=<val>]")
+	fs.StringArrayVar(&extStrings.envFiles, prefix+"ext-str-env-file", nil, "file in dotenv format containing lines of the form <var>=<val>")
 	fs.StringArrayVar(&extCodes.strings, prefix+"ext-code", nil, "external code: <var>=[val], if <val> is omitted, get from environment var <var>")
 	fs.StringArrayVar(&extCodes.files, prefix+"ext-code-file", nil, "external code from file: <var>=<filename>")
 	if addShortcuts {
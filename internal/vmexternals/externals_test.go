@@ -84,6 +84,40 @@ func TestExternals(t *testing.T) {
 	}, cfg.Variables.TopLevelVars)
 }
 
+func TestExternalsEnvFile(t *testing.T) {
+	var fn func() (Externals, error)
+	var cfg Externals
+	cmd := &cobra.Command{
+		Use: "show",
+		RunE: func(c *cobra.Command, args []string) error {
+			var err error
+			cfg, err = fn()
+			return err
+		},
+	}
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	fn = FromCommandParams(cmd, "vm:", false)
+	cmd.SetArgs([]string{
+		"show",
+		"--vm:ext-str-list=testdata/vars.txt",
+		"--vm:ext-str-env-file=testdata/vars.env",
+		"--vm:ext-str=envVar1=cmdline",
+	})
+	os.Setenv("listVar2", "l2")
+	defer os.Unsetenv("listVar2")
+	err := cmd.Execute()
+	require.NoError(t, err)
+	assert.EqualValues(t, map[string]UserVal{
+		"envVar1":  {Value: "cmdline"},
+		"envVar2":  {Value: "e2 # not a comment"},
+		"envVar3":  {Value: "line1\nline2"},
+		"envVar4":  {Value: "value with spaces"},
+		"listVar1": {Value: "fromEnvFile"},
+		"listVar2": {Value: "l2"},
+	}, cfg.Variables.Vars)
+}
+
 func TestConfigShorthands(t *testing.T) {
 	var fn func() (Externals, error)
 	var cfg Externals
@@ -18,6 +18,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -27,6 +28,7 @@ import (
 	"github.com/splunk/qbec/internal/cmd"
 	"github.com/splunk/qbec/internal/commands"
 	"github.com/splunk/qbec/internal/sio"
+	"github.com/splunk/qbec/internal/telemetry"
 )
 
 var start = time.Now()
@@ -38,28 +40,53 @@ func main() {
 
 `, commands.Executable), "\n")
 	root := &cobra.Command{
-		Use:                    commands.Executable,
-		Short:                  "Kubernetes cluster config tool",
-		Long:                   longdesc,
-		BashCompletionFunction: commands.BashCompletionFunc,
+		Use:   commands.Executable,
+		Short: "Kubernetes cluster config tool",
+		Long:  longdesc,
 	}
 	root.SilenceUsage = true
 	root.SilenceErrors = true
 	commands.Setup(root)
-	c, err := root.ExecuteContextC(context.TODO())
+
+	shutdown, err := telemetry.Init(context.Background(), commands.Version())
+	if err != nil {
+		sio.Warnln("unable to initialize tracing,", err)
+	}
+	ctx, span := telemetry.Start(context.Background(), commands.Executable)
+	telemetry.SetCommandContext(ctx)
+	c, err := root.ExecuteContextC(ctx)
+	if c != nil {
+		span.SetName(c.CommandPath())
+	}
+	telemetry.End(span, err)
 
 	exit := func(code int) {
 		duration := time.Since(start).Round(time.Second / 100)
 		if duration > 100*time.Millisecond {
 			sio.Debugln("command took", duration)
 		}
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := shutdown(sctx); err != nil {
+			sio.Warnln("unable to export traces,", err)
+		}
+		cancel()
 		os.Exit(code)
 	}
 
-	switch {
-	case err == nil:
+	if err == nil {
 		exit(0)
+	}
+	if format, _ := root.PersistentFlags().GetString("error-format"); format == "json" {
+		b, _ := json.Marshal(cmd.NewErrorEnvelope(c.CommandPath(), err))
+		fmt.Fprintln(os.Stderr, string(b))
+		exit(1)
+	}
+
+	logFormat, _ := root.PersistentFlags().GetString("log-format")
+	sio.EnableJSON(logFormat == "json") // also when the error occurred before options were processed
+	switch {
 	case cmd.IsRuntimeError(err):
+	case logFormat == "json": // do not interleave usage text with structured messages
 	default:
 		sio.Println()
 		c.Example = "" // do not print examples when there is a usage error
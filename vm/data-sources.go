@@ -19,10 +19,12 @@ package vm
 import (
 	"fmt"
 	"io"
+	"net/url"
 
 	"github.com/pkg/errors"
 	"github.com/splunk/qbec/vm/datasource"
 	"github.com/splunk/qbec/vm/internal/ds/factory"
+	"github.com/splunk/qbec/vm/internal/ds/recording"
 )
 
 type multiCloser struct {
@@ -50,20 +52,99 @@ func (m *multiCloser) Close() error {
 	}
 }
 
+// DataSourceOptions control how data sources are created.
+type DataSourceOptions struct {
+	// RecordDir is a directory into which the outputs of data sources are recorded.
+	RecordDir string
+	// ReplayDir is a directory from which outputs previously recorded are returned instead of using
+	// the data sources. Data sources are not initialized when this is set.
+	ReplayDir string
+	// RecordingKey distinguishes outputs recorded for the same data sources in different contexts, such as
+	// environments whose configuration variables have different values. Outputs are only replayed for the
+	// key with which they were recorded.
+	RecordingKey string
+	// Providers create data sources for URI schemes that are not built into the VM, keyed by scheme.
+	Providers map[string]DataSourceProvider
+	// Dir is the directory from which commands run by data sources are run and against which relative
+	// paths in their configuration are resolved. The working directory is used when it is blank.
+	Dir string
+}
+
+// DataSourceProvider creates a data source with the supplied name for a URI with a custom scheme, using the
+// query parameters of the URI. Data sources that also implement io.Closer are closed along with the built-in ones.
+type DataSourceProvider func(name string, params url.Values) (datasource.DataSource, error)
+
+// createCustom creates a data source for the supplied URI using the provider registered for its scheme. It returns
+// a nil data source when there is no such provider.
+func createCustom(uri string, providers map[string]DataSourceProvider) (datasource.DataSource, error) {
+	if len(providers) == 0 {
+		return nil, nil
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, nil // let the factory report the error
+	}
+	p, ok := providers[u.Scheme]
+	if !ok {
+		return nil, nil
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("data source '%s' does not have a name", uri)
+	}
+	return p(u.Host, u.Query())
+}
+
 // CreateDataSources returns the data source implementations for the supplied URIs. It also returns an io.Closer that should
 // be called at the point when the data sources are no longer in use. It guarantees that the returned closer will be non-nil
 // even when there are errors.
 func CreateDataSources(input []string, cp datasource.ConfigProvider) (sources []datasource.DataSource, closer io.Closer, _ error) {
-	closer = &multiCloser{}
+	return CreateDataSourcesWithOptions(input, cp, DataSourceOptions{})
+}
+
+// CreateDataSourcesWithOptions is the same as CreateDataSources but allows the outputs of data sources to be
+// recorded or replayed based on the supplied options.
+func CreateDataSourcesWithOptions(input []string, cp datasource.ConfigProvider, opts DataSourceOptions) (sources []datasource.DataSource, closer io.Closer, _ error) {
+	if opts.RecordDir != "" && opts.ReplayDir != "" {
+		return nil, &multiCloser{}, fmt.Errorf("data sources cannot be recorded and replayed at the same time")
+	}
+	mc := &multiCloser{}
+	closer = mc
 	for _, uri := range input {
-		src, err := factory.Create(uri)
+		custom, err := createCustom(uri, opts.Providers)
 		if err != nil {
 			return nil, closer, errors.Wrapf(err, "create data source %s", uri)
 		}
+		if custom != nil {
+			switch {
+			case opts.ReplayDir != "":
+				sources = append(sources, recording.NewReplayer(custom.Name(), opts.ReplayDir, opts.RecordingKey))
+			case opts.RecordDir != "":
+				sources = append(sources, recording.NewRecorder(custom, opts.RecordDir, opts.RecordingKey))
+			default:
+				sources = append(sources, custom)
+			}
+			if c, ok := custom.(io.Closer); ok {
+				mc.add(c)
+			}
+			continue
+		}
+		src, err := factory.Create(uri, opts.Dir)
+		if err != nil {
+			return nil, closer, errors.Wrapf(err, "create data source %s", uri)
+		}
+		if opts.ReplayDir != "" {
+			sources = append(sources, recording.NewReplayer(src.Name(), opts.ReplayDir, opts.RecordingKey))
+			continue
+		}
+		mc.add(src)
 		err = src.Init(cp)
 		if err != nil {
 			return nil, closer, errors.Wrapf(err, "init data source %s", uri)
 		}
+		if opts.RecordDir != "" {
+			sources = append(sources, recording.NewRecorder(src, opts.RecordDir, opts.RecordingKey))
+			continue
+		}
 		sources = append(sources, src)
 	}
 	return sources, closer, nil
@@ -19,8 +19,10 @@ package vm
 import (
 	"fmt"
 	"io"
+	"net/url"
 	"testing"
 
+	"github.com/splunk/qbec/vm/datasource"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -84,3 +86,72 @@ func TestCreateDataSourcesError(t *testing.T) {
 	assert.Equal(t, "create data source exec://foo: data source 'exec://foo' must have a configVar param", err.Error())
 	require.NotNil(t, closer)
 }
+
+func TestCreateDataSourcesReplay(t *testing.T) {
+	cp := func(name string) (string, error) { return "", fmt.Errorf("config provider called for %s", name) }
+	sources, closer, err := CreateDataSourcesWithOptions([]string{"exec://foo?configVar=foo"}, cp, DataSourceOptions{ReplayDir: t.TempDir()})
+	require.NoError(t, err)
+	require.NotNil(t, closer)
+	require.Equal(t, 1, len(sources))
+	assert.Equal(t, "foo", sources[0].Name())
+	_, err = sources[0].Resolve("/")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no recorded output for path /")
+
+	_, closer, err = CreateDataSourcesWithOptions([]string{"exec://foo?configVar=foo"}, cp, DataSourceOptions{ReplayDir: "a", RecordDir: "b"})
+	require.Error(t, err)
+	require.NotNil(t, closer)
+	assert.Equal(t, "data sources cannot be recorded and replayed at the same time", err.Error())
+}
+
+type customSource struct {
+	name   string
+	prefix string
+	closed bool
+}
+
+func (c *customSource) Name() string { return c.name }
+
+func (c *customSource) Resolve(path string) (string, error) { return c.prefix + path, nil }
+
+func (c *customSource) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestCreateDataSourcesCustom(t *testing.T) {
+	var created *customSource
+	opts := DataSourceOptions{
+		Providers: map[string]DataSourceProvider{
+			"custom": func(name string, params url.Values) (datasource.DataSource, error) {
+				if params.Get("fail") != "" {
+					return nil, fmt.Errorf("failed")
+				}
+				created = &customSource{name: name, prefix: params.Get("prefix")}
+				return created, nil
+			},
+		},
+	}
+	sources, closer, err := CreateDataSourcesWithOptions([]string{"custom://foo?prefix=x:"}, ConfigProviderFromVariables(VariableSet{}), opts)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(sources))
+	a := assert.New(t)
+	a.Equal("foo", sources[0].Name())
+	out, err := sources[0].Resolve("/bar")
+	require.NoError(t, err)
+	a.Equal("x:/bar", out)
+	require.NoError(t, closer.Close())
+	a.True(created.closed)
+
+	_, _, err = CreateDataSourcesWithOptions([]string{"custom://foo?fail=true"}, ConfigProviderFromVariables(VariableSet{}), opts)
+	require.Error(t, err)
+	a.Equal("create data source custom://foo?fail=true: failed", err.Error())
+
+	_, _, err = CreateDataSourcesWithOptions([]string{"custom:///foo"}, ConfigProviderFromVariables(VariableSet{}), opts)
+	require.Error(t, err)
+	a.Equal("create data source custom:///foo: data source 'custom:///foo' does not have a name", err.Error())
+
+	_, _, err = CreateDataSourcesWithOptions([]string{"custom://foo"}, ConfigProviderFromVariables(VariableSet{}), DataSourceOptions{})
+	require.Error(t, err)
+	a.Contains(err.Error(), "unsupported scheme 'custom'")
+}
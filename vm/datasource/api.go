@@ -17,6 +17,8 @@
 // Package datasource declares the data source interface.
 package datasource
 
+import "io"
+
 // DataSource is a named delegate that can resolve import paths. Multiple VMs may
 // access a single instance of a data source. Thus, data source implementations must
 // be safe for concurrent use.
@@ -28,6 +30,24 @@ type DataSource interface {
 	Resolve(path string) (string, error)
 }
 
+// StreamingDataSource is a data source that can write its output to a writer instead of returning it as a
+// string. This allows large outputs to be captured without holding multiple copies in memory and to be
+// aborted as soon as they exceed a size limit.
+type StreamingDataSource interface {
+	DataSource
+	// ResolveTo resolves the absolute path defined for the data source, writing the output to the supplied writer.
+	// Implementations must stop producing output and return the error when a write fails.
+	ResolveTo(path string, w io.Writer) error
+}
+
+// RedactingDataSource is a data source whose outputs may contain sensitive data. Outputs of such data sources
+// are redacted before they are recorded, such that the sensitive data is never written to disk.
+type RedactingDataSource interface {
+	DataSource
+	// Redact returns the supplied output for the supplied path with sensitive data replaced.
+	Redact(path, output string) (string, error)
+}
+
 // ConfigProvider returns the value of the supplied variable as a JSON string.
 // A config provider is used at the time of data source creation to allow the data source to be
 // correctly configured.
@@ -0,0 +1,154 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package plugin declares the protocol used by qbec to talk to data sources implemented as external programs,
+// and provides a helper to implement such programs in go.
+//
+// A plugin is started once for every data source that uses it and stays alive until qbec exits. qbec writes one
+// JSON request per line to the standard input of the plugin and expects a single JSON response per line on its
+// standard output for every request, in order. The standard error of the plugin is passed through to qbec's
+// standard error. Requests are never sent concurrently.
+//
+// The first request is always an "init" request, followed by any number of "resolve" requests and a final "close"
+// request, after which the plugin is expected to exit.
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ProtocolVersion is the version of the protocol described in this package.
+const ProtocolVersion = 1
+
+// Methods supported by the protocol.
+const (
+	MethodInit    = "init"    // initialize the plugin, params are InitParams and the result is InitResult
+	MethodResolve = "resolve" // resolve a path, params are ResolveParams and the result is ResolveResult
+	MethodClose   = "close"   // release all resources, there are no params or result
+)
+
+// Request is a request sent by qbec to a plugin.
+type Request struct {
+	ID     int             `json:"id"`               // request ID, to be returned in the response
+	Method string          `json:"method"`           // the method to run
+	Params json.RawMessage `json:"params,omitempty"` // parameters for the method
+}
+
+// Response is the response of a plugin to a request.
+type Response struct {
+	ID     int             `json:"id"`               // the ID of the request
+	Result json.RawMessage `json:"result,omitempty"` // the result of the method when successful
+	Error  string          `json:"error,omitempty"`  // the error message when the method failed
+}
+
+// InitParams are the parameters of an init request.
+type InitParams struct {
+	ProtocolVersion int             `json:"protocolVersion"`  // the protocol version used by qbec
+	Name            string          `json:"name"`             // the name of the data source
+	Config          json.RawMessage `json:"config,omitempty"` // plugin configuration from the config variable of the data source
+}
+
+// InitResult is the result of an init request.
+type InitResult struct {
+	ProtocolVersion int `json:"protocolVersion"` // the protocol version implemented by the plugin
+}
+
+// ResolveParams are the parameters of a resolve request.
+type ResolveParams struct {
+	Path string `json:"path"` // the path to resolve, including any query string
+}
+
+// ResolveResult is the result of a resolve request.
+type ResolveResult struct {
+	Content string `json:"content"` // the resolved content
+}
+
+// Handler is the implementation of a plugin data source. Calls to a handler are never concurrent.
+type Handler interface {
+	// Init initializes the handler for the supplied data source name and configuration.
+	Init(name string, config json.RawMessage) error
+	// Resolve resolves the supplied path to a string.
+	Resolve(path string) (string, error)
+	// Close releases all resources held by the handler.
+	Close() error
+}
+
+// Serve reads requests from the supplied reader and writes responses to the supplied writer using the supplied
+// handler, until a close request is processed or the reader is exhausted. Plugin programs typically call it with
+// their standard input and output.
+func Serve(r io.Reader, w io.Writer, h Handler) error {
+	dec := json.NewDecoder(r)
+	enc := json.NewEncoder(w)
+	for {
+		var req Request
+		if err := dec.Decode(&req); err != nil {
+			if err == io.EOF {
+				return h.Close()
+			}
+			return fmt.Errorf("read request: %v", err)
+		}
+		result, err := handle(h, req)
+		res := Response{ID: req.ID}
+		if err != nil {
+			res.Error = err.Error()
+		} else if result != nil {
+			b, err := json.Marshal(result)
+			if err != nil {
+				return fmt.Errorf("marshal %s result: %v", req.Method, err)
+			}
+			res.Result = b
+		}
+		if err := enc.Encode(res); err != nil {
+			return fmt.Errorf("write response: %v", err)
+		}
+		if req.Method == MethodClose {
+			return nil
+		}
+	}
+}
+
+func handle(h Handler, req Request) (interface{}, error) {
+	switch req.Method {
+	case MethodInit:
+		var p InitParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, fmt.Errorf("invalid init params: %v", err)
+		}
+		if p.ProtocolVersion != ProtocolVersion {
+			return nil, fmt.Errorf("unsupported protocol version %d, want %d", p.ProtocolVersion, ProtocolVersion)
+		}
+		if err := h.Init(p.Name, p.Config); err != nil {
+			return nil, err
+		}
+		return InitResult{ProtocolVersion: ProtocolVersion}, nil
+	case MethodResolve:
+		var p ResolveParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, fmt.Errorf("invalid resolve params: %v", err)
+		}
+		content, err := h.Resolve(p.Path)
+		if err != nil {
+			return nil, err
+		}
+		return ResolveResult{Content: content}, nil
+	case MethodClose:
+		return nil, h.Close()
+	default:
+		return nil, fmt.Errorf("unsupported method %q", req.Method)
+	}
+}
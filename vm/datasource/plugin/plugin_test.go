@@ -0,0 +1,115 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type handler struct {
+	name   string
+	config string
+	closed int
+}
+
+func (h *handler) Init(name string, config json.RawMessage) error {
+	h.name = name
+	h.config = string(config)
+	return nil
+}
+
+func (h *handler) Resolve(path string) (string, error) {
+	if path == "/bad" {
+		return "", errors.New("bad path")
+	}
+	return h.name + ":" + path, nil
+}
+
+func (h *handler) Close() error {
+	h.closed++
+	return nil
+}
+
+func readResponses(t *testing.T, out string) []Response {
+	var ret []Response
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		var res Response
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &res))
+		ret = append(ret, res)
+	}
+	return ret
+}
+
+func TestServe(t *testing.T) {
+	in := strings.Join([]string{
+		`{"id":1,"method":"init","params":{"protocolVersion":1,"name":"ds","config":{"foo":"bar"}}}`,
+		`{"id":2,"method":"resolve","params":{"path":"/a/b"}}`,
+		`{"id":3,"method":"resolve","params":{"path":"/bad"}}`,
+		`{"id":4,"method":"foo"}`,
+		`{"id":5,"method":"close"}`,
+		`{"id":6,"method":"resolve","params":{"path":"/ignored"}}`,
+	}, "\n")
+	var out bytes.Buffer
+	h := &handler{}
+	err := Serve(strings.NewReader(in), &out, h)
+	require.NoError(t, err)
+	a := assert.New(t)
+	a.Equal("ds", h.name)
+	a.Equal(`{"foo":"bar"}`, h.config)
+	a.Equal(1, h.closed)
+	responses := readResponses(t, out.String())
+	require.Len(t, responses, 5)
+	a.Equal(Response{ID: 1, Result: json.RawMessage(`{"protocolVersion":1}`)}, responses[0])
+	a.Equal(Response{ID: 2, Result: json.RawMessage(`{"content":"ds:/a/b"}`)}, responses[1])
+	a.Equal(Response{ID: 3, Error: "bad path"}, responses[2])
+	a.Equal(Response{ID: 4, Error: `unsupported method "foo"`}, responses[3])
+	a.Equal(Response{ID: 5}, responses[4])
+}
+
+func TestServeEOF(t *testing.T) {
+	var out bytes.Buffer
+	h := &handler{}
+	err := Serve(strings.NewReader(`{"id":1,"method":"resolve","params":{"path":"/a"}}`), &out, h)
+	require.NoError(t, err)
+	assert.Equal(t, 1, h.closed)
+	assert.Equal(t, []Response{{ID: 1, Result: json.RawMessage(`{"content":":/a"}`)}}, readResponses(t, out.String()))
+}
+
+func TestServeNegative(t *testing.T) {
+	var out bytes.Buffer
+	err := Serve(strings.NewReader(`{"id":1,"method":"init","params":{"protocolVersion":2,"name":"ds"}}`), &out, &handler{})
+	require.NoError(t, err)
+	assert.Equal(t, []Response{{ID: 1, Error: "unsupported protocol version 2, want 1"}}, readResponses(t, out.String()))
+
+	out.Reset()
+	err = Serve(strings.NewReader(`{"id":1,"method":"resolve","params":"foo"}`), &out, &handler{})
+	require.NoError(t, err)
+	assert.Contains(t, readResponses(t, out.String())[0].Error, "invalid resolve params")
+
+	err = Serve(strings.NewReader(`{"id":`), &out, &handler{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "read request")
+}
@@ -0,0 +1,116 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package vm
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/google/go-jsonnet/ast"
+	"github.com/google/go-jsonnet/toolutils"
+)
+
+// Import is an import made by a jsonnet file.
+type Import struct {
+	Path    string `json:"path"`    // the path as written in the importing file
+	FoundAt string `json:"foundAt"` // the location at which the import was found
+	Code    bool   `json:"code"`    // true for code imports, false for string imports
+}
+
+// ImportGraph returns the imports made by the supplied file and by every jsonnet file that it transitively imports,
+// keyed by the location of the importing file. The imports of each file are sorted by location and only code
+// imports are followed.
+func (v *vm) ImportGraph(file string) (map[string][]Import, error) {
+	file = filepath.ToSlash(file)
+	root, foundAt, err := v.jvm.ImportAST("", file)
+	if err != nil {
+		return nil, err
+	}
+	graph := map[string][]Import{}
+	var visit func(from string, node ast.Node) error
+	visit = func(from string, node ast.Node) error {
+		imports, err := v.collectImports(from, node)
+		if err != nil {
+			return err
+		}
+		if imports == nil {
+			imports = []Import{}
+		}
+		graph[from] = imports
+		for _, imp := range imports {
+			if _, seen := graph[imp.FoundAt]; seen || !imp.Code {
+				continue
+			}
+			child, _, err := v.jvm.ImportAST(from, imp.Path)
+			if err != nil {
+				return err
+			}
+			if err := visit(imp.FoundAt, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := visit(foundAt, root); err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
+
+// collectImports returns the distinct imports found in the supplied AST of a file at the supplied location.
+func (v *vm) collectImports(from string, node ast.Node) ([]Import, error) {
+	seen := map[Import]bool{}
+	var ret []Import
+	var walk func(n ast.Node) error
+	walk = func(n ast.Node) error {
+		var imp Import
+		switch i := n.(type) {
+		case *ast.Import:
+			imp = Import{Path: i.File.Value, Code: true}
+		case *ast.ImportStr:
+			imp = Import{Path: i.File.Value}
+		default:
+			for _, c := range toolutils.Children(n) {
+				if err := walk(c); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		foundAt, err := v.jvm.ResolveImport(from, imp.Path)
+		if err != nil {
+			return fmt.Errorf("%s: import %s: %v", from, imp.Path, err)
+		}
+		imp.FoundAt = foundAt
+		if !seen[imp] {
+			seen[imp] = true
+			ret = append(ret, imp)
+		}
+		return nil
+	}
+	if err := walk(node); err != nil {
+		return nil, err
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].FoundAt != ret[j].FoundAt {
+			return ret[i].FoundAt < ret[j].FoundAt
+		}
+		return ret[i].Path < ret[j].Path
+	})
+	return ret, nil
+}
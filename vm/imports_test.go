@@ -0,0 +1,54 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package vm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/splunk/qbec/vm/datasource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVMImportGraph(t *testing.T) {
+	jvm := New(Config{DataSources: []datasource.DataSource{&replay{name: "replay"}}})
+	graph, err := jvm.ImportGraph("testdata/imports/main.jsonnet")
+	require.NoError(t, err)
+	a := assert.New(t)
+	main := graph["testdata/imports/main.jsonnet"]
+	require.Equal(t, 5, len(main))
+	a.Equal(Import{Path: "data://replay/foo", FoundAt: "data://replay/foo"}, main[0])
+	a.True(strings.HasSuffix(main[1].FoundAt, "-import.glob"))
+	a.Equal(Import{Path: "lib/a.libsonnet", FoundAt: "testdata/imports/lib/a.libsonnet", Code: true}, main[2])
+	a.Equal(Import{Path: "./lib/b.libsonnet", FoundAt: "testdata/imports/lib/b.libsonnet", Code: true}, main[3])
+	a.Equal(Import{Path: "lib/text.txt", FoundAt: "testdata/imports/lib/text.txt"}, main[4])
+	a.Equal([]Import{{Path: "conf/x.json", FoundAt: "testdata/imports/conf/x.json", Code: true}}, graph[main[1].FoundAt])
+	a.Equal([]Import{{Path: "b.libsonnet", FoundAt: "testdata/imports/lib/b.libsonnet", Code: true}}, graph["testdata/imports/lib/a.libsonnet"])
+	a.Equal([]Import{{Path: "text.txt", FoundAt: "testdata/imports/lib/text.txt"}}, graph["testdata/imports/lib/b.libsonnet"])
+	a.Equal([]Import{}, graph["testdata/imports/conf/x.json"])
+	a.Equal(5, len(graph))
+}
+
+func TestVMImportGraphNegative(t *testing.T) {
+	jvm := New(Config{})
+	_, err := jvm.ImportGraph("testdata/imports/bad.jsonnet")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "testdata/imports/bad.jsonnet: import lib/missing.libsonnet: couldn't open import")
+	_, err = jvm.ImportGraph("testdata/imports/missing.jsonnet")
+	require.Error(t, err)
+}
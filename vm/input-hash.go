@@ -0,0 +1,109 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package vm
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/go-jsonnet"
+	"github.com/splunk/qbec/vm/internal/natives"
+)
+
+// ErrExternalInputs is returned by InputHash when the file or one of its imports refers to native functions
+// whose output depends on external state, such that a hash of the jsonnet inputs does not capture all inputs.
+var ErrExternalInputs = errors.New("inputs include native functions that read external state")
+
+// recordingImporter records a hash of the contents of every successful import keyed by the location at which
+// it was found, along with whether the contents refer to native functions that read external state. Since a
+// VM is only used by one evaluation at a time, no locking is required.
+type recordingImporter struct {
+	jsonnet.Importer
+	hashes   map[string]string
+	external map[string]bool
+}
+
+func hashString(s string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))
+}
+
+// Import implements the jsonnet.Importer interface.
+func (r *recordingImporter) Import(importedFrom, importedPath string) (jsonnet.Contents, string, error) {
+	contents, foundAt, err := r.Importer.Import(importedFrom, importedPath)
+	if err == nil {
+		r.hashes[foundAt] = hashString(contents.String())
+		r.external[foundAt] = usesExternalState(contents.String())
+	}
+	return contents, foundAt, err
+}
+
+// usesExternalState returns true if the supplied code refers to a native function that reads external state.
+func usesExternalState(code string) bool {
+	for _, name := range natives.ExternalStateFuncs {
+		if strings.Contains(code, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeVars writes a stable representation of the supplied variables to the supplied writer.
+func writeVars(w io.Writer, prefix string, vars map[string]Var) {
+	var names []string
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v := vars[name]
+		fmt.Fprintf(w, "%s:%s:%d:%s\n", prefix, name, v.kind, hashString(v.value))
+	}
+}
+
+// InputHash implements the interface method.
+func (v *vm) InputHash(file string, vars VariableSet) (string, error) {
+	file = filepath.ToSlash(file)
+	foundAt, err := v.jvm.ResolveImport("", file)
+	if err != nil {
+		return "", err
+	}
+	deps, err := v.jvm.FindDependencies("", []string{file})
+	if err != nil {
+		return "", err
+	}
+	if v.imports.external[foundAt] {
+		return "", ErrExternalInputs
+	}
+	for _, dep := range deps {
+		if v.imports.external[dep] {
+			return "", ErrExternalInputs
+		}
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "file:%s:%s\n", foundAt, v.imports.hashes[foundAt])
+	for _, dep := range deps {
+		fmt.Fprintf(h, "import:%s:%s\n", dep, v.imports.hashes[dep])
+	}
+	writeVars(h, "ext", vars.vars)
+	writeVars(h, "tla", vars.topLevelVars)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,115 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/splunk/qbec/vm/datasource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVMInputHash(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, contents string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+	}
+	write("main.jsonnet", `local lib = import 'lib.libsonnet'; { foo: lib.foo, bar: std.extVar('bar'), baz: importstr 'baz.txt' }`)
+	write("lib.libsonnet", `{ foo: 'foo' }`)
+	write("baz.txt", `baz`)
+	write("unrelated.libsonnet", `{}`)
+	file := filepath.Join(dir, "main.jsonnet")
+	vars := VariableSet{}.WithVars(NewVar("bar", "bar"))
+
+	// every hash is computed using a new VM since imports are cached for the lifetime of a VM
+	hash := func(vars VariableSet) string {
+		h, err := New(Config{}).InputHash(file, vars)
+		require.NoError(t, err)
+		return h
+	}
+	a := assert.New(t)
+	orig := hash(vars)
+	a.Equal(orig, hash(vars))
+
+	write("unrelated.libsonnet", `{ a: 1 }`)
+	a.Equal(orig, hash(vars))
+
+	write("lib.libsonnet", `{ foo: 'foo2' }`)
+	libChanged := hash(vars)
+	a.NotEqual(orig, libChanged)
+
+	write("baz.txt", `baz2`)
+	strChanged := hash(vars)
+	a.NotEqual(libChanged, strChanged)
+
+	a.NotEqual(strChanged, hash(VariableSet{}.WithVars(NewVar("bar", "bar2"))))
+	a.NotEqual(strChanged, hash(VariableSet{}.WithVars(NewCodeVar("bar", "'bar'"))))
+	a.NotEqual(strChanged, hash(vars.WithTopLevelVars(NewVar("x", "y"))))
+
+	_, err := New(Config{}).InputHash(filepath.Join(dir, "missing.jsonnet"), vars)
+	a.Error(err)
+}
+
+func TestVMInputHashExternalState(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, contents string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+	}
+	write("main.jsonnet", `local helm = import 'helm.libsonnet'; helm.expand('./chart')`)
+	write("helm.libsonnet", `{ expand(chart):: std.native('expandHelmTemplate')(chart, {}, {}) }`)
+	_, err := New(Config{}).InputHash(filepath.Join(dir, "main.jsonnet"), VariableSet{})
+	require.Error(t, err)
+	assert.Equal(t, ErrExternalInputs, err)
+
+	write("helm.libsonnet", `{ expand(chart):: chart }`)
+	_, err = New(Config{}).InputHash(filepath.Join(dir, "main.jsonnet"), VariableSet{})
+	require.NoError(t, err)
+}
+
+func TestVMInputHashDataSource(t *testing.T) {
+	jvm := New(Config{DataSources: []datasource.DataSource{&replay{name: "replay"}}})
+	h1, err := jvm.InputHash("testdata/data-sources/replay.jsonnet", VariableSet{})
+	require.NoError(t, err)
+	jvm2 := New(Config{DataSources: []datasource.DataSource{&replay{name: "replay2"}}})
+	_, err = jvm2.InputHash("testdata/data-sources/replay.jsonnet", VariableSet{})
+	require.Error(t, err)
+	h2, err := jvm.InputHash("testdata/data-sources/replay.jsonnet", VariableSet{})
+	require.NoError(t, err)
+	assert.Equal(t, h1, h2)
+
+	// a data source with the same name that produces different output changes the hash
+	jvm3 := New(Config{DataSources: []datasource.DataSource{&constant{name: "replay", value: `{ "changed": true }`}}})
+	h3, err := jvm3.InputHash("testdata/data-sources/replay.jsonnet", VariableSet{})
+	require.NoError(t, err)
+	assert.NotEqual(t, h1, h3)
+}
+
+type constant struct {
+	name  string
+	value string
+}
+
+func (c *constant) Name() string {
+	return c.name
+}
+
+func (c *constant) Resolve(_ string) (string, error) {
+	return c.value, nil
+}
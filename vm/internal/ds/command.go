@@ -0,0 +1,101 @@
+// Copyright 2021 Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ds
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os/exec"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/vm/internal/natives"
+)
+
+// maxFailureOutput is the number of bytes of the standard output of a failed command that are retained
+// for diagnostics.
+const maxFailureOutput = 64 * 1024
+
+// CommandError is returned when a command that produces YAML documents fails.
+type CommandError struct {
+	Err    error  // the error returned by the command
+	Output []byte // the start of the standard output of the command
+}
+
+func (c *CommandError) Error() string {
+	return c.Err.Error()
+}
+
+// headBuffer retains the first bytes written to it, up to its limit, and discards the rest.
+type headBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (h *headBuffer) Write(p []byte) (int, error) {
+	if n := h.limit - h.Len(); n > 0 {
+		if len(p) < n {
+			n = len(p)
+		}
+		h.Buffer.Write(p[:n])
+	}
+	return len(p), nil
+}
+
+// writeTracker records the first error returned by its writer.
+type writeTracker struct {
+	w   io.Writer
+	err error
+}
+
+func (t *writeTracker) Write(p []byte) (int, error) {
+	n, err := t.w.Write(p)
+	if err != nil && t.err == nil {
+		t.err = err
+	}
+	return n, err
+}
+
+// StreamYAMLOutput runs the supplied command and writes the YAML documents that it produces on its standard output
+// to the supplied writer as a JSON array, while the command runs. The cancel function must terminate the command,
+// typically by canceling the context with which it was created, and is called when the output cannot be parsed
+// or written, such that size limits enforced by the writer take effect before all output is produced.
+// The error of a failed write is returned as-is and a failure of the command is returned as a *CommandError.
+func StreamYAMLOutput(cmd *exec.Cmd, cancel func(), w io.Writer) error {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	head := &headBuffer{limit: maxFailureOutput}
+	tracker := &writeTracker{w: w}
+	streamErr := natives.WriteYAMLDocumentsAsJSON(io.TeeReader(stdout, head), tracker)
+	if streamErr != nil {
+		cancel()
+		_, _ = io.Copy(ioutil.Discard, stdout)
+	}
+	waitErr := cmd.Wait()
+	switch {
+	case tracker.err != nil:
+		return tracker.err
+	case waitErr != nil:
+		return &CommandError{Err: waitErr, Output: head.Bytes()}
+	case streamErr != nil:
+		return errors.Wrap(streamErr, "parse output")
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ds
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingWriter struct {
+	limit int
+	n     int
+}
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	if f.n+len(p) > f.limit {
+		return 0, errors.New("output too large")
+	}
+	f.n += len(p)
+	return len(p), nil
+}
+
+func runShell(t *testing.T, script string, w io.Writer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return StreamYAMLOutput(exec.CommandContext(ctx, "sh", "-c", script), cancel, w)
+}
+
+func TestStreamYAMLOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.SkipNow()
+	}
+	var b bytes.Buffer
+	err := runShell(t, `echo "a: 1"; echo "---"; echo "---"; echo "b: [x]"`, &b)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"a":1},{"b":["x"]}]`, b.String())
+
+	b.Reset()
+	err = runShell(t, `true`, &b)
+	require.NoError(t, err)
+	assert.Equal(t, `[]`, b.String())
+}
+
+func TestStreamYAMLOutputNegative(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.SkipNow()
+	}
+	start := time.Now()
+	err := runShell(t, `while true; do echo "---"; echo "a: b"; done`, &failingWriter{limit: 100})
+	require.Error(t, err)
+	assert.Equal(t, "output too large", err.Error())
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	var b bytes.Buffer
+	err = runShell(t, `echo "a: b"; exit 3`, &b)
+	require.Error(t, err)
+	var ce *CommandError
+	require.True(t, errors.As(err, &ce))
+	assert.Equal(t, "exit status 3", err.Error())
+	assert.Equal(t, "a: b\n", string(ce.Output))
+
+	err = runShell(t, `echo "a: [b"`, &b)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "parse output")
+}
@@ -21,9 +21,7 @@ package exec
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"os/exec"
-	"path/filepath"
+	"io"
 	"time"
 
 	"github.com/pkg/errors"
@@ -48,22 +46,7 @@ type Config struct {
 	timeout time.Duration // internal representation
 }
 
-func findExecutable(cmd string) (string, error) {
-	if !filepath.IsAbs(cmd) {
-		p, err := filepath.Abs(cmd)
-		if err == nil {
-			stat, err := os.Stat(cmd)
-			if err == nil {
-				if m := stat.Mode(); !m.IsDir() && m&0111 != 0 {
-					return p, nil
-				}
-			}
-		}
-	}
-	return exec.LookPath(cmd)
-}
-
-func (c *Config) assertValid() error {
+func (c *Config) assertValid(dir string) error {
 	if c.Command == "" {
 		return fmt.Errorf("command not specified")
 	}
@@ -74,7 +57,7 @@ func (c *Config) assertValid() error {
 		}
 		c.timeout = t
 	}
-	exe, err := findExecutable(c.Command)
+	exe, err := ds.FindExecutable(c.Command, dir)
 	if err != nil {
 		return fmt.Errorf("invalid command '%s': %v", c.Command, err)
 	}
@@ -91,14 +74,17 @@ func (c *Config) initDefaults() {
 type execSource struct {
 	name      string
 	configVar string
+	dir       string
 	runner    *runner
 }
 
-// New creates a new exec data source
-func New(name string, configVar string) ds.DataSourceWithLifecycle {
+// New creates a new exec data source. Commands are run from the supplied directory,
+// or the working directory when it is blank.
+func New(name string, configVar string, dir string) ds.DataSourceWithLifecycle {
 	return &execSource{
 		name:      name,
 		configVar: configVar,
+		dir:       dir,
 	}
 }
 
@@ -122,11 +108,11 @@ func (d *execSource) Init(p datasource.ConfigProvider) (fErr error) {
 		return err
 	}
 	c.initDefaults()
-	err = c.assertValid()
+	err = c.assertValid(d.dir)
 	if err != nil {
 		return err
 	}
-	d.runner = newRunner(&c)
+	d.runner = newRunner(&c, d.dir)
 	return nil
 }
 
@@ -138,6 +124,14 @@ func (d *execSource) Resolve(path string) (string, error) {
 	})
 }
 
+// ResolveTo implements the streaming interface method.
+func (d *execSource) ResolveTo(path string, w io.Writer) error {
+	return d.runner.runWithEnvTo(map[string]string{
+		"__DS_NAME__": d.name,
+		"__DS_PATH__": path,
+	}, w)
+}
+
 // Close implements the interface method.
 func (d *execSource) Close() error {
 	return d.runner.close()
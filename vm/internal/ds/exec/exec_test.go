@@ -23,6 +23,7 @@ import (
 	"os/exec"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/splunk/qbec/vm/datasource"
 	"github.com/stretchr/testify/assert"
@@ -47,7 +48,7 @@ func TestExecBasic(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(fmt.Sprintf("inhert_%t_override_%t", test.inherit, test.override), func(t *testing.T) {
-			ds := New("replay", "var1")
+			ds := New("replay", "var1", "")
 			os.Setenv("_akey_", "aval")
 			defer os.Unsetenv("_akey_")
 			env := ""
@@ -108,7 +109,7 @@ func TestExecRelativeFilePath(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("not running exec bit tests on windows")
 	}
-	ds := New("replay", "var1")
+	ds := New("replay", "var1", "")
 	err := ds.Init(func(name string) (string, error) {
 		c := Config{Command: "testdata/exec-bit-set.sh"}
 		b, _ := json.Marshal(c)
@@ -121,6 +122,23 @@ func TestExecRelativeFilePath(t *testing.T) {
 	assert.Equal(t, "{}\n", s)
 }
 
+func TestExecRelativeToDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("not running exec bit tests on windows")
+	}
+	ds := New("replay", "var1", "testdata")
+	err := ds.Init(func(name string) (string, error) {
+		c := Config{Command: "exec-bit-set.sh"}
+		b, _ := json.Marshal(c)
+		return string(b), nil
+	})
+	require.NoError(t, err)
+	defer ds.Close()
+	s, err := ds.Resolve("/")
+	require.NoError(t, err)
+	assert.Equal(t, "{}\n", s)
+}
+
 func TestExecNegative(t *testing.T) {
 	if _, err := exec.LookPath("qbec-replay-exec"); err != nil {
 		t.SkipNow()
@@ -213,7 +231,7 @@ func TestExecNegative(t *testing.T) {
 			if test.skipWindows && runtime.GOOS == "windows" {
 				t.SkipNow()
 			}
-			ds := New("replay", "c")
+			ds := New("replay", "c", "")
 			cp := func(name string) (string, error) {
 				b, _ := json.Marshal(test.config)
 				return string(b), nil
@@ -242,3 +260,35 @@ func TestExecNegative(t *testing.T) {
 		})
 	}
 }
+
+type failWriter struct {
+	n int
+}
+
+func (f *failWriter) Write(p []byte) (int, error) {
+	f.n += len(p)
+	return 0, fmt.Errorf("too much output")
+}
+
+func TestExecResolveTo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("not running shell tests on windows")
+	}
+	ds := New("yes", "var1", "")
+	err := ds.Init(func(name string) (string, error) {
+		c := Config{Command: "yes", Timeout: "10s"}
+		b, _ := json.Marshal(c)
+		return string(b), nil
+	})
+	require.NoError(t, err)
+	defer ds.Close()
+	s, ok := ds.(datasource.StreamingDataSource)
+	require.True(t, ok)
+	start := time.Now()
+	var w failWriter
+	err = s.ResolveTo("/", &w)
+	require.Error(t, err)
+	assert.Equal(t, "too much output", err.Error())
+	assert.True(t, w.n > 0)
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
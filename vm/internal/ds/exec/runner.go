@@ -20,23 +20,53 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+
+	"github.com/splunk/qbec/internal/sio"
 )
 
 type runner struct {
-	c *Config
+	c   *Config
+	dir string
+}
+
+func newRunner(c *Config, dir string) *runner {
+	return &runner{c: c, dir: dir}
+}
+
+// cancelWriter cancels the command when a write to its delegate fails, such that the command does not
+// block on a full pipe after its output is no longer consumed.
+type cancelWriter struct {
+	w      io.Writer
+	cancel context.CancelFunc
+	err    error
 }
 
-func newRunner(c *Config) *runner {
-	return &runner{c: c}
+func (c *cancelWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	if err != nil && c.err == nil {
+		c.err = err
+		c.cancel()
+	}
+	return n, err
 }
 
 func (r *runner) runWithEnv(e map[string]string) (string, error) {
+	var capture bytes.Buffer
+	if err := r.runWithEnvTo(e, &capture); err != nil {
+		return "", err
+	}
+	return capture.String(), nil
+}
+
+func (r *runner) runWithEnvTo(e map[string]string, w io.Writer) error {
 	ctx, cancel := context.WithTimeout(context.Background(), r.c.timeout)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, r.c.Command, r.c.Args...)
+	cmd.Dir = r.dir
 	var env []string
 	if r.c.InheritEnv {
 		env = os.Environ()
@@ -49,15 +79,18 @@ func (r *runner) runWithEnv(e map[string]string) (string, error) {
 	}
 	cmd.Env = env
 
-	var capture bytes.Buffer
+	cw := &cancelWriter{w: w, cancel: cancel}
 	cmd.Stdin = bytes.NewReader([]byte(r.c.Stdin))
-	cmd.Stdout = &capture
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = cw
+	stderr := sio.NewLineWriter(r.c.Command)
+	cmd.Stderr = stderr
 
-	if err := cmd.Run(); err != nil {
-		return "", err
+	err := cmd.Run()
+	stderr.Flush()
+	if cw.err != nil { // the process was killed because its output could not be written
+		return cw.err
 	}
-	return capture.String(), nil
+	return err
 }
 
 func (r *runner) close() error {
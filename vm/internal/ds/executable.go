@@ -0,0 +1,38 @@
+// Copyright 2021 Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ds
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// FindExecutable returns the path to the supplied command, resolving a relative path against the supplied
+// directory, or the working directory when it is blank, before looking for the command in the system path.
+func FindExecutable(cmd string, dir string) (string, error) {
+	if !filepath.IsAbs(cmd) {
+		p, err := filepath.Abs(filepath.Join(dir, cmd))
+		if err == nil {
+			stat, err := os.Stat(p)
+			if err == nil {
+				if m := stat.Mode(); !m.IsDir() && m&0111 != 0 {
+					return p, nil
+				}
+			}
+		}
+	}
+	return exec.LookPath(cmd)
+}
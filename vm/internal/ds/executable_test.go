@@ -0,0 +1,48 @@
+// Copyright 2021 Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ds
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindExecutable(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bin"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "bin", "tool"), []byte("#!/bin/sh\n"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "bin", "data"), []byte("data"), 0644))
+
+	exe, err := FindExecutable("bin/tool", dir)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "bin", "tool"), exe)
+
+	_, err = FindExecutable("bin/data", dir)
+	require.Error(t, err)
+	_, err = FindExecutable("bin", dir)
+	require.Error(t, err)
+
+	sh, err := exec.LookPath("sh")
+	require.NoError(t, err)
+	exe, err = FindExecutable("sh", dir)
+	require.NoError(t, err)
+	assert.Equal(t, sh, exe)
+}
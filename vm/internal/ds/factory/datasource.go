@@ -22,13 +22,20 @@ import (
 	"github.com/splunk/qbec/vm/internal/ds"
 	"github.com/splunk/qbec/vm/internal/ds/exec"
 	"github.com/splunk/qbec/vm/internal/ds/helm3"
+	"github.com/splunk/qbec/vm/internal/ds/http"
+	"github.com/splunk/qbec/vm/internal/ds/kustomize"
+	"github.com/splunk/qbec/vm/internal/ds/plugin"
+	"github.com/splunk/qbec/vm/internal/ds/sops"
+	"github.com/splunk/qbec/vm/internal/ds/vault"
 )
 
 // Create creates a new data source from the supplied URL.
 // Such a URL has a scheme that is the type of supported data source,
 // a hostname that is the name that it should be referred to in user code,
 // and a query param called configVar which supplies the data source config.
-func Create(u string) (ds.DataSourceWithLifecycle, error) {
+// Data sources that run commands or read files do so relative to the supplied directory, or the working
+// directory when it is blank.
+func Create(u string, dir string) (ds.DataSourceWithLifecycle, error) {
 	parsed, err := url.Parse(u)
 	if err != nil {
 		return nil, errors.Wrapf(err, "parse URL '%s'", u)
@@ -37,6 +44,11 @@ func Create(u string) (ds.DataSourceWithLifecycle, error) {
 	switch scheme {
 	case exec.Scheme:
 	case helm3.Scheme:
+	case http.Scheme, http.SecureScheme:
+	case kustomize.Scheme:
+	case plugin.Scheme:
+	case sops.Scheme:
+	case vault.Scheme:
 	default:
 		return nil, fmt.Errorf("data source URL '%s', unsupported scheme '%s'", u, scheme)
 	}
@@ -53,9 +65,19 @@ func Create(u string) (ds.DataSourceWithLifecycle, error) {
 	}
 	switch scheme {
 	case exec.Scheme:
-		return makeLazy(exec.New(name, varName)), nil
+		return makeLazy(exec.New(name, varName, dir)), nil
 	case helm3.Scheme:
-		return makeLazy(helm3.New(name, varName)), nil
+		return makeLazy(helm3.New(name, varName, dir)), nil
+	case http.Scheme, http.SecureScheme:
+		return makeLazy(http.New(scheme, name, varName)), nil
+	case kustomize.Scheme:
+		return makeLazy(kustomize.New(name, varName, dir)), nil
+	case plugin.Scheme:
+		return makeLazy(plugin.New(name, varName, dir)), nil
+	case sops.Scheme:
+		return makeLazy(sops.New(name, varName, dir)), nil
+	case vault.Scheme:
+		return makeLazy(vault.New(name, varName)), nil
 	default:
 		return nil, fmt.Errorf("internal error: unable to create a data source for %s", u)
 	}
@@ -24,7 +24,22 @@ import (
 )
 
 func TestDataSourceSuccess(t *testing.T) {
-	ds, err := Create("exec://foo?configVar=bar")
+	ds, err := Create("exec://foo?configVar=bar", "")
+	require.NoError(t, err)
+	assert.IsType(t, &lazySource{}, ds)
+	ds, err = Create("kustomize://foo?configVar=bar", "")
+	require.NoError(t, err)
+	assert.IsType(t, &lazySource{}, ds)
+	ds, err = Create("https://foo?configVar=bar", "")
+	require.NoError(t, err)
+	assert.IsType(t, &lazySource{}, ds)
+	ds, err = Create("plugin://foo?configVar=bar", "")
+	require.NoError(t, err)
+	assert.IsType(t, &lazySource{}, ds)
+	ds, err = Create("sops://foo?configVar=bar", "")
+	require.NoError(t, err)
+	assert.IsType(t, &lazySource{}, ds)
+	ds, err = Create("vault://foo?configVar=bar", "")
 	require.NoError(t, err)
 	assert.IsType(t, &lazySource{}, ds)
 }
@@ -63,7 +78,7 @@ func TestNegativeCases(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			_, err := Create(test.uri)
+			_, err := Create(test.uri, "")
 			require.Error(t, err)
 			assert.Contains(t, err.Error(), test.msg)
 		})
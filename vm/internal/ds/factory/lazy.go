@@ -17,6 +17,7 @@
 package factory
 
 import (
+	"io"
 	"sync"
 
 	"github.com/splunk/qbec/vm/datasource"
@@ -65,8 +66,27 @@ func (l *lazySource) Resolve(path string) (string, error) {
 	return l.delegate.Resolve(path)
 }
 
+// ResolveTo implements the streaming interface, delegating to the underlying data source if it supports streaming.
+func (l *lazySource) ResolveTo(path string, w io.Writer) error {
+	if err := l.initOnce(); err != nil {
+		return err
+	}
+	if s, ok := l.delegate.(datasource.StreamingDataSource); ok {
+		return s.ResolveTo(path, w)
+	}
+	out, err := l.delegate.Resolve(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, out)
+	return err
+}
+
 func (l *lazySource) Close() error {
 	return l.delegate.Close()
 }
 
-var _ ds.DataSourceWithLifecycle = &lazySource{}
+var (
+	_ ds.DataSourceWithLifecycle     = &lazySource{}
+	_ datasource.StreamingDataSource = &lazySource{}
+)
@@ -0,0 +1,453 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package helm3
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/sio"
+)
+
+const (
+	ociScheme           = "oci"
+	helmChartLayerType  = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	ociManifestType     = "application/vnd.oci.image.manifest.v1+json"
+	defaultFetchTimeout = time.Minute
+)
+
+// Credentials are the credentials used to access a chart repository or OCI registry.
+type Credentials struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"` // bearer token, used instead of username and password when set
+}
+
+// FetchConfig configures native chart downloads. When present, charts are pulled by qbec from classic helm
+// repositories and OCI registries, cached locally, and the helm command is only used to render the downloaded chart.
+type FetchConfig struct {
+	CacheDir  string                 `json:"cacheDir,omitempty"`  // cache directory, defaults to the qbec cache dir
+	Timeout   string                 `json:"timeout,omitempty"`   // timeout for a single download
+	PlainHTTP bool                   `json:"plainHttp,omitempty"` // use HTTP instead of HTTPS for OCI registries
+	Auth      map[string]Credentials `json:"auth,omitempty"`      // credentials keyed by host name
+	timeout   time.Duration          // internal representation
+}
+
+// defaultCacheDir returns the directory under which charts are cached when one is not explicitly configured.
+func defaultCacheDir() (string, error) {
+	if dir := os.Getenv("QBEC_CACHE_DIR"); dir != "" {
+		return filepath.Join(dir, "helm3"), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.Wrap(err, "get user cache dir")
+	}
+	return filepath.Join(dir, "qbec", "helm3"), nil
+}
+
+func (f *FetchConfig) init() error {
+	f.timeout = defaultFetchTimeout
+	if f.Timeout != "" {
+		t, err := time.ParseDuration(f.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid fetch timeout '%s': %v", f.Timeout, err)
+		}
+		f.timeout = t
+	}
+	if f.CacheDir == "" {
+		dir, err := defaultCacheDir()
+		if err != nil {
+			return err
+		}
+		f.CacheDir = dir
+	}
+	abs, err := filepath.Abs(f.CacheDir)
+	if err != nil {
+		return err
+	}
+	f.CacheDir = abs
+	return nil
+}
+
+// fetcher downloads charts and caches them on the local filesystem.
+type fetcher struct {
+	config FetchConfig
+	client *http.Client
+	l      sync.Mutex
+}
+
+func newFetcher(config FetchConfig) *fetcher {
+	return &fetcher{
+		config: config,
+		client: &http.Client{Timeout: config.timeout},
+	}
+}
+
+// chartRef is a reference to a chart that can be fetched.
+type chartRef struct {
+	repo    string // the repository URL, which may use the oci scheme
+	name    string // the chart name
+	version string // the chart version
+	url     string // direct URL to the chart archive, used when repo is empty
+}
+
+func (r chartRef) String() string {
+	if r.url != "" {
+		return r.url
+	}
+	return fmt.Sprintf("%s/%s:%s", strings.TrimSuffix(r.repo, "/"), r.name, r.version)
+}
+
+// cacheFile returns the file under which the referenced chart is cached.
+func (f *fetcher) cacheFile(ref chartRef) string {
+	sum := sha256.Sum256([]byte(ref.String()))
+	name := ref.name
+	if name == "" {
+		name = "chart"
+	}
+	return filepath.Join(f.config.CacheDir, fmt.Sprintf("%s-%s.tgz", name, hex.EncodeToString(sum[:8])))
+}
+
+// fetch returns the local path to the chart archive, downloading it if it is not already cached.
+// Charts without a version are always downloaded since the latest version can change between runs.
+func (f *fetcher) fetch(ref chartRef) (string, error) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	file := f.cacheFile(ref)
+	canCache := ref.version != "" || ref.url != ""
+	if canCache {
+		if _, err := os.Stat(file); err == nil {
+			sio.Debugln("use cached chart", file, "for", ref)
+			return file, nil
+		}
+	}
+	var b []byte
+	var err error
+	switch {
+	case ref.url != "":
+		b, err = f.download(ref.url, "", "", "")
+	case strings.HasPrefix(ref.repo, ociScheme+"://"):
+		b, err = f.fetchOCI(ref)
+	default:
+		b, err = f.fetchFromRepo(ref)
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "fetch chart %s", ref)
+	}
+	if err := os.MkdirAll(f.config.CacheDir, 0755); err != nil {
+		return "", errors.Wrap(err, "create cache dir")
+	}
+	tmp, err := ioutil.TempFile(f.config.CacheDir, ".download-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return "", err
+	}
+	sio.Debugln("cached chart", ref, "as", file)
+	return file, nil
+}
+
+func (f *fetcher) credentialsFor(u *url.URL) (Credentials, bool) {
+	c, ok := f.config.Auth[u.Host]
+	if !ok {
+		c, ok = f.config.Auth[u.Hostname()]
+	}
+	return c, ok
+}
+
+func (f *fetcher) authorize(req *http.Request) {
+	c, ok := f.credentialsFor(req.URL)
+	if !ok {
+		return
+	}
+	switch {
+	case c.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case c.Username != "":
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+}
+
+// download fetches the contents of the supplied URL. If a digest is supplied, the contents are verified against it.
+// The request is authorized using the bearer token if one is supplied, or the configured credentials for the host.
+func (f *fetcher) download(u string, accept string, digest string, token string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		f.authorize(req)
+	}
+	res, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: unexpected status %s", u, res.Status)
+	}
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read %s", u)
+	}
+	if err := verifyDigest(b, digest); err != nil {
+		return nil, errors.Wrapf(err, "verify %s", u)
+	}
+	return b, nil
+}
+
+func verifyDigest(b []byte, digest string) error {
+	if digest == "" {
+		return nil
+	}
+	digest = strings.TrimPrefix(digest, "sha256:")
+	sum := sha256.Sum256(b)
+	if actual := hex.EncodeToString(sum[:]); actual != digest {
+		return fmt.Errorf("digest mismatch, want %s, got %s", digest, actual)
+	}
+	return nil
+}
+
+// repoIndex is the subset of a helm repository index that is needed to find charts.
+type repoIndex struct {
+	Entries map[string][]struct {
+		Version string   `json:"version"`
+		Digest  string   `json:"digest"`
+		URLs    []string `json:"urls"`
+	} `json:"entries"`
+}
+
+// fetchFromRepo downloads a chart from a classic helm repository using its index.
+func (f *fetcher) fetchFromRepo(ref chartRef) ([]byte, error) {
+	base, err := url.Parse(strings.TrimSuffix(ref.repo, "/") + "/")
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse repo URL %q", ref.repo)
+	}
+	indexURL := base.ResolveReference(&url.URL{Path: "index.yaml"})
+	b, err := f.download(indexURL.String(), "", "", "")
+	if err != nil {
+		return nil, err
+	}
+	var index repoIndex
+	if err := yaml.Unmarshal(b, &index); err != nil {
+		return nil, errors.Wrapf(err, "parse index %s", indexURL)
+	}
+	versions := index.Entries[ref.name]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("chart %s not found in repo %s", ref.name, ref.repo)
+	}
+	// repository indexes list the latest version first
+	entry := versions[0]
+	if ref.version != "" {
+		found := false
+		for _, v := range versions {
+			if v.Version == ref.version || v.Version == "v"+ref.version {
+				entry = v
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("version %s of chart %s not found in repo %s", ref.version, ref.name, ref.repo)
+		}
+	}
+	if len(entry.URLs) == 0 {
+		return nil, fmt.Errorf("no URLs for version %s of chart %s in repo %s", entry.Version, ref.name, ref.repo)
+	}
+	chartURL, err := base.Parse(entry.URLs[0])
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse chart URL %q", entry.URLs[0])
+	}
+	return f.download(chartURL.String(), "", entry.Digest, "")
+}
+
+// ociManifest is the subset of an OCI manifest that is needed to find the chart layer.
+type ociManifest struct {
+	Layers []struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+	} `json:"layers"`
+}
+
+// fetchOCI downloads a chart from an OCI registry.
+func (f *fetcher) fetchOCI(ref chartRef) ([]byte, error) {
+	if ref.version == "" {
+		return nil, fmt.Errorf("version must be specified for OCI charts")
+	}
+	repo := strings.TrimPrefix(strings.TrimSuffix(ref.repo, "/"), ociScheme+"://")
+	parts := strings.SplitN(repo, "/", 2)
+	host := parts[0]
+	path := ref.name
+	if len(parts) == 2 {
+		path = parts[1] + "/" + ref.name
+	}
+	scheme := "https"
+	if f.config.PlainHTTP {
+		scheme = "http"
+	}
+	base := fmt.Sprintf("%s://%s/v2/%s", scheme, host, path)
+	tag := strings.ReplaceAll(ref.version, "+", "_") // OCI tags do not allow '+'
+
+	token, err := f.registryToken(base+"/manifests/"+tag, path)
+	if err != nil {
+		return nil, err
+	}
+	b, err := f.download(base+"/manifests/"+tag, ociManifestType, "", token)
+	if err != nil {
+		return nil, err
+	}
+	var m ociManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, errors.Wrap(err, "unmarshal manifest")
+	}
+	for _, l := range m.Layers {
+		if l.MediaType == helmChartLayerType {
+			return f.download(base+"/blobs/"+l.Digest, "", l.Digest, token)
+		}
+	}
+	return nil, fmt.Errorf("no layer with media type %s in manifest", helmChartLayerType)
+}
+
+// registryToken probes the supplied URL and returns a bearer token if the registry requires token authentication.
+// A blank token is returned if the registry accepts requests with the configured credentials as-is.
+func (f *fetcher) registryToken(probeURL string, repoPath string) (string, error) {
+	req, err := http.NewRequest(http.MethodHead, probeURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", ociManifestType)
+	f.authorize(req)
+	res, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		return "", nil
+	}
+	challenge := res.Header.Get("WWW-Authenticate")
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return "", fmt.Errorf("HEAD %s: unauthorized", probeURL)
+	}
+	params := parseChallenge(challenge[len("bearer "):])
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("no realm in auth challenge %q", challenge)
+	}
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return "", errors.Wrapf(err, "parse realm %q", realm)
+	}
+	q := tokenURL.Query()
+	if s := params["service"]; s != "" {
+		q.Set("service", s)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", repoPath)
+	}
+	q.Set("scope", scope)
+	tokenURL.RawQuery = q.Encode()
+
+	treq, err := http.NewRequest(http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	// the credentials for the token service are the ones configured for the registry
+	if c, ok := f.credentialsFor(req.URL); ok && c.Username != "" {
+		treq.SetBasicAuth(c.Username, c.Password)
+	}
+	tres, err := f.client.Do(treq)
+	if err != nil {
+		return "", err
+	}
+	defer tres.Body.Close()
+	if tres.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get token from %s: unexpected status %s", realm, tres.Status)
+	}
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(tres.Body).Decode(&tok); err != nil {
+		return "", errors.Wrap(err, "decode token response")
+	}
+	if tok.Token != "" {
+		return tok.Token, nil
+	}
+	return tok.AccessToken, nil
+}
+
+// parseChallenge parses the comma-separated key="value" parameters of an auth challenge.
+func parseChallenge(s string) map[string]string {
+	ret := map[string]string{}
+	for s != "" {
+		s = strings.TrimLeft(s, ", ")
+		eq := strings.Index(s, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = s[eq+1:]
+		var val string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.Index(s[1:], `"`)
+			if end < 0 {
+				val, s = s[1:], ""
+			} else {
+				val, s = s[1:end+1], s[end+2:]
+			}
+		} else {
+			end := strings.Index(s, ",")
+			if end < 0 {
+				val, s = s, ""
+			} else {
+				val, s = s[:end], s[end:]
+			}
+		}
+		ret[key] = val
+	}
+	return ret
+}
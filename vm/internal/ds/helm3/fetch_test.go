@@ -0,0 +1,285 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package helm3
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var chartContents = []byte("not really a chart archive")
+
+func digestOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func newRepoServer(t *testing.T, downloads *int32) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/charts/index.yaml", func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok || u != "user" || p != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `
+apiVersion: v1
+entries:
+  foo:
+    - version: 1.1.0
+      digest: %s
+      urls: [ foo-1.1.0.tgz ]
+    - version: 1.0.0
+      digest: bad-digest
+      urls: [ foo-1.0.0.tgz ]
+`, digestOf(chartContents))
+	})
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(downloads, 1)
+		_, _ = w.Write(chartContents)
+	}
+	mux.HandleFunc("/charts/foo-1.1.0.tgz", handler)
+	mux.HandleFunc("/charts/foo-1.0.0.tgz", handler)
+	return httptest.NewServer(mux)
+}
+
+func TestFetchFromRepo(t *testing.T) {
+	var downloads int32
+	server := newRepoServer(t, &downloads)
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	fc := FetchConfig{
+		CacheDir: t.TempDir(),
+		Auth: map[string]Credentials{
+			u.Host: {Username: "user", Password: "pass"},
+		},
+	}
+	require.NoError(t, fc.init())
+	f := newFetcher(fc)
+	ref := chartRef{repo: server.URL + "/charts", name: "foo", version: "1.1.0"}
+	file, err := f.fetch(ref)
+	require.NoError(t, err)
+	b, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, chartContents, b)
+	assert.EqualValues(t, 1, downloads)
+
+	// second fetch is served from the cache
+	file2, err := f.fetch(ref)
+	require.NoError(t, err)
+	assert.Equal(t, file, file2)
+	assert.EqualValues(t, 1, downloads)
+
+	// latest version is the first one in the index, and is never cached
+	_, err = f.fetch(chartRef{repo: server.URL + "/charts", name: "foo"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, downloads)
+}
+
+func TestFetchFromRepoNegative(t *testing.T) {
+	var downloads int32
+	server := newRepoServer(t, &downloads)
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	tests := []struct {
+		name string
+		ref  chartRef
+		auth bool
+		msg  string
+	}{
+		{"no-auth", chartRef{repo: server.URL + "/charts", name: "foo", version: "1.1.0"}, false, "401 Unauthorized"},
+		{"bad-chart", chartRef{repo: server.URL + "/charts", name: "bar", version: "1.1.0"}, true, "chart bar not found in repo"},
+		{"bad-version", chartRef{repo: server.URL + "/charts", name: "foo", version: "2.0.0"}, true, "version 2.0.0 of chart foo not found"},
+		{"bad-digest", chartRef{repo: server.URL + "/charts", name: "foo", version: "1.0.0"}, true, "digest mismatch"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fc := FetchConfig{CacheDir: t.TempDir()}
+			if test.auth {
+				fc.Auth = map[string]Credentials{u.Host: {Username: "user", Password: "pass"}}
+			}
+			require.NoError(t, fc.init())
+			_, err := newFetcher(fc).fetch(test.ref)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.msg)
+		})
+	}
+}
+
+func newRegistryServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	var server *httptest.Server
+	const token = "s3cr3t"
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.Header().Set("WWW-Authenticate",
+				fmt.Sprintf(`Bearer realm="%s/token",service="test-registry",scope="repository:org/charts/foo:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok || u != "robot" || p != "pw" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("scope") != "repository:org/charts/foo:pull" || r.URL.Query().Get("service") != "test-registry" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": token})
+	})
+	digest := "sha256:" + digestOf(chartContents)
+	mux.HandleFunc("/v2/org/charts/foo/manifests/1.0.0_build", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		fmt.Fprintf(w, `{
+	"schemaVersion": 2,
+	"layers": [
+		{ "mediaType": "application/vnd.cncf.helm.config.v1+json", "digest": "sha256:0000" },
+		{ "mediaType": "%s", "digest": "%s" }
+	]
+}`, helmChartLayerType, digest)
+	})
+	mux.HandleFunc("/v2/org/charts/foo/blobs/"+digest, func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		_, _ = w.Write(chartContents)
+	})
+	server = httptest.NewServer(mux)
+	return server
+}
+
+func TestFetchOCI(t *testing.T) {
+	server := newRegistryServer(t)
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	fc := FetchConfig{
+		CacheDir:  t.TempDir(),
+		PlainHTTP: true,
+		Auth: map[string]Credentials{
+			u.Host: {Username: "robot", Password: "pw"},
+		},
+	}
+	require.NoError(t, fc.init())
+	f := newFetcher(fc)
+	file, err := f.fetch(chartRef{repo: "oci://" + u.Host + "/org/charts", name: "foo", version: "1.0.0+build"})
+	require.NoError(t, err)
+	b, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, chartContents, b)
+
+	_, err = f.fetch(chartRef{repo: "oci://" + u.Host + "/org/charts", name: "foo"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "version must be specified for OCI charts")
+
+	fc.Auth = nil
+	fc.CacheDir = t.TempDir()
+	_, err = newFetcher(fc).fetch(chartRef{repo: "oci://" + u.Host + "/org/charts", name: "foo", version: "1.0.0+build"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401 Unauthorized")
+}
+
+func TestParseChallenge(t *testing.T) {
+	ret := parseChallenge(`realm="https://auth.example.com/token",service=registry,scope="repository:foo/bar:pull,push"`)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry",
+		"scope":   "repository:foo/bar:pull,push",
+	}, ret)
+}
+
+func TestRunTemplateWithFetch(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.SkipNow()
+	}
+	var downloads int32
+	server := newRepoServer(t, &downloads)
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	cacheDir := t.TempDir()
+	cfg := fmt.Sprintf(`{
+	"command": "testdata/fake-helm.sh",
+	"fetch": {
+		"cacheDir": %q,
+		"auth": { %q: { "username": "user", "password": "pass" } }
+	}
+}`, cacheDir, u.Host)
+	h := &helm3Source{name: "helm", configVar: "cfg"}
+	err = h.Init(func(string) (string, error) { return cfg, nil })
+	require.NoError(t, err)
+	out, err := renderTemplate(h, &url.URL{Path: "/foo"}, TemplateConfig{
+		Name: "my-release",
+		Options: TemplateOptions{
+			Namespace: "ns1",
+			Repo:      server.URL + "/charts",
+			Version:   "1.1.0",
+			Username:  "user",
+			Password:  "pass",
+		},
+	})
+	require.NoError(t, err)
+	docs, ok := out.([]interface{})
+	require.True(t, ok)
+	require.Equal(t, 1, len(docs))
+	args := docs[0].(map[string]interface{})["args"].(string)
+	assert.True(t, strings.HasPrefix(args, "template --debug --namespace=ns1 my-release "+cacheDir))
+	assert.NotContains(t, args, "--repo")
+	assert.NotContains(t, args, "--version")
+	assert.NotContains(t, args, "--password")
+}
+
+func TestRunTemplateOCIWithoutFetch(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.SkipNow()
+	}
+	h := &helm3Source{name: "helm", configVar: "cfg"}
+	err := h.Init(func(string) (string, error) { return `{ "command": "testdata/fake-helm.sh" }`, nil })
+	require.NoError(t, err)
+	out, err := renderTemplate(h, &url.URL{Path: "/foo"}, TemplateConfig{
+		Options: TemplateOptions{
+			Repo:    "oci://registry.example.com/charts/",
+			Version: "1.0.0",
+		},
+	})
+	require.NoError(t, err)
+	docs := out.([]interface{})
+	assert.Equal(t, "template --debug --version=1.0.0 oci://registry.example.com/charts/foo --values -",
+		docs[0].(map[string]interface{})["args"])
+}
@@ -0,0 +1,192 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/sio"
+)
+
+const gitPrefix = "git+"
+
+// isGitRepo returns true if the supplied repo option refers to a git repository.
+func isGitRepo(repo string) bool {
+	return strings.HasPrefix(repo, gitPrefix)
+}
+
+// gitRef is a reference to a chart in a subdirectory of a git repository.
+type gitRef struct {
+	url    string // the clone URL without the git+ prefix
+	ref    string // branch, tag or commit to check out, blank for the default branch
+	subdir string // the chart directory relative to the repository root
+}
+
+func (r gitRef) String() string {
+	s := r.url
+	if r.subdir != "" {
+		s += "//" + r.subdir
+	}
+	if r.ref != "" {
+		s += "?ref=" + r.ref
+	}
+	return s
+}
+
+// parseGitRef parses a repo option of the form git+https://host/org/repo//charts/foo?ref=v1.2.3. The chart
+// path in the import is appended to the subdirectory in the repo option, if any. When the repo option does not
+// have a ref query parameter, the supplied version is used as the ref.
+func parseGitRef(repo string, chartPath string, version string) (gitRef, error) {
+	u, err := url.Parse(strings.TrimPrefix(repo, gitPrefix))
+	if err != nil {
+		return gitRef{}, errors.Wrapf(err, "parse git repo %q", repo)
+	}
+	if u.Scheme == "" {
+		return gitRef{}, fmt.Errorf("git repo %q does not have a scheme", repo)
+	}
+	ref := u.Query().Get("ref")
+	if ref == "" {
+		ref = version
+	}
+	u.RawQuery = ""
+	subdir := ""
+	// the subdirectory is separated from the repository path by a double slash
+	if pos := strings.Index(u.Path, "//"); pos >= 0 {
+		subdir = u.Path[pos+2:]
+		u.Path = u.Path[:pos]
+		u.RawPath = ""
+	}
+	// joining with the root ensures that the subdirectory cannot point outside the repository
+	subdir = path.Join("/", subdir, chartPath)
+	return gitRef{url: u.String(), ref: ref, subdir: strings.TrimPrefix(subdir, "/")}, nil
+}
+
+// gitCache maintains shallow clones of git repositories on the local filesystem.
+type gitCache struct {
+	command string
+	dir     string
+	timeout time.Duration
+	l       sync.Mutex
+}
+
+func newGitCache(c Config) (*gitCache, error) {
+	timeout := defaultFetchTimeout
+	var dir string
+	if c.Fetch != nil {
+		dir = c.Fetch.CacheDir
+		timeout = c.Fetch.timeout
+	} else {
+		d, err := defaultCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = d
+	}
+	return &gitCache{command: "git", dir: filepath.Join(dir, "git"), timeout: timeout}, nil
+}
+
+// checkoutDir returns the directory in which the repository for the supplied reference is checked out.
+func (g *gitCache) checkoutDir(ref gitRef) string {
+	sum := sha256.Sum256([]byte(ref.url + "@" + ref.ref))
+	name := path.Base(strings.TrimSuffix(ref.url, ".git"))
+	return filepath.Join(g.dir, fmt.Sprintf("%s-%s", name, hex.EncodeToString(sum[:8])))
+}
+
+// chartDir returns the local directory for the chart, performing a shallow clone of the repository if it has not
+// already been cached. Repositories without a ref are always cloned since the default branch can change between runs.
+func (g *gitCache) chartDir(ref gitRef) (string, error) {
+	g.l.Lock()
+	defer g.l.Unlock()
+	dir := g.checkoutDir(ref)
+	chartDir := filepath.Join(dir, filepath.FromSlash(ref.subdir))
+	if ref.ref != "" {
+		if _, err := os.Stat(dir); err == nil {
+			sio.Debugln("use cached git checkout", dir, "for", ref)
+			return g.assertChart(ref, chartDir)
+		}
+	}
+	if err := os.MkdirAll(g.dir, 0755); err != nil {
+		return "", errors.Wrap(err, "create cache dir")
+	}
+	tmp, err := ioutil.TempDir(g.dir, ".clone-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	if err := g.clone(ref, tmp); err != nil {
+		return "", errors.Wrapf(err, "fetch chart %s", ref)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return "", err
+	}
+	sio.Debugln("cached git checkout", ref, "as", dir)
+	return g.assertChart(ref, chartDir)
+}
+
+func (g *gitCache) assertChart(ref gitRef, chartDir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(chartDir, "Chart.yaml")); err != nil {
+		return "", fmt.Errorf("no chart found at %s", ref)
+	}
+	return chartDir, nil
+}
+
+// clone performs a shallow fetch of the reference into the supplied directory. Fetching the ref directly
+// instead of cloning a branch allows tags, branches and commit SHAs to be used in the same way.
+func (g *gitCache) clone(ref gitRef, dir string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	defer cancel()
+	r := ref.ref
+	if r == "" {
+		r = "HEAD"
+	}
+	commands := []struct {
+		name string
+		args []string
+	}{
+		{name: "init", args: []string{"init", "--quiet", dir}},
+		{name: "fetch", args: []string{"-C", dir, "fetch", "--quiet", "--depth", "1", ref.url, r}},
+		{name: "checkout", args: []string{"-C", dir, "checkout", "--quiet", "FETCH_HEAD"}},
+	}
+	for _, c := range commands {
+		sio.Debugln(g.command, strings.Join(c.args, " "))
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, g.command, c.args...)
+		cmd.Stderr = &stderr
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("git %s: %v\n%s", c.name, err, strings.TrimSpace(stderr.String()))
+		}
+	}
+	return nil
+}
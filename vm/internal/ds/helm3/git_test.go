@@ -0,0 +1,134 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package helm3
+
+import (
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGitRef(t *testing.T) {
+	tests := []struct {
+		repo     string
+		path     string
+		version  string
+		expected gitRef
+	}{
+		{
+			repo:     "git+https://github.com/org/repo//charts/foo?ref=v1.2.3",
+			expected: gitRef{url: "https://github.com/org/repo", ref: "v1.2.3", subdir: "charts/foo"},
+		},
+		{
+			repo:     "git+https://github.com/org/repo.git",
+			path:     "charts/foo",
+			version:  "main",
+			expected: gitRef{url: "https://github.com/org/repo.git", ref: "main", subdir: "charts/foo"},
+		},
+		{
+			repo:     "git+ssh://git@github.com/org/repo//charts?ref=abc123",
+			path:     "foo",
+			version:  "1.0.0",
+			expected: gitRef{url: "ssh://git@github.com/org/repo", ref: "abc123", subdir: "charts/foo"},
+		},
+		{
+			repo:     "git+https://github.com/org/repo//../../etc",
+			expected: gitRef{url: "https://github.com/org/repo", subdir: "etc"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.repo, func(t *testing.T) {
+			ref, err := parseGitRef(test.repo, test.path, test.version)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, ref)
+		})
+	}
+	_, err := parseGitRef("git+github.com/org/repo", "", "")
+	require.Error(t, err)
+	assert.Equal(t, `git repo "git+github.com/org/repo" does not have a scheme`, err.Error())
+}
+
+func git(t *testing.T, dir string, args ...string) {
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+func TestRunTemplateFromGit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.SkipNow()
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repoDir := t.TempDir()
+	chartDir := filepath.Join(repoDir, "charts", "foo")
+	require.NoError(t, os.MkdirAll(chartDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("name: foo\nversion: 1.0.0\n"), 0644))
+	git(t, repoDir, "init", "--quiet")
+	git(t, repoDir, "add", ".")
+	git(t, repoDir, "commit", "--quiet", "-m", "initial")
+	git(t, repoDir, "tag", "v1.0.0")
+
+	cacheDir := t.TempDir()
+	t.Setenv("QBEC_CACHE_DIR", cacheDir)
+	h := &helm3Source{name: "helm", configVar: "cfg"}
+	err := h.Init(func(string) (string, error) { return `{ "command": "testdata/fake-helm.sh" }`, nil })
+	require.NoError(t, err)
+
+	render := func() string {
+		out, err := renderTemplate(h, &url.URL{Path: "/"}, TemplateConfig{
+			Name: "my-release",
+			Options: TemplateOptions{
+				Namespace: "ns1",
+				Repo:      "git+file://" + repoDir + "//charts/foo?ref=v1.0.0",
+				Version:   "2.0.0",
+			},
+		})
+		require.NoError(t, err)
+		docs := out.([]interface{})
+		return docs[0].(map[string]interface{})["args"].(string)
+	}
+	args := render()
+	prefix := "template --debug --namespace=ns1 my-release " + filepath.Join(cacheDir, "helm3", "git")
+	assert.True(t, strings.HasPrefix(args, prefix), args)
+	assert.True(t, strings.HasSuffix(args, filepath.Join("charts", "foo")+" --values -"), args)
+	assert.NotContains(t, args, "--version")
+
+	// subsequent renders use the cached checkout
+	require.NoError(t, os.RemoveAll(repoDir))
+	assert.Equal(t, args, render())
+
+	_, err = renderTemplate(h, &url.URL{Path: "/bar"}, TemplateConfig{
+		Options: TemplateOptions{Namespace: "ns1", Repo: "git+file://" + repoDir + "//charts/foo?ref=v1.0.0"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no chart found at file://"+repoDir+"//charts/foo/bar?ref=v1.0.0")
+
+	_, err = renderTemplate(h, &url.URL{Path: "/"}, TemplateConfig{
+		Options: TemplateOptions{Namespace: "ns1", Repo: "git+file://" + repoDir + "//charts/foo?ref=v2.0.0"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "git fetch")
+}
@@ -22,10 +22,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
-	"os"
 	"os/exec"
-	"path/filepath"
 	"reflect"
 	"strings"
 	"time"
@@ -35,7 +34,6 @@ import (
 	"github.com/splunk/qbec/internal/sio"
 	"github.com/splunk/qbec/vm/datasource"
 	"github.com/splunk/qbec/vm/internal/ds"
-	"github.com/splunk/qbec/vm/internal/natives"
 )
 
 // Scheme is the scheme supported by this data source
@@ -55,6 +53,7 @@ func SetDefaultNamespaceVar(s string) {
 type Config struct {
 	Command string        `json:"command"`           // the executable that is run, default is "helm"
 	Timeout string        `json:"timeout,omitempty"` // command timeout as a duration string
+	Fetch   *FetchConfig  `json:"fetch,omitempty"`   // when set, charts are downloaded and cached by qbec
 	timeout time.Duration // internal representation
 }
 
@@ -165,6 +164,19 @@ func (o TemplateOptions) toCommandLine() []string {
 	return o.toInternalCommandLine(false)
 }
 
+// forLocalChart returns a copy of the options with all repository related options removed, such that
+// they can be used to render a chart that has already been downloaded.
+func (o TemplateOptions) forLocalChart() TemplateOptions {
+	o.Repo = ""
+	o.Version = ""
+	o.Username = ""
+	o.Password = ""
+	o.PassCredentials = false
+	o.Verify = false
+	o.Devel = false
+	return o
+}
+
 func (o TemplateOptions) toDisplay() string {
 	parts := o.toInternalCommandLine(true)
 	return strings.Join(parts, " ") // TODO: improve me for quoting
@@ -178,22 +190,7 @@ type TemplateConfig struct {
 	Values  map[string]interface{} `json:"values,omitempty"`
 }
 
-func findExecutable(cmd string) (string, error) {
-	if !filepath.IsAbs(cmd) {
-		p, err := filepath.Abs(cmd)
-		if err == nil {
-			stat, err := os.Stat(cmd)
-			if err == nil {
-				if m := stat.Mode(); !m.IsDir() && m&0111 != 0 {
-					return p, nil
-				}
-			}
-		}
-	}
-	return exec.LookPath(cmd)
-}
-
-func (c *Config) assertValid() error {
+func (c *Config) assertValid(dir string) error {
 	if c.Command == "" {
 		return fmt.Errorf("command not specified")
 	}
@@ -204,11 +201,16 @@ func (c *Config) assertValid() error {
 		}
 		c.timeout = t
 	}
-	exe, err := findExecutable(c.Command)
+	exe, err := ds.FindExecutable(c.Command, dir)
 	if err != nil {
 		return fmt.Errorf("invalid command '%s': %v", c.Command, err)
 	}
 	c.Command = exe
+	if c.Fetch != nil {
+		if err := c.Fetch.init(); err != nil {
+			return err
+		}
+	}
 	// TODO: support version/ SHA  checking etc.
 	return nil
 }
@@ -225,15 +227,20 @@ func (c *Config) initDefaults() {
 type helm3Source struct {
 	name      string
 	configVar string
+	dir       string
 	cp        datasource.ConfigProvider
 	config    Config
+	fetcher   *fetcher
+	git       *gitCache
 }
 
-// New creates a new helm3 data source
-func New(name string, configVar string) ds.DataSourceWithLifecycle {
+// New creates a new helm3 data source. Commands are run from the supplied directory,
+// or the working directory when it is blank.
+func New(name string, configVar string, dir string) ds.DataSourceWithLifecycle {
 	return &helm3Source{
 		name:      name,
 		configVar: configVar,
+		dir:       dir,
 	}
 }
 
@@ -257,72 +264,120 @@ func (d *helm3Source) Init(p datasource.ConfigProvider) (fErr error) {
 		return err
 	}
 	c.initDefaults()
-	err = c.assertValid()
+	err = c.assertValid(d.dir)
 	if err != nil {
 		return err
 	}
 	d.cp = p
 	d.config = c
+	if c.Fetch != nil {
+		d.fetcher = newFetcher(*c.Fetch)
+	}
+	d.git, err = newGitCache(c)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
 // Resolve implements the interface method.
-func (d *helm3Source) Resolve(path string) (_ string, finalErr error) {
+func (d *helm3Source) Resolve(path string) (string, error) {
+	var b strings.Builder
+	if err := d.ResolveTo(path, &b); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// ResolveTo implements the streaming interface method. Objects are written to the writer as they are produced
+// by helm.
+func (d *helm3Source) ResolveTo(path string, w io.Writer) error {
+	u, tc, err := d.templateConfig(path)
+	if err != nil {
+		return err
+	}
+	return d.runTemplate(u, tc, w)
+}
+
+// templateConfig returns the parsed path and the template configuration for the supplied data source path.
+func (d *helm3Source) templateConfig(path string) (*url.URL, TemplateConfig, error) {
+	var tc TemplateConfig
 	u, err := url.Parse(path)
 	if err != nil {
-		return "", errors.Wrapf(err, "parse path %q", path)
+		return nil, tc, errors.Wrapf(err, "parse path %q", path)
 	}
 	configVar := u.Query().Get(configVarParam)
 	if configVar == "" {
-		return "", fmt.Errorf("%s query param not set in data source path %q", configVarParam, path)
+		return nil, tc, fmt.Errorf("%s query param not set in data source path %q", configVarParam, path)
 	}
 	u.Query().Del(configVarParam)
 	str, err := d.cp(configVar)
 	if err != nil {
-		return "", errors.Wrapf(err, "get ext code variable %s", configVar)
+		return nil, tc, errors.Wrapf(err, "get ext code variable %s", configVar)
 	}
-	var tc TemplateConfig
 	err = json.Unmarshal([]byte(str), &tc)
 	if err != nil {
-		return "", errors.Wrapf(err, "json unmarshal of %s value", configVar)
+		return nil, tc, errors.Wrapf(err, "json unmarshal of %s value", configVar)
 	}
 	if tc.Options.Namespace == "" {
 		if defaultNamespaceVar == "" {
-			return "", fmt.Errorf("namespace option not specified and no default value exists")
+			return nil, tc, fmt.Errorf("namespace option not specified and no default value exists")
 		}
 		ns, err := d.cp(defaultNamespaceVar)
 		if err != nil {
-			return "", errors.Wrapf(err, "get default namespace from %s", defaultNamespaceVar)
+			return nil, tc, errors.Wrapf(err, "get default namespace from %s", defaultNamespaceVar)
 		}
 		tc.Options.Namespace = ns
 	}
-	out, err := d.runTemplate(u, tc)
-	if err != nil {
-		return "", err
-	}
-	b, err := json.Marshal(out)
-	if err != nil {
-		return "", errors.Wrap(err, "marshal output")
-	}
-	return string(b), nil
+	return u, tc, nil
 }
 
-func (d *helm3Source) runTemplate(u *url.URL, tc TemplateConfig) (interface{}, error) {
+func (d *helm3Source) runTemplate(u *url.URL, tc TemplateConfig, w io.Writer) error {
 	path := strings.TrimPrefix(u.Path, "/")
 	chart := path
-	if tc.Options.Repo == "" { // then assume path is a URL with https scheme
+	isOCI := strings.HasPrefix(tc.Options.Repo, ociScheme+"://")
+	isGit := isGitRepo(tc.Options.Repo)
+	switch {
+	case isGit: // helm cannot render charts from git, always check them out locally
+		ref, err := parseGitRef(tc.Options.Repo, path, tc.Options.Version)
+		if err != nil {
+			return err
+		}
+		dir, err := d.git.chartDir(ref)
+		if err != nil {
+			return err
+		}
+		chart = dir
+		tc.Options = tc.Options.forLocalChart()
+	case isOCI: // helm only accepts OCI charts as a full reference
+		chart = strings.TrimSuffix(tc.Options.Repo, "/") + "/" + path
+	case tc.Options.Repo == "": // then assume path is a URL with https scheme
 		parts := strings.SplitN(path, "/", 2) // first component of part is actually the host
 		if len(parts) == 1 {
-			return nil, fmt.Errorf("unable to extract host and path from %s", path)
+			return fmt.Errorf("unable to extract host and path from %s", path)
 		}
 		u.Host = parts[0]
 		u.Path = "/" + parts[1]
 		u.Scheme = "https"
 		chart = u.String()
 	}
+	if d.fetcher != nil && !isGit {
+		ref := chartRef{repo: tc.Options.Repo, name: path, version: tc.Options.Version}
+		if tc.Options.Repo == "" {
+			ref = chartRef{url: chart}
+		}
+		file, err := d.fetcher.fetch(ref)
+		if err != nil {
+			return err
+		}
+		chart = file
+		tc.Options = tc.Options.forLocalChart()
+	} else if isOCI {
+		tc.Options.Repo = ""
+	}
 	b, err := json.Marshal(tc.Values)
 	if err != nil {
-		return "", errors.Wrap(err, "marshal values")
+		return errors.Wrap(err, "marshal values")
 	}
 	args := append([]string{"template", "--debug"}, tc.Options.toCommandLine()...)
 	if tc.Name != "" { // TODO: figure out if omitting name is the correct strategy
@@ -337,22 +392,18 @@ func (d *helm3Source) runTemplate(u *url.URL, tc TemplateConfig) (interface{}, e
 	displayCommand := fmt.Sprintf("%s template %s %s %s", d.config.Command, tc.Options.toDisplay(), tc.Name, chart)
 	sio.Debugln(displayCommand)
 
-	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 	cmd := exec.CommandContext(ctx, d.config.Command, args...)
+	cmd.Dir = d.dir
 	cmd.Stdin = bytes.NewBuffer(b)
-	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
-	err = cmd.Run()
-	if err != nil {
-		sio.Warnf("%s\n%s\n", "debug output from helm", stdout.String())
-		return "", fmt.Errorf("%s\n%s", err.Error(), stderr.String())
-	}
-	docs, err := natives.ParseYAMLDocuments(bytes.NewReader(stdout.Bytes()))
-	if err != nil {
-		return "", err
+	err = ds.StreamYAMLOutput(cmd, cancel, w)
+	var ce *ds.CommandError
+	if errors.As(err, &ce) {
+		sio.Warnf("%s\n%s\n", "debug output from helm", ce.Output)
+		return fmt.Errorf("%s\n%s", err.Error(), stderr.String())
 	}
-	return docs, nil
+	return err
 }
 
 // Close implements the interface method.
@@ -17,6 +17,7 @@
 package helm3
 
 import (
+	"bytes"
 	"encoding/json"
 	"io/ioutil"
 	"net/url"
@@ -161,7 +162,7 @@ func TestRunTemplate(t *testing.T) {
 		Host:   "",
 		Path:   "apache",
 	}
-	templated, err := helm3Src.runTemplate(mockURL, mockTemplateConfig)
+	templated, err := renderTemplate(helm3Src, mockURL, mockTemplateConfig)
 	b, err := json.MarshalIndent(templated, "", "  ")
 	require.NoError(t, err)
 	filePath := filepath.Join("testdata", "apache.json")
@@ -182,12 +183,6 @@ func TestInitDefaults(t *testing.T) {
 	require.Equal(t, cfg.timeout, time.Minute)
 }
 
-func TestFindExecutable(t *testing.T) {
-	cmd, err := findExecutable("helm")
-	require.NoError(t, err)
-	require.FileExists(t, cmd)
-}
-
 func TestClose(t *testing.T) {
 	helm3Src := &helm3Source{configVar: "config-var-name"}
 	err := helm3Src.Close()
@@ -208,3 +203,16 @@ func TestName(t *testing.T) {
 	name := helm3Src.Name()
 	require.Equal(t, name, "baz")
 }
+
+// renderTemplate runs the template command for the supplied source and returns the objects written by it.
+func renderTemplate(h *helm3Source, u *url.URL, tc TemplateConfig) (interface{}, error) {
+	var b bytes.Buffer
+	if err := h.runTemplate(u, tc, &b); err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(b.Bytes(), &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
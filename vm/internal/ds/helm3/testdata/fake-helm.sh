@@ -0,0 +1,4 @@
+#!/bin/sh
+
+# emulates helm template by emitting a document containing the arguments passed
+echo "args: '$*'"
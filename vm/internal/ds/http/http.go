@@ -0,0 +1,325 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package http provides a data source implementation that fetches import paths from an HTTP server, with support
+// for request headers from environment variables, retries, and caching of responses on the local filesystem.
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/sio"
+	"github.com/splunk/qbec/vm/datasource"
+	"github.com/splunk/qbec/vm/internal/ds"
+)
+
+// Schemes supported by this data source
+const (
+	Scheme       = "http"
+	SecureScheme = "https"
+)
+
+// maxResponseBytes is the maximum size of a response body.
+const maxResponseBytes = 50 * 1024 * 1024
+
+// Config is the configuration of the data source.
+type Config struct {
+	URL            string            `json:"url,omitempty"`            // base URL to which import paths are appended, defaults to <scheme>://<name>
+	Headers        map[string]string `json:"headers,omitempty"`        // headers added to every request
+	HeadersFromEnv map[string]string `json:"headersFromEnv,omitempty"` // headers whose values are read from the named environment variables
+	Timeout        string            `json:"timeout,omitempty"`        // timeout for a single request as a duration string
+	Retries        *int              `json:"retries,omitempty"`        // number of times a failed request is retried, default 2
+	RetryBackoff   string            `json:"retryBackoff,omitempty"`   // wait time before the first retry, doubled for every subsequent retry
+	CacheTTL       string            `json:"cacheTTL,omitempty"`       // time for which responses are cached on disk, no caching when not set
+	CacheDir       string            `json:"cacheDir,omitempty"`       // cache directory, defaults to the qbec cache dir
+
+	timeout      time.Duration // internal representation
+	retries      int           // internal representation
+	retryBackoff time.Duration // internal representation
+	cacheTTL     time.Duration // internal representation
+}
+
+// defaultCacheDir returns the directory under which responses are cached when one is not explicitly configured.
+func defaultCacheDir() (string, error) {
+	if dir := os.Getenv("QBEC_CACHE_DIR"); dir != "" {
+		return filepath.Join(dir, "http"), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.Wrap(err, "get user cache dir")
+	}
+	return filepath.Join(dir, "qbec", "http"), nil
+}
+
+func parseDuration(name, value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s': %v", name, value, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s '%s': must be positive", name, value)
+	}
+	return d, nil
+}
+
+func (c *Config) initDefaults(scheme, name string) {
+	if c.URL == "" {
+		c.URL = fmt.Sprintf("%s://%s", scheme, name)
+	}
+	c.retries = 2
+	if c.Retries != nil {
+		c.retries = *c.Retries
+	}
+}
+
+func (c *Config) assertValid(scheme string) error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url '%s': %v", c.URL, err)
+	}
+	if u.Scheme != scheme {
+		return fmt.Errorf("invalid url '%s': scheme must be %s", c.URL, scheme)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("invalid url '%s': must not have a query string or fragment", c.URL)
+	}
+	c.URL = strings.TrimSuffix(c.URL, "/")
+	if c.retries < 0 {
+		return fmt.Errorf("invalid retries %d, must not be negative", c.retries)
+	}
+	if c.timeout, err = parseDuration("timeout", c.Timeout, 30*time.Second); err != nil {
+		return err
+	}
+	if c.retryBackoff, err = parseDuration("retryBackoff", c.RetryBackoff, time.Second); err != nil {
+		return err
+	}
+	if c.cacheTTL, err = parseDuration("cacheTTL", c.CacheTTL, 0); err != nil {
+		return err
+	}
+	if c.cacheTTL > 0 {
+		if c.CacheDir == "" {
+			dir, err := defaultCacheDir()
+			if err != nil {
+				return err
+			}
+			c.CacheDir = dir
+		}
+		abs, err := filepath.Abs(c.CacheDir)
+		if err != nil {
+			return err
+		}
+		c.CacheDir = abs
+	}
+	return nil
+}
+
+type httpSource struct {
+	scheme    string
+	name      string
+	configVar string
+	config    Config
+	headers   http.Header
+	client    *http.Client
+}
+
+// New creates a new HTTP data source for the supplied scheme, which must be Scheme or SecureScheme.
+func New(scheme string, name string, configVar string) ds.DataSourceWithLifecycle {
+	return &httpSource{
+		scheme:    scheme,
+		name:      name,
+		configVar: configVar,
+	}
+}
+
+// Name implements the interface method
+func (d *httpSource) Name() string {
+	return d.name
+}
+
+// Init implements the interface method. It reads the configuration and resolves header values from the
+// environment.
+func (d *httpSource) Init(p datasource.ConfigProvider) (fErr error) {
+	defer func() {
+		fErr = errors.Wrapf(fErr, "init data source %s", d.name) // nil wraps as nil
+	}()
+	cfgJSON, err := p(d.configVar)
+	if err != nil {
+		return err
+	}
+	var c Config
+	err = json.Unmarshal([]byte(cfgJSON), &c)
+	if err != nil {
+		return err
+	}
+	c.initDefaults(d.scheme, d.name)
+	err = c.assertValid(d.scheme)
+	if err != nil {
+		return err
+	}
+	headers := http.Header{}
+	for k, v := range c.Headers {
+		headers.Set(k, v)
+	}
+	for k, env := range c.HeadersFromEnv {
+		v := os.Getenv(env)
+		if v == "" {
+			return fmt.Errorf("environment variable %s for header %s not set", env, k)
+		}
+		headers.Set(k, v)
+	}
+	d.config = c
+	d.headers = headers
+	d.client = &http.Client{Timeout: c.timeout}
+	return nil
+}
+
+// cacheFile returns the file under which the response for the supplied URL is cached. Header values are part of
+// the key, such that responses are never shared between different credentials.
+func (d *httpSource) cacheFile(u string) string {
+	var keys []string
+	for k := range d.headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	fmt.Fprintln(h, u)
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s: %s\n", k, strings.Join(d.headers[k], ","))
+	}
+	return filepath.Join(d.config.CacheDir, fmt.Sprintf("%s-%s", d.name, hex.EncodeToString(h.Sum(nil)[:8])))
+}
+
+// writeCache writes the supplied content to the cache file atomically. Responses may be fetched using credentials,
+// so only the current user is allowed to read them.
+func (d *httpSource) writeCache(file string, content []byte) error {
+	if err := os.MkdirAll(d.config.CacheDir, 0700); err != nil {
+		return errors.Wrap(err, "create cache dir")
+	}
+	tmp, err := ioutil.TempFile(d.config.CacheDir, ".download-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// retryableError is an error for a request that may succeed when retried.
+type retryableError struct {
+	error
+}
+
+// get performs a single GET request for the supplied URL and returns the response body.
+func (d *httpSource) get(u string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.config.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range d.headers {
+		req.Header[k] = v
+	}
+	sio.Debugf("http: GET %s\n", u)
+	res, err := d.client.Do(req)
+	if err != nil {
+		return nil, retryableError{err}
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(res.Body, maxResponseBytes+1))
+	if err != nil {
+		return nil, retryableError{errors.Wrap(err, "read response")}
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		err := fmt.Errorf("status %d", res.StatusCode)
+		if res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500 {
+			return nil, retryableError{err}
+		}
+		return nil, err
+	}
+	if len(b) > maxResponseBytes {
+		return nil, fmt.Errorf("response larger than %d bytes", maxResponseBytes)
+	}
+	return b, nil
+}
+
+// Resolve implements the interface method. The path, including any query string, is appended to the base URL
+// and the body of the response is returned. Network errors, 429 and 5xx responses are retried with exponential
+// backoff.
+func (d *httpSource) Resolve(path string) (string, error) {
+	u := d.config.URL + path
+	var cacheFile string
+	if d.config.cacheTTL > 0 {
+		cacheFile = d.cacheFile(u)
+		if st, err := os.Stat(cacheFile); err == nil && time.Since(st.ModTime()) < d.config.cacheTTL {
+			sio.Debugf("http: use cached response for %s from %s\n", u, cacheFile)
+			b, err := ioutil.ReadFile(cacheFile)
+			if err != nil {
+				return "", err
+			}
+			return string(b), nil
+		}
+	}
+	backoff := d.config.retryBackoff
+	var b []byte
+	var err error
+	for attempt := 0; ; attempt++ {
+		b, err = d.get(u)
+		if _, ok := err.(retryableError); !ok || attempt >= d.config.retries {
+			break
+		}
+		sio.Debugf("http: GET %s failed, retry in %v: %v\n", u, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "GET %s", u)
+	}
+	if cacheFile != "" {
+		if err := d.writeCache(cacheFile, b); err != nil {
+			sio.Warnf("unable to cache response for %s: %v\n", u, err)
+		}
+	}
+	return string(b), nil
+}
+
+// Close implements the interface method.
+func (d *httpSource) Close() error {
+	return nil
+}
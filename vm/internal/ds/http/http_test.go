@@ -0,0 +1,235 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package http
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/splunk/qbec/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testServer returns a server that fails the first failures requests to /flaky with a 503 status and reports the
+// number of requests it has received.
+func testServer(t *testing.T, failures int32) (*httptest.Server, *int32) {
+	var count int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&count, 1)
+		if r.Header.Get("Authorization") != "Bearer s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/services":
+			fmt.Fprintf(w, `{"env":%q,"team":%q,"count":%d}`, r.URL.Query().Get("env"), r.Header.Get("X-Team"), n)
+		case "/api/flaky":
+			if n <= failures {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, "ok")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s, &count
+}
+
+func initSource(t *testing.T, config string) *httpSource {
+	d := New(Scheme, "catalog", "cfg").(*httpSource)
+	err := d.Init(testutil.VarProvider(map[string]string{"cfg": config}))
+	require.NoError(t, err)
+	return d
+}
+
+func TestHTTPBasic(t *testing.T) {
+	t.Setenv("CATALOG_TOKEN", "Bearer s3cr3t")
+	s, count := testServer(t, 0)
+	d := initSource(t, fmt.Sprintf(`{
+		"url": "%s/api/",
+		"headers": { "X-Team": "payments" },
+		"headersFromEnv": { "Authorization": "CATALOG_TOKEN" }
+	}`, s.URL))
+	defer d.Close()
+	a := assert.New(t)
+	a.Equal("catalog", d.Name())
+	out, err := d.Resolve("/services?env=dev")
+	require.NoError(t, err)
+	a.Equal(`{"env":"dev","team":"payments","count":1}`, out)
+	out, err = d.Resolve("/services?env=dev")
+	require.NoError(t, err)
+	a.Equal(`{"env":"dev","team":"payments","count":2}`, out)
+
+	_, err = d.Resolve("/foo")
+	require.Error(t, err)
+	a.Equal(fmt.Sprintf("GET %s/api/foo: status 404", s.URL), err.Error())
+	a.EqualValues(3, atomic.LoadInt32(count))
+}
+
+func TestHTTPRetries(t *testing.T) {
+	t.Setenv("CATALOG_TOKEN", "Bearer s3cr3t")
+	s, count := testServer(t, 2)
+	d := initSource(t, fmt.Sprintf(`{
+		"url": "%s/api",
+		"headersFromEnv": { "Authorization": "CATALOG_TOKEN" },
+		"retryBackoff": "10ms"
+	}`, s.URL))
+	out, err := d.Resolve("/flaky")
+	require.NoError(t, err)
+	assert.Equal(t, "ok", out)
+	assert.EqualValues(t, 3, atomic.LoadInt32(count))
+
+	s, count = testServer(t, 2)
+	d = initSource(t, fmt.Sprintf(`{
+		"url": "%s/api",
+		"headersFromEnv": { "Authorization": "CATALOG_TOKEN" },
+		"retries": 1,
+		"retryBackoff": "10ms"
+	}`, s.URL))
+	_, err = d.Resolve("/flaky")
+	require.Error(t, err)
+	assert.Equal(t, fmt.Sprintf("GET %s/api/flaky: status 503", s.URL), err.Error())
+	assert.EqualValues(t, 2, atomic.LoadInt32(count))
+}
+
+func TestHTTPCache(t *testing.T) {
+	t.Setenv("CATALOG_TOKEN", "Bearer s3cr3t")
+	t.Setenv("QBEC_CACHE_DIR", t.TempDir())
+	s, count := testServer(t, 0)
+	config := fmt.Sprintf(`{
+		"url": "%s/api",
+		"headersFromEnv": { "Authorization": "CATALOG_TOKEN" },
+		"cacheTTL": "1h"
+	}`, s.URL)
+	d := initSource(t, config)
+	a := assert.New(t)
+	for i := 0; i < 2; i++ {
+		out, err := d.Resolve("/services?env=dev")
+		require.NoError(t, err)
+		a.Equal(`{"env":"dev","team":"","count":1}`, out)
+	}
+	out, err := d.Resolve("/services?env=prod")
+	require.NoError(t, err)
+	a.Equal(`{"env":"prod","team":"","count":2}`, out)
+
+	// a new data source uses the cache from disk
+	d = initSource(t, config)
+	out, err = d.Resolve("/services?env=dev")
+	require.NoError(t, err)
+	a.Equal(`{"env":"dev","team":"","count":1}`, out)
+
+	// errors are not cached
+	_, err = d.Resolve("/foo")
+	require.Error(t, err)
+	_, err = d.Resolve("/foo")
+	require.Error(t, err)
+	a.EqualValues(4, atomic.LoadInt32(count))
+
+	// different credentials do not share cached responses
+	t.Setenv("CATALOG_TOKEN", "Bearer other")
+	d = initSource(t, config)
+	_, err = d.Resolve("/services?env=dev")
+	require.Error(t, err)
+	a.Contains(err.Error(), "status 401")
+
+	files, err := ioutil.ReadDir(d.config.CacheDir)
+	require.NoError(t, err)
+	a.Equal(2, len(files))
+	for _, f := range files {
+		a.Equal(os.FileMode(0600), f.Mode().Perm())
+	}
+	st, err := os.Stat(d.config.CacheDir)
+	require.NoError(t, err)
+	a.Equal(os.FileMode(0700), st.Mode().Perm())
+}
+
+func TestHTTPDefaultURL(t *testing.T) {
+	d := New(SecureScheme, "catalog.example.com", "cfg").(*httpSource)
+	err := d.Init(testutil.VarProvider(map[string]string{"cfg": "{}"}))
+	require.NoError(t, err)
+	assert.Equal(t, "https://catalog.example.com", d.config.URL)
+}
+
+func TestHTTPInitNegative(t *testing.T) {
+	t.Setenv("CATALOG_TOKEN", "")
+	tests := []struct {
+		name   string
+		config string
+		msg    string
+	}{
+		{
+			name:   "no-var",
+			config: "",
+			msg:    "init data source catalog: no such var cfg",
+		},
+		{
+			name:   "bad-json",
+			config: "{",
+			msg:    "init data source catalog: unexpected end of JSON input",
+		},
+		{
+			name:   "bad-scheme",
+			config: `{ "url": "https://foo" }`,
+			msg:    "init data source catalog: invalid url 'https://foo': scheme must be http",
+		},
+		{
+			name:   "query",
+			config: `{ "url": "http://foo?bar=baz" }`,
+			msg:    "init data source catalog: invalid url 'http://foo?bar=baz': must not have a query string or fragment",
+		},
+		{
+			name:   "bad-timeout",
+			config: `{ "timeout": "abc" }`,
+			msg:    "init data source catalog: invalid timeout 'abc'",
+		},
+		{
+			name:   "negative-ttl",
+			config: `{ "cacheTTL": "-1h" }`,
+			msg:    "init data source catalog: invalid cacheTTL '-1h': must be positive",
+		},
+		{
+			name:   "bad-retries",
+			config: `{ "retries": -1 }`,
+			msg:    "init data source catalog: invalid retries -1, must not be negative",
+		},
+		{
+			name:   "no-env",
+			config: `{ "headersFromEnv": { "Authorization": "CATALOG_TOKEN" } }`,
+			msg:    "init data source catalog: environment variable CATALOG_TOKEN for header Authorization not set",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vars := map[string]string{}
+			if test.config != "" {
+				vars["cfg"] = test.config
+			}
+			d := New(Scheme, "catalog", "cfg")
+			err := d.Init(testutil.VarProvider(vars))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.msg)
+		})
+	}
+}
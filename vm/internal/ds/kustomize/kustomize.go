@@ -0,0 +1,223 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package kustomize provides a data source implementation that can extract k8s objects out of
+// kustomize bases and overlays.
+package kustomize
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/sio"
+	"github.com/splunk/qbec/vm/datasource"
+	"github.com/splunk/qbec/vm/internal/ds"
+)
+
+// Scheme is the scheme supported by this data source
+const (
+	Scheme         = "kustomize"
+	configVarParam = "config-from"
+)
+
+// Config is the configuration of the data source.
+type Config struct {
+	Command string        `json:"command"`           // the executable that is run, default is "kustomize"
+	Timeout string        `json:"timeout,omitempty"` // command timeout as a duration string
+	timeout time.Duration // internal representation
+}
+
+// BuildOptions are a subset of command line arguments that can be passed to `kustomize build`.
+type BuildOptions struct {
+	EnableHelm          bool   `json:"enableHelm,omitempty"`
+	EnableAlphaPlugins  bool   `json:"enableAlphaPlugins,omitempty"`
+	LoadRestrictor      string `json:"loadRestrictor,omitempty"`
+	ReorderOutput       string `json:"reorder,omitempty"`
+	AddManagedByLabel   bool   `json:"enableManagedbyLabel,omitempty"`
+	HelmCommand         string `json:"helmCommand,omitempty"`
+	NetworkForFunctions bool   `json:"network,omitempty"`
+}
+
+func (o BuildOptions) toCommandLine() []string {
+	var ret []string
+	boolFlag := func(b bool, name string) {
+		if b {
+			ret = append(ret, "--"+name)
+		}
+	}
+	stringFlag := func(s string, name string) {
+		if s != "" {
+			ret = append(ret, fmt.Sprintf("--%s=%s", name, s))
+		}
+	}
+	boolFlag(o.EnableHelm, "enable-helm")
+	boolFlag(o.EnableAlphaPlugins, "enable-alpha-plugins")
+	stringFlag(o.LoadRestrictor, "load-restrictor")
+	stringFlag(o.ReorderOutput, "reorder")
+	boolFlag(o.AddManagedByLabel, "enable-managedby-label")
+	stringFlag(o.HelmCommand, "helm-command")
+	boolFlag(o.NetworkForFunctions, "network")
+	return ret
+}
+
+// BuildConfig is the per-import configuration for the build command.
+type BuildConfig struct {
+	Options BuildOptions `json:"options,omitempty"`
+}
+
+func (c *Config) assertValid(dir string) error {
+	if c.Command == "" {
+		return fmt.Errorf("command not specified")
+	}
+	if c.Timeout != "" {
+		t, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout '%s': %v", c.Timeout, err)
+		}
+		c.timeout = t
+	}
+	exe, err := ds.FindExecutable(c.Command, dir)
+	if err != nil {
+		return fmt.Errorf("invalid command '%s': %v", c.Command, err)
+	}
+	c.Command = exe
+	return nil
+}
+
+func (c *Config) initDefaults() {
+	if c.Command == "" {
+		c.Command = "kustomize"
+	}
+	if c.timeout == 0 {
+		c.timeout = time.Minute
+	}
+}
+
+type kustomizeSource struct {
+	name      string
+	configVar string
+	dir       string
+	cp        datasource.ConfigProvider
+	config    Config
+}
+
+// New creates a new kustomize data source. Commands are run from the supplied directory,
+// or the working directory when it is blank.
+func New(name string, configVar string, dir string) ds.DataSourceWithLifecycle {
+	return &kustomizeSource{
+		name:      name,
+		configVar: configVar,
+		dir:       dir,
+	}
+}
+
+// Name implements the interface method
+func (d *kustomizeSource) Name() string {
+	return d.name
+}
+
+// Init implements the interface method.
+func (d *kustomizeSource) Init(p datasource.ConfigProvider) (fErr error) {
+	defer func() {
+		fErr = errors.Wrapf(fErr, "init data source %s", d.name) // nil wraps as nil
+	}()
+	cfgJSON, err := p(d.configVar)
+	if err != nil {
+		return err
+	}
+	var c Config
+	err = json.Unmarshal([]byte(cfgJSON), &c)
+	if err != nil {
+		return err
+	}
+	c.initDefaults()
+	err = c.assertValid(d.dir)
+	if err != nil {
+		return err
+	}
+	d.cp = p
+	d.config = c
+	return nil
+}
+
+// Resolve implements the interface method. The path is the directory containing the kustomization
+// relative to the qbec root. An optional config-from query parameter names a variable that holds
+// build options for the directory.
+func (d *kustomizeSource) Resolve(path string) (string, error) {
+	var b strings.Builder
+	if err := d.ResolveTo(path, &b); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// ResolveTo implements the streaming interface method. Objects are written to the writer as they are produced
+// by kustomize.
+func (d *kustomizeSource) ResolveTo(path string, w io.Writer) error {
+	u, err := url.Parse(path)
+	if err != nil {
+		return errors.Wrapf(err, "parse path %q", path)
+	}
+	var bc BuildConfig
+	if configVar := u.Query().Get(configVarParam); configVar != "" {
+		str, err := d.cp(configVar)
+		if err != nil {
+			return errors.Wrapf(err, "get ext code variable %s", configVar)
+		}
+		if err := json.Unmarshal([]byte(str), &bc); err != nil {
+			return errors.Wrapf(err, "json unmarshal of %s value", configVar)
+		}
+	}
+	dir := strings.TrimPrefix(u.Path, "/")
+	if dir == "" {
+		return fmt.Errorf("no kustomization directory in data source path %q", path)
+	}
+	return d.runBuild(dir, bc, w)
+}
+
+func (d *kustomizeSource) runBuild(dir string, bc BuildConfig, w io.Writer) error {
+	args := append([]string{"build"}, bc.Options.toCommandLine()...)
+	args = append(args, dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.config.timeout)
+	defer cancel()
+
+	sio.Debugln(fmt.Sprintf("%s %s", d.config.Command, strings.Join(args, " ")))
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, d.config.Command, args...)
+	cmd.Dir = d.dir
+	cmd.Stderr = &stderr
+	err := ds.StreamYAMLOutput(cmd, cancel, w)
+	var ce *ds.CommandError
+	if errors.As(err, &ce) {
+		return fmt.Errorf("kustomize build %s: %s\n%s", dir, err.Error(), stderr.String())
+	}
+	return errors.Wrapf(err, "kustomize build %s", dir) // nil ok
+}
+
+// Close implements the interface method.
+func (d *kustomizeSource) Close() error {
+	return nil
+}
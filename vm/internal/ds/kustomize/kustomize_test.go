@@ -0,0 +1,136 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"encoding/json"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/splunk/qbec/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildOptions(t *testing.T) {
+	a := assert.New(t)
+	a.Equal(0, len(BuildOptions{}.toCommandLine()))
+	ret := BuildOptions{
+		EnableHelm:          true,
+		EnableAlphaPlugins:  true,
+		LoadRestrictor:      "LoadRestrictionsNone",
+		ReorderOutput:       "none",
+		AddManagedByLabel:   true,
+		HelmCommand:         "helm3",
+		NetworkForFunctions: true,
+	}.toCommandLine()
+	a.EqualValues([]string{
+		"--enable-helm",
+		"--enable-alpha-plugins",
+		"--load-restrictor=LoadRestrictionsNone",
+		"--reorder=none",
+		"--enable-managedby-label",
+		"--helm-command=helm3",
+		"--network",
+	}, ret)
+}
+
+func TestInitDefaults(t *testing.T) {
+	cfg := Config{}
+	cfg.initDefaults()
+	require.Equal(t, "kustomize", cfg.Command)
+	require.Equal(t, time.Minute, cfg.timeout)
+}
+
+func TestResolve(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.SkipNow()
+	}
+	d := New("kust", "cfg", "")
+	err := d.Init(testutil.VarProvider(map[string]string{
+		"cfg":  `{ "command": "testdata/fake-kustomize.sh", "timeout": "10s" }`,
+		"opts": `{ "options": { "enableHelm": true } }`,
+	}))
+	require.NoError(t, err)
+	defer d.Close()
+	assert.Equal(t, "kust", d.Name())
+
+	out, err := d.Resolve("/testdata/base")
+	require.NoError(t, err)
+	var docs []map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(out), &docs))
+	require.Equal(t, 2, len(docs))
+	assert.Equal(t, "ConfigMap", docs[0]["kind"])
+	assert.Equal(t, "testdata/base", docs[1]["args"])
+
+	out, err = d.Resolve("/testdata/base?config-from=opts")
+	require.NoError(t, err)
+	docs = nil
+	require.NoError(t, json.Unmarshal([]byte(out), &docs))
+	require.Equal(t, 2, len(docs))
+	assert.Equal(t, "--enable-helm testdata/base", docs[1]["args"])
+}
+
+func TestResolveNegative(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.SkipNow()
+	}
+	d := New("kust", "cfg", "")
+	err := d.Init(testutil.VarProvider(map[string]string{
+		"cfg":  `{ "command": "testdata/fake-kustomize.sh" }`,
+		"opts": `{ "options": "foo" }`,
+	}))
+	require.NoError(t, err)
+	tests := []struct {
+		name string
+		path string
+		msg  string
+	}{
+		{"no-dir", "/", "no kustomization directory in data source path"},
+		{"bad-dir", "/testdata/nonexistent", "no kustomization in testdata/nonexistent"},
+		{"bad-var", "/testdata/base?config-from=foo", "get ext code variable foo"},
+		{"bad-opts", "/testdata/base?config-from=opts", "json unmarshal of opts value"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := d.Resolve(test.path)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.msg)
+		})
+	}
+}
+
+func TestInitNegative(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  string
+		msg  string
+	}{
+		{"bad-json", `{`, "init data source kust"},
+		{"bad-timeout", `{ "command": "testdata/fake-kustomize.sh", "timeout": "xx" }`, "invalid timeout 'xx'"},
+		{"bad-command", `{ "command": "testdata/no-such-command" }`, "invalid command 'testdata/no-such-command'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := New("kust", "cfg", "")
+			err := d.Init(testutil.VarProvider(map[string]string{"cfg": test.cfg}))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.msg)
+		})
+	}
+}
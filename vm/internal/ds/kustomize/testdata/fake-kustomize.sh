@@ -0,0 +1,22 @@
+#!/bin/sh
+
+# emulates kustomize build by emitting the kustomization file in the directory and the arguments passed
+if [ "$1" != "build" ]
+then
+    echo "unexpected command $1" >&2
+    exit 1
+fi
+shift
+dir=""
+for arg in "$@"
+do
+    dir="${arg}"
+done
+if [ ! -f "${dir}/kustomization.yaml" ]
+then
+    echo "no kustomization in ${dir}" >&2
+    exit 1
+fi
+cat "${dir}/kustomization.yaml"
+echo "---"
+echo "args: '$*'"
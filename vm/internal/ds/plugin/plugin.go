@@ -0,0 +1,297 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package plugin provides a data source implementation that delegates to an external program speaking the
+// protocol declared in the public datasource/plugin package. Unlike the exec data source, the program is started
+// once and serves all imports for the data source, such that it can keep state between calls.
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/sio"
+	"github.com/splunk/qbec/vm/datasource"
+	"github.com/splunk/qbec/vm/datasource/plugin"
+	"github.com/splunk/qbec/vm/internal/ds"
+)
+
+// Scheme is the scheme supported by this data source
+const Scheme = "plugin"
+
+// Config is the configuration of the data source.
+type Config struct {
+	Command    string            `json:"command"`              // the plugin executable
+	Args       []string          `json:"args,omitempty"`       // arguments to be passed to the command
+	Env        map[string]string `json:"env,omitempty"`        // environment for the command
+	InheritEnv bool              `json:"inheritEnv,omitempty"` // inherit env from the parent(qbec) process
+	Timeout    string            `json:"timeout,omitempty"`    // timeout for every call made to the plugin as a duration string
+	Config     json.RawMessage   `json:"config,omitempty"`     // configuration passed to the plugin on init
+
+	timeout time.Duration // internal representation
+}
+
+func (c *Config) assertValid(dir string) error {
+	if c.Command == "" {
+		return fmt.Errorf("command not specified")
+	}
+	if c.Timeout != "" {
+		t, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout '%s': %v", c.Timeout, err)
+		}
+		c.timeout = t
+	}
+	exe, err := ds.FindExecutable(c.Command, dir)
+	if err != nil {
+		return fmt.Errorf("invalid command '%s': %v", c.Command, err)
+	}
+	c.Command = exe
+	return nil
+}
+
+func (c *Config) initDefaults() {
+	if c.timeout == 0 {
+		c.timeout = time.Minute
+	}
+}
+
+type pluginSource struct {
+	name      string
+	configVar string
+	dir       string
+	timeout   time.Duration
+
+	l         sync.Mutex
+	cmd       *exec.Cmd
+	enc       *json.Encoder
+	stdin     io.Closer
+	responses chan plugin.Response // responses read from the plugin, closed when its output ends
+	readErr   error                // the error that ended the output, set before responses is closed
+	exited    chan struct{}        // closed when the plugin process has exited
+	nextID    int
+	err       error // set when the plugin can no longer be used
+}
+
+// New creates a new plugin data source. The plugin is started from the supplied directory, or the working
+// directory when it is blank.
+func New(name string, configVar string, dir string) ds.DataSourceWithLifecycle {
+	return &pluginSource{
+		name:      name,
+		configVar: configVar,
+		dir:       dir,
+	}
+}
+
+// Name implements the interface method
+func (d *pluginSource) Name() string {
+	return d.name
+}
+
+// Init implements the interface method. It starts the plugin and sends it an init request. Errors are not
+// qualified with the data source, since the caller does that.
+func (d *pluginSource) Init(p datasource.ConfigProvider) error {
+	cfgJSON, err := p(d.configVar)
+	if err != nil {
+		return err
+	}
+	var c Config
+	err = json.Unmarshal([]byte(cfgJSON), &c)
+	if err != nil {
+		return err
+	}
+	err = c.assertValid(d.dir)
+	if err != nil {
+		return err
+	}
+	c.initDefaults()
+	if err := d.start(&c); err != nil {
+		return err
+	}
+	var res plugin.InitResult
+	err = d.call(plugin.MethodInit, plugin.InitParams{
+		ProtocolVersion: plugin.ProtocolVersion,
+		Name:            d.name,
+		Config:          c.Config,
+	}, &res)
+	if err != nil {
+		return err
+	}
+	if res.ProtocolVersion != plugin.ProtocolVersion {
+		return d.fail(fmt.Errorf("plugin protocol version %d, want %d", res.ProtocolVersion, plugin.ProtocolVersion))
+	}
+	return nil
+}
+
+// start starts the plugin process and a goroutine that reads its responses.
+func (d *pluginSource) start(c *Config) error {
+	cmd := exec.Command(c.Command, c.Args...)
+	cmd.Dir = d.dir
+	var env []string
+	if c.InheritEnv {
+		env = os.Environ()
+	}
+	for k, v := range c.Env {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	env = append(env, fmt.Sprintf("__DS_NAME__=%s", d.name))
+	cmd.Env = env
+	stderr := sio.NewLineWriter(d.name)
+	cmd.Stderr = stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return errors.Wrap(err, "start plugin")
+	}
+	d.cmd = cmd
+	d.timeout = c.timeout
+	d.enc = json.NewEncoder(stdin)
+	d.stdin = stdin
+	d.responses = make(chan plugin.Response)
+	d.exited = make(chan struct{})
+	go func() {
+		dec := json.NewDecoder(stdout)
+		for {
+			var res plugin.Response
+			if err := dec.Decode(&res); err != nil {
+				d.readErr = err
+				close(d.responses)
+				break
+			}
+			d.responses <- res
+		}
+		_ = cmd.Wait()
+		stderr.Flush()
+		close(d.exited)
+	}()
+	return nil
+}
+
+// fail marks the plugin as unusable, kills its process and returns the supplied error.
+func (d *pluginSource) fail(err error) error {
+	if d.err == nil {
+		d.err = err
+		_ = d.cmd.Process.Kill()
+	}
+	return err
+}
+
+// call sends a request for the supplied method and params to the plugin and unmarshals the result of the response
+// into the supplied result object. The caller must hold the lock, or be the only user of the data source.
+func (d *pluginSource) call(method string, params interface{}, result interface{}) error {
+	if d.err != nil {
+		return d.err
+	}
+	d.nextID++
+	req := plugin.Request{ID: d.nextID, Method: method}
+	if params != nil {
+		b, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = b
+	}
+	if err := d.enc.Encode(req); err != nil {
+		return d.fail(errors.Wrapf(err, "%s: write request", method))
+	}
+	timer := time.NewTimer(d.timeout)
+	defer timer.Stop()
+	select {
+	case res, ok := <-d.responses:
+		if !ok {
+			return d.fail(fmt.Errorf("%s: plugin output ended: %v", method, d.readErr))
+		}
+		if res.ID != req.ID {
+			return d.fail(fmt.Errorf("%s: response for request %d, want %d", method, res.ID, req.ID))
+		}
+		if res.Error != "" {
+			return fmt.Errorf("%s: %s", method, res.Error)
+		}
+		if result == nil {
+			return nil
+		}
+		if err := json.Unmarshal(res.Result, result); err != nil {
+			return d.fail(errors.Wrapf(err, "%s: unmarshal result", method))
+		}
+		return nil
+	case <-timer.C:
+		return d.fail(fmt.Errorf("%s: plugin did not respond in %v", method, d.timeout))
+	}
+}
+
+// waitExit waits for the plugin process to exit, discarding any responses that are no longer expected. The process
+// is killed if it does not exit within the call timeout.
+func (d *pluginSource) waitExit() {
+	timer := time.NewTimer(d.timeout)
+	defer timer.Stop()
+	responses := d.responses
+	for {
+		select {
+		case <-d.exited:
+			return
+		case _, ok := <-responses:
+			if !ok {
+				responses = nil
+			}
+		case <-timer.C:
+			_ = d.cmd.Process.Kill()
+		}
+	}
+}
+
+// Resolve implements the interface method.
+func (d *pluginSource) Resolve(path string) (string, error) {
+	d.l.Lock()
+	defer d.l.Unlock()
+	var res plugin.ResolveResult
+	if err := d.call(plugin.MethodResolve, plugin.ResolveParams{Path: path}, &res); err != nil {
+		return "", err
+	}
+	return res.Content, nil
+}
+
+// Close implements the interface method. It sends a close request to the plugin and waits for it to exit,
+// killing it if it does not exit in time.
+func (d *pluginSource) Close() error {
+	d.l.Lock()
+	defer d.l.Unlock()
+	if d.cmd == nil {
+		return nil
+	}
+	var err error
+	if d.err == nil {
+		err = d.call(plugin.MethodClose, nil, nil)
+	}
+	_ = d.stdin.Close()
+	d.waitExit()
+	d.cmd = nil
+	if d.err == nil {
+		d.err = fmt.Errorf("data source %s has been closed", d.name)
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/splunk/qbec/vm/datasource/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testHandler is the plugin implementation run by the test binary when testPluginEnv is set.
+type testHandler struct {
+	name   string
+	config json.RawMessage
+	count  int
+}
+
+const testPluginEnv = "QBEC_TEST_PLUGIN"
+
+func (h *testHandler) Init(name string, config json.RawMessage) error {
+	if os.Getenv("__DS_NAME__") != name {
+		return fmt.Errorf("data source name %q not in environment", name)
+	}
+	h.name = name
+	h.config = config
+	return nil
+}
+
+func (h *testHandler) Resolve(path string) (string, error) {
+	switch path {
+	case "/count":
+		h.count++
+		return fmt.Sprint(h.count), nil
+	case "/config":
+		return string(h.config), nil
+	case "/env":
+		return os.Getenv("FOO"), nil
+	case "/hang":
+		time.Sleep(time.Minute)
+		return "", nil
+	case "/exit":
+		os.Exit(1)
+	}
+	return "", fmt.Errorf("%s: not found", path)
+}
+
+func (h *testHandler) Close() error {
+	return nil
+}
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testPluginEnv) != "" {
+		if err := plugin.Serve(os.Stdin, os.Stdout, &testHandler{}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func configProvider(t *testing.T, cfg map[string]interface{}) func(string) (string, error) {
+	return func(name string) (string, error) {
+		if name != "pluginConfig" {
+			return "", fmt.Errorf("invalid call to config provider, want %q got %q", "pluginConfig", name)
+		}
+		b, err := json.Marshal(cfg)
+		require.NoError(t, err)
+		return string(b), nil
+	}
+}
+
+func testConfig(extra map[string]interface{}) map[string]interface{} {
+	exe, _ := os.Executable()
+	cfg := map[string]interface{}{
+		"command": exe,
+		"env":     map[string]string{testPluginEnv: "true", "FOO": "bar"},
+		"config":  map[string]interface{}{"region": "us-west-2"},
+	}
+	for k, v := range extra {
+		cfg[k] = v
+	}
+	return cfg
+}
+
+func TestPluginBasic(t *testing.T) {
+	ds := New("my-ds", "pluginConfig", "")
+	err := ds.Init(configProvider(t, testConfig(nil)))
+	require.NoError(t, err)
+	defer ds.Close()
+	a := assert.New(t)
+	a.Equal("my-ds", ds.Name())
+
+	for i := 1; i <= 3; i++ {
+		out, err := ds.Resolve("/count")
+		require.NoError(t, err)
+		a.Equal(fmt.Sprint(i), out)
+	}
+	out, err := ds.Resolve("/config")
+	require.NoError(t, err)
+	a.JSONEq(`{"region":"us-west-2"}`, out)
+	out, err = ds.Resolve("/env")
+	require.NoError(t, err)
+	a.Equal("bar", out)
+
+	_, err = ds.Resolve("/foo")
+	require.Error(t, err)
+	a.Equal("resolve: /foo: not found", err.Error())
+	out, err = ds.Resolve("/count")
+	require.NoError(t, err)
+	a.Equal("4", out)
+
+	require.NoError(t, ds.Close())
+	require.NoError(t, ds.Close())
+	_, err = ds.Resolve("/count")
+	require.Error(t, err)
+	a.Equal("data source my-ds has been closed", err.Error())
+}
+
+func TestPluginTimeout(t *testing.T) {
+	ds := New("my-ds", "pluginConfig", "")
+	err := ds.Init(configProvider(t, testConfig(map[string]interface{}{"timeout": "500ms"})))
+	require.NoError(t, err)
+	defer ds.Close()
+	_, err = ds.Resolve("/hang")
+	require.Error(t, err)
+	assert.Equal(t, "resolve: plugin did not respond in 500ms", err.Error())
+	_, err = ds.Resolve("/count")
+	require.Error(t, err)
+	assert.Equal(t, "resolve: plugin did not respond in 500ms", err.Error())
+	require.NoError(t, ds.Close())
+}
+
+func TestPluginExit(t *testing.T) {
+	ds := New("my-ds", "pluginConfig", "")
+	err := ds.Init(configProvider(t, testConfig(nil)))
+	require.NoError(t, err)
+	defer ds.Close()
+	_, err = ds.Resolve("/exit")
+	require.Error(t, err)
+	assert.Equal(t, "resolve: plugin output ended: EOF", err.Error())
+	require.NoError(t, ds.Close())
+}
+
+func TestPluginInitNegative(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		msg    string
+	}{
+		{
+			name:   "no-command",
+			config: map[string]interface{}{},
+			msg:    "command not specified",
+		},
+		{
+			name:   "bad-command",
+			config: map[string]interface{}{"command": "no-such-plugin"},
+			msg:    "invalid command 'no-such-plugin'",
+		},
+		{
+			name:   "bad-timeout",
+			config: testConfig(map[string]interface{}{"timeout": "abc"}),
+			msg:    "invalid timeout 'abc'",
+		},
+		{
+			name:   "not-a-plugin",
+			config: map[string]interface{}{"command": "true"},
+			msg:    "init: ",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ds := New("my-ds", "pluginConfig", "")
+			err := ds.Init(configProvider(t, test.config))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.msg)
+			require.NoError(t, ds.Close())
+		})
+	}
+}
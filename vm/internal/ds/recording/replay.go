@@ -0,0 +1,174 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package recording provides data sources that record the outputs of other data sources to disk and replay them
+// later without access to the original data source.
+package recording
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/vm/datasource"
+)
+
+// recording is the serialized form of the output of a data source for a single path.
+type recording struct {
+	Key    string `json:"key,omitempty"`
+	Path   string `json:"path"`
+	Output string `json:"output"`
+}
+
+// recordingFile returns the file in which the output for the supplied key, data source and path is recorded.
+// The key distinguishes outputs of the same data source and path that depend on the context in which the
+// data source is used, such as the environment whose configuration it was initialized with.
+func recordingFile(dir, key, name, path string) string {
+	id := path
+	if key != "" {
+		id = key + "\x00" + path
+	}
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(dir, name, hex.EncodeToString(sum[:])+".json")
+}
+
+// Recorder is a data source that delegates to another data source and records its outputs in a directory.
+// Outputs of delegates that implement the redacting data source interface are redacted before they are recorded.
+type Recorder struct {
+	delegate datasource.DataSource
+	dir      string
+	key      string
+}
+
+// NewRecorder returns a data source that records the outputs of the supplied data source under the supplied directory
+// for the supplied key.
+func NewRecorder(delegate datasource.DataSource, dir, key string) *Recorder {
+	return &Recorder{delegate: delegate, dir: dir, key: key}
+}
+
+// Name implements the interface method.
+func (r *Recorder) Name() string {
+	return r.delegate.Name()
+}
+
+func (r *Recorder) record(path, output string) error {
+	if rd, ok := r.delegate.(datasource.RedactingDataSource); ok {
+		var err error
+		if output, err = rd.Redact(path, output); err != nil {
+			return errors.Wrapf(err, "redact output for %s", path)
+		}
+	}
+	file := recordingFile(r.dir, r.key, r.Name(), path)
+	// recorded outputs may contain secrets, so only the current user is allowed to read them
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(recording{Key: r.key, Path: path, Output: output}, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write to a temporary file and rename it, since the same path may be recorded concurrently by multiple VMs
+	f, err := ioutil.TempFile(filepath.Dir(file), ".recording-")
+	if err != nil {
+		return err
+	}
+	err = f.Chmod(0600)
+	if err == nil {
+		_, err = f.Write(append(b, '\n'))
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), file)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return errors.Wrapf(err, "record output for %s", path)
+	}
+	return nil
+}
+
+// Resolve implements the interface method.
+func (r *Recorder) Resolve(path string) (string, error) {
+	out, err := r.delegate.Resolve(path)
+	if err != nil {
+		return "", err
+	}
+	if err := r.record(path, out); err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// ResolveTo implements the interface method, streaming the output of the delegate when it supports streaming.
+func (r *Recorder) ResolveTo(path string, w io.Writer) error {
+	s, ok := r.delegate.(datasource.StreamingDataSource)
+	if !ok {
+		out, err := r.Resolve(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, out)
+		return err
+	}
+	var b bytes.Buffer
+	if err := s.ResolveTo(path, io.MultiWriter(w, &b)); err != nil {
+		return err
+	}
+	return r.record(path, b.String())
+}
+
+// Replayer is a data source that returns outputs previously recorded for a data source with the same name.
+type Replayer struct {
+	name string
+	dir  string
+	key  string
+}
+
+// NewReplayer returns a data source with the supplied name that replays outputs recorded in the supplied directory
+// for the supplied key.
+func NewReplayer(name, dir, key string) *Replayer {
+	return &Replayer{name: name, dir: dir, key: key}
+}
+
+// Name implements the interface method.
+func (r *Replayer) Name() string {
+	return r.name
+}
+
+// Resolve implements the interface method. It returns an error when no output has been recorded for the path.
+func (r *Replayer) Resolve(path string) (string, error) {
+	b, err := ioutil.ReadFile(recordingFile(r.dir, r.key, r.name, path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("no recorded output for path %s in %s", path, r.dir)
+		}
+		return "", err
+	}
+	var rec recording
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return "", errors.Wrapf(err, "unmarshal recorded output for path %s", path)
+	}
+	return rec.Output, nil
+}
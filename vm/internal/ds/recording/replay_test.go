@@ -0,0 +1,170 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package recording
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type source struct {
+	calls int32
+}
+
+func (s *source) Name() string { return "src" }
+
+func (s *source) Resolve(path string) (string, error) {
+	atomic.AddInt32(&s.calls, 1)
+	if path == "/bad" {
+		return "", fmt.Errorf("bad path")
+	}
+	return "output for " + path, nil
+}
+
+type streamingSource struct {
+	source
+}
+
+func (s *streamingSource) ResolveTo(path string, w io.Writer) error {
+	out, err := s.Resolve(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, strings.ToUpper(out))
+	return err
+}
+
+type redactingSource struct {
+	streamingSource
+}
+
+func (s *redactingSource) Redact(path, output string) (string, error) {
+	if path == "/unredactable" {
+		return "", fmt.Errorf("bad output")
+	}
+	return strings.Replace(output, "FOO", "XXX", -1), nil
+}
+
+func TestRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	src := &source{}
+	r := NewRecorder(src, dir, "")
+	a := assert.New(t)
+	a.Equal("src", r.Name())
+
+	out, err := r.Resolve("/foo")
+	require.NoError(t, err)
+	a.Equal("output for /foo", out)
+	var b strings.Builder
+	require.NoError(t, r.ResolveTo("/bar?x=1", &b))
+	a.Equal("output for /bar?x=1", b.String())
+	_, err = r.Resolve("/bad")
+	require.Error(t, err)
+	a.Equal("bad path", err.Error())
+	a.EqualValues(3, src.calls)
+
+	p := NewReplayer("src", dir, "")
+	a.Equal("src", p.Name())
+	out, err = p.Resolve("/foo")
+	require.NoError(t, err)
+	a.Equal("output for /foo", out)
+	out, err = p.Resolve("/bar?x=1")
+	require.NoError(t, err)
+	a.Equal("output for /bar?x=1", out)
+	_, err = p.Resolve("/bad")
+	require.Error(t, err)
+	a.Equal("no recorded output for path /bad in "+dir, err.Error())
+	a.EqualValues(3, src.calls)
+
+	_, err = NewReplayer("other", dir, "").Resolve("/foo")
+	require.Error(t, err)
+
+	matches, err := filepath.Glob(filepath.Join(dir, "src", "*.json"))
+	require.NoError(t, err)
+	a.Equal(2, len(matches))
+	for _, m := range matches {
+		st, err := os.Stat(m)
+		require.NoError(t, err)
+		a.Equal(os.FileMode(0600), st.Mode().Perm())
+	}
+	st, err := os.Stat(filepath.Join(dir, "src"))
+	require.NoError(t, err)
+	a.Equal(os.FileMode(0700), st.Mode().Perm())
+}
+
+func TestRecordStreaming(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRecorder(&streamingSource{}, dir, "")
+	var b strings.Builder
+	require.NoError(t, r.ResolveTo("/foo", &b))
+	assert.Equal(t, "OUTPUT FOR /FOO", b.String())
+	require.Error(t, r.ResolveTo("/bad", &b))
+
+	out, err := NewReplayer("src", dir, "").Resolve("/foo")
+	require.NoError(t, err)
+	assert.Equal(t, "OUTPUT FOR /FOO", out)
+}
+
+func TestRecordReplayKeys(t *testing.T) {
+	dir := t.TempDir()
+	dev := &source{}
+	_, err := NewRecorder(dev, dir, "dev").Resolve("/foo")
+	require.NoError(t, err)
+	var b strings.Builder
+	require.NoError(t, NewRecorder(&streamingSource{}, dir, "prod").ResolveTo("/foo", &b))
+
+	out, err := NewReplayer("src", dir, "dev").Resolve("/foo")
+	require.NoError(t, err)
+	assert.Equal(t, "output for /foo", out)
+	out, err = NewReplayer("src", dir, "prod").Resolve("/foo")
+	require.NoError(t, err)
+	assert.Equal(t, "OUTPUT FOR /FOO", out)
+	_, err = NewReplayer("src", dir, "").Resolve("/foo")
+	require.Error(t, err)
+	_, err = NewReplayer("src", dir, "stage").Resolve("/foo")
+	require.Error(t, err)
+}
+
+func TestRecordRedacted(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRecorder(&redactingSource{}, dir, "")
+	out, err := r.Resolve("/foo")
+	require.NoError(t, err)
+	assert.Equal(t, "output for /foo", out)
+	var b strings.Builder
+	require.NoError(t, r.ResolveTo("/foo/bar", &b))
+	assert.Equal(t, "OUTPUT FOR /FOO/BAR", b.String())
+	_, err = r.Resolve("/unredactable")
+	require.Error(t, err)
+	assert.Equal(t, "redact output for /unredactable: bad output", err.Error())
+
+	p := NewReplayer("src", dir, "")
+	out, err = p.Resolve("/foo")
+	require.NoError(t, err)
+	assert.Equal(t, "output for /foo", out)
+	out, err = p.Resolve("/foo/bar")
+	require.NoError(t, err)
+	assert.Equal(t, "OUTPUT FOR /XXX/BAR", out)
+}
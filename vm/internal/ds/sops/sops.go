@@ -0,0 +1,169 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package sops provides a data source implementation that decrypts files encrypted using sops.
+package sops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/sops"
+	"github.com/splunk/qbec/vm/datasource"
+	"github.com/splunk/qbec/vm/internal/ds"
+	"github.com/splunk/qbec/vm/internal/natives"
+)
+
+// Scheme is the scheme supported by this data source
+const Scheme = "sops"
+
+// Config is the configuration of the data source.
+type Config struct {
+	Command string        `json:"command"`           // the executable that is run, default is "sops"
+	Timeout string        `json:"timeout,omitempty"` // command timeout as a duration string
+	timeout time.Duration // internal representation
+}
+
+func (c *Config) assertValid(dir string) error {
+	if c.Command == "" {
+		return fmt.Errorf("command not specified")
+	}
+	if c.Timeout != "" {
+		t, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout '%s': %v", c.Timeout, err)
+		}
+		c.timeout = t
+	}
+	exe, err := ds.FindExecutable(c.Command, dir)
+	if err != nil {
+		return fmt.Errorf("invalid command '%s': %v", c.Command, err)
+	}
+	c.Command = exe
+	return nil
+}
+
+func (c *Config) initDefaults() {
+	if c.Command == "" {
+		c.Command = sops.DefaultCommand
+	}
+	if c.timeout == 0 {
+		c.timeout = time.Minute
+	}
+}
+
+type sopsSource struct {
+	name      string
+	configVar string
+	dir       string
+	config    Config
+}
+
+// New creates a new sops data source. Files are resolved relative to the supplied directory, which is the
+// qbec root, or the working directory when it is blank.
+func New(name string, configVar string, dir string) ds.DataSourceWithLifecycle {
+	return &sopsSource{
+		name:      name,
+		configVar: configVar,
+		dir:       dir,
+	}
+}
+
+// Name implements the interface method
+func (d *sopsSource) Name() string {
+	return d.name
+}
+
+// Init implements the interface method.
+func (d *sopsSource) Init(p datasource.ConfigProvider) (fErr error) {
+	defer func() {
+		fErr = errors.Wrapf(fErr, "init data source %s", d.name) // nil wraps as nil
+	}()
+	cfgJSON, err := p(d.configVar)
+	if err != nil {
+		return err
+	}
+	var c Config
+	err = json.Unmarshal([]byte(cfgJSON), &c)
+	if err != nil {
+		return err
+	}
+	c.initDefaults()
+	err = c.assertValid(d.dir)
+	if err != nil {
+		return err
+	}
+	d.config = c
+	return nil
+}
+
+// Resolve implements the interface method. The path is that of the encrypted file relative to the qbec root.
+// YAML and JSON files are returned as data, with a YAML file containing multiple documents returned as an array.
+// The decrypted contents of any other file are returned as a string.
+func (d *sopsSource) Resolve(path string) (string, error) {
+	file := strings.TrimPrefix(path, "/")
+	if file == "" {
+		return "", fmt.Errorf("no file in data source path %q", path)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.config.timeout)
+	defer cancel()
+	b, err := sops.Decrypt(ctx, d.config.Command, filepath.Join(d.dir, file))
+	if err != nil {
+		return "", err
+	}
+	out, err := parse(file, b)
+	if err != nil {
+		return "", errors.Wrapf(err, "parse decrypted contents of %s", file)
+	}
+	ret, err := json.Marshal(out)
+	if err != nil {
+		return "", errors.Wrap(err, "marshal output")
+	}
+	return string(ret), nil
+}
+
+func parse(file string, b []byte) (interface{}, error) {
+	switch filepath.Ext(file) {
+	case ".yaml", ".yml":
+		docs, err := natives.ParseYAMLDocuments(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		if len(docs) == 1 {
+			return docs[0], nil
+		}
+		return docs, nil
+	case ".json":
+		var data interface{}
+		if err := json.Unmarshal(b, &data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	default:
+		return string(b), nil
+	}
+}
+
+// Close implements the interface method.
+func (d *sopsSource) Close() error {
+	return nil
+}
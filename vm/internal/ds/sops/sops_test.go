@@ -0,0 +1,114 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package sops
+
+import (
+	"encoding/json"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/splunk/qbec/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitDefaults(t *testing.T) {
+	cfg := Config{}
+	cfg.initDefaults()
+	require.Equal(t, "sops", cfg.Command)
+	require.Equal(t, time.Minute, cfg.timeout)
+}
+
+func TestResolve(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.SkipNow()
+	}
+	d := New("secrets", "cfg", "")
+	err := d.Init(testutil.VarProvider(map[string]string{
+		"cfg": `{ "command": "testdata/fake-sops.sh", "timeout": "10s" }`,
+	}))
+	require.NoError(t, err)
+	defer d.Close()
+	assert.Equal(t, "secrets", d.Name())
+
+	tests := []struct {
+		path     string
+		expected interface{}
+	}{
+		{"/testdata/secret.yaml", map[string]interface{}{"password": "s3cret"}},
+		{"/testdata/multi.yaml", []interface{}{map[string]interface{}{"a": float64(1)}, map[string]interface{}{"b": float64(2)}}},
+		{"/testdata/secret.json", map[string]interface{}{"password": "s3cret"}},
+		{"/testdata/token.txt", "tok3n\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			out, err := d.Resolve(test.path)
+			require.NoError(t, err)
+			var data interface{}
+			require.NoError(t, json.Unmarshal([]byte(out), &data))
+			assert.EqualValues(t, test.expected, data)
+		})
+	}
+}
+
+func TestResolveNegative(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.SkipNow()
+	}
+	d := New("secrets", "cfg", "")
+	err := d.Init(testutil.VarProvider(map[string]string{
+		"cfg": `{ "command": "testdata/fake-sops.sh" }`,
+	}))
+	require.NoError(t, err)
+	tests := []struct {
+		name string
+		path string
+		msg  string
+	}{
+		{"no-file", "/", "no file in data source path"},
+		{"bad-file", "/testdata/nonexistent.yaml", "sops decrypt testdata/nonexistent.yaml"},
+		{"bad-contents", "/testdata/bad.yaml", "parse decrypted contents of testdata/bad.yaml"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := d.Resolve(test.path)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.msg)
+		})
+	}
+}
+
+func TestInitNegative(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  string
+		msg  string
+	}{
+		{"bad-json", `{`, "init data source secrets"},
+		{"bad-timeout", `{ "command": "testdata/fake-sops.sh", "timeout": "xx" }`, "invalid timeout 'xx'"},
+		{"bad-command", `{ "command": "testdata/no-such-command" }`, "invalid command 'testdata/no-such-command'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := New("secrets", "cfg", "")
+			err := d.Init(testutil.VarProvider(map[string]string{"cfg": test.cfg}))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.msg)
+		})
+	}
+}
@@ -0,0 +1,305 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package vault provides a data source implementation that reads secrets from a HashiCorp Vault server.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/sio"
+	"github.com/splunk/qbec/vm/datasource"
+	"github.com/splunk/qbec/vm/internal/ds"
+)
+
+// Scheme is the scheme supported by this data source
+const Scheme = "vault"
+
+// environment variables from which the server address and credentials are read.
+const (
+	envAddress   = "VAULT_ADDR"
+	envToken     = "VAULT_TOKEN"
+	envRoleID    = "VAULT_ROLE_ID"
+	envSecretID  = "VAULT_SECRET_ID"
+	envNamespace = "VAULT_NAMESPACE"
+)
+
+// query parameters supported in import paths.
+const (
+	keyParam     = "key"
+	versionParam = "version"
+)
+
+// Config is the configuration of the data source. Credentials are never part of the config and are always
+// read from the environment.
+type Config struct {
+	Address      string        `json:"address,omitempty"`      // vault server address, defaults to the value of VAULT_ADDR
+	Mount        string        `json:"mount,omitempty"`        // mount path of the KV secrets engine, default is "secret"
+	KVVersion    int           `json:"kvVersion,omitempty"`    // version of the KV secrets engine, 1 or 2, default is 2
+	Namespace    string        `json:"namespace,omitempty"`    // vault enterprise namespace, defaults to the value of VAULT_NAMESPACE
+	AppRoleMount string        `json:"appRoleMount,omitempty"` // mount path of the approle auth method, default is "approle"
+	Timeout      string        `json:"timeout,omitempty"`      // request timeout as a duration string
+	timeout      time.Duration // internal representation
+}
+
+func (c *Config) initDefaults() {
+	if c.Address == "" {
+		c.Address = os.Getenv(envAddress)
+	}
+	if c.Namespace == "" {
+		c.Namespace = os.Getenv(envNamespace)
+	}
+	if c.Mount == "" {
+		c.Mount = "secret"
+	}
+	if c.KVVersion == 0 {
+		c.KVVersion = 2
+	}
+	if c.AppRoleMount == "" {
+		c.AppRoleMount = "approle"
+	}
+	if c.timeout == 0 {
+		c.timeout = 30 * time.Second
+	}
+}
+
+func (c *Config) assertValid() error {
+	if c.Address == "" {
+		return fmt.Errorf("vault address not specified in config or %s environment variable", envAddress)
+	}
+	u, err := url.Parse(c.Address)
+	if err != nil {
+		return fmt.Errorf("invalid address '%s': %v", c.Address, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid address '%s': scheme must be http or https", c.Address)
+	}
+	if c.KVVersion != 1 && c.KVVersion != 2 {
+		return fmt.Errorf("invalid KV version %d, must be 1 or 2", c.KVVersion)
+	}
+	if c.Timeout != "" {
+		t, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout '%s': %v", c.Timeout, err)
+		}
+		c.timeout = t
+	}
+	c.Address = strings.TrimSuffix(c.Address, "/")
+	c.Mount = strings.Trim(c.Mount, "/")
+	c.AppRoleMount = strings.Trim(c.AppRoleMount, "/")
+	return nil
+}
+
+type vaultSource struct {
+	name      string
+	configVar string
+	config    Config
+	client    *http.Client
+	token     string
+}
+
+// New creates a new vault data source
+func New(name string, configVar string) ds.DataSourceWithLifecycle {
+	return &vaultSource{
+		name:      name,
+		configVar: configVar,
+	}
+}
+
+// Name implements the interface method
+func (d *vaultSource) Name() string {
+	return d.name
+}
+
+// Init implements the interface method. It reads the configuration and authenticates to the vault server
+// using a token from VAULT_TOKEN or, when that is not set, an approle login using VAULT_ROLE_ID and VAULT_SECRET_ID.
+func (d *vaultSource) Init(p datasource.ConfigProvider) (fErr error) {
+	defer func() {
+		fErr = errors.Wrapf(fErr, "init data source %s", d.name) // nil wraps as nil
+	}()
+	cfgJSON, err := p(d.configVar)
+	if err != nil {
+		return err
+	}
+	var c Config
+	err = json.Unmarshal([]byte(cfgJSON), &c)
+	if err != nil {
+		return err
+	}
+	c.initDefaults()
+	err = c.assertValid()
+	if err != nil {
+		return err
+	}
+	d.config = c
+	d.client = &http.Client{Timeout: c.timeout}
+	token, err := d.authenticate()
+	if err != nil {
+		return err
+	}
+	d.token = token
+	return nil
+}
+
+func (d *vaultSource) authenticate() (string, error) {
+	if token := os.Getenv(envToken); token != "" {
+		return token, nil
+	}
+	roleID, secretID := os.Getenv(envRoleID), os.Getenv(envSecretID)
+	if roleID == "" || secretID == "" {
+		return "", fmt.Errorf("no vault credentials found, set %s or both %s and %s", envToken, envRoleID, envSecretID)
+	}
+	body, err := json.Marshal(map[string]string{"role_id": roleID, "secret_id": secretID})
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	if err := d.do(http.MethodPost, fmt.Sprintf("auth/%s/login", d.config.AppRoleMount), nil, body, &out); err != nil {
+		return "", errors.Wrap(err, "approle login")
+	}
+	if out.Auth.ClientToken == "" {
+		return "", fmt.Errorf("approle login: no client token in response")
+	}
+	return out.Auth.ClientToken, nil
+}
+
+// do performs a request against the vault API at the supplied path relative to /v1 and decodes the JSON response
+// into out.
+func (d *vaultSource) do(method, path string, query url.Values, body []byte, out interface{}) error {
+	u := fmt.Sprintf("%s/v1/%s", d.config.Address, path)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.config.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if d.token != "" {
+		req.Header.Set("X-Vault-Token", d.token)
+	}
+	if d.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", d.config.Namespace)
+	}
+	sio.Debugf("vault: %s %s\n", method, u)
+	res, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(res.Body, 10*1024*1024))
+	if err != nil {
+		return errors.Wrap(err, "read response")
+	}
+	if res.StatusCode != http.StatusOK {
+		var ve struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(b, &ve) == nil && len(ve.Errors) > 0 {
+			return fmt.Errorf("%s %s: status %d: %s", method, path, res.StatusCode, strings.Join(ve.Errors, ", "))
+		}
+		return fmt.Errorf("%s %s: status %d", method, path, res.StatusCode)
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return errors.Wrap(err, "unmarshal response")
+	}
+	return nil
+}
+
+// Resolve implements the interface method. The path is the path of the secret relative to the KV mount.
+// The returned value is a JSON object containing the key/value pairs of the secret. An optional key query
+// parameter restricts the output to the value of that key and, for KV version 2, an optional version parameter
+// reads a specific version of the secret.
+func (d *vaultSource) Resolve(path string) (string, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "parse path %q", path)
+	}
+	secretPath := strings.Trim(u.Path, "/")
+	if secretPath == "" {
+		return "", fmt.Errorf("no secret path in data source path %q", path)
+	}
+	q := u.Query()
+	key := q.Get(keyParam)
+	query := url.Values{}
+	var apiPath string
+	if d.config.KVVersion == 2 {
+		apiPath = fmt.Sprintf("%s/data/%s", d.config.Mount, secretPath)
+		if v := q.Get(versionParam); v != "" {
+			query.Set(versionParam, v)
+		}
+	} else {
+		if q.Get(versionParam) != "" {
+			return "", fmt.Errorf("secret %s: version is only supported for KV version 2", secretPath)
+		}
+		apiPath = fmt.Sprintf("%s/%s", d.config.Mount, secretPath)
+	}
+	var out struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := d.do(http.MethodGet, apiPath, query, nil, &out); err != nil {
+		return "", errors.Wrapf(err, "read secret %s", secretPath)
+	}
+	data := map[string]interface{}{}
+	if d.config.KVVersion == 2 {
+		var v2 struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := json.Unmarshal(out.Data, &v2); err != nil {
+			return "", errors.Wrapf(err, "read secret %s", secretPath)
+		}
+		if v2.Data != nil {
+			data = v2.Data
+		}
+	} else if err := json.Unmarshal(out.Data, &data); err != nil {
+		return "", errors.Wrapf(err, "read secret %s", secretPath)
+	}
+	var ret interface{} = data
+	if key != "" {
+		v, ok := data[key]
+		if !ok {
+			return "", fmt.Errorf("secret %s does not have key %q", secretPath, key)
+		}
+		ret = v
+	}
+	b, err := json.Marshal(ret)
+	if err != nil {
+		return "", errors.Wrap(err, "marshal output")
+	}
+	return string(b), nil
+}
+
+// Close implements the interface method.
+func (d *vaultSource) Close() error {
+	return nil
+}
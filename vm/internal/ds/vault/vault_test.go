@@ -0,0 +1,216 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/splunk/qbec/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func clearEnv(t *testing.T) {
+	for _, k := range []string{envAddress, envToken, envRoleID, envSecretID, envNamespace} {
+		t.Setenv(k, "")
+	}
+}
+
+func testServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		write := func(v interface{}) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(v)
+		}
+		if r.URL.Path == "/v1/auth/approle/login" {
+			var body map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			if body["role_id"] != "role" || body["secret_id"] != "s3cr3t" {
+				w.WriteHeader(http.StatusBadRequest)
+				write(map[string]interface{}{"errors": []string{"invalid role or secret ID"}})
+				return
+			}
+			write(map[string]interface{}{"auth": map[string]interface{}{"client_token": "approle-token"}})
+			return
+		}
+		token := r.Header.Get("X-Vault-Token")
+		if token != "root-token" && token != "approle-token" {
+			w.WriteHeader(http.StatusForbidden)
+			write(map[string]interface{}{"errors": []string{"permission denied"}})
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/app/db":
+			password := "p1"
+			if r.URL.Query().Get("version") == "1" {
+				password = "p0"
+			}
+			write(map[string]interface{}{
+				"data": map[string]interface{}{
+					"data":     map[string]interface{}{"user": "admin", "password": password},
+					"metadata": map[string]interface{}{"version": 2},
+				},
+			})
+		case "/v1/kv/app/db":
+			assert.Equal(t, "ns1", r.Header.Get("X-Vault-Namespace"))
+			write(map[string]interface{}{
+				"data": map[string]interface{}{"user": "admin", "password": "v1"},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			write(map[string]interface{}{"errors": []string{}})
+		}
+	}))
+}
+
+func TestInitDefaults(t *testing.T) {
+	clearEnv(t)
+	t.Setenv(envAddress, "http://vault:8200")
+	cfg := Config{}
+	cfg.initDefaults()
+	require.NoError(t, cfg.assertValid())
+	assert.Equal(t, "http://vault:8200", cfg.Address)
+	assert.Equal(t, "secret", cfg.Mount)
+	assert.Equal(t, 2, cfg.KVVersion)
+	assert.Equal(t, "approle", cfg.AppRoleMount)
+	assert.Equal(t, 30*time.Second, cfg.timeout)
+}
+
+func TestResolveWithToken(t *testing.T) {
+	clearEnv(t)
+	s := testServer(t)
+	defer s.Close()
+	t.Setenv(envAddress, s.URL)
+	t.Setenv(envToken, "root-token")
+	d := New("vault", "cfg")
+	require.NoError(t, d.Init(testutil.VarProvider(map[string]string{"cfg": `{}`})))
+	defer d.Close()
+	assert.Equal(t, "vault", d.Name())
+
+	out, err := d.Resolve("/app/db")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"user":"admin","password":"p1"}`, out)
+
+	out, err = d.Resolve("/app/db?key=password&version=1")
+	require.NoError(t, err)
+	assert.Equal(t, `"p0"`, out)
+
+	_, err = d.Resolve("/app/db?key=foo")
+	require.Error(t, err)
+	assert.Equal(t, `secret app/db does not have key "foo"`, err.Error())
+
+	_, err = d.Resolve("/app/missing")
+	require.Error(t, err)
+	assert.Equal(t, "read secret app/missing: GET secret/data/app/missing: status 404", err.Error())
+
+	_, err = d.Resolve("/")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no secret path in data source path")
+}
+
+func TestResolveWithAppRoleKV1(t *testing.T) {
+	clearEnv(t)
+	s := testServer(t)
+	defer s.Close()
+	t.Setenv(envRoleID, "role")
+	t.Setenv(envSecretID, "s3cr3t")
+	d := New("vault", "cfg")
+	cfg := fmt.Sprintf(`{"address":"%s/","mount":"kv","kvVersion":1,"namespace":"ns1","timeout":"5s"}`, s.URL)
+	require.NoError(t, d.Init(testutil.VarProvider(map[string]string{"cfg": cfg})))
+	defer d.Close()
+
+	out, err := d.Resolve("/app/db?key=password")
+	require.NoError(t, err)
+	assert.Equal(t, `"v1"`, out)
+
+	_, err = d.Resolve("/app/db?version=1")
+	require.Error(t, err)
+	assert.Equal(t, "secret app/db: version is only supported for KV version 2", err.Error())
+}
+
+func TestInitNegative(t *testing.T) {
+	s := testServer(t)
+	defer s.Close()
+	tests := []struct {
+		name string
+		cfg  string
+		env  map[string]string
+		msg  string
+	}{
+		{
+			name: "bad config var",
+			msg:  "init data source vault: no such var cfg",
+		},
+		{
+			name: "bad json",
+			cfg:  `{`,
+			msg:  "init data source vault: unexpected end of JSON input",
+		},
+		{
+			name: "no address",
+			cfg:  `{}`,
+			msg:  "init data source vault: vault address not specified in config or VAULT_ADDR environment variable",
+		},
+		{
+			name: "bad scheme",
+			cfg:  `{"address":"ftp://vault"}`,
+			msg:  "init data source vault: invalid address 'ftp://vault': scheme must be http or https",
+		},
+		{
+			name: "bad kv version",
+			cfg:  `{"address":"http://vault","kvVersion":3}`,
+			msg:  "init data source vault: invalid KV version 3, must be 1 or 2",
+		},
+		{
+			name: "bad timeout",
+			cfg:  `{"address":"http://vault","timeout":"xxx"}`,
+			msg:  `init data source vault: invalid timeout 'xxx': time: invalid duration`,
+		},
+		{
+			name: "no credentials",
+			cfg:  `{"address":"http://vault"}`,
+			msg:  "init data source vault: no vault credentials found, set VAULT_TOKEN or both VAULT_ROLE_ID and VAULT_SECRET_ID",
+		},
+		{
+			name: "bad approle",
+			cfg:  fmt.Sprintf(`{"address":"%s"}`, s.URL),
+			env:  map[string]string{envRoleID: "role", envSecretID: "wrong"},
+			msg:  "init data source vault: approle login: POST auth/approle/login: status 400: invalid role or secret ID",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range test.env {
+				t.Setenv(k, v)
+			}
+			vars := map[string]string{}
+			if test.cfg != "" {
+				vars["cfg"] = test.cfg
+			}
+			d := New("vault", "cfg")
+			err := d.Init(testutil.VarProvider(vars))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.msg)
+		})
+	}
+}
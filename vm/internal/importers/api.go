@@ -24,3 +24,10 @@ type ExtendedImporter interface {
 	jsonnet.Importer
 	CanProcess(path string) bool
 }
+
+// RelativeImporter is an extended importer that can also resolve relative imports made from the files that it
+// returned.
+type RelativeImporter interface {
+	ExtendedImporter
+	CanProcessFrom(importedFrom, path string) bool
+}
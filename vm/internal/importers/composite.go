@@ -36,6 +36,9 @@ func NewCompositeImporter(importers ...ExtendedImporter) *CompositeImporter {
 // Import implements the interface method by delegating to installed importers in sequence
 func (c *CompositeImporter) Import(importedFrom, importedPath string) (contents jsonnet.Contents, foundAt string, err error) {
 	for _, importer := range c.importers {
+		if ri, ok := importer.(RelativeImporter); ok && ri.CanProcessFrom(importedFrom, importedPath) {
+			return importer.Import(importedFrom, importedPath)
+		}
 		if importer.CanProcess(importedPath) {
 			return importer.Import(importedFrom, importedPath)
 		}
@@ -40,23 +40,61 @@ type DataSourceImporter struct {
 	cache    map[string]*sourceEntry
 	exact    string
 	prefix   string
+	maxBytes int64
 }
 
 // NewDataSourceImporter returns an importer that can resolve paths for the specified datasource.
 // It processes entries of the form
 //    data://{name}[/{path-to-be-resolved}]
-// If no path is provided, it is set to "/"
-func NewDataSourceImporter(source datasource.DataSource) *DataSourceImporter {
+// If no path is provided, it is set to "/". When maxBytes is positive, imports fail when the output of the
+// data source exceeds that many bytes.
+func NewDataSourceImporter(source datasource.DataSource, maxBytes int64) *DataSourceImporter {
 	exact := fmt.Sprintf("%s://%s", dsPrefix, source.Name())
 	ret := &DataSourceImporter{
 		delegate: source,
 		cache:    map[string]*sourceEntry{},
 		exact:    exact,
 		prefix:   exact + "/",
+		maxBytes: maxBytes,
 	}
 	return ret
 }
 
+// limitWriter accumulates output up to a maximum size. The builder is used such that the final string
+// does not require an additional copy of the output.
+type limitWriter struct {
+	b     strings.Builder
+	limit int64
+}
+
+func (l *limitWriter) Write(p []byte) (int, error) {
+	if l.limit > 0 && int64(l.b.Len()+len(p)) > l.limit {
+		return 0, fmt.Errorf("output exceeds limit of %d bytes", l.limit)
+	}
+	return l.b.Write(p)
+}
+
+// resolve returns the output of the data source for the supplied target, streaming it from the data source
+// when supported.
+func (d *DataSourceImporter) resolve(target string) (string, error) {
+	if s, ok := d.delegate.(datasource.StreamingDataSource); ok {
+		var w limitWriter
+		w.limit = d.maxBytes
+		if err := s.ResolveTo(target, &w); err != nil {
+			return "", err
+		}
+		return w.b.String(), nil
+	}
+	out, err := d.delegate.Resolve(target)
+	if err != nil {
+		return "", err
+	}
+	if d.maxBytes > 0 && int64(len(out)) > d.maxBytes {
+		return "", fmt.Errorf("output exceeds limit of %d bytes", d.maxBytes)
+	}
+	return out, nil
+}
+
 // CanProcess implements the interface method
 func (d *DataSourceImporter) CanProcess(path string) bool {
 	return path == d.exact || strings.HasPrefix(path, d.prefix)
@@ -74,7 +112,7 @@ func (d *DataSourceImporter) Import(_, importedPath string) (contents jsonnet.Co
 		return entry.contents, entry.foundAt, entry.err
 	}
 	ds := d.delegate
-	content, err := ds.Resolve(target)
+	content, err := d.resolve(target)
 	err = errors.Wrapf(err, "data source %s, target=%s", ds.Name(), target) // nil ok
 	entry = &sourceEntry{
 		contents: jsonnet.MakeContents(content),
@@ -18,6 +18,9 @@ package importers
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/google/go-jsonnet"
@@ -31,7 +34,7 @@ func (r replay) Name() string                        { return "replay" }
 func (r replay) Resolve(path string) (string, error) { return path, nil }
 
 func TestDataSourceImporterBasic(t *testing.T) {
-	imp := NewDataSourceImporter(replay{})
+	imp := NewDataSourceImporter(replay{}, 0)
 	vm := jsonnet.MakeVM()
 	vm.Importer(NewCompositeImporter(imp))
 	jsonCode, err := vm.EvaluateAnonymousSnippet("test.jsonnet", `{ foo: importstr 'data://replay/foo/bar' }`)
@@ -45,7 +48,7 @@ func TestDataSourceImporterBasic(t *testing.T) {
 }
 
 func TestDataSourceImporterNoPathc(t *testing.T) {
-	imp := NewDataSourceImporter(replay{})
+	imp := NewDataSourceImporter(replay{}, 0)
 	vm := jsonnet.MakeVM()
 	vm.Importer(NewCompositeImporter(imp))
 	jsonCode, err := vm.EvaluateAnonymousSnippet("test.jsonnet", `{ foo: importstr 'data://replay', bar: importstr 'data://replay' }`)
@@ -58,3 +61,46 @@ func TestDataSourceImporterNoPathc(t *testing.T) {
 	assert.Equal(t, "/", data.Foo)
 	assert.Equal(t, 1, len(imp.cache))
 }
+
+type streamer struct {
+	replay
+	chunks int
+}
+
+func (s streamer) Resolve(path string) (string, error) {
+	return "", fmt.Errorf("resolve should not be called")
+}
+
+func (s streamer) ResolveTo(path string, w io.Writer) error {
+	for i := 0; i < s.chunks; i++ {
+		if _, err := io.WriteString(w, "0123456789"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestDataSourceImporterStreaming(t *testing.T) {
+	imp := NewDataSourceImporter(streamer{chunks: 3}, 30)
+	vm := jsonnet.MakeVM()
+	vm.Importer(NewCompositeImporter(imp))
+	jsonCode, err := vm.EvaluateAnonymousSnippet("test.jsonnet", `importstr 'data://replay/foo'`)
+	require.NoError(t, err)
+	assert.Equal(t, `"`+strings.Repeat("0123456789", 3)+`"`, strings.TrimSpace(jsonCode))
+}
+
+func TestDataSourceImporterLimits(t *testing.T) {
+	imp := NewDataSourceImporter(streamer{chunks: 4}, 30)
+	vm := jsonnet.MakeVM()
+	vm.Importer(NewCompositeImporter(imp))
+	_, err := vm.EvaluateAnonymousSnippet("test.jsonnet", `importstr 'data://replay/foo'`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "data source replay, target=/foo: output exceeds limit of 30 bytes")
+
+	imp = NewDataSourceImporter(replay{}, 3)
+	vm = jsonnet.MakeVM()
+	vm.Importer(NewCompositeImporter(imp))
+	_, err = vm.EvaluateAnonymousSnippet("test.jsonnet", `importstr 'data://replay/foo'`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "data source replay, target=/foo: output exceeds limit of 3 bytes")
+}
@@ -0,0 +1,86 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package importers
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/google/go-jsonnet"
+)
+
+// LibraryPrefix is the reserved import prefix for libraries backed by virtual file systems.
+const LibraryPrefix = "qbec-lib/"
+
+// FSImporter implements an importer for a library backed by a virtual file system, such as an embedded
+// file system or a zip file. It processes imports of the form
+//
+//	qbec-lib/{name}/{path-in-file-system}
+//
+// as well as relative imports made from files in the library.
+type FSImporter struct {
+	name   string
+	prefix string
+	fsys   fs.FS
+	cache  map[string]*sourceEntry
+}
+
+// NewFSImporter returns an importer for the named library backed by the supplied file system.
+func NewFSImporter(name string, fsys fs.FS) *FSImporter {
+	return &FSImporter{
+		name:   name,
+		prefix: LibraryPrefix + name + "/",
+		fsys:   fsys,
+		cache:  map[string]*sourceEntry{},
+	}
+}
+
+// CanProcess implements the interface method.
+func (f *FSImporter) CanProcess(importedPath string) bool {
+	return strings.HasPrefix(importedPath, f.prefix)
+}
+
+// CanProcessFrom returns true if the supplied path is a relative import from a file in the library.
+func (f *FSImporter) CanProcessFrom(importedFrom, importedPath string) bool {
+	return strings.HasPrefix(importedFrom, f.prefix) && !path.IsAbs(importedPath) && !strings.Contains(importedPath, ":")
+}
+
+// Import implements the interface method.
+func (f *FSImporter) Import(importedFrom, importedPath string) (contents jsonnet.Contents, foundAt string, err error) {
+	full := importedPath
+	if !f.CanProcess(importedPath) {
+		dir, _ := path.Split(importedFrom)
+		full = path.Join(dir, importedPath)
+		if !f.CanProcess(full) {
+			return contents, foundAt, fmt.Errorf("import %s from %s is outside library %s", importedPath, importedFrom, f.name)
+		}
+	}
+	if entry, ok := f.cache[full]; ok {
+		return entry.contents, entry.foundAt, entry.err
+	}
+	entry := &sourceEntry{foundAt: full}
+	b, err := fs.ReadFile(f.fsys, strings.TrimPrefix(full, f.prefix))
+	if err != nil {
+		entry.err = fmt.Errorf("library %s: %v", f.name, err)
+	} else {
+		entry.contents = jsonnet.MakeContents(string(b))
+	}
+	f.cache[full] = entry
+	return entry.contents, entry.foundAt, entry.err
+}
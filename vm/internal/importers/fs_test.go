@@ -0,0 +1,62 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package importers
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-jsonnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFSImporter(t *testing.T) {
+	lib := fstest.MapFS{
+		"main.libsonnet":        {Data: []byte(`{ util: import 'util/util.libsonnet', data: importstr 'data.txt' }`)},
+		"util/util.libsonnet":   {Data: []byte(`{ greet(s):: 'hello ' + s, version: (import '../version.json').version }`)},
+		"version.json":          {Data: []byte(`{ "version": "1.0" }`)},
+		"data.txt":              {Data: []byte(`some data`)},
+		"util/escape.libsonnet": {Data: []byte(`import '../../other/x.libsonnet'`)},
+	}
+	vm := jsonnet.MakeVM()
+	fi := NewFSImporter("k8s", lib)
+	vm.Importer(NewCompositeImporter(fi, NewFileImporter(&jsonnet.FileImporter{})))
+
+	a := assert.New(t)
+	a.True(fi.CanProcess("qbec-lib/k8s/main.libsonnet"))
+	a.False(fi.CanProcess("qbec-lib/other/main.libsonnet"))
+	a.False(fi.CanProcess("main.libsonnet"))
+	a.True(fi.CanProcessFrom("qbec-lib/k8s/main.libsonnet", "util.libsonnet"))
+	a.False(fi.CanProcessFrom("components/foo.jsonnet", "util.libsonnet"))
+	a.False(fi.CanProcessFrom("qbec-lib/k8s/main.libsonnet", "data://foo"))
+
+	out, err := vm.EvaluateAnonymousSnippet("test.jsonnet", `
+local lib = import 'qbec-lib/k8s/main.libsonnet';
+{ greeting: lib.util.greet('world'), version: lib.util.version, data: lib.data }
+`)
+	require.NoError(t, err)
+	a.JSONEq(`{"greeting": "hello world", "version": "1.0", "data": "some data"}`, out)
+
+	_, err = vm.EvaluateAnonymousSnippet("test.jsonnet", `import 'qbec-lib/k8s/missing.libsonnet'`)
+	require.Error(t, err)
+	a.Contains(err.Error(), "library k8s: open missing.libsonnet: file does not exist")
+
+	_, err = vm.EvaluateAnonymousSnippet("test.jsonnet", `import 'qbec-lib/k8s/util/escape.libsonnet'`)
+	require.Error(t, err)
+	a.Contains(err.Error(), "import ../../other/x.libsonnet from qbec-lib/k8s/util/escape.libsonnet is outside library k8s")
+}
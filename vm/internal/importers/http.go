@@ -0,0 +1,238 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package importers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/go-jsonnet"
+)
+
+// HTTPLibPath is a base URL from which jsonnet files are imported, along with optional sha256 digests
+// of files keyed by their path relative to the URL.
+type HTTPLibPath struct {
+	URL    string
+	SHA256 map[string]string
+}
+
+// isURL returns true if the supplied import path is an http(s) URL.
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// HTTPFetcher fetches files under HTTP library paths. Files that have a sha256 digest are verified and cached on
+// disk by digest such that they are only downloaded once. Other files are cached in memory for the lifetime of
+// the fetcher. A fetcher is safe for concurrent use and is meant to be shared across VMs.
+type HTTPFetcher struct {
+	paths    []HTTPLibPath
+	cacheDir string
+	client   *http.Client
+	l        sync.Mutex // protects the cache map, not held while files are downloaded
+	cache    map[string]*httpEntry
+}
+
+// httpEntry is the cached result of loading a URL. Callers that find an entry whose download is in flight wait
+// for it to complete instead of downloading the same file again.
+type httpEntry struct {
+	ready chan struct{} // closed once the source is set
+	src   *sourceEntry
+}
+
+// NewHTTPFetcher returns a fetcher for the supplied library paths that uses the supplied cache directory
+// and HTTP client. No disk cache is used when the directory is blank.
+func NewHTTPFetcher(paths []HTTPLibPath, cacheDir string, client *http.Client) *HTTPFetcher {
+	var ps []HTTPLibPath
+	for _, p := range paths {
+		if !strings.HasSuffix(p.URL, "/") {
+			p.URL += "/"
+		}
+		ps = append(ps, p)
+	}
+	return &HTTPFetcher{paths: ps, cacheDir: cacheDir, client: client, cache: map[string]*httpEntry{}}
+}
+
+// libPathFor returns the library path under which the supplied URL falls.
+func (f *HTTPFetcher) libPathFor(u string) (HTTPLibPath, bool) {
+	for _, p := range f.paths {
+		if strings.HasPrefix(u, p.URL) {
+			return p, true
+		}
+	}
+	return HTTPLibPath{}, false
+}
+
+func (f *HTTPFetcher) cacheFile(digest string) string {
+	return filepath.Join(f.cacheDir, digest)
+}
+
+// download returns the contents of the supplied URL. It returns os.ErrNotExist when the server returns a 404.
+func (f *HTTPFetcher) download(u string) ([]byte, error) {
+	res, err := f.client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, os.ErrNotExist
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned %d", u, res.StatusCode)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// load returns the contents of the supplied URL, verifying and caching it if a digest is known for it.
+func (f *HTTPFetcher) load(u string) ([]byte, error) {
+	lp, ok := f.libPathFor(u)
+	if !ok {
+		return nil, fmt.Errorf("%s is not under any HTTP library path", u)
+	}
+	digest := lp.SHA256[strings.TrimPrefix(u, lp.URL)]
+	if digest != "" && f.cacheDir != "" {
+		if b, err := ioutil.ReadFile(f.cacheFile(digest)); err == nil && sha256Hex(b) == digest {
+			return b, nil
+		}
+	}
+	b, err := f.download(u)
+	if err != nil {
+		return nil, err
+	}
+	if digest == "" {
+		return b, nil
+	}
+	if actual := sha256Hex(b); actual != digest {
+		return nil, fmt.Errorf("sha256 mismatch for %s, want %s, got %s", u, digest, actual)
+	}
+	if f.cacheDir != "" {
+		if err := os.MkdirAll(f.cacheDir, 0755); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(f.cacheFile(digest), b, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// fetch returns the cached entry for the supplied URL, loading it if needed. Different URLs are loaded
+// concurrently and a URL that is being loaded by another caller is waited for.
+func (f *HTTPFetcher) fetch(u string) *sourceEntry {
+	f.l.Lock()
+	e, ok := f.cache[u]
+	if !ok {
+		e = &httpEntry{ready: make(chan struct{})}
+		f.cache[u] = e
+	}
+	f.l.Unlock()
+	if ok {
+		<-e.ready
+		return e.src
+	}
+	src := &sourceEntry{foundAt: u}
+	b, err := f.load(u)
+	if err != nil {
+		src.err = err
+	} else {
+		src.contents = jsonnet.MakeContents(string(b))
+	}
+	e.src = src
+	close(e.ready)
+	return src
+}
+
+// search looks for the supplied relative path under every library path in order and returns the first
+// entry found, or nil if no library path has it.
+func (f *HTTPFetcher) search(p string) *sourceEntry {
+	for _, lp := range f.paths {
+		entry := f.fetch(lp.URL + p)
+		if entry.err == os.ErrNotExist {
+			continue
+		}
+		return entry
+	}
+	return nil
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// HTTPImporter imports files from HTTP library paths in addition to local files. It processes
+//
+//   - imports of URLs under a library path
+//   - relative imports made from files that were fetched from a library path
+//   - any other path, that is looked up as a local file first and then under every library path in order
+//
+// and must therefore be the last importer.
+type HTTPImporter struct {
+	fetcher *HTTPFetcher
+	local   *ExtendedFileImporter
+}
+
+// NewHTTPImporter returns an importer that uses the supplied fetcher for remote files and the supplied
+// file importer for local files.
+func NewHTTPImporter(fetcher *HTTPFetcher, local *ExtendedFileImporter) *HTTPImporter {
+	return &HTTPImporter{fetcher: fetcher, local: local}
+}
+
+// CanProcess implements the interface method.
+func (h *HTTPImporter) CanProcess(_ string) bool {
+	return true
+}
+
+// Import implements the interface method.
+func (h *HTTPImporter) Import(importedFrom, importedPath string) (contents jsonnet.Contents, foundAt string, err error) {
+	var entry *sourceEntry
+	switch {
+	case isURL(importedPath):
+		entry = h.fetcher.fetch(importedPath)
+	case isURL(importedFrom):
+		base, err := url.Parse(importedFrom)
+		if err != nil {
+			return contents, foundAt, err
+		}
+		ref, err := url.Parse(importedPath)
+		if err != nil {
+			return contents, foundAt, err
+		}
+		entry = h.fetcher.fetch(base.ResolveReference(ref).String())
+	default:
+		contents, foundAt, err = h.local.Import(importedFrom, importedPath)
+		if err == nil || path.IsAbs(importedPath) {
+			return contents, foundAt, err
+		}
+		if entry = h.fetcher.search(importedPath); entry == nil {
+			return contents, foundAt, err
+		}
+	}
+	if entry.err == os.ErrNotExist {
+		return contents, foundAt, fmt.Errorf("%s: not found", entry.foundAt)
+	}
+	return entry.contents, entry.foundAt, entry.err
+}
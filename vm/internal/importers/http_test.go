@@ -0,0 +1,186 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package importers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-jsonnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, files map[string]string) (*httptest.Server, *int32) {
+	var count int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		contents, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(contents))
+	}))
+	t.Cleanup(s.Close)
+	return s, &count
+}
+
+func newHTTPTestVM(f *HTTPFetcher, jpaths ...string) *jsonnet.VM {
+	vm := jsonnet.MakeVM()
+	vm.Importer(NewCompositeImporter(NewHTTPImporter(f, NewFileImporter(&jsonnet.FileImporter{JPaths: jpaths}))))
+	return vm
+}
+
+func TestHTTPImporter(t *testing.T) {
+	s, _ := newTestServer(t, map[string]string{
+		"/v1/main.libsonnet":      `{ util: import 'util/util.libsonnet', data: importstr 'data.txt' }`,
+		"/v1/util/util.libsonnet": `{ greet(s):: 'hello ' + s, version: (import '../version.json').version }`,
+		"/v1/version.json":        `{ "version": "1.0" }`,
+		"/v1/data.txt":            `some data`,
+		"/v2/other.libsonnet":     `{ other: true }`,
+	})
+	f := NewHTTPFetcher([]HTTPLibPath{{URL: s.URL + "/v1"}, {URL: s.URL + "/v2/"}}, "", s.Client())
+	vm := newHTTPTestVM(f)
+	a := assert.New(t)
+
+	out, err := vm.EvaluateAnonymousSnippet("test.jsonnet", `
+local lib = import '`+s.URL+`/v1/main.libsonnet';
+{ greeting: lib.util.greet('world'), version: lib.util.version, data: lib.data }
+`)
+	require.NoError(t, err)
+	a.JSONEq(`{"greeting": "hello world", "version": "1.0", "data": "some data"}`, out)
+
+	out, err = vm.EvaluateAnonymousSnippet("test.jsonnet", `{ v: (import 'main.libsonnet').util.version, o: (import 'other.libsonnet').other }`)
+	require.NoError(t, err)
+	a.JSONEq(`{"v": "1.0", "o": true}`, out)
+
+	_, err = vm.EvaluateAnonymousSnippet("test.jsonnet", `import 'missing.libsonnet'`)
+	require.Error(t, err)
+	a.Contains(err.Error(), "couldn't open import \"missing.libsonnet\"")
+
+	_, err = vm.EvaluateAnonymousSnippet("test.jsonnet", `import '`+s.URL+`/v1/missing.libsonnet'`)
+	require.Error(t, err)
+	a.Contains(err.Error(), s.URL+"/v1/missing.libsonnet: not found")
+
+	_, err = vm.EvaluateAnonymousSnippet("test.jsonnet", `import '`+s.URL+`/v3/main.libsonnet'`)
+	require.Error(t, err)
+	a.Contains(err.Error(), s.URL+"/v3/main.libsonnet is not under any HTTP library path")
+}
+
+func TestHTTPImporterLocalFirst(t *testing.T) {
+	s, count := newTestServer(t, map[string]string{
+		"/lib/k.libsonnet": `{ source: 'remote' }`,
+	})
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "k.libsonnet"), []byte(`{ source: 'local' }`), 0644))
+	f := NewHTTPFetcher([]HTTPLibPath{{URL: s.URL + "/lib"}}, "", s.Client())
+	vm := newHTTPTestVM(f, dir)
+	out, err := vm.EvaluateAnonymousSnippet("test.jsonnet", `(import 'k.libsonnet').source`)
+	require.NoError(t, err)
+	assert.Equal(t, "\"local\"\n", out)
+	assert.Equal(t, int32(0), atomic.LoadInt32(count))
+}
+
+func TestHTTPImporterPinned(t *testing.T) {
+	contents := `{ pinned: true }`
+	digest := sha256Hex([]byte(contents))
+	otherDigest := sha256Hex([]byte(`{ pinned: 'other' }`))
+	s, count := newTestServer(t, map[string]string{
+		"/lib/k.libsonnet":   contents,
+		"/lib/bad.libsonnet": `{ pinned: false }`,
+	})
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	paths := []HTTPLibPath{{URL: s.URL + "/lib", SHA256: map[string]string{"k.libsonnet": digest, "bad.libsonnet": otherDigest}}}
+
+	vm := newHTTPTestVM(NewHTTPFetcher(paths, cacheDir, s.Client()))
+	out, err := vm.EvaluateAnonymousSnippet("test.jsonnet", `(import 'k.libsonnet').pinned`)
+	require.NoError(t, err)
+	assert.Equal(t, "true\n", out)
+	assert.Equal(t, int32(1), atomic.LoadInt32(count))
+	b, err := ioutil.ReadFile(filepath.Join(cacheDir, digest))
+	require.NoError(t, err)
+	assert.Equal(t, contents, string(b))
+
+	// a new fetcher uses the disk cache
+	vm = newHTTPTestVM(NewHTTPFetcher(paths, cacheDir, s.Client()))
+	_, err = vm.EvaluateAnonymousSnippet("test.jsonnet", `(import 'k.libsonnet').pinned`)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(count))
+
+	// a corrupt cache entry is downloaded again
+	require.NoError(t, ioutil.WriteFile(filepath.Join(cacheDir, digest), []byte("corrupt"), 0644))
+	vm = newHTTPTestVM(NewHTTPFetcher(paths, cacheDir, s.Client()))
+	_, err = vm.EvaluateAnonymousSnippet("test.jsonnet", `(import 'k.libsonnet').pinned`)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(count))
+
+	_, err = vm.EvaluateAnonymousSnippet("test.jsonnet", `import 'bad.libsonnet'`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sha256 mismatch for "+s.URL+"/lib/bad.libsonnet, want "+otherDigest)
+}
+
+func TestHTTPFetcherServerError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer s.Close()
+	f := NewHTTPFetcher([]HTTPLibPath{{URL: s.URL}}, "", s.Client())
+	entry := f.fetch(s.URL + "/k.libsonnet")
+	require.Error(t, entry.err)
+	assert.Equal(t, "GET "+s.URL+"/k.libsonnet returned 500", entry.err.Error())
+	assert.NotEqual(t, os.ErrNotExist, entry.err)
+}
+
+func TestHTTPFetcherConcurrent(t *testing.T) {
+	release := make(chan struct{})
+	var slowCount int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow.libsonnet" {
+			atomic.AddInt32(&slowCount, 1)
+			<-release
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer s.Close()
+	f := NewHTTPFetcher([]HTTPLibPath{{URL: s.URL}}, "", s.Client())
+
+	var wg sync.WaitGroup
+	entries := make([]*sourceEntry, 2)
+	for i := range entries {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			entries[i] = f.fetch(s.URL + "/slow.libsonnet")
+		}(i)
+	}
+	// other files are fetched while the slow file is being downloaded
+	entry := f.fetch(s.URL + "/fast.libsonnet")
+	require.NoError(t, entry.err)
+	close(release)
+	wg.Wait()
+	a := assert.New(t)
+	a.EqualValues(1, atomic.LoadInt32(&slowCount))
+	a.NoError(entries[0].err)
+	a.Same(entries[0], entries[1])
+}
@@ -0,0 +1,49 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package importers
+
+import (
+	_ "embed" // for the standard library
+
+	"github.com/google/go-jsonnet"
+)
+
+// StdlibPath is the import path for the qbec standard library.
+const StdlibPath = "qbec://std"
+
+//go:embed stdlib/qbec.libsonnet
+var stdlibSource string
+
+// StdlibImporter implements an importer for the qbec standard library that is embedded in the binary.
+type StdlibImporter struct {
+	contents jsonnet.Contents
+}
+
+// NewStdlibImporter returns an importer for the qbec standard library.
+func NewStdlibImporter() *StdlibImporter {
+	return &StdlibImporter{contents: jsonnet.MakeContents(stdlibSource)}
+}
+
+// CanProcess implements the interface method.
+func (s *StdlibImporter) CanProcess(importedPath string) bool {
+	return importedPath == StdlibPath
+}
+
+// Import implements the interface method.
+func (s *StdlibImporter) Import(_, _ string) (contents jsonnet.Contents, foundAt string, err error) {
+	return s.contents, StdlibPath, nil
+}
@@ -0,0 +1,76 @@
+// qbec standard library, embedded in the qbec binary and imported as 'qbec://std'.
+// The version is bumped whenever helpers are added or changed.
+{
+  version: '1.1.0',
+
+  // env returns the name of the environment for which components are being evaluated.
+  env():: std.extVar('qbec.io/env'),
+
+  // tag returns the GC tag in effect, or null if none was specified.
+  tag():: std.extVar('qbec.io/tag'),
+
+  // defaultNs returns the default namespace of the environment.
+  defaultNs():: std.extVar('qbec.io/defaultNs'),
+
+  // cleanMode returns true if components are being evaluated in clean mode.
+  cleanMode():: std.extVar('qbec.io/cleanMode') == 'on',
+
+  // envProperties returns the properties object defined for the environment.
+  envProperties():: std.extVar('qbec.io/envProperties'),
+
+  // property returns the environment property at the supplied dotted path, or the default value when any
+  // element of the path is missing.
+  property(path, default=null)::
+    local walk(obj, keys) =
+      if std.length(keys) == 0 then obj
+      else if std.isObject(obj) && std.objectHas(obj, keys[0]) then walk(obj[keys[0]], keys[1:])
+      else default;
+    walk($.envProperties(), std.split(path, '.')),
+
+  // standardLabels returns the recommended kubernetes labels for the supplied app name and optional
+  // component, version and partOf values.
+  standardLabels(name, component=null, version=null, partOf=null)::
+    { 'app.kubernetes.io/name': name, 'app.kubernetes.io/managed-by': 'qbec' } +
+    (if component != null then { 'app.kubernetes.io/component': component } else {}) +
+    (if version != null then { 'app.kubernetes.io/version': version } else {}) +
+    (if partOf != null then { 'app.kubernetes.io/part-of': partOf } else {}),
+
+  // isObject returns true if the supplied value looks like a kubernetes object.
+  isObject(o):: std.isObject(o) && std.objectHas(o, 'apiVersion') && std.objectHas(o, 'kind'),
+
+  // flatten returns an array of kubernetes objects found in the supplied value, which may be an object,
+  // an array, or an arbitrarily nested combination of the two. Kubernetes lists are expanded into their items
+  // and null values are dropped.
+  flatten(v)::
+    if v == null then []
+    else if std.isArray(v) then std.flattenArrays([$.flatten(x) for x in v])
+    else if $.isObject(v) then
+      if std.endsWith(v.kind, 'List') && std.objectHas(v, 'items') then $.flatten(v.items) else [v]
+    else if std.isObject(v) then std.flattenArrays([$.flatten(v[k]) for k in std.objectFields(v)])
+    else error 'flatten: unexpected value of type ' + std.type(v),
+
+  // mapObjects applies the supplied function to every kubernetes object found in the supplied value and
+  // returns a flat array of the results.
+  mapObjects(fn, v):: [fn(o) for o in $.flatten(v)],
+
+  // withLabels returns the supplied object with the labels merged into its metadata.
+  withLabels(obj, labels):: obj { metadata+: { labels+: labels } },
+
+  // withAnnotations returns the supplied object with the annotations merged into its metadata.
+  withAnnotations(obj, annotations):: obj { metadata+: { annotations+: annotations } },
+
+  // labelsMatch returns true if the labels of the supplied object match the label selector string.
+  labelsMatch(obj, selector)::
+    local labels = if std.objectHas(obj, 'metadata') then std.get(obj.metadata, 'labels', {}) else {};
+    std.native('labelsMatchSelector')(labels, selector),
+
+  // wrappers for native functions provided by qbec.
+  parseJson(str):: std.native('parseJson')(str),
+  parseYaml(str):: std.native('parseYaml')(str),
+  renderYaml(data):: std.native('renderYaml')(data),
+  escapeStringRegex(str):: std.native('escapeStringRegex')(str),
+  regexMatch(regex, str):: std.native('regexMatch')(regex, str),
+  regexSubst(regex, src, repl):: std.native('regexSubst')(regex, src, repl),
+  deepMerge(base, overlay, options={}):: std.native('deepMerge')(base, overlay, options),
+  expandHelmTemplate(chart, values, options):: std.native('expandHelmTemplate')(chart, values, options),
+}
@@ -0,0 +1,91 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package importers
+
+import (
+	"testing"
+
+	"github.com/google/go-jsonnet"
+	"github.com/splunk/qbec/vm/internal/natives"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStdlibImporter(t *testing.T) {
+	vm := jsonnet.MakeVM()
+	natives.Register(vm)
+	vm.ExtVar("qbec.io/env", "dev")
+	vm.ExtVar("qbec.io/defaultNs", "my-ns")
+	vm.ExtVar("qbec.io/cleanMode", "off")
+	vm.ExtCode("qbec.io/tag", "null")
+	vm.ExtCode("qbec.io/envProperties", `{ db: { host: 'db.dev', port: 5432 } }`)
+	si := NewStdlibImporter()
+	vm.Importer(NewCompositeImporter(si, NewFileImporter(&jsonnet.FileImporter{})))
+
+	a := assert.New(t)
+	a.True(si.CanProcess("qbec://std"))
+	a.False(si.CanProcess("qbec://std/foo.libsonnet"))
+	a.False(si.CanProcess("std.libsonnet"))
+
+	out, err := vm.EvaluateAnonymousSnippet("test.jsonnet", `
+local q = import 'qbec://std';
+local cm = { apiVersion: 'v1', kind: 'ConfigMap', metadata: { name: 'cm', labels: { app: 'foo' } } };
+local objects = {
+  a: cm,
+  b: [ null, { apiVersion: 'v1', kind: 'List', items: [ cm { metadata+: { name: 'cm2' } } ] } ],
+};
+{
+  version: q.version,
+  env: q.env(),
+  ns: q.defaultNs(),
+  tag: q.tag(),
+  clean: q.cleanMode(),
+  host: q.property('db.host'),
+  missing: q.property('db.user', 'admin'),
+  labels: q.standardLabels('foo', component='web'),
+  names: [ o.metadata.name for o in q.flatten(objects) ],
+  labeled: q.withLabels(cm, { team: 'x' }).metadata.labels,
+  annotated: q.withAnnotations(cm, { note: 'y' }).metadata.annotations,
+  match: q.labelsMatch(cm, 'app=foo'),
+  noMatch: q.labelsMatch({ metadata: {} }, 'app=foo'),
+  parsed: q.parseYaml('a: 1'),
+  merged: q.deepMerge({ a: [1] }, { a: [2] }, { arrays: 'append' }),
+}
+`)
+	require.NoError(t, err)
+	a.JSONEq(`{
+  "version": "1.1.0",
+  "env": "dev",
+  "ns": "my-ns",
+  "tag": null,
+  "clean": false,
+  "host": "db.dev",
+  "missing": "admin",
+  "labels": { "app.kubernetes.io/name": "foo", "app.kubernetes.io/managed-by": "qbec", "app.kubernetes.io/component": "web" },
+  "names": [ "cm", "cm2" ],
+  "labeled": { "app": "foo", "team": "x" },
+  "annotated": { "note": "y" },
+  "match": true,
+  "noMatch": false,
+  "parsed": [ { "a": 1 } ],
+  "merged": { "a": [1, 2] }
+}`, out)
+
+	_, err = vm.EvaluateAnonymousSnippet("test.jsonnet", `(import 'qbec://std').flatten({ a: 'foo' })`)
+	require.Error(t, err)
+	a.Contains(err.Error(), "flatten: unexpected value of type string")
+}
@@ -18,7 +18,6 @@ package natives
 
 import (
 	"bytes"
-	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -26,6 +25,7 @@ import (
 
 	"github.com/ghodss/yaml"
 	"github.com/pkg/errors"
+	"github.com/splunk/qbec/internal/sio"
 )
 
 // helmOptions are options that can be passed to the helm template command as well
@@ -98,16 +98,18 @@ func expandHelmTemplate(chart string, values map[string]interface{}, options hel
 	cmd := exec.Command("helm", args...)
 	cmd.Stdin = bytes.NewBuffer(valueBytes)
 	cmd.Stdout = &stdout
-	cmd.Stderr = os.Stderr
+	stderr := sio.NewLineWriter("helm")
+	cmd.Stderr = stderr
+	defer stderr.Flush()
 	cmd.Dir = workDir
 
 	if options.Verbose {
-		fmt.Fprintf(os.Stderr, "[helm template] cd %s && helm %s\n", workDir, strings.Join(args, " "))
+		sio.Debugf("[helm template] cd %s && helm %s\n", workDir, strings.Join(args, " "))
 	}
 
 	if err := cmd.Run(); err != nil {
 		if options.ThisFile == "" {
-			fmt.Fprintln(os.Stderr, "[WARN] helm template command failed, you may need to set the 'thisFile' option to make relative chart paths work")
+			sio.Warnln("helm template command failed, you may need to set the 'thisFile' option to make relative chart paths work")
 		}
 		return nil, errors.Wrap(err, "run helm template command")
 	}
@@ -0,0 +1,151 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package natives
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// array merge strategies for arrays that do not have a merge key.
+const (
+	arraysReplace = "replace"
+	arraysAppend  = "append"
+)
+
+// mergeOptions controls how arrays are merged by the deepMerge native function.
+type mergeOptions struct {
+	Arrays    string            `json:"arrays"`    // strategy for arrays without merge keys, "replace" (default) or "append"
+	MergeKeys map[string]string `json:"mergeKeys"` // map of attribute names to the key used to merge array elements by identity
+}
+
+func (m mergeOptions) validate() error {
+	switch m.Arrays {
+	case "", arraysReplace, arraysAppend:
+		return nil
+	default:
+		return fmt.Errorf("invalid array strategy '%s', must be one of '%s' or '%s'", m.Arrays, arraysReplace, arraysAppend)
+	}
+}
+
+// deepMerge merges the overlay into the base using RFC7386 semantics for objects. That is, objects are merged
+// recursively, null values in the overlay delete the corresponding key from the base, and all other values in the
+// overlay replace those in the base. Arrays for attributes that have a merge key are merged element-wise such that
+// overlay elements with the same key value as a base element are merged into it and the rest are appended.
+// Other arrays are replaced or appended based on the options.
+func deepMerge(base, overlay interface{}, opts mergeOptions) (interface{}, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	return opts.merge("", base, overlay)
+}
+
+func (m mergeOptions) merge(path string, base, overlay interface{}) (interface{}, error) {
+	switch o := overlay.(type) {
+	case map[string]interface{}:
+		b, ok := base.(map[string]interface{})
+		if !ok {
+			b = map[string]interface{}{}
+		}
+		out := map[string]interface{}{}
+		for k, v := range b {
+			out[k] = v
+		}
+		for k, v := range o {
+			if v == nil {
+				delete(out, k)
+				continue
+			}
+			merged, err := m.merge(path+"."+k, out[k], v)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = merged
+		}
+		return out, nil
+	case []interface{}:
+		b, ok := base.([]interface{})
+		if !ok {
+			return o, nil
+		}
+		if key := m.MergeKeys[lastSegment(path)]; path != "" && key != "" {
+			return m.mergeByKey(path, key, b, o)
+		}
+		if m.Arrays == arraysAppend {
+			return append(append([]interface{}{}, b...), o...), nil
+		}
+		return o, nil
+	default:
+		return overlay, nil
+	}
+}
+
+func (m mergeOptions) mergeByKey(path, key string, base, overlay []interface{}) (interface{}, error) {
+	keyOf := func(v interface{}, which string, index int) (interface{}, error) {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: %s element %d is a %v, not an object, cannot merge by key '%s'", path[1:], which, index, reflect.TypeOf(v), key)
+		}
+		k, ok := obj[key]
+		if !ok {
+			return nil, fmt.Errorf("%s: %s element %d does not have merge key '%s'", path[1:], which, index, key)
+		}
+		switch k.(type) {
+		case string, float64, bool:
+			return k, nil
+		default:
+			return nil, fmt.Errorf("%s: %s element %d has a merge key '%s' of type %v, must be a string, number or boolean", path[1:], which, index, key, reflect.TypeOf(k))
+		}
+	}
+	out := append([]interface{}{}, base...)
+	positions := map[interface{}]int{}
+	for i, v := range base {
+		k, err := keyOf(v, "base", i)
+		if err != nil {
+			return nil, err
+		}
+		positions[k] = i
+	}
+	for i, v := range overlay {
+		k, err := keyOf(v, "overlay", i)
+		if err != nil {
+			return nil, err
+		}
+		pos, ok := positions[k]
+		if !ok {
+			positions[k] = len(out)
+			out = append(out, v)
+			continue
+		}
+		merged, err := m.merge(fmt.Sprintf("%s[%v]", path, k), out[pos], v)
+		if err != nil {
+			return nil, err
+		}
+		out[pos] = merged
+	}
+	return out, nil
+}
+
+// lastSegment returns the attribute name at the end of the supplied path.
+func lastSegment(path string) string {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == '.' {
+			return path[i+1:]
+		}
+	}
+	return path
+}
@@ -0,0 +1,114 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package natives
+
+import (
+	"testing"
+
+	"github.com/google/go-jsonnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeepMerge(t *testing.T) {
+	vm := jsonnet.MakeVM()
+	Register(vm)
+	tests := []struct {
+		name     string
+		code     string
+		expected string
+	}{
+		{
+			name:     "rfc7386",
+			code:     `std.native('deepMerge')({ a: 1, b: { c: 2, d: 3 }, e: [1, 2] }, { a: 10, b: { c: null, x: 1 }, e: [3] }, null)`,
+			expected: `{ "a": 10, "b": { "d": 3, "x": 1 }, "e": [3] }`,
+		},
+		{
+			name:     "append",
+			code:     `std.native('deepMerge')({ e: [1, 2] }, { e: [3] }, { arrays: 'append' })`,
+			expected: `{ "e": [1, 2, 3] }`,
+		},
+		{
+			name:     "non-object-base",
+			code:     `std.native('deepMerge')({ a: 'foo' }, { a: { b: 1 } }, {})`,
+			expected: `{ "a": { "b": 1 } }`,
+		},
+		{
+			name: "merge-keys",
+			code: `std.native('deepMerge')(
+				{ containers: [ { name: 'web', image: 'web:1', env: [ { name: 'A', value: '1' } ] }, { name: 'side', image: 'side:1' } ] },
+				{ containers: [ { name: 'web', image: 'web:2', env: [ { name: 'B', value: '2' }, { name: 'A', value: '3' } ] }, { name: 'extra', image: 'extra:1' } ] },
+				{ mergeKeys: { containers: 'name', env: 'name' } },
+			)`,
+			expected: `{ "containers": [
+				{ "name": "web", "image": "web:2", "env": [ { "name": "A", "value": "3" }, { "name": "B", "value": "2" } ] },
+				{ "name": "side", "image": "side:1" },
+				{ "name": "extra", "image": "extra:1" }
+			] }`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := vm.EvaluateAnonymousSnippet("test.jsonnet", test.code)
+			require.NoError(t, err)
+			assert.JSONEq(t, test.expected, out)
+		})
+	}
+}
+
+func TestDeepMergeNegative(t *testing.T) {
+	vm := jsonnet.MakeVM()
+	Register(vm)
+	tests := []struct {
+		name   string
+		code   string
+		errMsg string
+	}{
+		{
+			name:   "bad-strategy",
+			code:   `std.native('deepMerge')({}, {}, { arrays: 'merge' })`,
+			errMsg: "invalid array strategy 'merge', must be one of 'replace' or 'append'",
+		},
+		{
+			name:   "bad-options",
+			code:   `std.native('deepMerge')({}, {}, 'foo')`,
+			errMsg: "invalid options type, string, want a map",
+		},
+		{
+			name:   "missing-key",
+			code:   `std.native('deepMerge')({ ports: [ { port: 80 } ] }, { ports: [ { name: 'http' } ] }, { mergeKeys: { ports: 'port' } })`,
+			errMsg: "ports: overlay element 0 does not have merge key 'port'",
+		},
+		{
+			name:   "not-object",
+			code:   `std.native('deepMerge')({ ports: [ 80 ] }, { ports: [ 81 ] }, { mergeKeys: { ports: 'port' } })`,
+			errMsg: "ports: base element 0 is a float64, not an object, cannot merge by key 'port'",
+		},
+		{
+			name:   "bad-key-type",
+			code:   `std.native('deepMerge')({ ports: [ { port: {} } ] }, { ports: [] }, { mergeKeys: { ports: 'port' } })`,
+			errMsg: "ports: base element 0 has a merge key 'port' of type map[string]interface {}, must be a string, number or boolean",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := vm.EvaluateAnonymousSnippet("test.jsonnet", test.code)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.errMsg)
+		})
+	}
+}
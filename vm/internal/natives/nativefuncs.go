@@ -31,6 +31,10 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 )
 
+// ExternalStateFuncs are the names of native functions whose output depends on state outside the jsonnet
+// program, such as the files of a helm chart.
+var ExternalStateFuncs = []string{"expandHelmTemplate"}
+
 // Register adds qbec's native jsonnet functions to the provided VM
 func Register(vm *jsonnet.VM) {
 	// NB: libjsonnet native functions can only pass primitive
@@ -122,6 +126,28 @@ func Register(vm *jsonnet.VM) {
 		},
 	})
 
+	vm.NativeFunction(&jsonnet.NativeFunction{
+		Name:   "deepMerge",
+		Params: []ast.Identifier{"base", "overlay", "options"},
+		Func: func(args []interface{}) (res interface{}, err error) {
+			var opts mergeOptions
+			if args[2] != nil {
+				options, ok := args[2].(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("invalid options type, %v, want a map", reflect.TypeOf(args[2]))
+				}
+				b, err := json.Marshal(options)
+				if err != nil {
+					return nil, errors.Wrap(err, "marshal options to JSON")
+				}
+				if err := json.Unmarshal(b, &opts); err != nil {
+					return nil, errors.Wrap(err, "unmarshal options from JSON")
+				}
+			}
+			return deepMerge(args[0], args[1], opts)
+		},
+	})
+
 	vm.NativeFunction(&jsonnet.NativeFunction{
 		Name:   "labelsMatchSelector",
 		Params: []ast.Identifier{"labels", "selectorString"},
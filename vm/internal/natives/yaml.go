@@ -17,6 +17,7 @@
 package natives
 
 import (
+	"encoding/json"
 	"io"
 
 	v3yaml "gopkg.in/yaml.v3"
@@ -43,6 +44,44 @@ func ParseYAMLDocuments(reader io.Reader) ([]interface{}, error) {
 	return ret, nil
 }
 
+// WriteYAMLDocumentsAsJSON parses the contents of the reader as YAML documents and writes them to the supplied
+// writer as a JSON array, one document at a time. The output is the same as that of marshaling the result of
+// ParseYAMLDocuments, without holding all documents in memory. It stops and returns the error of the first
+// write that fails.
+func WriteYAMLDocumentsAsJSON(reader io.Reader, w io.Writer) error {
+	d := yaml.NewYAMLToJSONDecoder(reader)
+	sep := "["
+	for {
+		var doc interface{}
+		if err := d.Decode(&doc); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		if doc == nil {
+			continue
+		}
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, sep); err != nil {
+			return err
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+		sep = ","
+	}
+	if sep == "[" {
+		_, err := io.WriteString(w, "[]")
+		return err
+	}
+	_, err := io.WriteString(w, "]")
+	return err
+}
+
 // RenderYAMLDocuments renders the supplied data as a series of YAML documents if the input is an array
 // or a single document when it is not. Nils are excluded from output.
 // If the caller wants an array to be rendered as a single document,
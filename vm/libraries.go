@@ -0,0 +1,72 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package vm
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/splunk/qbec/vm/internal/importers"
+)
+
+// LibraryImportPrefix is the reserved prefix for imports of libraries backed by virtual file systems.
+// A file at path p in the library named n is imported as LibraryImportPrefix + n + "/" + p.
+const LibraryImportPrefix = importers.LibraryPrefix
+
+// LoadLibraryBundle loads a library bundle from the supplied zip file. The contents of the file are read
+// into memory such that no file handles are retained.
+func LoadLibraryBundle(file string) (fs.FS, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "read library bundle")
+	}
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "open library bundle %s", file)
+	}
+	return r, nil
+}
+
+// HTTPLibPath is a base URL from which jsonnet files are imported, along with optional sha256 digests
+// of files keyed by their path relative to the URL.
+type HTTPLibPath struct {
+	URL    string
+	SHA256 map[string]string
+}
+
+// HTTPImports fetches jsonnet files from HTTP library paths. It is safe for concurrent use and should be shared
+// by all VMs such that files are only downloaded once.
+type HTTPImports struct {
+	fetcher *importers.HTTPFetcher
+}
+
+// NewHTTPImports returns HTTP imports for the supplied library paths. Files for which a digest is specified
+// are verified and cached in the supplied directory. No files are cached on disk when the directory is blank.
+func NewHTTPImports(paths []HTTPLibPath, cacheDir string) *HTTPImports {
+	var ps []importers.HTTPLibPath
+	for _, p := range paths {
+		ps = append(ps, importers.HTTPLibPath{URL: p.URL, SHA256: p.SHA256})
+	}
+	return &HTTPImports{
+		fetcher: importers.NewHTTPFetcher(ps, cacheDir, &http.Client{Timeout: time.Minute}),
+	}
+}
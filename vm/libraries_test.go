@@ -0,0 +1,78 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package vm
+
+import (
+	"archive/zip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeBundle(t *testing.T, files map[string]string) string {
+	file := filepath.Join(t.TempDir(), "bundle.zip")
+	f, err := os.Create(file)
+	require.NoError(t, err)
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		fw, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return file
+}
+
+func TestVMLibraries(t *testing.T) {
+	bundle, err := LoadLibraryBundle(writeBundle(t, map[string]string{
+		"k8s/deployment.libsonnet": `{ deployment(name):: { kind: 'Deployment', name: name, labels: (import '../labels.libsonnet') } }`,
+		"labels.libsonnet":         `{ team: 'platform' }`,
+	}))
+	require.NoError(t, err)
+	vm := New(Config{Libraries: map[string]fs.FS{
+		"bundle":   bundle,
+		"embedded": fstest.MapFS{"main.libsonnet": {Data: []byte(`{ version: '1.0' }`)}},
+	}})
+	out, err := vm.EvalCode("test.jsonnet", MakeCode(`{
+		obj: (import 'qbec-lib/bundle/k8s/deployment.libsonnet').deployment('web'),
+		version: (import 'qbec-lib/embedded/main.libsonnet').version,
+	}`), VariableSet{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"obj": {"kind": "Deployment", "name": "web", "labels": {"team": "platform"}}, "version": "1.0"}`, out)
+
+	_, err = vm.EvalCode("test.jsonnet", MakeCode(`import 'qbec-lib/unknown/main.libsonnet'`), VariableSet{})
+	require.Error(t, err)
+}
+
+func TestLoadLibraryBundleNegative(t *testing.T) {
+	_, err := LoadLibraryBundle(filepath.Join(t.TempDir(), "missing.zip"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "read library bundle")
+
+	file := filepath.Join(t.TempDir(), "bad.zip")
+	require.NoError(t, os.WriteFile(file, []byte("not a zip"), 0644))
+	_, err = LoadLibraryBundle(file)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "open library bundle "+file)
+}
@@ -0,0 +1 @@
+{ b: import 'b.libsonnet' }
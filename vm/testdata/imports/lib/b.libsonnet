@@ -0,0 +1 @@
+{ b: 'b', text: importstr 'text.txt' }
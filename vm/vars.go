@@ -50,7 +50,7 @@ func NewCodeVar(name, code string) Var {
 	if err != nil { // we'll let it fail later on in eval
 		return Var{Name: name, kind: varKindCode, value: code}
 	}
-	return Var{Name: name, kind: varKindNode, node: node}
+	return Var{Name: name, kind: varKindNode, node: node, value: code}
 }
 
 // VariableSet is an immutable set of variables to be registered with a jsonnet VM
@@ -21,10 +21,13 @@ package vm
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"sync"
+	"runtime"
+	"sort"
 
 	"github.com/google/go-jsonnet"
 	"github.com/google/go-jsonnet/linter"
@@ -50,8 +53,12 @@ func MakeSnippet(filename, s string) linter.Snippet {
 
 // Config is the configuration of the VM
 type Config struct {
-	LibPaths    []string                // library paths
-	DataSources []datasource.DataSource // data sources
+	LibPaths           []string                // library paths
+	DataSources        []datasource.DataSource // data sources
+	Warmup             int                     // number of VMs to create upfront for repeated evaluations
+	MaxDataSourceBytes int64                   // maximum size of the output of a data source for a single import, no limit when 0
+	Libraries          map[string]fs.FS        // libraries backed by virtual file systems, imported as qbec-lib/<name>/<path>
+	HTTPImports        *HTTPImports            // imports from HTTP library paths, searched after local library paths
 }
 
 // VM provides a narrow interface to the capabilities of a jsonnet VM.
@@ -60,15 +67,25 @@ type VM interface {
 	// and returns its output as a JSON string.
 	EvalFile(file string, v VariableSet) (string, error)
 	// EvalCode evaluates the supplied code initializing the VM with the supplied variables
-	// and returns its output as a JSON string.
+	// and returns its output as a JSON string. Relative imports in the code are resolved with respect
+	// to the directory of the diagnostic file.
 	EvalCode(diagnosticFile string, code Code, v VariableSet) (string, error)
 	// LintCode uses the jsonnet linter to lint the code and returns any errors
 	LintCode(linter.Snippet) error
+	// InputHash returns a hash of the supplied file, the contents of everything it transitively imports,
+	// including data source output, and the supplied variables. Evaluations of the file that have the same
+	// input hash produce the same output. ErrExternalInputs is returned when the file or its imports refer to
+	// native functions that read external state, such as expandHelmTemplate.
+	InputHash(file string, v VariableSet) (string, error)
+	// ImportGraph returns the imports made by the supplied file and by every jsonnet file that it transitively
+	// imports, keyed by the location of the importing file. Data sources imported by the files are run.
+	ImportGraph(file string) (map[string][]Import, error)
 }
 
 // vm is an implementation of VM
 type vm struct {
-	jvm *jsonnet.VM
+	jvm     *jsonnet.VM
+	imports *recordingImporter
 }
 
 // EvalFile implements the interface method.
@@ -91,7 +108,16 @@ func (v *vm) EvalFile(file string, vars VariableSet) (string, error) {
 // EvalCode implements the interface method.
 func (v *vm) EvalCode(diagnosticFile string, code Code, vars VariableSet) (string, error) {
 	vars.register(v.jvm)
-	return v.jvm.EvaluateAnonymousSnippet(diagnosticFile, code.code)
+	// parse the code with the diagnostic file set as its location so that imports are resolved relative to it
+	node, err := jsonnet.SnippetToAST(filepath.ToSlash(diagnosticFile), code.code)
+	if err != nil {
+		return "", errors.New(v.jvm.ErrorFormatter.Format(err))
+	}
+	out, err := v.jvm.Evaluate(node)
+	if err != nil {
+		return "", errors.New(v.jvm.ErrorFormatter.Format(err))
+	}
+	return out, nil
 }
 
 // LintCode implements the interface method.
@@ -114,69 +140,126 @@ func (v *vm) LintCode(snippet linter.Snippet) (outErr error) {
 	return nil
 }
 
+// vmPool is a bounded free list of VMs that retains idle VMs across garbage collections such that
+// native functions, importers and import caches are set up once and reused.
 type vmPool struct {
-	pool sync.Pool
+	config Config
+	free   chan *vm
 }
 
 func newPool(config Config) *vmPool {
-	return &vmPool{
-		pool: sync.Pool{
-			New: func() interface{} {
-				return &vm{jvm: newJsonnetVM(config)}
-			},
-		},
+	size := runtime.NumCPU()
+	if config.Warmup > size {
+		size = config.Warmup
+	}
+	p := &vmPool{
+		config: config,
+		free:   make(chan *vm, size),
+	}
+	for i := 0; i < config.Warmup; i++ {
+		p.free <- p.newVM()
+	}
+	return p
+}
+
+func (p *vmPool) newVM() *vm {
+	imports := &recordingImporter{Importer: defaultImporter(p.config), hashes: map[string]string{}, external: map[string]bool{}}
+	return &vm{jvm: newJsonnetVM(imports), imports: imports}
+}
+
+// get returns an idle VM from the pool or a new one if none are available.
+func (p *vmPool) get() *vm {
+	select {
+	case v := <-p.free:
+		return v
+	default:
+		return p.newVM()
+	}
+}
+
+// put returns a VM to the pool, discarding it if the pool is full.
+func (p *vmPool) put(v *vm) {
+	select {
+	case p.free <- v:
+	default:
 	}
 }
 
 // EvalFile implements the interface method.
-func (v *vmPool) EvalFile(file string, vars VariableSet) (string, error) {
-	vm := v.pool.Get().(*vm)
-	out, err := vm.EvalFile(file, vars)
-	v.pool.Put(vm)
-	return out, err
+func (p *vmPool) EvalFile(file string, vars VariableSet) (string, error) {
+	vm := p.get()
+	defer p.put(vm)
+	return vm.EvalFile(file, vars)
 }
 
 // EvalCode implements the interface method.
-func (v *vmPool) EvalCode(diagnosticFile string, code Code, vars VariableSet) (string, error) {
-	vm := v.pool.Get().(*vm)
-	out, err := vm.EvalCode(diagnosticFile, code, vars)
-	v.pool.Put(vm)
-	return out, err
+func (p *vmPool) EvalCode(diagnosticFile string, code Code, vars VariableSet) (string, error) {
+	vm := p.get()
+	defer p.put(vm)
+	return vm.EvalCode(diagnosticFile, code, vars)
 }
 
 // LintCode implements the interface method.
-func (v *vmPool) LintCode(snippet linter.Snippet) error {
-	vm := v.pool.Get().(*vm)
-	err := vm.LintCode(snippet)
-	v.pool.Put(vm)
-	return err
+func (p *vmPool) LintCode(snippet linter.Snippet) error {
+	vm := p.get()
+	defer p.put(vm)
+	return vm.LintCode(snippet)
+}
+
+// InputHash implements the interface method.
+func (p *vmPool) InputHash(file string, vars VariableSet) (string, error) {
+	vm := p.get()
+	defer p.put(vm)
+	return vm.InputHash(file, vars)
+}
+
+// ImportGraph implements the interface method.
+func (p *vmPool) ImportGraph(file string) (map[string][]Import, error) {
+	vm := p.get()
+	defer p.put(vm)
+	return vm.ImportGraph(file)
 }
 
 // defaultImporter returns the standard importer.
 func defaultImporter(c Config) jsonnet.Importer {
 	var imps []importers.ExtendedImporter
 	for _, ds := range c.DataSources {
-		imps = append(imps, importers.NewDataSourceImporter(ds))
+		imps = append(imps, importers.NewDataSourceImporter(ds, c.MaxDataSourceBytes))
+	}
+	var libs []string
+	for name := range c.Libraries {
+		libs = append(libs, name)
+	}
+	sort.Strings(libs)
+	for _, name := range libs {
+		imps = append(imps, importers.NewFSImporter(name, c.Libraries[name]))
+	}
+	fi := importers.NewFileImporter(&jsonnet.FileImporter{
+		JPaths: c.LibPaths,
+	})
+	var last importers.ExtendedImporter = fi
+	if c.HTTPImports != nil {
+		last = importers.NewHTTPImporter(c.HTTPImports.fetcher, fi)
 	}
 	std := []importers.ExtendedImporter{
+		importers.NewStdlibImporter(),
 		importers.NewGlobImporter("import"),
 		importers.NewGlobImporter("importstr"),
-		importers.NewFileImporter(&jsonnet.FileImporter{
-			JPaths: c.LibPaths,
-		}),
+		last,
 	}
 	return importers.NewCompositeImporter(append(imps, std...)...)
 }
 
-// newJsonnetVM create a new jsonnet VM with native functions and importer registered.
-func newJsonnetVM(config Config) *jsonnet.VM {
+// newJsonnetVM create a new jsonnet VM with native functions and the supplied importer registered.
+func newJsonnetVM(importer jsonnet.Importer) *jsonnet.VM {
 	jvm := jsonnet.MakeVM()
 	natives.Register(jvm)
-	jvm.Importer(defaultImporter(config))
+	jvm.Importer(importer)
 	return jvm
 }
 
-// New constructs a new VM based on the supplied config. The returned VM interface is safe for concurrent use.
+// New constructs a new VM based on the supplied config. The returned VM interface is safe for concurrent use
+// and reuses underlying jsonnet VMs across evaluations.
 func New(config Config) VM {
 	return newPool(config)
 }
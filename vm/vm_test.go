@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"runtime"
 	"strings"
 	"sync"
 	"testing"
@@ -73,6 +74,39 @@ func TestVMEvalCode(t *testing.T) {
 	assert.True(t, data.Bar)
 }
 
+func TestVMEvalCodeRelativeImport(t *testing.T) {
+	vm := New(Config{LibPaths: []string{"testdata/vmlib"}})
+	out, err := vm.EvalCode(
+		"testdata/fake.jsonnet",
+		MakeCode(`
+			import 'vmtest.jsonnet'
+		`),
+		VariableSet{}.WithVars(
+			NewVar("foo", "fooVal"),
+			NewCodeVar("bar", "true"),
+		),
+	)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{ "foo": "fooVal", "bar": true }`, out)
+}
+
+func TestVMStdlib(t *testing.T) {
+	vm := New(Config{})
+	out, err := vm.EvalCode(
+		"fake.jsonnet",
+		MakeCode(`
+			local q = import 'qbec://std';
+			{ env: q.env(), foo: q.property('foo') }
+		`),
+		VariableSet{}.WithVars(
+			NewVar("qbec.io/env", "dev"),
+			NewCodeVar("qbec.io/envProperties", `{ foo: 'bar' }`),
+		),
+	)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{ "env": "dev", "foo": "bar" }`, out)
+}
+
 func TestVMEvalNonExistentFile(t *testing.T) {
 	vm := New(Config{})
 	_, err := vm.EvalFile("testdata/does-not-exist.jsonnet", VariableSet{})
@@ -234,3 +268,55 @@ func TestVMLint(t *testing.T) {
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "foo.jsonnet:1:1-2")
 }
+
+func TestVMPoolWarmup(t *testing.T) {
+	p := newPool(Config{Warmup: 3})
+	assert.Equal(t, 3, len(p.free))
+	v := p.get()
+	assert.Equal(t, 2, len(p.free))
+	p.put(v)
+	assert.Equal(t, 3, len(p.free))
+	for i := 0; i < cap(p.free)+2; i++ {
+		p.put(p.newVM())
+	}
+	assert.Equal(t, cap(p.free), len(p.free))
+}
+
+func TestVMPoolReuseDoesNotLeakVars(t *testing.T) {
+	vm := New(Config{Warmup: 1})
+	code := MakeCode(`std.extVar('foo')`)
+	out, err := vm.EvalCode("a.jsonnet", code, VariableSet{}.WithVars(NewVar("foo", "bar")))
+	require.NoError(t, err)
+	assert.Equal(t, `"bar"`, strings.TrimSpace(out))
+	_, err = vm.EvalCode("a.jsonnet", code, VariableSet{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Undefined external variable: foo")
+}
+
+const benchCode = `{ a: std.extVar('foo'), b: [x * 2 for x in std.range(1, 100)] }`
+
+func BenchmarkEvalCodeNewVM(b *testing.B) {
+	vars := VariableSet{}.WithVars(NewVar("foo", "bar"))
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, err := New(Config{}).EvalCode("bench.jsonnet", MakeCode(benchCode), vars)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkEvalCodePooledVM(b *testing.B) {
+	vars := VariableSet{}.WithVars(NewVar("foo", "bar"))
+	vm := New(Config{Warmup: runtime.NumCPU()})
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, err := vm.EvalCode("bench.jsonnet", MakeCode(benchCode), vars)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
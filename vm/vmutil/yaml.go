@@ -0,0 +1,179 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package vmutil
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLDocument is a single YAML document that retains comments and the order of keys such that it can be
+// modified and written back without destroying the formatting of the original content. Values are addressed
+// by a path of object keys.
+type YAMLDocument struct {
+	doc yaml.Node
+}
+
+// ParseYAMLDocument parses the supplied content as a single YAML document. Empty content is treated as an
+// empty object.
+func ParseYAMLDocument(content []byte) (*YAMLDocument, error) {
+	var d YAMLDocument
+	if err := yaml.Unmarshal(content, &d.doc); err != nil {
+		return nil, errors.Wrap(err, "YAML unmarshal")
+	}
+	if len(d.doc.Content) == 0 {
+		d.doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	return &d, nil
+}
+
+func mappingIndex(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// lookup returns the node at the supplied path, or nil if it does not exist.
+func (d *YAMLDocument) lookup(path []string) *yaml.Node {
+	n := d.doc.Content[0]
+	for _, key := range path {
+		if n.Kind == yaml.AliasNode {
+			n = n.Alias
+		}
+		if n.Kind != yaml.MappingNode {
+			return nil
+		}
+		i := mappingIndex(n, key)
+		if i < 0 {
+			return nil
+		}
+		n = n.Content[i+1]
+	}
+	return n
+}
+
+// Get returns the value at the supplied path decoded into a generic data structure, and whether the path exists.
+// An empty path returns the whole document.
+func (d *YAMLDocument) Get(path ...string) (value interface{}, found bool, err error) {
+	n := d.lookup(path)
+	if n == nil {
+		return nil, false, nil
+	}
+	if err := n.Decode(&value); err != nil {
+		return nil, true, errors.Wrapf(err, "decode %s", strings.Join(path, "."))
+	}
+	return value, true, nil
+}
+
+// Keys returns the keys of the object at the supplied path in document order. It returns nil if the path
+// does not exist or is not an object.
+func (d *YAMLDocument) Keys(path ...string) []string {
+	n := d.lookup(path)
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	var ret []string
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		ret = append(ret, n.Content[i].Value)
+	}
+	return ret
+}
+
+// Set sets the value at the supplied path, creating intermediate objects as needed. An existing value is
+// replaced in place such that its position and comments are retained. New keys are added at the end of the
+// object that contains them. Intermediate values that are null are replaced with objects and it is an error
+// for an intermediate value to exist and be anything other than an object.
+func (d *YAMLDocument) Set(value interface{}, path ...string) error {
+	if len(path) == 0 {
+		return fmt.Errorf("set: empty path")
+	}
+	var v yaml.Node
+	if err := v.Encode(value); err != nil {
+		return errors.Wrapf(err, "encode value for %s", strings.Join(path, "."))
+	}
+	// empty collections are encoded in flow style, use block style instead such that values added later
+	// are formatted like the rest of the document.
+	if (v.Kind == yaml.MappingNode || v.Kind == yaml.SequenceNode) && len(v.Content) == 0 {
+		v.Style &^= yaml.FlowStyle
+	}
+	n := d.doc.Content[0]
+	for i, key := range path {
+		if n.Kind != yaml.MappingNode {
+			return fmt.Errorf("set %s: %s is not an object", strings.Join(path, "."), strings.Join(path[:i], "."))
+		}
+		idx := mappingIndex(n, key)
+		last := i == len(path)-1
+		switch {
+		case idx < 0 && last:
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, &v)
+		case idx < 0:
+			child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, child)
+			n = child
+		case last:
+			old := n.Content[idx+1]
+			v.HeadComment, v.LineComment, v.FootComment = old.HeadComment, old.LineComment, old.FootComment
+			n.Content[idx+1] = &v
+		default:
+			child := n.Content[idx+1]
+			if child.Kind == yaml.ScalarNode && child.Tag == "!!null" {
+				child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", LineComment: child.LineComment}
+				n.Content[idx+1] = child
+			}
+			n = child
+		}
+	}
+	return nil
+}
+
+// Delete removes the value at the supplied path and returns true if it existed.
+func (d *YAMLDocument) Delete(path ...string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	parent := d.lookup(path[:len(path)-1])
+	if parent == nil || parent.Kind != yaml.MappingNode {
+		return false
+	}
+	i := mappingIndex(parent, path[len(path)-1])
+	if i < 0 {
+		return false
+	}
+	parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+	return true
+}
+
+// Bytes returns the serialized form of the document using an indent of 2 spaces.
+func (d *YAMLDocument) Bytes() ([]byte, error) {
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&d.doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
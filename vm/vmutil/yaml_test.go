@@ -0,0 +1,119 @@
+/*
+   Copyright 2021 Splunk Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package vmutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const commentedDoc = `# top comment
+kind: App
+spec:
+  # the environments
+  environments:
+    dev:
+      server: https://dev # dev server
+      properties:
+        replicas: 1
+  libPaths: # library paths
+    - lib
+`
+
+func TestYAMLDocumentRoundTrip(t *testing.T) {
+	d, err := ParseYAMLDocument([]byte(commentedDoc))
+	require.NoError(t, err)
+	b, err := d.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, commentedDoc, string(b))
+}
+
+func TestYAMLDocumentEdits(t *testing.T) {
+	d, err := ParseYAMLDocument([]byte(commentedDoc))
+	require.NoError(t, err)
+	a := assert.New(t)
+
+	v, found, err := d.Get("spec", "environments", "dev", "properties", "replicas")
+	require.NoError(t, err)
+	a.True(found)
+	a.EqualValues(1, v)
+	_, found, err = d.Get("spec", "foo", "bar")
+	require.NoError(t, err)
+	a.False(found)
+	a.Equal([]string{"kind", "spec"}, d.Keys())
+	a.Nil(d.Keys("kind"))
+
+	require.NoError(t, d.Set("https://dev2", "spec", "environments", "dev", "server"))
+	require.NoError(t, d.Set(map[string]interface{}{}, "spec", "environments", "prod"))
+	require.NoError(t, d.Set("https://prod", "spec", "environments", "prod", "server"))
+	require.NoError(t, d.Set([]string{"lib", "vendor"}, "spec", "libPaths"))
+	require.NoError(t, d.Set("prod-ns", "spec", "environments", "prod", "properties", "namespace"))
+	a.True(d.Delete("spec", "environments", "dev", "properties"))
+	a.False(d.Delete("spec", "environments", "dev", "properties"))
+	a.False(d.Delete())
+
+	b, err := d.Bytes()
+	require.NoError(t, err)
+	a.Equal(`# top comment
+kind: App
+spec:
+  # the environments
+  environments:
+    dev:
+      server: https://dev2 # dev server
+    prod:
+      server: https://prod
+      properties:
+        namespace: prod-ns
+  libPaths: # library paths
+    - lib
+    - vendor
+`, string(b))
+}
+
+func TestYAMLDocumentEmpty(t *testing.T) {
+	d, err := ParseYAMLDocument(nil)
+	require.NoError(t, err)
+	require.NoError(t, d.Set("bar", "foo"))
+	b, err := d.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, "foo: bar\n", string(b))
+
+	d, err = ParseYAMLDocument([]byte("foo:\n"))
+	require.NoError(t, err)
+	require.NoError(t, d.Set("baz", "foo", "bar"))
+	b, err = d.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, "foo:\n  bar: baz\n", string(b))
+}
+
+func TestYAMLDocumentNegative(t *testing.T) {
+	_, err := ParseYAMLDocument([]byte("foo: [bar"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "YAML unmarshal")
+
+	d, err := ParseYAMLDocument([]byte("foo: bar\n"))
+	require.NoError(t, err)
+	err = d.Set("baz", "foo", "bar")
+	require.Error(t, err)
+	assert.Equal(t, "set foo.bar: foo is not an object", err.Error())
+	err = d.Set("baz")
+	require.Error(t, err)
+	assert.Equal(t, "set: empty path", err.Error())
+}